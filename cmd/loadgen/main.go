@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+const (
+	defaultAddress          = "localhost:50053"
+	defaultSecretConfigPath = "configs/dev_client/secret.dev.yaml"
+	defaultTLSConfigPath    = "configs/dev_client/tls.yaml"
+)
+
+// loadgen drives a mix of CreateKey, GetKey and RotateKey calls against a running server
+// from concurrent workers, then reports the latency percentiles and error rate of each
+// operation. With -max-p99 or -max-error-rate it exits non-zero when a threshold is
+// exceeded, so that a release pipeline can fail on a performance regression.
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "loadgen:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func run(args []string, out, errOut io.Writer) error {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	address := fs.String("addr", defaultAddress, "address of the server")
+	tlsConfigPath := fs.String("tls-config", defaultTLSConfigPath, "client TLS config file")
+	insecureConn := fs.Bool("insecure", false, "connect without TLS")
+	secretConfigPath := fs.String("secret-config", defaultSecretConfigPath, "file with the id and secret of the client to authenticate as")
+	mixSpec := fs.String("mix", "create=1,get=8,rotate=1", "relative weights of the operations, from create, get and rotate")
+	keyType := fs.String("key-type", "aes-256", "type of the keys created")
+	concurrency := fs.Int("concurrency", 8, "number of concurrent workers")
+	duration := fs.Duration("duration", 30*time.Second, "how long to generate load")
+	requests := fs.Int("requests", 0, "stop after this many requests instead of after -duration, when positive")
+	rate := fs.Float64("rate", 0, "requests per second across all workers, unlimited when 0")
+	seedKeys := fs.Int("seed-keys", 10, "keys created before the run for get and rotate to target")
+	callTimeout := fs.Duration("timeout", 10*time.Second, "timeout of each call")
+	format := fs.String("o", "table", "output format: table or json")
+	maxP99 := fs.Duration("max-p99", 0, "fail when the p99 latency of any operation exceeds this, when positive")
+	maxErrorRate := fs.Float64("max-error-rate", -1, "fail when the error rate of any operation exceeds this fraction, when not negative")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	if *concurrency <= 0 {
+		return errors.New("-concurrency must be positive")
+	}
+	mix, err := parseMix(*mixSpec)
+	if err != nil {
+		return err
+	}
+	parsedType, err := parseKeyType(*keyType)
+	if err != nil {
+		return err
+	}
+
+	t, err := newTarget(*address, *tlsConfigPath, *secretConfigPath, *insecureConn)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	t.keyType = parsedType
+	t.callTimeout = *callTimeout
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if mix.needsKeys() {
+		if err := t.seed(ctx, max(*seedKeys, 1)); err != nil {
+			return fmt.Errorf("failed to create seed keys: %w", err)
+		}
+	}
+
+	fmt.Fprintf(errOut, "loadgen: %d workers against %s, mix %s\n", *concurrency, *address, mix)
+	r := newRunner(t, mix, *concurrency, *requests, *rate)
+	if *requests <= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+	rep := r.run(ctx)
+
+	if *format == "json" {
+		err = rep.writeJSON(out)
+	} else {
+		err = rep.writeTable(out)
+	}
+	if err != nil {
+		return err
+	}
+	return rep.check(*maxP99, *maxErrorRate)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// operation is a kind of call that the load is made of.
+type operation int
+
+const (
+	opCreate operation = iota
+	opGet
+	opRotate
+	numOperations
+)
+
+var operationNames = [numOperations]string{
+	opCreate: "create",
+	opGet:    "get",
+	opRotate: "rotate",
+}
+
+func (o operation) String() string {
+	return operationNames[o]
+}
+
+// mix is the relative weight of each operation in the load.
+type mix struct {
+	weights [numOperations]int
+	total   int
+}
+
+// parseMix parses weights given as create=1,get=8,rotate=1. Operations left out get no
+// load.
+func parseMix(spec string) (mix, error) {
+	var m mix
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return mix{}, fmt.Errorf("invalid mix entry %q, want operation=weight", part)
+		}
+		op, found := operation(-1), false
+		for i, opName := range operationNames {
+			if opName == name {
+				op, found = operation(i), true
+			}
+		}
+		if !found {
+			return mix{}, fmt.Errorf("unknown operation %q in mix", name)
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return mix{}, fmt.Errorf("invalid weight %q for %s", value, name)
+		}
+		m.weights[op] = weight
+	}
+	for _, w := range m.weights {
+		m.total += w
+	}
+	if m.total == 0 {
+		return mix{}, fmt.Errorf("mix %q has no weight", spec)
+	}
+	return m, nil
+}
+
+// pick returns an operation at random according to the weights.
+func (m mix) pick() operation {
+	n := rand.IntN(m.total)
+	for op, w := range m.weights {
+		if n < w {
+			return operation(op)
+		}
+		n -= w
+	}
+	return opCreate
+}
+
+// needsKeys reports whether the mix has operations on existing keys.
+func (m mix) needsKeys() bool {
+	return m.weights[opGet] > 0 || m.weights[opRotate] > 0
+}
+
+func (m mix) String() string {
+	parts := make([]string, 0, numOperations)
+	for op, w := range m.weights {
+		if w > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", operation(op), w))
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// report summarizes a run per operation.
+type report struct {
+	Elapsed    time.Duration     `json:"elapsed_ns"`
+	Operations []operationReport `json:"operations"`
+}
+
+type operationReport struct {
+	Operation  string         `json:"operation"`
+	Requests   int            `json:"requests"`
+	Errors     int            `json:"errors"`
+	ErrorRate  float64        `json:"error_rate"`
+	Throughput float64        `json:"requests_per_second"`
+	Min        time.Duration  `json:"min_ns"`
+	Mean       time.Duration  `json:"mean_ns"`
+	P50        time.Duration  `json:"p50_ns"`
+	P90        time.Duration  `json:"p90_ns"`
+	P99        time.Duration  `json:"p99_ns"`
+	Max        time.Duration  `json:"max_ns"`
+	ErrorCodes map[string]int `json:"error_codes,omitempty"`
+}
+
+// newReport merges the samples of the workers. Latency percentiles only cover successful
+// calls, so that fast failures do not flatter them.
+func newReport(elapsed time.Duration, samples []*workerSamples) *report {
+	rep := &report{Elapsed: elapsed}
+	for op := range numOperations {
+		var latencies []time.Duration
+		codes := map[string]int{}
+		for _, s := range samples {
+			latencies = append(latencies, s.latencies[op]...)
+			for code, n := range s.errors[op] {
+				codes[code] += n
+			}
+		}
+		opRep := operationReport{Operation: op.String()}
+		for _, n := range codes {
+			opRep.Errors += n
+		}
+		opRep.Requests = len(latencies) + opRep.Errors
+		if opRep.Requests == 0 {
+			continue
+		}
+		if opRep.Errors > 0 {
+			opRep.ErrorCodes = codes
+		}
+		opRep.ErrorRate = float64(opRep.Errors) / float64(opRep.Requests)
+		opRep.Throughput = float64(opRep.Requests) / elapsed.Seconds()
+		if len(latencies) > 0 {
+			slices.Sort(latencies)
+			var sum time.Duration
+			for _, l := range latencies {
+				sum += l
+			}
+			opRep.Min = latencies[0]
+			opRep.Mean = sum / time.Duration(len(latencies))
+			opRep.P50 = percentile(latencies, 0.50)
+			opRep.P90 = percentile(latencies, 0.90)
+			opRep.P99 = percentile(latencies, 0.99)
+			opRep.Max = latencies[len(latencies)-1]
+		}
+		rep.Operations = append(rep.Operations, opRep)
+	}
+	return rep
+}
+
+// percentile returns the nearest-rank percentile p of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func (r *report) writeTable(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OPERATION\tREQUESTS\tRPS\tERRORS\tERROR RATE\tMIN\tMEAN\tP50\tP90\tP99\tMAX")
+	for _, op := range r.Operations {
+		fmt.Fprintf(w, "%s\t%d\t%.1f\t%d\t%.2f%%\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			op.Operation, op.Requests, op.Throughput, op.Errors, op.ErrorRate*100,
+			round(op.Min), round(op.Mean), round(op.P50), round(op.P90), round(op.P99), round(op.Max))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, op := range r.Operations {
+		if len(op.ErrorCodes) == 0 {
+			continue
+		}
+		codes := make([]string, 0, len(op.ErrorCodes))
+		for code := range op.ErrorCodes {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		parts := make([]string, len(codes))
+		for i, code := range codes {
+			parts[i] = fmt.Sprintf("%s=%d", code, op.ErrorCodes[code])
+		}
+		fmt.Fprintf(out, "%s errors: %s\n", op.Operation, strings.Join(parts, " "))
+	}
+	_, err := fmt.Fprintf(out, "elapsed %s\n", round(r.Elapsed))
+	return err
+}
+
+func (r *report) writeJSON(out io.Writer) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// check returns an error naming every operation whose p99 latency or error rate exceeds
+// its threshold. A zero maxP99 or a negative maxErrorRate disables that check.
+func (r *report) check(maxP99 time.Duration, maxErrorRate float64) error {
+	var errs []error
+	for _, op := range r.Operations {
+		if maxP99 > 0 && op.P99 > maxP99 {
+			errs = append(errs, fmt.Errorf("%s p99 %s exceeds %s", op.Operation, round(op.P99), maxP99))
+		}
+		if maxErrorRate >= 0 && op.ErrorRate > maxErrorRate {
+			errs = append(errs, fmt.Errorf("%s error rate %.4f exceeds %.4f", op.Operation, op.ErrorRate, maxErrorRate))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/status"
+)
+
+// runner spreads the load over concurrent workers, each keeping its own samples so that
+// recording does not contend.
+type runner struct {
+	target      *target
+	mix         mix
+	concurrency int
+	// requests is the total number of requests to send, unbounded when not positive.
+	requests int64
+	limiter  *rate.Limiter
+	sent     atomic.Int64
+}
+
+func newRunner(t *target, m mix, concurrency, requests int, perSecond float64) *runner {
+	r := &runner{target: t, mix: m, concurrency: concurrency, requests: int64(requests)}
+	if perSecond > 0 {
+		r.limiter = rate.NewLimiter(rate.Limit(perSecond), 1)
+	}
+	return r
+}
+
+// run generates load until ctx is done or the request budget is spent.
+func (r *runner) run(ctx context.Context) *report {
+	samples := make([]*workerSamples, r.concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range samples {
+		samples[i] = newWorkerSamples()
+		wg.Add(1)
+		go func(s *workerSamples) {
+			defer wg.Done()
+			r.work(ctx, s)
+		}(samples[i])
+	}
+	wg.Wait()
+	return newReport(time.Since(start), samples)
+}
+
+func (r *runner) work(ctx context.Context, s *workerSamples) {
+	for ctx.Err() == nil {
+		if r.requests > 0 && r.sent.Add(1) > r.requests {
+			return
+		}
+		if r.limiter != nil && r.limiter.Wait(ctx) != nil {
+			return
+		}
+		op := r.mix.pick()
+		authCtx, err := r.target.authContext(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.record(op, 0, err)
+			continue
+		}
+		callCtx, cancel := context.WithTimeout(authCtx, r.target.callTimeout)
+		began := time.Now()
+		err = r.target.call(callCtx, op)
+		elapsed := time.Since(began)
+		cancel()
+		// A call cut short by the end of the run is neither a success nor a failure.
+		if err != nil && ctx.Err() != nil {
+			return
+		}
+		s.record(op, elapsed, err)
+	}
+}
+
+// workerSamples are the latencies and errors one worker observed.
+type workerSamples struct {
+	latencies [numOperations][]time.Duration
+	errors    [numOperations]map[string]int
+}
+
+func newWorkerSamples() *workerSamples {
+	s := &workerSamples{}
+	for op := range s.errors {
+		s.errors[op] = map[string]int{}
+	}
+	return s
+}
+
+// record keeps the latency of a successful call, or the gRPC code of a failed one.
+func (s *workerSamples) record(op operation, elapsed time.Duration, err error) {
+	if err != nil {
+		s.errors[op][status.Code(err).String()]++
+		return
+	}
+	s.latencies[op] = append(s.latencies[op], elapsed)
+}
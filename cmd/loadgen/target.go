@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/wiring"
+	"github.com/spounge-ai/polykey/pkg/testutil"
+	cmn "github.com/spounge-ai/spounge-proto/gen/go/common/v2"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// tokenRenewMargin is how long before its expiry the access token is renewed, so that no
+// call is rejected for carrying an expired one.
+const tokenRenewMargin = 30 * time.Second
+
+// target is the server under load. It authenticates once and shares the access token
+// between the workers, and keeps the IDs of the keys that get and rotate pick from.
+type target struct {
+	conn        *grpc.ClientConn
+	service     pk.PolykeyServiceClient
+	creds       testutil.ClientSecretConfig
+	keyType     pk.KeyType
+	callTimeout time.Duration
+
+	mu        sync.Mutex
+	token     string
+	tier      cmn.ClientTier
+	renewAt   time.Time
+	keyIDs    []string
+	createdAt time.Time
+}
+
+func newTarget(address, tlsConfigPath, secretConfigPath string, insecureConn bool) (*target, error) {
+	data, err := os.ReadFile(secretConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client secret file %s: %w", secretConfigPath, err)
+	}
+	var creds testutil.ClientSecretConfig
+	if err := yaml.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse client secret file %s: %w", secretConfigPath, err)
+	}
+	if creds.ID == "" || creds.Secret == "" {
+		return nil, fmt.Errorf("client secret file %s needs an id and a secret", secretConfigPath)
+	}
+
+	transport := insecure.NewCredentials()
+	if !insecureConn {
+		tlsConfig, err := wiring.ConfigureClientTLS(tlsConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		transport = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(transport))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	return &target{
+		conn:      conn,
+		service:   pk.NewPolykeyServiceClient(conn),
+		creds:     creds,
+		createdAt: time.Now(),
+	}, nil
+}
+
+func (t *target) Close() {
+	_ = t.conn.Close()
+}
+
+// authContext returns ctx carrying a valid access token, authenticating first when there
+// is none or it is about to expire. Authentication is not timed as part of any operation.
+func (t *target) authContext(ctx context.Context) (context.Context, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == "" || time.Now().After(t.renewAt) {
+		authCtx, cancel := context.WithTimeout(ctx, t.callTimeout)
+		defer cancel()
+		resp, err := t.service.Authenticate(authCtx, &pk.AuthenticateRequest{
+			ClientId: t.creds.ID,
+			ApiKey:   t.creds.Secret,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+		t.token = resp.GetAccessToken()
+		t.tier = resp.GetClientTier()
+		t.renewAt = time.Now().Add(time.Duration(resp.GetExpiresIn())*time.Second - tokenRenewMargin)
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+t.token), nil
+}
+
+func (t *target) requester() *pk.RequesterContext {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &pk.RequesterContext{ClientIdentity: t.creds.ID, ClientTier: t.tier}
+}
+
+// seed creates n keys for get and rotate to target before the run starts.
+func (t *target) seed(ctx context.Context, n int) error {
+	for range n {
+		ctx, err := t.authContext(ctx)
+		if err != nil {
+			return err
+		}
+		callCtx, cancel := context.WithTimeout(ctx, t.callTimeout)
+		err = t.createKey(callCtx)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call runs one operation against the server.
+func (t *target) call(ctx context.Context, op operation) error {
+	switch op {
+	case opCreate:
+		return t.createKey(ctx)
+	case opGet:
+		keyID, err := t.pickKey()
+		if err != nil {
+			return err
+		}
+		_, err = t.service.GetKey(ctx, &pk.GetKeyRequest{KeyId: keyID, RequesterContext: t.requester()})
+		return err
+	case opRotate:
+		keyID, err := t.pickKey()
+		if err != nil {
+			return err
+		}
+		_, err = t.service.RotateKey(ctx, &pk.RotateKeyRequest{KeyId: keyID, RequesterContext: t.requester()})
+		return err
+	default:
+		return fmt.Errorf("unknown operation %d", op)
+	}
+}
+
+func (t *target) createKey(ctx context.Context) error {
+	resp, err := t.service.CreateKey(ctx, &pk.CreateKeyRequest{
+		KeyType:          t.keyType,
+		RequesterContext: t.requester(),
+		Description:      "loadgen",
+		Tags: map[string]string{
+			"source": "loadgen",
+			"run":    t.createdAt.UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.keyIDs = append(t.keyIDs, resp.GetMetadata().GetKeyId())
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *target) pickKey() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.keyIDs) == 0 {
+		return "", errors.New("no keys to target")
+	}
+	return t.keyIDs[rand.IntN(len(t.keyIDs))], nil
+}
+
+// parseKeyType accepts a key type as aes-256 or KEY_TYPE_AES_256.
+func parseKeyType(value string) (pk.KeyType, error) {
+	name := strings.ToUpper(strings.ReplaceAll(value, "-", "_"))
+	if !strings.HasPrefix(name, "KEY_TYPE_") {
+		name = "KEY_TYPE_" + name
+	}
+	keyType, ok := pk.KeyType_value[name]
+	if !ok || keyType == int32(pk.KeyType_KEY_TYPE_UNSPECIFIED) {
+		return 0, fmt.Errorf("unknown key type %q", value)
+	}
+	return pk.KeyType(keyType), nil
+}
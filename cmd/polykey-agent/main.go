@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/agent"
+	"github.com/spounge-ai/polykey/internal/polykeyclient"
+	"github.com/spounge-ai/polykey/internal/wiring"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// polykey-agent runs next to a chatty client as a sidecar. It authenticates once as the
+// client whose API key is in POLYKEY_AGENT_API_KEY or -api-key-file, and serves the keys
+// the pod's processes read from a local cache over HTTP on the Unix socket -socket,
+// which the containers share through a volume. An example pod is in
+// deployments/k8s/agent.
+func main() {
+	socket := flag.String("socket", "/var/run/polykey/agent.sock", "Unix socket the API is served on")
+	socketMode := flag.String("socket-mode", "0660", "permissions of the socket")
+	address := flag.String("address", envOr("POLYKEY_AGENT_ADDRESS", "polykey:50053"), "address of the Polykey gRPC server")
+	clientID := flag.String("client-id", os.Getenv("POLYKEY_AGENT_CLIENT_ID"), "client ID the agent authenticates as")
+	apiKeyFile := flag.String("api-key-file", "", "file holding the client's API key, instead of POLYKEY_AGENT_API_KEY")
+	certFile := flag.String("tls-cert", "", "client certificate for mTLS")
+	keyFile := flag.String("tls-key", "", "client key for mTLS")
+	caFile := flag.String("tls-ca", "", "CA of the server certificate")
+	ttl := flag.Duration("ttl", 5*time.Minute, "how long a key is served from the cache before it is read again")
+	maxEntries := flag.Int("max-entries", 1024, "maximum number of cached keys")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	if *clientID == "" {
+		log.Fatalf("FATAL: set POLYKEY_AGENT_CLIENT_ID or -client-id")
+	}
+	apiKey := os.Getenv("POLYKEY_AGENT_API_KEY")
+	if *apiKeyFile != "" {
+		data, err := os.ReadFile(*apiKeyFile)
+		if err != nil {
+			log.Fatalf("FATAL: failed to read the API key: %v", err)
+		}
+		apiKey = strings.TrimSpace(string(data))
+	}
+	if apiKey == "" {
+		log.Fatalf("FATAL: set POLYKEY_AGENT_API_KEY or -api-key-file")
+	}
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil {
+		log.Fatalf("FATAL: invalid -socket-mode %q: %v", *socketMode, err)
+	}
+
+	creds := insecure.NewCredentials()
+	if *certFile != "" {
+		tlsConfig, err := wiring.ClientTLSConfig{CertFile: *certFile, KeyFile: *keyFile, CAFile: *caFile}.TLSConfig()
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	tokens := polykeyclient.NewTokenSource(*clientID, apiKey)
+	conn, err := polykeyclient.Dial(*address, tokens, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Authenticate before serving, so that bad credentials fail the sidecar at startup.
+	if _, err := tokens.Requester(ctx); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	cache := agent.New(conn, tokens, agent.Config{TTL: *ttl, MaxEntries: *maxEntries}, logger)
+	go cache.Run(ctx)
+
+	if err := os.MkdirAll(filepath.Dir(*socket), 0o755); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if err := os.Remove(*socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("FATAL: failed to remove the stale socket: %v", err)
+	}
+	lis, err := net.Listen("unix", *socket)
+	if err != nil {
+		log.Fatalf("FATAL: failed to listen on %s: %v", *socket, err)
+	}
+	if err := os.Chmod(*socket, os.FileMode(mode)); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	server := &http.Server{Handler: cache.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("serving the agent", "socket", *socket, "address", *address, "clientId", *clientID, "ttl", *ttl)
+	if err := server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("FATAL: %v", err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/domain"
+	infra_auth "github.com/spounge-ai/polykey/internal/infra/auth"
+	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/kms"
+	"github.com/spounge-ai/polykey/internal/wiring"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+const (
+	// doctorCheckTimeout bounds each check, so that an unreachable dependency fails its
+	// check instead of hanging the doctor.
+	doctorCheckTimeout = 30 * time.Second
+	// maxClockSkew is the largest difference from the database clock that passes. Tokens
+	// are checked against the local clock, and key expiry against the database's.
+	maxClockSkew = 5 * time.Second
+)
+
+// doctor runs the dependency checks of the -doctor mode and collects their results.
+type doctor struct {
+	results [][]string
+	failed  bool
+}
+
+// check runs fn and records whether it passed, along with the detail it returned or its
+// error.
+func (d *doctor) check(name string, fn func(ctx context.Context) (string, error)) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorCheckTimeout)
+	defer cancel()
+	detail, err := fn(ctx)
+	if err != nil {
+		d.failed = true
+		// Multi-line errors, such as config decoding errors, would break the table.
+		d.results = append(d.results, []string{name, "FAIL", strings.Join(strings.Fields(err.Error()), " ")})
+		return false
+	}
+	d.results = append(d.results, []string{name, "ok", detail})
+	return true
+}
+
+func (d *doctor) skip(name, reason string) {
+	d.results = append(d.results, []string{name, "skip", reason})
+}
+
+func (d *doctor) write(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+	for _, result := range d.results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result[0], result[1], result[2])
+	}
+	_ = w.Flush()
+}
+
+// runDoctor checks every dependency the server needs to boot with the config at
+// configPath, prints the result of each to out and reports whether all passed. A check
+// that depends on one that failed is skipped.
+func runDoctor(configPath string, out io.Writer) bool {
+	d := &doctor{}
+
+	var fileCfg *infra_config.Config
+	if d.check("config file", func(context.Context) (string, error) {
+		var err error
+		if fileCfg, err = infra_config.ReadFile(configPath); err != nil {
+			return "", err
+		}
+		if configPath == "" {
+			return "defaults and environment only", nil
+		}
+		return configPath, nil
+	}) {
+		d.check("bootstrap secrets", func(context.Context) (string, error) {
+			loaded, err := infra_config.CheckBootstrapSecrets(fileCfg)
+			if err != nil || loaded {
+				return "read from " + fileCfg.BootstrapSecretsProvider, err
+			}
+			return "none loaded, as aws is disabled", nil
+		})
+	} else {
+		d.skip("bootstrap secrets", "the config file cannot be read")
+	}
+
+	var cfg *infra_config.Config
+	if !d.check("config", func(context.Context) (string, error) {
+		var err error
+		cfg, err = infra_config.Load(configPath)
+		return "valid", err
+	}) {
+		for _, name := range []string{"tls", "database", "clock skew", "kms", "client store"} {
+			d.skip(name, "the config does not load")
+		}
+		d.write(out)
+		return false
+	}
+
+	d.check("tls", func(context.Context) (string, error) { return checkTLS(cfg) })
+
+	container := wiring.NewContainer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer container.Close()
+
+	var pool *pgxpool.Pool
+	if d.check("database", func(ctx context.Context) (string, error) {
+		var err error
+		if pool, err = container.GetPgxPool(ctx); err != nil {
+			return "", err
+		}
+		return checkDatabase(ctx, pool)
+	}) {
+		d.check("clock skew", func(ctx context.Context) (string, error) { return checkClockSkew(ctx, pool) })
+	} else {
+		d.skip("clock skew", "the database is not reachable")
+	}
+
+	var providers map[string]kms.KMSProvider
+	if d.check("kms", func(ctx context.Context) (string, error) {
+		var err error
+		providers, err = container.GetKMSProviders(ctx)
+		return fmt.Sprintf("%d providers configured", len(providers)), err
+	}) {
+		names := make([]string, 0, len(providers))
+		for name := range providers {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			d.check("kms "+name, func(ctx context.Context) (string, error) { return checkKMSRoundTrip(ctx, providers[name]) })
+		}
+	}
+
+	d.check("client store", func(context.Context) (string, error) {
+		store, err := infra_auth.NewFileClientStore(cfg.ClientCredentialsPath)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d clients in %s", store.GetClientCount(), cfg.ClientCredentialsPath), nil
+	})
+
+	d.write(out)
+	return !d.failed
+}
+
+func checkTLS(cfg *infra_config.Config) (string, error) {
+	reloadableTLS, err := wiring.NewReloadableTLS(cfg.Server.TLS, cfg.BootstrapSecrets)
+	if err != nil {
+		return "", err
+	}
+	if reloadableTLS == nil {
+		return "disabled", nil
+	}
+	cert := reloadableTLS.Certificate()
+	leaf := cert.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return "", fmt.Errorf("failed to parse the server certificate: %w", err)
+		}
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return "", fmt.Errorf("the server certificate expired %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+	return "server certificate expires " + leaf.NotAfter.Format(time.RFC3339), nil
+}
+
+// checkDatabase reports whether the connection is encrypted and the schema version the
+// migrations reached.
+func checkDatabase(ctx context.Context, pool *pgxpool.Pool) (string, error) {
+	var ssl bool
+	var sslVersion *string
+	err := pool.QueryRow(ctx, "SELECT ssl, version FROM pg_stat_ssl WHERE pid = pg_backend_pid()").Scan(&ssl, &sslVersion)
+	if err != nil {
+		return "", fmt.Errorf("failed to query the connection: %w", err)
+	}
+	detail := "connected without TLS"
+	if ssl && sslVersion != nil {
+		detail = "connected with " + *sslVersion
+	}
+
+	var version int64
+	var dirty bool
+	if err := pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations").Scan(&version, &dirty); err != nil {
+		return "", fmt.Errorf("%s, but the schema version cannot be read: %w", detail, err)
+	}
+	if dirty {
+		return "", fmt.Errorf("%s, but migration %d failed halfway", detail, version)
+	}
+	return fmt.Sprintf("%s, schema version %d", detail, version), nil
+}
+
+// checkClockSkew compares the local clock with the database's, allowing for the round
+// trip.
+func checkClockSkew(ctx context.Context, pool *pgxpool.Pool) (string, error) {
+	var dbNow time.Time
+	sent := time.Now()
+	if err := pool.QueryRow(ctx, "SELECT now()").Scan(&dbNow); err != nil {
+		return "", fmt.Errorf("failed to read the database clock: %w", err)
+	}
+	received := time.Now()
+	skew := dbNow.Sub(sent.Add(received.Sub(sent) / 2))
+	if skew.Abs() > maxClockSkew {
+		return "", fmt.Errorf("the local clock is %s off the database clock", skew.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s off the database clock", skew.Round(time.Millisecond)), nil
+}
+
+// checkKMSRoundTrip wraps a random DEK for a throwaway key and checks that it unwraps to
+// the same DEK.
+func checkKMSRoundTrip(ctx context.Context, provider kms.KMSProvider) (string, error) {
+	if err := provider.HealthCheck(ctx); err != nil {
+		return "", fmt.Errorf("health check failed: %w", err)
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	key := &domain.Key{
+		ID:       domain.NewKeyID(),
+		Version:  1,
+		Metadata: &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256},
+	}
+	start := time.Now()
+	wrapped, err := provider.EncryptDEK(ctx, dek, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap a DEK: %w", err)
+	}
+	key.EncryptedDEK = wrapped
+	unwrapped, err := provider.DecryptDEK(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap a DEK: %w", err)
+	}
+	if !bytes.Equal(dek, unwrapped) {
+		return "", errors.New("the unwrapped DEK differs from the wrapped one")
+	}
+	return fmt.Sprintf("wrap and unwrap in %s", time.Since(start).Round(time.Millisecond)), nil
+}
@@ -2,45 +2,97 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spounge-ai/polykey/internal/app/grpc"
 	app_errors "github.com/spounge-ai/polykey/internal/errors"
 	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	infra_health "github.com/spounge-ai/polykey/internal/infra/health"
+	"github.com/spounge-ai/polykey/internal/infra/logging"
+	"github.com/spounge-ai/polykey/internal/infra/persistence"
 	"github.com/spounge-ai/polykey/internal/wiring"
 	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
 )
 
 
 
+// activeKeyCountTTL is how long HealthCheck reuses the active key count before
+// counting again.
+const activeKeyCountTTL = 30 * time.Second
+
 func main() {
+	printConfig := flag.Bool("print-config", false, "print the effective config with the source of each value, secrets masked, and exit")
+	runChecks := flag.Bool("doctor", false, "check the config, bootstrap secrets, TLS, database, KMS providers, client store and clock, print the result of each, and exit")
+	flag.Parse()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	bootLevels, _ := logging.NewLevels("info", nil)
+	logger := newLogger(bootLevels, nil)
+	slog.SetDefault(logger)
 
-	cfg, err := infra_config.Load(os.Getenv("POLYKEY_CONFIG_PATH"))
+	configPath := os.Getenv("POLYKEY_CONFIG_PATH")
+	if *runChecks {
+		if !runDoctor(configPath, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+	cfg, err := infra_config.LoadWaiting(configPath)
 	if err != nil {
 		logger.Error("failed to load config", "error", err)
 		os.Exit(1)
 	}
+	if *printConfig {
+		writeSettings(cfg.Settings())
+		return
+	}
+	logLevels, err := newLogLevels(cfg.Logging)
+	if err != nil {
+		logger.Error("invalid logging config", "error", err)
+		os.Exit(1)
+	}
+	logger = newLogger(logLevels, cfg.Logging.RedactFields)
+	slog.SetDefault(logger)
+	watchLogLevelSignal(ctx, logger, logLevels)
 
-	tlsConfig, err := wiring.ConfigureTLS(cfg.Server.TLS, cfg.BootstrapSecrets)
+	reloadableTLS, err := wiring.NewReloadableTLS(cfg.Server.TLS, cfg.BootstrapSecrets)
 	if err != nil {
 		logger.Error("failed to configure TLS", "error", err)
 		os.Exit(1)
 	}
+	var tlsConfig *tls.Config
+	if reloadableTLS != nil {
+		tlsConfig = reloadableTLS.ServerConfig()
+	}
 
-	container := wiring.NewContainer(cfg, logger)
-	defer func() {
-		if err := container.Close(); err != nil {
-			logger.Error("failed to close container", "error", err)
+	startupSrv, _, err := grpc.NewStartupServer(cfg.Server, tlsConfig, logger.With(logging.ModuleKey, "grpc"))
+	if err != nil {
+		logger.Error("failed to create startup health server", "error", err)
+		os.Exit(1)
+	}
+	startupSrv.Start()
+
+	var probeSrv *infra_health.ProbeServer
+	if cfg.Server.Probes.Enabled {
+		probeSrv, _, err = infra_health.NewProbeServer(cfg.Server.Probes.Port, logger.With(logging.ModuleKey, "health"))
+		if err != nil {
+			logger.Error("failed to create probe server", "error", err)
+			os.Exit(1)
 		}
-	}()
+		probeSrv.Start()
+	}
+
+	container := wiring.NewContainer(cfg, logger)
 
 	deps, err := container.GetDependencies(ctx)
 	if err != nil {
@@ -48,16 +100,122 @@ func main() {
 		os.Exit(1)
 	}
 
-	errorClassifier := app_errors.NewErrorClassifier(logger)
+	pool, err := container.GetPgxPool(ctx)
+	if err != nil {
+		logger.Error("failed to get database pool", "error", err)
+		os.Exit(1)
+	}
+	startupSrv.Stop()
+
+	grpcLogger := logger.With(logging.ModuleKey, "grpc")
+	errorClassifier := app_errors.NewErrorClassifier(grpcLogger)
+	rateLimiter := deps.RateLimiter
+
+	var configWatcher *infra_config.Watcher
+	var reloadConfig func(context.Context) ([]infra_config.Change, error)
+	currentConfig := func() *infra_config.Config { return cfg }
+	if cfg.Reload.Enabled {
+		reloader := container.ConfigReloader(rateLimiter, logLevels)
+		configWatcher = infra_config.NewWatcher(configPath, cfg, logger.With(logging.ModuleKey, "config"), reloader.Apply)
+		currentConfig = configWatcher.Current
+		reloadConfig = configWatcher.Reload
+	}
+	secretRotator := container.SecretRotator(reloadableTLS)
 
-	srv, port, err := grpc.New(cfg, deps.KeyService, deps.AuthService, deps.Authorizer, deps.AuditLogger, logger, errorClassifier, tlsConfig)
+	serverDeps := grpc.PolykeyDeps{
+		Config:          cfg,
+		KeyService:      deps.KeyService,
+		AuthService:     deps.AuthService,
+		AuditService:    deps.AuditService,
+		Authorizer:      deps.Authorizer,
+		Audit:           deps.AuditLogger,
+		Logger:          grpcLogger,
+		ErrorClassifier: errorClassifier,
+		KeyEvents:       deps.KeyEvents,
+		Health:          deps.Health,
+		StartedAt:       time.Now(),
+		PoolAcquireWait: persistence.AcquireWaitSampler(pool),
+		ActiveKeyCount:  persistence.ActiveKeyCounter(pool, activeKeyCountTTL),
+		CircuitBreakers: deps.CircuitBreakers,
+		LogLevels:       logLevels,
+		RateLimiter:     rateLimiter,
+		CurrentConfig:   currentConfig,
+		TokenManager:    deps.TokenManager,
+		SecretRotator:   secretRotator,
+		ClientManager:   deps.ClientManager,
+		RoleManager:     deps.RoleManager,
+		Sessions:        deps.Sessions,
+		Caches:          deps.Caches,
+		ReloadConfig:    reloadConfig,
+		KMSRewrap:       deps.KMSRewrap,
+	}
+	srv, port, err := grpc.New(serverDeps, tlsConfig)
 	if err != nil {
 		logger.Error("failed to create server", "error", err)
 		os.Exit(1)
 	}
+	var adminSrv *grpc.Server
+	if cfg.Server.Admin.Enabled {
+		adminDeps := serverDeps
+		adminDeps.Logger = logger.With(logging.ModuleKey, "admin")
+		adminTLS, err := reloadableTLS.AdminServerConfig(cfg.Server.Admin)
+		if err != nil {
+			logger.Error("failed to configure admin TLS", "error", err)
+			os.Exit(1)
+		}
+		adminSrv, _, err = grpc.NewAdmin(adminDeps, adminTLS)
+		if err != nil {
+			logger.Error("failed to create admin server", "error", err)
+			os.Exit(1)
+		}
+	}
 
 	// Set up resource management
-	resourceManager := []lifecycle.ManagedResource{srv}
+	// Background jobs come first: the server's Start blocks until it is stopped.
+	var resourceManager []lifecycle.ManagedResource
+	if deps.LeaderElector != nil {
+		resourceManager = append(resourceManager, deps.LeaderElector)
+	}
+	if deps.ExpirationJob != nil {
+		resourceManager = append(resourceManager, deps.ExpirationJob)
+	}
+	if deps.RetentionJob != nil {
+		resourceManager = append(resourceManager, deps.RetentionJob)
+	}
+	if deps.VersionRetentionJob != nil {
+		resourceManager = append(resourceManager, deps.VersionRetentionJob)
+	}
+	if deps.RotationScheduleJob != nil {
+		resourceManager = append(resourceManager, deps.RotationScheduleJob)
+	}
+	if deps.ReplicationJob != nil {
+		resourceManager = append(resourceManager, deps.ReplicationJob)
+	}
+	if deps.EventRelayJob != nil {
+		resourceManager = append(resourceManager, deps.EventRelayJob)
+	}
+	if configWatcher != nil {
+		resourceManager = append(resourceManager, configWatcher)
+	}
+	if cfg.SecretRotation.Enabled {
+		resourceManager = append(resourceManager, secretRotator)
+	}
+	if reloadableTLS != nil && cfg.Server.TLS.ReloadInterval > 0 {
+		resourceManager = append(resourceManager, wiring.NewCertificateWatcher(reloadableTLS, cfg, logger.With(logging.ModuleKey, "tls")))
+	}
+	// The admin server's Start blocks as well, so it is started on its own.
+	if adminSrv != nil {
+		go func() {
+			if err := adminSrv.Start(ctx); err != nil {
+				logger.Error("admin server failed", "error", err)
+				cancel()
+			}
+		}()
+	}
+	resourceManager = append(resourceManager, srv)
+	if probeSrv != nil {
+		probeSrv.SetServing(deps.Health)
+	}
 
 	// Start resources in a separate goroutine
 	go func() {
@@ -84,14 +242,54 @@ func main() {
 	}
 
 	// Graceful shutdown
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer shutdownCancel()
 
 	logger.Info("shutting down application resources")
+	if probeSrv != nil {
+		probeSrv.SetDraining()
+	}
+	if adminSrv != nil {
+		if err := adminSrv.Stop(shutdownCtx); err != nil {
+			logger.Error("error stopping admin server", "error", err)
+		}
+	}
 	for i := len(resourceManager) - 1; i >= 0; i-- {
 		if err := resourceManager[i].Stop(shutdownCtx); err != nil {
 			logger.Error("error stopping resource", "error", err)
 		}
 	}
+
+	// Only once no request can produce new work: flush the audit log, then close the pool.
+	if err := container.Close(); err != nil {
+		logger.Error("failed to close container", "error", err)
+	}
+	if probeSrv != nil {
+		if err := probeSrv.Stop(shutdownCtx); err != nil {
+			logger.Error("error stopping probe server", "error", err)
+		}
+	}
 	logger.Info("shutdown complete")
 }
+
+// newLogger creates the service logger, which logs at the levels of levels and redacts
+// the default sensitive fields and redactFields.
+func newLogger(levels *logging.Levels, redactFields []string) *slog.Logger {
+	// Levels filters; the handlers below it accept everything it lets through.
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(logging.NewLevelHandler(logging.NewContextHandler(logging.NewRedactingHandler(handler, redactFields)), levels))
+}
+
+func newLogLevels(cfg infra_config.LoggingConfig) (*logging.Levels, error) {
+	return logging.NewLevels(cfg.Level, cfg.ModuleLevels())
+}
+
+// writeSettings prints settings to stdout as aligned key, value and source columns.
+func writeSettings(settings []infra_config.Setting) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, setting := range settings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+	}
+	_ = w.Flush()
+}
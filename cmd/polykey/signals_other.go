@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/spounge-ai/polykey/internal/infra/logging"
+)
+
+// watchLogLevelSignal does nothing where there is no SIGUSR1; use the SetLogLevel RPC.
+func watchLogLevelSignal(context.Context, *slog.Logger, *logging.Levels) {}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spounge-ai/polykey/internal/infra/logging"
+)
+
+// watchLogLevelSignal toggles the global log level between debug and the configured
+// level on every SIGUSR1, until ctx is done.
+func watchLogLevelSignal(ctx context.Context, logger *slog.Logger, levels *logging.Levels) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				level := levels.ToggleDebug()
+				logger.Warn("log level changed by SIGUSR1", "level", level.String())
+			}
+		}
+	}()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spounge-ai/polykey/internal/csiprovider"
+	"github.com/spounge-ai/polykey/internal/wiring"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// version is the provider version reported to the driver, set at build time.
+var version = "dev"
+
+// polykey_csi_provider serves the Secrets Store CSI provider API on a Unix socket in the
+// driver's provider directory, reading the keys pods mount from the Polykey server at
+// -address. Each SecretProviderClass authenticates with the clientId and apiKey of its
+// nodePublishSecretRef Secret, or with -client-id and POLYKEY_CSI_API_KEY when it has
+// none. The DaemonSet is in deployments/k8s/csi-provider.
+func main() {
+	endpoint := flag.String("endpoint", "/var/run/secrets-store-csi-providers/polykey.sock", "Unix socket the driver connects to")
+	address := flag.String("address", envOr("POLYKEY_CSI_ADDRESS", "polykey:50053"), "address of the Polykey gRPC server")
+	clientID := flag.String("client-id", os.Getenv("POLYKEY_CSI_CLIENT_ID"), "client of mounts whose SecretProviderClass has no credentials")
+	certFile := flag.String("tls-cert", "", "client certificate for mTLS")
+	keyFile := flag.String("tls-key", "", "client key for mTLS")
+	caFile := flag.String("tls-ca", "", "CA of the server certificate")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	creds := insecure.NewCredentials()
+	if *certFile != "" {
+		tlsConfig, err := wiring.ClientTLSConfig{CertFile: *certFile, KeyFile: *keyFile, CAFile: *caFile}.TLSConfig()
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(*address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log.Fatalf("FATAL: failed to connect to %s: %v", *address, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	fallback := csiprovider.Credentials{ClientID: *clientID, APIKey: strings.TrimSpace(os.Getenv("POLYKEY_CSI_API_KEY"))}
+	provider := csiprovider.New(conn, fallback, version, logger)
+
+	if err := os.MkdirAll(filepath.Dir(*endpoint), 0o755); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if err := os.Remove(*endpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("FATAL: failed to remove the stale socket: %v", err)
+	}
+	lis, err := net.Listen("unix", *endpoint)
+	if err != nil {
+		log.Fatalf("FATAL: failed to listen on %s: %v", *endpoint, err)
+	}
+
+	server := grpc.NewServer(grpc.ForceServerCodec(csiprovider.Codec{}))
+	server.RegisterService(&csiprovider.ServiceDesc, provider)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	logger.Info("serving the csi provider", "endpoint", *endpoint, "address", *address)
+	if err := server.Serve(lis); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/esowebhook"
+	"github.com/spounge-ai/polykey/internal/wiring"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// polykey_eso_webhook serves keys of the Polykey server at -address to the External
+// Secrets Operator's webhook provider on -listen. SecretStores authenticate each request
+// with their client's credentials, which travel in the Authorization header, so serve
+// over TLS with -serve-cert and -serve-key outside of development. An example store is
+// in deployments/k8s/eso-webhook.
+func main() {
+	listen := flag.String("listen", ":8443", "address the webhook API is served on")
+	address := flag.String("address", envOr("POLYKEY_ESO_ADDRESS", "polykey:50053"), "address of the Polykey gRPC server")
+	serveCert := flag.String("serve-cert", "", "certificate the webhook API is served with")
+	serveKey := flag.String("serve-key", "", "key of -serve-cert")
+	certFile := flag.String("tls-cert", "", "client certificate for mTLS")
+	keyFile := flag.String("tls-key", "", "client key for mTLS")
+	caFile := flag.String("tls-ca", "", "CA of the server certificate")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	creds := insecure.NewCredentials()
+	if *certFile != "" {
+		tlsConfig, err := wiring.ClientTLSConfig{CertFile: *certFile, KeyFile: *keyFile, CAFile: *caFile}.TLSConfig()
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(*address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log.Fatalf("FATAL: failed to connect to %s: %v", *address, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           esowebhook.New(conn, logger).Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("serving the eso webhook", "listen", *listen, "address", *address, "tls", *serveCert != "")
+	if *serveCert != "" {
+		err = server.ListenAndServeTLS(*serveCert, *serveKey)
+	} else {
+		logger.Warn("serving without TLS; client credentials travel in clear text")
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("FATAL: %v", err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
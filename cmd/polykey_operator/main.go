@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spounge-ai/polykey/internal/operator"
+	"github.com/spounge-ai/polykey/internal/operator/api/v1alpha1"
+	"github.com/spounge-ai/polykey/internal/polykeyclient"
+	"github.com/spounge-ai/polykey/internal/wiring"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// polykey_operator reconciles the PolykeyKey resources of the cluster into keys of the
+// Polykey server at -address, authenticating as the client whose API key is in
+// POLYKEY_OPERATOR_API_KEY or -api-key-file. The manifests it needs are in
+// deployments/k8s/operator.
+func main() {
+	address := flag.String("address", envOr("POLYKEY_OPERATOR_ADDRESS", "polykey:50053"), "address of the Polykey gRPC server")
+	clientID := flag.String("client-id", envOr("POLYKEY_OPERATOR_CLIENT_ID", "polykey-operator"), "client ID the operator authenticates as")
+	apiKeyFile := flag.String("api-key-file", "", "file holding the client's API key, instead of POLYKEY_OPERATOR_API_KEY")
+	certFile := flag.String("tls-cert", "", "client certificate for mTLS")
+	keyFile := flag.String("tls-key", "", "client key for mTLS")
+	caFile := flag.String("tls-ca", "", "CA of the server certificate")
+	metricsAddr := flag.String("metrics-bind-address", ":8080", "address the metrics endpoint binds to, 0 to disable it")
+	probeAddr := flag.String("health-probe-bind-address", ":8081", "address the health probes bind to")
+	leaderElect := flag.Bool("leader-elect", true, "elect a leader so that only one replica reconciles")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	apiKey := os.Getenv("POLYKEY_OPERATOR_API_KEY")
+	if *apiKeyFile != "" {
+		data, err := os.ReadFile(*apiKeyFile)
+		if err != nil {
+			log.Fatalf("FATAL: failed to read the API key: %v", err)
+		}
+		apiKey = strings.TrimSpace(string(data))
+	}
+	if apiKey == "" {
+		log.Fatalf("FATAL: set POLYKEY_OPERATOR_API_KEY or -api-key-file")
+	}
+
+	creds := insecure.NewCredentials()
+	if *certFile != "" {
+		tlsConfig, err := wiring.ClientTLSConfig{CertFile: *certFile, KeyFile: *keyFile, CAFile: *caFile}.TLSConfig()
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	tokens := polykeyclient.NewTokenSource(*clientID, apiKey)
+	conn, err := polykeyclient.Dial(*address, tokens, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                 scheme,
+		Metrics:                metricsserver.Options{BindAddress: *metricsAddr},
+		HealthProbeBindAddress: *probeAddr,
+		LeaderElection:         *leaderElect,
+		LeaderElectionID:       "polykey-operator.polykey.spounge.ai",
+	})
+	if err != nil {
+		log.Fatalf("FATAL: failed to create the manager: %v", err)
+	}
+	reconciler := &operator.PolykeyKeyReconciler{
+		Client:    mgr.GetClient(),
+		Keys:      pk.NewPolykeyServiceClient(conn),
+		Requester: tokens,
+		Logger:    logger,
+	}
+	if err := reconciler.SetupWithManager(mgr); err != nil {
+		log.Fatalf("FATAL: failed to set up the reconciler: %v", err)
+	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	logger.Info("starting the operator", "address", *address, "clientId", *clientID)
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	app_grpc "github.com/spounge-ai/polykey/internal/app/grpc"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// commandFunc runs a command with its own arguments, those after its name.
+type commandFunc func(ctx context.Context, s *session, p *printer, args []string) error
+
+var commands = map[string]commandFunc{
+	"authenticate": runAuthenticate,
+	"keys create":  runKeysCreate,
+	"keys get":     runKeysGet,
+	"keys rotate":  runKeysRotate,
+	"keys revoke":  runKeysRevoke,
+	"keys list":    runKeysList,
+	"audit query":  runAuditQuery,
+	"kms rewrap":   runKMSRewrap,
+}
+
+// longRunning are the commands that apply --timeout to each of their calls instead of
+// to the whole command.
+var longRunning = map[string]bool{
+	"kms rewrap": true,
+}
+
+// runAuthenticate prints an access token for the profile's client. As a table, only the
+// token is printed, so that it can be captured into POLYKEYCTL_TOKEN.
+func runAuthenticate(ctx context.Context, s *session, p *printer, args []string) error {
+	fs := newFlagSet("authenticate")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	resp, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	if p.json {
+		return p.message(resp)
+	}
+	_, err = fmt.Fprintln(p.out, resp.GetAccessToken())
+	return err
+}
+
+func runKeysCreate(ctx context.Context, s *session, p *printer, args []string) error {
+	fs := newFlagSet("keys create")
+	keyType := fs.String("type", "", "key type: aes-256, rsa-4096, ecdsa-p384 or api-key")
+	description := fs.String("description", "", "description of the key")
+	classification := fs.String("classification", "", "data classification of the key")
+	expiresIn := fs.Duration("expires-in", 0, "expire the key after this long")
+	tags := map[string]string{}
+	fs.Func("tag", "tag as key=value, repeatable", func(value string) error {
+		k, v, ok := strings.Cut(value, "=")
+		if !ok || k == "" {
+			return errors.New("want key=value")
+		}
+		tags[k] = v
+		return nil
+	})
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	parsedType, err := parseKeyType(*keyType)
+	if err != nil {
+		return err
+	}
+
+	req := &pk.CreateKeyRequest{
+		KeyType:            parsedType,
+		RequesterContext:   s.requester(),
+		Description:        *description,
+		Tags:               tags,
+		DataClassification: *classification,
+	}
+	if *expiresIn > 0 {
+		req.ExpiresAt = timestamppb.New(time.Now().Add(*expiresIn))
+	}
+
+	ctx, err = s.authContext(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := s.service().CreateKey(ctx, req)
+	if err != nil {
+		return err
+	}
+	// Key material never leaves the server through the CLI.
+	resp.KeyMaterial = nil
+	if p.json {
+		return p.message(resp)
+	}
+	return p.keys(resp.GetMetadata())
+}
+
+func runKeysGet(ctx context.Context, s *session, p *printer, args []string) error {
+	fs := newFlagSet("keys get <key-id>")
+	version := fs.Int("version", 0, "version to show instead of the current one")
+	positionals, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	keyID := positionals[0]
+
+	ctx, err = s.authContext(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := s.service().GetKeyMetadata(ctx, &pk.GetKeyMetadataRequest{
+		KeyId:            keyID,
+		RequesterContext: s.requester(),
+		Version:          int32(*version),
+	})
+	if err != nil {
+		return err
+	}
+	if p.json {
+		return p.message(resp)
+	}
+	return p.keys(resp.GetMetadata())
+}
+
+func runKeysRotate(ctx context.Context, s *session, p *printer, args []string) error {
+	fs := newFlagSet("keys rotate <key-id>")
+	gracePeriod := fs.Duration("grace-period", 0, "how long the previous version stays usable")
+	positionals, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	keyID := positionals[0]
+
+	ctx, err = s.authContext(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := s.service().RotateKey(ctx, &pk.RotateKeyRequest{
+		KeyId:              keyID,
+		RequesterContext:   s.requester(),
+		GracePeriodSeconds: int32(gracePeriod.Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+	resp.NewKeyMaterial = nil
+	if p.json {
+		return p.message(resp)
+	}
+	return p.table([]string{"KEY ID", "PREVIOUS VERSION", "NEW VERSION", "PREVIOUS EXPIRES"}, [][]string{{
+		resp.GetKeyId(),
+		fmt.Sprint(resp.GetPreviousVersion()),
+		fmt.Sprint(resp.GetNewVersion()),
+		formatTimestamp(resp.GetOldVersionExpiresAt()),
+	}})
+}
+
+func runKeysRevoke(ctx context.Context, s *session, p *printer, args []string) error {
+	fs := newFlagSet("keys revoke <key-id>")
+	reason := fs.String("reason", "", "reason recorded for the revocation")
+	positionals, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	keyID := positionals[0]
+
+	ctx, err = s.authContext(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := s.service().RevokeKey(ctx, &pk.RevokeKeyRequest{
+		KeyId:            keyID,
+		RequesterContext: s.requester(),
+		RevocationReason: *reason,
+	}); err != nil {
+		return err
+	}
+	if p.json {
+		return p.message(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"key_id":  structpb.NewStringValue(keyID),
+			"revoked": structpb.NewBoolValue(true),
+		}})
+	}
+	_, err = fmt.Fprintf(p.out, "key %s revoked\n", keyID)
+	return err
+}
+
+func runKeysList(ctx context.Context, s *session, p *printer, args []string) error {
+	fs := newFlagSet("keys list")
+	pageSize := fs.Int("page-size", 0, "keys per page, the server default when 0")
+	pageToken := fs.String("page-token", "", "token of the page to list")
+	orderBy := fs.String("order-by", "", `order of the keys, such as "alias" or "updated_at desc"; newest first when empty`)
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	ctx, err := s.authContext(ctx)
+	if err != nil {
+		return err
+	}
+	req := &pk.ListKeysRequest{
+		RequesterContext: s.requester(),
+		PageSize:         int32(*pageSize),
+		PageToken:        *pageToken,
+	}
+	if *orderBy != "" {
+		req.Attributes = &pk.AccessAttributes{CustomAttributes: map[string]string{"order_by": *orderBy}}
+	}
+	resp, err := s.service().ListKeys(ctx, req)
+	if err != nil {
+		return err
+	}
+	if p.json {
+		return p.message(resp)
+	}
+	if err := p.keys(resp.GetKeys()...); err != nil {
+		return err
+	}
+	p.nextPage(resp.GetNextPageToken())
+	return nil
+}
+
+func runAuditQuery(ctx context.Context, s *session, p *printer, args []string) error {
+	fs := newFlagSet("audit query")
+	fields := map[string]*structpb.Value{}
+	stringFilter := func(name, field, usage string) {
+		fs.Func(name, usage, func(value string) error {
+			fields[field] = structpb.NewStringValue(value)
+			return nil
+		})
+	}
+	stringFilter("client", "client_identity", "only events of this client")
+	stringFilter("key", "key_id", "only events on this key")
+	stringFilter("operation", "operation", "only events of this operation")
+	stringFilter("page-token", "page_token", "token of the page to return")
+	fs.Func("success", "only successful events when true, only failed ones when false", func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fields["success"] = structpb.NewBoolValue(b)
+		return nil
+	})
+	from := fs.String("from", "", "only events at or after this RFC 3339 time")
+	to := fs.String("to", "", "only events before this RFC 3339 time")
+	since := fs.Duration("since", 0, "only events in this long before now, instead of --from")
+	pageSize := fs.Int("page-size", 0, "events per page, the server default when 0")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *since > 0 {
+		if *from != "" {
+			return errors.New("--since and --from are mutually exclusive")
+		}
+		*from = time.Now().Add(-*since).UTC().Format(time.RFC3339)
+	}
+	for field, value := range map[string]string{"from": *from, "to": *to} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			return fmt.Errorf("--%s must be an RFC 3339 time: %w", field, err)
+		}
+		fields[field] = structpb.NewStringValue(value)
+	}
+	if *pageSize > 0 {
+		fields["page_size"] = structpb.NewNumberValue(float64(*pageSize))
+	}
+
+	client, err := s.auditClient()
+	if err != nil {
+		return err
+	}
+	ctx, err = s.authContext(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := client.QueryAuditEvents(ctx, &structpb.Struct{Fields: fields})
+	if err != nil {
+		return err
+	}
+	if p.json {
+		return p.message(resp)
+	}
+	if err := p.auditEvents(resp); err != nil {
+		return err
+	}
+	p.nextPage(resp.GetFields()["next_page_token"].GetStringValue())
+	return nil
+}
+
+// runKMSRewrap re-wraps the DEKs wrapped by one KMS provider with another through the
+// admin listener, a few batches per call, until the server reports the re-wrap complete.
+// Interrupting it is safe: the server keeps a checkpoint and the next run resumes there.
+func runKMSRewrap(ctx context.Context, s *session, p *printer, args []string) error {
+	fs := newFlagSet("kms rewrap")
+	from := fs.String("from", "", "KMS provider that wraps the DEKs now, such as local")
+	to := fs.String("to", "", "KMS provider to wrap the DEKs with, such as aws")
+	batchSize := fs.Int("batch-size", 0, "key versions per batch, the server default when 0")
+	batchInterval := fs.Duration("batch-interval", 0, "pause between batches, to stay within KMS quotas")
+	batchesPerCall := fs.Int("batches-per-call", 1, "batches the server runs before reporting progress")
+	restart := fs.Bool("restart", false, "discard the checkpoint and start over, to retry failed versions")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		return errors.New("--from and --to are required")
+	}
+	if *batchesPerCall < 1 {
+		return errors.New("--batches-per-call must be at least 1")
+	}
+
+	client, err := s.adminClient()
+	if err != nil {
+		return err
+	}
+	fields := map[string]*structpb.Value{
+		"from_provider":  structpb.NewStringValue(*from),
+		"to_provider":    structpb.NewStringValue(*to),
+		"batch_size":     structpb.NewNumberValue(float64(*batchSize)),
+		"max_batches":    structpb.NewNumberValue(float64(*batchesPerCall)),
+		"batch_interval": structpb.NewStringValue(batchInterval.String()),
+		"restart":        structpb.NewBoolValue(*restart),
+	}
+
+	for {
+		resp, err := rewrapCall(ctx, s, client, &structpb.Struct{Fields: fields})
+		if err != nil {
+			return err
+		}
+		// Only the first call restarts; the rest continue from its checkpoint.
+		fields["restart"] = structpb.NewBoolValue(false)
+
+		progress := resp.GetFields()
+		if progress["completed"].GetBoolValue() {
+			if p.json {
+				return p.message(resp)
+			}
+			_, err := fmt.Fprintf(p.out, "re-wrap from %s to %s completed: %d rewrapped, %d failed\n", *from, *to,
+				int64(progress["rewrapped"].GetNumberValue()), int64(progress["failed"].GetNumberValue()))
+			return err
+		}
+		if !p.json {
+			fmt.Fprintf(p.out, "%d rewrapped, %d failed, up to key %s version %d\n",
+				int64(progress["rewrapped"].GetNumberValue()), int64(progress["failed"].GetNumberValue()),
+				progress["last_key_id"].GetStringValue(), int64(progress["last_version"].GetNumberValue()))
+		}
+	}
+}
+
+// rewrapCall makes one RewrapKeys call within the call timeout, authenticating again
+// once if the access token expired during a long re-wrap.
+func rewrapCall(ctx context.Context, s *session, client app_grpc.PolykeyAdminClient, req *structpb.Struct) (*structpb.Struct, error) {
+	for attempt := 0; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, s.callTimeout)
+		authCtx, err := s.authContext(callCtx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		resp, err := client.RewrapKeys(authCtx, req)
+		cancel()
+		if status.Code(err) == codes.Unauthenticated && attempt == 0 && s.renewToken() {
+			continue
+		}
+		return resp, err
+	}
+}
+
+func newFlagSet(usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(usage, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: polykeyctl %s [flags]\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args into fs, allowing flags after the positional arguments, and
+// returns the positional arguments, of which there must be exactly positional.
+func parseFlags(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	var positionals []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positionals = append(positionals, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positionals) != positional {
+		fs.Usage()
+		return nil, fmt.Errorf("%s: want %d arguments, got %d", fs.Name(), positional, len(positionals))
+	}
+	return positionals, nil
+}
+
+// parseKeyType parses a key type given as aes-256 or KEY_TYPE_AES_256.
+func parseKeyType(value string) (pk.KeyType, error) {
+	if value == "" {
+		return 0, errors.New("--type is required")
+	}
+	name := strings.ToUpper(strings.ReplaceAll(value, "-", "_"))
+	if !strings.HasPrefix(name, "KEY_TYPE_") {
+		name = "KEY_TYPE_" + name
+	}
+	keyType, ok := pk.KeyType_value[name]
+	if !ok || keyType == int32(pk.KeyType_KEY_TYPE_UNSPECIFIED) {
+		return 0, fmt.Errorf("unknown key type %q", value)
+	}
+	return pk.KeyType(keyType), nil
+}
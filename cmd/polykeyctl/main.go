@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+const usage = `polykeyctl is the operator command line for the Polykey API.
+
+usage: polykeyctl [global flags] <command> [flags] [arguments]
+
+commands:
+  authenticate                 print an access token for the profile's client
+  keys create --type <type>    create a key
+  keys get <key-id>            show the metadata of a key
+  keys rotate <key-id>         rotate a key to a new version
+  keys revoke <key-id>         revoke a key
+  keys list                    list keys
+  audit query                  search the audit trail
+  kms rewrap --from <provider> --to <provider>
+                               re-wrap all DEKs from one KMS provider to another
+  profiles                     list the profiles in the config file
+
+Run a command with -h for its flags.
+
+global flags:
+`
+
+// polykeyctl connects with a profile from its config file, authenticates as the
+// profile's client and prints the results as a table or as JSON. Key material is never
+// printed.
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "polykeyctl:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func run(args []string, out, errOut io.Writer) error {
+	fs := flag.NewFlagSet("polykeyctl", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.Usage = func() {
+		fmt.Fprint(errOut, usage)
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", defaultConfigPath(), "profile config file, or set "+configPathEnv)
+	profileName := fs.String("profile", os.Getenv(profileEnv), "profile to use instead of current_profile, or set "+profileEnv)
+	format := fs.String("o", "table", "output format: table or json")
+	token := fs.String("token", os.Getenv(tokenEnv), "access token to use instead of authenticating, or set "+tokenEnv)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of the command, or of each call of a long-running one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	p, err := newPrinter(out, *format)
+	if err != nil {
+		return err
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return errors.New("no command given")
+	}
+	name := args[0]
+	args = args[1:]
+	if (name == "keys" || name == "audit" || name == "kms") && len(args) > 0 {
+		name += " " + args[0]
+		args = args[1:]
+	}
+
+	cfg, err := loadProfileConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if name == "profiles" {
+		return listProfiles(cfg, p)
+	}
+	command, ok := commands[name]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	_, profile, err := cfg.profile(*profileName)
+	if err != nil {
+		return err
+	}
+	s, err := newSession(profile, *token)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	s.callTimeout = *timeout
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if !longRunning[name] {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	return command(ctx, s, p, args)
+}
+
+func listProfiles(cfg *profileConfig, p *printer) error {
+	rows := make([][]string, 0, len(cfg.Profiles))
+	for _, name := range cfg.names() {
+		current := ""
+		if name == cfg.CurrentProfile {
+			current = "*"
+		}
+		profile := cfg.Profiles[name]
+		rows = append(rows, []string{current, name, profile.Address, profile.AdminAddress, profile.ClientID})
+	}
+	return p.table([]string{"CURRENT", "NAME", "ADDRESS", "ADMIN ADDRESS", "CLIENT ID"}, rows)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// printer writes command results as aligned tables or as JSON.
+type printer struct {
+	out  io.Writer
+	json bool
+}
+
+func newPrinter(out io.Writer, format string) (*printer, error) {
+	switch format {
+	case "table":
+		return &printer{out: out}, nil
+	case "json":
+		return &printer{out: out, json: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, want table or json", format)
+	}
+}
+
+// message writes m as JSON with the field names of the proto definitions.
+func (p *printer) message(m proto.Message) error {
+	data, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", UseProtoNames: true}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(p.out, string(data))
+	return err
+}
+
+func (p *printer) table(header []string, rows [][]string) error {
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func (p *printer) keys(keys ...*pk.KeyMetadata) error {
+	rows := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, []string{
+			key.GetKeyId(),
+			strings.ToLower(strings.TrimPrefix(key.GetKeyType().String(), "KEY_TYPE_")),
+			strings.ToLower(strings.TrimPrefix(key.GetStatus().String(), "KEY_STATUS_")),
+			fmt.Sprint(key.GetVersion()),
+			formatTimestamp(key.GetCreatedAt()),
+			formatTimestamp(key.GetExpiresAt()),
+			key.GetDescription(),
+		})
+	}
+	return p.table([]string{"KEY ID", "TYPE", "STATUS", "VERSION", "CREATED", "EXPIRES", "DESCRIPTION"}, rows)
+}
+
+func (p *printer) auditEvents(resp *structpb.Struct) error {
+	events := resp.GetFields()["events"].GetListValue().GetValues()
+	rows := make([][]string, 0, len(events))
+	for _, value := range events {
+		event := value.GetStructValue().GetFields()
+		result := "ok"
+		if !event["success"].GetBoolValue() {
+			result = "failed"
+		}
+		rows = append(rows, []string{
+			event["timestamp"].GetStringValue(),
+			event["client_identity"].GetStringValue(),
+			event["operation"].GetStringValue(),
+			event["key_id"].GetStringValue(),
+			result,
+			event["error"].GetStringValue(),
+		})
+	}
+	return p.table([]string{"TIME", "CLIENT", "OPERATION", "KEY ID", "RESULT", "ERROR"}, rows)
+}
+
+// nextPage notes the token of the next page after a table, when there is one.
+func (p *printer) nextPage(token string) {
+	if token != "" {
+		fmt.Fprintf(p.out, "\nnext page: --page-token %s\n", token)
+	}
+}
+
+func formatTimestamp(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return "-"
+	}
+	return ts.AsTime().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spounge-ai/polykey/internal/wiring"
+	cmn "github.com/spounge-ai/spounge-proto/gen/go/common/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configPathEnv    = "POLYKEYCTL_CONFIG"
+	profileEnv       = "POLYKEYCTL_PROFILE"
+	tokenEnv         = "POLYKEYCTL_TOKEN"
+	defaultAPIKeyEnv = "POLYKEY_API_KEY"
+)
+
+// profileConfig is the polykeyctl config file, a set of named connection profiles.
+type profileConfig struct {
+	CurrentProfile string             `yaml:"current_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// Profile holds how to reach a Polykey server and which client to authenticate as. The
+// API key is never stored in the profile itself; it is read from api_key_env, or
+// POLYKEY_API_KEY when that is unset, and otherwise from api_key_file.
+type Profile struct {
+	Address      string                  `yaml:"address"`
+	AdminAddress string                  `yaml:"admin_address"`
+	TLS          *wiring.ClientTLSConfig `yaml:"tls"`
+	Insecure     bool                    `yaml:"insecure"`
+	ClientID     string                  `yaml:"client_id"`
+	APIKeyEnv    string                  `yaml:"api_key_env"`
+	APIKeyFile   string                  `yaml:"api_key_file"`
+	ClientTier   string                  `yaml:"client_tier"`
+}
+
+// defaultConfigPath returns POLYKEYCTL_CONFIG, or else polykeyctl/config.yaml in the
+// user's config directory.
+func defaultConfigPath() string {
+	if path := os.Getenv(configPathEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "polykeyctl.yaml"
+	}
+	return filepath.Join(dir, "polykeyctl", "config.yaml")
+}
+
+func loadProfileConfig(path string) (*profileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	var cfg profileConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	// TLS files are relative to the config file, so that a profile directory can be moved
+	// as a whole.
+	dir := filepath.Dir(path)
+	for name, profile := range cfg.Profiles {
+		if profile.TLS != nil {
+			tlsCfg := *profile.TLS
+			tlsCfg.CertFile = resolvePath(dir, tlsCfg.CertFile)
+			tlsCfg.KeyFile = resolvePath(dir, tlsCfg.KeyFile)
+			tlsCfg.CAFile = resolvePath(dir, tlsCfg.CAFile)
+			profile.TLS = &tlsCfg
+		}
+		profile.APIKeyFile = resolvePath(dir, profile.APIKeyFile)
+		cfg.Profiles[name] = profile
+	}
+	return &cfg, nil
+}
+
+// profile returns the profile named name, or else the current profile, or else the only
+// profile in the file.
+func (c *profileConfig) profile(name string) (string, Profile, error) {
+	if name == "" {
+		name = c.CurrentProfile
+	}
+	if name == "" && len(c.Profiles) == 1 {
+		for only := range c.Profiles {
+			name = only
+		}
+	}
+	if name == "" {
+		return "", Profile{}, errors.New("no profile selected; set current_profile or pass --profile")
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return "", Profile{}, fmt.Errorf("profile %q is not defined", name)
+	}
+	if err := profile.validate(); err != nil {
+		return "", Profile{}, fmt.Errorf("profile %q: %w", name, err)
+	}
+	return name, profile, nil
+}
+
+func (c *profileConfig) names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p Profile) validate() error {
+	if p.Address == "" {
+		return errors.New("address is required")
+	}
+	if p.TLS == nil && !p.Insecure {
+		return errors.New("tls is required unless insecure is set")
+	}
+	if _, err := p.tier(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// apiKey returns the API key of the profile's client.
+func (p Profile) apiKey() (string, error) {
+	env := p.APIKeyEnv
+	if env == "" {
+		env = defaultAPIKeyEnv
+	}
+	if key := os.Getenv(env); key != "" {
+		return key, nil
+	}
+	if p.APIKeyFile == "" {
+		return "", fmt.Errorf("no API key: set %s or api_key_file", env)
+	}
+	data, err := os.ReadFile(p.APIKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read api_key_file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// tier parses client_tier, which is free, pro or enterprise. It is unspecified when
+// client_tier is unset, and then the tier the server reports on authentication is used.
+func (p Profile) tier() (cmn.ClientTier, error) {
+	if p.ClientTier == "" {
+		return cmn.ClientTier_CLIENT_TIER_UNSPECIFIED, nil
+	}
+	value, ok := cmn.ClientTier_value["CLIENT_TIER_"+strings.ToUpper(p.ClientTier)]
+	if !ok {
+		return 0, fmt.Errorf("unknown client_tier %q", p.ClientTier)
+	}
+	return cmn.ClientTier(value), nil
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	app_grpc "github.com/spounge-ai/polykey/internal/app/grpc"
+	"github.com/spounge-ai/polykey/internal/polykeyclient"
+	cmn "github.com/spounge-ai/spounge-proto/gen/go/common/v2"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// session is a connection to the server of a profile, authenticated on first use.
+type session struct {
+	profile Profile
+	token   string
+	tier    cmn.ClientTier
+	// tokenGiven is set when the token came from the command line, and so cannot be
+	// renewed.
+	tokenGiven bool
+	// callTimeout bounds each call of a long-running command.
+	callTimeout time.Duration
+
+	conn      *grpc.ClientConn
+	adminConn *grpc.ClientConn
+}
+
+// newSession connects to the profile's server. With a token, the session uses it
+// instead of authenticating.
+func newSession(profile Profile, token string) (*session, error) {
+	tier, err := profile.tier()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dial(profile, profile.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &session{profile: profile, token: token, tier: tier, tokenGiven: token != "", conn: conn}, nil
+}
+
+func dial(profile Profile, address string) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if profile.TLS != nil {
+		tlsConfig, err := profile.TLS.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+func (s *session) Close() {
+	_ = s.conn.Close()
+	if s.adminConn != nil {
+		_ = s.adminConn.Close()
+	}
+}
+
+func (s *session) service() pk.PolykeyServiceClient {
+	return pk.NewPolykeyServiceClient(s.conn)
+}
+
+// auditQuerier is implemented by the clients of both services that serve audit queries.
+type auditQuerier interface {
+	QueryAuditEvents(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+}
+
+// auditClient returns the client for audit queries, on the admin listener when the
+// profile has one, since the server only serves them there when it is enabled.
+func (s *session) auditClient() (auditQuerier, error) {
+	if s.profile.AdminAddress == "" {
+		return app_grpc.NewPolykeyStreamClient(s.conn), nil
+	}
+	return s.adminClient()
+}
+
+// adminClient returns the client of the admin listener.
+func (s *session) adminClient() (app_grpc.PolykeyAdminClient, error) {
+	if s.profile.AdminAddress == "" {
+		return nil, errors.New("the profile has no admin_address")
+	}
+	if s.adminConn == nil {
+		if s.profile.TLS == nil {
+			return nil, errors.New("admin_address requires tls, the admin listener only accepts mTLS")
+		}
+		conn, err := dial(s.profile, s.profile.AdminAddress)
+		if err != nil {
+			return nil, err
+		}
+		s.adminConn = conn
+	}
+	return app_grpc.NewPolykeyAdminClient(s.adminConn), nil
+}
+
+// authenticate exchanges the profile's client credentials for an access token.
+func (s *session) authenticate(ctx context.Context) (*pk.AuthenticateResponse, error) {
+	if s.profile.ClientID == "" {
+		return nil, errors.New("the profile has no client_id")
+	}
+	apiKey, err := s.profile.apiKey()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.service().Authenticate(polykeyclient.WithAuthenticateChallenge(ctx, s.profile.ClientID, apiKey), &pk.AuthenticateRequest{
+		ClientId: s.profile.ClientID,
+		ApiKey:   apiKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	s.token = resp.GetAccessToken()
+	if s.tier == cmn.ClientTier_CLIENT_TIER_UNSPECIFIED {
+		s.tier = resp.GetClientTier()
+	}
+	return resp, nil
+}
+
+// authContext returns ctx carrying the session's access token, authenticating first if
+// the session has none yet.
+func (s *session) authContext(ctx context.Context) (context.Context, error) {
+	if s.token == "" {
+		if _, err := s.authenticate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+s.token), nil
+}
+
+// renewToken drops an expired access token the session obtained itself, so that the next
+// call authenticates again. It reports false when the token cannot be renewed.
+func (s *session) renewToken() bool {
+	if s.tokenGiven {
+		return false
+	}
+	s.token = ""
+	return true
+}
+
+func (s *session) requester() *pk.RequesterContext {
+	return &pk.RequesterContext{
+		ClientIdentity: s.profile.ClientID,
+		ClientTier:     s.tier,
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/infra/persistence"
+)
+
+// promote_standby prepares the standby database of the replication config to become the
+// primary, as described in docs/REPLICATION.md. With the primary reachable, it ships
+// every key mutation still in the outbox, which writes must have stopped adding to, and
+// unregisters the standby so the old primary stops recording for it. With -primary-down
+// it only checks the standby, and the mutations the primary had not shipped are lost.
+// Either way it refuses to promote when the primary wraps DEKs with an AWS KMS key that
+// is not multi-region, as the standby region could not unwrap them.
+// With -status it reports the replication backlog and changes nothing.
+func main() {
+	status := flag.Bool("status", false, "report the replication backlog and exit")
+	primaryDown := flag.Bool("primary-down", false, "promote without draining the primary, which cannot be reached")
+	drainTimeout := flag.Duration("drain-timeout", 5*time.Minute, "how long to ship the outbox before giving up")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := config.Load(os.Getenv("POLYKEY_CONFIG_PATH"))
+	if err != nil {
+		log.Fatalf("FATAL: could not load config: %v", err)
+	}
+	repl := cfg.Replication
+	if repl.Target == "" || repl.StandbyURL == "" {
+		log.Fatalf("FATAL: replication.target and replication.standby_url must be set")
+	}
+	if cfg.AWS.Enabled {
+		if err := persistence.CheckReplicableKMSKey(cfg.BootstrapSecrets.AWSKMSKeyARN); err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+	}
+
+	standby, err := pgxpool.New(ctx, repl.StandbyURL)
+	if err != nil {
+		log.Fatalf("FATAL: failed to connect to the standby: %v", err)
+	}
+	defer standby.Close()
+	if err := standby.Ping(ctx); err != nil {
+		log.Fatalf("FATAL: the standby cannot be reached: %v", err)
+	}
+
+	if *primaryDown {
+		if *status {
+			log.Fatalf("FATAL: -status needs the primary")
+		}
+		log.Printf("WARN: the primary is down; key mutations it had not shipped to %s are lost.", repl.Target)
+		printNextSteps(repl.Target)
+		return
+	}
+
+	primary, err := pgxpool.New(ctx, cfg.BootstrapSecrets.NeonDBURL)
+	if err != nil {
+		log.Fatalf("FATAL: failed to connect to the primary: %v", err)
+	}
+	defer primary.Close()
+	replicator := persistence.NewOutboxReplicator(primary, standby, repl.Target)
+
+	backlog, err := replicator.Backlog(ctx)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if backlog.Oldest != nil {
+		log.Printf("INFO: %d key mutations pending for %s, the oldest recorded %s ago.", backlog.Pending, repl.Target, time.Since(*backlog.Oldest).Round(time.Second))
+	} else {
+		log.Printf("INFO: no key mutations pending for %s.", repl.Target)
+	}
+	if *status {
+		return
+	}
+
+	drainCtx, cancel := context.WithTimeout(ctx, *drainTimeout)
+	defer cancel()
+	shipped, conflicts := 0, 0
+	for {
+		batch, err := replicator.ReplicateBatch(drainCtx, max(repl.BatchSize, 1))
+		if err != nil {
+			log.Fatalf("FATAL: failed to ship the outbox after %d mutations: %v", shipped, err)
+		}
+		for _, conflict := range batch.Conflicts {
+			log.Printf("WARN: key %s version %d changed on the standby, kept as the standby has it.", conflict.KeyID, conflict.Version)
+		}
+		shipped += batch.Shipped
+		conflicts += len(batch.Conflicts)
+		if batch.Shipped == 0 {
+			break
+		}
+	}
+	if err := replicator.Unregister(ctx); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	log.Printf("SUCCESS: shipped %d key mutations, %d in conflict; the primary no longer records mutations for %s.", shipped, conflicts, repl.Target)
+	printNextSteps(repl.Target)
+}
+
+func printNextSteps(target string) {
+	log.Printf("NEXT: point the neondb_url bootstrap secret of the %s deployment at the standby database and start it.", target)
+	log.Printf("NEXT: its aws_kms_key_arn must be the replica of the primary's multi-region key in its region, and its master key and metadata_integrity_key the primary's.")
+	log.Printf("NEXT: to replicate back, set replication.standby_url of the new primary to the old primary's database once it is reachable.")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/spounge-ai/polykey/internal/infra/secrets"
+)
+
+// seal_secrets encrypts a local bootstrap secrets file for the local secret provider. The
+// key is read from POLYKEY_LOCAL_SECRETS_KEY; without it, a new key is generated and
+// printed, to be set in that variable when the service starts.
+func main() {
+	in := flag.String("in", "", "plaintext secrets file, a YAML map from secret paths to values")
+	out := flag.String("out", "", "sealed secrets file to write")
+	flag.Parse()
+	if *in == "" || *out == "" {
+		log.Fatalf("FATAL: usage: seal_secrets -in <secrets.yaml> -out <secrets.sealed>")
+	}
+
+	plaintext, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatalf("FATAL: could not read secrets file: %v", err)
+	}
+
+	encodedKey := os.Getenv(secrets.LocalSecretsKeyEnv)
+	if encodedKey == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("FATAL: could not generate key: %v", err)
+		}
+		encodedKey = base64.StdEncoding.EncodeToString(key)
+		log.Printf("INFO: generated a new key; start the service with %s=%s", secrets.LocalSecretsKeyEnv, encodedKey)
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		log.Fatalf("FATAL: %s is not valid base64: %v", secrets.LocalSecretsKeyEnv, err)
+	}
+
+	sealed, err := secrets.SealSecrets(plaintext, key)
+	if err != nil {
+		log.Fatalf("FATAL: could not seal secrets: %v", err)
+	}
+	if err := os.WriteFile(*out, sealed, 0o600); err != nil {
+		log.Fatalf("FATAL: could not write sealed secrets: %v", err)
+	}
+	log.Printf("SUCCESS: sealed %s to %s.", *in, *out)
+}
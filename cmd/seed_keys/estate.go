@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	cts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/kms"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// dekSize is the size of an AES-256 DEK, the only key type the service generates.
+const dekSize = 32
+
+var (
+	services        = []string{"billing", "checkout", "identity", "ledger", "notifications", "reporting", "search", "storage"}
+	teams           = []string{"payments", "platform", "data", "growth", "security"}
+	environments    = []string{"production", "staging", "development"}
+	classifications = []string{"public", "internal", "confidential", "restricted"}
+	purposes        = []string{"field encryption", "backup encryption", "token signing", "session encryption", "export encryption"}
+	readOperations  = []string{cts.MethodGetKey, cts.MethodGetKey, cts.MethodGetKey, cts.MethodGetKeyMetadata}
+)
+
+// estateSpec describes the estate to generate.
+type estateSpec struct {
+	Keys        int
+	MaxVersions int
+	// MaxAuditEvents bounds the read events generated per key, on top of those of its
+	// creation, rotations and revocation.
+	MaxAuditEvents int
+	Namespace      string
+	// History is how far back key creation times are spread.
+	History time.Duration
+	// RevokedFraction and ExpiringFraction are the shares of keys that are revoked, and
+	// that carry an expiry within the next History.
+	RevokedFraction  float64
+	ExpiringFraction float64
+	KMSProvider      string
+	Now              time.Time
+}
+
+// generator builds keys and their audit history. Everything but the DEKs is drawn from
+// rng, so that a seed reproduces the same estate shape.
+type generator struct {
+	spec    estateSpec
+	rng     *mrand.Rand
+	kms     kms.KMSProvider
+	clients []string
+}
+
+func newGenerator(spec estateSpec, provider kms.KMSProvider, seed uint64) *generator {
+	g := &generator{spec: spec, rng: mrand.New(mrand.NewPCG(seed, seed^0x9e3779b97f4a7c15)), kms: provider}
+	for _, service := range services {
+		g.clients = append(g.clients, "svc-"+service)
+	}
+	return g
+}
+
+// seededKey is one generated key: all its versions, oldest first, and its history.
+type seededKey struct {
+	versions []*domain.Key
+	revoked  bool
+	events   []*domain.AuditEvent
+}
+
+func (g *generator) key(ctx context.Context) (*seededKey, error) {
+	keyID := domain.NewKeyID()
+	service := pick(g.rng, services)
+	creator := "svc-" + service
+	created := g.spec.Now.Add(-time.Duration(g.rng.Int64N(int64(g.spec.History) + 1)))
+	env := pick(g.rng, environments)
+	tags := map[string]string{
+		"service":     service,
+		"team":        pick(g.rng, teams),
+		"environment": env,
+		"seeded":      "true",
+	}
+	if g.rng.IntN(3) == 0 {
+		tags["cost-center"] = fmt.Sprintf("cc-%04d", g.rng.IntN(10000))
+	}
+	storage := pk.StorageProfile_STORAGE_PROFILE_STANDARD
+	if env == "production" && g.rng.IntN(2) == 0 {
+		storage = pk.StorageProfile_STORAGE_PROFILE_HARDENED
+	}
+	metadata := &pk.KeyMetadata{
+		KeyId:              keyID.String(),
+		KeyType:            pk.KeyType_KEY_TYPE_AES_256,
+		CreatorIdentity:    creator,
+		AuthorizedContexts: []string{creator},
+		Description:        fmt.Sprintf("%s %s key (%s)", service, pick(g.rng, purposes), env),
+		Tags:               tags,
+		DataClassification: pick(g.rng, classifications),
+		StorageType:        storage,
+	}
+	if g.rng.Float64() < g.spec.ExpiringFraction {
+		metadata.ExpiresAt = timestamppb.New(g.spec.Now.Add(time.Duration(g.rng.Int64N(int64(g.spec.History) + 1))))
+	}
+
+	sk := &seededKey{revoked: g.rng.Float64() < g.spec.RevokedFraction}
+	sk.events = append(sk.events, g.event(creator, cts.MethodCreateKey, keyID, created, true))
+
+	// Versions are spread evenly from the creation of the key to now.
+	count := 1 + g.rng.IntN(max(g.spec.MaxVersions, 1))
+	step := g.spec.Now.Sub(created) / time.Duration(count)
+	for v := 1; v <= count; v++ {
+		at := created.Add(step * time.Duration(v-1))
+		status := domain.KeyStatusRotated
+		if v == count {
+			status = domain.KeyStatusActive
+		}
+		version, err := g.version(ctx, keyID, metadata, int32(v), status, at)
+		if err != nil {
+			return nil, err
+		}
+		sk.versions = append(sk.versions, version)
+		if v > 1 {
+			sk.events = append(sk.events, g.event(creator, cts.MethodRotateKey, keyID, at, true))
+		}
+	}
+
+	for range g.rng.IntN(g.spec.MaxAuditEvents + 1) {
+		at := created.Add(time.Duration(g.rng.Int64N(int64(g.spec.Now.Sub(created)) + 1)))
+		client := creator
+		// Some reads come from clients the key is not shared with, and are denied.
+		success := g.rng.IntN(20) != 0
+		if !success {
+			client = pick(g.rng, g.clients)
+		}
+		sk.events = append(sk.events, g.event(client, pick(g.rng, readOperations), keyID, at, success))
+	}
+	if sk.revoked {
+		sk.events = append(sk.events, g.event(creator, cts.MethodRevokeKey, keyID, g.spec.Now, true))
+	}
+	return sk, nil
+}
+
+// version builds one version of a key with a fresh DEK wrapped by the KMS provider.
+func (g *generator) version(ctx context.Context, keyID domain.KeyID, metadata *pk.KeyMetadata, version int32, status domain.KeyStatus, at time.Time) (*domain.Key, error) {
+	md := proto.Clone(metadata).(*pk.KeyMetadata)
+	md.Version = version
+	md.Status = status.Proto()
+	md.CreatedAt = timestamppb.New(at)
+	md.UpdatedAt = timestamppb.New(at)
+	key := &domain.Key{
+		ID:          keyID,
+		Namespace:   g.spec.Namespace,
+		Version:     version,
+		Metadata:    md,
+		Status:      status,
+		CreatedAt:   at,
+		UpdatedAt:   at,
+		KMSProvider: g.spec.KMSProvider,
+	}
+
+	dek := make([]byte, dekSize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	encrypted, err := g.kms.EncryptDEK(ctx, dek, key)
+	clear(dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt DEK: %w", err)
+	}
+	key.EncryptedDEK = encrypted
+	return key, nil
+}
+
+func (g *generator) event(client, operation string, keyID domain.KeyID, at time.Time, success bool) *domain.AuditEvent {
+	event := &domain.AuditEvent{
+		ID:             uuid.New().String(),
+		ClientIdentity: client,
+		Operation:      operation,
+		KeyID:          keyID.String(),
+		CorrelationID:  uuid.New().String(),
+		Success:        success,
+		Timestamp:      at,
+		PeerIP:         fmt.Sprintf("10.%d.%d.%d", g.rng.IntN(256), g.rng.IntN(256), 1+g.rng.IntN(254)),
+		UserAgent:      "grpc-go/1.74.2",
+	}
+	if !success {
+		event.Error = "permission denied"
+	}
+	return event
+}
+
+// writer stores generated keys through the repositories in batches.
+type writer struct {
+	keys      domain.KeyRepository
+	audit     domain.AuditRepository
+	batchSize int
+
+	pending []*seededKey
+	written struct{ keys, versions, revoked, events int }
+}
+
+func (w *writer) add(ctx context.Context, sk *seededKey) error {
+	w.pending = append(w.pending, sk)
+	if len(w.pending) < w.batchSize {
+		return nil
+	}
+	return w.flush(ctx)
+}
+
+// flush writes the pending keys, revokes those meant to be, then appends their history
+// to the audit chain in time order.
+func (w *writer) flush(ctx context.Context) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	var versions []*domain.Key
+	var revoke []domain.KeyID
+	var events []*domain.AuditEvent
+	for _, sk := range w.pending {
+		versions = append(versions, sk.versions...)
+		if sk.revoked {
+			revoke = append(revoke, sk.versions[0].ID)
+		}
+		events = append(events, sk.events...)
+	}
+
+	if err := w.keys.CreateBatchKeys(ctx, versions); err != nil {
+		return fmt.Errorf("failed to store keys: %w", err)
+	}
+	if len(revoke) > 0 {
+		if err := w.keys.RevokeBatchKeys(ctx, revoke); err != nil {
+			return fmt.Errorf("failed to revoke keys: %w", err)
+		}
+	}
+	if w.audit != nil && len(events) > 0 {
+		sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+		if err := w.audit.CreateAuditEventsBatch(ctx, events); err != nil {
+			return fmt.Errorf("failed to store audit events: %w", err)
+		}
+	}
+
+	w.written.keys += len(w.pending)
+	w.written.versions += len(versions)
+	w.written.revoked += len(revoke)
+	w.written.events += len(events)
+	w.pending = w.pending[:0]
+	return nil
+}
+
+func pick[T any](rng *mrand.Rand, values []T) T {
+	return values[rng.IntN(len(values))]
+}
+
+func (s estateSpec) validate() error {
+	switch {
+	case s.Keys <= 0:
+		return errors.New("-keys must be positive")
+	case s.MaxVersions <= 0:
+		return errors.New("-max-versions must be positive")
+	case s.MaxAuditEvents < 0:
+		return errors.New("-max-audit-events cannot be negative")
+	case s.History <= 0:
+		return errors.New("-history must be positive")
+	case s.RevokedFraction < 0 || s.RevokedFraction > 1:
+		return errors.New("-revoked must be between 0 and 1")
+	case s.ExpiringFraction < 0 || s.ExpiringFraction > 1:
+		return errors.New("-expiring must be between 0 and 1")
+	}
+	return nil
+}
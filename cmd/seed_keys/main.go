@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/infra/persistence"
+	"github.com/spounge-ai/polykey/internal/wiring"
+	"github.com/spounge-ai/polykey/pkg/clock"
+)
+
+// seed_keys fills the database of POLYKEY_CONFIG_PATH with a generated key estate: keys
+// with several versions, tags, classifications, expiries and revocations, and an audit
+// history for each, written straight through the repositories. It is meant for staging
+// environments and benchmark baselines, never for production: the DEKs are real and
+// wrapped by the configured KMS, but the keys belong to no one.
+func main() {
+	spec := estateSpec{Now: time.Now()}
+	flag.IntVar(&spec.Keys, "keys", 1000, "number of keys to generate")
+	flag.IntVar(&spec.MaxVersions, "max-versions", 5, "most versions a key is given, each key getting between 1 and this many")
+	flag.IntVar(&spec.MaxAuditEvents, "max-audit-events", 20, "most read events generated per key, beside its creation, rotations and revocation")
+	flag.StringVar(&spec.Namespace, "namespace", domain.DefaultNamespace, "namespace to create the keys in")
+	flag.DurationVar(&spec.History, "history", 90*24*time.Hour, "how far back key creation times are spread")
+	flag.Float64Var(&spec.RevokedFraction, "revoked", 0.05, "fraction of keys to revoke")
+	flag.Float64Var(&spec.ExpiringFraction, "expiring", 0.2, "fraction of keys given an expiry within -history from now")
+	flag.StringVar(&spec.KMSProvider, "kms-provider", "", "KMS provider to wrap the DEKs with, the only or local one when empty")
+	noAudit := flag.Bool("no-audit", false, "do not write audit history")
+	batchSize := flag.Int("batch-size", 200, "keys written per batch")
+	seed := flag.Uint64("seed", 0, "seed of the estate shape, random when 0")
+	flag.Parse()
+	if err := spec.validate(); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if *batchSize <= 0 {
+		log.Fatalf("FATAL: -batch-size must be positive")
+	}
+	if *seed == 0 {
+		*seed = uint64(time.Now().UnixNano())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := config.Load(os.Getenv("POLYKEY_CONFIG_PATH"))
+	if err != nil {
+		log.Fatalf("FATAL: could not load config: %v", err)
+	}
+
+	container := wiring.NewContainer(cfg, slog.Default())
+	defer container.Close()
+	providers, err := container.GetKMSProviders(ctx)
+	if err != nil {
+		log.Fatalf("FATAL: failed to create KMS providers: %v", err)
+	}
+	if spec.KMSProvider == "" {
+		spec.KMSProvider = defaultProvider(providers)
+	}
+	provider, ok := providers[spec.KMSProvider]
+	if !ok {
+		log.Fatalf("FATAL: KMS provider %q is not configured", spec.KMSProvider)
+	}
+
+	pool, err := pgxpool.New(ctx, cfg.BootstrapSecrets.NeonDBURL)
+	if err != nil {
+		log.Fatalf("FATAL: failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	// The plain adapter is used, without the cache or key events: nothing is serving
+	// these keys yet.
+	integrity, err := domain.NewMetadataIntegrity(cfg.BootstrapSecrets.MetadataIntegrityKey)
+	if err != nil {
+		log.Fatalf("FATAL: failed to load metadata integrity key: %v", err)
+	}
+	keyRepo, err := persistence.NewPSQLAdapter(pool, slog.Default(), clock.System(), integrity)
+	if err != nil {
+		log.Fatalf("FATAL: failed to create key repository: %v", err)
+	}
+	w := &writer{keys: keyRepo, batchSize: *batchSize}
+	if !*noAudit {
+		w.audit, err = persistence.NewAuditRepository(pool)
+		if err != nil {
+			log.Fatalf("FATAL: failed to create audit repository: %v", err)
+		}
+	}
+
+	log.Printf("INFO: generating %d keys in namespace %q with seed %d...", spec.Keys, spec.Namespace, *seed)
+	ctx = domain.NewContextWithNamespace(ctx, spec.Namespace)
+	gen := newGenerator(spec, provider, *seed)
+	started := time.Now()
+	for i := range spec.Keys {
+		sk, err := gen.key(ctx)
+		if err != nil {
+			log.Fatalf("FATAL: failed to generate key %d: %v", i+1, err)
+		}
+		if err := w.add(ctx, sk); err != nil {
+			log.Fatalf("FATAL: %v (%d keys written)", err, w.written.keys)
+		}
+		if w.written.keys > 0 && len(w.pending) == 0 {
+			log.Printf("INFO: %d of %d keys written", w.written.keys, spec.Keys)
+		}
+	}
+	if err := w.flush(ctx); err != nil {
+		log.Fatalf("FATAL: %v (%d keys written)", err, w.written.keys)
+	}
+
+	log.Printf("SUCCESS: %d keys with %d versions written in %s, %d revoked, %d audit events.",
+		w.written.keys, w.written.versions, time.Since(started).Round(time.Millisecond), w.written.revoked, w.written.events)
+}
+
+// defaultProvider returns the local provider when there is one, and otherwise the first
+// by name.
+func defaultProvider[P any](providers map[string]P) string {
+	if _, ok := providers["local"]; ok {
+		return "local"
+	}
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names[0]
+}
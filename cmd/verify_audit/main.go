@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/infra/persistence"
+)
+
+// verify_audit checks the audit hash chain directly against the database, for use when
+// the service itself is unavailable or not trusted. It exits non-zero if the chain is broken.
+func main() {
+	ctx := context.Background()
+
+	cfg, err := config.Load(os.Getenv("POLYKEY_CONFIG_PATH"))
+	if err != nil {
+		log.Fatalf("FATAL: could not load config: %v", err)
+	}
+
+	pool, err := pgxpool.New(ctx, cfg.BootstrapSecrets.NeonDBURL)
+	if err != nil {
+		log.Fatalf("FATAL: failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	repo, err := persistence.NewAuditRepository(pool)
+	if err != nil {
+		log.Fatalf("FATAL: failed to create audit repository: %v", err)
+	}
+
+	log.Println("INFO: verifying audit chain...")
+	report, err := repo.VerifyAuditIntegrity(ctx)
+	if err != nil {
+		log.Fatalf("FATAL: verification failed: %v", err)
+	}
+
+	if !report.Valid {
+		log.Printf("ERROR: audit chain broken at sequence %d: %s", report.BrokenAtSequence, report.Reason)
+		log.Printf("ERROR: %d events verified before the break, from sequence %d", report.CheckedEvents, report.FirstSequence)
+		pool.Close()
+		os.Exit(1)
+	}
+
+	log.Printf("SUCCESS: %d audit events verified, sequences %d to %d.", report.CheckedEvents, report.FirstSequence, report.LastSequence)
+}
@@ -0,0 +1,295 @@
+// Package agent is a caching sidecar for chatty clients of the Polykey API. It
+// authenticates once as its pod's client, serves the keys the pod's processes read from a
+// local cache behind a Unix socket, and follows the server's key events so that a rotated
+// or revoked key is not served from the cache after the server reports the change.
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	app_grpc "github.com/spounge-ai/polykey/internal/app/grpc"
+	"github.com/spounge-ai/polykey/internal/domain"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultTTL        = 5 * time.Minute
+	defaultMaxEntries = 1024
+
+	minWatchBackoff = time.Second
+	maxWatchBackoff = 30 * time.Second
+)
+
+// Config tunes the cache of an Agent.
+type Config struct {
+	// TTL is how long a key is served from the cache before it is read again, even
+	// without an event reporting a change.
+	TTL time.Duration
+	// MaxEntries bounds the number of cached keys; the entry that expires first is
+	// evicted to make room.
+	MaxEntries int
+}
+
+// RequesterSource supplies the requester context of the agent's client.
+type RequesterSource interface {
+	Requester(ctx context.Context) (*pk.RequesterContext, error)
+}
+
+// Entry is a key read from the server.
+type Entry struct {
+	KeyID     string
+	Version   int32
+	Material  []byte
+	Metadata  *pk.KeyMetadata
+	FetchedAt time.Time
+	ExpiresAt time.Time
+}
+
+// cacheKey identifies an entry; version is zero for the current version of the key.
+type cacheKey struct {
+	keyID   string
+	version int32
+}
+
+// Agent caches the keys of one client.
+type Agent struct {
+	keys       pk.PolykeyServiceClient
+	events     app_grpc.PolykeyStreamClient
+	requester  RequesterSource
+	ttl        time.Duration
+	maxEntries int
+	logger     *slog.Logger
+
+	fetches singleflight.Group
+
+	mu       sync.RWMutex
+	entries  map[cacheKey]*Entry
+	watching bool
+}
+
+// New creates an agent reading keys over conn as the client of requester.
+func New(conn grpc.ClientConnInterface, requester RequesterSource, cfg Config, logger *slog.Logger) *Agent {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+	return &Agent{
+		keys:       pk.NewPolykeyServiceClient(conn),
+		events:     app_grpc.NewPolykeyStreamClient(conn),
+		requester:  requester,
+		ttl:        cfg.TTL,
+		maxEntries: cfg.MaxEntries,
+		logger:     logger,
+		entries:    make(map[cacheKey]*Entry),
+	}
+}
+
+// GetKey returns version of keyID, or its current version when version is zero, and
+// whether it was served from the cache. Concurrent misses of the same key share one read.
+func (a *Agent) GetKey(ctx context.Context, keyID string, version int32) (*Entry, bool, error) {
+	key := cacheKey{keyID: keyID, version: version}
+	a.mu.RLock()
+	entry, ok := a.entries[key]
+	a.mu.RUnlock()
+	if ok && time.Now().Before(entry.ExpiresAt) {
+		return entry, true, nil
+	}
+
+	result, err, _ := a.fetches.Do(keyID+"@"+strconv.Itoa(int(version)), func() (any, error) {
+		return a.fetch(context.WithoutCancel(ctx), key)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return result.(*Entry), false, nil
+}
+
+// fetch reads key from the server and caches it.
+func (a *Agent) fetch(ctx context.Context, key cacheKey) (*Entry, error) {
+	requester, err := a.requester.Requester(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	resp, err := a.keys.GetKey(ctx, &pk.GetKeyRequest{KeyId: key.keyID, Version: key.version, RequesterContext: requester})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	entry := &Entry{
+		KeyID:     key.keyID,
+		Version:   resp.GetMetadata().GetVersion(),
+		Material:  resp.GetKeyMaterial().GetEncryptedKeyData(),
+		Metadata:  resp.GetMetadata(),
+		FetchedAt: now,
+		ExpiresAt: now.Add(a.ttl),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.entries[key]; !ok && len(a.entries) >= a.maxEntries {
+		a.evictLocked()
+	}
+	a.entries[key] = entry
+	return entry, nil
+}
+
+// evictLocked removes the entry that expires first.
+func (a *Agent) evictLocked() {
+	var victim cacheKey
+	var first time.Time
+	for key, entry := range a.entries {
+		if first.IsZero() || entry.ExpiresAt.Before(first) {
+			victim, first = key, entry.ExpiresAt
+		}
+	}
+	delete(a.entries, victim)
+}
+
+// Len returns the number of cached keys.
+func (a *Agent) Len() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.entries)
+}
+
+// Watching reports whether the agent follows the server's key events. While it does not,
+// changed keys are only read again once their entries expire.
+func (a *Agent) Watching() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.watching
+}
+
+// Run follows the server's key events and removes expired entries until ctx is done.
+func (a *Agent) Run(ctx context.Context) {
+	go a.sweep(ctx)
+
+	backoff := minWatchBackoff
+	for {
+		established, err := a.watch(ctx)
+		a.setWatching(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if established {
+			backoff = minWatchBackoff
+		}
+		// Events may have been missed while disconnected.
+		a.invalidateCurrent()
+		a.logger.WarnContext(ctx, "key event watch ended, retrying", "error", err, "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxWatchBackoff)
+	}
+}
+
+// watch applies key events until the stream fails, and reports whether it received any.
+func (a *Agent) watch(ctx context.Context) (bool, error) {
+	requester, err := a.requester.Requester(ctx)
+	if err != nil {
+		return false, err
+	}
+	stream, err := a.events.WatchKeys(ctx, &pk.ListKeysRequest{RequesterContext: requester})
+	if err != nil {
+		return false, err
+	}
+	a.setWatching(true)
+	for received := false; ; received = true {
+		event, err := stream.Recv()
+		if err != nil {
+			return received, err
+		}
+		fields := event.GetFields()
+		a.apply(ctx, fields["key_id"].GetStringValue(), int32(fields["version"].GetNumberValue()), domain.KeyEventType(fields["type"].GetStringValue()))
+	}
+}
+
+// apply updates the cache after eventType happened to version of keyID. The current
+// version of a cached key that was rotated or restored is read again right away, so that
+// readers do not wait for it; a revoked key is dropped with all its versions. An expired
+// key drops its current version and the entry pinned to the expired one, and an expired
+// rotated version only drops its pinned entry.
+func (a *Agent) apply(ctx context.Context, keyID string, version int32, eventType domain.KeyEventType) {
+	current := cacheKey{keyID: keyID}
+	pinned := cacheKey{keyID: keyID, version: version}
+	a.mu.Lock()
+	_, cached := a.entries[current]
+	switch eventType {
+	case domain.KeyEventCreated, domain.KeyEventExpiring:
+		a.mu.Unlock()
+		return
+	case domain.KeyEventRevoked:
+		for key := range a.entries {
+			if key.keyID == keyID {
+				delete(a.entries, key)
+			}
+		}
+	case domain.KeyEventVersionExpired:
+		delete(a.entries, pinned)
+	case domain.KeyEventExpired:
+		delete(a.entries, current)
+		delete(a.entries, pinned)
+	default:
+		delete(a.entries, current)
+	}
+	a.mu.Unlock()
+
+	if cached && (eventType == domain.KeyEventRotated || eventType == domain.KeyEventRestored) {
+		go func() {
+			if _, _, err := a.GetKey(ctx, keyID, 0); err != nil {
+				a.logger.WarnContext(ctx, "failed to read a changed key", "keyId", keyID, "event", eventType, "error", err)
+			}
+		}()
+	}
+}
+
+// invalidateCurrent drops the current versions of all keys, which may have changed.
+// Pinned versions do not change, and are kept until they expire.
+func (a *Agent) invalidateCurrent() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key := range a.entries {
+		if key.version == 0 {
+			delete(a.entries, key)
+		}
+	}
+}
+
+func (a *Agent) sweep(ctx context.Context) {
+	ticker := time.NewTicker(a.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			a.mu.Lock()
+			for key, entry := range a.entries {
+				if !now.Before(entry.ExpiresAt) {
+					delete(a.entries, key)
+				}
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+func (a *Agent) setWatching(watching bool) {
+	a.mu.Lock()
+	a.watching = watching
+	a.mu.Unlock()
+}
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/polykeyclient"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// cacheHeader tells whether a key was served from the cache, hit, or read from the
+// server, miss.
+const cacheHeader = "X-Polykey-Cache"
+
+// keyResponse is the body of GET /v1/keys/{keyId}. Material is base64 encoded.
+type keyResponse struct {
+	KeyID     string          `json:"key_id"`
+	Version   int32           `json:"version"`
+	Material  []byte          `json:"material"`
+	Metadata  json.RawMessage `json:"metadata"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+type healthResponse struct {
+	Status   string `json:"status"`
+	Watching bool   `json:"watching"`
+	Entries  int    `json:"entries"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler returns the HTTP API the agent serves to the processes of its pod:
+//
+//	GET /v1/keys/{keyId}[?version=N]  the material and metadata of a key
+//	GET /healthz                      whether the agent follows key events
+//
+// The API has no authentication of its own; access is controlled by the permissions of
+// the socket it is served on.
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/keys/{keyId}", a.getKey)
+	mux.HandleFunc("GET /healthz", a.healthz)
+	return mux
+}
+
+func (a *Agent) getKey(w http.ResponseWriter, r *http.Request) {
+	var version int32
+	if v := r.URL.Query().Get("version"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || parsed <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "version must be a positive integer"})
+			return
+		}
+		version = int32(parsed)
+	}
+
+	entry, hit, err := a.GetKey(r.Context(), r.PathValue("keyId"), version)
+	if err != nil {
+		st := status.Convert(err)
+		writeJSON(w, polykeyclient.HTTPStatus(st.Code()), errorResponse{Error: st.Message()})
+		return
+	}
+	metadata, err := protojson.Marshal(entry.Metadata)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	if hit {
+		w.Header().Set(cacheHeader, "hit")
+	} else {
+		w.Header().Set(cacheHeader, "miss")
+	}
+	writeJSON(w, http.StatusOK, keyResponse{
+		KeyID:     entry.KeyID,
+		Version:   entry.Version,
+		Material:  entry.Material,
+		Metadata:  metadata,
+		ExpiresAt: entry.ExpiresAt,
+	})
+}
+
+// healthz answers 200 while the agent follows key events, and 503 while it does not and
+// may serve a changed key until its entry expires.
+func (a *Agent) healthz(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{Status: "healthy", Watching: a.Watching(), Entries: a.Len()}
+	code := http.StatusOK
+	if !resp.Watching {
+		resp.Status, code = "degraded", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
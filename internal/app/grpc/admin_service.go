@@ -0,0 +1,873 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/auth"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// PolykeyAdminServiceName is the service of operational RPCs served on the admin
+// listener, apart from the data-plane API. Like the companion service, it reuses
+// well-known messages on the wire.
+const PolykeyAdminServiceName = "polykey.v2.PolykeyAdminService"
+
+const (
+	adminListClientsFullMethod            = "/" + PolykeyAdminServiceName + "/" + cts.MethodListClients
+	adminPutClientFullMethod              = "/" + PolykeyAdminServiceName + "/" + cts.MethodPutClient
+	adminDeleteClientFullMethod           = "/" + PolykeyAdminServiceName + "/" + cts.MethodDeleteClient
+	adminListRolesFullMethod              = "/" + PolykeyAdminServiceName + "/" + cts.MethodListRoles
+	adminPutRoleFullMethod                = "/" + PolykeyAdminServiceName + "/" + cts.MethodPutRole
+	adminDeleteRoleFullMethod             = "/" + PolykeyAdminServiceName + "/" + cts.MethodDeleteRole
+	adminControlCircuitBreakerFullMethod  = "/" + PolykeyAdminServiceName + "/" + cts.MethodControlCircuitBreaker
+	adminFlushCachesFullMethod            = "/" + PolykeyAdminServiceName + "/" + cts.MethodFlushCaches
+	adminReloadConfigFullMethod           = "/" + PolykeyAdminServiceName + "/" + cts.MethodReloadConfig
+	adminQueryAuditEventsFullMethod       = "/" + PolykeyAdminServiceName + "/" + cts.MethodQueryAuditEvents
+	adminRewrapKeysFullMethod             = "/" + PolykeyAdminServiceName + "/" + cts.MethodRewrapKeys
+	adminApplyKeyFullMethod               = "/" + PolykeyAdminServiceName + "/" + cts.MethodApplyKey
+	adminGetKeyByAliasFullMethod          = "/" + PolykeyAdminServiceName + "/" + cts.MethodGetKeyByAlias
+	adminListActiveTokensFullMethod       = "/" + PolykeyAdminServiceName + "/" + cts.MethodListActiveTokens
+	adminRevokeAllForClientFullMethod     = "/" + PolykeyAdminServiceName + "/" + cts.MethodRevokeAllForClient
+	adminVerifyAuditIntegrityFullMethod   = "/" + PolykeyAdminServiceName + "/" + cts.MethodVerifyAuditIntegrity
+	adminRestoreAuditArchivesFullMethod   = "/" + PolykeyAdminServiceName + "/" + cts.MethodRestoreAuditArchives
+	adminSetLogLevelFullMethod            = "/" + PolykeyAdminServiceName + "/" + cts.MethodSetLogLevel
+	adminGetEffectiveConfigFullMethod     = "/" + PolykeyAdminServiceName + "/" + cts.MethodGetEffectiveConfig
+	adminRotateBootstrapSecretsFullMethod = "/" + PolykeyAdminServiceName + "/" + cts.MethodRotateBootstrapSecrets
+	adminGetOpenAPIDocumentFullMethod     = "/" + PolykeyAdminServiceName + "/" + cts.MethodGetOpenAPIDocument
+)
+
+// adminOnlyMethods are the companion service methods the admin service also serves. The
+// data-plane listener refuses them while the admin listener is enabled.
+var adminOnlyMethods = map[string]struct{}{
+	controlCircuitBreakerFullMethod:  {},
+	queryAuditEventsFullMethod:       {},
+	verifyAuditIntegrityFullMethod:   {},
+	restoreAuditArchivesFullMethod:   {},
+	setLogLevelFullMethod:            {},
+	getEffectiveConfigFullMethod:     {},
+	rotateBootstrapSecretsFullMethod: {},
+}
+
+// PolykeyAdminServer is the server API for the admin service.
+type PolykeyAdminServer interface {
+	ListClients(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	PutClient(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	DeleteClient(context.Context, *structpb.Struct) (*emptypb.Empty, error)
+	ListRoles(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	PutRole(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	DeleteRole(context.Context, *structpb.Struct) (*emptypb.Empty, error)
+	ControlCircuitBreaker(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	FlushCaches(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ReloadConfig(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	QueryAuditEvents(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	RewrapKeys(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ApplyKey(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	GetKeyByAlias(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ListActiveTokens(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	RevokeAllForClient(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	VerifyAuditIntegrity(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	RestoreAuditArchives(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	SetLogLevel(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	GetEffectiveConfig(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	RotateBootstrapSecrets(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	GetOpenAPIDocument(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}
+
+// PolykeyAdminServiceDesc is the grpc.ServiceDesc for the admin service.
+var PolykeyAdminServiceDesc = grpc.ServiceDesc{
+	ServiceName: PolykeyAdminServiceName,
+	HandlerType: (*PolykeyAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod(cts.MethodListClients, adminListClientsFullMethod, PolykeyAdminServer.ListClients),
+		unaryMethod(cts.MethodPutClient, adminPutClientFullMethod, PolykeyAdminServer.PutClient),
+		unaryMethod(cts.MethodDeleteClient, adminDeleteClientFullMethod, PolykeyAdminServer.DeleteClient),
+		unaryMethod(cts.MethodListRoles, adminListRolesFullMethod, PolykeyAdminServer.ListRoles),
+		unaryMethod(cts.MethodPutRole, adminPutRoleFullMethod, PolykeyAdminServer.PutRole),
+		unaryMethod(cts.MethodDeleteRole, adminDeleteRoleFullMethod, PolykeyAdminServer.DeleteRole),
+		unaryMethod(cts.MethodControlCircuitBreaker, adminControlCircuitBreakerFullMethod, PolykeyAdminServer.ControlCircuitBreaker),
+		unaryMethod(cts.MethodFlushCaches, adminFlushCachesFullMethod, PolykeyAdminServer.FlushCaches),
+		unaryMethod(cts.MethodReloadConfig, adminReloadConfigFullMethod, PolykeyAdminServer.ReloadConfig),
+		unaryMethod(cts.MethodQueryAuditEvents, adminQueryAuditEventsFullMethod, PolykeyAdminServer.QueryAuditEvents),
+		unaryMethod(cts.MethodRewrapKeys, adminRewrapKeysFullMethod, PolykeyAdminServer.RewrapKeys),
+		unaryMethod(cts.MethodApplyKey, adminApplyKeyFullMethod, PolykeyAdminServer.ApplyKey),
+		unaryMethod(cts.MethodGetKeyByAlias, adminGetKeyByAliasFullMethod, PolykeyAdminServer.GetKeyByAlias),
+		unaryMethod(cts.MethodListActiveTokens, adminListActiveTokensFullMethod, PolykeyAdminServer.ListActiveTokens),
+		unaryMethod(cts.MethodRevokeAllForClient, adminRevokeAllForClientFullMethod, PolykeyAdminServer.RevokeAllForClient),
+		unaryMethod(cts.MethodVerifyAuditIntegrity, adminVerifyAuditIntegrityFullMethod, PolykeyAdminServer.VerifyAuditIntegrity),
+		unaryMethod(cts.MethodRestoreAuditArchives, adminRestoreAuditArchivesFullMethod, PolykeyAdminServer.RestoreAuditArchives),
+		unaryMethod(cts.MethodSetLogLevel, adminSetLogLevelFullMethod, PolykeyAdminServer.SetLogLevel),
+		unaryMethod(cts.MethodGetEffectiveConfig, adminGetEffectiveConfigFullMethod, PolykeyAdminServer.GetEffectiveConfig),
+		unaryMethod(cts.MethodRotateBootstrapSecrets, adminRotateBootstrapSecretsFullMethod, PolykeyAdminServer.RotateBootstrapSecrets),
+		unaryMethod(cts.MethodGetOpenAPIDocument, adminGetOpenAPIDocumentFullMethod, PolykeyAdminServer.GetOpenAPIDocument),
+	},
+}
+
+// PolykeyAdminClient is the client API for the admin service.
+type PolykeyAdminClient interface {
+	ListClients(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error)
+	PutClient(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	DeleteClient(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListRoles(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error)
+	PutRole(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	DeleteRole(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ControlCircuitBreaker(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	FlushCaches(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	ReloadConfig(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error)
+	QueryAuditEvents(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	RewrapKeys(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	ApplyKey(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	GetKeyByAlias(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	ListActiveTokens(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	RevokeAllForClient(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	VerifyAuditIntegrity(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error)
+	RestoreAuditArchives(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	SetLogLevel(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	GetEffectiveConfig(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	RotateBootstrapSecrets(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error)
+	GetOpenAPIDocument(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error)
+}
+
+type polykeyAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPolykeyAdminClient(cc grpc.ClientConnInterface) PolykeyAdminClient {
+	return &polykeyAdminClient{cc: cc}
+}
+
+func (c *polykeyAdminClient) ListClients(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminListClientsFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) PutClient(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminPutClientFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) DeleteClient(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return invokeUnary[emptypb.Empty](ctx, c.cc, adminDeleteClientFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) ListRoles(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminListRolesFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) PutRole(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminPutRoleFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) DeleteRole(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return invokeUnary[emptypb.Empty](ctx, c.cc, adminDeleteRoleFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) ControlCircuitBreaker(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminControlCircuitBreakerFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) FlushCaches(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminFlushCachesFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) ReloadConfig(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminReloadConfigFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) QueryAuditEvents(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminQueryAuditEventsFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) RewrapKeys(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminRewrapKeysFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) ApplyKey(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminApplyKeyFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) GetKeyByAlias(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminGetKeyByAliasFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) ListActiveTokens(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminListActiveTokensFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) RevokeAllForClient(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminRevokeAllForClientFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) VerifyAuditIntegrity(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminVerifyAuditIntegrityFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) RestoreAuditArchives(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminRestoreAuditArchivesFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) SetLogLevel(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminSetLogLevelFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) GetEffectiveConfig(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminGetEffectiveConfigFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) RotateBootstrapSecrets(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminRotateBootstrapSecretsFullMethod, in, opts...)
+}
+
+func (c *polykeyAdminClient) GetOpenAPIDocument(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, adminGetOpenAPIDocumentFullMethod, in, opts...)
+}
+
+// ListClients returns the registered API clients as clients, a list with the id,
+// permissions and namespace of each. API key hashes are not returned.
+func (s *PolykeyService) ListClients(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodListClients, cts.MethodScopes[cts.MethodListClients], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			if s.deps.ClientManager == nil {
+				return nil, app_errors.ErrClientManagementUnavailable
+			}
+			clients, err := s.deps.ClientManager.ListClients(ctx)
+			if err != nil {
+				return nil, err
+			}
+			values := make([]*structpb.Value, len(clients))
+			for i, client := range clients {
+				values[i] = structpb.NewStructValue(clientStruct(client))
+			}
+			return &structpb.Struct{Fields: map[string]*structpb.Value{
+				"clients": structpb.NewListValue(&structpb.ListValue{Values: values}),
+			}}, nil
+		})
+}
+
+// PutClient registers an API client or replaces the one with the same id. The request
+// has id, hashed_api_key, a bcrypt hash of the client's API key, permissions, the roles
+// its tokens carry, and optionally namespace, tier, one of free, pro and enterprise,
+// token_ttl, a duration such as "12h" that overrides the lifetime of its tokens, and
+// totp_secret, the base32 secret of its one-time passwords. The response is the client
+// without its hash and secret. Changes are audited.
+func (s *PolykeyService) PutClient(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodPutClient, cts.MethodScopes[cts.MethodPutClient], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			client, err := clientFromStruct(req)
+			if err != nil {
+				return nil, err
+			}
+			if s.deps.ClientManager == nil {
+				return nil, app_errors.ErrClientManagementUnavailable
+			}
+			if err := s.deps.ClientManager.PutClient(ctx, client); err != nil {
+				return nil, clientManagementError(err)
+			}
+			changes := []domain.AuditChange{{Field: "clients." + client.ID, New: strings.Join(client.Permissions, ",")}}
+			s.deps.Audit.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), callerIdentity(ctx), cts.MethodPutClient, "", "", true, nil)
+			return clientStruct(client), nil
+		})
+}
+
+// DeleteClient removes the API client named by the request's id. Tokens already issued
+// to it stay valid until they expire. Deletions are audited.
+func (s *PolykeyService) DeleteClient(ctx context.Context, req *structpb.Struct) (*emptypb.Empty, error) {
+	return execWithoutKey(s, ctx, cts.MethodDeleteClient, cts.MethodScopes[cts.MethodDeleteClient], nil, nil,
+		func(ctx context.Context) (*emptypb.Empty, error) {
+			id, err := nameFromStruct(req, "id")
+			if err != nil {
+				return nil, err
+			}
+			if s.deps.ClientManager == nil {
+				return nil, app_errors.ErrClientManagementUnavailable
+			}
+			if err := s.deps.ClientManager.DeleteClient(ctx, id); err != nil {
+				return nil, clientManagementError(err)
+			}
+			changes := []domain.AuditChange{{Field: "clients." + id, Old: "registered"}}
+			s.deps.Audit.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), callerIdentity(ctx), cts.MethodDeleteClient, "", "", true, nil)
+			return emptyResponse, nil
+		})
+}
+
+// ListRoles returns roles, a map from each role to the operations it allows.
+func (s *PolykeyService) ListRoles(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodListRoles, cts.MethodScopes[cts.MethodListRoles], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			if s.deps.RoleManager == nil {
+				return nil, app_errors.ErrRoleManagementUnavailable
+			}
+			roles := make(map[string]*structpb.Value)
+			for name, operations := range s.deps.RoleManager.Roles() {
+				roles[name] = stringListValue(operations)
+			}
+			return &structpb.Struct{Fields: map[string]*structpb.Value{
+				"roles": structpb.NewStructValue(&structpb.Struct{Fields: roles}),
+			}}, nil
+		})
+}
+
+// PutRole defines the request's role, or replaces its allowed_operations, a list of
+// operations such as "keys:read" or "*" for all. Roles changed at runtime last until the
+// service restarts; update authorization.roles in the config to keep them. Changes are
+// audited.
+func (s *PolykeyService) PutRole(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodPutRole, cts.MethodScopes[cts.MethodPutRole], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			role, operations, err := roleFromStruct(req)
+			if err != nil {
+				return nil, err
+			}
+			if s.deps.RoleManager == nil {
+				return nil, app_errors.ErrRoleManagementUnavailable
+			}
+			old := strings.Join(s.deps.RoleManager.Roles()[role], ",")
+			s.deps.RoleManager.PutRole(role, operations)
+
+			changes := []domain.AuditChange{{Field: "authorization.roles." + role, Old: old, New: strings.Join(operations, ",")}}
+			s.deps.Audit.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), callerIdentity(ctx), cts.MethodPutRole, "", "", true, nil)
+			return &structpb.Struct{Fields: map[string]*structpb.Value{
+				"role":               structpb.NewStringValue(role),
+				"allowed_operations": stringListValue(operations),
+			}}, nil
+		})
+}
+
+// DeleteRole removes the request's role. Tokens that carry it allow nothing through it
+// from then on. Deletions are audited.
+func (s *PolykeyService) DeleteRole(ctx context.Context, req *structpb.Struct) (*emptypb.Empty, error) {
+	return execWithoutKey(s, ctx, cts.MethodDeleteRole, cts.MethodScopes[cts.MethodDeleteRole], nil, nil,
+		func(ctx context.Context) (*emptypb.Empty, error) {
+			role, err := nameFromStruct(req, "role")
+			if err != nil {
+				return nil, err
+			}
+			if s.deps.RoleManager == nil {
+				return nil, app_errors.ErrRoleManagementUnavailable
+			}
+			old := strings.Join(s.deps.RoleManager.Roles()[role], ",")
+			if !s.deps.RoleManager.DeleteRole(role) {
+				return nil, fmt.Errorf("%w: %s", app_errors.ErrRoleNotFound, role)
+			}
+			changes := []domain.AuditChange{{Field: "authorization.roles." + role, Old: old}}
+			s.deps.Audit.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), callerIdentity(ctx), cts.MethodDeleteRole, "", "", true, nil)
+			return emptyResponse, nil
+		})
+}
+
+// FlushCaches drops the cached entries of the caches the request's caches lists, or of
+// all of them when it is empty. The response's flushed lists the caches flushed.
+// Flushes are audited.
+func (s *PolykeyService) FlushCaches(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodFlushCaches, cts.MethodScopes[cts.MethodFlushCaches], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			names, err := cacheNamesFromStruct(req)
+			if err != nil {
+				return nil, err
+			}
+			if len(names) == 0 {
+				for name := range s.deps.Caches {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+			}
+			for _, name := range names {
+				if _, ok := s.deps.Caches[name]; !ok {
+					return nil, fmt.Errorf("%w: unknown cache %s", app_errors.ErrInvalidInput, name)
+				}
+			}
+
+			for _, name := range names {
+				s.deps.Caches[name].FlushCache(ctx)
+			}
+			s.deps.Audit.AuditLog(ctx, callerIdentity(ctx), cts.MethodFlushCaches, "", "", true, nil)
+			s.deps.Logger.InfoContext(ctx, "caches flushed", "caches", strings.Join(names, ","), "client", callerIdentity(ctx))
+			return &structpb.Struct{Fields: map[string]*structpb.Value{
+				"flushed": stringListValue(names),
+			}}, nil
+		})
+}
+
+// ReloadConfig reloads the config now instead of at the next reload interval. The
+// response's changes lists the key, old and new value of each reloadable setting that
+// changed. If the config fails to load, the service keeps the current one and the call
+// fails. Reloads that change settings are audited.
+func (s *PolykeyService) ReloadConfig(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodReloadConfig, cts.MethodScopes[cts.MethodReloadConfig], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			if s.deps.ReloadConfig == nil {
+				return nil, app_errors.ErrConfigReloadUnavailable
+			}
+			changes, err := s.deps.ReloadConfig(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", app_errors.ErrConfigReloadFailed, err)
+			}
+
+			values := make([]*structpb.Value, len(changes))
+			auditChanges := make([]domain.AuditChange, len(changes))
+			for i, change := range changes {
+				values[i] = structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+					"key": structpb.NewStringValue(change.Key),
+					"old": structpb.NewStringValue(change.Old),
+					"new": structpb.NewStringValue(change.New),
+				}})
+				auditChanges[i] = domain.AuditChange{Field: change.Key, Old: change.Old, New: change.New}
+			}
+			if len(changes) > 0 {
+				s.deps.Audit.AuditLog(domain.NewContextWithAuditChanges(ctx, auditChanges), callerIdentity(ctx), cts.MethodReloadConfig, "", "", true, nil)
+			}
+			return &structpb.Struct{Fields: map[string]*structpb.Value{
+				"changes": structpb.NewListValue(&structpb.ListValue{Values: values}),
+			}}, nil
+		})
+}
+
+// RewrapKeys moves the DEKs wrapped by from_provider to to_provider, in batches of
+// batch_size key versions with batch_interval, a duration such as "500ms", between them.
+// It stops after max_batches batches when that is set, and the next call resumes from
+// the checkpoint; restart discards the checkpoint first. The response is the checkpoint
+// reached: rewrapped, failed, the last key version finished and, once no version is left,
+// completed_at. Every call is audited.
+func (s *PolykeyService) RewrapKeys(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodRewrapKeys, cts.MethodScopes[cts.MethodRewrapKeys], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			if s.deps.KMSRewrap == nil {
+				return nil, app_errors.ErrKMSRewrapUnavailable
+			}
+			rewrapReq, err := rewrapRequestFromStruct(req)
+			if err != nil {
+				return nil, err
+			}
+
+			checkpoint, err := s.deps.KMSRewrap.RewrapKeys(ctx, rewrapReq)
+			if checkpoint != nil {
+				auditChanges := []domain.AuditChange{{Field: "kms_provider", Old: rewrapReq.FromProvider, New: rewrapReq.ToProvider}}
+				s.deps.Audit.AuditLog(domain.NewContextWithAuditChanges(ctx, auditChanges), callerIdentity(ctx), cts.MethodRewrapKeys, "", "", err == nil, err)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return rewrapCheckpointStruct(checkpoint), nil
+		})
+}
+
+// ApplyKey makes the key named by the request's alias match the request, for
+// declarative tools such as a Terraform provider. The request has alias, key_type, a
+// KeyType name such as "KEY_TYPE_AES_256", and optionally description, tags, a map of
+// strings that replaces the key's tags, and etag, the etag the key was read with. A key
+// is created when the alias names none, or only a revoked one; otherwise its description
+// and tags are updated, and a call that changes nothing succeeds without effect. A stale
+// etag fails with ETAG_MISMATCH. The response is the key, as GetKeyByAlias returns it,
+// with created telling whether the call created it. Changes are audited.
+func (s *PolykeyService) ApplyKey(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodApplyKey, cts.MethodScopes[cts.MethodApplyKey], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			spec, err := keySpecFromStruct(req)
+			if err != nil {
+				return nil, err
+			}
+			provisioned, err := s.deps.KeyService.ApplyKey(ctx, spec, &pk.RequesterContext{ClientIdentity: callerIdentity(ctx)})
+			if err != nil {
+				return nil, err
+			}
+			resp := provisionedKeyStruct(provisioned)
+			resp.Fields["created"] = structpb.NewBoolValue(provisioned.Created)
+			return resp, nil
+		})
+}
+
+// GetKeyByAlias returns the key named by the request's alias: alias, key_id, key_type,
+// version, status, description, tags, updated_at and etag, which ApplyKey takes to
+// apply a change only to the state read.
+func (s *PolykeyService) GetKeyByAlias(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodGetKeyByAlias, cts.MethodScopes[cts.MethodGetKeyByAlias], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			alias, err := nameFromStruct(req, "alias")
+			if err != nil {
+				return nil, err
+			}
+			provisioned, err := s.deps.KeyService.GetKeyByAlias(ctx, alias)
+			if err != nil {
+				return nil, err
+			}
+			return provisionedKeyStruct(provisioned), nil
+		})
+}
+
+// ListActiveTokens returns tokens, the unexpired and unrevoked access tokens of the
+// request's client_id, or of every client when it has none, oldest first. Each has
+// token_id, client_id, namespace, issued_at and expires_at; the tokens themselves are
+// not returned.
+func (s *PolykeyService) ListActiveTokens(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodListActiveTokens, cts.MethodScopes[cts.MethodListActiveTokens], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			var clientID string
+			if len(req.GetFields()) > 0 {
+				var err error
+				if clientID, err = nameFromStruct(req, "client_id"); err != nil {
+					return nil, err
+				}
+			}
+			if s.deps.Sessions == nil {
+				return nil, app_errors.ErrSessionManagementUnavailable
+			}
+			sessions, err := s.deps.Sessions.ListActiveTokens(ctx, clientID)
+			if err != nil {
+				return nil, err
+			}
+			return &structpb.Struct{Fields: map[string]*structpb.Value{
+				"tokens": tokenSessionsValue(sessions),
+			}}, nil
+		})
+}
+
+// RevokeAllForClient revokes every active access token of the request's client_id, for
+// incident response. The client can still authenticate again; delete it or change its
+// API key to keep it out. The response's revoked lists the tokens revoked, as
+// ListActiveTokens returns them. Revocations are audited.
+func (s *PolykeyService) RevokeAllForClient(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodRevokeAllForClient, cts.MethodScopes[cts.MethodRevokeAllForClient], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			clientID, err := nameFromStruct(req, "client_id")
+			if err != nil {
+				return nil, err
+			}
+			if s.deps.Sessions == nil {
+				return nil, app_errors.ErrSessionManagementUnavailable
+			}
+			revoked, err := s.deps.Sessions.RevokeAllForClient(ctx, clientID)
+			if err != nil {
+				return nil, err
+			}
+			changes := []domain.AuditChange{{Field: "sessions." + clientID, Old: strconv.Itoa(len(revoked)), New: "0"}}
+			s.deps.Audit.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), callerIdentity(ctx), cts.MethodRevokeAllForClient, "", "", true, nil)
+			s.deps.Logger.InfoContext(ctx, "client tokens revoked", "target_client", clientID, "revoked", len(revoked), "client", callerIdentity(ctx))
+			return &structpb.Struct{Fields: map[string]*structpb.Value{
+				"revoked": tokenSessionsValue(revoked),
+			}}, nil
+		})
+}
+
+// callerIdentity returns the ID of the authenticated user in ctx, or "" without one.
+func callerIdentity(ctx context.Context) string {
+	if user, ok := domain.UserFromContext(ctx); ok {
+		return user.ID
+	}
+	return ""
+}
+
+// clientManagementError maps the client store's errors to the service's.
+func clientManagementError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrClientNotFound):
+		return fmt.Errorf("%w: %v", app_errors.ErrClientNotFound, err)
+	case errors.Is(err, auth.ErrInvalidConfig):
+		return fmt.Errorf("%w: %v", app_errors.ErrInvalidInput, err)
+	default:
+		return err
+	}
+}
+
+func tokenSessionsValue(sessions []domain.TokenSession) *structpb.Value {
+	values := make([]*structpb.Value, len(sessions))
+	for i, session := range sessions {
+		values[i] = structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"token_id":   structpb.NewStringValue(session.TokenID),
+			"client_id":  structpb.NewStringValue(session.ClientID),
+			"namespace":  structpb.NewStringValue(session.Namespace),
+			"issued_at":  structpb.NewStringValue(session.IssuedAt.UTC().Format(time.RFC3339Nano)),
+			"expires_at": structpb.NewStringValue(session.ExpiresAt.UTC().Format(time.RFC3339Nano)),
+		}})
+	}
+	return structpb.NewListValue(&structpb.ListValue{Values: values})
+}
+
+func clientStruct(client domain.Client) *structpb.Struct {
+	fields := map[string]*structpb.Value{
+		"id":          structpb.NewStringValue(client.ID),
+		"permissions": stringListValue(client.Permissions),
+		"namespace":   structpb.NewStringValue(client.Namespace),
+		"tier":        structpb.NewStringValue(string(client.Tier)),
+	}
+	if client.TokenTTL != 0 {
+		fields["token_ttl"] = structpb.NewStringValue(client.TokenTTL.String())
+	}
+	fields["totp_enrolled"] = structpb.NewBoolValue(client.TOTPSecret != "")
+	return &structpb.Struct{Fields: fields}
+}
+
+// clientFromStruct reads the client of a PutClient request.
+func clientFromStruct(req *structpb.Struct) (domain.Client, error) {
+	var client domain.Client
+	for name, value := range req.GetFields() {
+		var err error
+		switch name {
+		case "id":
+			client.ID, err = structString(name, value)
+		case "hashed_api_key":
+			client.HashedAPIKey, err = structString(name, value)
+		case "permissions":
+			client.Permissions, err = structStringList(name, value)
+		case "namespace":
+			client.Namespace, err = structString(name, value)
+		case "tier":
+			var tier string
+			tier, err = structString(name, value)
+			client.Tier = domain.KeyTier(tier)
+		case "token_ttl":
+			client.TokenTTL, err = structDuration(name, value)
+		case "totp_secret":
+			client.TOTPSecret, err = structString(name, value)
+		default:
+			err = fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+		}
+		if err != nil {
+			return domain.Client{}, err
+		}
+	}
+	if client.ID == "" {
+		return domain.Client{}, fmt.Errorf("%w: id is required", app_errors.ErrInvalidInput)
+	}
+	return client, nil
+}
+
+// roleFromStruct reads the role and allowed operations of a PutRole request.
+func roleFromStruct(req *structpb.Struct) (role string, operations []string, err error) {
+	for name, value := range req.GetFields() {
+		switch name {
+		case "role":
+			role, err = structString(name, value)
+		case "allowed_operations":
+			operations, err = structStringList(name, value)
+		default:
+			err = fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	if role == "" {
+		return "", nil, fmt.Errorf("%w: role is required", app_errors.ErrInvalidInput)
+	}
+	if len(operations) == 0 {
+		return "", nil, fmt.Errorf("%w: allowed_operations is required", app_errors.ErrInvalidInput)
+	}
+	return role, operations, nil
+}
+
+// nameFromStruct reads a request whose only, required, field is field.
+func nameFromStruct(req *structpb.Struct, field string) (string, error) {
+	var value string
+	for name, v := range req.GetFields() {
+		if name != field {
+			return "", fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+		}
+		var err error
+		if value, err = structString(name, v); err != nil {
+			return "", err
+		}
+	}
+	if value == "" {
+		return "", fmt.Errorf("%w: %s is required", app_errors.ErrInvalidInput, field)
+	}
+	return value, nil
+}
+
+// cacheNamesFromStruct reads the caches of a FlushCaches request.
+func cacheNamesFromStruct(req *structpb.Struct) ([]string, error) {
+	var names []string
+	for name, value := range req.GetFields() {
+		if name != "caches" {
+			return nil, fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+		}
+		var err error
+		if names, err = structStringList(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return slices.Compact(slices.Sorted(slices.Values(names))), nil
+}
+
+// rewrapRequestFromStruct reads a RewrapKeys request.
+func rewrapRequestFromStruct(req *structpb.Struct) (domain.RewrapRequest, error) {
+	var rewrapReq domain.RewrapRequest
+	for name, value := range req.GetFields() {
+		var err error
+		switch name {
+		case "from_provider":
+			rewrapReq.FromProvider, err = structString(name, value)
+		case "to_provider":
+			rewrapReq.ToProvider, err = structString(name, value)
+		case "batch_size":
+			rewrapReq.BatchSize, err = structInt(name, value)
+		case "max_batches":
+			rewrapReq.MaxBatches, err = structInt(name, value)
+		case "batch_interval":
+			var interval string
+			if interval, err = structString(name, value); err == nil {
+				if rewrapReq.BatchInterval, err = time.ParseDuration(interval); err != nil {
+					err = fmt.Errorf("%w: %s must be a duration", app_errors.ErrInvalidInput, name)
+				}
+			}
+		case "restart":
+			b, ok := value.GetKind().(*structpb.Value_BoolValue)
+			if !ok {
+				err = fmt.Errorf("%w: %s must be a bool", app_errors.ErrInvalidInput, name)
+				break
+			}
+			rewrapReq.Restart = b.BoolValue
+		default:
+			err = fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+		}
+		if err != nil {
+			return domain.RewrapRequest{}, err
+		}
+	}
+	return rewrapReq, nil
+}
+
+// keySpecFromStruct reads the spec of an ApplyKey request.
+func keySpecFromStruct(req *structpb.Struct) (domain.KeySpec, error) {
+	var spec domain.KeySpec
+	for name, value := range req.GetFields() {
+		var err error
+		switch name {
+		case "alias":
+			spec.Alias, err = structString(name, value)
+		case "key_type":
+			var keyType string
+			if keyType, err = structString(name, value); err == nil {
+				number, ok := pk.KeyType_value[keyType]
+				if !ok {
+					err = fmt.Errorf("%w: unknown key_type %s", app_errors.ErrInvalidInput, keyType)
+				}
+				spec.KeyType = pk.KeyType(number)
+			}
+		case "description":
+			spec.Description, err = structString(name, value)
+		case "tags":
+			tags, ok := value.GetKind().(*structpb.Value_StructValue)
+			if !ok {
+				err = fmt.Errorf("%w: %s must be a map of strings", app_errors.ErrInvalidInput, name)
+				break
+			}
+			spec.Tags = make(map[string]string, len(tags.StructValue.GetFields()))
+			for tag, tagValue := range tags.StructValue.GetFields() {
+				if spec.Tags[tag], err = structString(name+"."+tag, tagValue); err != nil {
+					break
+				}
+			}
+		case "etag":
+			spec.ETag, err = structString(name, value)
+		default:
+			err = fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+		}
+		if err != nil {
+			return domain.KeySpec{}, err
+		}
+	}
+	if spec.Alias == "" {
+		return domain.KeySpec{}, fmt.Errorf("%w: alias is required", app_errors.ErrInvalidInput)
+	}
+	return spec, nil
+}
+
+func provisionedKeyStruct(provisioned *domain.ProvisionedKey) *structpb.Struct {
+	metadata := provisioned.Metadata
+	tags := make(map[string]*structpb.Value, len(metadata.GetTags()))
+	for name, value := range metadata.GetTags() {
+		tags[name] = structpb.NewStringValue(value)
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"alias":       structpb.NewStringValue(provisioned.Alias),
+		"key_id":      structpb.NewStringValue(metadata.GetKeyId()),
+		"key_type":    structpb.NewStringValue(metadata.GetKeyType().String()),
+		"version":     structpb.NewNumberValue(float64(metadata.GetVersion())),
+		"status":      structpb.NewStringValue(metadata.GetStatus().String()),
+		"description": structpb.NewStringValue(metadata.GetDescription()),
+		"tags":        structpb.NewStructValue(&structpb.Struct{Fields: tags}),
+		"updated_at":  structpb.NewStringValue(metadata.GetUpdatedAt().AsTime().UTC().Format(time.RFC3339Nano)),
+		"etag":        structpb.NewStringValue(provisioned.ETag),
+	}}
+}
+
+func rewrapCheckpointStruct(checkpoint *domain.RewrapCheckpoint) *structpb.Struct {
+	fields := map[string]*structpb.Value{
+		"from_provider": structpb.NewStringValue(checkpoint.FromProvider),
+		"to_provider":   structpb.NewStringValue(checkpoint.ToProvider),
+		"rewrapped":     structpb.NewNumberValue(float64(checkpoint.Rewrapped)),
+		"failed":        structpb.NewNumberValue(float64(checkpoint.Failed)),
+		"last_key_id":   structpb.NewStringValue(checkpoint.After.KeyID),
+		"last_version":  structpb.NewNumberValue(float64(checkpoint.After.Version)),
+		"completed":     structpb.NewBoolValue(checkpoint.CompletedAt != nil),
+	}
+	if checkpoint.CompletedAt != nil {
+		fields["completed_at"] = structpb.NewStringValue(checkpoint.CompletedAt.UTC().Format(time.RFC3339Nano))
+	}
+	return &structpb.Struct{Fields: fields}
+}
+
+// structInt reads a non-negative integer.
+func structInt(name string, value *structpb.Value) (int, error) {
+	n, ok := value.GetKind().(*structpb.Value_NumberValue)
+	if !ok || n.NumberValue < 0 || n.NumberValue != float64(int(n.NumberValue)) {
+		return 0, fmt.Errorf("%w: %s must be a non-negative integer", app_errors.ErrInvalidInput, name)
+	}
+	return int(n.NumberValue), nil
+}
+
+func structStringList(name string, value *structpb.Value) ([]string, error) {
+	list, ok := value.GetKind().(*structpb.Value_ListValue)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s must be a list of strings", app_errors.ErrInvalidInput, name)
+	}
+	values := make([]string, 0, len(list.ListValue.GetValues()))
+	for _, item := range list.ListValue.GetValues() {
+		s, err := structString(name, item)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a list of strings", app_errors.ErrInvalidInput, name)
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+func stringListValue(values []string) *structpb.Value {
+	list := make([]*structpb.Value, len(values))
+	for i, value := range values {
+		list[i] = structpb.NewStringValue(value)
+	}
+	return structpb.NewListValue(&structpb.ListValue{Values: list})
+}
+
+// GetOpenAPIDocument returns the OpenAPI document of the Polykey services. The response
+// is the document itself, so its JSON form can be fed to client generators as is.
+func (s *PolykeyService) GetOpenAPIDocument(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodGetOpenAPIDocument, cts.MethodScopes[cts.MethodGetOpenAPIDocument], nil, nil,
+		func(context.Context) (*structpb.Struct, error) {
+			return OpenAPIDocument()
+		})
+}
+
+// refuseAdminMethods rejects the admin service's methods on the data-plane listener, so
+// that they are only reachable through the admin listener's stricter mTLS.
+func refuseAdminMethods() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if _, ok := adminOnlyMethods[info.FullMethod]; ok {
+			return nil, status.Errorf(codes.Unimplemented, "%s is served by the admin listener", info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
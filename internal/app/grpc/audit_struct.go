@@ -0,0 +1,157 @@
+package grpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// auditQueryFromStruct reads a QueryAuditEvents request. Unknown fields are rejected so
+// that a misspelt filter does not silently widen the search.
+func auditQueryFromStruct(req *structpb.Struct) (domain.AuditQuery, string, error) {
+	var query domain.AuditQuery
+	var pageToken string
+	for name, value := range req.GetFields() {
+		var err error
+		switch name {
+		case "client_identity":
+			query.ClientIdentity, err = structString(name, value)
+		case "key_id":
+			query.KeyID, err = structString(name, value)
+		case "operation":
+			query.Operation, err = structString(name, value)
+		case "success":
+			b, ok := value.GetKind().(*structpb.Value_BoolValue)
+			if !ok {
+				err = fmt.Errorf("%w: %s must be a bool", app_errors.ErrInvalidInput, name)
+				break
+			}
+			query.Success = &b.BoolValue
+		case "from":
+			query.From, err = structTime(name, value)
+		case "to":
+			query.To, err = structTime(name, value)
+		case "page_size":
+			n, ok := value.GetKind().(*structpb.Value_NumberValue)
+			if !ok || n.NumberValue < 0 || n.NumberValue != float64(int(n.NumberValue)) {
+				err = fmt.Errorf("%w: %s must be a non-negative integer", app_errors.ErrInvalidInput, name)
+				break
+			}
+			query.Limit = int(n.NumberValue)
+		case "page_token":
+			pageToken, err = structString(name, value)
+		default:
+			err = fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+		}
+		if err != nil {
+			return domain.AuditQuery{}, "", err
+		}
+	}
+	return query, pageToken, nil
+}
+
+// auditRangeFromStruct reads the from and to of a RestoreAuditArchives request.
+func auditRangeFromStruct(req *structpb.Struct) (from, to time.Time, err error) {
+	for name, value := range req.GetFields() {
+		switch name {
+		case "from":
+			from, err = structTime(name, value)
+		case "to":
+			to, err = structTime(name, value)
+		default:
+			err = fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+		}
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	return from, to, nil
+}
+
+func structString(name string, value *structpb.Value) (string, error) {
+	s, ok := value.GetKind().(*structpb.Value_StringValue)
+	if !ok {
+		return "", fmt.Errorf("%w: %s must be a string", app_errors.ErrInvalidInput, name)
+	}
+	return s.StringValue, nil
+}
+
+func structTime(name string, value *structpb.Value) (time.Time, error) {
+	s, err := structString(name, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be an RFC 3339 timestamp", app_errors.ErrInvalidInput, name)
+	}
+	return t, nil
+}
+
+func structDuration(name string, value *structpb.Value) (time.Duration, error) {
+	s, err := structString(name, value)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be a duration such as \"12h\"", app_errors.ErrInvalidInput, name)
+	}
+	return d, nil
+}
+
+func auditEventsStruct(events []*domain.AuditEvent, nextPageToken string) *structpb.Struct {
+	values := make([]*structpb.Value, 0, len(events))
+	for _, event := range events {
+		fields := map[string]*structpb.Value{
+			"id":               structpb.NewStringValue(event.ID),
+			"timestamp":        structpb.NewStringValue(event.Timestamp.UTC().Format(time.RFC3339Nano)),
+			"client_identity":  structpb.NewStringValue(event.ClientIdentity),
+			"operation":        structpb.NewStringValue(event.Operation),
+			"key_id":           structpb.NewStringValue(event.KeyID),
+			"auth_decision_id": structpb.NewStringValue(event.AuthDecisionID),
+			"correlation_id":   structpb.NewStringValue(event.CorrelationID),
+			"success":          structpb.NewBoolValue(event.Success),
+			"error":            structpb.NewStringValue(event.Error),
+			"peer_ip":          structpb.NewStringValue(event.PeerIP),
+			"user_agent":       structpb.NewStringValue(event.UserAgent),
+			"cert_fingerprint": structpb.NewStringValue(event.CertFingerprint),
+		}
+		if event.Sequence > 0 {
+			fields["sequence"] = structpb.NewNumberValue(float64(event.Sequence))
+			fields["hash"] = structpb.NewStringValue(event.Hash)
+		}
+		if len(event.Changes) > 0 {
+			changes := make([]*structpb.Value, len(event.Changes))
+			for i, change := range event.Changes {
+				changes[i] = structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+					"field": structpb.NewStringValue(change.Field),
+					"old":   structpb.NewStringValue(change.Old),
+					"new":   structpb.NewStringValue(change.New),
+				}})
+			}
+			fields["changes"] = structpb.NewListValue(&structpb.ListValue{Values: changes})
+		}
+		values = append(values, structpb.NewStructValue(&structpb.Struct{Fields: fields}))
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"events":          structpb.NewListValue(&structpb.ListValue{Values: values}),
+		"next_page_token": structpb.NewStringValue(nextPageToken),
+	}}
+}
+
+func auditRestoreStruct(result *domain.AuditRestoreResult) *structpb.Struct {
+	pending := make([]*structpb.Value, 0, len(result.Pending))
+	for _, key := range result.Pending {
+		pending = append(pending, structpb.NewStringValue(key))
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"archives":        structpb.NewNumberValue(float64(result.Archives)),
+		"restored_events": structpb.NewNumberValue(float64(result.RestoredEvents)),
+		"restored_until":  structpb.NewStringValue(result.RestoredUntil.UTC().Format(time.RFC3339Nano)),
+		"pending":         structpb.NewListValue(&structpb.ListValue{Values: pending}),
+	}}
+}
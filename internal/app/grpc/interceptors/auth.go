@@ -2,17 +2,17 @@ package interceptors
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
 	auth "github.com/spounge-ai/polykey/internal/infra/auth"
 	"github.com/spounge-ai/polykey/internal/infra/ratelimit"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
-	"google.golang.org/grpc/status"
 )
 
 var unprotectedMethods = map[string]struct{}{
@@ -20,61 +20,117 @@ var unprotectedMethods = map[string]struct{}{
 	"/polykey.v2.PolykeyService/Authenticate": {},
 }
 
+// IsUnprotected reports whether fullMethod is served without an access token.
+func IsUnprotected(fullMethod string) bool {
+	_, ok := unprotectedMethods[fullMethod]
+	return ok
+}
+
 // AuthenticationInterceptor validates the JWT token, extracts peer TLS info, and applies rate limiting.
-func AuthenticationInterceptor(tokenManager *auth.TokenManager, limiter ratelimit.Limiter) grpc.UnaryServerInterceptor {
+// Refused calls get errorClassifier's sanitized status, with a RetryInfo when rate limited.
+func AuthenticationInterceptor(tokenManager *auth.TokenManager, limiter ratelimit.Limiter, errorClassifier *app_errors.ErrorClassifier) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		if _, isUnprotected := unprotectedMethods[info.FullMethod]; isUnprotected {
 			return handler(ctx, req)
 		}
 
-		// Extract peer certificate information for zero-trust validation.
-		if p, ok := peer.FromContext(ctx); ok {
-			if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
-				if len(tlsInfo.State.PeerCertificates) > 0 {
-					// Add the leaf certificate to the context for the authorizer to use.
-					ctx = domain.NewContextWithPeerCert(ctx, tlsInfo.State.PeerCertificates[0])
-				}
-			}
+		ctx, err := authenticate(ctx, tokenManager, limiter)
+		if err != nil {
+			return nil, errorClassifier.Status(err, info.FullMethod)
 		}
 
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "metadata is not provided")
-		}
+		return handler(ctx, req)
+	}
+}
 
-		authHeaders := md.Get("authorization")
-		if len(authHeaders) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "authorization token is not provided")
+// StreamAuthenticationInterceptor applies the same checks as AuthenticationInterceptor to streaming RPCs.
+func StreamAuthenticationInterceptor(tokenManager *auth.TokenManager, limiter ratelimit.Limiter, errorClassifier *app_errors.ErrorClassifier) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if _, isUnprotected := unprotectedMethods[info.FullMethod]; isUnprotected {
+			return handler(srv, ss)
 		}
 
-		authHeader := authHeaders[0]
-		const bearerPrefix = "Bearer "
-		if !strings.HasPrefix(authHeader, bearerPrefix) {
-			return nil, status.Error(codes.Unauthenticated, "authorization header must use Bearer scheme")
+		ctx, err := authenticate(ss.Context(), tokenManager, limiter)
+		if err != nil {
+			return errorClassifier.Status(err, info.FullMethod)
 		}
 
-		token := authHeader[len(bearerPrefix):]
-		if token == "" {
-			return nil, status.Error(codes.Unauthenticated, "bearer token is empty")
-		}
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
 
-		claims, err := tokenManager.ValidateToken(ctx, token)
-		if err != nil {
-			return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+func authenticate(ctx context.Context, tokenManager *auth.TokenManager, limiter ratelimit.Limiter) (context.Context, error) {
+	// Extract peer certificate information for zero-trust validation.
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if len(tlsInfo.State.PeerCertificates) > 0 {
+				// Add the leaf certificate to the context for the authorizer to use.
+				ctx = domain.NewContextWithPeerCert(ctx, tlsInfo.State.PeerCertificates[0])
+			}
 		}
+	}
 
-		// Apply rate limiting based on the client ID from the token.
-		if !limiter.Allow(claims.UserID) {
-			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded for client %s", claims.UserID)
-		}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not provided", app_errors.ErrAuthentication)
+	}
 
-		user := &domain.AuthenticatedUser{
-			ID:          claims.UserID,
-			Permissions: claims.Roles,
-		}
+	authHeaders := md.Get("authorization")
+	if len(authHeaders) == 0 {
+		return nil, fmt.Errorf("%w: authorization token is not provided", app_errors.ErrAuthentication)
+	}
 
-		ctx = domain.NewContextWithUser(ctx, user)
+	authHeader := authHeaders[0]
+	const bearerPrefix = "Bearer "
+	if !strings.HasPrefix(authHeader, bearerPrefix) {
+		return nil, fmt.Errorf("%w: authorization header must use Bearer scheme", app_errors.ErrAuthentication)
+	}
 
-		return handler(ctx, req)
+	token := authHeader[len(bearerPrefix):]
+	if token == "" {
+		return nil, fmt.Errorf("%w: bearer token is empty", app_errors.ErrAuthentication)
+	}
+
+	claims, err := tokenManager.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid token: %v", app_errors.ErrAuthentication, err)
+	}
+	// A bound token is only as good as the key of the certificate it was issued to.
+	peerCert, _ := domain.PeerCertFromContext(ctx)
+	if err := auth.VerifyCertificateBinding(claims, peerCert); err != nil {
+		return nil, fmt.Errorf("%w: invalid token: %v", app_errors.ErrAuthentication, err)
+	}
+
+	// Apply rate limiting based on the client ID from the token.
+	if !limiter.Allow(ctx, claims.UserID) {
+		return nil, app_errors.WithRetryAfter(fmt.Errorf("%w for client %s", app_errors.ErrRateLimit, claims.UserID), limiter.RetryAfter())
 	}
+
+	namespace := claims.Namespace
+	if namespace == "" {
+		namespace = domain.DefaultNamespace
+	}
+
+	user := &domain.AuthenticatedUser{
+		ID:               claims.UserID,
+		Namespace:        namespace,
+		Permissions:      claims.Roles,
+		AuthMethods:      claims.AMR,
+		AuthContextClass: claims.ACR,
+	}
+
+	// Every request is scoped to the namespace of its token, so the repository only
+	// ever sees that namespace's keys.
+	ctx = domain.NewContextWithNamespace(ctx, namespace)
+	return domain.NewContextWithUser(ctx, user), nil
+}
+
+// wrappedServerStream overrides the context of a grpc.ServerStream.
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedServerStream) Context() context.Context {
+	return w.ctx
 }
@@ -0,0 +1,62 @@
+package interceptors
+
+import (
+	"context"
+	"path"
+
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+var batchTooLarge, _ = meter.Int64Counter(
+	"polykey.grpc.batch.too_large",
+	metric.WithDescription("Number of batch calls rejected for carrying more items than allowed, by method and tier."),
+)
+
+// BatchLimitInterceptor rejects the batch calls that carry more items, in their repeated
+// keys field, than cfg allows their method for the tier the request's RequesterContext
+// claims, as bulkheads use. It runs before validation, so that an oversized batch is
+// refused before any of its items is looked at.
+func BatchLimitInterceptor(cfg config.BatchConfig, errorClassifier *app_errors.ErrorClassifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := CheckBatchItems(ctx, cfg, info.FullMethod, req); err != nil {
+			return nil, errorClassifier.LogAndSanitize(ctx, errorClassifier.Classify(err, info.FullMethod))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// CheckBatchItems returns a BatchTooLargeError when req, a request of fullMethod, is a
+// batch carrying more items than cfg allows for its tier. Streaming batch RPCs, which
+// the interceptor does not see, call it on every message they receive.
+func CheckBatchItems(ctx context.Context, cfg config.BatchConfig, fullMethod string, req any) error {
+	items, ok := batchItems(req)
+	if !ok {
+		return nil
+	}
+	method, tier := path.Base(fullMethod), requesterTier(req)
+	if limit := cfg.MaxBatchItems(method, string(tier)); limit > 0 && items > limit {
+		batchTooLarge.Add(ctx, 1, metric.WithAttributes(attribute.String("rpc.method", fullMethod), attribute.String("tier", string(tier))))
+		return &app_errors.BatchTooLargeError{Method: method, Tier: string(tier), Items: items, MaxItems: limit}
+	}
+	return nil
+}
+
+// batchItems returns the length of the repeated keys field every batch request has, and
+// false for other requests.
+func batchItems(req any) (int, bool) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return 0, false
+	}
+	m := msg.ProtoReflect()
+	field := m.Descriptor().Fields().ByName("keys")
+	if field == nil || !field.IsList() {
+		return 0, false
+	}
+	return m.Get(field).List().Len(), true
+}
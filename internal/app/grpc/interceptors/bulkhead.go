@@ -0,0 +1,128 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/pkg/authorization"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+)
+
+var (
+	bulkheadInUse, _ = meter.Int64UpDownCounter(
+		"polykey.grpc.bulkhead.in_use",
+		metric.WithDescription("Number of calls holding a bulkhead slot, by method and tier."),
+	)
+	bulkheadRejected, _ = meter.Int64Counter(
+		"polykey.grpc.bulkhead.rejected",
+		metric.WithDescription("Number of calls rejected because their bulkhead was full, by method and tier."),
+	)
+)
+
+// Bulkheads limits the calls of each configured method served at once, separately for
+// each client tier. A call waits up to maxWait for a slot and otherwise fails with
+// CONCURRENCY_LIMITED, asked to retry after maxWait. The tier is the one the request's
+// RequesterContext claims, as storage profiles use.
+type Bulkheads struct {
+	methods         map[string]*methodBulkhead
+	maxWait         time.Duration
+	errorClassifier *app_errors.ErrorClassifier
+}
+
+// methodBulkhead holds one method's slots: a buffered channel per tier, nil where the
+// tier is unlimited.
+type methodBulkhead struct {
+	tiers    map[domain.KeyTier]chan struct{}
+	fallback chan struct{}
+}
+
+// NewBulkheads creates the bulkheads of cfg's methods.
+func NewBulkheads(cfg config.BulkheadConfig, errorClassifier *app_errors.ErrorClassifier) *Bulkheads {
+	b := &Bulkheads{methods: make(map[string]*methodBulkhead, len(cfg.Methods)), maxWait: cfg.MaxWait, errorClassifier: errorClassifier}
+	for method, limits := range cfg.Methods {
+		m := &methodBulkhead{tiers: make(map[domain.KeyTier]chan struct{}, len(limits.Tiers)), fallback: slots(limits.Default)}
+		for tier, limit := range limits.Tiers {
+			m.tiers[domain.KeyTier(strings.ToLower(tier))] = slots(limit)
+		}
+		b.methods[strings.ToLower(method)] = m
+	}
+	return b
+}
+
+func slots(limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	return make(chan struct{}, limit)
+}
+
+// UnaryInterceptor returns a unary interceptor that holds a slot for the duration of
+// each call of a bulkheaded method.
+func (b *Bulkheads) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		method, ok := b.methods[strings.ToLower(path.Base(info.FullMethod))]
+		if !ok {
+			return handler(ctx, req)
+		}
+		tier := requesterTier(req)
+		slot, ok := method.tiers[tier]
+		if !ok {
+			slot = method.fallback
+		}
+		if slot == nil {
+			return handler(ctx, req)
+		}
+
+		attrs := metric.WithAttributes(attribute.String("rpc.method", info.FullMethod), attribute.String("tier", string(tier)))
+		if !b.acquire(ctx, slot) {
+			bulkheadRejected.Add(ctx, 1, attrs)
+			err := fmt.Errorf("%w: %s for tier %s", app_errors.ErrConcurrencyLimited, path.Base(info.FullMethod), tier)
+			return nil, b.errorClassifier.Status(app_errors.WithRetryAfter(err, b.maxWait), info.FullMethod)
+		}
+		bulkheadInUse.Add(ctx, 1, attrs)
+		defer func() {
+			<-slot
+			bulkheadInUse.Add(context.WithoutCancel(ctx), -1, attrs)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// acquire takes a slot, waiting up to maxWait, and reports whether it got one.
+func (b *Bulkheads) acquire(ctx context.Context, slot chan struct{}) bool {
+	select {
+	case slot <- struct{}{}:
+		return true
+	default:
+	}
+	if b.maxWait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+	select {
+	case slot <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// requesterTier returns the client tier a request claims, or TierUnknown.
+func requesterTier(req any) domain.KeyTier {
+	if r, ok := req.(interface{ GetRequesterContext() *pk.RequesterContext }); ok {
+		return authorization.FromProtoTier(r.GetRequesterContext().GetClientTier())
+	}
+	return domain.TierUnknown
+}
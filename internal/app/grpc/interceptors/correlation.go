@@ -0,0 +1,68 @@
+package interceptors
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// CorrelationIDHeader is the metadata key used to propagate correlation IDs in both directions.
+const CorrelationIDHeader = "x-correlation-id"
+
+const maxCorrelationIDLength = 128
+
+// UnaryCorrelationIDInterceptor takes the correlation ID from the incoming metadata, or
+// generates one, and makes it available to logs, audit entries, spans and the response trailer.
+func UnaryCorrelationIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, correlationID := withCorrelationID(ctx)
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(CorrelationIDHeader, correlationID))
+		return handler(ctx, req)
+	}
+}
+
+// StreamCorrelationIDInterceptor is the streaming counterpart of UnaryCorrelationIDInterceptor.
+func StreamCorrelationIDInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, correlationID := withCorrelationID(ss.Context())
+		ss.SetTrailer(metadata.Pairs(CorrelationIDHeader, correlationID))
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func withCorrelationID(ctx context.Context) (context.Context, string) {
+	correlationID := incomingCorrelationID(ctx)
+	if correlationID == "" {
+		correlationID = uuid.New().String()
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("correlation_id", correlationID))
+	return domain.NewContextWithCorrelationID(ctx, correlationID), correlationID
+}
+
+// incomingCorrelationID returns the caller supplied ID if it is short and printable.
+func incomingCorrelationID(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(CorrelationIDHeader)
+	if len(values) == 0 || len(values[0]) > maxCorrelationIDLength {
+		return ""
+	}
+	for _, r := range values[0] {
+		if r < 0x21 || r > 0x7e {
+			return ""
+		}
+	}
+	return values[0]
+}
+
+// CorrelationIDFromContext returns the correlation ID of the current request.
+func CorrelationIDFromContext(ctx context.Context) string {
+	return domain.CorrelationIDFromContext(ctx)
+}
@@ -0,0 +1,77 @@
+package interceptors
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryDeadlineInterceptor bounds every unary RPC by a server-side timeout. The timeout
+// is looked up by bare method name (case-insensitive) and falls back to defaultTimeout.
+// A tighter deadline set by the client is kept, and the resulting deadline flows down
+// through the context into repository and KMS calls.
+func UnaryDeadlineInterceptor(defaultTimeout time.Duration, perMethod map[string]time.Duration) grpc.UnaryServerInterceptor {
+	timeouts := lowerKeys(perMethod)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+
+		timeout, ok := timeouts[strings.ToLower(path.Base(info.FullMethod))]
+		if !ok {
+			timeout = defaultTimeout
+		}
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// StreamDeadlineInterceptor bounds streaming RPCs like UnaryDeadlineInterceptor bounds
+// unary ones. The methods named in longLived, such as watches that are meant to stay
+// open until the client leaves, are exempt from defaultTimeout and only bounded by a
+// timeout set for them in perMethod.
+func StreamDeadlineInterceptor(defaultTimeout time.Duration, perMethod map[string]time.Duration, longLived ...string) grpc.StreamServerInterceptor {
+	timeouts := lowerKeys(perMethod)
+	exempt := make(map[string]bool, len(longLived))
+	for _, method := range longLived {
+		exempt[strings.ToLower(method)] = true
+	}
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+
+		method := strings.ToLower(path.Base(info.FullMethod))
+		timeout, ok := timeouts[method]
+		if !ok && !exempt[method] {
+			timeout = defaultTimeout
+		}
+		if timeout <= 0 {
+			return handler(srv, ss)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func lowerKeys(perMethod map[string]time.Duration) map[string]time.Duration {
+	timeouts := make(map[string]time.Duration, len(perMethod))
+	for method, timeout := range perMethod {
+		timeouts[strings.ToLower(method)] = timeout
+	}
+	return timeouts
+}
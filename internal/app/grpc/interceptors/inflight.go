@@ -0,0 +1,92 @@
+package interceptors
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"google.golang.org/grpc"
+)
+
+// InFlightTracker counts the RPCs currently being served and, once draining,
+// turns away new ones so that shutdown only has to wait for existing work.
+type InFlightTracker struct {
+	wg       sync.WaitGroup
+	mu       sync.RWMutex
+	draining bool
+	count    atomic.Int64
+
+	errorClassifier *app_errors.ErrorClassifier
+}
+
+// NewInFlightTracker creates a new InFlightTracker. The RPCs it turns away fail with
+// SHUTTING_DOWN, sanitized by errorClassifier.
+func NewInFlightTracker(errorClassifier *app_errors.ErrorClassifier) *InFlightTracker {
+	return &InFlightTracker{errorClassifier: errorClassifier}
+}
+
+// InFlight returns the number of RPCs currently being served.
+func (t *InFlightTracker) InFlight() int64 {
+	return t.count.Load()
+}
+
+// Drain stops admitting new RPCs.
+func (t *InFlightTracker) Drain() {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+}
+
+// Wait blocks until every admitted RPC has finished or ctx is done.
+func (t *InFlightTracker) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *InFlightTracker) acquire() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.draining {
+		return false
+	}
+	t.wg.Add(1)
+	t.count.Add(1)
+	return true
+}
+
+func (t *InFlightTracker) release() {
+	t.count.Add(-1)
+	t.wg.Done()
+}
+
+// UnaryInterceptor tracks unary RPCs.
+func (t *InFlightTracker) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !t.acquire() {
+			return nil, t.errorClassifier.Status(app_errors.ErrShuttingDown, info.FullMethod)
+		}
+		defer t.release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor tracks streaming RPCs.
+func (t *InFlightTracker) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !t.acquire() {
+			return t.errorClassifier.Status(app_errors.ErrShuttingDown, info.FullMethod)
+		}
+		defer t.release()
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,111 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+)
+
+const healthServicePrefix = "/grpc.health.v1.Health/"
+
+var shedCounter, _ = meter.Int64Counter(
+	"polykey.grpc.shed",
+	metric.WithDescription("Number of RPCs rejected by the load shedder."),
+)
+
+// LoadSignal is a single pressure measurement. Overloaded reports whether it is
+// currently above its threshold.
+type LoadSignal struct {
+	Name       string
+	Overloaded func() bool
+}
+
+// LoadShedder rejects low-priority RPCs with ResourceExhausted while any of its
+// signals is overloaded. Signals are sampled at most once per interval so that the
+// check stays cheap on the request path.
+type LoadShedder struct {
+	signals         []LoadSignal
+	priority        map[string]struct{}
+	interval        time.Duration
+	errorClassifier *app_errors.ErrorClassifier
+
+	mu        sync.Mutex
+	sampledAt time.Time
+	tripped   string
+}
+
+// NewLoadShedder creates a LoadShedder. priorityMethods are bare method names, such as
+// "GetKey", that are never shed. Shed calls fail with OVERLOADED, asked to retry once the
+// signals are sampled again.
+func NewLoadShedder(interval time.Duration, priorityMethods []string, errorClassifier *app_errors.ErrorClassifier, signals ...LoadSignal) *LoadShedder {
+	priority := make(map[string]struct{}, len(priorityMethods))
+	for _, m := range priorityMethods {
+		priority[m] = struct{}{}
+	}
+	return &LoadShedder{signals: signals, priority: priority, interval: interval, errorClassifier: errorClassifier}
+}
+
+// UnaryInterceptor returns a unary interceptor that sheds low-priority requests.
+func (l *LoadShedder) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := l.admit(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns a stream interceptor that sheds low-priority streams.
+func (l *LoadShedder) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.admit(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (l *LoadShedder) admit(ctx context.Context, fullMethod string) error {
+	if strings.HasPrefix(fullMethod, healthServicePrefix) {
+		return nil
+	}
+	if _, ok := l.priority[path.Base(fullMethod)]; ok {
+		return nil
+	}
+	signal := l.overloaded()
+	if signal == "" {
+		return nil
+	}
+	shedCounter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("rpc.method", fullMethod),
+		attribute.String("signal", signal),
+	))
+	err := fmt.Errorf("%w: %s", app_errors.ErrOverloaded, signal)
+	return l.errorClassifier.Status(app_errors.WithRetryAfter(err, l.interval), fullMethod)
+}
+
+// overloaded returns the name of the first tripped signal, or "" if none is.
+func (l *LoadShedder) overloaded() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.sampledAt) < l.interval {
+		return l.tripped
+	}
+	l.sampledAt = time.Now()
+	l.tripped = ""
+	for _, s := range l.signals {
+		if s.Overloaded() {
+			l.tripped = s.Name
+			break
+		}
+	}
+	return l.tripped
+}
@@ -5,20 +5,16 @@ import (
 	"log/slog"
 	"time"
 
-	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-type correlationIDKey struct{}
-
 func UnaryLoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		start := time.Now()
 
-		correlationID := uuid.New().String()
-		ctx = context.WithValue(ctx, correlationIDKey{}, correlationID)
+		correlationID := CorrelationIDFromContext(ctx)
 
 		resp, err := handler(ctx, req)
 		duration := time.Since(start)
@@ -32,27 +28,19 @@ func UnaryLoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
 			}
 		}
 
-		// Use With() for structured attributes
-		logEntry := logger.With(
+		attrs := []slog.Attr{
 			slog.String("correlation_id", correlationID),
 			slog.String("method", info.FullMethod),
 			slog.Duration("duration", duration),
 			slog.String("status_code", statusCode.String()),
-		)
+		}
 
 		if err != nil {
-			logEntry.WarnContext(ctx, "gRPC request failed", slog.String("error", err.Error()))
+			logger.LogAttrs(ctx, slog.LevelWarn, "gRPC request failed", append(attrs, slog.String("error", err.Error()))...)
 		} else {
-			logEntry.InfoContext(ctx, "gRPC request completed")
+			logger.LogAttrs(ctx, slog.LevelInfo, "gRPC request completed", attrs...)
 		}
 
 		return resp, err
 	}
 }
-
-func CorrelationIDFromContext(ctx context.Context) string {
-	if correlationID, ok := ctx.Value(correlationIDKey{}).(string); ok {
-		return correlationID
-	}
-	return ""
-}
\ No newline at end of file
@@ -0,0 +1,41 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/infra/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serverFailureCodes are the status codes that count towards the error rate. Requests
+// rejected for what the caller sent, such as an unknown key or a missing permission,
+// are not failures of the service.
+var serverFailureCodes = map[codes.Code]bool{
+	codes.Unknown:          true,
+	codes.DeadlineExceeded: true,
+	codes.Internal:         true,
+	codes.Unavailable:      true,
+	codes.DataLoss:         true,
+}
+
+// healthProbePrefix marks the grpc_health_v1 methods that load balancers and
+// orchestrators poll; they are left out so that probes do not make up the request rate.
+const healthProbePrefix = "/grpc.health.v1.Health/"
+
+// UnaryMetricsInterceptor records every unary RPC in the registry. Streams are not
+// recorded: a WatchKeys subscription lasting hours would swamp the average latency.
+func UnaryMetricsInterceptor(registry *metrics.Registry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if strings.HasPrefix(info.FullMethod, healthProbePrefix) {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		registry.Observe(time.Since(start), serverFailureCodes[status.Code(err)])
+		return resp, err
+	}
+}
@@ -0,0 +1,56 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+)
+
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/app/grpc/interceptors")
+
+var panicCounter, _ = meter.Int64Counter(
+	"polykey.grpc.panics",
+	metric.WithDescription("Number of panics recovered while serving RPCs."),
+)
+
+// UnaryRecoveryInterceptor turns a panic in a handler into a classified Internal error
+// instead of letting it take down the process.
+func UnaryRecoveryInterceptor(logger *slog.Logger, classifier *app_errors.ErrorClassifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverPanic(ctx, logger, classifier, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecoveryInterceptor is the streaming counterpart of UnaryRecoveryInterceptor.
+func StreamRecoveryInterceptor(logger *slog.Logger, classifier *app_errors.ErrorClassifier) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverPanic(ss.Context(), logger, classifier, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recoverPanic(ctx context.Context, logger *slog.Logger, classifier *app_errors.ErrorClassifier, method string, r any) error {
+	panicCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("rpc.method", method)))
+	logger.ErrorContext(ctx, "recovered from panic",
+		slog.String("method", method),
+		slog.Any("panic", r),
+		slog.String("stack", string(debug.Stack())),
+	)
+	return classifier.LogAndSanitize(ctx, classifier.Classify(fmt.Errorf("panic: %v", r), method))
+}
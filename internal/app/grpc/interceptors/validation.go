@@ -32,6 +32,12 @@ func UnaryValidationInterceptor(errorClassifier *app_errors.ErrorClassifier) grp
 		reflect.TypeOf(&pk.UpdateKeyMetadataRequest{}): func(ctx context.Context, r any) error {
 			return requestValidator.ValidateUpdateKeyMetadataRequest(ctx, r.(*pk.UpdateKeyMetadataRequest))
 		},
+		reflect.TypeOf(&pk.BatchCreateKeysRequest{}): func(ctx context.Context, r any) error {
+			return requestValidator.ValidateBatchCreateKeysRequest(r.(*pk.BatchCreateKeysRequest))
+		},
+		reflect.TypeOf(&pk.BatchUpdateKeyMetadataRequest{}): func(ctx context.Context, r any) error {
+			return requestValidator.ValidateBatchUpdateKeyMetadataRequest(r.(*pk.BatchUpdateKeyMetadataRequest))
+		},
 		reflect.TypeOf(&pk.ListKeysRequest{}): func(ctx context.Context, r any) error {
 			return queryValidator.ValidateListKeysRequest(r.(*pk.ListKeysRequest))
 		},
@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"runtime"
+
+	"github.com/spounge-ai/polykey/internal/app/grpc/interceptors"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+)
+
+// newLoadShedder builds the load shedder from the configured thresholds, skipping
+// any signal whose threshold is zero or whose source is not available.
+func newLoadShedder(cfg config.LoadSheddingConfig, deps PolykeyDeps) *interceptors.LoadShedder {
+	var signals []interceptors.LoadSignal
+
+	if limit := cfg.MaxRotationQueueUtilization; limit > 0 && deps.KeyService != nil {
+		signals = append(signals, interceptors.LoadSignal{
+			Name: "rotation_queue",
+			Overloaded: func() bool {
+				pending, capacity := deps.KeyService.RotationBacklog()
+				return capacity > 0 && float64(pending)/float64(capacity) >= limit
+			},
+		})
+	}
+	if limit := cfg.MaxPoolAcquireWait; limit > 0 && deps.PoolAcquireWait != nil {
+		signals = append(signals, interceptors.LoadSignal{
+			Name:       "db_pool_wait",
+			Overloaded: func() bool { return deps.PoolAcquireWait() >= limit },
+		})
+	}
+	if limit := cfg.MaxGoroutines; limit > 0 {
+		signals = append(signals, interceptors.LoadSignal{
+			Name:       "goroutines",
+			Overloaded: func() bool { return runtime.NumGoroutine() >= limit },
+		})
+	}
+
+	return interceptors.NewLoadShedder(cfg.SampleInterval, cfg.PriorityMethods, deps.ErrorClassifier, signals...)
+}
@@ -0,0 +1,243 @@
+package grpc
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/spounge-ai/polykey/internal/app/grpc/interceptors"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// openAPIServices are the services OpenAPIDocument describes. Admin services are only
+// served on the admin listener, which requires a client certificate.
+var openAPIServices = []struct {
+	desc  *grpc.ServiceDesc
+	admin bool
+}{
+	{desc: &pk.PolykeyService_ServiceDesc},
+	{desc: &PolykeyStreamServiceDesc},
+	{desc: &PolykeyAdminServiceDesc, admin: true},
+}
+
+// openAPIDescription introduces the document to readers of the generated clients.
+const openAPIDescription = "Polykey serves gRPC only. Each unary method is described as a POST of its " +
+	"request, in the protobuf JSON mapping, to the method's gRPC path, as JSON transcoding " +
+	"proxies call it. Streaming methods have no such mapping and are left out. A failed call " +
+	"returns a google.rpc.Status whose details start with a google.rpc.ErrorInfo."
+
+// wellKnownSchemas are the schemas of the well-known types, which the protobuf JSON
+// mapping encodes specially rather than as objects of their fields.
+var wellKnownSchemas = map[protoreflect.FullName]map[string]any{
+	"google.protobuf.Empty":       {"type": "object"},
+	"google.protobuf.Struct":      {"type": "object", "additionalProperties": true},
+	"google.protobuf.Value":       {},
+	"google.protobuf.ListValue":   {"type": "array", "items": map[string]any{}},
+	"google.protobuf.Timestamp":   {"type": "string", "format": "date-time"},
+	"google.protobuf.Duration":    {"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`},
+	"google.protobuf.FieldMask":   {"type": "string"},
+	"google.protobuf.BoolValue":   {"type": "boolean"},
+	"google.protobuf.StringValue": {"type": "string"},
+	"google.protobuf.BytesValue":  {"type": "string", "format": "byte"},
+	"google.protobuf.Int32Value":  {"type": "integer", "format": "int32"},
+	"google.protobuf.UInt32Value": {"type": "integer", "format": "uint32"},
+	"google.protobuf.Int64Value":  {"type": "string", "format": "int64"},
+	"google.protobuf.UInt64Value": {"type": "string", "format": "uint64"},
+	"google.protobuf.FloatValue":  {"type": "number", "format": "float"},
+	"google.protobuf.DoubleValue": {"type": "number", "format": "double"},
+	"google.protobuf.Any": {
+		"type":                 "object",
+		"properties":           map[string]any{"@type": map[string]any{"type": "string"}},
+		"required":             []any{"@type"},
+		"additionalProperties": true,
+	},
+}
+
+// OpenAPIDocument returns an OpenAPI 3.1 document describing the unary methods of the
+// Polykey services, generated from their service descriptors, with the bearer token and
+// client certificate they authenticate with and the status failed calls return. It is
+// meant for generating clients in languages without gRPC tooling.
+var OpenAPIDocument = sync.OnceValues(func() (*structpb.Struct, error) {
+	return structpb.NewStruct(buildOpenAPIDocument())
+})
+
+func buildOpenAPIDocument() map[string]any {
+	schemas := openAPISchemas{}
+	statusRef := schemas.ref((&rpcstatus.Status{}).ProtoReflect().Descriptor())
+	for _, detail := range []proto.Message{&errdetails.ErrorInfo{}, &errdetails.RetryInfo{}, &errdetails.PreconditionFailure{}, &errdetails.BadRequest{}} {
+		schemas.ref(detail.ProtoReflect().Descriptor())
+	}
+	schemas.documentReasons()
+
+	paths := map[string]any{}
+	var tags []any
+	for _, service := range openAPIServices {
+		listener := "Served on the data-plane listener."
+		if service.admin {
+			listener = "Served on the admin listener, which requires a client certificate."
+		}
+		tags = append(tags, map[string]any{"name": service.desc.ServiceName, "description": listener})
+
+		handler := reflect.TypeOf(service.desc.HandlerType).Elem()
+		for _, method := range service.desc.Methods {
+			call, ok := handler.MethodByName(method.MethodName)
+			if !ok || call.Type.NumIn() != 2 || call.Type.NumOut() != 2 {
+				continue
+			}
+			req, reqOK := reflect.New(call.Type.In(1).Elem()).Interface().(proto.Message)
+			resp, respOK := reflect.New(call.Type.Out(0).Elem()).Interface().(proto.Message)
+			if !reqOK || !respOK {
+				continue
+			}
+
+			fullMethod := "/" + service.desc.ServiceName + "/" + method.MethodName
+			operation := map[string]any{
+				"operationId": service.desc.ServiceName + "." + method.MethodName,
+				"tags":        []any{service.desc.ServiceName},
+				"requestBody": map[string]any{
+					"required": true,
+					"content":  jsonContent(schemas.ref(req.ProtoReflect().Descriptor())),
+				},
+				"responses": map[string]any{
+					"200":     map[string]any{"description": "The method's response.", "content": jsonContent(schemas.ref(resp.ProtoReflect().Descriptor()))},
+					"default": map[string]any{"description": "The status of the failed call.", "content": jsonContent(statusRef)},
+				},
+			}
+			switch {
+			case interceptors.IsUnprotected(fullMethod):
+				operation["security"] = []any{}
+			case service.admin:
+				operation["security"] = []any{map[string]any{"bearerAuth": []any{}, "mutualTLS": []any{}}}
+			}
+			paths[fullMethod] = map[string]any{"post": operation}
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Polykey API",
+			"version":     "v2",
+			"description": openAPIDescription,
+		},
+		"tags":     tags,
+		"paths":    paths,
+		"security": []any{map[string]any{"bearerAuth": []any{}}},
+		"components": map[string]any{
+			"schemas": map[string]any(schemas),
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+					"description":  "An access token issued by Authenticate, sent in the authorization metadata.",
+				},
+				"mutualTLS": map[string]any{
+					"type":        "mutualTLS",
+					"description": "A client certificate signed by the CA Polykey trusts.",
+				},
+			},
+		},
+	}
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// openAPISchemas collects the component schemas of the messages and enums a document
+// refers to, by their full protobuf name.
+type openAPISchemas map[string]any
+
+// ref returns the schema of md, adding it and the messages it refers to to the
+// components unless it is a well-known type.
+func (s openAPISchemas) ref(md protoreflect.MessageDescriptor) map[string]any {
+	if schema, ok := wellKnownSchemas[md.FullName()]; ok {
+		return schema
+	}
+	name := string(md.FullName())
+	if _, ok := s[name]; !ok {
+		// Claimed before the fields are walked, so recursive messages end.
+		s[name] = map[string]any{}
+		properties := map[string]any{}
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			field := fields.Get(i)
+			properties[field.JSONName()] = s.field(field)
+		}
+		s[name] = map[string]any{"type": "object", "properties": properties}
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (s openAPISchemas) field(fd protoreflect.FieldDescriptor) map[string]any {
+	switch {
+	case fd.IsMap():
+		return map[string]any{"type": "object", "additionalProperties": s.value(fd.MapValue())}
+	case fd.IsList():
+		return map[string]any{"type": "array", "items": s.value(fd)}
+	}
+	return s.value(fd)
+}
+
+// value returns the schema of one value of fd, following the protobuf JSON mapping,
+// which encodes 64-bit integers as strings and enums by name.
+func (s openAPISchemas) value(fd protoreflect.FieldDescriptor) map[string]any {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		return s.enum(fd.Enum())
+	default:
+		return s.ref(fd.Message())
+	}
+}
+
+func (s openAPISchemas) enum(ed protoreflect.EnumDescriptor) map[string]any {
+	name := string(ed.FullName())
+	if _, ok := s[name]; !ok {
+		var names []any
+		values := ed.Values()
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		s[name] = map[string]any{"type": "string", "enum": names}
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// documentReasons lists the reasons of the error taxonomy as the values the reason of
+// an ErrorInfo can take.
+func (s openAPISchemas) documentReasons() {
+	var reasons []any
+	for _, entry := range app_errors.Taxonomy() {
+		if !slices.Contains(reasons, any(entry.Reason)) {
+			reasons = append(reasons, entry.Reason)
+		}
+	}
+	errorInfo := s[string((&errdetails.ErrorInfo{}).ProtoReflect().Descriptor().FullName())].(map[string]any)
+	errorInfo["properties"].(map[string]any)["reason"] = map[string]any{"type": "string", "enum": reasons}
+}
@@ -2,19 +2,35 @@ package grpc
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
 	cts "github.com/spounge-ai/polykey/internal/constants"
 	"github.com/spounge-ai/polykey/internal/domain"
 	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/auth"
 	"github.com/spounge-ai/polykey/internal/infra/config"
+	infra_health "github.com/spounge-ai/polykey/internal/infra/health"
+	"github.com/spounge-ai/polykey/internal/infra/logging"
+	"github.com/spounge-ai/polykey/internal/infra/ratelimit"
+	"github.com/spounge-ai/polykey/internal/infra/metrics"
 	"github.com/spounge-ai/polykey/internal/service"
+	"github.com/spounge-ai/polykey/pkg/authorization"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
-	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -23,19 +39,71 @@ type PolykeyDeps struct {
 	Config          *config.Config
 	KeyService      service.KeyService
 	AuthService     service.AuthService
+	AuditService    service.AuditService
 	Authorizer      domain.Authorizer
 	Audit           domain.AuditLogger
 	Logger          *slog.Logger
 	ErrorClassifier *app_errors.ErrorClassifier
+	KeyEvents       domain.KeyEventSubscriber
+	Health          *infra_health.Checker
+	StartedAt       time.Time
+	// PoolAcquireWait reports the average database connection acquire wait since the
+	// previous call. It feeds the load shedder and may be nil.
+	PoolAcquireWait func() time.Duration
+	// ActiveKeyCount counts the active keys for the HealthCheck metrics and may be nil.
+	ActiveKeyCount func(context.Context) (int64, error)
+	// Metrics is created by New when not set.
+	Metrics *metrics.Registry
+	// CircuitBreakers control the circuit breakers of the key repository, the audit
+	// repository and the KMS providers, and are empty when they are disabled.
+	CircuitBreakers []domain.CircuitBreakerControl
+	// LogLevels holds the runtime log levels SetLogLevel changes and may be nil.
+	LogLevels *logging.Levels
+	// RateLimiter limits authenticated requests per client. New creates an in-memory one
+	// from the config when not set.
+	RateLimiter ratelimit.Limiter
+	// CurrentConfig returns the config as last reloaded, for GetEffectiveConfig. When
+	// nil, Config is used.
+	CurrentConfig func() *config.Config
+	// TokenManager validates the tokens of authenticated requests. New creates one from
+	// the config when not set; set it to the one that issues tokens, so that rotating
+	// its key applies to both.
+	TokenManager *auth.TokenManager
+	// SecretRotator rotates the bootstrap secrets for RotateBootstrapSecrets and may be
+	// nil.
+	SecretRotator domain.SecretRotator
+	// ClientManager and RoleManager change the API clients and roles for the admin
+	// service. Either may be nil when the store behind it is read-only.
+	ClientManager domain.ClientManager
+	RoleManager   domain.RoleManager
+	// Sessions lists and revokes the tokens issued to clients for the admin service, and
+	// is nil when the token store does not track them.
+	Sessions domain.TokenSessionStore
+	// Caches are the caches FlushCaches can flush, by name.
+	Caches map[string]domain.CacheFlusher
+	// ReloadConfig reloads the config for the ReloadConfig admin RPC and is nil when
+	// reloading is disabled.
+	ReloadConfig func(context.Context) ([]config.Change, error)
+	// KMSRewrap moves wrapped DEKs between KMS providers for the RewrapKeys admin RPC and
+	// is nil when keys are not stored in PostgreSQL.
+	KMSRewrap service.KMSRewrapService
 }
 
 type PolykeyService struct {
 	pk.UnimplementedPolykeyServiceServer
-	deps PolykeyDeps
+	deps      PolykeyDeps
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-func NewPolykeyService(deps PolykeyDeps) pk.PolykeyServiceServer {
-	return &PolykeyService{deps: deps}
+func NewPolykeyService(deps PolykeyDeps) *PolykeyService {
+	return &PolykeyService{deps: deps, done: make(chan struct{})}
+}
+
+// closeStreams ends long-lived streams such as WatchKeys so that shutdown is not
+// held up by subscribers that would otherwise never finish.
+func (s *PolykeyService) closeStreams() {
+	s.closeOnce.Do(func() { close(s.done) })
 }
 
 func execWithAuth[T any](
@@ -54,7 +122,7 @@ func execWithAuth[T any](
 	}
 
 	if ok, reason := s.deps.Authorizer.Authorize(ctx, reqContext, attrs, authOp, keyID); !ok {
-		return zero, s.sanitizeError(ctx, methodName, fmt.Errorf("%w: %s", app_errors.ErrAuthorization, reason))
+		return zero, s.sanitizeError(ctx, methodName, authorizationError(reason))
 	}
 
 	resp, err := fn(ctx, keyID)
@@ -75,7 +143,7 @@ func execWithoutKey[T any](
 	var zero T
 
 	if ok, reason := s.deps.Authorizer.Authorize(ctx, reqContext, attrs, authOp, domain.KeyID{}); !ok {
-		return zero, s.sanitizeError(ctx, methodName, fmt.Errorf("%w: %s", app_errors.ErrAuthorization, reason))
+		return zero, s.sanitizeError(ctx, methodName, authorizationError(reason))
 	}
 
 	resp, err := fn(ctx)
@@ -86,6 +154,39 @@ func execWithoutKey[T any](
 }
 
 
+// withListScope limits the keys listed with ctx to those the caller could read, when the
+// authorizer scopes listings.
+func (s *PolykeyService) withListScope(ctx context.Context) context.Context {
+	if scoper, ok := s.deps.Authorizer.(domain.ListScoper); ok {
+		if authorizedContext, scoped := scoper.ListScope(ctx); scoped {
+			return domain.NewContextWithListScope(ctx, authorizedContext)
+		}
+	}
+	return ctx
+}
+
+// withKeyCheck authorizes every key a batch read made with ctx touches against its own
+// ID, so that the checks of the key itself, such as step-up and authorized contexts,
+// apply as they do to GetKey. A denied key fails its item.
+func (s *PolykeyService) withKeyCheck(ctx context.Context, authOp string, reqContext *pk.RequesterContext, attrs *pk.AccessAttributes) context.Context {
+	return domain.NewContextWithKeyCheck(ctx, func(ctx context.Context, keyID domain.KeyID) error {
+		if ok, reason := s.deps.Authorizer.Authorize(ctx, reqContext, attrs, authOp, keyID); !ok {
+			return authorizationError(reason)
+		}
+		return nil
+	})
+}
+
+// authorizationError maps an authorizer reason to a typed error so that clients
+// can distinguish a missing step-up assertion from a plain denial, and tell denials
+// apart by their denial_reason.
+func authorizationError(reason string) error {
+	if reason == domain.ReasonStepUpRequired {
+		return fmt.Errorf("%w: %s", app_errors.ErrStepUpRequired, reason)
+	}
+	return &app_errors.AuthorizationError{Reason: reason}
+}
+
 func (s *PolykeyService) sanitizeError(ctx context.Context, method string, err error) error {
 	return s.deps.ErrorClassifier.LogAndSanitize(ctx, s.deps.ErrorClassifier.Classify(err, method))
 }
@@ -95,12 +196,26 @@ var emptyResponse = &emptypb.Empty{}
 
 func (s *PolykeyService) Authenticate(ctx context.Context, req *pk.AuthenticateRequest) (*pk.AuthenticateResponse, error) {
 	if req.GetClientId() == "" || req.GetApiKey() == "" {
-		return nil, status.Error(codes.InvalidArgument, "client_id and api_key are required")
+		return nil, s.sanitizeError(ctx, "Authenticate", fmt.Errorf("%w: client_id and api_key are required", app_errors.ErrInvalidInput))
 	}
 
-	result, err := s.deps.AuthService.Authenticate(ctx, req.GetClientId(), req.GetApiKey())
+	result, err := s.deps.AuthService.Authenticate(ctx, service.AuthenticationRequest{
+		ClientID:   req.GetClientId(),
+		APIKey:     req.GetApiKey(),
+		Challenge:  authenticateChallenge(ctx),
+		OTP:        authenticateOTP(ctx),
+		SourceIP:   peerIP(ctx),
+		ClientCert: peerCertificate(ctx),
+	})
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, "authentication failed: %v", err)
+		if s.deps.Audit != nil {
+			s.deps.Audit.AuditLog(ctx, req.GetClientId(), "Authenticate", "", "", false, err)
+		}
+		if !errors.Is(err, app_errors.ErrRateLimit) {
+			// The cause stays in the logs: clients are not told whether the client exists.
+			err = fmt.Errorf("%w: %v", app_errors.ErrAuthentication, err)
+		}
+		return nil, s.sanitizeError(ctx, "Authenticate", err)
 	}
 
 	return &pk.AuthenticateResponse{
@@ -108,9 +223,64 @@ func (s *PolykeyService) Authenticate(ctx context.Context, req *pk.AuthenticateR
 		TokenType:   result.TokenType,
 		ExpiresIn:   result.ExpiresIn,
 		IssuedAt:    timestamppb.Now(),
+		ClientTier:  authorization.ToProtoTier(result.ClientTier),
 	}, nil
 }
 
+// authenticateChallenge reads the anti-replay challenge of an Authenticate request from
+// its metadata, if it carries one.
+func authenticateChallenge(ctx context.Context) *domain.AuthenticateChallenge {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get(domain.AuthenticateNonceHeader)) == 0 {
+		return nil
+	}
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	return &domain.AuthenticateChallenge{
+		Nonce:     first(domain.AuthenticateNonceHeader),
+		Timestamp: first(domain.AuthenticateTimestampHeader),
+		Signature: first(domain.AuthenticateSignatureHeader),
+	}
+}
+
+// authenticateOTP returns the one-time password an Authenticate request carries, or "".
+func authenticateOTP(ctx context.Context) string {
+	if values := metadata.ValueFromIncomingContext(ctx, domain.AuthenticateOTPHeader); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// peerIP returns the IP of the caller, or "" when it is unknown.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// peerCertificate returns the leaf client certificate of the connection, or nil without
+// mTLS.
+func peerCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil
+	}
+	return tlsInfo.State.PeerCertificates[0]
+}
+
 func (s *PolykeyService) GetKey(ctx context.Context, req *pk.GetKeyRequest) (*pk.GetKeyResponse, error) {
 	return execWithAuth(s, ctx, cts.MethodGetKey, cts.MethodScopes[cts.MethodGetKey], req.GetKeyId(), req.GetRequesterContext(), req.GetAttributes(),
 		func(ctx context.Context, keyID domain.KeyID) (*pk.GetKeyResponse, error) {
@@ -135,6 +305,7 @@ func (s *PolykeyService) BatchCreateKeys(ctx context.Context, req *pk.BatchCreat
 func (s *PolykeyService) BatchGetKeys(ctx context.Context, req *pk.BatchGetKeysRequest) (*pk.BatchGetKeysResponse, error) {
 	return execWithoutKey(s, ctx, cts.MethodGetKey, cts.MethodScopes[cts.MethodGetKey], req.GetRequesterContext(), req.GetAttributes(),
 		func(ctx context.Context) (*pk.BatchGetKeysResponse, error) {
+			ctx = s.withKeyCheck(ctx, cts.MethodScopes[cts.MethodGetKey], req.GetRequesterContext(), req.GetAttributes())
 			return s.deps.KeyService.BatchGetKeys(ctx, req)
 		})
 }
@@ -170,7 +341,7 @@ func (s *PolykeyService) BatchUpdateKeyMetadata(ctx context.Context, req *pk.Bat
 func (s *PolykeyService) ListKeys(ctx context.Context, req *pk.ListKeysRequest) (*pk.ListKeysResponse, error) {
 	return execWithoutKey(s, ctx, cts.MethodListKeys, cts.MethodScopes[cts.MethodListKeys], req.GetRequesterContext(), req.GetAttributes(),
 		func(ctx context.Context) (*pk.ListKeysResponse, error) {
-			return s.deps.KeyService.ListKeys(ctx, req)
+			return s.deps.KeyService.ListKeys(s.withListScope(ctx), req)
 		})
 }
 
@@ -191,10 +362,34 @@ func (s *PolykeyService) RevokeKey(ctx context.Context, req *pk.RevokeKeyRequest
 func (s *PolykeyService) UpdateKeyMetadata(ctx context.Context, req *pk.UpdateKeyMetadataRequest) (*emptypb.Empty, error) {
 	return execWithAuth(s, ctx, cts.MethodUpdateKeyMetadata, cts.MethodScopes[cts.MethodUpdateKeyMetadata], req.GetKeyId(), req.GetRequesterContext(), nil,
 		func(ctx context.Context, keyID domain.KeyID) (*emptypb.Empty, error) {
+			if mask := updateMask(ctx); mask != nil {
+				ctx = domain.NewContextWithUpdateMask(ctx, mask)
+			}
 			return emptyResponse, s.deps.KeyService.UpdateKeyMetadata(ctx, req)
 		})
 }
 
+// updateMask reads the field mask of an update from its metadata, or returns nil when
+// it carries none.
+func updateMask(ctx context.Context) *fieldmaskpb.FieldMask {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	var paths []string
+	for _, value := range md.Get(domain.UpdateMaskHeader) {
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return &fieldmaskpb.FieldMask{Paths: paths}
+}
+
 func (s *PolykeyService) GetKeyMetadata(ctx context.Context, req *pk.GetKeyMetadataRequest) (*pk.GetKeyMetadataResponse, error) {
 	return execWithAuth(s, ctx, cts.MethodGetKeyMetadata, cts.MethodScopes[cts.MethodGetKeyMetadata], req.GetKeyId(), req.GetRequesterContext(), req.GetAttributes(),
 		func(ctx context.Context, keyID domain.KeyID) (*pk.GetKeyMetadataResponse, error) {
@@ -203,13 +398,65 @@ func (s *PolykeyService) GetKeyMetadata(ctx context.Context, req *pk.GetKeyMetad
 }
 
 func (s *PolykeyService) HealthCheck(ctx context.Context, req *emptypb.Empty) (*pk.HealthCheckResponse, error) {
-	return &pk.HealthCheckResponse{
+	resp := &pk.HealthCheckResponse{
 		Status:         pk.HealthStatus_HEALTH_STATUS_HEALTHY,
 		Timestamp:      timestamppb.Now(),
 		ServiceVersion: s.deps.Config.ServiceVersion,
 		BuildCommit:    s.deps.Config.BuildCommit,
 		Metrics: &pk.ServiceMetrics{
-			UptimeSince: timestamppb.New(time.Now().Add(-24 * time.Hour)), // Mock uptime
+			UptimeSince: timestamppb.New(s.deps.StartedAt),
 		},
-	}, nil
+	}
+	if s.deps.Metrics != nil {
+		resp.Metrics = serviceMetricsToProto(s.deps.Metrics.Snapshot(ctx))
+	}
+	if s.deps.Health == nil {
+		return resp, nil
+	}
+
+	// HealthCheck is unauthenticated, so it serves the report the server refreshes every
+	// health_check_interval rather than probing the dependencies on each call.
+	report, err := s.deps.Health.Wait(ctx)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	resp.Status = healthStatusToProto[report.Status]
+
+	// Per-component results are returned as response headers, since the
+	// HealthCheckResponse message has no field for them. HealthCheck is
+	// unauthenticated, so probe errors are only logged, never returned.
+	md := metadata.MD{}
+	for _, component := range report.Components {
+		value := "ok"
+		if !component.Healthy {
+			value = "failing"
+		}
+		md.Set(healthHeaderPrefix+component.Name, value)
+	}
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		s.deps.Logger.WarnContext(ctx, "failed to set health headers", "error", err)
+	}
+
+	return resp, nil
+}
+
+const healthHeaderPrefix = "x-polykey-health-"
+
+func serviceMetricsToProto(snap metrics.Snapshot) *pk.ServiceMetrics {
+	return &pk.ServiceMetrics{
+		AverageResponseTimeMs: float64(snap.AverageResponseTime) / float64(time.Millisecond),
+		RequestsPerSecond:     int64(math.Round(snap.RequestsPerSecond)),
+		ErrorRatePercent:      snap.ErrorRatePercent,
+		CpuUsagePercent:       snap.CPUUsagePercent,
+		MemoryUsagePercent:    snap.MemoryUsagePercent,
+		ActiveKeysCount:       snap.ActiveKeys,
+		TotalRequestsHandled:  snap.TotalRequests,
+		UptimeSince:           timestamppb.New(snap.UptimeSince),
+	}
+}
+
+var healthStatusToProto = map[infra_health.Status]pk.HealthStatus{
+	infra_health.StatusHealthy:   pk.HealthStatus_HEALTH_STATUS_HEALTHY,
+	infra_health.StatusDegraded:  pk.HealthStatus_HEALTH_STATUS_DEGRADED,
+	infra_health.StatusUnhealthy: pk.HealthStatus_HEALTH_STATUS_UNHEALTHY,
 }
@@ -6,17 +6,22 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/spounge-ai/polykey/internal/app/grpc/interceptors"
-	"github.com/spounge-ai/polykey/internal/domain"
-	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	cts "github.com/spounge-ai/polykey/internal/constants"
 	"github.com/spounge-ai/polykey/internal/infra/auth"
 	"github.com/spounge-ai/polykey/internal/infra/config"
+	infra_health "github.com/spounge-ai/polykey/internal/infra/health"
+	"github.com/spounge-ai/polykey/internal/infra/metrics"
 	"github.com/spounge-ai/polykey/internal/infra/ratelimit"
-	"github.com/spounge-ai/polykey/internal/service"
+	"github.com/spounge-ai/polykey/pkg/clock"
 	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
+	channelz "google.golang.org/grpc/channelz/service"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
@@ -25,98 +30,273 @@ import (
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 )
 
+const (
+	polykeyServiceName         = "polykey.v2.PolykeyService"
+	componentHealthPrefix      = "polykey.component."
+	defaultHealthCheckInterval = 15 * time.Second
+)
+
 type Server struct {
+	name       string
+	services   []string
 	grpcServer *grpc.Server
 	healthSrv  *health.Server
+	checker    *infra_health.Checker
 	cfg        *config.Config
 	lis        net.Listener
 	logger     *slog.Logger
+	service    *PolykeyService
+	inFlight   *interceptors.InFlightTracker
+	stopHealth chan struct{}
+	stopOnce   sync.Once
+}
+
+// New creates the data-plane server, which serves the key API on server.port.
+func New(deps PolykeyDeps, tlsConfig *tls.Config) (*Server, int, error) {
+	return newServer(deps, tlsConfig, false)
 }
 
-func New(
-	cfg *config.Config,
-	keyService service.KeyService,
-	authService service.AuthService,
-	authorizer domain.Authorizer,
-	auditLogger domain.AuditLogger,
-	logger *slog.Logger,
-	errorClassifier *app_errors.ErrorClassifier,
-	tlsConfig *tls.Config,
-) (*Server, int, error) {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.Port))
+// NewAdmin creates the admin server, which serves only the admin service on
+// server.admin.port. tlsConfig should require client certificates, as
+// ReloadableTLS.AdminServerConfig does. The admin server runs no health checks and is
+// never load shed.
+func NewAdmin(deps PolykeyDeps, tlsConfig *tls.Config) (*Server, int, error) {
+	return newServer(deps, tlsConfig, true)
+}
+
+func newServer(deps PolykeyDeps, tlsConfig *tls.Config, admin bool) (*Server, int, error) {
+	cfg := deps.Config
+	logger := deps.Logger
+
+	listenPort := cfg.Server.Port
+	if admin {
+		listenPort = cfg.Server.Admin.Port
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", listenPort))
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to listen: %w", err)
 	}
 
 	port := lis.Addr().(*net.TCPAddr).Port
 
-	var opts []grpc.ServerOption
+	opts := transportOptions(cfg.Server.Transport)
 	if tlsConfig != nil {
 		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
+	if cfg.Telemetry.Tracing.Enabled {
+		// Continues the caller's trace and gives every RPC a server span.
+		opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	}
 
-	tokenStore := auth.NewInMemoryTokenStore()
-	tokenManager, err := auth.NewTokenManager(cfg.BootstrapSecrets.JWTRSAPrivateKey, tokenStore, auditLogger)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create token manager for interceptor: %w", err)
+	tokenManager := deps.TokenManager
+	if tokenManager == nil {
+		tokenStore := auth.NewInMemoryTokenStore(clock.System())
+		tokenManager, err = auth.NewTokenManager(cfg.BootstrapSecrets.JWTRSAPrivateKey, tokenStore, deps.Audit, clock.System())
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create token manager for interceptor: %w", err)
+		}
 	}
 
 	// Create a rate limiter for the authentication interceptor.
-	rateLimiter := ratelimit.NewInMemoryRateLimiter(
-		rate.Limit(cfg.Server.RateLimiter.Rate),
-		cfg.Server.RateLimiter.Burst,
-	)
+	rateLimiter := deps.RateLimiter
+	if rateLimiter == nil {
+		rateLimiter = NewRateLimiter(cfg.Server.RateLimiter)
+	}
 
-	opts = append(opts, grpc.ChainUnaryInterceptor(
-		interceptors.UnaryLoggingInterceptor(logger),
-		interceptors.AuthenticationInterceptor(tokenManager, rateLimiter),
-		interceptors.UnaryValidationInterceptor(errorClassifier),
-	))
+	inFlight := interceptors.NewInFlightTracker(deps.ErrorClassifier)
 
-	grpcServer := grpc.NewServer(opts...)
+	if deps.StartedAt.IsZero() {
+		deps.StartedAt = time.Now()
+	}
+	if deps.Metrics == nil {
+		deps.Metrics = metrics.NewRegistry(deps.StartedAt, deps.ActiveKeyCount)
+	}
 
-	deps := PolykeyDeps{
-		Config:          cfg,
-		KeyService:      keyService,
-		AuthService:     authService,
-		Authorizer:      authorizer,
-		Audit:           auditLogger,
-		Logger:          logger,
-		ErrorClassifier: errorClassifier,
+	unary := []grpc.UnaryServerInterceptor{
+		inFlight.UnaryInterceptor(),
+		interceptors.UnaryMetricsInterceptor(deps.Metrics),
+		interceptors.UnaryCorrelationIDInterceptor(),
+		interceptors.UnaryLoggingInterceptor(logger),
+		interceptors.UnaryRecoveryInterceptor(logger, deps.ErrorClassifier),
+		interceptors.UnaryDeadlineInterceptor(cfg.Server.Deadlines.Default, cfg.Server.Deadlines.Methods),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		inFlight.StreamInterceptor(),
+		interceptors.StreamCorrelationIDInterceptor(),
+		interceptors.StreamRecoveryInterceptor(logger, deps.ErrorClassifier),
+		// WatchKeys and the health Watch stay open until the client leaves.
+		interceptors.StreamDeadlineInterceptor(cfg.Server.Deadlines.Default, cfg.Server.Deadlines.Methods, cts.MethodWatchKeys, "Watch"),
+	}
+	if !admin && cfg.Server.Admin.Enabled {
+		unary = append(unary, refuseAdminMethods())
+	}
+	if !admin && cfg.Server.LoadShedding.Enabled {
+		shedder := newLoadShedder(cfg.Server.LoadShedding, deps)
+		unary = append(unary, shedder.UnaryInterceptor())
+		stream = append(stream, shedder.StreamInterceptor())
+	}
+	unary = append(unary,
+		interceptors.AuthenticationInterceptor(tokenManager, rateLimiter, deps.ErrorClassifier),
+		interceptors.BatchLimitInterceptor(cfg.Batch, deps.ErrorClassifier),
+		interceptors.UnaryValidationInterceptor(deps.ErrorClassifier),
+	)
+	if !admin && cfg.Server.Bulkheads.Enabled {
+		// After authentication, so that rejected callers never hold a slot.
+		unary = append(unary, interceptors.NewBulkheads(cfg.Server.Bulkheads, deps.ErrorClassifier).UnaryInterceptor())
 	}
+	stream = append(stream, interceptors.StreamAuthenticationInterceptor(tokenManager, rateLimiter, deps.ErrorClassifier))
 
-	polykeyService := NewPolykeyService(deps)
-	pk.RegisterPolykeyServiceServer(grpcServer, polykeyService)
+	opts = append(opts, grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+
+	grpcServer := grpc.NewServer(opts...)
 
+	polykeyService := NewPolykeyService(deps)
 	healthSrv := health.NewServer()
 	grpc_health_v1.RegisterHealthServer(grpcServer, healthSrv)
-	reflection.Register(grpcServer)
+
+	name, services, checker := "gRPC server", []string{polykeyServiceName, PolykeyStreamServiceName}, deps.Health
+	if admin {
+		name, services, checker = "admin gRPC server", []string{PolykeyAdminServiceName}, nil
+		grpcServer.RegisterService(&PolykeyAdminServiceDesc, polykeyService)
+	} else {
+		pk.RegisterPolykeyServiceServer(grpcServer, polykeyService)
+		grpcServer.RegisterService(&PolykeyStreamServiceDesc, polykeyService)
+		registerDebugServices(grpcServer, cfg.Server, logger)
+	}
 
 	return &Server{
+		name:       name,
+		services:   services,
 		grpcServer: grpcServer,
 		healthSrv:  healthSrv,
+		checker:    checker,
 		cfg:        cfg,
 		lis:        lis,
 		logger:     logger,
+		service:    polykeyService,
+		inFlight:   inFlight,
+		stopHealth: make(chan struct{}),
 	}, port, nil
 }
 
+// NewRateLimiter creates the per-client rate limiter of the authentication interceptor.
+func NewRateLimiter(cfg config.RateLimiterConfig) *ratelimit.InMemoryRateLimiter {
+	limiter := ratelimit.NewInMemoryRateLimiter(rate.Limit(cfg.Rate), cfg.Burst)
+	limiter.Configure(cfg.Enabled, rate.Limit(cfg.Rate), cfg.Burst)
+	return limiter
+}
+
+// registerDebugServices exposes reflection and channelz when configured, but never in
+// production mode where they would leak the service surface and connection details.
+func registerDebugServices(grpcServer *grpc.Server, cfg config.ServerConfig, logger *slog.Logger) {
+	if cfg.Mode == "production" {
+		if cfg.Debug.Reflection || cfg.Debug.Channelz {
+			logger.Warn("ignoring gRPC debug services in production mode", "reflection", cfg.Debug.Reflection, "channelz", cfg.Debug.Channelz)
+		}
+		return
+	}
+	if cfg.Debug.Reflection {
+		reflection.Register(grpcServer)
+	}
+	if cfg.Debug.Channelz {
+		channelz.RegisterChannelzServiceToServer(grpcServer)
+	}
+}
+
 func (s *Server) Start(ctx context.Context) error {
-	s.logger.Info("gRPC server listening", "address", s.lis.Addr().String())
-	s.healthSrv.SetServingStatus("polykey.v2.PolykeyService", grpc_health_v1.HealthCheckResponse_SERVING)
+	s.logger.Info(s.name+" listening", "address", s.lis.Addr().String())
+	for _, service := range s.services {
+		s.healthSrv.SetServingStatus(service, grpc_health_v1.HealthCheckResponse_SERVING)
+	}
+	if s.checker != nil {
+		go s.runHealthChecks(ctx)
+	}
 	return s.grpcServer.Serve(s.lis)
 }
 
+// Stop drains the server: new RPCs are refused and health reports NOT_SERVING,
+// open watch streams are closed, and in-flight RPCs are given until ctx is done
+// to finish before the remaining connections are cut.
 func (s *Server) Stop(ctx context.Context) error {
-	s.logger.Info("Stopping gRPC server...")
-	s.healthSrv.SetServingStatus("polykey.v2.PolykeyService", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
-	s.grpcServer.GracefulStop()
-	s.logger.Info("gRPC server stopped.")
+	s.logger.Info("Stopping "+s.name+"...", "in_flight", s.inFlight.InFlight())
+	s.stopOnce.Do(func() { close(s.stopHealth) })
+	s.inFlight.Drain()
+	s.healthSrv.Shutdown()
+	s.service.closeStreams()
+
+	if err := s.inFlight.Wait(ctx); err != nil {
+		s.logger.Warn("shutdown deadline reached with requests still in flight", "in_flight", s.inFlight.InFlight())
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		<-stopped
+	}
+
+	s.logger.Info(s.name + " stopped.")
 	return nil
 }
 
+// runHealthChecks periodically probes the dependencies and mirrors the result into
+// the grpc_health_v1 server, both for the service as a whole and per component.
+func (s *Server) runHealthChecks(ctx context.Context) {
+	interval := s.cfg.Server.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.applyHealthReport(s.checker.Check(context.WithoutCancel(ctx)))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopHealth:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) applyHealthReport(report infra_health.Report) {
+	overall := grpc_health_v1.HealthCheckResponse_SERVING
+	if report.Status == infra_health.StatusUnhealthy {
+		overall = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	s.healthSrv.SetServingStatus("", overall)
+	for _, service := range s.services {
+		s.healthSrv.SetServingStatus(service, overall)
+	}
+
+	for _, component := range report.Components {
+		status := grpc_health_v1.HealthCheckResponse_SERVING
+		if !component.Healthy {
+			status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+			s.logger.Warn("health probe failed", "component", component.Name, "critical", component.Critical, "error", component.Error)
+		}
+		s.healthSrv.SetServingStatus(componentHealthPrefix+component.Name, status)
+	}
+}
+
 func (s *Server) Health(ctx context.Context) lifecycle.HealthStatus {
-	// The gRPC health server manages the status internally, so we just reflect that.
-	// A more sophisticated check could involve checking dependencies.
-	return lifecycle.HealthStatus{Ready: true, Message: "gRPC server is running"}
+	if s.checker == nil {
+		return lifecycle.HealthStatus{Ready: true, Message: s.name + " is running"}
+	}
+	report, ok := s.checker.Last()
+	if !ok {
+		report = s.checker.Check(ctx)
+	}
+	return lifecycle.HealthStatus{
+		Ready:   report.Status != infra_health.StatusUnhealthy,
+		Message: "gRPC server is " + report.Status.String(),
+	}
 }
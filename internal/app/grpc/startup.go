@@ -0,0 +1,61 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// StartupServer answers health checks on server.port with NOT_SERVING while the service
+// waits for its dependencies, so that probes find a live process that is not ready yet
+// rather than a refused connection. It serves nothing else, and must be stopped before
+// the server takes over the port.
+type StartupServer struct {
+	grpcServer *grpc.Server
+	lis        net.Listener
+	logger     *slog.Logger
+}
+
+// NewStartupServer listens on cfg.Port with tlsConfig, if any, and returns the port.
+func NewStartupServer(cfg config.ServerConfig, tlsConfig *tls.Config, logger *slog.Logger) (*StartupServer, int, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to listen: %w", err)
+	}
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(opts...)
+	healthSrv := health.NewServer()
+	for _, service := range []string{"", polykeyServiceName, PolykeyStreamServiceName} {
+		healthSrv.SetServingStatus(service, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	}
+	grpc_health_v1.RegisterHealthServer(grpcServer, healthSrv)
+	return &StartupServer{grpcServer: grpcServer, lis: lis, logger: logger}, lis.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Start serves health checks in the background.
+func (s *StartupServer) Start() {
+	s.logger.Info("reporting NOT_SERVING until dependencies are ready", "address", s.lis.Addr().String())
+	go func() {
+		if err := s.grpcServer.Serve(s.lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			s.logger.Warn("startup health server stopped", "error", err)
+		}
+	}()
+}
+
+// Stop closes the listener and every connection at once.
+func (s *StartupServer) Stop() {
+	s.grpcServer.Stop()
+	// Serve may not have taken the listener yet.
+	_ = s.lis.Close()
+}
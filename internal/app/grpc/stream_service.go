@@ -0,0 +1,924 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/app/grpc/interceptors"
+	cts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/logging"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// PolykeyStreamServiceName is the companion service that carries RPCs not yet part of
+// the published polykey.v2 proto. It reuses the polykey.v2 messages on the wire,
+// so existing generated clients only need the stream descriptor below.
+const PolykeyStreamServiceName = "polykey.v2.PolykeyStreamService"
+
+const (
+	streamListKeysFullMethod         = "/" + PolykeyStreamServiceName + "/" + cts.MethodStreamListKeys
+	watchKeysFullMethod              = "/" + PolykeyStreamServiceName + "/" + cts.MethodWatchKeys
+	listKeyVersionsFullMethod        = "/" + PolykeyStreamServiceName + "/" + cts.MethodListKeyVersions
+	restoreKeyFullMethod             = "/" + PolykeyStreamServiceName + "/" + cts.MethodRestoreKey
+	putKeyTemplateFullMethod         = "/" + PolykeyStreamServiceName + "/" + cts.MethodPutKeyTemplate
+	listKeyTemplatesFullMethod       = "/" + PolykeyStreamServiceName + "/" + cts.MethodListKeyTemplates
+	deleteKeyTemplateFullMethod      = "/" + PolykeyStreamServiceName + "/" + cts.MethodDeleteKeyTemplate
+	rotateKeysByFilterFullMethod     = "/" + PolykeyStreamServiceName + "/" + cts.MethodRotateKeysByFilter
+	transferKeyOwnershipFullMethod   = "/" + PolykeyStreamServiceName + "/" + cts.MethodTransferKeyOwnership
+	verifyAuditIntegrityFullMethod   = "/" + PolykeyStreamServiceName + "/" + cts.MethodVerifyAuditIntegrity
+	queryAuditEventsFullMethod       = "/" + PolykeyStreamServiceName + "/" + cts.MethodQueryAuditEvents
+	restoreAuditArchivesFullMethod   = "/" + PolykeyStreamServiceName + "/" + cts.MethodRestoreAuditArchives
+	controlCircuitBreakerFullMethod  = "/" + PolykeyStreamServiceName + "/" + cts.MethodControlCircuitBreaker
+	setLogLevelFullMethod            = "/" + PolykeyStreamServiceName + "/" + cts.MethodSetLogLevel
+	getEffectiveConfigFullMethod     = "/" + PolykeyStreamServiceName + "/" + cts.MethodGetEffectiveConfig
+	rotateBootstrapSecretsFullMethod = "/" + PolykeyStreamServiceName + "/" + cts.MethodRotateBootstrapSecrets
+	streamBatchGetKeysFullMethod     = "/" + PolykeyStreamServiceName + "/" + cts.MethodStreamBatchGetKeys
+	streamBatchCreateKeysFullMethod  = "/" + PolykeyStreamServiceName + "/" + cts.MethodStreamBatchCreateKeys
+)
+
+// watchOwnerAttribute is the custom access attribute WatchKeys uses to filter events by key owner.
+const watchOwnerAttribute = "owner"
+
+// PolykeyStreamServer is the server API for the companion streaming service.
+type PolykeyStreamServer interface {
+	StreamListKeys(*pk.ListKeysRequest, grpc.ServerStreamingServer[pk.ListKeysResponse]) error
+	WatchKeys(*pk.ListKeysRequest, grpc.ServerStreamingServer[structpb.Struct]) error
+	ListKeyVersions(context.Context, *pk.GetKeyMetadataRequest) (*pk.ListKeysResponse, error)
+	RestoreKey(context.Context, *pk.RevokeKeyRequest) (*pk.GetKeyMetadataResponse, error)
+	PutKeyTemplate(context.Context, *pk.CreateKeyRequest) (*emptypb.Empty, error)
+	ListKeyTemplates(context.Context, *pk.ListKeysRequest) (*pk.BatchCreateKeysRequest, error)
+	DeleteKeyTemplate(context.Context, *pk.CreateKeyRequest) (*emptypb.Empty, error)
+	RotateKeysByFilter(*pk.ListKeysRequest, grpc.ServerStreamingServer[pk.BatchRotateKeysResponse]) error
+	TransferKeyOwnership(context.Context, *pk.UpdateKeyMetadataRequest) (*pk.GetKeyMetadataResponse, error)
+	VerifyAuditIntegrity(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	QueryAuditEvents(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	RestoreAuditArchives(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ControlCircuitBreaker(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	SetLogLevel(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	GetEffectiveConfig(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	RotateBootstrapSecrets(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	StreamBatchGetKeys(grpc.BidiStreamingServer[pk.BatchGetKeysRequest, pk.BatchGetKeysResponse]) error
+	StreamBatchCreateKeys(grpc.BidiStreamingServer[pk.BatchCreateKeysRequest, pk.BatchCreateKeysResponse]) error
+}
+
+// PolykeyStreamServiceDesc is the grpc.ServiceDesc for the companion streaming service.
+var PolykeyStreamServiceDesc = grpc.ServiceDesc{
+	ServiceName: PolykeyStreamServiceName,
+	HandlerType: (*PolykeyStreamServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod(cts.MethodListKeyVersions, listKeyVersionsFullMethod, PolykeyStreamServer.ListKeyVersions),
+		unaryMethod(cts.MethodRestoreKey, restoreKeyFullMethod, PolykeyStreamServer.RestoreKey),
+		unaryMethod(cts.MethodPutKeyTemplate, putKeyTemplateFullMethod, PolykeyStreamServer.PutKeyTemplate),
+		unaryMethod(cts.MethodListKeyTemplates, listKeyTemplatesFullMethod, PolykeyStreamServer.ListKeyTemplates),
+		unaryMethod(cts.MethodDeleteKeyTemplate, deleteKeyTemplateFullMethod, PolykeyStreamServer.DeleteKeyTemplate),
+		unaryMethod(cts.MethodTransferKeyOwnership, transferKeyOwnershipFullMethod, PolykeyStreamServer.TransferKeyOwnership),
+		unaryMethod(cts.MethodVerifyAuditIntegrity, verifyAuditIntegrityFullMethod, PolykeyStreamServer.VerifyAuditIntegrity),
+		unaryMethod(cts.MethodQueryAuditEvents, queryAuditEventsFullMethod, PolykeyStreamServer.QueryAuditEvents),
+		unaryMethod(cts.MethodRestoreAuditArchives, restoreAuditArchivesFullMethod, PolykeyStreamServer.RestoreAuditArchives),
+		unaryMethod(cts.MethodControlCircuitBreaker, controlCircuitBreakerFullMethod, PolykeyStreamServer.ControlCircuitBreaker),
+		unaryMethod(cts.MethodSetLogLevel, setLogLevelFullMethod, PolykeyStreamServer.SetLogLevel),
+		unaryMethod(cts.MethodGetEffectiveConfig, getEffectiveConfigFullMethod, PolykeyStreamServer.GetEffectiveConfig),
+		unaryMethod(cts.MethodRotateBootstrapSecrets, rotateBootstrapSecretsFullMethod, PolykeyStreamServer.RotateBootstrapSecrets),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    cts.MethodStreamListKeys,
+			Handler:       streamListKeysHandler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    cts.MethodWatchKeys,
+			Handler:       watchKeysHandler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    cts.MethodRotateKeysByFilter,
+			Handler:       rotateKeysByFilterHandler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    cts.MethodStreamBatchGetKeys,
+			Handler:       streamBatchGetKeysHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    cts.MethodStreamBatchCreateKeys,
+			Handler:       streamBatchCreateKeysHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+func streamListKeysHandler(srv any, stream grpc.ServerStream) error {
+	m := new(pk.ListKeysRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PolykeyStreamServer).StreamListKeys(m, &grpc.GenericServerStream[pk.ListKeysRequest, pk.ListKeysResponse]{ServerStream: stream})
+}
+
+func watchKeysHandler(srv any, stream grpc.ServerStream) error {
+	m := new(pk.ListKeysRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PolykeyStreamServer).WatchKeys(m, &grpc.GenericServerStream[pk.ListKeysRequest, structpb.Struct]{ServerStream: stream})
+}
+
+func rotateKeysByFilterHandler(srv any, stream grpc.ServerStream) error {
+	m := new(pk.ListKeysRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PolykeyStreamServer).RotateKeysByFilter(m, &grpc.GenericServerStream[pk.ListKeysRequest, pk.BatchRotateKeysResponse]{ServerStream: stream})
+}
+
+func streamBatchGetKeysHandler(srv any, stream grpc.ServerStream) error {
+	return srv.(PolykeyStreamServer).StreamBatchGetKeys(&grpc.GenericServerStream[pk.BatchGetKeysRequest, pk.BatchGetKeysResponse]{ServerStream: stream})
+}
+
+func streamBatchCreateKeysHandler(srv any, stream grpc.ServerStream) error {
+	return srv.(PolykeyStreamServer).StreamBatchCreateKeys(&grpc.GenericServerStream[pk.BatchCreateKeysRequest, pk.BatchCreateKeysResponse]{ServerStream: stream})
+}
+
+// unaryMethod builds the descriptor for a unary method of a hand-written service such as
+// the companion service, doing what generated code does per method: decode the request
+// and run the interceptor chain.
+func unaryMethod[Srv, Req, Resp any](name, fullMethod string, call func(Srv, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := new(Req)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(Srv), ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: fullMethod,
+			}
+			handler := func(ctx context.Context, req any) (any, error) {
+				return call(srv.(Srv), ctx, req.(*Req))
+			}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+// PolykeyStreamClient is the client API for the companion streaming service.
+type PolykeyStreamClient interface {
+	StreamListKeys(ctx context.Context, in *pk.ListKeysRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pk.ListKeysResponse], error)
+	WatchKeys(ctx context.Context, in *pk.ListKeysRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[structpb.Struct], error)
+	ListKeyVersions(ctx context.Context, in *pk.GetKeyMetadataRequest, opts ...grpc.CallOption) (*pk.ListKeysResponse, error)
+	RestoreKey(ctx context.Context, in *pk.RevokeKeyRequest, opts ...grpc.CallOption) (*pk.GetKeyMetadataResponse, error)
+	PutKeyTemplate(ctx context.Context, in *pk.CreateKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListKeyTemplates(ctx context.Context, in *pk.ListKeysRequest, opts ...grpc.CallOption) (*pk.BatchCreateKeysRequest, error)
+	DeleteKeyTemplate(ctx context.Context, in *pk.CreateKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	RotateKeysByFilter(ctx context.Context, in *pk.ListKeysRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pk.BatchRotateKeysResponse], error)
+	TransferKeyOwnership(ctx context.Context, in *pk.UpdateKeyMetadataRequest, opts ...grpc.CallOption) (*pk.GetKeyMetadataResponse, error)
+	VerifyAuditIntegrity(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error)
+	QueryAuditEvents(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	RestoreAuditArchives(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	ControlCircuitBreaker(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	SetLogLevel(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error)
+	StreamBatchGetKeys(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[pk.BatchGetKeysRequest, pk.BatchGetKeysResponse], error)
+	StreamBatchCreateKeys(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[pk.BatchCreateKeysRequest, pk.BatchCreateKeysResponse], error)
+}
+
+type polykeyStreamClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPolykeyStreamClient(cc grpc.ClientConnInterface) PolykeyStreamClient {
+	return &polykeyStreamClient{cc: cc}
+}
+
+func (c *polykeyStreamClient) StreamListKeys(ctx context.Context, in *pk.ListKeysRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pk.ListKeysResponse], error) {
+	stream, err := c.cc.NewStream(ctx, &PolykeyStreamServiceDesc.Streams[0], streamListKeysFullMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[pk.ListKeysRequest, pk.ListKeysResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (c *polykeyStreamClient) WatchKeys(ctx context.Context, in *pk.ListKeysRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[structpb.Struct], error) {
+	stream, err := c.cc.NewStream(ctx, &PolykeyStreamServiceDesc.Streams[1], watchKeysFullMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[pk.ListKeysRequest, structpb.Struct]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (c *polykeyStreamClient) RotateKeysByFilter(ctx context.Context, in *pk.ListKeysRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pk.BatchRotateKeysResponse], error) {
+	stream, err := c.cc.NewStream(ctx, &PolykeyStreamServiceDesc.Streams[2], rotateKeysByFilterFullMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[pk.ListKeysRequest, pk.BatchRotateKeysResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (c *polykeyStreamClient) StreamBatchGetKeys(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[pk.BatchGetKeysRequest, pk.BatchGetKeysResponse], error) {
+	stream, err := c.cc.NewStream(ctx, &PolykeyStreamServiceDesc.Streams[3], streamBatchGetKeysFullMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc.GenericClientStream[pk.BatchGetKeysRequest, pk.BatchGetKeysResponse]{ClientStream: stream}, nil
+}
+
+func (c *polykeyStreamClient) StreamBatchCreateKeys(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[pk.BatchCreateKeysRequest, pk.BatchCreateKeysResponse], error) {
+	stream, err := c.cc.NewStream(ctx, &PolykeyStreamServiceDesc.Streams[4], streamBatchCreateKeysFullMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc.GenericClientStream[pk.BatchCreateKeysRequest, pk.BatchCreateKeysResponse]{ClientStream: stream}, nil
+}
+
+func invokeUnary[Resp any](ctx context.Context, cc grpc.ClientConnInterface, fullMethod string, in any, opts ...grpc.CallOption) (*Resp, error) {
+	out := new(Resp)
+	if err := cc.Invoke(ctx, fullMethod, in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *polykeyStreamClient) ListKeyVersions(ctx context.Context, in *pk.GetKeyMetadataRequest, opts ...grpc.CallOption) (*pk.ListKeysResponse, error) {
+	return invokeUnary[pk.ListKeysResponse](ctx, c.cc, listKeyVersionsFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) RestoreKey(ctx context.Context, in *pk.RevokeKeyRequest, opts ...grpc.CallOption) (*pk.GetKeyMetadataResponse, error) {
+	return invokeUnary[pk.GetKeyMetadataResponse](ctx, c.cc, restoreKeyFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) PutKeyTemplate(ctx context.Context, in *pk.CreateKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return invokeUnary[emptypb.Empty](ctx, c.cc, putKeyTemplateFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) ListKeyTemplates(ctx context.Context, in *pk.ListKeysRequest, opts ...grpc.CallOption) (*pk.BatchCreateKeysRequest, error) {
+	return invokeUnary[pk.BatchCreateKeysRequest](ctx, c.cc, listKeyTemplatesFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) DeleteKeyTemplate(ctx context.Context, in *pk.CreateKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return invokeUnary[emptypb.Empty](ctx, c.cc, deleteKeyTemplateFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) TransferKeyOwnership(ctx context.Context, in *pk.UpdateKeyMetadataRequest, opts ...grpc.CallOption) (*pk.GetKeyMetadataResponse, error) {
+	return invokeUnary[pk.GetKeyMetadataResponse](ctx, c.cc, transferKeyOwnershipFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) VerifyAuditIntegrity(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, verifyAuditIntegrityFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) QueryAuditEvents(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, queryAuditEventsFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) RestoreAuditArchives(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, restoreAuditArchivesFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) ControlCircuitBreaker(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, controlCircuitBreakerFullMethod, in, opts...)
+}
+
+func (c *polykeyStreamClient) SetLogLevel(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	return invokeUnary[structpb.Struct](ctx, c.cc, setLogLevelFullMethod, in, opts...)
+}
+
+func (s *PolykeyService) StreamListKeys(req *pk.ListKeysRequest, stream grpc.ServerStreamingServer[pk.ListKeysResponse]) error {
+	ctx := stream.Context()
+
+	if ok, reason := s.deps.Authorizer.Authorize(ctx, req.GetRequesterContext(), req.GetAttributes(), cts.MethodScopes[cts.MethodStreamListKeys], domain.KeyID{}); !ok {
+		return s.sanitizeError(ctx, cts.MethodStreamListKeys, authorizationError(reason))
+	}
+
+	if err := s.deps.KeyService.StreamListKeys(s.withListScope(ctx), req, stream.Send); err != nil {
+		return s.sanitizeError(ctx, cts.MethodStreamListKeys, err)
+	}
+	return nil
+}
+
+// WatchKeys streams key lifecycle events until the client goes away, each as a struct
+// with the event type, the key and version it is about, and a summary of the key's
+// metadata. Events can be narrowed with the request's tag filters and statuses, and by
+// owner through the "owner" custom access attribute. Like listings, a caller without an
+// admin role only receives the events of keys that list it among their authorized
+// contexts.
+//
+// Events come from the writes this server makes. Behind a load balancer, a watcher only
+// sees the changes made through the replica it is connected to; the NATS event relay
+// delivers the events of every replica.
+func (s *PolykeyService) WatchKeys(req *pk.ListKeysRequest, stream grpc.ServerStreamingServer[structpb.Struct]) error {
+	ctx := stream.Context()
+
+	if ok, reason := s.deps.Authorizer.Authorize(ctx, req.GetRequesterContext(), req.GetAttributes(), cts.MethodScopes[cts.MethodWatchKeys], domain.KeyID{}); !ok {
+		return s.sanitizeError(ctx, cts.MethodWatchKeys, authorizationError(reason))
+	}
+
+	if s.deps.KeyEvents == nil {
+		return s.sanitizeError(ctx, cts.MethodWatchKeys, app_errors.ErrKeyEventsUnavailable)
+	}
+
+	scope, scoped := domain.ListScopeFromContext(s.withListScope(ctx))
+	events, unsubscribe := s.deps.KeyEvents.Subscribe(ctx)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.done:
+			return s.deps.ErrorClassifier.Status(app_errors.ErrShuttingDown, cts.MethodWatchKeys)
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if !domain.NamespaceVisible(ctx, event.Namespace) || !matchesWatchFilter(req, event) {
+				continue
+			}
+			// An event without metadata cannot show that the caller may see its key.
+			if scoped && !slices.Contains(event.Metadata.GetAuthorizedContexts(), scope) {
+				continue
+			}
+			if err := stream.Send(keyEventStruct(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func matchesWatchFilter(req *pk.ListKeysRequest, event domain.KeyEvent) bool {
+	md := event.Metadata
+	for tag, value := range req.GetTagFilters() {
+		if md.GetTags()[tag] != value {
+			return false
+		}
+	}
+	if owner := req.GetAttributes().GetCustomAttributes()[watchOwnerAttribute]; owner != "" && md.GetCreatorIdentity() != owner {
+		return false
+	}
+	if len(req.GetStatuses()) > 0 && !slices.Contains(req.GetStatuses(), md.GetStatus()) {
+		return false
+	}
+	return true
+}
+
+func keyEventStruct(event domain.KeyEvent) *structpb.Struct {
+	md := event.Metadata
+	tags := make(map[string]*structpb.Value, len(md.GetTags()))
+	for name, value := range md.GetTags() {
+		tags[name] = structpb.NewStringValue(value)
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"type":             structpb.NewStringValue(string(event.Type)),
+		"key_id":           structpb.NewStringValue(event.KeyID),
+		"namespace":        structpb.NewStringValue(event.Namespace),
+		"version":          structpb.NewNumberValue(float64(event.Version)),
+		"actor":            structpb.NewStringValue(event.Actor),
+		"correlation_id":   structpb.NewStringValue(event.CorrelationID),
+		"occurred_at":      structpb.NewStringValue(event.OccurredAt.UTC().Format(time.RFC3339Nano)),
+		"key_type":         structpb.NewStringValue(md.GetKeyType().String()),
+		"status":           structpb.NewStringValue(md.GetStatus().String()),
+		"creator_identity": structpb.NewStringValue(md.GetCreatorIdentity()),
+		"tags":             structpb.NewStructValue(&structpb.Struct{Fields: tags}),
+	}}
+}
+
+// ListKeyVersions returns the metadata of every version of a key, without key material.
+func (s *PolykeyService) ListKeyVersions(ctx context.Context, req *pk.GetKeyMetadataRequest) (*pk.ListKeysResponse, error) {
+	return execWithAuth(s, ctx, cts.MethodListKeyVersions, cts.MethodScopes[cts.MethodListKeyVersions], req.GetKeyId(), req.GetRequesterContext(), req.GetAttributes(),
+		func(ctx context.Context, keyID domain.KeyID) (*pk.ListKeysResponse, error) {
+			return s.deps.KeyService.ListKeyVersions(ctx, req)
+		})
+}
+
+// RestoreKey undoes an accidental RevokeKey within the caller's restore window. The request
+// reuses RevokeKeyRequest; its revocation_reason carries the reason for the restore.
+func (s *PolykeyService) RestoreKey(ctx context.Context, req *pk.RevokeKeyRequest) (*pk.GetKeyMetadataResponse, error) {
+	return execWithAuth(s, ctx, cts.MethodRestoreKey, cts.MethodScopes[cts.MethodRestoreKey], req.GetKeyId(), req.GetRequesterContext(), nil,
+		func(ctx context.Context, keyID domain.KeyID) (*pk.GetKeyMetadataResponse, error) {
+			return s.deps.KeyService.RestoreKey(ctx, req)
+		})
+}
+
+// PutKeyTemplate creates or replaces a key template. The template is described by a
+// CreateKeyRequest whose generation_params name it under "template" and may give a
+// "rotation_period".
+func (s *PolykeyService) PutKeyTemplate(ctx context.Context, req *pk.CreateKeyRequest) (*emptypb.Empty, error) {
+	return execWithoutKey(s, ctx, cts.MethodPutKeyTemplate, cts.MethodScopes[cts.MethodPutKeyTemplate], req.GetRequesterContext(), nil,
+		func(ctx context.Context) (*emptypb.Empty, error) {
+			return emptyResponse, s.deps.KeyService.PutKeyTemplate(ctx, req)
+		})
+}
+
+// ListKeyTemplates returns all key templates, one CreateKeyItem each.
+func (s *PolykeyService) ListKeyTemplates(ctx context.Context, req *pk.ListKeysRequest) (*pk.BatchCreateKeysRequest, error) {
+	return execWithoutKey(s, ctx, cts.MethodListKeyTemplates, cts.MethodScopes[cts.MethodListKeyTemplates], req.GetRequesterContext(), req.GetAttributes(),
+		func(ctx context.Context) (*pk.BatchCreateKeysRequest, error) {
+			return s.deps.KeyService.ListKeyTemplates(ctx, req)
+		})
+}
+
+// DeleteKeyTemplate removes the key template named in the request's generation_params.
+func (s *PolykeyService) DeleteKeyTemplate(ctx context.Context, req *pk.CreateKeyRequest) (*emptypb.Empty, error) {
+	return execWithoutKey(s, ctx, cts.MethodDeleteKeyTemplate, cts.MethodScopes[cts.MethodDeleteKeyTemplate], req.GetRequesterContext(), nil,
+		func(ctx context.Context) (*emptypb.Empty, error) {
+			return emptyResponse, s.deps.KeyService.DeleteKeyTemplate(ctx, req)
+		})
+}
+
+// RotateKeysByFilter rotates every active key matching the request's tag filters and
+// the "creator", "classification" and "kms_provider" custom access attributes. Progress
+// is streamed as BatchRotateKeysResponse messages carrying each page's results and the
+// running counts, ending with a summary message that has no results.
+func (s *PolykeyService) RotateKeysByFilter(req *pk.ListKeysRequest, stream grpc.ServerStreamingServer[pk.BatchRotateKeysResponse]) error {
+	ctx := stream.Context()
+
+	if ok, reason := s.deps.Authorizer.Authorize(ctx, req.GetRequesterContext(), req.GetAttributes(), cts.MethodScopes[cts.MethodRotateKeysByFilter], domain.KeyID{}); !ok {
+		return s.sanitizeError(ctx, cts.MethodRotateKeysByFilter, authorizationError(reason))
+	}
+
+	if err := s.deps.KeyService.RotateKeysByFilter(s.withListScope(ctx), req, stream.Send); err != nil {
+		return s.sanitizeError(ctx, cts.MethodRotateKeysByFilter, err)
+	}
+	return nil
+}
+
+// StreamBatchGetKeys is BatchGetKeys for batches too large to answer in one message.
+// The client sends any number of BatchGetKeysRequest messages, each authorized and
+// limited to batch.max_items like a BatchGetKeys call, and half-closes the stream when
+// done. The result of every key is sent as soon as it is read, alone in a
+// BatchGetKeysResponse with the running success and failure counts of the stream, so
+// that neither side holds a whole batch and the client can send while it receives.
+// Results come in the order the reads complete and are matched to keys by key_id.
+func (s *PolykeyService) StreamBatchGetKeys(stream grpc.BidiStreamingServer[pk.BatchGetKeysRequest, pk.BatchGetKeysResponse]) error {
+	ctx := stream.Context()
+
+	var successCount, failedCount int32
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.authorizeBatchMessage(ctx, cts.MethodStreamBatchGetKeys, streamBatchGetKeysFullMethod, req, req.GetAttributes()); err != nil {
+			return err
+		}
+		// The message is authorized without a key; each key is authorized by its own ID
+		// before it is read.
+		itemCtx := s.withKeyCheck(ctx, cts.MethodScopes[cts.MethodStreamBatchGetKeys], req.GetRequesterContext(), req.GetAttributes())
+		err = s.deps.KeyService.StreamBatchGetKeys(itemCtx, req, func(result *pk.BatchGetKeysResult) error {
+			if result.GetSuccess() != nil {
+				successCount++
+			} else {
+				failedCount++
+			}
+			return stream.Send(&pk.BatchGetKeysResponse{
+				Results:           []*pk.BatchGetKeysResult{result},
+				ResponseTimestamp: timestamppb.Now(),
+				SuccessfulCount:   successCount,
+				FailedCount:       failedCount,
+			})
+		})
+		if err != nil {
+			return s.sanitizeError(ctx, cts.MethodStreamBatchGetKeys, err)
+		}
+	}
+}
+
+// StreamBatchCreateKeys is BatchCreateKeys for batches too large to answer in one
+// message, streamed like StreamBatchGetKeys. Each key is stored as soon as it is
+// created and its result sent alone in a BatchCreateKeysResponse, with a request_index
+// that counts the items of every message sent on the stream so far. Without
+// continue_on_error, a message with an invalid item creates none of its keys, and a
+// key that fails to be created ends the stream; the keys already sent stay created.
+func (s *PolykeyService) StreamBatchCreateKeys(stream grpc.BidiStreamingServer[pk.BatchCreateKeysRequest, pk.BatchCreateKeysResponse]) error {
+	ctx := stream.Context()
+
+	var offset, successCount, failedCount int32
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.authorizeBatchMessage(ctx, cts.MethodStreamBatchCreateKeys, streamBatchCreateKeysFullMethod, req, nil); err != nil {
+			return err
+		}
+		err = s.deps.KeyService.StreamBatchCreateKeys(ctx, req, func(result *pk.BatchCreateKeysResult) error {
+			if result.GetSuccess() != nil {
+				successCount++
+			} else {
+				failedCount++
+			}
+			result.RequestIndex += offset
+			return stream.Send(&pk.BatchCreateKeysResponse{
+				Results:           []*pk.BatchCreateKeysResult{result},
+				ResponseTimestamp: timestamppb.Now(),
+				SuccessfulCount:   successCount,
+				FailedCount:       failedCount,
+			})
+		})
+		if err != nil {
+			return s.sanitizeError(ctx, cts.MethodStreamBatchCreateKeys, err)
+		}
+		offset += int32(len(req.GetKeys()))
+	}
+}
+
+// authorizeBatchMessage authorizes one message of a streaming batch RPC and holds it to
+// the batch item limit that BatchLimitInterceptor applies to unary batch calls.
+func (s *PolykeyService) authorizeBatchMessage(ctx context.Context, method, fullMethod string, req interface {
+	GetRequesterContext() *pk.RequesterContext
+}, attrs *pk.AccessAttributes) error {
+	if ok, reason := s.deps.Authorizer.Authorize(ctx, req.GetRequesterContext(), attrs, cts.MethodScopes[method], domain.KeyID{}); !ok {
+		return s.sanitizeError(ctx, method, authorizationError(reason))
+	}
+	if err := interceptors.CheckBatchItems(ctx, s.deps.Config.Batch, fullMethod, req); err != nil {
+		return s.sanitizeError(ctx, method, err)
+	}
+	return nil
+}
+
+// TransferKeyOwnership hands a key to the owner named under "new_owner" in the request's
+// policies_to_update, rewriting its authorized contexts with contexts_to_remove and
+// contexts_to_add. The caller needs keys:transfer and access to the key. The decisions
+// cached for the key are dropped, so that whoever lost access loses it at once.
+func (s *PolykeyService) TransferKeyOwnership(ctx context.Context, req *pk.UpdateKeyMetadataRequest) (*pk.GetKeyMetadataResponse, error) {
+	return execWithAuth(s, ctx, cts.MethodTransferKeyOwnership, cts.MethodScopes[cts.MethodTransferKeyOwnership], req.GetKeyId(), req.GetRequesterContext(), nil,
+		func(ctx context.Context, keyID domain.KeyID) (*pk.GetKeyMetadataResponse, error) {
+			resp, err := s.deps.KeyService.TransferKeyOwnership(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			if flusher, ok := s.deps.Authorizer.(domain.CacheFlusher); ok {
+				flusher.FlushKey(ctx, keyID)
+			}
+			return resp, nil
+		})
+}
+
+// VerifyAuditIntegrity walks the audit hash chain and reports whether it is intact. There
+// is no polykey.v2 message for the report, so it is returned as a Struct with the fields
+// valid, checked_events, archived_sequence, first_sequence, last_sequence, head_sequence
+// and, for a broken chain, broken_at_sequence and reason.
+func (s *PolykeyService) VerifyAuditIntegrity(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodVerifyAuditIntegrity, cts.MethodScopes[cts.MethodVerifyAuditIntegrity], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			report, err := s.deps.AuditService.VerifyAuditIntegrity(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return auditIntegrityStruct(report), nil
+		})
+}
+
+func auditIntegrityStruct(report *domain.AuditIntegrityReport) *structpb.Struct {
+	fields := map[string]*structpb.Value{
+		"valid":             structpb.NewBoolValue(report.Valid),
+		"checked_events":    structpb.NewNumberValue(float64(report.CheckedEvents)),
+		"archived_sequence": structpb.NewNumberValue(float64(report.ArchivedSequence)),
+		"first_sequence":    structpb.NewNumberValue(float64(report.FirstSequence)),
+		"last_sequence":     structpb.NewNumberValue(float64(report.LastSequence)),
+		"head_sequence":     structpb.NewNumberValue(float64(report.HeadSequence)),
+	}
+	if !report.Valid {
+		fields["broken_at_sequence"] = structpb.NewNumberValue(float64(report.BrokenAtSequence))
+		fields["reason"] = structpb.NewStringValue(report.Reason)
+	}
+	return &structpb.Struct{Fields: fields}
+}
+
+// QueryAuditEvents searches the audit trail, newest first. The request is a Struct with
+// the optional filters client_identity, key_id, operation, success (bool), from and to
+// (RFC 3339, from inclusive and to exclusive), and page_size and page_token. The response
+// has events, a list of event Structs, and next_page_token, empty on the last page.
+func (s *PolykeyService) QueryAuditEvents(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodQueryAuditEvents, cts.MethodScopes[cts.MethodQueryAuditEvents], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			query, pageToken, err := auditQueryFromStruct(req)
+			if err != nil {
+				return nil, err
+			}
+			events, nextPageToken, err := s.deps.AuditService.QueryAuditEvents(ctx, query, pageToken)
+			if err != nil {
+				return nil, err
+			}
+			return auditEventsStruct(events, nextPageToken), nil
+		})
+}
+
+// RestoreAuditArchives brings archived audit events logged between from and to (RFC 3339,
+// both required) back into the database for an investigation. The response has archives,
+// restored_events, restored_until and pending, the archives still being retrieved from
+// Glacier; repeat the call once they are ready.
+func (s *PolykeyService) RestoreAuditArchives(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodRestoreAuditArchives, cts.MethodScopes[cts.MethodRestoreAuditArchives], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			from, to, err := auditRangeFromStruct(req)
+			if err != nil {
+				return nil, err
+			}
+			result, err := s.deps.AuditService.RestoreAuditArchives(ctx, from, to)
+			if err != nil {
+				return nil, err
+			}
+			return auditRestoreStruct(result), nil
+		})
+}
+
+// ControlCircuitBreaker lets operators override the circuit breakers in front of the key
+// repository, the audit repository and the KMS providers during an incident. The
+// request's action is "trip", which opens a breaker and keeps it open until reset,
+// "reset", which closes it, or "status", the default, which changes nothing. The
+// request's name selects one breaker, such as key_repository_writes; without it the
+// action applies to every breaker. The response's breakers list the name, state,
+// failures, trips and forced of the selected breakers. Trips and resets are audited.
+func (s *PolykeyService) ControlCircuitBreaker(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodControlCircuitBreaker, cts.MethodScopes[cts.MethodControlCircuitBreaker], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			action, name, err := circuitBreakerRequestFromStruct(req)
+			if err != nil {
+				return nil, err
+			}
+			if len(s.deps.CircuitBreakers) == 0 {
+				return nil, app_errors.ErrCircuitBreakerDisabled
+			}
+			var breakers []domain.CircuitBreakerControl
+			for _, breaker := range s.deps.CircuitBreakers {
+				if name == "" || breaker.Status().Name == name {
+					breakers = append(breakers, breaker)
+				}
+			}
+			if len(breakers) == 0 {
+				return nil, fmt.Errorf("%w: unknown circuit breaker %s", app_errors.ErrInvalidInput, name)
+			}
+
+			var operation string
+			var changes []domain.AuditChange
+			statuses := make([]domain.CircuitBreakerStatus, len(breakers))
+			for i, breaker := range breakers {
+				before := breaker.Status()
+				switch action {
+				case "trip":
+					breaker.Trip()
+					operation = "TripCircuitBreaker"
+				case "reset":
+					breaker.Reset()
+					operation = "ResetCircuitBreaker"
+				}
+				statuses[i] = breaker.Status()
+				if statuses[i].State != before.State {
+					changes = append(changes, domain.AuditChange{Field: before.Name, Old: before.State, New: statuses[i].State})
+				}
+			}
+			if operation != "" {
+				var clientIdentity string
+				if user, ok := domain.UserFromContext(ctx); ok {
+					clientIdentity = user.ID
+				}
+				s.deps.Audit.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), clientIdentity, operation, "", "", true, nil)
+			}
+			return circuitBreakersStruct(statuses), nil
+		})
+}
+
+// SetLogLevel changes log levels at runtime. The request may set level, such as "debug"
+// or "warn", and module. A level alone becomes the global level; with a module it
+// becomes that module's level, or with "inherit" the module follows the global level
+// again. An empty request changes nothing. The response has the global level and
+// modules, the module levels. Changes are audited.
+func (s *PolykeyService) SetLogLevel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodSetLogLevel, cts.MethodScopes[cts.MethodSetLogLevel], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			module, levelName, err := logLevelFromStruct(req)
+			if err != nil {
+				return nil, err
+			}
+			levels := s.deps.LogLevels
+			if levels == nil {
+				return nil, app_errors.ErrLogLevelsUnavailable
+			}
+			if levelName == "" {
+				return logLevelsStruct(levels), nil
+			}
+
+			field, oldLevel := "log_level", levels.Global().String()
+			if module != "" {
+				field = "log_level." + module
+				oldLevel = levelOrInherit(levels.Modules(), module)
+			}
+			if module != "" && levelName == "inherit" {
+				levels.ClearModule(module)
+			} else {
+				level, err := logging.ParseLevel(levelName)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %v", app_errors.ErrInvalidInput, err)
+				}
+				if module != "" {
+					levels.SetModule(module, level)
+				} else {
+					levels.SetGlobal(level)
+				}
+			}
+			newLevel := levels.Global().String()
+			if module != "" {
+				newLevel = levelOrInherit(levels.Modules(), module)
+			}
+
+			var clientIdentity string
+			if user, ok := domain.UserFromContext(ctx); ok {
+				clientIdentity = user.ID
+			}
+			changes := []domain.AuditChange{{Field: field, Old: oldLevel, New: newLevel}}
+			s.deps.Audit.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), clientIdentity, cts.MethodSetLogLevel, "", "", true, nil)
+			s.deps.Logger.Warn("log level changed", "field", field, "from", oldLevel, "to", newLevel, "client", clientIdentity)
+			return logLevelsStruct(levels), nil
+		})
+}
+
+// GetEffectiveConfig returns the config the service runs with, as last reloaded, to debug
+// which of the defaults, config file, environment and bootstrap secret overrides a value
+// came from. The request may set prefix to return only the settings under it, such as
+// "server.rate_limiter". The response's settings list has the key, value and source of
+// each setting, with secrets masked.
+func (s *PolykeyService) GetEffectiveConfig(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodGetEffectiveConfig, cts.MethodScopes[cts.MethodGetEffectiveConfig], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			var prefix string
+			for name, value := range req.GetFields() {
+				if name != "prefix" {
+					return nil, fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+				}
+				var err error
+				if prefix, err = structString(name, value); err != nil {
+					return nil, err
+				}
+			}
+
+			cfg := s.deps.Config
+			if s.deps.CurrentConfig != nil {
+				cfg = s.deps.CurrentConfig()
+			}
+			var settings []*structpb.Value
+			for _, setting := range cfg.Settings() {
+				if prefix != "" && setting.Key != prefix && !strings.HasPrefix(setting.Key, prefix+".") {
+					continue
+				}
+				settings = append(settings, structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+					"key":    structpb.NewStringValue(setting.Key),
+					"value":  structpb.NewStringValue(setting.Value),
+					"source": structpb.NewStringValue(setting.Source),
+				}}))
+			}
+			return &structpb.Struct{Fields: map[string]*structpb.Value{
+				"settings": structpb.NewListValue(&structpb.ListValue{Values: settings}),
+			}}, nil
+		})
+}
+
+// RotateBootstrapSecrets reads the database URL, JWT private key and TLS materials from
+// the bootstrap secret provider again and switches the service to those that changed,
+// without a restart. The response's rotated lists their secret paths. If some fail to
+// apply, the service keeps their current values and the call fails. Rotations are
+// audited.
+func (s *PolykeyService) RotateBootstrapSecrets(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	return execWithoutKey(s, ctx, cts.MethodRotateBootstrapSecrets, cts.MethodScopes[cts.MethodRotateBootstrapSecrets], nil, nil,
+		func(ctx context.Context) (*structpb.Struct, error) {
+			if s.deps.SecretRotator == nil {
+				return nil, app_errors.ErrSecretRotationUnavailable
+			}
+			rotated, err := s.deps.SecretRotator.RotateSecrets(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", app_errors.ErrSecretRotationFailed, err)
+			}
+			secrets := make([]*structpb.Value, len(rotated))
+			for i, secret := range rotated {
+				secrets[i] = structpb.NewStringValue(secret)
+			}
+			return &structpb.Struct{Fields: map[string]*structpb.Value{
+				"rotated": structpb.NewListValue(&structpb.ListValue{Values: secrets}),
+			}}, nil
+		})
+}
+
+// circuitBreakerRequestFromStruct reads the action and breaker name of a
+// ControlCircuitBreaker request.
+func circuitBreakerRequestFromStruct(req *structpb.Struct) (action, name string, err error) {
+	action = "status"
+	for field, value := range req.GetFields() {
+		switch field {
+		case "action":
+			action, err = structString(field, value)
+		case "name":
+			name, err = structString(field, value)
+		default:
+			err = fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, field)
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	switch action {
+	case "status", "trip", "reset":
+		return action, name, nil
+	default:
+		return "", "", fmt.Errorf("%w: action must be status, trip or reset, not %q", app_errors.ErrInvalidInput, action)
+	}
+}
+
+func circuitBreakersStruct(statuses []domain.CircuitBreakerStatus) *structpb.Struct {
+	breakers := make([]*structpb.Value, len(statuses))
+	for i, status := range statuses {
+		breakers[i] = structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"name":     structpb.NewStringValue(status.Name),
+			"state":    structpb.NewStringValue(status.State),
+			"failures": structpb.NewNumberValue(float64(status.Failures)),
+			"trips":    structpb.NewNumberValue(float64(status.Trips)),
+			"forced":   structpb.NewBoolValue(status.Forced),
+		}})
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"breakers": structpb.NewListValue(&structpb.ListValue{Values: breakers}),
+	}}
+}
+
+// logLevelFromStruct reads the module and level of a SetLogLevel request.
+func logLevelFromStruct(req *structpb.Struct) (module, level string, err error) {
+	for name, value := range req.GetFields() {
+		switch name {
+		case "module":
+			module, err = structString(name, value)
+		case "level":
+			level, err = structString(name, value)
+		default:
+			err = fmt.Errorf("%w: unknown field %s", app_errors.ErrInvalidInput, name)
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if module != "" && level == "" {
+		return "", "", fmt.Errorf("%w: level is required with module", app_errors.ErrInvalidInput)
+	}
+	return module, level, nil
+}
+
+func levelOrInherit(modules map[string]slog.Level, module string) string {
+	if level, ok := modules[module]; ok {
+		return level.String()
+	}
+	return "inherit"
+}
+
+func logLevelsStruct(levels *logging.Levels) *structpb.Struct {
+	modules := make(map[string]*structpb.Value)
+	for module, level := range levels.Modules() {
+		modules[module] = structpb.NewStringValue(level.String())
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"global":  structpb.NewStringValue(levels.Global().String()),
+		"modules": structpb.NewStructValue(&structpb.Struct{Fields: modules}),
+	}}
+}
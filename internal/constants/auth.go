@@ -1,13 +1,45 @@
 package constants
 
 const (
-	MethodGetKey            = "GetKey"
-	MethodCreateKey         = "CreateKey"
-	MethodListKeys          = "ListKeys"
-	MethodRotateKey         = "RotateKey"
-	MethodRevokeKey         = "RevokeKey"
-	MethodUpdateKeyMetadata = "UpdateKeyMetadata"
-	MethodGetKeyMetadata    = "GetKeyMetadata"
+	MethodGetKey                 = "GetKey"
+	MethodCreateKey              = "CreateKey"
+	MethodListKeys               = "ListKeys"
+	MethodRotateKey              = "RotateKey"
+	MethodRevokeKey              = "RevokeKey"
+	MethodUpdateKeyMetadata      = "UpdateKeyMetadata"
+	MethodGetKeyMetadata         = "GetKeyMetadata"
+	MethodStreamListKeys         = "StreamListKeys"
+	MethodWatchKeys              = "WatchKeys"
+	MethodListKeyVersions        = "ListKeyVersions"
+	MethodRestoreKey             = "RestoreKey"
+	MethodPutKeyTemplate         = "PutKeyTemplate"
+	MethodListKeyTemplates       = "ListKeyTemplates"
+	MethodDeleteKeyTemplate      = "DeleteKeyTemplate"
+	MethodRotateKeysByFilter     = "RotateKeysByFilter"
+	MethodTransferKeyOwnership   = "TransferKeyOwnership"
+	MethodVerifyAuditIntegrity   = "VerifyAuditIntegrity"
+	MethodQueryAuditEvents       = "QueryAuditEvents"
+	MethodRestoreAuditArchives   = "RestoreAuditArchives"
+	MethodControlCircuitBreaker  = "ControlCircuitBreaker"
+	MethodSetLogLevel            = "SetLogLevel"
+	MethodGetEffectiveConfig     = "GetEffectiveConfig"
+	MethodRotateBootstrapSecrets = "RotateBootstrapSecrets"
+	MethodListClients            = "ListClients"
+	MethodPutClient              = "PutClient"
+	MethodDeleteClient           = "DeleteClient"
+	MethodListRoles              = "ListRoles"
+	MethodPutRole                = "PutRole"
+	MethodDeleteRole             = "DeleteRole"
+	MethodFlushCaches            = "FlushCaches"
+	MethodReloadConfig           = "ReloadConfig"
+	MethodRewrapKeys             = "RewrapKeys"
+	MethodApplyKey               = "ApplyKey"
+	MethodGetKeyByAlias          = "GetKeyByAlias"
+	MethodListActiveTokens       = "ListActiveTokens"
+	MethodRevokeAllForClient     = "RevokeAllForClient"
+	MethodStreamBatchGetKeys     = "StreamBatchGetKeys"
+	MethodStreamBatchCreateKeys  = "StreamBatchCreateKeys"
+	MethodGetOpenAPIDocument     = "GetOpenAPIDocument"
 )
 
 const (
@@ -17,14 +49,100 @@ const (
 	AuthKeysRotate = "keys:rotate"
 	AuthKeysRevoke = "keys:revoke"
 	AuthKeysUpdate = "keys:update"
+	// AuthKeysRestore is deliberately separate from keys:revoke so that undoing a
+	// revocation can be granted to fewer principals than revoking.
+	AuthKeysRestore = "keys:restore"
+	// AuthKeysTransfer hands a key to another owner, which also drops the caller's
+	// own access unless they are kept in the new authorized contexts.
+	AuthKeysTransfer = "keys:transfer"
+	// AuthKeysAdmin guards operations that manage the service rather than one key.
+	AuthKeysAdmin = "keys:admin"
+	// AuthAuditRead lets security teams search the audit trail without any key access.
+	AuthAuditRead = "audit:read"
 )
- 
+
+// PolicyRequireStepUp is the access policy entry that marks a key as requiring
+// a step-up (MFA) assertion in the caller's token.
+const PolicyRequireStepUp = "require_step_up"
+
+// PolicyExportable and PolicyPlaintextMaterialAllowed are the access policy entries
+// that restrict what clients may receive of a key's material. Either set to "false"
+// holds for the life of the key: it cannot be set back to "true".
+const (
+	// PolicyExportable set to "false" keeps the wrapped material of the key from ever
+	// being returned, by its creation, rotations or reads.
+	PolicyExportable = "exportable"
+	// PolicyPlaintextMaterialAllowed set to "false" keeps the plaintext material of the
+	// key from ever being returned. Polykey only returns material wrapped by its KMS
+	// provider, so no RPC returns it.
+	PolicyPlaintextMaterialAllowed = "plaintext_material_allowed"
+)
+
+// PolicyRotationPeriod is the access policy entry in which a key created from a
+// template records the template's rotation period, as a Go duration string. The
+// scheduled rotation job rotates the key once its latest version is that old.
+const PolicyRotationPeriod = "rotation_period"
+
+// GenerationParamTemplate is the CreateKeyRequest generation_params entry that names
+// the key template to create the key from. Template management requests carry the
+// template's own name and rotation period in the same map.
+const (
+	GenerationParamTemplate       = "template"
+	GenerationParamRotationPeriod = "rotation_period"
+)
+
+// RotationFilter* are the custom access attributes RotateKeysByFilter matches keys on,
+// in addition to the request's tag filters. The KMS provider is the configured
+// provider name, such as "aws" or "local".
+const (
+	RotationFilterCreator        = "creator"
+	RotationFilterClassification = "classification"
+	RotationFilterKMSProvider    = "kms_provider"
+)
+
+// TransferParamNewOwner is the UpdateKeyMetadataRequest policies_to_update entry in which
+// TransferKeyOwnership receives the identity of the new owner. It is consumed by the
+// transfer and not stored as an access policy.
+const TransferParamNewOwner = "new_owner"
+
 var MethodScopes = map[string]string{
-	MethodGetKey:            AuthKeysRead,
-	MethodCreateKey:         AuthKeysCreate,
-	MethodListKeys:          AuthKeysList,
-	MethodRotateKey:         AuthKeysRotate,
-	MethodRevokeKey:         AuthKeysRevoke,
-	MethodUpdateKeyMetadata: AuthKeysUpdate,
-	MethodGetKeyMetadata:    AuthKeysRead,
+	MethodGetKey:                 AuthKeysRead,
+	MethodCreateKey:              AuthKeysCreate,
+	MethodListKeys:               AuthKeysList,
+	MethodRotateKey:              AuthKeysRotate,
+	MethodRevokeKey:              AuthKeysRevoke,
+	MethodUpdateKeyMetadata:      AuthKeysUpdate,
+	MethodGetKeyMetadata:         AuthKeysRead,
+	MethodStreamListKeys:         AuthKeysList,
+	MethodWatchKeys:              AuthKeysList,
+	MethodListKeyVersions:        AuthKeysRead,
+	MethodRestoreKey:             AuthKeysRestore,
+	MethodPutKeyTemplate:         AuthKeysAdmin,
+	MethodListKeyTemplates:       AuthKeysAdmin,
+	MethodDeleteKeyTemplate:      AuthKeysAdmin,
+	MethodRotateKeysByFilter:     AuthKeysAdmin,
+	MethodTransferKeyOwnership:   AuthKeysTransfer,
+	MethodVerifyAuditIntegrity:   AuthKeysAdmin,
+	MethodQueryAuditEvents:       AuthAuditRead,
+	MethodRestoreAuditArchives:   AuthKeysAdmin,
+	MethodControlCircuitBreaker:  AuthKeysAdmin,
+	MethodSetLogLevel:            AuthKeysAdmin,
+	MethodGetEffectiveConfig:     AuthKeysAdmin,
+	MethodRotateBootstrapSecrets: AuthKeysAdmin,
+	MethodListClients:            AuthKeysAdmin,
+	MethodPutClient:              AuthKeysAdmin,
+	MethodDeleteClient:           AuthKeysAdmin,
+	MethodListRoles:              AuthKeysAdmin,
+	MethodPutRole:                AuthKeysAdmin,
+	MethodDeleteRole:             AuthKeysAdmin,
+	MethodFlushCaches:            AuthKeysAdmin,
+	MethodReloadConfig:           AuthKeysAdmin,
+	MethodRewrapKeys:             AuthKeysAdmin,
+	MethodApplyKey:               AuthKeysAdmin,
+	MethodGetKeyByAlias:          AuthKeysAdmin,
+	MethodListActiveTokens:       AuthKeysAdmin,
+	MethodRevokeAllForClient:     AuthKeysAdmin,
+	MethodStreamBatchGetKeys:     AuthKeysRead,
+	MethodStreamBatchCreateKeys:  AuthKeysCreate,
+	MethodGetOpenAPIDocument:     AuthKeysAdmin,
 }
@@ -1,11 +1,14 @@
 package constants
 
+import "fmt"
+
 // Prepared statement names
 const (
 	StmtGetLatestKey    = "get_latest_key"
 	StmtGetKeyByVersion = "get_key_by_version"
 	
 	StmtUpdateMetadata  = "update_metadata"
+	StmtUpdateMetadataIfOwner = "update_metadata_if_owner"
 	StmtRevokeKey       = "revoke_key"
 	StmtCheckExists     = "check_exists"
 	StmtGetVersions     = "get_versions"
@@ -15,82 +18,225 @@ const (
 	StmtGetBatchKeys        = "get_batch_keys"
 	StmtGetBatchKeyMetadata = "get_batch_key_metadata"
 	StmtRevokeBatchKeys     = "revoke_batch_keys"
+	StmtExpireKeys          = "expire_keys"
+	StmtListExpiringKeys    = "list_expiring_keys"
+	StmtGetLatestStatus     = "get_latest_status"
+	StmtRecordKeyAccesses   = "record_key_accesses"
+	StmtRestoreKey          = "restore_key"
+	StmtCountKeys           = "count_keys"
+	StmtCountActiveKeys     = "count_active_keys"
+	StmtPruneKeyVersions    = "prune_key_versions"
 )
 
 var Queries = map[string]string{
 	StmtGetLatestKey: `
-		SELECT version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at 
+		SELECT version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider 
 		FROM keys 
-		WHERE id = $1::uuid 
+		WHERE id = $1::uuid AND ($2::text IS NULL OR namespace = $2)
 		ORDER BY version DESC 
 		LIMIT 1`,
 
 	StmtGetKeyByVersion: `
-		SELECT version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at 
+		SELECT version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider 
 		FROM keys 
-		WHERE id = $1::uuid AND version = $2`,
+		WHERE id = $1::uuid AND version = $2 AND ($3::text IS NULL OR namespace = $3)`,
 
 	
 
 	StmtUpdateMetadata: `
 		UPDATE keys 
 		SET metadata = $1, updated_at = $2 
-		WHERE id = $3::uuid AND version = (
+		WHERE id = $3::uuid AND ($4::text IS NULL OR namespace = $4) AND version = (
 			SELECT MAX(version) FROM keys WHERE id = $3::uuid
 		)`,
 
+	StmtUpdateMetadataIfOwner: `
+		UPDATE keys 
+		SET metadata = $1, updated_at = $2 
+		WHERE id = $3::uuid AND ($4::text IS NULL OR namespace = $4) AND version = $5
+		  AND creator_identity IS NOT DISTINCT FROM NULLIF($6::text, '')
+		  AND version = (SELECT MAX(version) FROM keys WHERE id = $3::uuid)`,
+
 	StmtRevokeKey: `
 		UPDATE keys 
 		SET status = $1, revoked_at = $2 
-		WHERE id = $3::uuid`,
+		WHERE id = $3::uuid AND status = ANY($4) AND ($5::text IS NULL OR namespace = $5)`,
 
 	StmtCheckExists: `
-		SELECT EXISTS(SELECT 1 FROM keys WHERE id = $1::uuid LIMIT 1)`,
+		SELECT EXISTS(SELECT 1 FROM keys WHERE id = $1::uuid AND ($2::text IS NULL OR namespace = $2) LIMIT 1)`,
 
 	StmtGetVersions: `
-		SELECT version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at 
+		SELECT version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider 
 		FROM keys 
-		WHERE id = $1::uuid 
+		WHERE id = $1::uuid AND ($2::text IS NULL OR namespace = $2)
 		ORDER BY version DESC`,
 
-	StmtListKeys: `
-		WITH latest_keys AS (
-			SELECT DISTINCT ON (id) id, version, metadata, encrypted_dek, status, storage_type, 
-				   created_at, updated_at, revoked_at
-			FROM keys 
-			ORDER BY id, version DESC
-		)
-		SELECT id, version, metadata, encrypted_dek, status, storage_type, 
-			   created_at, updated_at, revoked_at 
-		FROM latest_keys
-		WHERE ($1::timestamptz IS NULL OR created_at < $1)
-		ORDER BY created_at DESC
-		LIMIT $2`,
-
 	StmtGetKeyMetadata: `
 		SELECT metadata FROM keys 
-		WHERE id = $1::uuid 
+		WHERE id = $1::uuid AND ($2::text IS NULL OR namespace = $2)
 		ORDER BY version DESC 
 		LIMIT 1`,
 
 	StmtGetKeyMetadataByVersion: `
 		SELECT metadata FROM keys 
-		WHERE id = $1::uuid AND version = $2`,
+		WHERE id = $1::uuid AND version = $2 AND ($3::text IS NULL OR namespace = $3)`,
 
 	StmtGetBatchKeys: `
-		SELECT id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at
+		SELECT id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider
 		FROM keys
-		WHERE id = ANY($1)
+		WHERE id = ANY($1) AND ($2::text IS NULL OR namespace = $2)
 		ORDER BY id, version DESC`,
 
 	StmtGetBatchKeyMetadata: `
 		SELECT metadata
 		FROM keys
-		WHERE id = ANY($1)
+		WHERE id = ANY($1) AND ($2::text IS NULL OR namespace = $2)
 		ORDER BY id, version DESC`,
 
 	StmtRevokeBatchKeys: `
 		UPDATE keys
 		SET status = $1, revoked_at = $2
-		WHERE id = ANY($3)`,
+		WHERE id = ANY($3) AND status = ANY($4) AND ($5::text IS NULL OR namespace = $5)`,
+
+	StmtRestoreKey: `
+		UPDATE keys
+		SET status = $1, revoked_at = NULL, updated_at = now()
+		WHERE id = $2::uuid AND status = $3 AND revoked_at >= $4
+		  AND ($5::text IS NULL OR namespace = $5)
+		  AND version = (SELECT MAX(version) FROM keys WHERE id = $2::uuid)
+		RETURNING version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider`,
+
+	StmtCountKeys: `
+		SELECT COUNT(*) FROM (
+			SELECT DISTINCT ON (id) status FROM keys
+			WHERE namespace = $1
+			ORDER BY id, version DESC
+		) latest
+		WHERE status <> ALL($2)`,
+
+	StmtCountActiveKeys: `
+		SELECT COUNT(DISTINCT id) FROM keys WHERE status = $1`,
+
+	StmtGetLatestStatus: `
+		SELECT status FROM keys
+		WHERE id = $1::uuid AND ($2::text IS NULL OR namespace = $2)
+		ORDER BY version DESC
+		LIMIT 1`,
+
+	StmtExpireKeys: `
+		WITH due AS (
+			SELECT k.id, k.version
+			FROM keys k
+			WHERE (k.status = $2
+			       AND k.expires_at <= $3
+			       AND k.version = (SELECT MAX(version) FROM keys WHERE id = k.id))
+			   OR (k.status = $5 AND k.grace_expires_at <= $3)
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE keys
+		SET status = $1, updated_at = now()
+		FROM due
+		WHERE keys.id = due.id AND keys.version = due.version
+		RETURNING keys.id, keys.version, keys.metadata, keys.encrypted_dek, keys.status, keys.storage_type,
+			keys.created_at, keys.updated_at, keys.revoked_at, keys.grace_expires_at, keys.namespace, keys.kms_provider`,
+
+	StmtListExpiringKeys: `
+		SELECT id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider
+		FROM keys k
+		WHERE status = $1
+		  AND (expires_at > $2 OR ($5::uuid IS NOT NULL AND expires_at = $2 AND id > $5::uuid))
+		  AND expires_at <= $3
+		  AND version = (SELECT MAX(version) FROM keys WHERE id = k.id)
+		ORDER BY expires_at, id
+		LIMIT $4`,
+
+	StmtRecordKeyAccesses: `
+		UPDATE keys k
+		SET metadata = jsonb_set(
+				jsonb_set(k.metadata, '{access_count}',
+					to_jsonb(COALESCE((k.metadata->>'access_count')::bigint, 0) + a.hits)),
+				'{last_accessed_at}', jsonb_build_object('seconds', a.seconds, 'nanos', a.nanos))
+		FROM unnest($1::uuid[], $2::bigint[], $3::bigint[], $4::int[]) AS a(id, hits, seconds, nanos)
+		WHERE k.id = a.id
+		  AND k.version = (SELECT MAX(version) FROM keys WHERE id = a.id)`,
+
+	StmtPruneKeyVersions: `
+		WITH due AS (
+			SELECT k.id, k.version
+			FROM keys k
+			WHERE ((k.status = $1 AND k.updated_at <= $3)
+			       OR (k.status = $2 AND k.grace_expires_at <= $3))
+			  AND k.version <= (SELECT MAX(version) FROM keys WHERE id = k.id) - $4
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		), pruned AS (
+			DELETE FROM keys
+			USING due
+			WHERE keys.id = due.id AND keys.version = due.version
+			RETURNING keys.id, keys.version, keys.metadata, keys.encrypted_dek, keys.status, keys.storage_type,
+				keys.created_at, keys.updated_at, keys.revoked_at, keys.grace_expires_at, keys.namespace, keys.kms_provider
+		), archived AS (
+			INSERT INTO archived_key_versions (id, version, metadata, encrypted_dek, status, storage_type,
+				created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider)
+			SELECT * FROM pruned
+			WHERE $6::boolean
+			ON CONFLICT (id, version) DO NOTHING
+		)
+		SELECT * FROM pruned`,
+}
+
+// listKeysQuery is the ListKeys statement, formatted with the sort expression, its SQL
+// type, the direction and the comparison that resumes after the cursor of $1 and $9. Key
+// type and creator are the same in every version of a key, so they can narrow the
+// versions scanned; status and classification are those of the latest version.
+const listKeysQuery = `
+		WITH latest_keys AS (
+			SELECT DISTINCT ON (id) id, version, metadata, encrypted_dek, status, storage_type, 
+				   created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider, data_classification,
+				   last_accessed_at
+			FROM keys 
+			WHERE ($3::text IS NULL OR namespace = $3)
+			  AND ($4::int[] IS NULL OR key_type = ANY($4))
+			  AND ($6::text IS NULL OR creator_identity = $6)
+			ORDER BY id, version DESC
+		), listed_keys AS (
+			SELECT latest_keys.*,
+				   (SELECT MIN(alias) FROM key_aliases a WHERE a.namespace = latest_keys.namespace AND a.key_id = latest_keys.id) AS alias
+			FROM latest_keys
+			WHERE ($5::text[] IS NULL OR status = ANY($5))
+			  AND ($7::text IS NULL OR data_classification = $7)
+			  AND ($8::text IS NULL OR metadata->'authorized_contexts' @> jsonb_build_array($8::text))
+		)
+		SELECT id, version, metadata, encrypted_dek, status, storage_type, 
+			   created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider, alias
+		FROM listed_keys
+		WHERE ($1::text IS NULL OR (%[1]s, id) %[4]s ($1::text::%[2]s, $9::uuid))
+		ORDER BY %[1]s %[3]s, id %[3]s
+		LIMIT $2`
+
+// listKeysSortColumns are the sort expressions and SQL types of the fields keys can be
+// listed by. Keys never accessed sort as accessed at -infinity and keys without alias as
+// the empty alias, so that every key has a value a cursor compares with.
+var listKeysSortColumns = map[string][2]string{
+	"created_at":       {"created_at", "timestamptz"},
+	"updated_at":       {"updated_at", "timestamptz"},
+	"last_accessed_at": {"COALESCE(last_accessed_at, '-infinity')", "timestamptz"},
+	"alias":            {"COALESCE(alias, '')", "text"},
+}
+
+// ListKeysStatement returns the name of the ListKeys statement ordered by field, which
+// must be one of domain.KeySortFields.
+func ListKeysStatement(field string, descending bool) string {
+	if descending {
+		return StmtListKeys + "_by_" + field + "_desc"
+	}
+	return StmtListKeys + "_by_" + field + "_asc"
+}
+
+func init() {
+	for field, column := range listKeysSortColumns {
+		Queries[ListKeysStatement(field, false)] = fmt.Sprintf(listKeysQuery, column[0], column[1], "ASC", ">")
+		Queries[ListKeysStatement(field, true)] = fmt.Sprintf(listKeysQuery, column[0], column[1], "DESC", "<")
+	}
 }
@@ -0,0 +1,345 @@
+package csiprovider
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The Secrets Store CSI driver talks to its providers with the v1alpha1.CSIDriverProvider
+// service of sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1. Its messages are
+// small and stable, so they are encoded here by hand rather than pulling in the driver
+// module; the field numbers are those of the driver's service.proto.
+
+const (
+	// ServiceName is the gRPC service the driver calls.
+	ServiceName = "v1alpha1.CSIDriverProvider"
+	// ProtocolVersion is the provider API version the driver asks for.
+	ProtocolVersion = "v1alpha1"
+)
+
+// VersionRequest asks for the provider's API version.
+type VersionRequest struct {
+	Version string
+}
+
+// VersionResponse names the provider and its API version.
+type VersionResponse struct {
+	Version        string
+	RuntimeName    string
+	RuntimeVersion string
+}
+
+// MountRequest asks for the files of a SecretProviderClass volume.
+type MountRequest struct {
+	// Attributes is a JSON object of the SecretProviderClass parameters and the pod's
+	// identity.
+	Attributes string
+	// Secrets is a JSON object of the nodePublishSecretRef Secret.
+	Secrets string
+	// TargetPath is where the driver mounts the files.
+	TargetPath string
+	// Permission is the JSON file mode of the files.
+	Permission string
+	// CurrentObjectVersion is what the volume holds, empty on the first mount.
+	CurrentObjectVersion []ObjectVersion
+}
+
+// MountResponse holds the files the driver writes to the volume, and the versions of the
+// objects they came from.
+type MountResponse struct {
+	ObjectVersion []ObjectVersion
+	Error         *Error
+	Files         []File
+}
+
+// ObjectVersion is the version of a mounted object.
+type ObjectVersion struct {
+	ID      string
+	Version string
+}
+
+// Error reports a mount failure by code.
+type Error struct {
+	Code string
+}
+
+// File is a file of the volume, with its path relative to the target path.
+type File struct {
+	Path     string
+	Mode     int32
+	Contents []byte
+}
+
+// Server is the provider API.
+type Server interface {
+	Version(context.Context, *VersionRequest) (*VersionResponse, error)
+	Mount(context.Context, *MountRequest) (*MountResponse, error)
+}
+
+// ServiceDesc is the grpc.ServiceDesc of the provider API. The server it is registered
+// on needs the Codec, as the messages are not generated protobuf types.
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Version", Handler: unaryHandler("Version", Server.Version)},
+		{MethodName: "Mount", Handler: unaryHandler("Mount", Server.Mount)},
+	},
+}
+
+// unaryHandler decodes the request of a provider method and runs the interceptor chain,
+// as generated code does.
+func unaryHandler[Req, Resp any](method string, call func(Server, context.Context, *Req) (*Resp, error)) grpc.MethodHandler {
+	fullMethod := "/" + ServiceName + "/" + method
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := new(Req)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(Server), ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}
+		return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+			return call(srv.(Server), ctx, req.(*Req))
+		})
+	}
+}
+
+// message is a provider message with its wire encoding.
+type message interface {
+	marshal() []byte
+	unmarshal([]byte) error
+}
+
+// Codec encodes the provider messages in the protobuf wire format.
+type Codec struct{}
+
+func (Codec) Name() string { return "proto" }
+
+func (Codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("csiprovider: cannot marshal %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (Codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("csiprovider: cannot unmarshal into %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+func (m *VersionRequest) marshal() []byte {
+	return appendString(nil, 1, m.Version)
+}
+
+func (m *VersionRequest) unmarshal(data []byte) error {
+	return consumeFields(data, func(num protowire.Number, value []byte) error {
+		if num == 1 {
+			m.Version = string(value)
+		}
+		return nil
+	})
+}
+
+func (m *VersionResponse) marshal() []byte {
+	b := appendString(nil, 1, m.Version)
+	b = appendString(b, 2, m.RuntimeName)
+	return appendString(b, 3, m.RuntimeVersion)
+}
+
+func (m *VersionResponse) unmarshal(data []byte) error {
+	return consumeFields(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			m.Version = string(value)
+		case 2:
+			m.RuntimeName = string(value)
+		case 3:
+			m.RuntimeVersion = string(value)
+		}
+		return nil
+	})
+}
+
+func (m *MountRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Attributes)
+	b = appendString(b, 2, m.Secrets)
+	b = appendString(b, 3, m.TargetPath)
+	b = appendString(b, 4, m.Permission)
+	for _, v := range m.CurrentObjectVersion {
+		b = appendMessage(b, 5, v.marshal())
+	}
+	return b
+}
+
+func (m *MountRequest) unmarshal(data []byte) error {
+	return consumeFields(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			m.Attributes = string(value)
+		case 2:
+			m.Secrets = string(value)
+		case 3:
+			m.TargetPath = string(value)
+		case 4:
+			m.Permission = string(value)
+		case 5:
+			var v ObjectVersion
+			if err := v.unmarshal(value); err != nil {
+				return err
+			}
+			m.CurrentObjectVersion = append(m.CurrentObjectVersion, v)
+		}
+		return nil
+	})
+}
+
+func (m *MountResponse) marshal() []byte {
+	var b []byte
+	for _, v := range m.ObjectVersion {
+		b = appendMessage(b, 1, v.marshal())
+	}
+	if m.Error != nil {
+		b = appendMessage(b, 2, appendString(nil, 1, m.Error.Code))
+	}
+	for _, f := range m.Files {
+		b = appendMessage(b, 3, f.marshal())
+	}
+	return b
+}
+
+func (m *MountResponse) unmarshal(data []byte) error {
+	return consumeFields(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			var v ObjectVersion
+			if err := v.unmarshal(value); err != nil {
+				return err
+			}
+			m.ObjectVersion = append(m.ObjectVersion, v)
+		case 2:
+			m.Error = &Error{}
+			return consumeFields(value, func(num protowire.Number, value []byte) error {
+				if num == 1 {
+					m.Error.Code = string(value)
+				}
+				return nil
+			})
+		case 3:
+			var f File
+			if err := f.unmarshal(value); err != nil {
+				return err
+			}
+			m.Files = append(m.Files, f)
+		}
+		return nil
+	})
+}
+
+func (v *ObjectVersion) marshal() []byte {
+	b := appendString(nil, 1, v.ID)
+	return appendString(b, 2, v.Version)
+}
+
+func (v *ObjectVersion) unmarshal(data []byte) error {
+	return consumeFields(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			v.ID = string(value)
+		case 2:
+			v.Version = string(value)
+		}
+		return nil
+	})
+}
+
+func (f *File) marshal() []byte {
+	b := appendString(nil, 1, f.Path)
+	if f.Mode != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(f.Mode))
+	}
+	if len(f.Contents) > 0 {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, f.Contents)
+	}
+	return b
+}
+
+func (f *File) unmarshal(data []byte) error {
+	return consumeFields(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			f.Path = string(value)
+		case 2:
+			mode, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			f.Mode = int32(mode)
+		case 3:
+			f.Contents = append([]byte(nil), value...)
+		}
+		return nil
+	})
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// consumeFields calls fn with the number and value of each field in data: the contents of
+// a length-delimited field, the encoded varint of a varint field. Fields of other types
+// are skipped, as none of the provider messages has them.
+func consumeFields(data []byte, fn func(protowire.Number, []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		var value []byte
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			value, data = v, data[n:]
+		case protowire.VarintType:
+			_, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			value, data = data[:n], data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		if err := fn(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Package csiprovider is a Secrets Store CSI driver provider: it lets pods mount the
+// material and metadata of Polykey keys as files through a SecretProviderClass, in place
+// of static Kubernetes Secrets. The driver mounts the volume through Mount and, with
+// rotation enabled, calls it again on its rotation poll; as every call reads the current
+// version of each key, a rotated key reaches the volume on the next poll.
+package csiprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spounge-ai/polykey/internal/polykeyclient"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+// Parameters and secret keys a SecretProviderClass mount is configured with.
+const (
+	// objectsParameter is the YAML list of objects to mount.
+	objectsParameter = "objects"
+	// podNamespaceAttribute and podNameAttribute identify the pod in the attributes.
+	podNamespaceAttribute = "csi.storage.k8s.io/pod.namespace"
+	podNameAttribute      = "csi.storage.k8s.io/pod.name"
+	// clientIDSecret and apiKeySecret are the keys of the client credentials in the
+	// nodePublishSecretRef Secret.
+	clientIDSecret = "clientId"
+	apiKeySecret   = "apiKey"
+)
+
+// Contents of a mounted object.
+const (
+	ContentMaterial = "material"
+	ContentMetadata = "metadata"
+)
+
+// Object is a file of a SecretProviderClass volume.
+type Object struct {
+	KeyID string `yaml:"keyId"`
+	// FileName is the path of the file in the volume, the key ID when empty.
+	FileName string `yaml:"fileName"`
+	// Content is material, the default, or metadata, the key's metadata as JSON.
+	Content string `yaml:"content"`
+	// Version pins the key version, the current one when zero.
+	Version int32 `yaml:"version"`
+}
+
+// Credentials are the client credentials a mount authenticates with.
+type Credentials struct {
+	ClientID string
+	APIKey   string
+}
+
+// Provider serves the CSI provider API from a Polykey server.
+type Provider struct {
+	keys     pk.PolykeyServiceClient
+	sources  *polykeyclient.TokenSources
+	fallback Credentials
+	version  string
+	logger   *slog.Logger
+}
+
+// New creates a provider reading keys over conn. Mounts without credentials in their
+// nodePublishSecretRef authenticate with fallback, when it is set.
+func New(conn grpc.ClientConnInterface, fallback Credentials, version string, logger *slog.Logger) *Provider {
+	return &Provider{
+		keys:     pk.NewPolykeyServiceClient(conn),
+		sources:  polykeyclient.NewTokenSources(conn),
+		fallback: fallback,
+		version:  version,
+		logger:   logger,
+	}
+}
+
+func (p *Provider) Version(ctx context.Context, req *VersionRequest) (*VersionResponse, error) {
+	return &VersionResponse{Version: ProtocolVersion, RuntimeName: "polykey", RuntimeVersion: p.version}, nil
+}
+
+func (p *Provider) Mount(ctx context.Context, req *MountRequest) (*MountResponse, error) {
+	var attributes, secrets map[string]string
+	if err := json.Unmarshal([]byte(req.Attributes), &attributes); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to parse the attributes: %v", err)
+	}
+	if req.Secrets != "" {
+		if err := json.Unmarshal([]byte(req.Secrets), &secrets); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to parse the secrets: %v", err)
+		}
+	}
+	var mode int32
+	if err := json.Unmarshal([]byte(req.Permission), &mode); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to parse the permission: %v", err)
+	}
+	objects, err := parseObjects(attributes[objectsParameter])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	creds := Credentials{ClientID: secrets[clientIDSecret], APIKey: secrets[apiKeySecret]}
+	if creds.ClientID == "" && creds.APIKey == "" {
+		creds = p.fallback
+	}
+	if creds.ClientID == "" || creds.APIKey == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "no client credentials: set %s and %s in the nodePublishSecretRef Secret", clientIDSecret, apiKeySecret)
+	}
+	tokens, requester, err := p.sources.Get(ctx, creds.ClientID, creds.APIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &MountResponse{}
+	for _, object := range objects {
+		var key *pk.GetKeyResponse
+		err := tokens.Invoke(ctx, func(ctx context.Context) error {
+			var err error
+			key, err = p.keys.GetKey(ctx, &pk.GetKeyRequest{KeyId: object.KeyID, Version: object.Version, RequesterContext: requester})
+			return err
+		})
+		if err != nil {
+			p.logger.WarnContext(ctx, "csi mount failed", "keyId", object.KeyID, "pod", attributes[podNamespaceAttribute]+"/"+attributes[podNameAttribute], "error", err)
+			return nil, err
+		}
+		contents := key.GetKeyMaterial().GetEncryptedKeyData()
+		if object.Content == ContentMetadata {
+			if contents, err = protojson.Marshal(key.GetMetadata()); err != nil {
+				return nil, err
+			}
+		}
+		resp.Files = append(resp.Files, File{Path: object.FileName, Mode: mode, Contents: contents})
+		resp.ObjectVersion = append(resp.ObjectVersion, ObjectVersion{ID: object.FileName, Version: strconv.Itoa(int(key.GetMetadata().GetVersion()))})
+	}
+	p.logger.InfoContext(ctx, "csi volume mounted", "pod", attributes[podNamespaceAttribute]+"/"+attributes[podNameAttribute], "clientId", creds.ClientID, "objects", len(objects))
+	return resp, nil
+}
+
+// parseObjects parses and checks the objects parameter of a SecretProviderClass.
+func parseObjects(parameter string) ([]Object, error) {
+	if parameter == "" {
+		return nil, fmt.Errorf("the %s parameter is empty", objectsParameter)
+	}
+	var objects []Object
+	if err := yaml.Unmarshal([]byte(parameter), &objects); err != nil {
+		return nil, fmt.Errorf("failed to parse the %s parameter: %w", objectsParameter, err)
+	}
+	seen := make(map[string]bool, len(objects))
+	for i := range objects {
+		object := &objects[i]
+		if object.KeyID == "" {
+			return nil, fmt.Errorf("object %d has no keyId", i)
+		}
+		if object.FileName == "" {
+			object.FileName = object.KeyID
+		}
+		if !filepath.IsLocal(object.FileName) {
+			return nil, fmt.Errorf("object %d: fileName %q leaves the volume", i, object.FileName)
+		}
+		if seen[object.FileName] {
+			return nil, fmt.Errorf("object %d: fileName %q is used twice", i, object.FileName)
+		}
+		seen[object.FileName] = true
+		switch object.Content {
+		case "":
+			object.Content = ContentMaterial
+		case ContentMaterial, ContentMetadata:
+		default:
+			return nil, fmt.Errorf("object %d: content must be %s or %s", i, ContentMaterial, ContentMetadata)
+		}
+	}
+	return objects, nil
+}
@@ -15,14 +15,143 @@ type AuditEvent struct {
 	Operation        string
 	KeyID            string
 	AuthDecisionID   string
+	CorrelationID    string
 	Success          bool
 	Error            string
 	Timestamp        time.Time
 	RequestMetadata  map[string]string
+	// PeerIP, UserAgent and CertFingerprint describe the caller's connection: its IP
+	// address, user-agent header and the SHA-256 of its mTLS client certificate.
+	PeerIP           string
+	UserAgent        string
+	CertFingerprint  string
+	// Changes lists what the operation changed, for operations that modify a key's
+	// metadata and are audited in full.
+	Changes          []AuditChange
+	// Sequence, PrevHash and Hash place the event in the tamper-evident audit chain.
+	// They are assigned by the AuditRepository when the event is stored.
+	Sequence         int64
+	PrevHash         string
+	Hash             string
+}
+
+// AuditChange is one field changed by an audited operation, with its values before and
+// after; a value is empty when the field was added or removed.
+type AuditChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+type auditChangesKey struct{}
+
+// NewContextWithAuditChanges returns a context carrying the changes made by the
+// operation about to be audited.
+func NewContextWithAuditChanges(ctx context.Context, changes []AuditChange) context.Context {
+	return context.WithValue(ctx, auditChangesKey{}, changes)
+}
+
+// AuditChangesFromContext returns the changes set by NewContextWithAuditChanges, if any.
+func AuditChangesFromContext(ctx context.Context) []AuditChange {
+	changes, _ := ctx.Value(auditChangesKey{}).([]AuditChange)
+	return changes
 }
 
 type AuditRepository interface {
 	CreateAuditEvent(ctx context.Context, event *AuditEvent) error
 	CreateAuditEventsBatch(ctx context.Context, events []*AuditEvent) error
 	GetAuditHistory(ctx context.Context, keyID string, limit int) ([]*AuditEvent, error)
-}
\ No newline at end of file
+	VerifyAuditIntegrity(ctx context.Context) (*AuditIntegrityReport, error)
+	QueryAuditEvents(ctx context.Context, query AuditQuery) ([]*AuditEvent, error)
+
+	// ListArchivableAuditEvents returns up to limit of the oldest events not yet archived
+	// that were logged before the cutoff, in chain order.
+	ListArchivableAuditEvents(ctx context.Context, before time.Time, limit int) ([]*AuditEvent, error)
+	// RecordAuditArchive registers an archive and deletes the events it holds.
+	RecordAuditArchive(ctx context.Context, archive *AuditArchive, eventIDs []string) error
+	// ListAuditArchives returns the archives holding events logged in [from, to).
+	ListAuditArchives(ctx context.Context, from, to time.Time) ([]*AuditArchive, error)
+	// RestoreAuditEvents puts archived events back until the given time and returns how
+	// many were not already present.
+	RestoreAuditEvents(ctx context.Context, archive *AuditArchive, events []*AuditEvent, until time.Time) (int64, error)
+	// PurgeRestoredAuditEvents deletes restored events whose restore ended before now.
+	PurgeRestoredAuditEvents(ctx context.Context, now time.Time) (int64, error)
+}
+
+// AuditArchive describes a range of audit events moved out of the database into an
+// AuditArchiveStore. Sequences are zero for archives of events older than the chain.
+// FirstTimestamp and LastTimestamp are the earliest and latest event times it holds.
+// RestoredUntil is set while its events have been put back for an investigation.
+type AuditArchive struct {
+	ObjectKey      string
+	FirstSequence  int64
+	LastSequence   int64
+	LastHash       string
+	FirstTimestamp time.Time
+	LastTimestamp  time.Time
+	EventCount     int
+	ArchivedAt     time.Time
+	RestoredUntil  *time.Time
+}
+
+// AuditArchiveStore keeps archived audit events. GetAuditArchive fails with
+// ErrAuditArchiveNotReady from the errors package while an object in cold storage is
+// being made readable; the caller is expected to try again later.
+type AuditArchiveStore interface {
+	PutAuditArchive(ctx context.Context, objectKey string, events []*AuditEvent) error
+	GetAuditArchive(ctx context.Context, objectKey string) ([]*AuditEvent, error)
+}
+
+// AuditRestoreResult is the outcome of restoring the archives covering a time range.
+// Pending lists the archives still being retrieved from cold storage.
+type AuditRestoreResult struct {
+	Archives       int
+	RestoredEvents int64
+	Pending        []string
+	RestoredUntil  time.Time
+}
+
+// AuditQuery selects audit events, newest first. Empty fields and zero times do not
+// filter; From is inclusive and To exclusive. After, when set, continues a previous
+// page from the last event it returned.
+type AuditQuery struct {
+	ClientIdentity string
+	KeyID          string
+	Operation      string
+	Success        *bool
+	From           time.Time
+	To             time.Time
+	After          *AuditCursor
+	Limit          int
+}
+
+// AuditCursor is the position of an event in the newest-first audit order.
+type AuditCursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+// AuditIntegrityReport is the outcome of walking the audit hash chain. When Valid is
+// false, BrokenAtSequence is the first sequence number at which the chain does not hold
+// and Reason says why. ArchivedSequence is the last sequence moved to the archive; the
+// walk starts after it.
+type AuditIntegrityReport struct {
+	Valid            bool
+	CheckedEvents    int64
+	ArchivedSequence int64
+	FirstSequence    int64
+	LastSequence     int64
+	HeadSequence     int64
+	BrokenAtSequence int64
+	Reason           string
+}
+
+// AuditSink receives audit events in addition to the AuditRepository, for export to
+// systems such as a SIEM. Publish gets events in the order they were logged; a sink is
+// responsible for its own retries, and an error it returns is logged, never retried by
+// the caller.
+type AuditSink interface {
+	Name() string
+	Publish(ctx context.Context, events []*AuditEvent) error
+	Close() error
+}
@@ -7,16 +7,26 @@ import (
 )
 
 // AuthenticatedUser represents a user that has been authenticated.
-// It contains the user's ID and a list of permissions.
+// It contains the user's ID, namespace, a list of permissions and the authentication
+// methods (amr) and context class (acr) asserted by the token.
 type AuthenticatedUser struct {
-	ID          string
-	Permissions []string
+	ID               string
+	Namespace        string
+	Permissions      []string
+	AuthMethods      []string
+	AuthContextClass string
 }
 
+// ReasonStepUpRequired is the authorization reason returned when a key requires
+// a step-up assertion the caller's token does not carry.
+const ReasonStepUpRequired = "step_up_required"
+
 type contextKey string
 
 const (
-	userContextKey = contextKey("user")
+	userContextKey      = contextKey("user")
+	listScopeContextKey = contextKey("list_scope")
+	keyCheckContextKey  = contextKey("key_check")
 )
 
 // NewContextWithUser creates a new context with the authenticated user.
@@ -30,7 +40,56 @@ func UserFromContext(ctx context.Context) (*AuthenticatedUser, bool) {
 	return user, ok
 }
 
+// NewContextWithListScope limits the keys listed with the returned context to those whose
+// latest version lists authorizedContext among its authorized contexts.
+func NewContextWithListScope(ctx context.Context, authorizedContext string) context.Context {
+	return context.WithValue(ctx, listScopeContextKey, authorizedContext)
+}
+
+// ListScopeFromContext returns the authorized context listings made with ctx are limited
+// to. ok is false when they see every key of the namespace.
+func ListScopeFromContext(ctx context.Context) (authorizedContext string, ok bool) {
+	authorizedContext, ok = ctx.Value(listScopeContextKey).(string)
+	return authorizedContext, ok
+}
+
+// KeyCheck authorizes the caller for one key of a batch request, as the single-key RPC
+// for that key would, and returns the error to report for the item when it is denied.
+type KeyCheck func(ctx context.Context, keyID KeyID) error
+
+// NewContextWithKeyCheck makes the batch reads made with the returned context authorize
+// each key with check, since the batch itself is authorized without a key.
+func NewContextWithKeyCheck(ctx context.Context, check KeyCheck) context.Context {
+	return context.WithValue(ctx, keyCheckContextKey, check)
+}
+
+// KeyCheckFromContext returns the per-key check of ctx, if it has one.
+func KeyCheckFromContext(ctx context.Context) (KeyCheck, bool) {
+	check, ok := ctx.Value(keyCheckContextKey).(KeyCheck)
+	return check, ok
+}
+
 // Authorizer defines the interface for an authorization service.
 type Authorizer interface {
 	Authorize(ctx context.Context, reqContext *pk.RequesterContext, attrs *pk.AccessAttributes, operation string, keyID KeyID) (bool, string)
 }
+
+// RoleManager is implemented by authorizers whose roles, the operations each role in a
+// token allows, can be changed while the service runs. Changes are kept in memory only;
+// a restart goes back to the roles in the config.
+type RoleManager interface {
+	// Roles returns the allowed operations of every role.
+	Roles() map[string][]string
+	// PutRole defines role, or replaces its allowed operations.
+	PutRole(role string, allowedOperations []string)
+	// DeleteRole removes role and reports whether it existed.
+	DeleteRole(role string) bool
+}
+
+// ListScoper is implemented by authorizers that limit listings to the keys the caller
+// could read, since calls such as ListKeys are authorized without a key to check.
+type ListScoper interface {
+	// ListScope returns the authorized context a key must list for the caller of ctx to
+	// see it, and false when the caller sees every key, as admins do.
+	ListScope(ctx context.Context) (authorizedContext string, scoped bool)
+}
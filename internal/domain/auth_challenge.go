@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Metadata an Authenticate request may carry to prove it is fresh: a nonce used once, the
+// time it was signed in Unix seconds, and the signature of both, an HMAC-SHA256 keyed
+// with the API key over "<client ID>\n<nonce>\n<timestamp>".
+const (
+	AuthenticateNonceHeader     = "authenticate-nonce"
+	AuthenticateTimestampHeader = "authenticate-timestamp"
+	AuthenticateSignatureHeader = "authenticate-signature"
+)
+
+// AuthenticateOTPHeader is the metadata carrying the client's current one-time password,
+// which Authenticate records in the token as the otp authentication method.
+const AuthenticateOTPHeader = "authenticate-otp"
+
+// AuthenticateChallenge is the nonce, timestamp and signature an Authenticate request
+// carries.
+type AuthenticateChallenge struct {
+	Nonce     string
+	Timestamp string
+	Signature string
+}
+
+// SignAuthenticateChallenge returns the signature of nonce and timestamp for clientID
+// with apiKey.
+func SignAuthenticateChallenge(apiKey, clientID, nonce, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(apiKey))
+	mac.Write([]byte(clientID + "\n" + nonce + "\n" + timestamp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
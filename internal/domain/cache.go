@@ -0,0 +1,11 @@
+package domain
+
+import "context"
+
+// CacheFlusher is implemented by components that cache data they read elsewhere, so that
+// operators can drop stale entries without waiting for them to expire.
+type CacheFlusher interface {
+	FlushCache(ctx context.Context)
+	// FlushKey drops what is cached for one key, as after a change to who may use it.
+	FlushKey(ctx context.Context, id KeyID)
+}
@@ -0,0 +1,21 @@
+package domain
+
+// CircuitBreakerStatus describes a circuit breaker at one point in time.
+type CircuitBreakerStatus struct {
+	Name     string
+	State    string
+	Failures int64
+	Trips    int64
+	// Forced is true while the breaker is held open by a manual trip.
+	Forced bool
+}
+
+// CircuitBreakerControl lets operators inspect a circuit breaker and override it
+// during incidents.
+type CircuitBreakerControl interface {
+	Status() CircuitBreakerStatus
+	// Trip opens the breaker and keeps it open until Reset.
+	Trip()
+	// Reset closes the breaker, also ending a manual trip.
+	Reset()
+}
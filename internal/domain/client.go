@@ -1,12 +1,25 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Client represents a registered API client and its permissions.
 type Client struct {
 	ID           string   `yaml:"id"`
 	HashedAPIKey string   `yaml:"hashed_api_key"`
 	Permissions  []string `yaml:"permissions"`
+	// Namespace is the only namespace whose keys the client can see.
+	Namespace string `yaml:"namespace"`
+	// Tier is the client's service tier, which sets the lifetime of its tokens. Empty
+	// for clients without one.
+	Tier KeyTier `yaml:"tier"`
+	// TokenTTL overrides the lifetime of the client's tokens when not zero.
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	// TOTPSecret is the base32 secret of the client's one-time passwords, empty when it
+	// has none. Authenticating with a one-time password satisfies step-up.
+	TOTPSecret string `yaml:"totp_secret"`
 }
 
 // ClientStore defines the interface for retrieving client credentials.
@@ -14,3 +27,13 @@ type Client struct {
 type ClientStore interface {
 	FindClientByID(ctx context.Context, clientID string) (*Client, error)
 }
+
+// ClientManager is implemented by client stores whose clients can be changed while the
+// service runs. Changes apply to the next authentication of the client; tokens already
+// issued stay valid until they expire.
+type ClientManager interface {
+	ListClients(ctx context.Context) ([]Client, error)
+	// PutClient registers client, or replaces the client with its ID.
+	PutClient(ctx context.Context, client Client) error
+	DeleteClient(ctx context.Context, clientID string) error
+}
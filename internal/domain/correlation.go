@@ -0,0 +1,18 @@
+package domain
+
+import "context"
+
+type correlationIDKey struct{}
+
+// NewContextWithCorrelationID returns a context carrying the request's correlation ID.
+func NewContextWithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationIDFromContext returns the correlation ID of the request, or "" if none is set.
+func CorrelationIDFromContext(ctx context.Context) string {
+	if correlationID, ok := ctx.Value(correlationIDKey{}).(string); ok {
+		return correlationID
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+// KeyEventType identifies a lifecycle change of a key.
+type KeyEventType string
+
+const (
+	KeyEventCreated KeyEventType = "created"
+	KeyEventRotated KeyEventType = "rotated"
+	KeyEventRevoked KeyEventType = "revoked"
+	// KeyEventRestored reports that a revocation was undone.
+	KeyEventRestored KeyEventType = "restored"
+	KeyEventExpired  KeyEventType = "expired"
+	// KeyEventVersionExpired reports that a rotated version reached the end of its grace
+	// period and can no longer be read. The key itself is unaffected.
+	KeyEventVersionExpired KeyEventType = "version_expired"
+	// KeyEventExpiring is an advance notice that a key will expire soon.
+	KeyEventExpiring KeyEventType = "expiring"
+)
+
+// KeyEvent describes a committed change to a key.
+type KeyEvent struct {
+	Type          KeyEventType
+	KeyID         string
+	Namespace     string
+	Version       int32
+	Metadata      *pk.KeyMetadata
+	Actor         string
+	CorrelationID string
+	OccurredAt    time.Time
+}
+
+// KeyEventPublisher delivers key events to interested subscribers.
+type KeyEventPublisher interface {
+	Publish(ctx context.Context, event KeyEvent)
+}
+
+// KeyEventSubscriber hands out subscriptions to key events.
+// The returned function must be called to release the subscription.
+type KeyEventSubscriber interface {
+	Subscribe(ctx context.Context) (<-chan KeyEvent, func())
+}
+
+// OutboxKeyEvent is a key event recorded in the transaction that caused it, awaiting
+// delivery.
+type OutboxKeyEvent struct {
+	// Seq orders the events of a consumer.
+	Seq int64
+	// ID identifies the event across repeated deliveries.
+	ID string
+	KeyEvent
+}
+
+// KeyEventBacklog describes the key events a consumer has not delivered yet.
+type KeyEventBacklog struct {
+	Pending int64
+	// Oldest is when the oldest pending event was recorded, nil when none is pending.
+	Oldest *time.Time
+}
+
+// KeyEventOutbox holds the key events recorded for one consumer until it has delivered
+// them. Events are recorded with the change that caused them, so none is lost when the
+// process stops between the change and the delivery.
+type KeyEventOutbox interface {
+	// Consumer names the consumer.
+	Consumer() string
+	// Register makes key changes record events for the consumer.
+	Register(ctx context.Context) error
+	// Unregister stops recording events for the consumer and forgets those pending.
+	Unregister(ctx context.Context) error
+	// Pending returns up to limit undelivered events, oldest first.
+	Pending(ctx context.Context, limit int) ([]OutboxKeyEvent, error)
+	// Delivered forgets the events with the given sequence numbers.
+	Delivered(ctx context.Context, seqs []int64) error
+	Backlog(ctx context.Context) (KeyEventBacklog, error)
+}
+
+// KeyEventSink delivers outbox events to a system outside the service.
+type KeyEventSink interface {
+	Name() string
+	// Deliver returns once the receiving system has accepted the event.
+	Deliver(ctx context.Context, event OutboxKeyEvent) error
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
@@ -9,6 +10,8 @@ import (
 
 type Key struct {
     ID           KeyID
+    // Namespace isolates the keys of one tenant from those of the others.
+    Namespace    string
     Version      int32
     Metadata     *pk.KeyMetadata
     EncryptedDEK []byte
@@ -17,6 +20,14 @@ type Key struct {
     CreatedAt    time.Time
     UpdatedAt    time.Time
     RevokedAt    *time.Time
+    // GraceExpiresAt is when a rotated version stops being readable.
+    GraceExpiresAt *time.Time
+    // KMSProvider names the KMS provider that wrapped EncryptedDEK. It is empty for
+    // versions written before providers were recorded.
+    KMSProvider string
+    // Alias is the first, in order, of the aliases bound to the key. Only listings fill
+    // it in.
+    Alias string
 }
 
 type KeyTier string
@@ -34,6 +45,9 @@ const (
 	KeyStatusActive   KeyStatus = "active"
 	KeyStatusRotated  KeyStatus = "rotated"
 	KeyStatusRevoked  KeyStatus = "revoked"
+	KeyStatusExpired  KeyStatus = "expired"
+	KeyStatusPendingDeletion KeyStatus = "pending_deletion"
+	KeyStatusDestroyed       KeyStatus = "destroyed"
 )
 
 
@@ -44,15 +58,75 @@ type KeyRepository interface {
 	GetKeyMetadataByVersion(ctx context.Context, id KeyID, version int32) (*pk.KeyMetadata, error)
 	CreateKey(ctx context.Context, key *Key) error
 	CreateBatchKeys(ctx context.Context, keys []*Key) error
-	ListKeys(ctx context.Context, lastCreatedAt *time.Time, limit int) ([]*Key, error)
+	// ListKeys returns up to limit keys that match filter, at their latest version, in
+	// the order of page, starting after its cursor when it is set.
+	ListKeys(ctx context.Context, filter KeyFilter, page KeyPage, limit int) ([]*Key, error)
 	UpdateKeyMetadata(ctx context.Context, id KeyID, metadata *pk.KeyMetadata) error
-	RotateKey(ctx context.Context, id KeyID, newEncryptedDEK []byte) (*Key, error)
+	// UpdateKeyMetadataIfOwner replaces the metadata of the latest version like
+	// UpdateKeyMetadata, provided that version is still version and is still owned by
+	// owner. It fails with ErrConflict when either changed since the caller read the key.
+	UpdateKeyMetadataIfOwner(ctx context.Context, id KeyID, version int32, owner string, metadata *pk.KeyMetadata) error
+	// RotateKey adds a new active version and marks the previous one rotated. The previous
+	// version stays readable until graceDeadline; the zero time means no deadline.
+	RotateKey(ctx context.Context, id KeyID, newEncryptedDEK []byte, graceDeadline time.Time) (*Key, error)
 	RevokeKey(ctx context.Context, id KeyID) error
+	// RestoreKey moves the latest version of a revoked key back to active, provided it
+	// was revoked at or after revokedSince, and returns it.
+	RestoreKey(ctx context.Context, id KeyID, revokedSince time.Time) (*Key, error)
 	GetKeyVersions(ctx context.Context, id KeyID) ([]*Key, error)
+	// CountKeys returns how many keys of the namespace have not been destroyed.
+	CountKeys(ctx context.Context, namespace string) (int, error)
 	Exists(ctx context.Context, id KeyID) (bool, error)
 	GetBatchKeys(ctx context.Context, ids []KeyID) ([]*Key, error)
 	GetBatchKeyMetadata(ctx context.Context, ids []KeyID) ([]*pk.KeyMetadata, error)
 	RevokeBatchKeys(ctx context.Context, ids []KeyID) error
 	UpdateBatchKeyMetadata(ctx context.Context, updates []*Key) error
+	// ExpireKeys marks as expired up to limit active keys whose ExpiresAt is at or before
+	// asOf, together with rotated versions whose grace period ended by then, and returns them.
+	ExpireKeys(ctx context.Context, asOf time.Time, limit int) ([]*Key, error)
+	// ListExpiringKeys returns up to limit active keys that come after the cursor and
+	// expire at or before to, in the order of KeyExpiryCursor.
+	ListExpiringKeys(ctx context.Context, after KeyExpiryCursor, to time.Time, limit int) ([]*Key, error)
+	// PruneKeyVersions removes up to limit versions that retention allows to go, archiving
+	// them if it says so, and returns them.
+	PruneKeyVersions(ctx context.Context, retention KeyVersionRetention, limit int) ([]*Key, error)
+}
+
+// KeyFilter selects keys by the latest version of each. Empty fields match any key.
+type KeyFilter struct {
+	KeyTypes           []pk.KeyType
+	Statuses           []KeyStatus
+	CreatorIdentity    string
+	DataClassification string
+	// AuthorizedContext keeps the keys that list it among their authorized contexts.
+	AuthorizedContext string
+}
+
+// Matches reports whether key passes f, for repositories that filter in memory.
+func (f KeyFilter) Matches(key *Key) bool {
+	md := key.Metadata
+	if len(f.KeyTypes) > 0 && !slices.Contains(f.KeyTypes, md.GetKeyType()) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, key.Status) {
+		return false
+	}
+	if f.CreatorIdentity != "" && md.GetCreatorIdentity() != f.CreatorIdentity {
+		return false
+	}
+	if f.DataClassification != "" && md.GetDataClassification() != f.DataClassification {
+		return false
+	}
+	return f.AuthorizedContext == "" || slices.Contains(md.GetAuthorizedContexts(), f.AuthorizedContext)
+}
+
+// KeyVersionRetention selects the versions that can be removed: expired versions, and
+// rotated ones whose grace period ended, that went out of service before Before and are
+// not among the newest KeepVersions of their key.
+type KeyVersionRetention struct {
+	KeepVersions int
+	Before       time.Time
+	// Archive moves the versions aside instead of deleting them.
+	Archive bool
 }
  
\ No newline at end of file
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// KeyAccess is the read activity aggregated for one key between two flushes.
+type KeyAccess struct {
+	KeyID          KeyID
+	Count          int64
+	LastAccessedAt time.Time
+}
+
+// KeyAccessRecorder counts reads of key material. RecordAccess must not block the caller.
+type KeyAccessRecorder interface {
+	RecordAccess(id KeyID)
+}
+
+// KeyAccessRepository adds aggregated reads to the AccessCount and LastAccessedAt
+// metadata of the latest version of each key.
+type KeyAccessRepository interface {
+	RecordKeyAccesses(ctx context.Context, accesses []KeyAccess) error
+}
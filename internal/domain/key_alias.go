@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+// keyAliasPattern is what an alias may look like: up to 128 letters, digits and
+// "._/-", starting with a letter or digit.
+var keyAliasPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,127}$`)
+
+// ValidateKeyAlias checks that alias can name a key.
+func ValidateKeyAlias(alias string) error {
+	if !keyAliasPattern.MatchString(alias) {
+		return fmt.Errorf("alias %q must be 1 to 128 letters, digits or ._/-, starting with a letter or digit", alias)
+	}
+	return nil
+}
+
+// KeySpec is the desired state of a key provisioned by alias, as a declarative tool
+// such as Terraform applies it. Description and Tags replace the key's own; the key type
+// cannot change once the key exists.
+type KeySpec struct {
+	Alias       string
+	KeyType     pk.KeyType
+	Description string
+	Tags        map[string]string
+	// ETag, when set, is the etag the key had when the caller read it. The spec is only
+	// applied if the key is still in that state.
+	ETag string
+}
+
+// ProvisionedKey is the state of a key provisioned by alias.
+type ProvisionedKey struct {
+	Alias    string
+	Metadata *pk.KeyMetadata
+	ETag     string
+	// Created tells that applying the spec created the key.
+	Created bool
+}
+
+// KeyETag returns the etag of the current version of a key. It changes whenever the key
+// is rotated, revoked or its metadata updated.
+func KeyETag(metadata *pk.KeyMetadata) string {
+	h := sha256.New()
+	h.Write([]byte(metadata.GetKeyId()))
+	var buf [20]byte
+	binary.BigEndian.PutUint32(buf[0:4], uint32(metadata.GetVersion()))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(metadata.GetStatus()))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(metadata.GetUpdatedAt().GetSeconds()))
+	binary.BigEndian.PutUint32(buf[16:20], uint32(metadata.GetUpdatedAt().GetNanos()))
+	h.Write(buf[:])
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// KeyAliasRepository binds aliases to keys, per namespace.
+type KeyAliasRepository interface {
+	// GetKeyAlias returns the key alias is bound to in namespace, or ErrKeyNotFound.
+	GetKeyAlias(ctx context.Context, namespace, alias string) (KeyID, error)
+	// PutKeyAlias binds alias to id in namespace, replacing its binding if it has one.
+	PutKeyAlias(ctx context.Context, namespace, alias string, id KeyID) error
+	// WithKeyAliasLock runs fn holding a lock on alias in namespace, so that the applies
+	// of an alias run one at a time across instances.
+	WithKeyAliasLock(ctx context.Context, namespace, alias string, fn func(context.Context) error) error
+}
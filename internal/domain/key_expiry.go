@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// KeyExpiryCursor orders keys by ExpiresAt and then ID, as expiry notices walk them. An
+// empty KeyID stands after every key expiring at ExpiresAt.
+type KeyExpiryCursor struct {
+	ExpiresAt time.Time
+	KeyID     string
+}
+
+// ExpiryCursorOf returns the cursor standing at key. Expiry times are kept to the second.
+func ExpiryCursorOf(key *Key) KeyExpiryCursor {
+	return KeyExpiryCursor{
+		ExpiresAt: time.Unix(key.Metadata.GetExpiresAt().GetSeconds(), 0),
+		KeyID:     key.ID.String(),
+	}
+}
+
+// Before reports whether c comes before other.
+func (c KeyExpiryCursor) Before(other KeyExpiryCursor) bool {
+	if !c.ExpiresAt.Equal(other.ExpiresAt) {
+		return c.ExpiresAt.Before(other.ExpiresAt)
+	}
+	if c.KeyID == "" {
+		return false
+	}
+	return other.KeyID == "" || c.KeyID < other.KeyID
+}
+
+// KeyExpiryNoticeStore keeps how far expiry notices have got, so that a restarted job,
+// or one on another replica after a leader change, neither repeats nor skips them.
+type KeyExpiryNoticeStore interface {
+	// GetExpiryNoticeWatermark returns the last key announced, or the zero cursor if
+	// none was.
+	GetExpiryNoticeWatermark(ctx context.Context) (KeyExpiryCursor, error)
+	SaveExpiryNoticeWatermark(ctx context.Context, watermark KeyExpiryCursor) error
+}
@@ -0,0 +1,146 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// metadataChecksumPrefix names the MAC of a metadata checksum.
+	metadataChecksumPrefix = "hmac-sha256:"
+	// legacyChecksumPrefix names the unkeyed hash of the checksums written before they
+	// were keyed, which anyone able to write a row could recompute.
+	legacyChecksumPrefix = "sha256:"
+)
+
+// MetadataIntegrityKeyLen is the shortest key metadata checksums are computed with.
+const MetadataIntegrityKeyLen = 32
+
+// canonicalMetadata is what a metadata checksum covers: the fields that describe a key
+// and who may use it. The status, version, timestamps of updates and accesses, and
+// access count are left out, as the repository changes them in place without rewriting
+// the metadata.
+type canonicalMetadata struct {
+	KeyID              string            `json:"key_id"`
+	KeyType            int32             `json:"key_type"`
+	CreatedAt          string            `json:"created_at"`
+	ExpiresAt          string            `json:"expires_at"`
+	CreatorIdentity    string            `json:"creator_identity"`
+	AuthorizedContexts []string          `json:"authorized_contexts"`
+	AccessPolicies     map[string]string `json:"access_policies"`
+	Description        string            `json:"description"`
+	Tags               map[string]string `json:"tags"`
+	DataClassification string            `json:"data_classification"`
+	StorageType        int32             `json:"storage_type"`
+}
+
+// MetadataIntegrity computes and verifies the checksums of key metadata: HMAC-SHA256s
+// under a key held in the bootstrap secrets, so that metadata changed in the database
+// cannot be given a checksum that verifies. Every server sharing a database, standbys
+// included, must hold the same key.
+type MetadataIntegrity struct {
+	key []byte
+}
+
+// NewMetadataIntegrity creates a MetadataIntegrity with encodedKey, a base64 key of at
+// least MetadataIntegrityKeyLen bytes.
+func NewMetadataIntegrity(encodedKey string) (*MetadataIntegrity, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metadata integrity key: %w", err)
+	}
+	if len(key) < MetadataIntegrityKeyLen {
+		return nil, fmt.Errorf("metadata integrity key must hold at least %d bytes", MetadataIntegrityKeyLen)
+	}
+	return &MetadataIntegrity{key: key}, nil
+}
+
+// Checksum returns the checksum of metadata, "hmac-sha256:" followed by the hex
+// HMAC-SHA256 of its canonical encoding.
+func (m *MetadataIntegrity) Checksum(metadata *pk.KeyMetadata) string {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write(canonicalMetadataJSON(metadata))
+	return metadataChecksumPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks metadata against its Checksum, failing with ErrMetadataIntegrity when
+// they differ or metadata has none.
+func (m *MetadataIntegrity) Verify(metadata *pk.KeyMetadata) error {
+	stored := metadata.GetMetadataChecksum()
+	if stored == "" {
+		return fmt.Errorf("%w: key %s version %d has no checksum", app_errors.ErrMetadataIntegrity, metadata.GetKeyId(), metadata.GetVersion())
+	}
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(m.Checksum(metadata))) != 1 {
+		return fmt.Errorf("%w: key %s version %d", app_errors.ErrMetadataIntegrity, metadata.GetKeyId(), metadata.GetVersion())
+	}
+	return nil
+}
+
+// NeedsChecksum reports whether metadata was written before checksums were keyed: it
+// has none, or an unkeyed one.
+func NeedsChecksum(metadata *pk.KeyMetadata) bool {
+	return !strings.HasPrefix(metadata.GetMetadataChecksum(), metadataChecksumPrefix)
+}
+
+// VerifyLegacyChecksum checks metadata written before checksums were keyed against its
+// unkeyed checksum, if it has one, before it is given a keyed one. A mismatch fails with
+// ErrMetadataIntegrity.
+func VerifyLegacyChecksum(metadata *pk.KeyMetadata) error {
+	stored := metadata.GetMetadataChecksum()
+	if stored == "" {
+		return nil
+	}
+	sum := sha256.Sum256(canonicalMetadataJSON(metadata))
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(legacyChecksumPrefix+hex.EncodeToString(sum[:]))) != 1 {
+		return fmt.Errorf("%w: key %s version %d", app_errors.ErrMetadataIntegrity, metadata.GetKeyId(), metadata.GetVersion())
+	}
+	return nil
+}
+
+// canonicalMetadataJSON returns the canonical JSON encoding of metadata, in which maps
+// are sorted by key. Empty lists and maps encode as absent ones, as they read back from
+// the metadata column.
+func canonicalMetadataJSON(metadata *pk.KeyMetadata) []byte {
+	canonical := canonicalMetadata{
+		KeyID:              metadata.GetKeyId(),
+		KeyType:            int32(metadata.GetKeyType()),
+		CreatedAt:          canonicalTime(metadata.GetCreatedAt()),
+		ExpiresAt:          canonicalTime(metadata.GetExpiresAt()),
+		CreatorIdentity:    metadata.GetCreatorIdentity(),
+		AuthorizedContexts: metadata.GetAuthorizedContexts(),
+		AccessPolicies:     metadata.GetAccessPolicies(),
+		Description:        metadata.GetDescription(),
+		Tags:               metadata.GetTags(),
+		DataClassification: metadata.GetDataClassification(),
+		StorageType:        int32(metadata.GetStorageType()),
+	}
+	if len(canonical.AuthorizedContexts) == 0 {
+		canonical.AuthorizedContexts = nil
+	}
+	if len(canonical.AccessPolicies) == 0 {
+		canonical.AccessPolicies = nil
+	}
+	if len(canonical.Tags) == 0 {
+		canonical.Tags = nil
+	}
+	data, _ := json.Marshal(canonical)
+	return data
+}
+
+func canonicalTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().UTC().Format(time.RFC3339Nano)
+}
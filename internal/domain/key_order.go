@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// KeySortField is a field key listings can be ordered by.
+type KeySortField string
+
+const (
+	KeySortCreatedAt      KeySortField = "created_at"
+	KeySortUpdatedAt      KeySortField = "updated_at"
+	KeySortLastAccessedAt KeySortField = "last_accessed_at"
+	KeySortAlias          KeySortField = "alias"
+)
+
+// KeySortFields are the fields key listings can be ordered by.
+var KeySortFields = []KeySortField{KeySortCreatedAt, KeySortUpdatedAt, KeySortLastAccessedAt, KeySortAlias}
+
+// KeyOrder is the order of a key listing. Keys with the same value of Field are ordered
+// by ID, in the same direction.
+type KeyOrder struct {
+	Field      KeySortField `json:"field"`
+	Descending bool         `json:"descending,omitempty"`
+}
+
+// DefaultKeyOrder lists the newest keys first.
+var DefaultKeyOrder = KeyOrder{Field: KeySortCreatedAt, Descending: true}
+
+// ParseKeyOrder parses an order such as "alias" or "updated_at desc": one of
+// KeySortFields, optionally followed by asc, the default, or desc. The empty order is
+// DefaultKeyOrder.
+func ParseKeyOrder(s string) (KeyOrder, error) {
+	parts := strings.Fields(s)
+	if len(parts) == 0 {
+		return DefaultKeyOrder, nil
+	}
+	order := KeyOrder{Field: KeySortField(parts[0])}
+	if !slices.Contains(KeySortFields, order.Field) {
+		return KeyOrder{}, fmt.Errorf("keys cannot be ordered by %q", parts[0])
+	}
+	if len(parts) > 2 {
+		return KeyOrder{}, fmt.Errorf("order %q must be a field and an optional direction", s)
+	}
+	if len(parts) == 2 {
+		switch strings.ToLower(parts[1]) {
+		case "asc":
+		case "desc":
+			order.Descending = true
+		default:
+			return KeyOrder{}, fmt.Errorf("order direction %q must be asc or desc", parts[1])
+		}
+	}
+	return order, nil
+}
+
+// OrDefault returns o, or DefaultKeyOrder when o is the zero order.
+func (o KeyOrder) OrDefault() KeyOrder {
+	if o.Field == "" {
+		return DefaultKeyOrder
+	}
+	return o
+}
+
+// NeverAccessed is the sort value of the last access of keys that were never accessed,
+// which sort before every key that was.
+const NeverAccessed = "-infinity"
+
+// KeyCursor is where a key listing resumes: the sort value and the ID of the last key
+// of the previous page. Times are RFC 3339 in UTC; a key without alias has the empty
+// alias.
+type KeyCursor struct {
+	Value string
+	ID    KeyID
+}
+
+// CursorAfter returns the cursor of the keys listed in o after key.
+func (o KeyOrder) CursorAfter(key *Key) KeyCursor {
+	cursor := KeyCursor{ID: key.ID}
+	switch o.OrDefault().Field {
+	case KeySortCreatedAt:
+		cursor.Value = key.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case KeySortUpdatedAt:
+		cursor.Value = key.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case KeySortLastAccessedAt:
+		// Last accesses are ordered to the second.
+		cursor.Value = NeverAccessed
+		if accessed := key.Metadata.GetLastAccessedAt(); accessed != nil {
+			cursor.Value = time.Unix(accessed.GetSeconds(), 0).UTC().Format(time.RFC3339)
+		}
+	case KeySortAlias:
+		cursor.Value = key.Alias
+	}
+	return cursor
+}
+
+// KeyPage selects a page of a key listing.
+type KeyPage struct {
+	// Order is the order of the listing; the zero order is DefaultKeyOrder.
+	Order KeyOrder
+	// After is the cursor of the last key of the previous page, nil for the first page.
+	After *KeyCursor
+}
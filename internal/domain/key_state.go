@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+// keyStatusTransitions lists, for each status, the statuses a key may move to next.
+// Destroyed is terminal. Revoked to active is only reachable through RestoreKey, which
+// additionally bounds it by the restore window.
+var keyStatusTransitions = map[KeyStatus][]KeyStatus{
+	KeyStatusActive:          {KeyStatusRotated, KeyStatusRevoked, KeyStatusExpired, KeyStatusPendingDeletion},
+	KeyStatusRotated:         {KeyStatusExpired, KeyStatusRevoked},
+	KeyStatusExpired:         {KeyStatusRevoked, KeyStatusPendingDeletion},
+	KeyStatusRevoked:         {KeyStatusActive, KeyStatusPendingDeletion},
+	KeyStatusPendingDeletion: {KeyStatusActive, KeyStatusDestroyed},
+}
+
+// CanTransitionTo reports whether a key in status s may move to next.
+func (s KeyStatus) CanTransitionTo(next KeyStatus) bool {
+	return slices.Contains(keyStatusTransitions[s], next)
+}
+
+// ValidateKeyTransition returns a *KeyTransitionError if from may not move to to.
+func ValidateKeyTransition(from, to KeyStatus) error {
+	if from.CanTransitionTo(to) {
+		return nil
+	}
+	return &KeyTransitionError{From: from, To: to}
+}
+
+// KeyStatusesTransitioningTo returns every status from which a key may move to to,
+// for use as a guard in conditional updates.
+func KeyStatusesTransitioningTo(to KeyStatus) []KeyStatus {
+	var from []KeyStatus
+	for status, next := range keyStatusTransitions {
+		if slices.Contains(next, to) {
+			from = append(from, status)
+		}
+	}
+	slices.Sort(from)
+	return from
+}
+
+// keyStatusToProto folds the domain statuses onto the coarser published enum. Versions
+// that can no longer be read for new work but are not revoked report as deprecated.
+var keyStatusToProto = map[KeyStatus]pk.KeyStatus{
+	KeyStatusActive:          pk.KeyStatus_KEY_STATUS_ACTIVE,
+	KeyStatusRotated:         pk.KeyStatus_KEY_STATUS_DEPRECATED,
+	KeyStatusExpired:         pk.KeyStatus_KEY_STATUS_DEPRECATED,
+	KeyStatusRevoked:         pk.KeyStatus_KEY_STATUS_REVOKED,
+	KeyStatusPendingDeletion: pk.KeyStatus_KEY_STATUS_REVOKED,
+	KeyStatusDestroyed:       pk.KeyStatus_KEY_STATUS_REVOKED,
+}
+
+// Proto returns the published status closest to s.
+func (s KeyStatus) Proto() pk.KeyStatus {
+	return keyStatusToProto[s]
+}
+
+// KeyStatusesFromProto returns the statuses s stands for, sorted, or none for a status
+// no key reports.
+func KeyStatusesFromProto(s pk.KeyStatus) []KeyStatus {
+	var statuses []KeyStatus
+	for status, published := range keyStatusToProto {
+		if published == s {
+			statuses = append(statuses, status)
+		}
+	}
+	slices.Sort(statuses)
+	return statuses
+}
+
+// KeyTransitionError reports a rejected status change. It matches
+// app_errors.ErrInvalidKeyTransition with errors.Is.
+type KeyTransitionError struct {
+	From KeyStatus
+	To   KeyStatus
+}
+
+func (e *KeyTransitionError) Error() string {
+	return fmt.Sprintf("invalid key status transition from %q to %q", e.From, e.To)
+}
+
+func (e *KeyTransitionError) Unwrap() error {
+	return app_errors.ErrInvalidKeyTransition
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+// KeyTemplate is a named set of creation defaults that platform teams publish so that
+// keys of one kind are created consistently.
+type KeyTemplate struct {
+	Name               string            `json:"name"`
+	KeyType            pk.KeyType        `json:"key_type"`
+	Description        string            `json:"description,omitempty"`
+	DataClassification string            `json:"data_classification,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	AuthorizedContexts []string          `json:"authorized_contexts,omitempty"`
+	AccessPolicies     map[string]string `json:"access_policies,omitempty"`
+	// RotationPeriod is how old the latest version of a key created from the template
+	// may get before the scheduled rotation job rotates it; zero means never.
+	RotationPeriod time.Duration `json:"rotation_period,omitempty"`
+	CreatedAt      time.Time     `json:"-"`
+	UpdatedAt      time.Time     `json:"-"`
+}
+
+// KeyTemplateRepository stores key templates by name.
+type KeyTemplateRepository interface {
+	PutKeyTemplate(ctx context.Context, template *KeyTemplate) error
+	GetKeyTemplate(ctx context.Context, name string) (*KeyTemplate, error)
+	ListKeyTemplates(ctx context.Context) ([]*KeyTemplate, error)
+	DeleteKeyTemplate(ctx context.Context, name string) error
+}
+
+// Apply merges the template into a creation request. Key type and data classification
+// are fixed by the template and a request asking for something else is rejected; the
+// template's tags and access policies take precedence over the request's, its authorized
+// contexts are added to the request's, and its description is used when the request has
+// none. The rotation period is recorded under rotationPolicy in the access policies.
+func (t *KeyTemplate) Apply(item *pk.CreateKeyItem, rotationPolicy string) error {
+	if item.KeyType != pk.KeyType_KEY_TYPE_UNSPECIFIED && item.KeyType != t.KeyType {
+		return fmt.Errorf("%w: template %q requires key type %s", app_errors.ErrInvalidInput, t.Name, t.KeyType)
+	}
+	if t.DataClassification != "" && item.DataClassification != "" && item.DataClassification != t.DataClassification {
+		return fmt.Errorf("%w: template %q requires data classification %q", app_errors.ErrInvalidInput, t.Name, t.DataClassification)
+	}
+
+	item.KeyType = t.KeyType
+	if t.DataClassification != "" {
+		item.DataClassification = t.DataClassification
+	}
+	if item.Description == "" {
+		item.Description = t.Description
+	}
+	item.Tags = mergeStrings(item.Tags, t.Tags)
+	item.AccessPolicies = mergeStrings(item.AccessPolicies, t.AccessPolicies)
+	if t.RotationPeriod > 0 {
+		item.AccessPolicies = mergeStrings(item.AccessPolicies, map[string]string{rotationPolicy: t.RotationPeriod.String()})
+	}
+	for _, context := range t.AuthorizedContexts {
+		if !slices.Contains(item.InitialAuthorizedContexts, context) {
+			item.InitialAuthorizedContexts = append(item.InitialAuthorizedContexts, context)
+		}
+	}
+	return nil
+}
+
+// mergeStrings returns a copy of base overlaid with override.
+func mergeStrings(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	maps.Copy(merged, base)
+	maps.Copy(merged, override)
+	return merged
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// KeyVersionCursor orders key versions by key ID and then version, as a re-wrap walks
+// them.
+type KeyVersionCursor struct {
+	KeyID   string
+	Version int32
+}
+
+// RewrapCheckpoint records the progress of a re-wrap of DEKs from one KMS provider to
+// another.
+type RewrapCheckpoint struct {
+	FromProvider string
+	ToProvider   string
+	// After is the last key version the re-wrap finished; the zero cursor means it has
+	// not started.
+	After       KeyVersionCursor
+	Rewrapped   int64
+	Failed      int64
+	StartedAt   time.Time
+	UpdatedAt   time.Time
+	CompletedAt *time.Time
+}
+
+// RewrapRequest asks for DEKs wrapped by FromProvider to be wrapped by ToProvider
+// instead, in batches of BatchSize key versions with BatchInterval between them.
+// MaxBatches bounds the batches of one call, which is unbounded when it is zero, and a
+// later call resumes from the checkpoint. Restart discards the checkpoint first.
+type RewrapRequest struct {
+	FromProvider  string
+	ToProvider    string
+	BatchSize     int
+	MaxBatches    int
+	BatchInterval time.Duration
+	Restart       bool
+}
+
+// KeyRewrapRepository reads and replaces the wrapped DEKs of key versions for a
+// re-wrap, across all namespaces, and keeps its checkpoint.
+type KeyRewrapRepository interface {
+	// ListWrappedKeys returns up to limit key versions after the cursor, in its order,
+	// whose DEK is wrapped by provider. Versions that record no provider count as wrapped
+	// by defaultProvider. Destroyed versions and those of the hardened storage profile,
+	// which stay with the aws provider, are left out.
+	ListWrappedKeys(ctx context.Context, provider, defaultProvider string, after KeyVersionCursor, limit int) ([]*Key, error)
+	// ReplaceWrappedDEK stores newDEK, wrapped by provider, for a key version, provided its
+	// DEK is still oldDEK. It reports whether the version was updated.
+	ReplaceWrappedDEK(ctx context.Context, id KeyID, version int32, oldDEK, newDEK []byte, provider string) (bool, error)
+	// GetRewrapCheckpoint returns the checkpoint of a re-wrap, or nil if there is none.
+	GetRewrapCheckpoint(ctx context.Context, fromProvider, toProvider string) (*RewrapCheckpoint, error)
+	SaveRewrapCheckpoint(ctx context.Context, checkpoint *RewrapCheckpoint) error
+	DeleteRewrapCheckpoint(ctx context.Context, fromProvider, toProvider string) error
+}
@@ -0,0 +1,7 @@
+package domain
+
+// Leadership tells whether this replica is the one that runs the background jobs, so that
+// with several replicas each sweep happens once.
+type Leadership interface {
+	IsLeader() bool
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "context"
+
+// DefaultNamespace holds the keys and clients that were not assigned a namespace.
+const DefaultNamespace = "default"
+
+const namespaceContextKey = contextKey("namespace")
+
+// NewContextWithNamespace scopes every repository operation made with the returned
+// context to keys of the given namespace.
+func NewContextWithNamespace(ctx context.Context, namespace string) context.Context {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return context.WithValue(ctx, namespaceContextKey, namespace)
+}
+
+// NamespaceFromContext returns the namespace a context is scoped to. ok is false for
+// contexts that were never scoped, such as those of background jobs, which see the
+// keys of every namespace. Request contexts are always scoped by authentication.
+func NamespaceFromContext(ctx context.Context) (namespace string, ok bool) {
+	namespace, ok = ctx.Value(namespaceContextKey).(string)
+	return namespace, ok
+}
+
+// NamespaceVisible reports whether a key in namespace may be seen with ctx.
+func NamespaceVisible(ctx context.Context, namespace string) bool {
+	scoped, ok := NamespaceFromContext(ctx)
+	return !ok || scoped == namespace
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ReplicationBatch is the outcome of shipping one batch of key mutations to a standby.
+type ReplicationBatch struct {
+	// Shipped is the number of recorded mutations the batch took off the outbox.
+	Shipped int
+	// Conflicts are the key versions the standby changed after the primary last did. They
+	// are left as the standby has them.
+	Conflicts []KeyVersionCursor
+}
+
+// ReplicationBacklog describes the key mutations not yet shipped to a standby.
+type ReplicationBacklog struct {
+	Pending int64
+	// Oldest is when the oldest pending mutation was recorded, nil when none is pending.
+	Oldest *time.Time
+}
+
+// KeyReplicator ships the key mutations of a primary database to one standby.
+type KeyReplicator interface {
+	// Target names the standby.
+	Target() string
+	// Register makes the primary record key mutations for the standby. The first time, it
+	// records every existing key version, so that the standby catches up from scratch.
+	Register(ctx context.Context) error
+	// Unregister stops recording mutations for the standby and forgets those pending.
+	Unregister(ctx context.Context) error
+	// ReplicateBatch ships up to limit recorded mutations, oldest first.
+	ReplicateBatch(ctx context.Context, limit int) (ReplicationBatch, error)
+	Backlog(ctx context.Context) (ReplicationBacklog, error)
+}
@@ -0,0 +1,10 @@
+package domain
+
+import "context"
+
+// SecretRotator rotates the bootstrap secrets the service runs with.
+type SecretRotator interface {
+	// RotateSecrets reads the rotatable bootstrap secrets again and applies those that
+	// changed, returning the secret paths it rotated.
+	RotateSecrets(ctx context.Context) ([]string, error)
+}
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// TokenSession is an access token issued to a client, identified by its token ID (jti).
+type TokenSession struct {
+	TokenID   string
+	ClientID  string
+	Namespace string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// TokenSessionStore is implemented by token stores that track the tokens issued to each
+// client, so that operators can list a client's sessions and revoke them all at once.
+type TokenSessionStore interface {
+	// TrackToken records a token as it is issued.
+	TrackToken(ctx context.Context, session TokenSession) error
+	// ListActiveTokens returns the unexpired, unrevoked tokens of clientID, or of every
+	// client when clientID is "", oldest first.
+	ListActiveTokens(ctx context.Context, clientID string) ([]TokenSession, error)
+	// RevokeAllForClient revokes every active token of clientID and returns them.
+	RevokeAllForClient(ctx context.Context, clientID string) ([]TokenSession, error)
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// UpdateMaskHeader is the metadata an UpdateKeyMetadata request may carry its field mask
+// in: the comma-separated paths of the KeyMetadata fields to update, such as
+// "description,expires_at".
+const UpdateMaskHeader = "update-mask"
+
+// UpdatableMetadataFields are the KeyMetadata fields an update mask may name.
+var UpdatableMetadataFields = []string{"description", "expires_at", "data_classification", "tags"}
+
+type updateMaskKey struct{}
+
+// NewContextWithUpdateMask returns ctx carrying the field mask of an update.
+func NewContextWithUpdateMask(ctx context.Context, mask *fieldmaskpb.FieldMask) context.Context {
+	return context.WithValue(ctx, updateMaskKey{}, mask)
+}
+
+// UpdateMaskFromContext returns the field mask of the update made with ctx, if it has
+// one.
+func UpdateMaskFromContext(ctx context.Context) (*fieldmaskpb.FieldMask, bool) {
+	mask, ok := ctx.Value(updateMaskKey{}).(*fieldmaskpb.FieldMask)
+	return mask, ok
+}
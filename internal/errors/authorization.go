@@ -0,0 +1,48 @@
+package errors
+
+import "strings"
+
+// AuthorizationError is the ErrAuthorization of one call, with the authorizer's reason
+// for the denial, which may name identities and is only logged.
+type AuthorizationError struct {
+	Reason string
+}
+
+func (e *AuthorizationError) Error() string {
+	return "authorization failed: " + e.Reason
+}
+
+func (e *AuthorizationError) Unwrap() error {
+	return ErrAuthorization
+}
+
+// denialReasons maps the reasons of the authorizer, up to any "=" detail, to the denial
+// reasons clients are given. A missing key and a key the caller may not use are both
+// KEY_ACCESS_DENIED, so that a denial does not reveal whether a key exists.
+var denialReasons = map[string]string{
+	"missing_user_identity":                             "MISSING_IDENTITY",
+	"operation_not_allowed":                             "OPERATION_NOT_ALLOWED",
+	"operation_not_allowed_by_cache":                    "OPERATION_NOT_ALLOWED",
+	"mismatched_requester_identity_token":               "REQUESTER_IDENTITY_MISMATCH",
+	"missing_peer_certificate_for_identity_check":       "PEER_IDENTITY_MISMATCH",
+	"mismatched_identity_cn":                            "PEER_IDENTITY_MISMATCH",
+	"key_not_found":                                     "KEY_ACCESS_DENIED",
+	"internal_error_accessing_key":                      "KEY_ACCESS_DENIED",
+	"key_missing_metadata":                              "KEY_ACCESS_DENIED",
+	"insufficient_key_permissions":                      "KEY_ACCESS_DENIED",
+	"requester_context_is_required_for_tier_validation": "REQUESTER_CONTEXT_REQUIRED",
+}
+
+// DenialReason returns the reason of the denial clients are given in the
+// denial_reason metadata of the ErrorInfo: TIER_NOT_PERMITTED when the caller's tier
+// cannot use the key's storage profile, and DENIED for reasons without a code.
+func (e *AuthorizationError) DenialReason() string {
+	reason, _, _ := strings.Cut(e.Reason, "=")
+	if code, ok := denialReasons[reason]; ok {
+		return code
+	}
+	if strings.HasPrefix(reason, "tier ") {
+		return "TIER_NOT_PERMITTED"
+	}
+	return "DENIED"
+}
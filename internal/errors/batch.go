@@ -0,0 +1,20 @@
+package errors
+
+import "fmt"
+
+// BatchTooLargeError is the ErrBatchTooLarge of one call: the items it carried and the
+// most its method allows for the caller's tier.
+type BatchTooLargeError struct {
+	Method   string
+	Tier     string
+	Items    int
+	MaxItems int
+}
+
+func (e *BatchTooLargeError) Error() string {
+	return fmt.Sprintf("%s takes at most %d items for tier %s, got %d", e.Method, e.MaxItems, e.Tier, e.Items)
+}
+
+func (e *BatchTooLargeError) Unwrap() error {
+	return ErrBatchTooLarge
+}
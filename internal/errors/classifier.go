@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"strconv"
 	"sync"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type ErrorClass int
@@ -29,6 +33,7 @@ type ClassifiedError struct {
 	InternalError error
 	ClientMessage string
 	OperationName string
+	Reason        string // Machine-readable, sent to clients in ErrorInfo
 	KeyID         string // Store but never expose
 	Metadata      map[string]any
 }
@@ -51,18 +56,51 @@ var errorPool = sync.Pool{
 
 var classificationRules = []struct {
 	targetErr     error
+	reason        string
 	class         ErrorClass
 	clientMessage string
 }{
-	{ErrKeyNotFound, ClassNotFound, "The requested resource was not found"},
-	{ErrInvalidInput, ClassValidation, "The request contains invalid parameters"},
-	{ErrKMSFailure, ClassInternal, "An internal error occurred. Please try again later"},
-	{ErrAuthentication, ClassAuthentication, "Authentication failed"},
-	{ErrAuthorization, ClassAuthorization, "Permission denied"},
-	{ErrConflict, ClassConflict, "A conflict occurred"},
-	{ErrRateLimit, ClassRateLimit, "You have exceeded the rate limit"},
-	{ErrExternal, ClassExternal, "External service temporarily unavailable"},
-	{ErrKeyRevoked, ClassFailedPrecondition, "The operation cannot be completed because the key is revoked"},
+	{ErrKeyNotFound, "KEY_NOT_FOUND", ClassNotFound, "The requested resource was not found"},
+	{ErrTemplateNotFound, "TEMPLATE_NOT_FOUND", ClassNotFound, "The requested key template was not found"},
+	{ErrClientNotFound, "CLIENT_NOT_FOUND", ClassNotFound, "The requested client was not found"},
+	{ErrRoleNotFound, "ROLE_NOT_FOUND", ClassNotFound, "The requested role was not found"},
+	{ErrBatchTooLarge, "BATCH_TOO_LARGE", ClassValidation, "The batch has more items than allowed; split it into smaller batches"},
+	{ErrInvalidInput, "INVALID_INPUT", ClassValidation, "The request contains invalid parameters"},
+	{ErrSecretInMetadata, "SECRET_IN_METADATA", ClassValidation, "Descriptions, tags and access policies must not contain secrets"},
+	{ErrKMSFailure, "KMS_FAILURE", ClassInternal, "An internal error occurred. Please try again later"},
+	{ErrStepUpRequired, "STEP_UP_REQUIRED", ClassAuthentication, "Step-up authentication is required to access this key"},
+	{ErrAuthentication, "AUTHENTICATION_FAILED", ClassAuthentication, "Authentication failed"},
+	{ErrAuthorization, "PERMISSION_DENIED", ClassAuthorization, "Permission denied"},
+	{ErrConflict, "CONFLICT", ClassConflict, "A conflict occurred"},
+	{ErrRateLimit, "RATE_LIMITED", ClassRateLimit, "You have exceeded the rate limit"},
+	{ErrOverloaded, "OVERLOADED", ClassRateLimit, "The server is overloaded. Please retry later"},
+	{ErrConcurrencyLimited, "CONCURRENCY_LIMITED", ClassRateLimit, "Too many concurrent calls of this method for your tier. Please retry later"},
+	{ErrRotationQueueFull, "ROTATION_QUEUE_FULL", ClassRateLimit, "The key rotation queue is full. Please retry later"},
+	{ErrNamespaceQuotaExceeded, "NAMESPACE_QUOTA_EXCEEDED", ClassRateLimit, "The namespace has reached its key quota"},
+	{ErrExternal, "EXTERNAL_UNAVAILABLE", ClassExternal, "External service temporarily unavailable"},
+	{ErrShuttingDown, "SHUTTING_DOWN", ClassExternal, "The server is shutting down. Please retry"},
+	{ErrKeyRevoked, "KEY_REVOKED", ClassFailedPrecondition, "The operation cannot be completed because the key is revoked"},
+	{ErrKeyNotExportable, "KEY_NOT_EXPORTABLE", ClassFailedPrecondition, "The key's material cannot be returned to clients"},
+	{ErrKeyExpired, "KEY_EXPIRED", ClassFailedPrecondition, "The operation cannot be completed because the key has expired"},
+	{ErrInvalidKeyTransition, "INVALID_KEY_TRANSITION", ClassFailedPrecondition, "The operation is not allowed in the key's current status"},
+	{ErrRestoreWindowClosed, "RESTORE_WINDOW_CLOSED", ClassFailedPrecondition, "The key can no longer be restored"},
+	{ErrAuditArchivingDisabled, "AUDIT_ARCHIVING_DISABLED", ClassFailedPrecondition, "Audit archiving is not enabled"},
+	{ErrCircuitBreakerDisabled, "CIRCUIT_BREAKER_DISABLED", ClassFailedPrecondition, "The circuit breaker is not enabled"},
+	{ErrLogLevelsUnavailable, "LOG_LEVELS_UNAVAILABLE", ClassFailedPrecondition, "Log levels cannot be changed at runtime"},
+	{ErrSecretRotationUnavailable, "SECRET_ROTATION_UNAVAILABLE", ClassFailedPrecondition, "Bootstrap secrets cannot be rotated at runtime"},
+	{ErrSecretRotationFailed, "SECRET_ROTATION_FAILED", ClassFailedPrecondition, "Some bootstrap secrets could not be rotated; the current ones stay in use"},
+	{ErrClientManagementUnavailable, "CLIENT_MANAGEMENT_UNAVAILABLE", ClassFailedPrecondition, "Clients cannot be managed at runtime"},
+	{ErrRoleManagementUnavailable, "ROLE_MANAGEMENT_UNAVAILABLE", ClassFailedPrecondition, "Roles cannot be managed at runtime"},
+	{ErrConfigReloadUnavailable, "CONFIG_RELOAD_UNAVAILABLE", ClassFailedPrecondition, "Config reloading is not enabled"},
+	{ErrConfigReloadFailed, "CONFIG_RELOAD_FAILED", ClassFailedPrecondition, "The config could not be reloaded; the current one stays in use"},
+	{ErrKMSProviderUnavailable, "KMS_PROVIDER_UNAVAILABLE", ClassFailedPrecondition, "The KMS provider is not configured"},
+	{ErrKMSRewrapInProgress, "KMS_REWRAP_IN_PROGRESS", ClassFailedPrecondition, "A KMS re-wrap is already in progress"},
+	{ErrKMSRewrapUnavailable, "KMS_REWRAP_UNAVAILABLE", ClassFailedPrecondition, "KMS re-wrap is not available"},
+	{ErrETagMismatch, "ETAG_MISMATCH", ClassFailedPrecondition, "The key changed since it was read; read it again and retry"},
+	{ErrKeyAliasesUnavailable, "KEY_ALIASES_UNAVAILABLE", ClassFailedPrecondition, "Key aliases are not available"},
+	{ErrSessionManagementUnavailable, "SESSION_MANAGEMENT_UNAVAILABLE", ClassFailedPrecondition, "Token sessions are not tracked"},
+	{ErrKeyEventsUnavailable, "KEY_EVENTS_UNAVAILABLE", ClassFailedPrecondition, "Key events are not enabled on this server"},
+	{ErrMetadataIntegrity, "METADATA_INTEGRITY", ClassInternal, "An internal error occurred. Please try again later"},
 }
 
 func (ec *ErrorClassifier) Classify(err error, operation string) *ClassifiedError {
@@ -74,16 +112,19 @@ func (ec *ErrorClassifier) Classify(err error, operation string) *ClassifiedErro
 	classified.InternalError = err
 	classified.OperationName = operation
 
+	classified.Class = ClassInternal
+	classified.Reason = ReasonInternal
+	classified.ClientMessage = "An unexpected internal error occurred"
 	for _, rule := range classificationRules {
 		if errors.Is(err, rule.targetErr) {
 			classified.Class = rule.class
+			classified.Reason = rule.reason
 			classified.ClientMessage = rule.clientMessage
-			return classified
+			break
 		}
 	}
 
-	classified.Class = ClassInternal
-	classified.ClientMessage = "An unexpected internal error occurred"
+	recordClassified(classified)
 	return classified
 }
 
@@ -124,13 +165,62 @@ var grpcCodeMap = map[ErrorClass]codes.Code{
 	ClassFailedPrecondition: codes.FailedPrecondition,
 }
 
-func (ec *ErrorClassifier) toGRPCError(classified *ClassifiedError) error {
-	code, exists := grpcCodeMap[classified.Class]
-	if !exists {
-		code = codes.Internal
+// Status classifies err like Classify and returns its sanitized status without logging
+// it, for calls refused before they are served, such as by rate limiting or load
+// shedding, which are counted by their own metrics rather than logged one by one.
+func (ec *ErrorClassifier) Status(err error, operation string) error {
+	classified := ec.Classify(err, operation)
+	if classified == nil {
+		return nil
 	}
+	defer ec.putError(classified)
+	return ec.toGRPCError(classified)
+}
 
-	return status.Error(code, classified.ClientMessage)
+// toGRPCError builds the client's status of classified. Its details always start with
+// an ErrorInfo, followed by a PreconditionFailure for failed preconditions, a RetryInfo
+// when the error says when to retry, and a BadRequest for oversized batches.
+func (ec *ErrorClassifier) toGRPCError(classified *ClassifiedError) error {
+	st := status.New(classified.Class.Code(), classified.ClientMessage)
+	info := &errdetails.ErrorInfo{
+		Domain: ErrorDomain,
+		Reason: classified.Reason,
+		Metadata: map[string]string{
+			"operation": classified.OperationName,
+			"class":     classified.Class.String(),
+		},
+	}
+	details := []protoadapt.MessageV1{info}
+	if classified.Class == ClassFailedPrecondition {
+		details = append(details, &errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{{
+			Type:        classified.Reason,
+			Subject:     classified.OperationName,
+			Description: classified.ClientMessage,
+		}}})
+	}
+	var retryErr *RetryAfterError
+	if errors.As(classified.InternalError, &retryErr) && retryErr.After > 0 {
+		info.Metadata["retry_after"] = retryErr.After.String()
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(retryErr.After)})
+	}
+	var authzErr *AuthorizationError
+	if errors.As(classified.InternalError, &authzErr) {
+		info.Metadata["denial_reason"] = authzErr.DenialReason()
+	}
+	var batchErr *BatchTooLargeError
+	if errors.As(classified.InternalError, &batchErr) {
+		info.Metadata["max_items"] = strconv.Itoa(batchErr.MaxItems)
+		info.Metadata["items"] = strconv.Itoa(batchErr.Items)
+		details = append(details, &errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+			Field:       "keys",
+			Description: batchErr.Error(),
+		}}})
+	}
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
 }
 
 func (ec *ErrorClassifier) putError(err *ClassifiedError) {
@@ -142,6 +232,7 @@ func (ec *ErrorClassifier) putError(err *ClassifiedError) {
 	}
 	
 	err.OperationName = ""
+	err.Reason = ""
 	err.ClientMessage = ""
 	err.Class = 0
 	
@@ -0,0 +1,23 @@
+package errors
+
+import "time"
+
+// RetryAfterError is an error after which the call is worth retrying, but not before
+// After has passed. Sanitized errors report the delay in a google.rpc.RetryInfo.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+// WithRetryAfter marks err as worth retrying after the given delay.
+func WithRetryAfter(err error, after time.Duration) error {
+	return &RetryAfterError{Err: err, After: after}
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
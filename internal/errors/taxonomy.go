@@ -0,0 +1,77 @@
+package errors
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrorDomain is the domain of the google.rpc.ErrorInfo attached to sanitized errors.
+const ErrorDomain = "polykey.spounge.ai"
+
+// ReasonInternal is the ErrorInfo reason of errors that match no classification rule.
+const ReasonInternal = "INTERNAL"
+
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/errors")
+
+var classifiedErrors, _ = meter.Int64Counter(
+	"polykey.errors.classified",
+	metric.WithDescription("Number of errors classified, by class, gRPC code, reason and operation."),
+)
+
+var errorClassNames = map[ErrorClass]string{
+	ClassInternal:           "internal",
+	ClassValidation:         "validation",
+	ClassAuthentication:     "authentication",
+	ClassAuthorization:      "authorization",
+	ClassNotFound:           "not_found",
+	ClassConflict:           "conflict",
+	ClassRateLimit:          "rate_limit",
+	ClassExternal:           "external",
+	ClassFailedPrecondition: "failed_precondition",
+}
+
+func (c ErrorClass) String() string {
+	if name, ok := errorClassNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Code returns the gRPC code errors of the class are returned with.
+func (c ErrorClass) Code() codes.Code {
+	if code, ok := grpcCodeMap[c]; ok {
+		return code
+	}
+	return codes.Internal
+}
+
+// TaxonomyEntry describes one reason a client may find in an error's ErrorInfo.
+type TaxonomyEntry struct {
+	Reason  string
+	Class   ErrorClass
+	Code    codes.Code
+	Message string
+}
+
+// Taxonomy returns every reason the classifier can report, in classification order,
+// ending with ReasonInternal for errors that match no rule.
+func Taxonomy() []TaxonomyEntry {
+	entries := make([]TaxonomyEntry, 0, len(classificationRules)+1)
+	for _, rule := range classificationRules {
+		entries = append(entries, TaxonomyEntry{Reason: rule.reason, Class: rule.class, Code: rule.class.Code(), Message: rule.clientMessage})
+	}
+	return append(entries, TaxonomyEntry{Reason: ReasonInternal, Class: ClassInternal, Code: codes.Internal, Message: "An unexpected internal error occurred"})
+}
+
+func recordClassified(classified *ClassifiedError) {
+	classifiedErrors.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("class", classified.Class.String()),
+		attribute.String("code", classified.Class.Code().String()),
+		attribute.String("reason", classified.Reason),
+		attribute.String("operation", classified.OperationName),
+	))
+}
@@ -13,4 +13,37 @@ var (
 	ErrExternal       = errors.New("external service error")
 	ErrKeyRotationLocked = errors.New("key rotation is locked")
 	ErrKeyRevoked     = errors.New("key is revoked")
+	ErrKeyExpired     = errors.New("key is expired")
+	ErrInvalidKeyTransition = errors.New("invalid key status transition")
+	ErrStepUpRequired = errors.New("step-up authentication required")
+	ErrRestoreWindowClosed = errors.New("key restore window has closed")
+	ErrTemplateNotFound = errors.New("key template not found")
+	ErrNamespaceQuotaExceeded = errors.New("namespace key quota exceeded")
+	ErrAuditArchivingDisabled = errors.New("audit archiving is not enabled")
+	ErrAuditArchiveNotReady = errors.New("audit archive is not yet retrievable")
+	ErrCircuitBreakerDisabled = errors.New("circuit breaker is not enabled")
+	ErrLogLevelsUnavailable = errors.New("runtime log levels are not available")
+	ErrSecretRotationUnavailable = errors.New("bootstrap secret rotation is not available")
+	ErrSecretRotationFailed = errors.New("bootstrap secret rotation failed")
+	ErrClientNotFound = errors.New("client not found")
+	ErrRoleNotFound = errors.New("role not found")
+	ErrClientManagementUnavailable = errors.New("clients cannot be managed at runtime")
+	ErrRoleManagementUnavailable = errors.New("roles cannot be managed at runtime")
+	ErrConfigReloadUnavailable = errors.New("config reloading is not enabled")
+	ErrConfigReloadFailed = errors.New("config reload failed")
+	ErrKMSProviderUnavailable = errors.New("kms provider is not configured")
+	ErrKMSRewrapInProgress = errors.New("a kms re-wrap is already in progress")
+	ErrKMSRewrapUnavailable = errors.New("kms re-wrap is not available")
+	ErrETagMismatch = errors.New("etag does not match the key's current state")
+	ErrKeyAliasesUnavailable = errors.New("key aliases are not available")
+	ErrMetadataIntegrity = errors.New("key metadata does not match its checksum")
+	ErrSecretInMetadata = errors.New("metadata looks like it contains a secret")
+	ErrKeyNotExportable = errors.New("key material is not exportable")
+	ErrSessionManagementUnavailable = errors.New("token sessions are not tracked")
+	ErrBatchTooLarge = errors.New("batch has too many items")
+	ErrOverloaded = errors.New("server is overloaded")
+	ErrConcurrencyLimited = errors.New("too many concurrent calls")
+	ErrRotationQueueFull = errors.New("key rotation queue is full")
+	ErrShuttingDown = errors.New("server is shutting down")
+	ErrKeyEventsUnavailable = errors.New("key events are not enabled")
 )
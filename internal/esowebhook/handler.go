@@ -0,0 +1,161 @@
+// Package esowebhook serves Polykey keys to the External Secrets Operator through its
+// webhook provider, so that ExternalSecret resources can sync key material and metadata
+// into Kubernetes Secrets without a controller of their own. Each request authenticates
+// with the client credentials the SecretStore sends in its Authorization header, so every
+// store reads keys as its own client.
+package esowebhook
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/spounge-ai/polykey/internal/polykeyclient"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Properties a request can select with its property parameter. tagPrefix followed by a
+// tag name selects the value of that tag.
+const (
+	PropertyMaterial    = "material"
+	PropertyMetadata    = "metadata"
+	PropertyVersion     = "version"
+	PropertyDescription = "description"
+	tagPrefix           = "tags."
+)
+
+// keyResponse is the body of GET /v1/keys/{keyId}. Value is the selected property, which
+// a SecretStore extracts with the JSONPath $.value.
+type keyResponse struct {
+	KeyID   string `json:"key_id"`
+	Version int32  `json:"version"`
+	Value   string `json:"value"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the webhook API from a Polykey server.
+type Handler struct {
+	keys    pk.PolykeyServiceClient
+	sources *polykeyclient.TokenSources
+	logger  *slog.Logger
+}
+
+// New creates a handler reading keys over conn.
+func New(conn grpc.ClientConnInterface, logger *slog.Logger) *Handler {
+	return &Handler{
+		keys:    pk.NewPolykeyServiceClient(conn),
+		sources: polykeyclient.NewTokenSources(conn),
+		logger:  logger,
+	}
+}
+
+// Handler returns the HTTP API a webhook SecretStore calls:
+//
+//	GET /v1/keys/{keyId}[?version=N][&property=P]  the property P of a key
+//	GET /healthz                                   200 while the process serves
+//
+// Requests authenticate with HTTP basic authentication, the client ID as the user name
+// and the API key as the password. The property is material, the default, for the key
+// material as GetKey returns it in base64, metadata for the key's metadata as JSON,
+// version, description, or tags.<name> for the value of a tag.
+func (h *Handler) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/keys/{keyId}", h.getKey)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+	})
+	return mux
+}
+
+func (h *Handler) getKey(w http.ResponseWriter, r *http.Request) {
+	clientID, apiKey, ok := r.BasicAuth()
+	if !ok || clientID == "" || apiKey == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="polykey"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "client credentials are required"})
+		return
+	}
+	var version int32
+	if v := r.URL.Query().Get("version"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || parsed <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "version must be a positive integer"})
+			return
+		}
+		version = int32(parsed)
+	}
+	property := r.URL.Query().Get("property")
+	switch {
+	case property == "":
+		property = PropertyMaterial
+	case property == PropertyMaterial, property == PropertyMetadata, property == PropertyVersion, property == PropertyDescription:
+	case strings.HasPrefix(property, tagPrefix) && len(property) > len(tagPrefix):
+	default:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "unknown property " + property})
+		return
+	}
+
+	ctx := r.Context()
+	keyID := r.PathValue("keyId")
+	var key *pk.GetKeyResponse
+	tokens, requester, err := h.sources.Get(ctx, clientID, apiKey)
+	if err == nil {
+		err = tokens.Invoke(ctx, func(ctx context.Context) error {
+			var err error
+			key, err = h.keys.GetKey(ctx, &pk.GetKeyRequest{KeyId: keyID, Version: version, RequesterContext: requester})
+			return err
+		})
+	}
+	if err != nil {
+		st := status.Convert(err)
+		h.logger.WarnContext(ctx, "eso key read failed", "keyId", keyID, "clientId", clientID, "error", err)
+		writeJSON(w, polykeyclient.HTTPStatus(st.Code()), errorResponse{Error: st.Message()})
+		return
+	}
+
+	value, ok, err := keyProperty(key, property)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "key " + keyID + " has no property " + property})
+		return
+	}
+	writeJSON(w, http.StatusOK, keyResponse{KeyID: keyID, Version: key.GetMetadata().GetVersion(), Value: value})
+}
+
+// keyProperty returns a known property of key, and whether it has it, as a tag it may
+// not have.
+func keyProperty(key *pk.GetKeyResponse, property string) (string, bool, error) {
+	metadata := key.GetMetadata()
+	switch property {
+	case PropertyMaterial:
+		return base64.StdEncoding.EncodeToString(key.GetKeyMaterial().GetEncryptedKeyData()), true, nil
+	case PropertyMetadata:
+		data, err := protojson.Marshal(metadata)
+		return string(data), err == nil, err
+	case PropertyVersion:
+		return strconv.Itoa(int(metadata.GetVersion())), true, nil
+	case PropertyDescription:
+		return metadata.GetDescription(), true, nil
+	}
+	value, ok := metadata.GetTags()[strings.TrimPrefix(property, tagPrefix)]
+	return value, ok, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -2,14 +2,48 @@ package audit
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/infra/audit")
+
+var (
+	droppedEvents, _ = meter.Int64Counter(
+		"polykey.audit.events.dropped",
+		metric.WithDescription("Number of audit events lost because they could neither be queued, written nor spilled."),
+	)
+	spilledEvents, _ = meter.Int64Counter(
+		"polykey.audit.events.spilled",
+		metric.WithDescription("Number of audit events written to the disk spill buffer."),
+	)
+	replayedEvents, _ = meter.Int64Counter(
+		"polykey.audit.events.replayed",
+		metric.WithDescription("Number of spilled audit events written to the database on replay."),
+	)
+)
+
+// Reasons attached to the dropped and spilled counters.
+const (
+	reasonQueueFull   = "queue_full"
+	reasonWriteFailed = "write_failed"
+)
+
+// maxSpillReplayAttempts is how often a segment may fail to replay while live writes
+// succeed before it is set aside; such a segment is one the database will not accept,
+// for instance because a crash left it behind after it had already been written.
+const maxSpillReplayAttempts = 5
+
 // AsyncAuditLoggerConfig holds the configuration for the asynchronous logger.
 type AsyncAuditLoggerConfig struct {
 	ChannelBufferSize int
@@ -18,23 +52,82 @@ type AsyncAuditLoggerConfig struct {
 	BatchTimeout      time.Duration
 }
 
+// AuditSpillConfig holds the configuration for the disk spill buffer.
+type AuditSpillConfig struct {
+	Dir            string
+	MaxBytes       int64
+	ReplayInterval time.Duration
+}
+
 // AsyncAuditLogger provides a non-blocking, asynchronous implementation of the AuditLogger interface.
 type AsyncAuditLogger struct {
 	logger       *slog.Logger
 	auditRepo    domain.AuditRepository
+	sinks        []domain.AuditSink
+	clock        clock.Clock
 	eventChannel chan *domain.AuditEvent
 	waitGroup    sync.WaitGroup
 	config       AsyncAuditLoggerConfig
+	batchSize    atomic.Int64
+	batchTimeout atomic.Int64 // Nanoseconds
+	writeFailed  atomic.Bool
+	stopOnce     sync.Once
+
+	// spill is nil unless EnableSpill was called.
+	spill          *diskSpill
+	replayInterval time.Duration
+	replayStop     chan struct{}
+	replayDone     chan struct{}
+	stuckSegment   string
+	stuckAttempts  int
 }
 
-// NewAsyncAuditLogger creates a new asynchronous audit logger.
-func NewAsyncAuditLogger(logger *slog.Logger, auditRepo domain.AuditRepository, config AsyncAuditLoggerConfig) *AsyncAuditLogger {
-	return &AsyncAuditLogger{
+// queueSaturationThreshold is the fill ratio at which the audit queue is reported unhealthy.
+const queueSaturationThreshold = 0.9
+
+// NewAsyncAuditLogger creates a new asynchronous audit logger. Every batch written to the
+// repository is also published to the given sinks. Events are stamped with the time clk
+// reports when they are logged.
+func NewAsyncAuditLogger(logger *slog.Logger, auditRepo domain.AuditRepository, config AsyncAuditLoggerConfig, clk clock.Clock, sinks ...domain.AuditSink) *AsyncAuditLogger {
+	l := &AsyncAuditLogger{
 		logger:       logger,
 		auditRepo:    auditRepo,
+		sinks:        sinks,
+		clock:        clk,
 		eventChannel: make(chan *domain.AuditEvent, config.ChannelBufferSize),
 		config:       config,
 	}
+	l.SetBatching(config.BatchSize, config.BatchTimeout)
+	return l
+}
+
+// SetBatching changes how many events the workers write at once and how long they wait
+// for a batch to fill; a value that is not positive is left unchanged. Workers pick up
+// the change after their current batch.
+func (l *AsyncAuditLogger) SetBatching(batchSize int, batchTimeout time.Duration) {
+	if batchSize > 0 {
+		l.batchSize.Store(int64(batchSize))
+	}
+	if batchTimeout > 0 {
+		l.batchTimeout.Store(int64(batchTimeout))
+	}
+}
+
+// EnableSpill makes the logger write events to a bounded buffer on disk instead of
+// dropping them when the queue is full or a batch cannot be written, and replay them
+// into the database once it accepts writes again. Segments left by a previous run are
+// replayed too. It must be called before Start.
+func (l *AsyncAuditLogger) EnableSpill(config AuditSpillConfig) error {
+	spill, err := openDiskSpill(config.Dir, config.MaxBytes, l.config.BatchSize)
+	if err != nil {
+		return err
+	}
+	l.spill = spill
+	l.replayInterval = config.ReplayInterval
+	if l.replayInterval <= 0 {
+		l.replayInterval = 10 * time.Second
+	}
+	return nil
 }
 
 // Start begins the worker goroutines that process audit events.
@@ -43,14 +136,31 @@ func (l *AsyncAuditLogger) Start() {
 	for i := 0; i < l.config.WorkerCount; i++ {
 		go l.worker()
 	}
+	if l.spill != nil {
+		l.replayStop = make(chan struct{})
+		l.replayDone = make(chan struct{})
+		go l.replayer()
+	}
 }
 
 // Stop gracefully shuts down the audit logger, ensuring all queued events are processed.
+// Events that cannot be written by then stay in the spill buffer for the next run.
 func (l *AsyncAuditLogger) Stop() {
-	l.logger.Info("shutting down audit logger")
-	close(l.eventChannel)
-	l.waitGroup.Wait()
-	l.logger.Info("audit logger shut down successfully")
+	l.stopOnce.Do(func() {
+		l.logger.Info("shutting down audit logger")
+		close(l.eventChannel)
+		l.waitGroup.Wait()
+		if l.spill != nil {
+			if l.replayStop != nil {
+				close(l.replayStop)
+				<-l.replayDone
+			}
+			if err := l.spill.close(); err != nil {
+				l.logger.Error("failed to close audit spill buffer", "error", err)
+			}
+		}
+		l.logger.Info("audit logger shut down successfully")
+	})
 }
 
 // AuditLog sends an audit event to the queue for asynchronous processing.
@@ -62,12 +172,14 @@ func (l *AsyncAuditLogger) AuditLog(ctx context.Context, clientIdentity, operati
 		Operation:      operation,
 		KeyID:          keyID,
 		AuthDecisionID: authDecisionID,
+		CorrelationID:  domain.CorrelationIDFromContext(ctx),
 		Success:        success,
-		Timestamp:      time.Now().UTC(),
+		Timestamp:      l.clock.Now().UTC(),
 	}
 	if err != nil {
 		event.Error = err.Error()
 	}
+	setRequestDetails(ctx, event)
 
 	// The database write is decoupled by sending the event to a channel.
 	select {
@@ -75,7 +187,7 @@ func (l *AsyncAuditLogger) AuditLog(ctx context.Context, clientIdentity, operati
 		// Event successfully queued.
 	default:
 		// This case prevents blocking if the channel is full.
-		l.logger.Warn("audit event channel is full, event dropped", "operation", operation, "keyID", keyID)
+		l.spillEvents(ctx, []*domain.AuditEvent{event}, false, reasonQueueFull)
 	}
 }
 
@@ -83,10 +195,11 @@ func (l *AsyncAuditLogger) AuditLog(ctx context.Context, clientIdentity, operati
 func (l *AsyncAuditLogger) worker() {
 	defer l.waitGroup.Done()
 
-	ticker := time.NewTicker(l.config.BatchTimeout)
+	batchTimeout := time.Duration(l.batchTimeout.Load())
+	ticker := time.NewTicker(batchTimeout)
 	defer ticker.Stop()
 
-	batch := make([]*domain.AuditEvent, 0, l.config.BatchSize)
+	batch := make([]*domain.AuditEvent, 0, l.batchSize.Load())
 
 	for {
 		select {
@@ -99,15 +212,19 @@ func (l *AsyncAuditLogger) worker() {
 				return
 			}
 			batch = append(batch, event)
-			if len(batch) >= l.config.BatchSize {
+			if size := l.batchSize.Load(); int64(len(batch)) >= size {
 				l.writeBatchToDB(batch)
-				batch = make([]*domain.AuditEvent, 0, l.config.BatchSize) // Reset batch
+				batch = make([]*domain.AuditEvent, 0, size) // Reset batch
 			}
 		case <-ticker.C:
 			// Timeout reached, write any events in the current batch.
 			if len(batch) > 0 {
 				l.writeBatchToDB(batch)
-				batch = make([]*domain.AuditEvent, 0, l.config.BatchSize) // Reset batch
+				batch = make([]*domain.AuditEvent, 0, l.batchSize.Load()) // Reset batch
+			}
+			if timeout := time.Duration(l.batchTimeout.Load()); timeout != batchTimeout {
+				batchTimeout = timeout
+				ticker.Reset(batchTimeout)
 			}
 		}
 	}
@@ -120,7 +237,125 @@ func (l *AsyncAuditLogger) writeBatchToDB(batch []*domain.AuditEvent) {
 	}
 
 	if err := l.auditRepo.CreateAuditEventsBatch(context.Background(), batch); err != nil {
+		l.writeFailed.Store(true)
 		l.logger.Error("failed to write audit event batch to database", "error", err, "batch_size", len(batch))
-		// In a production system, add failed batch to a dead-letter queue for reprocessing.
+		l.spillEvents(context.Background(), batch, true, reasonWriteFailed)
+	} else {
+		l.writeFailed.Store(false)
+	}
+	// Sinks get the batch even when the database write failed, so that the export
+	// still has a copy.
+	publishToSinks(context.Background(), l.logger, l.sinks, batch)
+}
+
+// spillEvents writes events to the spill buffer, or counts them as dropped when there is
+// none or it is full. published tells whether the sinks already have them.
+func (l *AsyncAuditLogger) spillEvents(ctx context.Context, events []*domain.AuditEvent, published bool, reason string) {
+	reasonAttr := metric.WithAttributes(attribute.String("reason", reason))
+	spilled := 0
+	var err error
+	if l.spill != nil {
+		spilled, err = l.spill.append(events, published)
+		spilledEvents.Add(ctx, int64(spilled), reasonAttr)
+	}
+	if dropped := len(events) - spilled; dropped > 0 {
+		droppedEvents.Add(ctx, int64(dropped), reasonAttr)
+		l.logger.Warn("audit events dropped", "reason", reason, "dropped", dropped, "error", err, "operation", events[spilled].Operation)
+	}
+}
+
+func (l *AsyncAuditLogger) replayer() {
+	defer close(l.replayDone)
+
+	ticker := time.NewTicker(l.replayInterval)
+	defer ticker.Stop()
+
+	for {
+		l.replaySpill()
+
+		select {
+		case <-l.replayStop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// replaySpill writes spilled segments to the database, oldest first, one batch per
+// segment. It stops at the first failure and tries again on the next tick.
+func (l *AsyncAuditLogger) replaySpill() {
+	ctx := context.Background()
+	segments, err := l.spill.seal()
+	if err != nil {
+		l.logger.Error("failed to seal audit spill buffer", "error", err)
+		return
+	}
+
+	for _, segment := range segments {
+		events, published, err := l.spill.read(segment)
+		if err != nil {
+			l.logger.Error("setting aside unreadable audit spill segment", "segment", segment, "error", err)
+			l.rejectSegment(segment)
+			continue
+		}
+		if len(events) > 0 {
+			if err := l.auditRepo.CreateAuditEventsBatch(ctx, events); err != nil {
+				l.replayFailed(segment, err)
+				return
+			}
+		}
+		if err := l.spill.remove(segment); err != nil {
+			l.logger.Error("failed to remove replayed audit spill segment", "segment", segment, "error", err)
+			return
+		}
+		l.stuckSegment, l.stuckAttempts = "", 0
+		replayedEvents.Add(ctx, int64(len(events)))
+		l.logger.Info("replayed spilled audit events", "segment", segment, "events", len(events))
+
+		var unpublished []*domain.AuditEvent
+		for i, event := range events {
+			if !published[i] {
+				unpublished = append(unpublished, event)
+			}
+		}
+		if len(unpublished) > 0 {
+			publishToSinks(ctx, l.logger, l.sinks, unpublished)
+		}
+	}
+}
+
+func (l *AsyncAuditLogger) replayFailed(segment string, err error) {
+	if segment != l.stuckSegment {
+		l.stuckSegment, l.stuckAttempts = segment, 0
+	}
+	l.stuckAttempts++
+	// While live writes fail the database is the problem, not the segment.
+	if l.stuckAttempts >= maxSpillReplayAttempts && !l.writeFailed.Load() {
+		l.logger.Error("setting aside audit spill segment the database keeps rejecting", "segment", segment, "error", err)
+		l.rejectSegment(segment)
+		l.stuckSegment, l.stuckAttempts = "", 0
+		return
+	}
+	l.logger.Warn("failed to replay spilled audit events, will retry", "segment", segment, "attempt", l.stuckAttempts, "error", err)
+}
+
+func (l *AsyncAuditLogger) rejectSegment(segment string) {
+	if err := l.spill.reject(segment); err != nil {
+		l.logger.Error("failed to set aside audit spill segment", "segment", segment, "error", err)
+	}
+}
+
+// HealthCheck reports an error when the queue is nearly full, the last batch write
+// failed or the spill buffer is full.
+func (l *AsyncAuditLogger) HealthCheck(ctx context.Context) error {
+	if capacity := cap(l.eventChannel); capacity > 0 && float64(len(l.eventChannel)) >= float64(capacity)*queueSaturationThreshold {
+		return errors.New("audit event queue is saturated")
+	}
+	if l.writeFailed.Load() {
+		return errors.New("last audit batch write failed")
+	}
+	if l.spill != nil && l.spill.full() {
+		return errSpillFull
 	}
+	return nil
 }
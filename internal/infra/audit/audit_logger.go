@@ -3,24 +3,25 @@ package audit
 import (
 	"context"
 	"log/slog"
-	"time"
 
 	"github.com/google/uuid"
-	"github.com/spounge-ai/polykey/internal/app/grpc/interceptors"
 	"github.com/spounge-ai/polykey/internal/domain"
-	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/peer"
+	"github.com/spounge-ai/polykey/pkg/clock"
 )
 
 type Logger struct {
 	logger   *slog.Logger
 	auditRepo domain.AuditRepository
+	sinks     []domain.AuditSink
+	clock     clock.Clock
 }
 
-func NewAuditLogger(logger *slog.Logger, auditRepo domain.AuditRepository) domain.AuditLogger {
+func NewAuditLogger(logger *slog.Logger, auditRepo domain.AuditRepository, clk clock.Clock, sinks ...domain.AuditSink) domain.AuditLogger {
 	return &Logger{
 		logger:    logger,
 		auditRepo: auditRepo,
+		sinks:     sinks,
+		clock:     clk,
 	}
 }
 
@@ -32,13 +33,11 @@ func (l *Logger) AuditLog(ctx context.Context, clientIdentity, operation, keyID,
 		Operation:      operation,
 		KeyID:          keyID,
 		AuthDecisionID: authDecisionID,
+		CorrelationID:  domain.CorrelationIDFromContext(ctx),
 		Success:        success,
-		Timestamp:      time.Now().UTC(),
-		RequestMetadata: map[string]string{
-			"user_agent": extractUserAgent(ctx),
-			"source_ip":  extractSourceIP(ctx),
-		},
+		Timestamp:      l.clock.Now().UTC(),
 	}
+	setRequestDetails(ctx, event)
 
 	if err != nil {
 		event.Error = err.Error()
@@ -47,11 +46,12 @@ func (l *Logger) AuditLog(ctx context.Context, clientIdentity, operation, keyID,
 	// Log to structured logger
 	logAttrs := []slog.Attr{
 		slog.String("audit_id", event.ID),
-		slog.String("correlation_id", interceptors.CorrelationIDFromContext(ctx)),
+		slog.String("correlation_id", event.CorrelationID),
 		slog.String("client_identity", clientIdentity),
 		slog.String("operation", operation),
 		slog.String("key_id", keyID),
 		slog.String("auth_decision_id", authDecisionID),
+		slog.String("peer_ip", event.PeerIP),
 		slog.Bool("success", success),
 		slog.Time("timestamp", event.Timestamp),
 	}
@@ -59,6 +59,9 @@ func (l *Logger) AuditLog(ctx context.Context, clientIdentity, operation, keyID,
 	if err != nil {
 		logAttrs = append(logAttrs, slog.String("error", err.Error()))
 	}
+	if len(event.Changes) > 0 {
+		logAttrs = append(logAttrs, slog.Any("changes", event.Changes))
+	}
 
 	l.logger.LogAttrs(ctx, slog.LevelInfo, "audit_event", logAttrs...)
 
@@ -70,26 +73,6 @@ func (l *Logger) AuditLog(ctx context.Context, clientIdentity, operation, keyID,
 				slog.String("error", auditErr.Error()))
 		}
 	}
-}
 
-// extractUserAgent extracts user agent from context metadata.
-func extractUserAgent(ctx context.Context) string {
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return "unknown"
-	}
-	ua := md.Get("user-agent")
-	if len(ua) == 0 {
-		return "unknown"
-	}
-	return ua[0]
-}
-
-// extractSourceIP extracts source IP from context metadata.
-func extractSourceIP(ctx context.Context) string {
-	p, ok := peer.FromContext(ctx)
-	if !ok {
-		return "unknown"
-	}
-	return p.Addr.String()
+	publishToSinks(ctx, l.logger, l.sinks, []*domain.AuditEvent{event})
 }
@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// maxUserAgentLength bounds the caller supplied user agent stored with each event.
+const maxUserAgentLength = 512
+
+// setCallerMetadata records the network details of the gRPC caller on the event. Events
+// logged outside a request, such as by background jobs, have none.
+func setCallerMetadata(ctx context.Context, event *domain.AuditEvent) {
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			event.PeerIP = p.Addr.String()
+			if host, _, err := net.SplitHostPort(event.PeerIP); err == nil {
+				event.PeerIP = host
+			}
+		}
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+			sum := sha256.Sum256(tlsInfo.State.PeerCertificates[0].Raw)
+			event.CertFingerprint = hex.EncodeToString(sum[:])
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			event.UserAgent = ua[0]
+			if len(event.UserAgent) > maxUserAgentLength {
+				event.UserAgent = event.UserAgent[:maxUserAgentLength]
+			}
+		}
+	}
+}
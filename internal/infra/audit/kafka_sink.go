@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// KafkaSinkConfig holds the configuration for the Kafka audit sink.
+type KafkaSinkConfig struct {
+	Brokers        []string
+	Topic          string
+	MaxRetries     int
+	RetryBackoff   time.Duration
+	WriteTimeout   time.Duration
+	DeadLetterPath string
+}
+
+// KafkaSink publishes audit events to a Kafka topic as JSON, keyed by key ID so that the
+// events of one key stay in order within a partition. A batch that cannot be delivered
+// after MaxRetries retries is appended to the dead-letter file, one JSON event per line,
+// for replay once the cluster is reachable again.
+type KafkaSink struct {
+	writer     *kafka.Writer
+	config     KafkaSinkConfig
+	logger     *slog.Logger
+	deadLetter sync.Mutex
+}
+
+var _ domain.AuditSink = (*KafkaSink)(nil)
+
+// NewKafkaSink creates a Kafka audit sink. The connection is made lazily on first publish.
+func NewKafkaSink(logger *slog.Logger, config KafkaSinkConfig) (*KafkaSink, error) {
+	if len(config.Brokers) == 0 {
+		return nil, errors.New("kafka audit sink requires at least one broker")
+	}
+	if config.Topic == "" {
+		return nil, errors.New("kafka audit sink requires a topic")
+	}
+	return &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(config.Brokers...),
+			Topic:        config.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// Retries are handled by Publish so that exhausted batches reach the dead-letter file.
+			MaxAttempts:  1,
+			WriteTimeout: config.WriteTimeout,
+		},
+		config: config,
+		logger: logger,
+	}, nil
+}
+
+func (s *KafkaSink) Name() string {
+	return "kafka"
+}
+
+// Publish writes the events to the topic, retrying with exponential backoff. It only
+// returns an error when the events could neither be delivered nor dead-lettered.
+func (s *KafkaSink) Publish(ctx context.Context, events []*domain.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		value, err := json.Marshal(newSinkRecord(event))
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit event %s: %w", event.ID, err)
+		}
+		messages = append(messages, kafka.Message{
+			Key:   []byte(event.KeyID),
+			Value: value,
+			Time:  event.Timestamp,
+		})
+	}
+
+	err := s.writeWithRetry(ctx, messages)
+	if err == nil {
+		return nil
+	}
+
+	s.logger.WarnContext(ctx, "kafka audit delivery failed, writing to dead-letter file", "topic", s.config.Topic, "error", err, "batch_size", len(events))
+	if dlErr := s.writeDeadLetter(messages); dlErr != nil {
+		return errors.Join(err, dlErr)
+	}
+	return nil
+}
+
+func (s *KafkaSink) writeWithRetry(ctx context.Context, messages []kafka.Message) error {
+	backoff := s.config.RetryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = s.writer.WriteMessages(ctx, messages...); err == nil {
+			return nil
+		}
+		if attempt >= s.config.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s *KafkaSink) writeDeadLetter(messages []kafka.Message) error {
+	if s.config.DeadLetterPath == "" {
+		return errors.New("no dead-letter file configured")
+	}
+
+	s.deadLetter.Lock()
+	defer s.deadLetter.Unlock()
+
+	lines := make([][]byte, len(messages))
+	for i, msg := range messages {
+		lines[i] = msg.Value
+	}
+	return appendLines(s.config.DeadLetterPath, lines)
+}
+
+// Close flushes and closes the underlying producer.
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	cts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var sampledOutEvents, _ = meter.Int64Counter(
+	"polykey.audit.events.sampled_out",
+	metric.WithDescription("Number of successful read audit events skipped by audit sampling."),
+)
+
+// sampleableOperations are the read operations whose successful audits may be sampled.
+// Mutations and the authorization decisions for them are always recorded in full.
+var sampleableOperations = map[string]bool{
+	cts.MethodGetKey:          true,
+	cts.MethodGetKeyMetadata:  true,
+	cts.MethodListKeys:        true,
+	cts.MethodListKeyVersions: true,
+	"BatchGetKeys":            true,
+	cts.AuthKeysRead:          true,
+	cts.AuthKeysList:          true,
+}
+
+// SamplingRule records one in every OneIn successful audits of Operation.
+type SamplingRule struct {
+	Operation string
+	OneIn     int
+}
+
+// SamplingLogger wraps an audit logger and passes on only a sample of the successful
+// audits of read-heavy operations. Failures are always passed on, as is every audit of
+// an operation without a rule. The sample is deterministic: the first event of an
+// operation is recorded and then every OneIn-th.
+type SamplingLogger struct {
+	wrappedLogger
+	rates map[string]*samplingRate
+}
+
+type samplingRate struct {
+	oneIn uint64
+	seen  atomic.Uint64
+}
+
+var _ domain.AuditLogger = (*SamplingLogger)(nil)
+
+// NewSamplingLogger wraps next with the given rules. Only read operations can be
+// sampled; a rule for any other operation is rejected.
+func NewSamplingLogger(next domain.AuditLogger, rules []SamplingRule) (*SamplingLogger, error) {
+	rates := make(map[string]*samplingRate, len(rules))
+	for _, rule := range rules {
+		if !sampleableOperations[rule.Operation] {
+			return nil, fmt.Errorf("audit sampling is not allowed for operation %q", rule.Operation)
+		}
+		if rule.OneIn < 1 {
+			return nil, fmt.Errorf("audit sampling rate for %s must be at least 1, not %d", rule.Operation, rule.OneIn)
+		}
+		if rule.OneIn > 1 {
+			rates[rule.Operation] = &samplingRate{oneIn: uint64(rule.OneIn)}
+		}
+	}
+	return &SamplingLogger{wrappedLogger: wrappedLogger{next: next}, rates: rates}, nil
+}
+
+func (l *SamplingLogger) AuditLog(ctx context.Context, clientIdentity, operation, keyID, authDecisionID string, success bool, err error) {
+	if rate, ok := l.rates[operation]; ok && success && err == nil {
+		if (rate.seen.Add(1)-1)%rate.oneIn != 0 {
+			sampledOutEvents.Add(ctx, 1, metric.WithAttributes(attribute.String("operation", operation)))
+			return
+		}
+	}
+	l.next.AuditLog(ctx, clientIdentity, operation, keyID, authDecisionID, success, err)
+}
@@ -0,0 +1,226 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// sinkRecord is the JSON form of an audit event handed to external sinks.
+type sinkRecord struct {
+	ID              string               `json:"id"`
+	Timestamp       time.Time            `json:"timestamp"`
+	ClientIdentity  string               `json:"client_identity"`
+	Operation       string               `json:"operation"`
+	KeyID           string               `json:"key_id,omitempty"`
+	AuthDecisionID  string               `json:"auth_decision_id,omitempty"`
+	CorrelationID   string               `json:"correlation_id,omitempty"`
+	Success         bool                 `json:"success"`
+	Error           string               `json:"error,omitempty"`
+	RequestMetadata map[string]string    `json:"request_metadata,omitempty"`
+	PeerIP          string               `json:"peer_ip,omitempty"`
+	UserAgent       string               `json:"user_agent,omitempty"`
+	CertFingerprint string               `json:"cert_fingerprint,omitempty"`
+	Changes         []domain.AuditChange `json:"changes,omitempty"`
+	Sequence        int64                `json:"sequence,omitempty"`
+	Hash            string               `json:"hash,omitempty"`
+}
+
+func newSinkRecord(event *domain.AuditEvent) sinkRecord {
+	return sinkRecord{
+		ID:              event.ID,
+		Timestamp:       event.Timestamp,
+		ClientIdentity:  event.ClientIdentity,
+		Operation:       event.Operation,
+		KeyID:           event.KeyID,
+		AuthDecisionID:  event.AuthDecisionID,
+		CorrelationID:   event.CorrelationID,
+		Success:         event.Success,
+		Error:           event.Error,
+		RequestMetadata: event.RequestMetadata,
+		PeerIP:          event.PeerIP,
+		UserAgent:       event.UserAgent,
+		CertFingerprint: event.CertFingerprint,
+		Changes:         event.Changes,
+		Sequence:        event.Sequence,
+		Hash:            event.Hash,
+	}
+}
+
+// publishToSinks hands the events to every sink. A failing sink does not stop the others;
+// sinks wrapped in a QueuedSink only queue the events, so a slow one delays no other.
+func publishToSinks(ctx context.Context, logger *slog.Logger, sinks []domain.AuditSink, events []*domain.AuditEvent) {
+	for _, sink := range sinks {
+		if err := sink.Publish(ctx, events); err != nil {
+			logger.ErrorContext(ctx, "failed to publish audit events", "sink", sink.Name(), "error", err, "batch_size", len(events))
+		}
+	}
+}
+
+// sinkOverflowEvents counts the audit events a sink's queue had no room for, or still
+// held when it was closed, and that went to the dead-letter file instead.
+var sinkOverflowEvents, _ = meter.Int64Counter(
+	"polykey.audit.sink.overflow",
+	metric.WithDescription("Number of audit events written to the dead-letter file instead of a sink, because its queue was full or it was closed before delivering them."),
+)
+
+// QueuedSinkConfig holds the configuration of a QueuedSink. QueueSize is the number of
+// batches queued, DeadLetterPath the absolute path of the file taking the batches that
+// do not fit, and DrainTimeout how long Close waits for the queue to be delivered.
+type QueuedSinkConfig struct {
+	QueueSize      int
+	DeadLetterPath string
+	DrainTimeout   time.Duration
+}
+
+// QueuedSink hands batches to a sink from a goroutine of its own, through a bounded
+// queue, so that a slow or unreachable sink delays neither the audit logger nor the
+// other sinks. Batches that do not fit in the queue, or that are still queued when Close
+// stops waiting, are appended to the dead-letter file, one JSON event per line with the
+// name of the sink, for replay.
+type QueuedSink struct {
+	sink    domain.AuditSink
+	config  QueuedSinkConfig
+	logger  *slog.Logger
+	batches chan []*domain.AuditEvent
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	// mu guards closed, so that no batch is queued once batches is closed.
+	mu         sync.RWMutex
+	closed     bool
+	deadLetter sync.Mutex
+}
+
+var _ domain.AuditSink = (*QueuedSink)(nil)
+
+// deadLetterRecord is an event dead-lettered on the way to a sink.
+type deadLetterRecord struct {
+	Sink string `json:"sink"`
+	sinkRecord
+}
+
+// NewQueuedSink starts delivering the batches queued for sink.
+func NewQueuedSink(logger *slog.Logger, sink domain.AuditSink, config QueuedSinkConfig) *QueuedSink {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &QueuedSink{
+		sink:    sink,
+		config:  config,
+		logger:  logger,
+		batches: make(chan []*domain.AuditEvent, max(config.QueueSize, 1)),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *QueuedSink) Name() string {
+	return q.sink.Name()
+}
+
+// Publish queues the events for the sink, or writes them to the dead-letter file when
+// the queue is full. It only returns an error when they could be neither.
+func (q *QueuedSink) Publish(ctx context.Context, events []*domain.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	q.mu.RLock()
+	if !q.closed {
+		select {
+		case q.batches <- slices.Clone(events):
+			q.mu.RUnlock()
+			return nil
+		default:
+		}
+	}
+	q.mu.RUnlock()
+	q.logger.WarnContext(ctx, "audit sink queue is full, writing to dead-letter file", "sink", q.sink.Name(), "batch_size", len(events))
+	return q.writeDeadLetter(events)
+}
+
+func (q *QueuedSink) run() {
+	defer close(q.done)
+	for batch := range q.batches {
+		if q.ctx.Err() != nil {
+			if err := q.writeDeadLetter(batch); err != nil {
+				q.logger.Error("failed to dead-letter audit events", "sink", q.sink.Name(), "error", err, "batch_size", len(batch))
+			}
+			continue
+		}
+		if err := q.sink.Publish(q.ctx, batch); err != nil {
+			q.logger.Error("failed to publish audit events", "sink", q.sink.Name(), "error", err, "batch_size", len(batch))
+		}
+	}
+}
+
+func (q *QueuedSink) writeDeadLetter(events []*domain.AuditEvent) error {
+	if q.config.DeadLetterPath == "" {
+		return errors.New("no dead-letter file configured")
+	}
+	lines := make([][]byte, 0, len(events))
+	for _, event := range events {
+		line, err := json.Marshal(deadLetterRecord{Sink: q.sink.Name(), sinkRecord: newSinkRecord(event)})
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit event %s: %w", event.ID, err)
+		}
+		lines = append(lines, line)
+	}
+	q.deadLetter.Lock()
+	defer q.deadLetter.Unlock()
+	if err := appendLines(q.config.DeadLetterPath, lines); err != nil {
+		return err
+	}
+	sinkOverflowEvents.Add(context.Background(), int64(len(events)), metric.WithAttributes(attribute.String("sink", q.sink.Name())))
+	return nil
+}
+
+// Close stops taking batches and waits up to DrainTimeout for the queued ones to be
+// delivered. The delivery in progress is then cancelled and the batches left are
+// dead-lettered, before the sink itself is closed.
+func (q *QueuedSink) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.batches)
+	}
+	q.mu.Unlock()
+	timer := time.NewTimer(q.config.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-q.done:
+	case <-timer.C:
+		q.logger.Warn("audit sink did not drain in time, dead-lettering the rest", "sink", q.sink.Name(), "queued", len(q.batches))
+		q.cancel()
+		<-q.done
+	}
+	q.cancel()
+	return q.sink.Close()
+}
+
+// appendLines appends each line, followed by a newline, to the file at path.
+func appendLines(path string, lines [][]byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	for _, line := range lines {
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to write dead-letter file: %w", err)
+		}
+	}
+	return f.Close()
+}
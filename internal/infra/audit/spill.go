@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+const (
+	spillSegmentSuffix  = ".jsonl"
+	spillRejectedSuffix = ".rejected"
+)
+
+var errSpillFull = errors.New("audit spill buffer is full")
+
+// spillRecord is a spilled event. Published records whether the sinks already have it,
+// which is the case for events spilled after a failed database write.
+type spillRecord struct {
+	sinkRecord
+	Published bool `json:"published,omitempty"`
+}
+
+// diskSpill is a bounded on-disk buffer for audit events that could not be queued or
+// written. Events are appended as JSON lines to segment files of at most segmentEvents
+// events each, so that a segment can be replayed in a single batch; segments are named
+// by creation time and replayed oldest first. Appends are not synced individually;
+// a segment is synced when it is sealed.
+type diskSpill struct {
+	dir           string
+	maxBytes      int64
+	segmentEvents int
+
+	mu            sync.Mutex
+	size          int64
+	current       *os.File
+	currentEvents int
+	lastName      string
+}
+
+// openDiskSpill opens the spill directory, keeping any segments left by a previous run
+// for replay.
+func openDiskSpill(dir string, maxBytes int64, segmentEvents int) (*diskSpill, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit spill directory: %w", err)
+	}
+	s := &diskSpill{dir: dir, maxBytes: maxBytes, segmentEvents: max(segmentEvents, 1)}
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range segments {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		s.size += info.Size()
+	}
+	return s, nil
+}
+
+// append writes as many of the events as fit and returns how many that was; the rest
+// is rejected with errSpillFull.
+func (s *diskSpill) append(events []*domain.AuditEvent, published bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, event := range events {
+		record := spillRecord{sinkRecord: newSinkRecord(event), Published: published}
+		// A failed write may have assigned chain fields; the replay assigns new ones.
+		record.Sequence, record.Hash = 0, ""
+		line, err := json.Marshal(record)
+		if err != nil {
+			return i, err
+		}
+		line = append(line, '\n')
+		if s.size+int64(len(line)) > s.maxBytes {
+			return i, errSpillFull
+		}
+		if s.current == nil || s.currentEvents >= s.segmentEvents {
+			if err := s.rotate(); err != nil {
+				return i, err
+			}
+		}
+		if _, err := s.current.Write(line); err != nil {
+			return i, fmt.Errorf("failed to write audit spill segment: %w", err)
+		}
+		s.size += int64(len(line))
+		s.currentEvents++
+	}
+	return len(events), nil
+}
+
+func (s *diskSpill) rotate() error {
+	if err := s.sealCurrent(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%020d%s", time.Now().UnixNano(), spillSegmentSuffix)
+	if name <= s.lastName {
+		// Keep names increasing even if the clock does not move.
+		name = strings.TrimSuffix(s.lastName, spillSegmentSuffix) + "0" + spillSegmentSuffix
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create audit spill segment: %w", err)
+	}
+	s.current, s.currentEvents, s.lastName = f, 0, name
+	return nil
+}
+
+func (s *diskSpill) sealCurrent() error {
+	if s.current == nil {
+		return nil
+	}
+	err := errors.Join(s.current.Sync(), s.current.Close())
+	s.current = nil
+	return err
+}
+
+// seal closes the segment being appended to and returns every segment, oldest first.
+func (s *diskSpill) seal() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.sealCurrent(); err != nil {
+		return nil, err
+	}
+	return s.segments()
+}
+
+func (s *diskSpill) segments() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit spill segments: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), spillSegmentSuffix) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// read returns the events of a sealed segment and whether the sinks already have each.
+func (s *diskSpill) read(name string) ([]*domain.AuditEvent, []bool, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var events []*domain.AuditEvent
+	var published []bool
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record spillRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, nil, fmt.Errorf("corrupt audit spill segment %s: %w", name, err)
+		}
+		events = append(events, &domain.AuditEvent{
+			ID:              record.ID,
+			ClientIdentity:  record.ClientIdentity,
+			Operation:       record.Operation,
+			KeyID:           record.KeyID,
+			AuthDecisionID:  record.AuthDecisionID,
+			CorrelationID:   record.CorrelationID,
+			Success:         record.Success,
+			Error:           record.Error,
+			Timestamp:       record.Timestamp,
+			RequestMetadata: record.RequestMetadata,
+			PeerIP:          record.PeerIP,
+			UserAgent:       record.UserAgent,
+			CertFingerprint: record.CertFingerprint,
+			Changes:         record.Changes,
+		})
+		published = append(published, record.Published)
+	}
+	return events, published, scanner.Err()
+}
+
+// remove deletes a replayed segment.
+func (s *diskSpill) remove(name string) error {
+	return s.discard(name, "")
+}
+
+// reject sets aside a segment that cannot be replayed, for an operator to inspect; it no
+// longer counts towards the size limit.
+func (s *diskSpill) reject(name string) error {
+	return s.discard(name, name+spillRejectedSuffix)
+}
+
+func (s *diskSpill) discard(name, rename string) error {
+	path := filepath.Join(s.dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if rename == "" {
+		err = os.Remove(path)
+	} else {
+		err = os.Rename(path, filepath.Join(s.dir, rename))
+	}
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.size -= info.Size()
+	s.mu.Unlock()
+	return nil
+}
+
+// full reports whether less than one typical event fits.
+func (s *diskSpill) full() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxBytes-s.size < 1024
+}
+
+func (s *diskSpill) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sealCurrent()
+}
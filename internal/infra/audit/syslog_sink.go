@@ -0,0 +1,338 @@
+package audit
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// Syslog message formats.
+const (
+	SyslogFormatRFC5424 = "rfc5424"
+	SyslogFormatCEF     = "cef"
+)
+
+// syslogSDID is the structured data ID of RFC 5424 messages. 32473 is the private
+// enterprise number reserved for documentation, which RFC 5424 uses in its examples.
+const syslogSDID = "polykey@32473"
+
+// SyslogSinkConfig holds the configuration for the syslog audit sink. Network is "udp",
+// "tcp" or "tls"; TLSConfig is used for "tls".
+type SyslogSinkConfig struct {
+	Network      string
+	Address      string
+	Format       string
+	Facility     int
+	AppName      string
+	Hostname     string
+	TLSConfig    *tls.Config
+	MaxRetries   int
+	WriteTimeout time.Duration
+}
+
+// SyslogSink sends audit events to a syslog collector as RFC 5424 messages, either with
+// the event in structured data or, in the CEF format, with a CEF record as the message,
+// for SIEMs that take neither Kafka nor webhooks. Successful operations are logged at
+// informational and failed ones at warning severity. Over TCP and TLS messages are
+// framed by octet counting (RFC 6587); over UDP each message is one datagram.
+type SyslogSink struct {
+	config   SyslogSinkConfig
+	logger   *slog.Logger
+	version  string
+	procID   string
+	mu       sync.Mutex
+	conn     net.Conn
+	closed   bool
+	streamed bool
+}
+
+var _ domain.AuditSink = (*SyslogSink)(nil)
+
+// NewSyslogSink creates a syslog audit sink. The connection is made lazily on first publish.
+func NewSyslogSink(logger *slog.Logger, config SyslogSinkConfig) (*SyslogSink, error) {
+	switch config.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("syslog audit sink network must be udp, tcp or tls, not %q", config.Network)
+	}
+	if config.Address == "" {
+		return nil, errors.New("syslog audit sink requires an address")
+	}
+	switch config.Format {
+	case "":
+		config.Format = SyslogFormatRFC5424
+	case SyslogFormatRFC5424, SyslogFormatCEF:
+	default:
+		return nil, fmt.Errorf("syslog audit sink format must be %s or %s, not %q", SyslogFormatRFC5424, SyslogFormatCEF, config.Format)
+	}
+	if config.Facility < 0 || config.Facility > 23 {
+		return nil, fmt.Errorf("syslog facility %d is out of range", config.Facility)
+	}
+	if config.AppName == "" {
+		config.AppName = "polykey"
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+
+	version := "dev"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	return &SyslogSink{
+		config:   config,
+		logger:   logger,
+		version:  version,
+		procID:   strconv.Itoa(os.Getpid()),
+		streamed: config.Network != "udp",
+	}, nil
+}
+
+func (s *SyslogSink) Name() string {
+	return "syslog"
+}
+
+// Publish sends one message per event. A failed write drops the connection and is retried
+// on a new one, up to MaxRetries times.
+func (s *SyslogSink) Publish(ctx context.Context, events []*domain.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("syslog audit sink is closed")
+	}
+
+	for i, event := range events {
+		if err := s.writeWithRetry(ctx, s.frame(s.message(event))); err != nil {
+			return fmt.Errorf("failed to send %d of %d audit events to syslog: %w", len(events)-i, len(events), err)
+		}
+	}
+	return nil
+}
+
+func (s *SyslogSink) writeWithRetry(ctx context.Context, frame []byte) error {
+	var err error
+	for attempt := 0; attempt <= s.config.MaxRetries; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return errors.Join(err, ctxErr)
+		}
+		if s.conn == nil {
+			if s.conn, err = s.dial(ctx); err != nil {
+				continue
+			}
+		}
+		if s.config.WriteTimeout > 0 {
+			_ = s.conn.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout))
+		}
+		if _, err = s.conn.Write(frame); err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *SyslogSink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.config.WriteTimeout}
+	if s.config.Network == "tls" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: s.config.TLSConfig}
+		return tlsDialer.DialContext(ctx, "tcp", s.config.Address)
+	}
+	return dialer.DialContext(ctx, s.config.Network, s.config.Address)
+}
+
+func (s *SyslogSink) frame(message string) []byte {
+	if s.streamed {
+		return []byte(strconv.Itoa(len(message)) + " " + message)
+	}
+	return []byte(message)
+}
+
+// message renders the RFC 5424 message for an event:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG.
+func (s *SyslogSink) message(event *domain.AuditEvent) string {
+	severity := 6 // informational
+	if !event.Success {
+		severity = 4 // warning
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ",
+		s.config.Facility*8+severity,
+		event.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(s.config.Hostname, 255),
+		syslogHeaderField(s.config.AppName, 48),
+		syslogHeaderField(s.procID, 128),
+		syslogHeaderField(event.Operation, 32))
+
+	if s.config.Format == SyslogFormatCEF {
+		b.WriteString("- ")
+		b.WriteString(s.cef(event))
+		return b.String()
+	}
+
+	b.WriteString("[" + syslogSDID)
+	writeSDParam(&b, "id", event.ID)
+	writeSDParam(&b, "client", event.ClientIdentity)
+	writeSDParam(&b, "operation", event.Operation)
+	writeSDParam(&b, "keyId", event.KeyID)
+	writeSDParam(&b, "authDecisionId", event.AuthDecisionID)
+	writeSDParam(&b, "correlationId", event.CorrelationID)
+	writeSDParam(&b, "success", strconv.FormatBool(event.Success))
+	writeSDParam(&b, "error", event.Error)
+	writeSDParam(&b, "peerIp", event.PeerIP)
+	writeSDParam(&b, "userAgent", event.UserAgent)
+	writeSDParam(&b, "certFingerprint", event.CertFingerprint)
+	writeSDParam(&b, "changes", changesJSON(event.Changes))
+	if event.Sequence > 0 {
+		writeSDParam(&b, "seq", strconv.FormatInt(event.Sequence, 10))
+		writeSDParam(&b, "hash", event.Hash)
+	}
+	b.WriteString("]")
+	if event.Success {
+		b.WriteString(" " + event.Operation + " succeeded")
+	} else {
+		b.WriteString(" " + event.Operation + " failed")
+	}
+	return b.String()
+}
+
+// cef renders the event as a CEF record:
+// CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension.
+func (s *SyslogSink) cef(event *domain.AuditEvent) string {
+	severity, outcome := "3", "success"
+	if !event.Success {
+		severity, outcome = "7", "failure"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|Spounge|Polykey|%s|%s|%s|%s|",
+		cefHeaderField(s.version), cefHeaderField(event.Operation), cefHeaderField(event.Operation), severity)
+
+	extensions := [][2]string{
+		{"rt", strconv.FormatInt(event.Timestamp.UnixMilli(), 10)},
+		{"externalId", event.ID},
+		{"suser", event.ClientIdentity},
+		{"act", event.Operation},
+		{"outcome", outcome},
+		{"cs1Label", "keyId"},
+		{"cs1", event.KeyID},
+		{"cs2Label", "correlationId"},
+		{"cs2", event.CorrelationID},
+		{"cs3Label", "authDecisionId"},
+		{"cs3", event.AuthDecisionID},
+	}
+	if event.Error != "" {
+		extensions = append(extensions, [2]string{"reason", event.Error})
+	}
+	if event.PeerIP != "" {
+		extensions = append(extensions, [2]string{"src", event.PeerIP})
+	}
+	if event.UserAgent != "" {
+		extensions = append(extensions, [2]string{"requestClientApplication", event.UserAgent})
+	}
+	if event.CertFingerprint != "" {
+		extensions = append(extensions, [2]string{"cs5Label", "certFingerprint"}, [2]string{"cs5", event.CertFingerprint})
+	}
+	if len(event.Changes) > 0 {
+		extensions = append(extensions, [2]string{"cs6Label", "changes"}, [2]string{"cs6", changesJSON(event.Changes)})
+	}
+	if event.Sequence > 0 {
+		extensions = append(extensions,
+			[2]string{"cn1Label", "sequence"},
+			[2]string{"cn1", strconv.FormatInt(event.Sequence, 10)},
+			[2]string{"cs4Label", "hash"},
+			[2]string{"cs4", event.Hash})
+	}
+	for i, ext := range extensions {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(ext[0] + "=" + cefExtensionValue(ext[1]))
+	}
+	return b.String()
+}
+
+// changesJSON renders the changes as a JSON array, or "" when there are none.
+func changesJSON(changes []domain.AuditChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// syslogHeaderField returns the value as a header field: printable ASCII without spaces,
+// at most maxLen long, or the nil value "-" when empty.
+func syslogHeaderField(value string, maxLen int) string {
+	clean := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if clean == "" {
+		return "-"
+	}
+	if len(clean) > maxLen {
+		clean = clean[:maxLen]
+	}
+	return clean
+}
+
+// writeSDParam appends a structured data parameter, escaping '"', '\' and ']'. Empty
+// values are left out.
+func writeSDParam(b *strings.Builder, name, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteString(" " + name + `="`)
+	for _, r := range value {
+		if r == '"' || r == '\\' || r == ']' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeaderField(value string) string {
+	return cefHeaderEscaper.Replace(value)
+}
+
+func cefExtensionValue(value string) string {
+	return cefExtensionEscaper.Replace(value)
+}
+
+// Close closes the connection to the collector.
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
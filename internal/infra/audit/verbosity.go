@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// Verbosity is how much is recorded about an audited operation.
+type Verbosity string
+
+const (
+	// VerbosityOff records nothing.
+	VerbosityOff Verbosity = "off"
+	// VerbosityMinimal records who did what to which key and the outcome, without the
+	// caller's connection details or the changes made.
+	VerbosityMinimal Verbosity = "minimal"
+	// VerbosityFull also records the caller's connection details and, for operations
+	// that change metadata, the fields changed with their old and new values.
+	VerbosityFull Verbosity = "full"
+)
+
+// ParseVerbosity returns the verbosity named by s.
+func ParseVerbosity(s string) (Verbosity, error) {
+	switch v := Verbosity(s); v {
+	case VerbosityOff, VerbosityMinimal, VerbosityFull:
+		return v, nil
+	default:
+		return "", fmt.Errorf("audit verbosity must be %s, %s or %s, not %q", VerbosityOff, VerbosityMinimal, VerbosityFull, s)
+	}
+}
+
+type minimalAuditKey struct{}
+
+// VerbosityLogger wraps an audit logger and applies a verbosity to each operation:
+// operations set to off are not passed on, and those set to minimal are passed on
+// without the details a full audit adds.
+type VerbosityLogger struct {
+	wrappedLogger
+	defaultLevel Verbosity
+	levels       map[string]Verbosity
+}
+
+var _ domain.AuditLogger = (*VerbosityLogger)(nil)
+
+// NewVerbosityLogger wraps next, auditing the operations in levels at their verbosity
+// and all others at defaultLevel.
+func NewVerbosityLogger(next domain.AuditLogger, defaultLevel Verbosity, levels map[string]Verbosity) *VerbosityLogger {
+	return &VerbosityLogger{wrappedLogger: wrappedLogger{next: next}, defaultLevel: defaultLevel, levels: levels}
+}
+
+func (l *VerbosityLogger) AuditLog(ctx context.Context, clientIdentity, operation, keyID, authDecisionID string, success bool, err error) {
+	level, ok := l.levels[operation]
+	if !ok {
+		level = l.defaultLevel
+	}
+	switch level {
+	case VerbosityOff:
+		return
+	case VerbosityMinimal:
+		ctx = context.WithValue(ctx, minimalAuditKey{}, true)
+	}
+	l.next.AuditLog(ctx, clientIdentity, operation, keyID, authDecisionID, success, err)
+}
+
+// setRequestDetails adds what a full audit records beyond the operation itself: the
+// caller's connection and the changes the operation made.
+func setRequestDetails(ctx context.Context, event *domain.AuditEvent) {
+	if minimal, _ := ctx.Value(minimalAuditKey{}).(bool); minimal {
+		return
+	}
+	setCallerMetadata(ctx, event)
+	event.Changes = domain.AuditChangesFromContext(ctx)
+}
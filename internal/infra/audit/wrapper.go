@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// wrappedLogger is embedded by the loggers that filter audits before handing them to
+// another logger, so that the wrapped logger is still health checked and stopped.
+type wrappedLogger struct {
+	next domain.AuditLogger
+}
+
+// HealthCheck reports the health of the wrapped logger, if it has any.
+func (w wrappedLogger) HealthCheck(ctx context.Context) error {
+	if probe, ok := w.next.(interface{ HealthCheck(context.Context) error }); ok {
+		return probe.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// Stop stops the wrapped logger, if it needs stopping.
+func (w wrappedLogger) Stop() {
+	if logger, ok := w.next.(interface{ Stop() }); ok {
+		logger.Stop()
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/spounge-ai/polykey/internal/constants"
@@ -23,8 +24,13 @@ var tracer = otel.Tracer("github.com/spounge-ai/polykey/internal/infra/auth")
 
 // NewAuthorizer creates a new authorizer.
 func NewAuthorizer(cfg config.AuthorizationConfig, keyRepo domain.KeyRepository, auditLogger domain.AuditLogger) domain.Authorizer {
+	roles := make(map[string]config.RoleConfig, len(cfg.Roles))
+	for name, role := range cfg.Roles {
+		roles[name] = role
+	}
 	return &realAuthorizer{
 		cfg:         cfg,
+		roles:       roles,
 		keyRepo:     keyRepo,
 		auditLogger: auditLogger,
 		policyCache: cache.New(
@@ -39,10 +45,33 @@ type realAuthorizer struct {
 	keyRepo     domain.KeyRepository
 	policyCache cache.Store[string, bool]
 	auditLogger domain.AuditLogger
+
+	// roles starts as a copy of cfg.Roles and is changed through the RoleManager methods.
+	rolesMu sync.RWMutex
+	roles   map[string]config.RoleConfig
+
+	// generations counts the flushes of each key. It is part of the cache key, so a
+	// flush makes every decision cached for the key unreachable, including one being
+	// made from the metadata the flush replaced.
+	generationsMu sync.Mutex
+	generations   map[domain.KeyID]uint64
+}
+
+var (
+	_ domain.RoleManager  = (*realAuthorizer)(nil)
+	_ domain.CacheFlusher = (*realAuthorizer)(nil)
+)
+
+// getCacheKey includes the user's step-up state so that a decision made for a
+// step-up token is never reused for a token without one.
+func (a *realAuthorizer) getCacheKey(user *domain.AuthenticatedUser, operation string, keyID domain.KeyID) string {
+	return fmt.Sprintf("%s:%s:%s:%d:%t", user.ID, operation, keyID.String(), a.generation(keyID), a.hasStepUp(user))
 }
 
-func (a *realAuthorizer) getCacheKey(userID, operation string, keyID domain.KeyID) string {
-	return fmt.Sprintf("%s:%s:%s", userID, operation, keyID.String())
+func (a *realAuthorizer) generation(keyID domain.KeyID) uint64 {
+	a.generationsMu.Lock()
+	defer a.generationsMu.Unlock()
+	return a.generations[keyID]
 }
 
 // Authorize checks if the authenticated user in the context is permitted to perform the given operation.
@@ -82,7 +111,7 @@ func (a *realAuthorizer) Authorize(ctx context.Context, reqContext *pk.Requester
 		}
 	}
 
-	cacheKey := a.getCacheKey(user.ID, operation, keyID)
+	cacheKey := a.getCacheKey(user, operation, keyID)
 	if authorized, found := a.policyCache.Get(ctx, cacheKey); found {
 		span.SetAttributes(attribute.Bool("auth.cache_hit", true))
 		if !authorized {
@@ -124,26 +153,20 @@ func (a *realAuthorizer) checkIdentityMatch(ctx context.Context, user *domain.Au
 
 func (a *realAuthorizer) checkAuthorization(ctx context.Context, user *domain.AuthenticatedUser, operation string, keyID domain.KeyID, reqContext *pk.RequesterContext) (bool, string) {
 	// Check if the user has an admin role that bypasses resource-specific checks.
-	for _, roleName := range user.Permissions {
-		if roleName == "*" {
-			return true, "authorized_by_admin_role"
-		}
-		if role, ok := a.cfg.Roles[roleName]; ok {
-			if slices.Contains(role.AllowedOperations, "*") {
-				return true, "authorized_by_admin_role"
-			}
-		}
-	}
+	isAdmin := a.isAdmin(user)
 
 	// If keyID is not provided, we can't do resource-based authorization.
 	// This applies to operations like CreateKey or ListKeys.
 	if keyID.IsZero() {
+		if isAdmin {
+			return true, "authorized_by_admin_role"
+		}
 		return true, "authorized"
 	}
 
 	// For operations on a specific key, perform resource-based authorization.
 	switch operation {
-	case constants.AuthKeysRead, constants.AuthKeysRotate, constants.AuthKeysRevoke, constants.AuthKeysUpdate:
+	case constants.AuthKeysRead, constants.AuthKeysRotate, constants.AuthKeysRevoke, constants.AuthKeysUpdate, constants.AuthKeysRestore, constants.AuthKeysTransfer:
 		key, err := a.keyRepo.GetKey(ctx, keyID)
 		if err != nil {
 			if errors.Is(err, postgres.ErrKeyNotFound) {
@@ -157,6 +180,15 @@ func (a *realAuthorizer) checkAuthorization(ctx context.Context, user *domain.Au
 			return false, "key_missing_metadata"
 		}
 
+		// Step-up is a property of the key, so it applies to admins as well.
+		if requiresStepUp(key) && !a.hasStepUp(user) {
+			return false, domain.ReasonStepUpRequired
+		}
+
+		if isAdmin {
+			return true, "authorized_by_admin_role"
+		}
+
 		// Check if user is in the key's authorized contexts.
 		if !slices.Contains(key.Metadata.AuthorizedContexts, user.ID) {
 			return false, "insufficient_key_permissions"
@@ -173,9 +205,54 @@ func (a *realAuthorizer) checkAuthorization(ctx context.Context, user *domain.Au
 		}
 	}
 
+	if isAdmin {
+		return true, "authorized_by_admin_role"
+	}
 	return true, "authorized"
 }
 
+// ListScope limits the listings of a non-admin caller to the keys that list it among
+// their authorized contexts, the keys a key-level check would let it read. A context
+// without a user sees nothing, rather than everything.
+func (a *realAuthorizer) ListScope(ctx context.Context) (string, bool) {
+	user, ok := domain.UserFromContext(ctx)
+	if !ok {
+		return "", true
+	}
+	if a.isAdmin(user) {
+		return "", false
+	}
+	return user.ID, true
+}
+
+func (a *realAuthorizer) isAdmin(user *domain.AuthenticatedUser) bool {
+	for _, roleName := range user.Permissions {
+		if roleName == "*" {
+			return true
+		}
+		if role, ok := a.role(roleName); ok {
+			if slices.Contains(role.AllowedOperations, "*") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasStepUp reports whether the user's token carries an accepted amr or acr assertion.
+func (a *realAuthorizer) hasStepUp(user *domain.AuthenticatedUser) bool {
+	for _, method := range user.AuthMethods {
+		if slices.Contains(a.cfg.StepUp.AcceptedAMR, method) {
+			return true
+		}
+	}
+	return user.AuthContextClass != "" && slices.Contains(a.cfg.StepUp.AcceptedACR, user.AuthContextClass)
+}
+
+func requiresStepUp(key *domain.Key) bool {
+	return key.Metadata.GetAccessPolicies()[constants.PolicyRequireStepUp] == "true"
+}
+
 // authenticateAndAuthorize checks the user's permissions from the context against the required operation.
 func (a *realAuthorizer) authenticateAndAuthorize(ctx context.Context, operation string) (*domain.AuthenticatedUser, bool, string) {
 	user, ok := domain.UserFromContext(ctx)
@@ -187,7 +264,7 @@ func (a *realAuthorizer) authenticateAndAuthorize(ctx context.Context, operation
 		if roleName == "*" {
 			return user, true, "authorized" // Wildcard admin role
 		}
-		if role, ok := a.cfg.Roles[roleName]; ok {
+		if role, ok := a.role(roleName); ok {
 			if slices.Contains(role.AllowedOperations, "*") {
 				return user, true, "authorized" // Wildcard operation in role
 			}
@@ -199,3 +276,57 @@ func (a *realAuthorizer) authenticateAndAuthorize(ctx context.Context, operation
 
 	return nil, false, "operation_not_allowed"
 }
+
+func (a *realAuthorizer) role(name string) (config.RoleConfig, bool) {
+	a.rolesMu.RLock()
+	defer a.rolesMu.RUnlock()
+	role, ok := a.roles[name]
+	return role, ok
+}
+
+// Roles returns the allowed operations of every role.
+func (a *realAuthorizer) Roles() map[string][]string {
+	a.rolesMu.RLock()
+	defer a.rolesMu.RUnlock()
+	roles := make(map[string][]string, len(a.roles))
+	for name, role := range a.roles {
+		roles[name] = slices.Clone(role.AllowedOperations)
+	}
+	return roles
+}
+
+// PutRole defines role with allowedOperations. Cached decisions are dropped, since they
+// may have been made with the role's previous operations.
+func (a *realAuthorizer) PutRole(role string, allowedOperations []string) {
+	a.rolesMu.Lock()
+	a.roles[role] = config.RoleConfig{AllowedOperations: slices.Clone(allowedOperations)}
+	a.rolesMu.Unlock()
+	a.policyCache.Clear(context.Background())
+}
+
+// DeleteRole removes role and drops the cached decisions.
+func (a *realAuthorizer) DeleteRole(role string) bool {
+	a.rolesMu.Lock()
+	_, ok := a.roles[role]
+	delete(a.roles, role)
+	a.rolesMu.Unlock()
+	if ok {
+		a.policyCache.Clear(context.Background())
+	}
+	return ok
+}
+
+// FlushCache drops the cached authorization decisions.
+func (a *realAuthorizer) FlushCache(ctx context.Context) {
+	a.policyCache.Clear(ctx)
+}
+
+// FlushKey drops the decisions cached for one key, which then expire unused.
+func (a *realAuthorizer) FlushKey(_ context.Context, id domain.KeyID) {
+	a.generationsMu.Lock()
+	defer a.generationsMu.Unlock()
+	if a.generations == nil {
+		a.generations = make(map[domain.KeyID]uint64)
+	}
+	a.generations[id]++
+}
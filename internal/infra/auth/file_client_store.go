@@ -3,7 +3,13 @@ package auth
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
 	"gopkg.in/yaml.v3"
@@ -24,14 +30,27 @@ type clientData struct {
 	HashedAPIKey string   `yaml:"hashed_api_key"`
 	Permissions  []string `yaml:"permissions"`
 	Description  string   `yaml:"description,omitempty"`
+	Namespace    string   `yaml:"namespace,omitempty"`
+	Tier         string   `yaml:"tier,omitempty"`
+	// TokenTTL is a duration such as "12h".
+	TokenTTL string `yaml:"token_ttl,omitempty"`
+	// TOTPSecret is the base32 secret of the client's one-time passwords.
+	TOTPSecret string `yaml:"totp_secret,omitempty"`
 }
 
 // FileClientStore implements the domain.ClientStore interface using a local YAML file.
-// It holds an in-memory map of clients for fast O(1) lookups.
+// It holds an in-memory map of clients for fast O(1) lookups. Changes made through the
+// domain.ClientManager methods are written back to the file.
 type FileClientStore struct {
-	clients map[string]domain.Client
+	path string
+
+	mu           sync.RWMutex
+	clients      map[string]domain.Client
+	descriptions map[string]string
 }
 
+var _ domain.ClientManager = (*FileClientStore)(nil)
+
 // NewFileClientStore creates and initializes a new FileClientStore from a given file path.
 // It validates the configuration and pre-populates the in-memory store.
 func NewFileClientStore(filePath string) (*FileClientStore, error) {
@@ -50,25 +69,40 @@ func NewFileClientStore(filePath string) (*FileClientStore, error) {
 	}
 
 	clients := make(map[string]domain.Client, len(config.Clients))
+	descriptions := make(map[string]string)
 	for id, data := range config.Clients {
 		if err := validateClientData(id, data); err != nil {
 			return nil, fmt.Errorf("invalid client %s: %w", id, err)
 		}
 
+		namespace := data.Namespace
+		if namespace == "" {
+			namespace = domain.DefaultNamespace
+		}
+		tokenTTL, _ := parseTokenTTL(data.TokenTTL)
 		clients[id] = domain.Client{
 			ID:           id,
 			HashedAPIKey: data.HashedAPIKey,
 			Permissions:  data.Permissions,
+			Namespace:    namespace,
+			Tier:         domain.KeyTier(data.Tier),
+			TokenTTL:     tokenTTL,
+			TOTPSecret:   data.TOTPSecret,
+		}
+		if data.Description != "" {
+			descriptions[id] = data.Description
 		}
 	}
 
-	return &FileClientStore{clients: clients}, nil
+	return &FileClientStore{path: filePath, clients: clients, descriptions: descriptions}, nil
 }
 
 // FindClientByID finds a client by its ID in the in-memory map.
 // Returns ErrClientNotFound if the client doesn't exist.
 func (s *FileClientStore) FindClientByID(ctx context.Context, clientID string) (*domain.Client, error) {
+	s.mu.RLock()
 	client, exists := s.clients[clientID]
+	s.mu.RUnlock()
 	if !exists {
 		return nil, fmt.Errorf("%w: client ID '%s'", ErrClientNotFound, clientID)
 	}
@@ -78,15 +112,145 @@ func (s *FileClientStore) FindClientByID(ctx context.Context, clientID string) (
 		ID:           client.ID,
 		HashedAPIKey: client.HashedAPIKey,
 		Permissions:  append([]string(nil), client.Permissions...),
+		Namespace:    client.Namespace,
+		Tier:         client.Tier,
+		TokenTTL:     client.TokenTTL,
+		TOTPSecret:   client.TOTPSecret,
 	}, nil
 }
 
 // GetClientCount returns the number of configured clients.
 // Useful for monitoring and health checks.
 func (s *FileClientStore) GetClientCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return len(s.clients)
 }
 
+// ListClients returns the configured clients sorted by ID.
+func (s *FileClientStore) ListClients(ctx context.Context) ([]domain.Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clients := make([]domain.Client, 0, len(s.clients))
+	for _, client := range s.clients {
+		client.Permissions = slices.Clone(client.Permissions)
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients, nil
+}
+
+// PutClient validates client, registers it in place of a client with the same ID and
+// writes the clients to the file. The store is left unchanged if the file cannot be
+// written.
+func (s *FileClientStore) PutClient(ctx context.Context, client domain.Client) error {
+	if err := validateClientData(client.ID, clientDataOf(client)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if client.Namespace == "" {
+		client.Namespace = domain.DefaultNamespace
+	}
+	client.Permissions = slices.Clone(client.Permissions)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients := maps.Clone(s.clients)
+	clients[client.ID] = client
+	if err := s.save(clients); err != nil {
+		return err
+	}
+	s.clients = clients
+	return nil
+}
+
+// DeleteClient removes the client with clientID and writes the clients to the file. The
+// last client cannot be removed, since the file would no longer load.
+func (s *FileClientStore) DeleteClient(ctx context.Context, clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.clients[clientID]; !exists {
+		return fmt.Errorf("%w: client ID '%s'", ErrClientNotFound, clientID)
+	}
+	if len(s.clients) == 1 {
+		return fmt.Errorf("%w: the last client cannot be deleted", ErrInvalidConfig)
+	}
+
+	clients := maps.Clone(s.clients)
+	delete(clients, clientID)
+	if err := s.save(clients); err != nil {
+		return err
+	}
+	s.clients = clients
+	delete(s.descriptions, clientID)
+	return nil
+}
+
+// save writes clients to the file through a temporary file, so that a failed write
+// never leaves a truncated file behind.
+func (s *FileClientStore) save(clients map[string]domain.Client) error {
+	config := clientConfig{Clients: make(map[string]clientData, len(clients))}
+	for id, client := range clients {
+		data := clientDataOf(client)
+		data.Description = s.descriptions[id]
+		config.Clients[id] = data
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal client config: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write client config file %s: %w", s.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write client config file %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write client config file %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write client config file %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// clientDataOf returns client as it is written to the file.
+func clientDataOf(client domain.Client) clientData {
+	namespace := client.Namespace
+	if namespace == domain.DefaultNamespace {
+		namespace = ""
+	}
+	data := clientData{
+		HashedAPIKey: client.HashedAPIKey,
+		Permissions:  client.Permissions,
+		Namespace:    namespace,
+		Tier:         string(client.Tier),
+		TOTPSecret:   client.TOTPSecret,
+	}
+	if client.TokenTTL != 0 {
+		data.TokenTTL = client.TokenTTL.String()
+	}
+	return data
+}
+
+// parseTokenTTL parses the token_ttl of a client, which is empty for none.
+func parseTokenTTL(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("token_ttl must be a positive duration such as \"12h\"")
+	}
+	return ttl, nil
+}
+
 // validateClientData validates individual client configuration
 func validateClientData(id string, data clientData) error {
 	if id == "" {
@@ -104,5 +268,18 @@ func validateClientData(id string, data clientData) error {
 		data.HashedAPIKey[:4] != "$2b$" && data.HashedAPIKey[:4] != "$2y$") {
 		return fmt.Errorf("hashed_api_key must be a valid bcrypt hash")
 	}
+	switch domain.KeyTier(data.Tier) {
+	case "", domain.TierFree, domain.TierPro, domain.TierEnterprise:
+	default:
+		return fmt.Errorf("tier must be free, pro or enterprise")
+	}
+	if _, err := parseTokenTTL(data.TokenTTL); err != nil {
+		return err
+	}
+	if data.TOTPSecret != "" {
+		if _, err := decodeTOTPSecret(data.TOTPSecret); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -1,13 +1,86 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
 // Claims represents the JWT claims.
 
 type Claims struct {
-	UserID string   `json:"user_id"`
-	Roles  []string `json:"roles"`
+	UserID    string   `json:"user_id"`
+	Roles     []string `json:"roles"`
+	AMR       []string `json:"amr,omitempty"`
+	ACR       string   `json:"acr,omitempty"`
+	Namespace string   `json:"ns,omitempty"`
+	// Confirmation binds the token to the client certificate it was issued to.
+	Confirmation *Confirmation `json:"cnf,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// Confirmation is the cnf claim of RFC 8705: the SHA-256 thumbprint of the certificate
+// whose key must be presented with the token.
+type Confirmation struct {
+	X5tS256 string `json:"x5t#S256"`
+}
+
+// ErrCertificateBinding is returned for a token presented without the client
+// certificate it is bound to.
+var ErrCertificateBinding = errors.New("token is not bound to the presented client certificate")
+
+// TokenOption customizes the claims of a generated token.
+type TokenOption func(*Claims)
+
+// WithAuthMethods records the authentication methods (amr) used by the subject.
+func WithAuthMethods(methods ...string) TokenOption {
+	return func(c *Claims) {
+		c.AMR = append(c.AMR, methods...)
+	}
+}
+
+// WithNamespace scopes the subject to the keys of one namespace.
+func WithNamespace(namespace string) TokenOption {
+	return func(c *Claims) {
+		c.Namespace = namespace
+	}
+}
+
+// WithAuthContextClass records the authentication context class (acr) of the subject.
+func WithAuthContextClass(acr string) TokenOption {
+	return func(c *Claims) {
+		c.ACR = acr
+	}
+}
+
+// WithCertificateBinding binds the token to cert, so that it is only accepted on
+// connections authenticated with the same certificate.
+func WithCertificateBinding(cert *x509.Certificate) TokenOption {
+	return func(c *Claims) {
+		c.Confirmation = &Confirmation{X5tS256: CertificateThumbprint(cert)}
+	}
+}
+
+// CertificateThumbprint returns the x5t#S256 thumbprint of cert: the unpadded base64url
+// SHA-256 of its DER encoding.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyCertificateBinding checks that a token bound to a certificate is presented with
+// it. cert is the client certificate of the connection and may be nil. Tokens that are
+// not bound pass.
+func VerifyCertificateBinding(claims *Claims, cert *x509.Certificate) error {
+	if claims.Confirmation == nil || claims.Confirmation.X5tS256 == "" {
+		return nil
+	}
+	if cert == nil || subtle.ConstantTimeCompare([]byte(claims.Confirmation.X5tS256), []byte(CertificateThumbprint(cert))) != 1 {
+		return ErrCertificateBinding
+	}
+	return nil
+}
@@ -4,51 +4,112 @@ import (
 	"context"
 	"crypto/rsa"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/clock"
 )
 
 // TokenManager manages JWT token generation and validation using RSA keys.
 type TokenManager struct {
+	mu         sync.RWMutex
 	privateKey *rsa.PrivateKey
 	publicKey  *rsa.PublicKey
-	tokenStore TokenStore
+	// previousKey is the public key before the last rotation, which keeps validating the
+	// tokens signed with it until they expire.
+	previousKey *rsa.PublicKey
+	tokenStore  TokenStore
 	auditLogger domain.AuditLogger
+	clock       clock.Clock
 }
 
-// NewTokenManager creates a new TokenManager from a PEM-encoded RSA private key.
-func NewTokenManager(privateKeyPEM string, tokenStore TokenStore, auditLogger domain.AuditLogger) (*TokenManager, error) {
+// NewTokenManager creates a new TokenManager from a PEM-encoded RSA private key. Tokens
+// are issued, and their expiry checked, at the times clk reports.
+func NewTokenManager(privateKeyPEM string, tokenStore TokenStore, auditLogger domain.AuditLogger, clk clock.Clock) (*TokenManager, error) {
 	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKeyPEM))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse RSA private key: %w", err)
 	}
 
 	return &TokenManager{
-		privateKey: privateKey,
-		publicKey:  &privateKey.PublicKey,
-		tokenStore: tokenStore,
+		privateKey:  privateKey,
+		publicKey:   &privateKey.PublicKey,
+		tokenStore:  tokenStore,
 		auditLogger: auditLogger,
+		clock:       clk,
 	}, nil
 }
 
+// RotateKey replaces the signing key with a PEM-encoded RSA private key. Tokens signed
+// with the replaced key stay valid until the next rotation or their expiry.
+func (tm *TokenManager) RotateKey(privateKeyPEM string) error {
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKeyPEM))
+	if err != nil {
+		return fmt.Errorf("failed to parse RSA private key: %w", err)
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.privateKey.Equal(privateKey) {
+		return nil
+	}
+	tm.previousKey = tm.publicKey
+	tm.privateKey = privateKey
+	tm.publicKey = &privateKey.PublicKey
+	return nil
+}
+
 // GenerateToken generates a new JWT token signed with RS256.
-func (tm *TokenManager) GenerateToken(userID string, roles []string, expiration time.Duration) (string, error) {
-	expirationTime := time.Now().Add(expiration)
+func (tm *TokenManager) GenerateToken(userID string, roles []string, expiration time.Duration, opts ...TokenOption) (string, error) {
+	now := tm.clock.Now()
+	expirationTime := now.Add(expiration)
 	claims := &Claims{
 		UserID: userID,
 		Roles:  roles,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
+	for _, opt := range opts {
+		opt(claims)
+	}
+
+	tm.mu.RLock()
+	privateKey := tm.privateKey
+	tm.mu.RUnlock()
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	return token.SignedString(tm.privateKey)
+	signed, err := token.SignedString(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	// A token that is not tracked could not be revoked with the rest of its client's.
+	if sessions := tm.Sessions(); sessions != nil {
+		session := domain.TokenSession{
+			TokenID:   claims.ID,
+			ClientID:  claims.UserID,
+			Namespace: claims.Namespace,
+			IssuedAt:  claims.IssuedAt.Time,
+			ExpiresAt: expirationTime,
+		}
+		if err := sessions.TrackToken(context.Background(), session); err != nil {
+			return "", fmt.Errorf("failed to track token: %w", err)
+		}
+	}
+	return signed, nil
+}
+
+// Sessions returns the token store as a domain.TokenSessionStore, or nil when it does
+// not track the tokens it is given.
+func (tm *TokenManager) Sessions() domain.TokenSessionStore {
+	sessions, _ := tm.tokenStore.(domain.TokenSessionStore)
+	return sessions
 }
 
 // ValidateToken validates a JWT token signed with RS256 and checks if it has been revoked.
@@ -59,8 +120,13 @@ func (tm *TokenManager) ValidateToken(ctx context.Context, tokenString string) (
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
+		tm.mu.RLock()
+		defer tm.mu.RUnlock()
+		if tm.previousKey != nil {
+			return jwt.VerificationKeySet{Keys: []jwt.VerificationKey{tm.publicKey, tm.previousKey}}, nil
+		}
 		return tm.publicKey, nil
-	})
+	}, jwt.WithTimeFunc(tm.clock.Now))
 
 	if err != nil {
 		return nil, err
@@ -98,7 +164,7 @@ func (tm *TokenManager) Revoke(ctx context.Context, tokenString string) error {
 		return fmt.Errorf("cannot revoke token with no expiration")
 	}
 
-	ttl := time.Until(claims.ExpiresAt.Time)
+	ttl := clock.Until(tm.clock, claims.ExpiresAt.Time)
 	if ttl <= 0 {
 		// Token is already expired, no need to add to revocation list.
 		return nil
@@ -2,9 +2,13 @@ package auth
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/spounge-ai/polykey/internal/domain"
 	"github.com/spounge-ai/polykey/pkg/cache"
+	"github.com/spounge-ai/polykey/pkg/clock"
 )
 
 // TokenStore defines the interface for storing revoked tokens.
@@ -17,24 +21,81 @@ type TokenStore interface {
 	IsRevoked(ctx context.Context, tokenID string) bool
 }
 
-// NewInMemoryTokenStore creates a new in-memory token store.
-func NewInMemoryTokenStore() TokenStore {
+// NewInMemoryTokenStore creates a new in-memory token store. It also tracks the tokens
+// issued by this replica, as a domain.TokenSessionStore, expiring them at the times clk
+// reports.
+func NewInMemoryTokenStore(clk clock.Clock) TokenStore {
 	return &inMemoryTokenStore{
+		clock: clk,
 		store: cache.New(
 			cache.WithCleanupInterval[string, struct{}](10 * time.Minute),
 		),
+		sessions: make(map[string]domain.TokenSession),
 	}
 }
 
 type inMemoryTokenStore struct {
 	store cache.Store[string, struct{}]
+	clock clock.Clock
+
+	mu       sync.Mutex
+	sessions map[string]domain.TokenSession
 }
 
+var _ domain.TokenSessionStore = (*inMemoryTokenStore)(nil)
+
 func (s *inMemoryTokenStore) Revoke(ctx context.Context, tokenID string, ttl time.Duration) {
 	s.store.Set(ctx, tokenID, struct{}{}, ttl)
+	s.mu.Lock()
+	delete(s.sessions, tokenID)
+	s.mu.Unlock()
 }
 
 func (s *inMemoryTokenStore) IsRevoked(ctx context.Context, tokenID string) bool {
 	_, found := s.store.Get(ctx, tokenID)
 	return found
 }
+
+func (s *inMemoryTokenStore) TrackToken(_ context.Context, session domain.TokenSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	for id, tracked := range s.sessions {
+		if !now.Before(tracked.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[session.TokenID] = session
+	return nil
+}
+
+func (s *inMemoryTokenStore) ListActiveTokens(_ context.Context, clientID string) ([]domain.TokenSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.activeLocked(clientID), nil
+}
+
+func (s *inMemoryTokenStore) RevokeAllForClient(ctx context.Context, clientID string) ([]domain.TokenSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revoked := s.activeLocked(clientID)
+	for _, session := range revoked {
+		s.store.Set(ctx, session.TokenID, struct{}{}, clock.Until(s.clock, session.ExpiresAt))
+		delete(s.sessions, session.TokenID)
+	}
+	return revoked, nil
+}
+
+// activeLocked returns the unexpired sessions of clientID, or of all clients when it is
+// "", oldest first. s.mu must be held.
+func (s *inMemoryTokenStore) activeLocked(clientID string) []domain.TokenSession {
+	now := s.clock.Now()
+	var active []domain.TokenSession
+	for _, session := range s.sessions {
+		if now.Before(session.ExpiresAt) && (clientID == "" || session.ClientID == clientID) {
+			active = append(active, session)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].IssuedAt.Before(active[j].IssuedAt) })
+	return active
+}
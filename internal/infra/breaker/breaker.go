@@ -0,0 +1,113 @@
+// Package breaker names circuit breakers and reports them: each Breaker logs its state
+// changes, exports its state and trips as metrics labeled by name, and can be inspected
+// and overridden by operators through domain.CircuitBreakerControl.
+package breaker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/patterns/circuitbreaker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/infra/breaker")
+
+var (
+	circuitBreakerState, _ = meter.Int64ObservableGauge(
+		"polykey.circuit_breaker.state",
+		metric.WithDescription("Circuit breaker state: 0 closed, 1 open, 2 half-open."),
+	)
+	circuitBreakerTrips, _ = meter.Int64Counter(
+		"polykey.circuit_breaker.trips",
+		metric.WithDescription("Number of times a circuit breaker opened, by whether it was tripped manually."),
+	)
+)
+
+// Breaker is a named circuit breaker guarding one class of calls to a dependency.
+type Breaker struct {
+	name   string
+	cb     *circuitbreaker.Breaker[any]
+	logger *slog.Logger
+}
+
+var _ domain.CircuitBreakerControl = (*Breaker)(nil)
+
+// New returns a breaker named name that opens after maxFailures consecutive failures
+// and stays open for resetTimeout. opts may change the call timeout or which errors
+// count as failures.
+func New(name string, logger *slog.Logger, maxFailures int, resetTimeout time.Duration, opts ...circuitbreaker.Option[any]) *Breaker {
+	b := &Breaker{name: name, logger: logger}
+	nameAttr := attribute.String("breaker", name)
+
+	opts = append([]circuitbreaker.Option[any]{
+		circuitbreaker.WithResetTimeout[any](resetTimeout),
+		circuitbreaker.WithStateChangeCallback[any](func(from, to circuitbreaker.State) {
+			if to == circuitbreaker.StateOpen {
+				// Trip marks the breaker forced before opening it.
+				manual := b.cb.Forced()
+				circuitBreakerTrips.Add(context.Background(), 1, metric.WithAttributes(nameAttr, attribute.Bool("manual", manual)))
+			}
+			logger.Warn("circuit breaker state changed", "breaker", name, "from", from.String(), "to", to.String())
+		}),
+	}, opts...)
+	b.cb = circuitbreaker.New(maxFailures, opts...)
+
+	// The registration lives as long as the process, like the dependency it guards.
+	_, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(circuitBreakerState, int64(b.cb.State()), metric.WithAttributes(nameAttr))
+		return nil
+	}, circuitBreakerState)
+
+	return b
+}
+
+// Execute calls fn through b. It fails with circuitbreaker.ErrOpen without calling fn
+// while b is open.
+func Execute[T any](ctx context.Context, b *Breaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	result, err := b.cb.Execute(ctx, func(ctx context.Context) (any, error) {
+		return fn(ctx)
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result.(T), nil
+}
+
+// Name returns the name b is reported under.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Status reports the breaker's state and counters.
+func (b *Breaker) Status() domain.CircuitBreakerStatus {
+	return domain.CircuitBreakerStatus{
+		Name:     b.name,
+		State:    b.cb.State().String(),
+		Failures: b.cb.Failures(),
+		Trips:    b.cb.Trips(),
+		Forced:   b.cb.Forced(),
+	}
+}
+
+// Trip opens the breaker until Reset, failing every call through it fast.
+func (b *Breaker) Trip() {
+	b.logger.Warn("circuit breaker tripped manually", "breaker", b.name)
+	b.cb.Trip()
+}
+
+// Reset closes the breaker.
+func (b *Breaker) Reset() {
+	b.logger.Warn("circuit breaker reset manually", "breaker", b.name)
+	b.cb.Reset()
+}
+
+// SetLimits changes the failures that open the breaker and how long it stays open.
+func (b *Breaker) SetLimits(maxFailures int, resetTimeout time.Duration) {
+	b.cb.SetLimits(maxFailures, resetTimeout)
+}
@@ -5,13 +5,114 @@ import "time"
 // AuditingConfig holds the configuration for auditing.
 type AuditingConfig struct {
 	Asynchronous AsynchronousAuditingConfig `mapstructure:"asynchronous"`
+	Kafka        KafkaAuditSinkConfig       `mapstructure:"kafka"`
+	Retention    AuditRetentionConfig       `mapstructure:"retention"`
+	Sampling     AuditSamplingConfig        `mapstructure:"sampling"`
+	SinkQueue    AuditSinkQueueConfig       `mapstructure:"sink_queue"`
+	Syslog       SyslogAuditSinkConfig      `mapstructure:"syslog"`
+	Verbosity    AuditVerbosityConfig       `mapstructure:"verbosity"`
+}
+
+// AuditVerbosityConfig sets how much is recorded about each audited operation: off,
+// minimal (no caller connection details or metadata changes) or full. Operations
+// without an entry are audited at Default.
+type AuditVerbosityConfig struct {
+	Default    string                    `mapstructure:"default" validate:"omitempty,oneof=off minimal full"`
+	Operations []AuditOperationVerbosity `mapstructure:"operations" validate:"dive"`
+}
+
+// AuditOperationVerbosity is the verbosity of one operation.
+type AuditOperationVerbosity struct {
+	Operation string `mapstructure:"operation" validate:"required"`
+	Level     string `mapstructure:"level" validate:"oneof=off minimal full"`
+}
+
+// AuditSamplingConfig holds the configuration for recording only a sample of the
+// successful audits of read operations. Failures and mutations are always recorded.
+type AuditSamplingConfig struct {
+	Enabled bool                `mapstructure:"enabled"`
+	Rules   []AuditSamplingRule `mapstructure:"rules" validate:"dive"`
+}
+
+// AuditSamplingRule records one in every OneIn successful audits of Operation, such
+// as GetKey or the keys:read authorization decision.
+type AuditSamplingRule struct {
+	Operation string `mapstructure:"operation" validate:"required"`
+	OneIn     int    `mapstructure:"one_in" validate:"gte=1"`
 }
 
 // AsynchronousAuditingConfig holds the configuration for the asynchronous logger.
 type AsynchronousAuditingConfig struct {
-	Enabled           bool          `mapstructure:"enabled"`
-	ChannelBufferSize int           `mapstructure:"channel_buffer_size"`
-	WorkerCount       int           `mapstructure:"worker_count"`
-	BatchSize         int           `mapstructure:"batch_size"`
-	BatchTimeout      time.Duration `mapstructure:"batch_timeout"`
+	Enabled           bool             `mapstructure:"enabled"`
+	ChannelBufferSize int              `mapstructure:"channel_buffer_size"`
+	WorkerCount       int              `mapstructure:"worker_count"`
+	BatchSize         int              `mapstructure:"batch_size"`
+	BatchTimeout      time.Duration    `mapstructure:"batch_timeout"`
+	Spill             AuditSpillConfig `mapstructure:"spill"`
+}
+
+// AuditSpillConfig holds the configuration for the disk buffer that takes audit events
+// the asynchronous logger cannot queue or write, until the database accepts them again.
+type AuditSpillConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Dir            string        `mapstructure:"dir" validate:"required_if=Enabled true"`
+	MaxBytes       int64         `mapstructure:"max_bytes" validate:"gte=0"`
+	ReplayInterval time.Duration `mapstructure:"replay_interval" validate:"gte=0"`
+}
+
+// AuditSinkQueueConfig holds the configuration of the queue each audit sink is fed
+// through. Size is the number of batches queued per sink; the batches that do not fit,
+// or are still queued DrainTimeout into shutdown, are appended to DeadLetterPath, which
+// must be absolute.
+type AuditSinkQueueConfig struct {
+	Size           int           `mapstructure:"size" validate:"gte=1"`
+	DeadLetterPath string        `mapstructure:"dead_letter_path" validate:"required"`
+	DrainTimeout   time.Duration `mapstructure:"drain_timeout" validate:"gte=0"`
+}
+
+// KafkaAuditSinkConfig holds the configuration for exporting audit events to Kafka.
+type KafkaAuditSinkConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Brokers        []string      `mapstructure:"brokers"`
+	Topic          string        `mapstructure:"topic"`
+	MaxRetries     int           `mapstructure:"max_retries"`
+	RetryBackoff   time.Duration `mapstructure:"retry_backoff"`
+	WriteTimeout   time.Duration `mapstructure:"write_timeout"`
+	DeadLetterPath string        `mapstructure:"dead_letter_path"`
+}
+
+// SyslogAuditSinkConfig holds the configuration for exporting audit events to a syslog
+// collector. Network is udp, tcp or tls, Format rfc5424 or cef, and Facility the numeric
+// syslog facility. CAFile, when set, replaces the system roots for verifying a tls
+// collector.
+type SyslogAuditSinkConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Network      string        `mapstructure:"network" validate:"omitempty,oneof=udp tcp tls"`
+	Address      string        `mapstructure:"address" validate:"required_if=Enabled true"`
+	Format       string        `mapstructure:"format" validate:"omitempty,oneof=rfc5424 cef"`
+	Facility     int           `mapstructure:"facility" validate:"gte=0,lte=23"`
+	AppName      string        `mapstructure:"app_name"`
+	Hostname     string        `mapstructure:"hostname"`
+	CAFile       string        `mapstructure:"ca_file"`
+	MaxRetries   int           `mapstructure:"max_retries" validate:"gte=0"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout" validate:"gte=0"`
+}
+
+// AuditRetentionConfig holds the configuration for moving old audit events out of the
+// database into S3. Events older than HotRetention are written to gzip-compressed
+// objects with server-side encryption, under KMSKeyID when set, and StorageClass.
+// RestoreFor is how long events restored for an investigation stay in the database, and
+// RestoreDays how long S3 keeps the readable copy of an object it retrieves from Glacier.
+type AuditRetentionConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	HotRetention time.Duration `mapstructure:"hot_retention" validate:"gte=0"`
+	Interval     time.Duration `mapstructure:"interval" validate:"gte=0"`
+	BatchSize    int           `mapstructure:"batch_size" validate:"gte=0"`
+	Bucket       string        `mapstructure:"bucket" validate:"required_if=Enabled true"`
+	Prefix       string        `mapstructure:"prefix"`
+	StorageClass string        `mapstructure:"storage_class"`
+	KMSKeyID     string        `mapstructure:"kms_key_id"`
+	RestoreFor   time.Duration `mapstructure:"restore_for" validate:"gte=0"`
+	RestoreDays  int32         `mapstructure:"restore_days" validate:"gte=0"`
+	RestoreTier  string        `mapstructure:"restore_tier"`
 }
@@ -1,9 +1,28 @@
 package config
 
+import "time"
+
 // AuthorizationConfig represents the authorization configuration.
 type AuthorizationConfig struct {
 	Roles     map[string]RoleConfig `mapstructure:"roles"`
 	ZeroTrust ZeroTrustConfig       `mapstructure:"zero_trust"`
+	StepUp    StepUpConfig          `mapstructure:"step_up"`
+	// Authenticate configures the anti-replay challenge and failure throttling of
+	// the Authenticate RPC.
+	Authenticate AuthenticateConfig `mapstructure:"authenticate"`
+	// Tokens sets the lifetime of the access tokens Authenticate issues.
+	Tokens TokenConfig `mapstructure:"tokens"`
+}
+
+// TokenConfig sets how long access tokens live. A client's token_ttl in the client
+// store takes precedence over the TTL of its tier in TierTTLs, which takes precedence
+// over TTL. MaxTTL, when set, caps all of them. Clients renew their tokens before they
+// expire, so longer lifetimes mostly spare long-running workloads from re-authenticating
+// through a brief outage, at the cost of a longer window for a stolen token.
+type TokenConfig struct {
+	TTL      time.Duration            `mapstructure:"ttl" validate:"gt=0"`
+	TierTTLs map[string]time.Duration `mapstructure:"tier_ttls"`
+	MaxTTL   time.Duration            `mapstructure:"max_ttl" validate:"gte=0"`
 }
 
 // RoleConfig represents the role configuration.
@@ -15,3 +34,22 @@ type RoleConfig struct {
 type ZeroTrustConfig struct {
 	EnforceMTLSIdentityMatch bool `mapstructure:"enforce_mtls_identity_match"`
 }
+
+// StepUpConfig lists the token assertions that satisfy a key's step-up requirement.
+type StepUpConfig struct {
+	AcceptedAMR []string `mapstructure:"accepted_amr"`
+	AcceptedACR []string `mapstructure:"accepted_acr"`
+}
+
+// AuthenticateConfig configures how Authenticate protects client credentials. A request
+// may carry a signed nonce and timestamp, see domain.AuthenticateChallenge: one older or
+// newer than MaxClockSkew, or whose nonce was already used, is rejected as a replay.
+// RequireChallenge rejects requests without one. After MaxFailures failed attempts in
+// FailureWindow, a client ID or source IP is refused until the window ends; zero
+// MaxFailures disables throttling.
+type AuthenticateConfig struct {
+	RequireChallenge bool          `mapstructure:"require_challenge"`
+	MaxClockSkew     time.Duration `mapstructure:"max_clock_skew" validate:"gte=0"`
+	MaxFailures      int           `mapstructure:"max_failures" validate:"gte=0"`
+	FailureWindow    time.Duration `mapstructure:"failure_window" validate:"gte=0"`
+}
@@ -1,10 +1,14 @@
 package config
 
-// AWSConfig represents the AWS configuration.
+import "time"
+
+// AWSConfig represents the AWS configuration. CacheTTL is how long bootstrap secrets read
+// from Parameter Store or Secrets Manager are cached, which bounds how often config
+// reloads call them; zero disables the cache.
 type AWSConfig struct {
-	Enabled   bool   `mapstructure:"enabled"`
-	Region    string `mapstructure:"region"     validate:"required_if=Enabled true"`
-	S3Bucket string `mapstructure:"s3_bucket"`
-	KMSKeyARN string `mapstructure:"kms_key_arn"`
-	CacheTTL  string `mapstructure:"cache_ttl"`
+	Enabled   bool          `mapstructure:"enabled"`
+	Region    string        `mapstructure:"region"     validate:"required_if=Enabled true"`
+	S3Bucket  string        `mapstructure:"s3_bucket"`
+	KMSKeyARN string        `mapstructure:"kms_key_arn"`
+	CacheTTL  time.Duration `mapstructure:"cache_ttl" validate:"gte=0"`
 }
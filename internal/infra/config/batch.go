@@ -0,0 +1,78 @@
+package config
+
+import "strings"
+
+// Batch operations whose item concurrency can be configured.
+const (
+	BatchOperationCreate = "create"
+	BatchOperationGet    = "get"
+	BatchOperationRotate = "rotate"
+)
+
+// Defaults of a BatchConfig, also used for fields left zero.
+const (
+	DefaultBatchConcurrency   = 10
+	DefaultRotationWorkers    = 5
+	DefaultRotationQueueDepth = 100
+)
+
+// BatchConfig sizes the workers that process batch RPCs and key rotations.
+type BatchConfig struct {
+	// DefaultConcurrency is how many items of one batch RPC are processed at once, for
+	// operations without their own entry in Concurrency.
+	DefaultConcurrency int `mapstructure:"default_concurrency" validate:"gte=1"`
+	// Concurrency overrides DefaultConcurrency per operation: create, get or rotate.
+	Concurrency map[string]int `mapstructure:"concurrency"`
+	// RotationWorkers and RotationQueueDepth size the pipeline every rotation goes
+	// through: how many rotations run at once, and how many may wait for a worker.
+	RotationWorkers    int `mapstructure:"rotation_workers" validate:"gte=1"`
+	RotationQueueDepth int `mapstructure:"rotation_queue_depth" validate:"gte=1"`
+	// MaxItems caps the items of one call of a batch RPC, per client tier; a larger
+	// call is rejected before any item is processed. MethodMaxItems overrides it per
+	// batch RPC, keyed by bare method name, case-insensitively.
+	MaxItems       BatchItemLimits            `mapstructure:"max_items"`
+	MethodMaxItems map[string]BatchItemLimits `mapstructure:"method_max_items"`
+}
+
+// BatchItemLimits are the items one batch call may carry per client tier. Tiers without
+// an entry get Default; zero means no limit.
+type BatchItemLimits struct {
+	Default int            `mapstructure:"default" validate:"gte=0"`
+	Tiers   map[string]int `mapstructure:"tiers"`
+}
+
+// MaxConcurrency returns how many items of one batch of operation are processed at once.
+func (c BatchConfig) MaxConcurrency(operation string) int {
+	if n, ok := c.Concurrency[strings.ToLower(operation)]; ok && n > 0 {
+		return n
+	}
+	if c.DefaultConcurrency > 0 {
+		return c.DefaultConcurrency
+	}
+	return DefaultBatchConcurrency
+}
+
+// RotationPipelineSize returns the rotation workers and queue depth.
+func (c BatchConfig) RotationPipelineSize() (workers, queueDepth int) {
+	workers, queueDepth = c.RotationWorkers, c.RotationQueueDepth
+	if workers <= 0 {
+		workers = DefaultRotationWorkers
+	}
+	if queueDepth <= 0 {
+		queueDepth = DefaultRotationQueueDepth
+	}
+	return workers, queueDepth
+}
+
+// MaxBatchItems returns how many items one call of method may carry for tier, or zero
+// when it is unlimited.
+func (c BatchConfig) MaxBatchItems(method, tier string) int {
+	limits, ok := c.MethodMaxItems[strings.ToLower(method)]
+	if !ok {
+		limits = c.MaxItems
+	}
+	if n, ok := limits.Tiers[strings.ToLower(tier)]; ok {
+		return n
+	}
+	return limits.Default
+}
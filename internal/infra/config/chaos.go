@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// ChaosConfig injects faults into the key repository and the KMS providers, so that
+// circuit breakers, retries and degraded modes can be exercised under controlled
+// failure. It is refused in every mode but development.
+type ChaosConfig struct {
+	Enabled    bool        `mapstructure:"enabled"`
+	Repository FaultConfig `mapstructure:"repository"`
+	KMS        FaultConfig `mapstructure:"kms"`
+}
+
+// FaultConfig describes the faults injected into one dependency.
+type FaultConfig struct {
+	// Latency delays every call; up to Jitter more is added at random.
+	Latency time.Duration `mapstructure:"latency" validate:"gte=0"`
+	Jitter  time.Duration `mapstructure:"jitter" validate:"gte=0"`
+	// ErrorRate is the fraction of calls that fail, from 0 to 1.
+	ErrorRate float64 `mapstructure:"error_rate" validate:"gte=0,lte=1"`
+	// ItemFailureRate is the fraction of the items of batch operations that fail.
+	ItemFailureRate float64 `mapstructure:"item_failure_rate" validate:"gte=0,lte=1"`
+	// Seed makes the faults repeatable from one run to the next; 0 picks a random seed.
+	Seed uint64 `mapstructure:"seed"`
+}
@@ -5,52 +5,105 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	aws_config "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/go-playground/validator/v10"
 	infra_secrets "github.com/spounge-ai/polykey/internal/infra/secrets"
 	"github.com/spounge-ai/polykey/internal/secrets"
+	"github.com/spounge-ai/polykey/pkg/execution"
 	"github.com/spf13/viper"
 	customvalidator "github.com/spounge-ai/polykey/pkg/validator"
 	"gopkg.in/yaml.v3"
 )
 
-// BootstrapSecrets are loaded only from SSM Parameter Store
+// BootstrapSecrets are loaded only from the bootstrap secret provider, SSM Parameter
+// Store, Secrets Manager, Vault or local environment variables and files, under their
+// secret paths relative to the base path
 type BootstrapSecrets struct {
 	PolykeyMasterKey string `secretpath:"polykey/kms/polykey_master_key"`
-	NeonDBURL        string `secretpath:"polykey/db/neondb_url"`
-	JWTRSAPrivateKey string `secretpath:"polykey/jwt/jwt_rsa_private_key"`
-	TLSServerCert    string `secretpath:"polykey/tls/server-cert.pem"`
-	TLSServerKey     string `secretpath:"polykey/tls/server-key.pem"`
+	NeonDBURL        string `secretpath:"polykey/db/neondb_url" rotate:"true"`
+	JWTRSAPrivateKey string `secretpath:"polykey/jwt/jwt_rsa_private_key" rotate:"true"`
+	TLSServerCert    string `secretpath:"polykey/tls/server-cert.pem" rotate:"true"`
+	TLSServerKey     string `secretpath:"polykey/tls/server-key.pem" rotate:"true"`
 	AWSKMSKeyARN     string `secretpath:"polykey/kms/aws_kms_key_arn"`
-	SpoungeCA        string `secretpath:"tls/ca.pem"`
-
-	// Dynamic config values
-	CircuitBreakerConfig string `secretpath:"polykey/persistence/circuit_breaker"`
-	RateLimiterConfig    string `secretpath:"polykey/server/rate_limiter"`
-	AsyncAuditingConfig  string `secretpath:"polykey/auditing/asynchronous"`
+	SpoungeCA        string `secretpath:"tls/ca.pem" rotate:"true"`
+	// MetadataIntegrityKey keys the checksums of key metadata: base64, at least 32 bytes,
+	// and the same on every server sharing a database or replicating to one.
+	MetadataIntegrityKey string `secretpath:"polykey/persistence/metadata_integrity_key"`
+
+	// Dynamic config values, fetched again on every reload
+	CircuitBreakerConfig string `secretpath:"polykey/persistence/circuit_breaker" reload:"true"`
+	RateLimiterConfig    string `secretpath:"polykey/server/rate_limiter" reload:"true"`
+	AsyncAuditingConfig  string `secretpath:"polykey/auditing/asynchronous" reload:"true"`
 }
 
 // Config holds the runtime configuration
 type Config struct {
-	Server                   ServerConfig        `mapstructure:"server" validate:"required"`
-	Persistence              PersistenceConfig   `mapstructure:"persistence" validate:"required"`
-	AWS                      *AWSConfig          `mapstructure:"aws"`
-	Authorization            AuthorizationConfig `mapstructure:"authorization" validate:"required"`
-	ClientCredentialsPath    string              `mapstructure:"client_credentials_path"`
-	DefaultKMSProvider       string              `mapstructure:"default_kms_provider" validate:"required,oneof=local aws vault"`
-	BootstrapSecretsBasePath string              `mapstructure:"bootstrap_secrets_base_path" validate:"required"`
-	Auditing                 AuditingConfig      `mapstructure:"auditing"`
+	Server                   ServerConfig         `mapstructure:"server" validate:"required"`
+	Persistence              PersistenceConfig    `mapstructure:"persistence" validate:"required"`
+	AWS                      *AWSConfig           `mapstructure:"aws"`
+	Authorization            AuthorizationConfig  `mapstructure:"authorization" validate:"required"`
+	ClientCredentialsPath    string               `mapstructure:"client_credentials_path"`
+	DefaultKMSProvider       string               `mapstructure:"default_kms_provider" validate:"required,oneof=local aws vault"`
+	BootstrapSecretsBasePath string               `mapstructure:"bootstrap_secrets_base_path" validate:"required"`
+	BootstrapSecretsProvider string               `mapstructure:"bootstrap_secrets_provider" validate:"omitempty,oneof=ssm secrets_manager vault local"`
+	Vault                    *VaultConfig         `mapstructure:"vault" validate:"required_if=BootstrapSecretsProvider vault"`
+	LocalSecrets             LocalSecretsConfig   `mapstructure:"local_secrets"`
+	Auditing                 AuditingConfig       `mapstructure:"auditing"`
+	KeyLifecycle             KeyLifecycleConfig   `mapstructure:"key_lifecycle"`
+	Namespaces               NamespacesConfig     `mapstructure:"namespaces"`
+	Webhooks                 WebhooksConfig       `mapstructure:"webhooks"`
+	Telemetry                TelemetryConfig      `mapstructure:"telemetry"`
+	Logging                  LoggingConfig        `mapstructure:"logging"`
+	Reload                   ReloadConfig         `mapstructure:"reload"`
+	SecretRotation           SecretRotationConfig `mapstructure:"secret_rotation"`
+	LeaderElection           LeaderElectionConfig `mapstructure:"leader_election"`
+	KMS                      KMSConfig            `mapstructure:"kms"`
+	Redis                    RedisConfig          `mapstructure:"redis"`
+	Startup                  StartupConfig        `mapstructure:"startup"`
+	Replication              ReplicationConfig    `mapstructure:"replication"`
+	Events                   EventsConfig         `mapstructure:"events"`
+	Batch                    BatchConfig          `mapstructure:"batch"`
+	DEKPools                 DEKPoolsConfig       `mapstructure:"dek_pools"`
+	Chaos                    ChaosConfig          `mapstructure:"chaos"`
 	ServiceVersion   string
 	BuildCommit      string
 	BootstrapSecrets BootstrapSecrets
+
+	// secretProvider is kept for reloads, which fetch the dynamic config values again.
+	secretProvider secrets.BootstrapSecretProvider
+	// sources records where each value came from, for Settings.
+	sources map[string]string
 }
 
 func Load(path string) (*Config, error) {
+	return load(path, nil, false)
+}
+
+// LoadWaiting is Load for the service's startup: while the bootstrap secrets cannot be
+// fetched, it tries again as the startup settings allow instead of failing at once.
+func LoadWaiting(path string) (*Config, error) {
+	return load(path, nil, true)
+}
+
+// Reload reads the config at path again for a service running with current. Only the
+// dynamic config values are fetched from the bootstrap secret provider again; the other
+// bootstrap secrets are kept from current, as they are only used at startup. Changes to
+// the provider's own settings take effect on restart.
+func Reload(path string, current *Config) (*Config, error) {
+	return load(path, current, false)
+}
+
+func load(path string, current *Config, wait bool) (*Config, error) {
 	vip := viper.New()
 	setupViper(vip, path)
 
@@ -66,16 +119,35 @@ func Load(path string) (*Config, error) {
 	if err := vip.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	cfg.Server.TLS.applyCertDir()
+
+	var secretProvider secrets.BootstrapSecretProvider
+	var overridden []string
+	if current != nil {
+		reloaded := current.BootstrapSecrets
+		bootstrapSecrets = &reloaded
+		secretProvider = current.secretProvider
+	}
+	if secretProvider == nil {
+		var err error
+		if secretProvider, err = newBootstrapSecretProvider(&cfg); err != nil {
+			return nil, fmt.Errorf("failed to create bootstrap secret provider: %w", err)
+		}
+	}
 
-	if cfg.AWS != nil && cfg.AWS.Enabled {
+	if secretProvider != nil {
 		var err error
-		bootstrapSecrets, err = loadAWSBootstrapSecrets(&cfg)
+		if wait {
+			bootstrapSecrets, err = fetchBootstrapSecretsAtStartup(secretProvider, cfg.BootstrapSecretsBasePath, cfg.Startup, cfg.Server.TLS.fileSecrets())
+		} else {
+			bootstrapSecrets, err = fetchBootstrapSecrets(secretProvider, cfg.BootstrapSecretsBasePath, bootstrapSecrets, cfg.Server.TLS.fileSecrets())
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to load bootstrap secrets: %w", err)
 		}
 
 		// Apply dynamic config overrides from bootstrap secrets
-		if err := applyBootstrapConfigOverrides(vip, bootstrapSecrets); err != nil {
+		if overridden, err = applyBootstrapConfigOverrides(vip, bootstrapSecrets); err != nil {
 			return nil, fmt.Errorf("failed to apply bootstrap config overrides: %w", err)
 		}
 	}
@@ -84,11 +156,14 @@ func Load(path string) (*Config, error) {
 	if err := vip.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config after bootstrap overrides: %w", err)
 	}
+	cfg.Server.TLS.applyCertDir()
 
 	// Set bootstrap secrets
 	if bootstrapSecrets != nil {
 		cfg.BootstrapSecrets = *bootstrapSecrets
 	}
+	cfg.secretProvider = secretProvider
+	cfg.sources = recordSources(vip, overridden)
 
 	// Validate
 	if err := validateConfig(&cfg); err != nil {
@@ -123,75 +198,343 @@ func setDefaults(vip *viper.Viper) {
 	vip.SetDefault("server.mode", "development")
 	vip.SetDefault("server.tls.enabled", true)
 	vip.SetDefault("server.tls.client_auth", "RequireAndVerifyClientCert")
+	vip.SetDefault("server.tls.reload_interval", "1m")
+	vip.SetDefault("server.tls.min_version", DefaultTLSMinVersion)
+	vip.SetDefault("server.health_check_interval", "15s")
+	vip.SetDefault("server.shutdown_timeout", "10s")
+	vip.SetDefault("server.transport.max_recv_msg_size", 16<<20)
+	vip.SetDefault("server.transport.max_send_msg_size", 16<<20)
+	vip.SetDefault("server.transport.max_connection_age", "30m")
+	vip.SetDefault("server.transport.max_connection_age_grace", "1m")
+	vip.SetDefault("server.transport.keepalive_time", "2h")
+	vip.SetDefault("server.transport.keepalive_timeout", "20s")
+	vip.SetDefault("server.transport.keepalive_min_time", "30s")
+	vip.SetDefault("server.load_shedding.enabled", true)
+	vip.SetDefault("server.load_shedding.sample_interval", "1s")
+	vip.SetDefault("server.load_shedding.max_rotation_queue_utilization", 0.8)
+	vip.SetDefault("server.load_shedding.max_pool_acquire_wait", "250ms")
+	vip.SetDefault("server.load_shedding.max_goroutines", 10000)
+	vip.SetDefault("server.debug.reflection", true)
+	vip.SetDefault("server.debug.channelz", false)
+	vip.SetDefault("server.admin.enabled", false)
+	vip.SetDefault("server.admin.port", 50054)
+	vip.SetDefault("server.admin.client_auth", DefaultAdminClientAuth)
+	vip.SetDefault("server.probes.enabled", false)
+	vip.SetDefault("server.probes.port", 8086)
+	vip.SetDefault("server.deadlines.default", "5s")
+	vip.SetDefault("server.deadlines.methods.getkey", "1s")
+	vip.SetDefault("server.deadlines.methods.getkeymetadata", "1s")
+	vip.SetDefault("server.deadlines.methods.batchcreatekeys", "10s")
+	vip.SetDefault("server.deadlines.methods.batchgetkeys", "10s")
+	vip.SetDefault("server.deadlines.methods.batchgetkeymetadata", "10s")
+	vip.SetDefault("server.deadlines.methods.batchrotatekeys", "10s")
+	vip.SetDefault("server.deadlines.methods.batchrevokekeys", "10s")
+	vip.SetDefault("server.deadlines.methods.batchupdatekeymetadata", "10s")
+	vip.SetDefault("server.deadlines.methods.streamlistkeys", "1m")
+	vip.SetDefault("server.deadlines.methods.rotatekeysbyfilter", "5m")
+	vip.SetDefault("server.deadlines.methods.streambatchgetkeys", "5m")
+	vip.SetDefault("server.deadlines.methods.streambatchcreatekeys", "5m")
+	vip.SetDefault("server.bulkheads.enabled", true)
+	vip.SetDefault("server.bulkheads.max_wait", "100ms")
+	for _, method := range []string{"batchcreatekeys", "batchgetkeys", "batchgetkeymetadata", "batchrevokekeys", "batchupdatekeymetadata"} {
+		vip.SetDefault("server.bulkheads.methods."+method+".default", 2)
+		vip.SetDefault("server.bulkheads.methods."+method+".tiers.free", 1)
+		vip.SetDefault("server.bulkheads.methods."+method+".tiers.pro", 4)
+		vip.SetDefault("server.bulkheads.methods."+method+".tiers.enterprise", 8)
+	}
+	// Rotations hold row locks and call the KMS for every key.
+	vip.SetDefault("server.bulkheads.methods.batchrotatekeys.default", 1)
+	vip.SetDefault("server.bulkheads.methods.batchrotatekeys.tiers.free", 1)
+	vip.SetDefault("server.bulkheads.methods.batchrotatekeys.tiers.pro", 2)
+	vip.SetDefault("server.bulkheads.methods.batchrotatekeys.tiers.enterprise", 4)
+	vip.SetDefault("server.load_shedding.priority_methods", []string{"GetKey", "GetKeyMetadata", "BatchGetKeys", "BatchGetKeyMetadata", "HealthCheck", "Authenticate"})
+
+	vip.SetDefault("batch.default_concurrency", DefaultBatchConcurrency)
+	vip.SetDefault("batch.rotation_workers", DefaultRotationWorkers)
+	vip.SetDefault("batch.rotation_queue_depth", DefaultRotationQueueDepth)
+	vip.SetDefault("batch.max_items.default", 1000)
+	vip.SetDefault("batch.max_items.tiers.free", 100)
+	vip.SetDefault("batch.max_items.tiers.pro", 500)
+	vip.SetDefault("batch.max_items.tiers.enterprise", 1000)
 
 	vip.SetDefault("persistence.type", "neondb")
 
 	vip.SetDefault("persistence.circuit_breaker.enabled", true)
 	vip.SetDefault("persistence.circuit_breaker.max_failures", 5)
 	vip.SetDefault("persistence.circuit_breaker.reset_timeout", "30s")
+	vip.SetDefault("persistence.hedging.enabled", false)
+	vip.SetDefault("persistence.hedging.delay", "50ms")
+	vip.SetDefault("persistence.hedging.max_in_flight", 10)
+	vip.SetDefault("persistence.database.slow_query.enabled", true)
+	vip.SetDefault("persistence.database.slow_query.threshold", "500ms")
 
 	vip.SetDefault("server.rate_limiter.enabled", true)
 	vip.SetDefault("server.rate_limiter.rate", 10)
 	vip.SetDefault("server.rate_limiter.burst", 20)
+	vip.SetDefault("server.rate_limiter.backend", "memory")
+	vip.SetDefault("redis.timeout", "50ms")
+	vip.SetDefault("redis.key_prefix", "polykey:")
 
 	vip.SetDefault("auditing.asynchronous.enabled", true)
 	vip.SetDefault("auditing.asynchronous.channel_buffer_size", 10000)
 	vip.SetDefault("auditing.asynchronous.worker_count", 3)
 	vip.SetDefault("auditing.asynchronous.batch_size", 500)
 	vip.SetDefault("auditing.asynchronous.batch_timeout", "1s")
+	vip.SetDefault("auditing.asynchronous.spill.enabled", false)
+	vip.SetDefault("auditing.asynchronous.spill.dir", "polykey-audit-spill")
+	vip.SetDefault("auditing.asynchronous.spill.max_bytes", 268435456)
+	vip.SetDefault("auditing.asynchronous.spill.replay_interval", "10s")
+	vip.SetDefault("auditing.kafka.enabled", false)
+	vip.SetDefault("auditing.kafka.topic", "polykey.audit")
+	vip.SetDefault("auditing.kafka.max_retries", 3)
+	vip.SetDefault("auditing.kafka.retry_backoff", "500ms")
+	vip.SetDefault("auditing.kafka.write_timeout", "10s")
+	vip.SetDefault("auditing.kafka.dead_letter_path", "/var/lib/polykey/audit-deadletter.jsonl")
+	vip.SetDefault("auditing.sampling.enabled", false)
+	vip.SetDefault("auditing.sink_queue.size", 100)
+	vip.SetDefault("auditing.sink_queue.dead_letter_path", "/var/lib/polykey/audit-deadletter.jsonl")
+	vip.SetDefault("auditing.sink_queue.drain_timeout", "5s")
+	vip.SetDefault("auditing.syslog.enabled", false)
+	vip.SetDefault("auditing.syslog.network", "tls")
+	vip.SetDefault("auditing.syslog.format", "rfc5424")
+	vip.SetDefault("auditing.syslog.facility", 13)
+	vip.SetDefault("auditing.syslog.app_name", "polykey")
+	vip.SetDefault("auditing.syslog.max_retries", 2)
+	vip.SetDefault("auditing.syslog.write_timeout", "5s")
+	vip.SetDefault("auditing.verbosity.default", "full")
+	vip.SetDefault("kms.retry.max_attempts", 4)
+	vip.SetDefault("kms.retry.initial_backoff", "100ms")
+	vip.SetDefault("kms.retry.max_backoff", "2s")
+	vip.SetDefault("kms.circuit_breaker.enabled", true)
+	vip.SetDefault("kms.circuit_breaker.max_failures", 5)
+	vip.SetDefault("kms.circuit_breaker.reset_timeout", "30s")
+	vip.SetDefault("chaos.enabled", false)
+
+	vip.SetDefault("leader_election.enabled", false)
+	vip.SetDefault("leader_election.lock_name", "polykey-jobs")
+	vip.SetDefault("leader_election.interval", "10s")
+	vip.SetDefault("replication.enabled", false)
+	vip.SetDefault("replication.interval", "5s")
+	vip.SetDefault("replication.batch_size", 500)
+	vip.SetDefault("events.nats.enabled", false)
+	vip.SetDefault("events.nats.url", "nats://localhost:4222")
+	vip.SetDefault("events.nats.stream", "POLYKEY_KEYS")
+	vip.SetDefault("events.nats.create_stream", true)
+	vip.SetDefault("events.nats.subject_prefix", "polykey.keys")
+	vip.SetDefault("events.nats.publish_timeout", "5s")
+	vip.SetDefault("events.nats.interval", "1s")
+	vip.SetDefault("events.nats.batch_size", 100)
+	vip.SetDefault("startup.timeout", "2m")
+	vip.SetDefault("startup.initial_backoff", "1s")
+	vip.SetDefault("startup.max_backoff", "15s")
+
+	vip.SetDefault("auditing.retention.enabled", false)
+	vip.SetDefault("auditing.retention.hot_retention", "2160h")
+	vip.SetDefault("auditing.retention.interval", "1h")
+	vip.SetDefault("auditing.retention.batch_size", 10000)
+	vip.SetDefault("auditing.retention.prefix", "audit")
+	vip.SetDefault("auditing.retention.storage_class", "GLACIER")
+	vip.SetDefault("auditing.retention.restore_for", "168h")
+	vip.SetDefault("auditing.retention.restore_days", 7)
+	vip.SetDefault("auditing.retention.restore_tier", "Standard")
+	vip.SetDefault("webhooks.enabled", false)
+	vip.SetDefault("webhooks.timeout", "5s")
+	vip.SetDefault("webhooks.max_retries", 5)
+	vip.SetDefault("webhooks.initial_backoff", "1s")
+	vip.SetDefault("webhooks.max_backoff", "1m")
+	vip.SetDefault("webhooks.queue_size", 1000)
+	vip.SetDefault("webhooks.workers", 2)
+	vip.SetDefault("webhooks.shutdown_timeout", "10s")
+	vip.SetDefault("telemetry.service_name", "polykey")
+	vip.SetDefault("telemetry.tracing.enabled", false)
+	vip.SetDefault("telemetry.tracing.protocol", "grpc")
+	vip.SetDefault("telemetry.tracing.sample_ratio", 0.1)
+	vip.SetDefault("telemetry.tracing.timeout", "10s")
+	vip.SetDefault("logging.level", "info")
+	vip.SetDefault("reload.enabled", true)
+	vip.SetDefault("reload.interval", "30s")
+	vip.SetDefault("secret_rotation.enabled", false)
+	vip.SetDefault("secret_rotation.interval", "1h")
+
+	vip.SetDefault("key_lifecycle.expiration.enabled", true)
+	vip.SetDefault("key_lifecycle.expiration.interval", "1m")
+	vip.SetDefault("key_lifecycle.expiration.batch_size", 100)
+	vip.SetDefault("key_lifecycle.expiration.notify_before", "72h")
+	vip.SetDefault("key_lifecycle.rotation.default_grace_period", "24h")
+	vip.SetDefault("key_lifecycle.rotation.scheduled.enabled", true)
+	vip.SetDefault("key_lifecycle.rotation.scheduled.interval", "10m")
+	vip.SetDefault("key_lifecycle.rotation.scheduled.batch_size", 100)
+	vip.SetDefault("key_lifecycle.access_stats.enabled", true)
+	vip.SetDefault("key_lifecycle.access_stats.flush_interval", "10s")
+	vip.SetDefault("key_lifecycle.access_stats.max_pending_keys", 10000)
+	vip.SetDefault("key_lifecycle.restore.windows", map[string]string{"free": "1h", "pro": "24h", "enterprise": "72h"})
+	vip.SetDefault("key_lifecycle.version_retention.enabled", false)
+	vip.SetDefault("key_lifecycle.version_retention.interval", "1h")
+	vip.SetDefault("key_lifecycle.version_retention.batch_size", 100)
+	vip.SetDefault("key_lifecycle.version_retention.keep_versions", 5)
+	vip.SetDefault("key_lifecycle.version_retention.min_age", "2160h")
+	vip.SetDefault("key_lifecycle.version_retention.archive", true)
+	vip.SetDefault("namespaces.default_max_keys", 0)
 
 	vip.SetDefault("aws.enabled", true)
 	vip.SetDefault("aws.region", "us-east-1")
+	vip.SetDefault("aws.cache_ttl", "5m")
 
 	vip.SetDefault("default_kms_provider", "local")
 	vip.SetDefault("bootstrap_secrets_base_path", "/spounge/dev/")
+	vip.SetDefault("bootstrap_secrets_provider", "ssm")
 	vip.SetDefault("authorization.zero_trust.enforce_mtls_identity_match", true)
+	vip.SetDefault("authorization.step_up.accepted_amr", []string{"mfa", "otp", "hwk"})
+	vip.SetDefault("authorization.authenticate.max_clock_skew", 5*time.Minute)
+	vip.SetDefault("authorization.authenticate.max_failures", 10)
+	vip.SetDefault("authorization.authenticate.failure_window", 15*time.Minute)
+	vip.SetDefault("authorization.tokens.ttl", time.Hour)
+	vip.SetDefault("authorization.tokens.max_ttl", 24*time.Hour)
 }
 
-func loadAWSBootstrapSecrets(cfg *Config) (*BootstrapSecrets, error) {
+// newBootstrapSecretProvider creates the configured bootstrap secret provider. It is nil
+// when Parameter Store is configured but AWS is disabled, in which case no bootstrap
+// secrets are loaded.
+func newBootstrapSecretProvider(cfg *Config) (secrets.BootstrapSecretProvider, error) {
+	switch cfg.BootstrapSecretsProvider {
+	case "vault":
+		if cfg.Vault == nil {
+			return nil, fmt.Errorf("vault bootstrap secrets require the vault config")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return infra_secrets.NewVault(ctx, infra_secrets.VaultConfig{
+			Address:      cfg.Vault.Address,
+			Namespace:    cfg.Vault.Namespace,
+			CACert:       cfg.Vault.CACert,
+			AuthMethod:   cfg.Vault.Auth.Method,
+			Token:        cfg.Vault.Token,
+			RoleID:       cfg.Vault.Auth.RoleID,
+			SecretID:     cfg.Vault.Auth.SecretID,
+			SecretIDFile: cfg.Vault.Auth.SecretIDFile,
+			Role:         cfg.Vault.Auth.Role,
+			JWTPath:      cfg.Vault.Auth.JWTPath,
+			AuthMount:    cfg.Vault.Auth.Mount,
+			KVMount:      cfg.Vault.KVMount,
+			KVVersion:    cfg.Vault.KVVersion,
+			Field:        cfg.Vault.Field,
+			TransitMount: cfg.Vault.TransitMount,
+			TransitKey:   cfg.Vault.TransitKey,
+		})
+	case "secrets_manager":
+		if cfg.AWS == nil || !cfg.AWS.Enabled {
+			return nil, fmt.Errorf("secrets manager bootstrap secrets require aws.enabled")
+		}
+		awsCfg, err := loadAWSConfig(cfg.AWS)
+		if err != nil {
+			return nil, err
+		}
+		return cacheSecrets(infra_secrets.NewSecretsManager(awsCfg), cfg.AWS.CacheTTL), nil
+	case "local":
+		return infra_secrets.NewLocal(cfg.BootstrapSecretsBasePath, cfg.LocalSecrets.File)
+	default:
+		if cfg.AWS == nil || !cfg.AWS.Enabled {
+			return nil, nil
+		}
+		awsCfg, err := loadAWSConfig(cfg.AWS)
+		if err != nil {
+			return nil, err
+		}
+		return cacheSecrets(infra_secrets.NewParameterStore(awsCfg), cfg.AWS.CacheTTL), nil
+	}
+}
+
+func loadAWSConfig(cfg *AWSConfig) (aws.Config, error) {
 	awsCfg, err := aws_config.LoadDefaultConfig(
 		context.Background(),
-		aws_config.WithRegion(cfg.AWS.Region),
+		aws_config.WithRegion(cfg.Region),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return awsCfg, nil
+}
+
+// cacheSecrets caches the secrets of an AWS provider for ttl, when it is positive.
+func cacheSecrets(provider secrets.BootstrapSecretProvider, ttl time.Duration) secrets.BootstrapSecretProvider {
+	if ttl <= 0 {
+		return provider
 	}
+	return infra_secrets.NewCachedProvider(provider, ttl)
+}
 
-	secretProvider := infra_secrets.NewParameterStore(awsCfg)
-	return loadBootstrapSecrets(secretProvider, cfg.BootstrapSecretsBasePath)
+// fetchBootstrapSecrets loads the bootstrap secrets but the fields in skip or, when
+// reloading into previous, only the dynamic config values.
+func fetchBootstrapSecrets(secretProvider secrets.BootstrapSecretProvider, basePath string, previous *BootstrapSecrets, skip []string) (*BootstrapSecrets, error) {
+	if previous != nil {
+		return previous, loadSecretFields(secretProvider, basePath, previous, "reload", nil)
+	}
+	return loadBootstrapSecrets(secretProvider, basePath, skip)
 }
 
-// applyBootstrapConfigOverrides parses dynamic config from bootstrap secrets and applies to viper
-func applyBootstrapConfigOverrides(vip *viper.Viper, secrets *BootstrapSecrets) error {
+// fetchBootstrapSecretsAtStartup fetches the bootstrap secrets, trying again with
+// backoff for up to startup.Timeout while the secret store cannot be reached.
+func fetchBootstrapSecretsAtStartup(secretProvider secrets.BootstrapSecretProvider, basePath string, startup StartupConfig, skip []string) (*BootstrapSecrets, error) {
+	ctx := context.Background()
+	attempts := 1
+	if startup.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, startup.Timeout)
+		defer cancel()
+		attempts = math.MaxInt
+	}
+	return execution.Retry(ctx, execution.RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: startup.InitialBackoff,
+		MaxBackoff:     startup.MaxBackoff,
+		OnRetry: func(attempt int, err error) {
+			slog.Warn("bootstrap secrets unavailable, retrying", "attempt", attempt, "error", err)
+		},
+	}, func(context.Context) (*BootstrapSecrets, error) {
+		return loadBootstrapSecrets(secretProvider, basePath, skip)
+	})
+}
+
+// applyBootstrapConfigOverrides parses dynamic config from bootstrap secrets and applies to viper.
+// It returns the keys it set.
+func applyBootstrapConfigOverrides(vip *viper.Viper, secrets *BootstrapSecrets) ([]string, error) {
+	var overridden []string
+
 	// Apply circuit breaker config
 	if secrets.CircuitBreakerConfig != "" {
-		if err := applyConfigOverride(vip, "persistence.circuit_breaker", secrets.CircuitBreakerConfig); err != nil {
-			return fmt.Errorf("failed to apply circuit breaker config: %w", err)
+		keys, err := applyConfigOverride(vip, "persistence.circuit_breaker", secrets.CircuitBreakerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply circuit breaker config: %w", err)
 		}
+		overridden = append(overridden, keys...)
 	}
 
 	// Apply rate limiter config
 	if secrets.RateLimiterConfig != "" {
-		if err := applyConfigOverride(vip, "server.rate_limiter", secrets.RateLimiterConfig); err != nil {
-			return fmt.Errorf("failed to apply rate limiter config: %w", err)
+		keys, err := applyConfigOverride(vip, "server.rate_limiter", secrets.RateLimiterConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply rate limiter config: %w", err)
 		}
+		overridden = append(overridden, keys...)
 	}
 
 	// Apply async auditing config
 	if secrets.AsyncAuditingConfig != "" {
-		if err := applyConfigOverride(vip, "auditing.asynchronous", secrets.AsyncAuditingConfig); err != nil {
-			return fmt.Errorf("failed to apply async auditing config: %w", err)
+		keys, err := applyConfigOverride(vip, "auditing.asynchronous", secrets.AsyncAuditingConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply async auditing config: %w", err)
 		}
+		overridden = append(overridden, keys...)
 	}
 
-	return nil
+	return overridden, nil
 }
 
 // applyConfigOverride parses a JSON/YAML config string and applies it to viper at the given key prefix
-func applyConfigOverride(vip *viper.Viper, keyPrefix, configData string) error {
+func applyConfigOverride(vip *viper.Viper, keyPrefix, configData string) ([]string, error) {
 	configData = strings.TrimSpace(configData)
 	if configData == "" {
-		return nil
+		return nil, nil
 	}
 
 	var config map[string]interface{}
@@ -199,17 +542,19 @@ func applyConfigOverride(vip *viper.Viper, keyPrefix, configData string) error {
 	// Try JSON first, then YAML
 	if err := json.Unmarshal([]byte(configData), &config); err != nil {
 		if err := yaml.Unmarshal([]byte(configData), &config); err != nil {
-			return fmt.Errorf("failed to parse config as JSON or YAML: %w", err)
+			return nil, fmt.Errorf("failed to parse config as JSON or YAML: %w", err)
 		}
 	}
 
 	// Apply each key-value pair to viper
+	keys := make([]string, 0, len(config))
 	for key, value := range config {
 		fullKey := keyPrefix + "." + key
 		vip.Set(fullKey, value)
+		keys = append(keys, strings.ToLower(fullKey))
 	}
 
-	return nil
+	return keys, nil
 }
 
 func validateConfig(cfg *Config) error {
@@ -233,28 +578,120 @@ func validateConfig(cfg *Config) error {
 	if cfg.BootstrapSecrets.JWTRSAPrivateKey == "" {
 		return fmt.Errorf("JWT RSA private key is required")
 	}
+	if cfg.Persistence.Type == "neondb" && cfg.BootstrapSecrets.MetadataIntegrityKey == "" {
+		return fmt.Errorf("metadata integrity key is required")
+	}
 	if cfg.Server.TLS.Enabled {
-		if cfg.BootstrapSecrets.TLSServerCert == "" {
+		// A cert_file and key_file replace the certificate in the bootstrap secrets.
+		certFromFiles := cfg.Server.TLS.CertFile != "" || cfg.Server.TLS.KeyFile != ""
+		if certFromFiles && (cfg.Server.TLS.CertFile == "" || cfg.Server.TLS.KeyFile == "") {
+			return fmt.Errorf("TLS cert_file and key_file must be set together")
+		}
+		if !certFromFiles && cfg.BootstrapSecrets.TLSServerCert == "" {
 			return fmt.Errorf("TLS cert required when TLS enabled")
 		}
-		if cfg.BootstrapSecrets.TLSServerKey == "" {
+		if !certFromFiles && cfg.BootstrapSecrets.TLSServerKey == "" {
 			return fmt.Errorf("TLS key required when TLS enabled")
 		}
-		if cfg.BootstrapSecrets.SpoungeCA == "" {
+		// A client_ca_file replaces the CA in the bootstrap secrets.
+		caFromFile := cfg.Server.TLS.ClientCAFile != ""
+		if !caFromFile && cfg.BootstrapSecrets.SpoungeCA == "" {
 			return fmt.Errorf("CA cert required when TLS enabled")
 		}
 
 		// Validate TLS credentials
-		if err := validateTLSCredentials(&cfg.BootstrapSecrets); err != nil {
+		if err := validateTLSCredentials(&cfg.BootstrapSecrets, certFromFiles, caFromFile); err != nil {
 			return fmt.Errorf("TLS credentials validation failed: %w", err)
 		}
+		if err := validateTLSPolicy(cfg.Server); err != nil {
+			return err
+		}
+	}
+	for tier, ttl := range cfg.Authorization.Tokens.TierTTLs {
+		switch tier {
+		case "free", "pro", "enterprise":
+		default:
+			return fmt.Errorf("authorization.tokens.tier_ttls: unknown tier %q", tier)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("authorization.tokens.tier_ttls.%s must be positive", tier)
+		}
+	}
+	batchItemLimits := map[string]BatchItemLimits{"batch.max_items": cfg.Batch.MaxItems}
+	for method, limits := range cfg.Batch.MethodMaxItems {
+		batchItemLimits["batch.method_max_items."+method] = limits
+	}
+	for name, limits := range batchItemLimits {
+		if limits.Default < 0 {
+			return fmt.Errorf("%s.default must not be negative", name)
+		}
+		for tier, n := range limits.Tiers {
+			switch tier {
+			case "free", "pro", "enterprise":
+			default:
+				return fmt.Errorf("%s.tiers: unknown tier %q", name, tier)
+			}
+			if n < 0 {
+				return fmt.Errorf("%s.tiers.%s must not be negative", name, tier)
+			}
+		}
+	}
+	if cfg.Server.RateLimiter.Backend == "redis" && cfg.Redis.Address == "" {
+		return fmt.Errorf("redis.address is required for the redis rate limiter backend")
+	}
+	if cfg.Replication.Enabled && (cfg.Replication.Target == "" || cfg.Replication.StandbyURL == "") {
+		return fmt.Errorf("replication.target and replication.standby_url are required when replication is enabled")
+	}
+	if cfg.Events.NATS.Enabled && (cfg.Events.NATS.URL == "" || cfg.Events.NATS.Stream == "" || cfg.Events.NATS.SubjectPrefix == "") {
+		return fmt.Errorf("events.nats.url, events.nats.stream and events.nats.subject_prefix are required when NATS events are enabled")
+	}
+	if cfg.Server.Admin.Enabled {
+		if !cfg.Server.TLS.Enabled {
+			return fmt.Errorf("the admin listener requires TLS, since it authenticates clients by certificate")
+		}
+		if cfg.Server.Admin.Port == cfg.Server.Port {
+			return fmt.Errorf("the admin listener needs a port other than server.port")
+		}
+	}
+	for name, path := range map[string]string{
+		"auditing.kafka.dead_letter_path":      cfg.Auditing.Kafka.DeadLetterPath,
+		"auditing.sink_queue.dead_letter_path": cfg.Auditing.SinkQueue.DeadLetterPath,
+	} {
+		if path != "" && !filepath.IsAbs(path) {
+			return fmt.Errorf("%s must be an absolute path", name)
+		}
+	}
+	// Chaos is allowed only where it is explicitly wanted, so that a mode added later
+	// does not inherit it.
+	if cfg.Chaos.Enabled && cfg.Server.Mode != "development" {
+		return fmt.Errorf("chaos fault injection can only be enabled in development mode")
+	}
+	// The local provider reads secrets from the environment and a file on disk, which is
+	// only meant for running without AWS credentials.
+	if cfg.BootstrapSecretsProvider == "local" && cfg.Server.Mode != "development" {
+		return fmt.Errorf("the local bootstrap secrets provider can only be used in development mode")
+	}
+	if cfg.Server.Probes.Enabled {
+		if cfg.Server.Probes.Port == cfg.Server.Port || (cfg.Server.Admin.Enabled && cfg.Server.Probes.Port == cfg.Server.Admin.Port) {
+			return fmt.Errorf("the probe listener needs a port of its own")
+		}
 	}
 
 	return nil
 }
 
-// validateTLSCredentials performs validation of TLS certificates and keys
-func validateTLSCredentials(secrets *BootstrapSecrets) error {
+// validateTLSCredentials performs validation of TLS certificates and keys. Only the CA is
+// validated when the server certificate is read from files.
+func validateTLSCredentials(secrets *BootstrapSecrets, certFromFiles, caFromFile bool) error {
+	if !caFromFile {
+		if err := validatePEMFormat("CA Cert", secrets.SpoungeCA, "CERTIFICATE"); err != nil {
+			return err
+		}
+	}
+	if certFromFiles {
+		return nil
+	}
+
 	// Check for common PEM formatting issues
 	if err := validatePEMFormat("TLS Server Cert", secrets.TLSServerCert, "CERTIFICATE"); err != nil {
 		return err
@@ -263,10 +700,6 @@ func validateTLSCredentials(secrets *BootstrapSecrets) error {
 	if err := validatePEMFormat("TLS Server Key", secrets.TLSServerKey, "PRIVATE KEY"); err != nil {
 		return err
 	}
-	
-	if err := validatePEMFormat("CA Cert", secrets.SpoungeCA, "CERTIFICATE"); err != nil {
-		return err
-	}
 
 	// Test actual TLS key pair loading
 	_, err := tls.X509KeyPair([]byte(secrets.TLSServerCert), []byte(secrets.TLSServerKey))
@@ -348,8 +781,17 @@ func getenv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func loadBootstrapSecrets(secretProvider secrets.BootstrapSecretProvider, basePath string) (*BootstrapSecrets, error) {
+func loadBootstrapSecrets(secretProvider secrets.BootstrapSecretProvider, basePath string, skip []string) (*BootstrapSecrets, error) {
 	secretsObj := &BootstrapSecrets{}
+	if err := loadSecretFields(secretProvider, basePath, secretsObj, "", skip); err != nil {
+		return nil, err
+	}
+	return secretsObj, nil
+}
+
+// loadSecretFields fills the fields of secretsObj from their secret paths, or only those
+// tagged only:"true" when only is set, except the fields named in skip.
+func loadSecretFields(secretProvider secrets.BootstrapSecretProvider, basePath string, secretsObj *BootstrapSecrets, only string, skip []string) error {
 	secretsVal := reflect.ValueOf(secretsObj).Elem()
 	secretsType := secretsVal.Type()
 
@@ -363,14 +805,14 @@ func loadBootstrapSecrets(secretProvider secrets.BootstrapSecretProvider, basePa
 		fieldType := secretsType.Field(i)
 		relPath := fieldType.Tag.Get("secretpath")
 
-		if relPath == "" || !field.CanSet() {
+		if relPath == "" || !field.CanSet() || (only != "" && fieldType.Tag.Get(only) != "true") || slices.Contains(skip, fieldType.Name) {
 			continue
 		}
 
 		fullPath := base + relPath
 		secretValue, err := secretProvider.GetSecret(ctx, fullPath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load secret %s (%s): %w", fieldType.Name, fullPath, err)
+			return fmt.Errorf("failed to load secret %s (%s): %w", fieldType.Name, fullPath, err)
 		}
 
 		// Clean up common whitespace issues
@@ -378,5 +820,5 @@ func loadBootstrapSecrets(secretProvider secrets.BootstrapSecretProvider, basePa
 		field.SetString(secretValue)
 	}
 
-	return secretsObj, nil
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"strings"
+
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+// DEKPoolsConfig sizes the pools that recycle the buffers DEKs are generated in, one per
+// key type. A pool keeps up to its capacity of idle buffers; gets beyond them allocate,
+// and are counted as misses in polykey.dek_pool.misses.
+type DEKPoolsConfig struct {
+	// DefaultCapacity applies to key types without their own entry in Capacity; zero
+	// takes the default of 64.
+	DefaultCapacity int `mapstructure:"default_capacity" validate:"gte=0"`
+	// Capacity is keyed by key type name, such as KEY_TYPE_AES_256, case-insensitively.
+	Capacity map[string]int `mapstructure:"capacity"`
+}
+
+// PoolCapacity returns the capacity of the pool of keyType.
+func (c DEKPoolsConfig) PoolCapacity(keyType pk.KeyType) int {
+	if n, ok := c.Capacity[strings.ToLower(keyType.String())]; ok && n > 0 {
+		return n
+	}
+	return c.DefaultCapacity
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// ReadFile reads the config at path with its defaults and environment overrides, without
+// loading the bootstrap secrets or validating the result. It lets diagnostics tell a
+// broken config file apart from a secret provider that cannot be reached.
+func ReadFile(path string) (*Config, error) {
+	vip := viper.New()
+	setupViper(vip, path)
+	if err := vip.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+	var cfg Config
+	if err := vip.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// CheckBootstrapSecrets reads every bootstrap secret of cfg from its provider and returns
+// the first error. It reports false when cfg loads no bootstrap secrets at all.
+func CheckBootstrapSecrets(cfg *Config) (bool, error) {
+	secretProvider, err := newBootstrapSecretProvider(cfg)
+	if err != nil {
+		return true, fmt.Errorf("failed to create bootstrap secret provider: %w", err)
+	}
+	if secretProvider == nil {
+		return false, nil
+	}
+	if _, err := loadBootstrapSecrets(secretProvider, cfg.BootstrapSecretsBasePath, cfg.Server.TLS.fileSecrets()); err != nil {
+		return true, err
+	}
+	return true, nil
+}
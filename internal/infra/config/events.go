@@ -0,0 +1,30 @@
+package config
+
+import "time"
+
+// EventsConfig holds the configuration of the event buses key lifecycle events are
+// published to.
+type EventsConfig struct {
+	NATS NATSEventsConfig `mapstructure:"nats"`
+}
+
+// NATSEventsConfig holds the configuration for publishing key lifecycle events to NATS
+// JetStream. Events are recorded in the database in the transaction that changed the key,
+// and the replica leading the background jobs publishes them every Interval, BatchSize at
+// a time, on SubjectPrefix.<namespace>.<event type>. An event is deleted only once
+// JetStream acknowledged it, so every event is delivered at least once; receivers drop
+// duplicates by the Nats-Msg-Id header, which JetStream also deduplicates on within the
+// stream's duplicate window.
+type NATSEventsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	URL     string `mapstructure:"url" sensitive:"true"`
+	// CredentialsFile is a NATS user credentials file, for servers that require one.
+	CredentialsFile string `mapstructure:"credentials_file"`
+	// Stream is created over SubjectPrefix.> when CreateStream is set and it is missing.
+	Stream         string        `mapstructure:"stream"`
+	CreateStream   bool          `mapstructure:"create_stream"`
+	SubjectPrefix  string        `mapstructure:"subject_prefix"`
+	PublishTimeout time.Duration `mapstructure:"publish_timeout" validate:"gte=0"`
+	Interval       time.Duration `mapstructure:"interval" validate:"gte=0"`
+	BatchSize      int           `mapstructure:"batch_size" validate:"gte=0"`
+}
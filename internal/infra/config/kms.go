@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+// KMSConfig holds the settings shared by the remote KMS providers.
+type KMSConfig struct {
+	Retry KMSRetryConfig `mapstructure:"retry"`
+	// CircuitBreaker guards each remote provider. Only transient failures count towards
+	// opening it, and every retry attempt counts.
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+}
+
+// KMSRetryConfig is the retry policy of remote KMS calls. Throttling, KMS internal errors,
+// server errors, network errors and timed out attempts are retried while the request's
+// deadline leaves room for the wait; other errors fail at once.
+type KMSRetryConfig struct {
+	// MaxAttempts counts the first attempt; 1 disables retries.
+	MaxAttempts    int           `mapstructure:"max_attempts" validate:"gte=0"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff" validate:"gte=0"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff" validate:"gte=0"`
+}
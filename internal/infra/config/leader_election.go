@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// LeaderElectionConfig holds the configuration for electing the one replica that runs the
+// background jobs: key expiration, audit retention and key version retention. Replicas
+// campaign for a Postgres advisory lock named by LockName; the holder runs the jobs and
+// the others keep them on standby, trying again every Interval.
+//
+// The lock is held by a session, so the database must be reached without transaction
+// pooling in between.
+type LeaderElectionConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	LockName string        `mapstructure:"lock_name"`
+	Interval time.Duration `mapstructure:"interval" validate:"gte=0"`
+}
@@ -0,0 +1,71 @@
+package config
+
+import "time"
+
+// KeyLifecycleConfig holds the configuration for background key lifecycle jobs.
+type KeyLifecycleConfig struct {
+	Expiration  KeyExpirationConfig  `mapstructure:"expiration"`
+	Rotation    KeyRotationConfig    `mapstructure:"rotation"`
+	AccessStats KeyAccessStatsConfig `mapstructure:"access_stats"`
+	Restore     KeyRestoreConfig     `mapstructure:"restore"`
+	// VersionRetention bounds how many old versions each key keeps.
+	VersionRetention KeyVersionRetentionConfig `mapstructure:"version_retention"`
+}
+
+// KeyVersionRetentionConfig holds the configuration for the job that removes old key
+// versions. A version is removed only when it is beyond the newest KeepVersions of its
+// key and went out of service more than MinAge ago, as data encrypted with it can no
+// longer be decrypted afterwards.
+type KeyVersionRetentionConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Interval     time.Duration `mapstructure:"interval" validate:"gte=0"`
+	BatchSize    int           `mapstructure:"batch_size" validate:"gte=0"`
+	KeepVersions int           `mapstructure:"keep_versions" validate:"gte=0"`
+	MinAge       time.Duration `mapstructure:"min_age" validate:"gte=0"`
+	// Archive moves removed versions to an archive instead of deleting them.
+	Archive bool `mapstructure:"archive"`
+}
+
+// KeyRestoreConfig holds the configuration for undoing revocations.
+type KeyRestoreConfig struct {
+	// Windows maps a client tier to how long after revocation its keys can still be
+	// restored. A tier without an entry, or with zero, cannot restore keys.
+	Windows map[string]time.Duration `mapstructure:"windows"`
+}
+
+// KeyAccessStatsConfig holds the configuration for recording key reads into the
+// AccessCount and LastAccessedAt metadata fields.
+type KeyAccessStatsConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	FlushInterval time.Duration `mapstructure:"flush_interval" validate:"gte=0"`
+	// MaxPendingKeys bounds how many distinct keys are aggregated between flushes;
+	// reaching it triggers an early flush.
+	MaxPendingKeys int `mapstructure:"max_pending_keys" validate:"gte=0"`
+}
+
+// KeyRotationConfig holds the configuration for key rotation.
+type KeyRotationConfig struct {
+	// DefaultGracePeriod is how long a replaced version stays readable when the
+	// request does not specify a grace period.
+	DefaultGracePeriod time.Duration `mapstructure:"default_grace_period" validate:"gte=0"`
+	// Scheduled rotates the keys created from a template with a rotation period once
+	// that period has passed since their latest version was created.
+	Scheduled KeyScheduledRotationConfig `mapstructure:"scheduled"`
+}
+
+// KeyScheduledRotationConfig holds the configuration for the scheduled rotation job.
+type KeyScheduledRotationConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Interval  time.Duration `mapstructure:"interval" validate:"gte=0"`
+	BatchSize int           `mapstructure:"batch_size" validate:"gte=0"`
+}
+
+// KeyExpirationConfig holds the configuration for the key expiration job.
+type KeyExpirationConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Interval  time.Duration `mapstructure:"interval" validate:"gte=0"`
+	BatchSize int           `mapstructure:"batch_size" validate:"gte=0"`
+	// NotifyBefore is how far ahead of expiry an "expiring" key event is published.
+	// Zero disables the notice.
+	NotifyBefore time.Duration `mapstructure:"notify_before"`
+}
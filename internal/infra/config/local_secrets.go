@@ -0,0 +1,9 @@
+package config
+
+// LocalSecretsConfig represents the local bootstrap secrets used for development without
+// a cloud secret store. Each secret is read from its POLYKEY_SECRET_ environment variable
+// or else from File, a YAML map from secret paths to values, which may be sealed with
+// cmd/seal_secrets and opened with the key in POLYKEY_LOCAL_SECRETS_KEY.
+type LocalSecretsConfig struct {
+	File string `mapstructure:"file"`
+}
@@ -0,0 +1,25 @@
+package config
+
+// LoggingConfig holds the configuration for the service's logs. Level is the global
+// level and Modules override it for single modules; both can be changed at runtime.
+// RedactFields names attributes to redact on top of logging.DefaultRedactedFields.
+type LoggingConfig struct {
+	Level        string           `mapstructure:"level"`
+	Modules      []ModuleLogLevel `mapstructure:"modules" validate:"dive"`
+	RedactFields []string         `mapstructure:"redact_fields"`
+}
+
+// ModuleLogLevel sets the log level of one module, such as audit or persistence.
+type ModuleLogLevel struct {
+	Module string `mapstructure:"module" validate:"required"`
+	Level  string `mapstructure:"level" validate:"required"`
+}
+
+// ModuleLevels returns the module levels keyed by module.
+func (c LoggingConfig) ModuleLevels() map[string]string {
+	modules := make(map[string]string, len(c.Modules))
+	for _, module := range c.Modules {
+		modules[module.Module] = module.Level
+	}
+	return modules
+}
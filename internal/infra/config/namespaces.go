@@ -0,0 +1,21 @@
+package config
+
+import "strings"
+
+// NamespacesConfig holds the per-namespace limits of a deployment shared by several
+// teams. Namespace names are matched in lower case.
+type NamespacesConfig struct {
+	// DefaultMaxKeys caps the keys of namespaces without their own entry in MaxKeys.
+	// Zero means no limit.
+	DefaultMaxKeys int `mapstructure:"default_max_keys" validate:"gte=0"`
+	// MaxKeys caps, per namespace, how many keys that have not been destroyed it may hold.
+	MaxKeys map[string]int `mapstructure:"max_keys"`
+}
+
+// KeyQuota returns the maximum number of keys of namespace, or zero for no limit.
+func (c NamespacesConfig) KeyQuota(namespace string) int {
+	if quota, ok := c.MaxKeys[strings.ToLower(namespace)]; ok {
+		return quota
+	}
+	return c.DefaultMaxKeys
+}
@@ -2,7 +2,9 @@ package config
 
 import "time"
 
-// CircuitBreakerConfig holds settings for the persistence circuit breaker.
+// CircuitBreakerConfig holds the settings of a group of circuit breakers. Under
+// persistence, it applies to the key repository's reads, writes and batch breakers and
+// to the audit repository's breaker alike.
 type CircuitBreakerConfig struct {
 	Enabled      bool          `mapstructure:"enabled"`
 	MaxFailures  int           `mapstructure:"max_failures"`
@@ -14,21 +16,44 @@ type PersistenceConfig struct {
 	Type           string               `mapstructure:"type" validate:"required,oneof=s3 neondb cockroachdb"`
 	Database       DatabaseConfig       `mapstructure:"database"`
 	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	Hedging        HedgingConfig        `mapstructure:"hedging"`
+}
+
+// HedgingConfig controls hedged key reads: a GetKey query that has not answered within
+// Delay is sent again, and the first answer is used. At most MaxInFlight second queries
+// run at once.
+type HedgingConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Delay       time.Duration `mapstructure:"delay" validate:"gte=0"`
+	MaxInFlight int           `mapstructure:"max_in_flight" validate:"gte=0"`
 }
 
 // DatabaseConfig represents the database configuration.
 type DatabaseConfig struct {
 	Connection DBConnectionConfig `mapstructure:"connection"`
 	TLS        TLSConfig          `mapstructure:"tls"`
+	SlowQuery  SlowQueryConfig    `mapstructure:"slow_query"`
+}
+
+// SlowQueryConfig controls the logging of statements that take at least Threshold.
+type SlowQueryConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Threshold time.Duration `mapstructure:"threshold"`
 }
 
-// DBConnectionConfig represents the database connection pool configuration.
+// DBConnectionConfig represents the database connection pool configuration. Zero values
+// keep the defaults of pgxpool, or those set with pool_ parameters of the database URL.
+// MaxConns should stay below the connection limit of the database, or of its pooler,
+// divided by the replicas sharing it.
 type DBConnectionConfig struct {
-	MaxConns        int32         `mapstructure:"max_conns"`
-	MinConns        int32         `mapstructure:"min_conns"`
-	MaxConnLifetime time.Duration `mapstructure:"max_conn_lifetime"`
-	MaxConnIdleTime time.Duration `mapstructure:"max_conn_idle_time"`
-	HealthCheckPeriod time.Duration `mapstructure:"health_check_period"`
+	MaxConns        int32         `mapstructure:"max_conns" validate:"gte=0"`
+	MinConns        int32         `mapstructure:"min_conns" validate:"gte=0"`
+	MaxConnLifetime time.Duration `mapstructure:"max_conn_lifetime" validate:"gte=0"`
+	// MaxConnLifetimeJitter spreads the recycling of connections opened together over up
+	// to this long, so that they do not all reconnect at once.
+	MaxConnLifetimeJitter time.Duration `mapstructure:"max_conn_lifetime_jitter" validate:"gte=0"`
+	MaxConnIdleTime       time.Duration `mapstructure:"max_conn_idle_time" validate:"gte=0"`
+	HealthCheckPeriod     time.Duration `mapstructure:"health_check_period" validate:"gte=0"`
 }
 
 // TLSConfig represents the database TLS configuration.
@@ -39,10 +64,10 @@ type TLSConfig struct {
 
 // NeonDBConfig represents the NeonDB configuration.
 type NeonDBConfig struct {
-	URL string `mapstructure:"url" validate:"required,url"`
+	URL string `mapstructure:"url" validate:"required,url" sensitive:"true"`
 }
 
 // CockroachDBConfig represents the CockroachDB configuration.
 type CockroachDBConfig struct {
-	URL string `mapstructure:"url" validate:"required,url"`
+	URL string `mapstructure:"url" validate:"required,url" sensitive:"true"`
 }
\ No newline at end of file
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Sources of config values, from lowest to highest precedence.
+const (
+	SourceDefault         = "default"
+	SourceFile            = "file"
+	SourceEnv             = "env"
+	SourceBootstrapSecret = "bootstrap_secret"
+)
+
+// maskedValue replaces the values of sensitive settings and bootstrap secrets.
+const maskedValue = "******"
+
+// Setting is one effective config value and the source it won from.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// recordSources returns the source of the value viper resolved for each of its keys.
+// overridden are the prefixes set from the dynamic config values in bootstrap secrets.
+func recordSources(vip *viper.Viper, overridden []string) map[string]string {
+	sources := make(map[string]string)
+	for _, key := range vip.AllKeys() {
+		switch {
+		case hasKeyPrefix(key, overridden):
+			sources[key] = SourceBootstrapSecret
+		case isEnvSet(key):
+			sources[key] = SourceEnv
+		case vip.InConfig(key):
+			sources[key] = SourceFile
+		default:
+			sources[key] = SourceDefault
+		}
+	}
+	return sources
+}
+
+func hasKeyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isEnvSet reports whether the environment variable setupViper binds to key is set.
+func isEnvSet(key string) bool {
+	_, ok := os.LookupEnv("POLYKEY_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_")))
+	return ok
+}
+
+// Settings returns the effective config, one setting per value sorted by key, with the
+// values of fields tagged sensitive masked. Bootstrap secrets are listed masked under
+// bootstrap_secrets, keyed by their secret path.
+func (c *Config) Settings() []Setting {
+	settings := c.appendSettings(nil, "", reflect.ValueOf(c).Elem(), false)
+
+	secretsVal := reflect.ValueOf(c.BootstrapSecrets)
+	for i := 0; i < secretsVal.NumField(); i++ {
+		path := secretsVal.Type().Field(i).Tag.Get("secretpath")
+		if path == "" {
+			continue
+		}
+		settings = append(settings, Setting{
+			Key:    "bootstrap_secrets." + path,
+			Value:  mask(secretsVal.Field(i).String(), true),
+			Source: SourceBootstrapSecret,
+		})
+	}
+
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+func (c *Config) appendSettings(settings []Setting, key string, v reflect.Value, sensitive bool) []Setting {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return settings
+		}
+		return c.appendSettings(settings, key, v.Elem(), sensitive)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := field.Tag.Get("mapstructure")
+			if name == "" || !field.IsExported() {
+				continue
+			}
+			settings = c.appendSettings(settings, joinKey(key, name), v.Field(i), sensitive || field.Tag.Get("sensitive") == "true")
+		}
+		return settings
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			for i := 0; i < v.Len(); i++ {
+				settings = c.appendSettings(settings, joinKey(key, fmt.Sprint(i)), v.Index(i), sensitive)
+			}
+			return settings
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, mapKey := range keys {
+			settings = c.appendSettings(settings, joinKey(key, fmt.Sprint(mapKey)), v.MapIndex(mapKey), sensitive)
+		}
+		return settings
+	}
+	return append(settings, Setting{Key: key, Value: mask(fmt.Sprint(v.Interface()), sensitive), Source: c.source(key)})
+}
+
+// source returns the source of key, or of the nearest key above it that viper knows,
+// such as the list a webhook endpoint's settings came in.
+func (c *Config) source(key string) string {
+	for k := key; k != ""; {
+		if source, ok := c.sources[k]; ok {
+			return source
+		}
+		i := strings.LastIndex(k, ".")
+		if i < 0 {
+			break
+		}
+		k = k[:i]
+	}
+	return SourceDefault
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func mask(value string, sensitive bool) string {
+	if sensitive && value != "" {
+		return maskedValue
+	}
+	return value
+}
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// RedisConfig is the Redis server shared by the replicas, used when
+// server.rate_limiter.backend is redis.
+type RedisConfig struct {
+	Address  string `mapstructure:"address" validate:"omitempty,hostname_port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password" sensitive:"true"`
+	DB       int    `mapstructure:"db" validate:"gte=0"`
+	TLS      bool   `mapstructure:"tls"`
+	// Timeout bounds each command. A rate limit check that runs out of it falls back to
+	// the replica's own limiter rather than holding up the request.
+	Timeout   time.Duration `mapstructure:"timeout" validate:"gte=0"`
+	KeyPrefix string        `mapstructure:"key_prefix"`
+}
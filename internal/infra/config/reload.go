@@ -0,0 +1,232 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// ReloadConfig holds the configuration for re-reading the config file and the dynamic
+// config values in Parameter Store while the service runs. Only the settings Diff
+// compares can change without a restart.
+type ReloadConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval" validate:"gte=0"`
+}
+
+// Change is one reloadable setting that differs between two configs, keyed by its
+// config path, such as server.rate_limiter.rate.
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+// reloadableSettings are the sections of the config that can be applied at runtime.
+var reloadableSettings = []struct {
+	key   string
+	value func(*Config) any
+}{
+	{"server.rate_limiter", func(c *Config) any { return c.Server.RateLimiter }},
+	{"persistence.circuit_breaker", func(c *Config) any { return c.Persistence.CircuitBreaker }},
+	{"auditing.asynchronous", func(c *Config) any { return c.Auditing.Asynchronous }},
+	{"logging.level", func(c *Config) any { return c.Logging.Level }},
+	{"logging.modules", func(c *Config) any { return c.Logging.Modules }},
+}
+
+// Diff returns the reloadable settings that differ between old and next, sorted by key.
+func Diff(old, next *Config) []Change {
+	before, after := make(map[string]string), make(map[string]string)
+	for _, setting := range reloadableSettings {
+		flattenSetting(setting.key, reflect.ValueOf(setting.value(old)), before)
+		flattenSetting(setting.key, reflect.ValueOf(setting.value(next)), after)
+	}
+
+	var changes []Change
+	for key, value := range after {
+		if before[key] != value {
+			changes = append(changes, Change{Key: key, Old: before[key], New: value})
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			changes = append(changes, Change{Key: key, Old: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// flattenSetting writes the leaves of v to out under their mapstructure paths. Module
+// log levels are keyed by module, so that reordering them is not a change.
+func flattenSetting(key string, v reflect.Value, out map[string]string) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Tag.Get("mapstructure")
+			if name == "" {
+				continue
+			}
+			flattenSetting(key+"."+name, v.Field(i), out)
+		}
+	case reflect.Slice:
+		if modules, ok := v.Interface().([]ModuleLogLevel); ok {
+			for _, module := range modules {
+				out[key+"."+module.Module] = module.Level
+			}
+			return
+		}
+		out[key] = fmt.Sprint(v.Interface())
+	default:
+		out[key] = fmt.Sprint(v.Interface())
+	}
+}
+
+// Watcher reloads the config at an interval and hands every change of a reloadable
+// setting to apply. A config that fails to load or validate is logged and skipped; the
+// service keeps running with the last good one.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	apply    func(ctx context.Context, old, next *Config, changes []Change)
+	logger   *slog.Logger
+
+	reloadMu sync.Mutex
+	mu       sync.Mutex
+	current  *Config
+	lastErr  error
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+var _ lifecycle.ManagedResource = (*Watcher)(nil)
+
+// NewWatcher creates a Watcher for the config at path, which the service was started
+// with as current.
+func NewWatcher(path string, current *Config, logger *slog.Logger, apply func(ctx context.Context, old, next *Config, changes []Change)) *Watcher {
+	interval := current.Reload.Interval
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		apply:    apply,
+		logger:   logger,
+		current:  current,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Current returns the config as last reloaded.
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Start polls for changes in the background until Stop is called or ctx is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	w.startOnce.Do(func() { go w.run(ctx) })
+	return nil
+}
+
+// Stop ends polling and waits for a reload in progress to finish.
+func (w *Watcher) Stop(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	started := true
+	w.startOnce.Do(func() { started = false; close(w.done) })
+	if !started {
+		return nil
+	}
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports whether the last reload succeeded.
+func (w *Watcher) Health(context.Context) lifecycle.HealthStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: true, Message: "last config reload failed: " + w.lastErr.Error()}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: "config watcher is running"}
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			_ = w.ReloadOnce(ctx)
+		}
+	}
+}
+
+// ReloadOnce loads the config and applies it if a reloadable setting changed.
+func (w *Watcher) ReloadOnce(ctx context.Context) error {
+	_, err := w.Reload(ctx)
+	return err
+}
+
+// Reload loads the config, applies it if a reloadable setting changed and returns the
+// changes. Reloads triggered on demand share the lock of the periodic ones, so that
+// two never apply at once.
+func (w *Watcher) Reload(ctx context.Context) ([]Change, error) {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	w.mu.Lock()
+	current := w.current
+	w.mu.Unlock()
+
+	next, err := Reload(w.path, current)
+	w.mu.Lock()
+	w.lastErr = err
+	w.mu.Unlock()
+	if err != nil {
+		w.logger.ErrorContext(ctx, "failed to reload config, keeping the current one", "error", err)
+		return nil, err
+	}
+
+	changes := Diff(current, next)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(changes))
+	for i, change := range changes {
+		keys[i] = change.Key
+	}
+	w.logger.InfoContext(ctx, "config changed", "settings", strings.Join(keys, ","))
+
+	w.apply(ctx, current, next, changes)
+	w.mu.Lock()
+	w.current = next
+	w.mu.Unlock()
+	return changes, nil
+}
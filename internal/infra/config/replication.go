@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// ReplicationConfig holds the configuration for shipping key mutations to a standby
+// database in another region. The replica leading the background jobs registers Target
+// on the primary, which from then on records every changed key version, and every
+// Interval ships the recorded versions to the database at StandbyURL, BatchSize at a
+// time. Only keys are replicated; audit events stay in the region that wrote them.
+type ReplicationConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Target     string        `mapstructure:"target" validate:"omitempty,max=63"`
+	StandbyURL string        `mapstructure:"standby_url" sensitive:"true"`
+	Interval   time.Duration `mapstructure:"interval" validate:"gte=0"`
+	BatchSize  int           `mapstructure:"batch_size" validate:"gte=0"`
+}
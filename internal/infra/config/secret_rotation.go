@@ -0,0 +1,66 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SecretRotationConfig holds the configuration for reading the rotatable bootstrap
+// secrets, the database URL, JWT private key and TLS materials, from the bootstrap secret
+// provider again at an interval. Rotation can also be triggered on demand.
+type SecretRotationConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval" validate:"gte=0"`
+}
+
+// secretInvalidator is implemented by providers that cache secrets.
+type secretInvalidator interface {
+	Invalidate(ctx context.Context)
+}
+
+// FetchRotatableSecrets reads the bootstrap secrets tagged rotate from the bootstrap
+// secret provider again, bypassing its cache, and returns c's bootstrap secrets with
+// them replaced.
+func (c *Config) FetchRotatableSecrets(ctx context.Context) (BootstrapSecrets, error) {
+	fetched := c.BootstrapSecrets
+	if c.secretProvider == nil {
+		return fetched, errors.New("no bootstrap secret provider is configured")
+	}
+	if cached, ok := c.secretProvider.(secretInvalidator); ok {
+		cached.Invalidate(ctx)
+	}
+	if err := loadSecretFields(c.secretProvider, c.BootstrapSecretsBasePath, &fetched, "rotate", c.Server.TLS.fileSecrets()); err != nil {
+		return c.BootstrapSecrets, err
+	}
+	return fetched, nil
+}
+
+// FetchServerCertificate reads the TLS server certificate and key from the bootstrap
+// secret provider, through its cache.
+func (c *Config) FetchServerCertificate(ctx context.Context) (certPEM, keyPEM string, err error) {
+	if c.secretProvider == nil {
+		return "", "", errors.New("no bootstrap secret provider is configured")
+	}
+	if certPEM, err = c.fetchSecret(ctx, "TLSServerCert"); err != nil {
+		return "", "", err
+	}
+	if keyPEM, err = c.fetchSecret(ctx, "TLSServerKey"); err != nil {
+		return "", "", err
+	}
+	return certPEM, keyPEM, nil
+}
+
+// fetchSecret reads the bootstrap secret of the BootstrapSecrets field named field.
+func (c *Config) fetchSecret(ctx context.Context, field string) (string, error) {
+	structField, _ := reflect.TypeOf(BootstrapSecrets{}).FieldByName(field)
+	path := strings.TrimRight(c.BootstrapSecretsBasePath, "/") + "/" + structField.Tag.Get("secretpath")
+	value, err := c.secretProvider.GetSecret(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to load secret %s (%s): %w", field, path, err)
+	}
+	return strings.TrimSpace(value), nil
+}
@@ -1,11 +1,104 @@
 package config
 
+import (
+	"path/filepath"
+	"time"
+)
+
 // ServerConfig represents the server configuration.
 type ServerConfig struct {
-	Port       int               `mapstructure:"port" validate:"required,gte=1024,lte=65535"`
-	TLS        TLS               `mapstructure:"tls"`
-	Mode       string            `mapstructure:"mode" validate:"required,oneof=development production"`
-	RateLimiter RateLimiterConfig `mapstructure:"rate_limiter"`
+	Port                int                `mapstructure:"port" validate:"required,gte=1024,lte=65535"`
+	TLS                 TLS                `mapstructure:"tls"`
+	Mode                string             `mapstructure:"mode" validate:"required,oneof=development production"`
+	RateLimiter         RateLimiterConfig  `mapstructure:"rate_limiter"`
+	HealthCheckInterval time.Duration      `mapstructure:"health_check_interval"`
+	ShutdownTimeout     time.Duration      `mapstructure:"shutdown_timeout"`
+	Transport           TransportConfig    `mapstructure:"transport"`
+	LoadShedding        LoadSheddingConfig `mapstructure:"load_shedding"`
+	Deadlines           DeadlineConfig     `mapstructure:"deadlines"`
+	Bulkheads           BulkheadConfig     `mapstructure:"bulkheads"`
+	Debug               DebugConfig        `mapstructure:"debug"`
+	Admin               AdminServerConfig  `mapstructure:"admin"`
+	Probes              ProbeServerConfig  `mapstructure:"probes"`
+}
+
+// AdminServerConfig holds the configuration of the admin listener, which serves the
+// operational RPCs apart from the data-plane API. It verifies client certificates
+// against the CA, requiring one unless ClientAuth says otherwise, and when
+// AllowedIdentities is set, only the listed identities, matched against the
+// certificate's common name and DNS names, may connect.
+type AdminServerConfig struct {
+	Enabled           bool     `mapstructure:"enabled"`
+	Port              int      `mapstructure:"port" validate:"required_if=Enabled true,omitempty,gte=1024,lte=65535"`
+	AllowedIdentities []string `mapstructure:"allowed_identities"`
+	// ClientAuth is the client auth mode of the admin listener, independent of the one of
+	// server.tls. Only RequireAndVerifyClientCert, the default, is accepted.
+	ClientAuth string `mapstructure:"client_auth"`
+}
+
+// ProbeServerConfig holds the configuration of the plain HTTP listener serving /healthz
+// and /readyz, for probes and load balancers that do not speak gRPC. It serves nothing
+// else, so it needs neither TLS nor authentication.
+type ProbeServerConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Port    int  `mapstructure:"port" validate:"required_if=Enabled true,omitempty,gte=1024,lte=65535"`
+}
+
+// DebugConfig toggles gRPC introspection services. Both are ignored in production mode.
+type DebugConfig struct {
+	Reflection bool `mapstructure:"reflection"`
+	Channelz   bool `mapstructure:"channelz"`
+}
+
+// DeadlineConfig holds the server-side timeouts for unary RPCs, keyed by method name.
+type DeadlineConfig struct {
+	Default time.Duration            `mapstructure:"default"`
+	Methods map[string]time.Duration `mapstructure:"methods"`
+}
+
+// BulkheadConfig caps how many calls of expensive RPCs are served at once, so that large
+// batches cannot take every database connection and starve single-key traffic. Methods
+// are keyed by bare method name, case-insensitively; each tier of a method has its own
+// slots, so one tier's batches cannot use up another's.
+type BulkheadConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxWait is how long a call waits for a slot before it fails with
+	// RESOURCE_EXHAUSTED; zero fails it at once.
+	MaxWait time.Duration             `mapstructure:"max_wait" validate:"gte=0"`
+	Methods map[string]BulkheadLimits `mapstructure:"methods"`
+}
+
+// BulkheadLimits are the concurrent calls of one method allowed per client tier. Tiers
+// without an entry get Default; zero means no limit.
+type BulkheadLimits struct {
+	Default int            `mapstructure:"default" validate:"gte=0"`
+	Tiers   map[string]int `mapstructure:"tiers"`
+}
+
+// LoadSheddingConfig holds the thresholds above which low-priority RPCs are rejected.
+// A zero threshold disables that signal.
+type LoadSheddingConfig struct {
+	Enabled                     bool          `mapstructure:"enabled"`
+	SampleInterval              time.Duration `mapstructure:"sample_interval"`
+	MaxRotationQueueUtilization float64       `mapstructure:"max_rotation_queue_utilization" validate:"gte=0,lte=1"`
+	MaxPoolAcquireWait          time.Duration `mapstructure:"max_pool_acquire_wait"`
+	MaxGoroutines               int           `mapstructure:"max_goroutines" validate:"gte=0"`
+	PriorityMethods             []string      `mapstructure:"priority_methods"`
+}
+
+// TransportConfig represents the gRPC transport tuning.
+// Zero values leave the grpc-go defaults in place.
+type TransportConfig struct {
+	MaxRecvMsgSize               int           `mapstructure:"max_recv_msg_size" validate:"gte=0"`
+	MaxSendMsgSize               int           `mapstructure:"max_send_msg_size" validate:"gte=0"`
+	MaxConcurrentStreams         uint32        `mapstructure:"max_concurrent_streams"`
+	MaxConnectionIdle            time.Duration `mapstructure:"max_connection_idle"`
+	MaxConnectionAge             time.Duration `mapstructure:"max_connection_age"`
+	MaxConnectionAgeGrace        time.Duration `mapstructure:"max_connection_age_grace"`
+	KeepaliveTime                time.Duration `mapstructure:"keepalive_time"`
+	KeepaliveTimeout             time.Duration `mapstructure:"keepalive_timeout"`
+	KeepaliveMinTime             time.Duration `mapstructure:"keepalive_min_time"`
+	KeepalivePermitWithoutStream bool          `mapstructure:"keepalive_permit_without_stream"`
 }
 
 // RateLimiterConfig holds the configuration for the gRPC rate limiter.
@@ -13,13 +106,64 @@ type RateLimiterConfig struct {
 	Enabled bool    `mapstructure:"enabled"`
 	Rate    float64 `mapstructure:"rate"`
 	Burst   int     `mapstructure:"burst"`
+	// Backend is "memory", a bucket per client in each replica, so that the effective
+	// limit grows with the replica count, or "redis", one bucket per client shared by all
+	// replicas through the redis section's server.
+	Backend string `mapstructure:"backend" validate:"omitempty,oneof=memory redis"`
 }
 
-// TLS represents the TLS configuration.
+// TLS represents the TLS configuration. The server certificate is read from CertFile and
+// KeyFile when they are set, or else from the bootstrap secrets, and the CA client
+// certificates are verified against from ClientCAFile or else the bootstrap secrets. Both
+// are checked for renewal every ReloadInterval.
 type TLS struct {
-	Enabled      bool   `mapstructure:"enabled"`
-	CertFile     string `mapstructure:"cert_file"`
-	KeyFile      string `mapstructure:"key_file"`
-	ClientCAFile string `mapstructure:"client_ca_file"`
-	ClientAuth   string `mapstructure:"client_auth"`
+	Enabled bool `mapstructure:"enabled"`
+	// CertDir is a directory holding a certificate as cert-manager issues it, in tls.crt,
+	// tls.key and ca.crt: a mounted kubernetes.io/tls Secret or a csi.cert-manager.io
+	// volume. It stands in for the cert_file, key_file and client_ca_file that are not set.
+	CertDir        string        `mapstructure:"cert_dir"`
+	CertFile       string        `mapstructure:"cert_file"`
+	KeyFile        string        `mapstructure:"key_file"`
+	ClientCAFile   string        `mapstructure:"client_ca_file"`
+	ClientAuth     string        `mapstructure:"client_auth"`
+	ReloadInterval time.Duration `mapstructure:"reload_interval" validate:"gte=0"`
+	// MinVersion is the oldest protocol version accepted, "1.2" or "1.3".
+	MinVersion string `mapstructure:"min_version"`
+	// CipherSuites limits the TLS 1.2 cipher suites to those named, by their crypto/tls
+	// names; empty keeps Go's defaults. TLS 1.3 suites are not configurable.
+	CipherSuites []string `mapstructure:"cipher_suites"`
+}
+
+// Files of a certificate issued by cert-manager.
+const (
+	certDirCertFile = "tls.crt"
+	certDirKeyFile  = "tls.key"
+	certDirCAFile   = "ca.crt"
+)
+
+// applyCertDir sets the file paths that are not set to the files of CertDir.
+func (t *TLS) applyCertDir() {
+	if t.CertDir == "" {
+		return
+	}
+	if t.CertFile == "" && t.KeyFile == "" {
+		t.CertFile = filepath.Join(t.CertDir, certDirCertFile)
+		t.KeyFile = filepath.Join(t.CertDir, certDirKeyFile)
+	}
+	if t.ClientCAFile == "" {
+		t.ClientCAFile = filepath.Join(t.CertDir, certDirCAFile)
+	}
+}
+
+// fileSecrets returns the BootstrapSecrets fields the TLS files replace, which are not
+// read from the bootstrap secret provider.
+func (t TLS) fileSecrets() []string {
+	var fields []string
+	if t.CertFile != "" {
+		fields = append(fields, "TLSServerCert", "TLSServerKey")
+	}
+	if t.ClientCAFile != "" {
+		fields = append(fields, "SpoungeCA")
+	}
+	return fields
 }
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// StartupConfig holds how long the service waits for its dependencies at startup. When
+// fetching the bootstrap secrets or connecting to the database fails, it tries again
+// with exponential backoff and full jitter until Timeout has passed since the first
+// attempt, reporting NOT_SERVING health in the meantime. Zero Timeout tries once.
+type StartupConfig struct {
+	Timeout        time.Duration `mapstructure:"timeout" validate:"gte=0"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff" validate:"gte=0"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff" validate:"gte=0"`
+}
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// TelemetryConfig holds the configuration for exporting telemetry.
+type TelemetryConfig struct {
+	ServiceName string        `mapstructure:"service_name"`
+	Environment string        `mapstructure:"environment"`
+	Tracing     TracingConfig `mapstructure:"tracing"`
+}
+
+// TracingConfig holds the configuration for exporting spans over OTLP. Protocol is grpc
+// or http; Endpoint is host:port. SampleRatio is the share of new traces recorded;
+// requests that arrive in a sampled trace are always recorded.
+type TracingConfig struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	Endpoint    string            `mapstructure:"endpoint" validate:"required_if=Enabled true"`
+	Protocol    string            `mapstructure:"protocol" validate:"omitempty,oneof=grpc http"`
+	Insecure    bool              `mapstructure:"insecure"`
+	Headers     map[string]string `mapstructure:"headers" sensitive:"true"`
+	SampleRatio float64           `mapstructure:"sample_ratio" validate:"gte=0,lte=1"`
+	Timeout     time.Duration     `mapstructure:"timeout" validate:"gte=0"`
+}
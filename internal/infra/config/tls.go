@@ -0,0 +1,127 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Client auth modes of a TLS listener, named after the crypto/tls constants.
+const (
+	ClientAuthNone             = "NoClientCert"
+	ClientAuthRequest          = "RequestClientCert"
+	ClientAuthRequireAny       = "RequireAnyClientCert"
+	ClientAuthVerifyIfGiven    = "VerifyClientCertIfGiven"
+	ClientAuthRequireAndVerify = "RequireAndVerifyClientCert"
+)
+
+// Defaults of the TLS policy.
+const (
+	DefaultTLSMinVersion   = "1.2"
+	DefaultAdminClientAuth = ClientAuthRequireAndVerify
+)
+
+var clientAuthModes = map[string]tls.ClientAuthType{
+	ClientAuthNone:             tls.NoClientCert,
+	ClientAuthRequest:          tls.RequestClientCert,
+	ClientAuthRequireAny:       tls.RequireAnyClientCert,
+	ClientAuthVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+	ClientAuthRequireAndVerify: tls.RequireAndVerifyClientCert,
+}
+
+// ParseClientAuth returns the crypto/tls client auth type of mode. An empty mode is
+// NoClientCert.
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	if mode == "" {
+		return tls.NoClientCert, nil
+	}
+	clientAuth, ok := clientAuthModes[mode]
+	if !ok {
+		return 0, fmt.Errorf("unsupported client_auth %q: expected one of %s, %s, %s, %s or %s",
+			mode, ClientAuthNone, ClientAuthRequest, ClientAuthRequireAny, ClientAuthVerifyIfGiven, ClientAuthRequireAndVerify)
+	}
+	return clientAuth, nil
+}
+
+// TLSMinVersion returns the crypto/tls version of MinVersion, TLS 1.2 when it is unset.
+func (t TLS) TLSMinVersion() (uint16, error) {
+	switch t.MinVersion {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.0", "1.1":
+		return 0, fmt.Errorf("min_version %s is not supported: TLS 1.0 and 1.1 are deprecated, use 1.2 or 1.3", t.MinVersion)
+	}
+	return 0, fmt.Errorf("unsupported min_version %q: expected 1.2 or 1.3", t.MinVersion)
+}
+
+// TLSCipherSuites returns the IDs of CipherSuites, nil when it is empty, which keeps
+// the crypto/tls defaults. It rejects names crypto/tls does not know, insecure suites,
+// TLS 1.3 suites, a list for a min_version of 1.3, which would never be used, and a list
+// without an ECDHE AEAD suite, which HTTP/2, and so gRPC, requires.
+func (t TLS) TLSCipherSuites() ([]uint16, error) {
+	if len(t.CipherSuites) == 0 {
+		return nil, nil
+	}
+	if t.MinVersion == "1.3" {
+		return nil, fmt.Errorf("cipher_suites only apply to TLS 1.2, and min_version is 1.3: remove cipher_suites or lower min_version")
+	}
+
+	ids := make([]uint16, 0, len(t.CipherSuites))
+	http2Capable := false
+	for _, name := range t.CipherSuites {
+		if suite := findCipherSuite(tls.InsecureCipherSuites(), name); suite != nil {
+			return nil, fmt.Errorf("cipher suite %s is insecure and cannot be enabled", name)
+		}
+		suite := findCipherSuite(tls.CipherSuites(), name)
+		if suite == nil {
+			return nil, fmt.Errorf("unknown cipher suite %q: expected a crypto/tls name such as TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", name)
+		}
+		if !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			return nil, fmt.Errorf("cipher suite %s is a TLS 1.3 suite, which Go always enables and cannot be configured", name)
+		}
+		if strings.Contains(name, "_ECDHE_") && (strings.Contains(name, "_GCM_") || strings.Contains(name, "_CHACHA20_")) {
+			http2Capable = true
+		}
+		ids = append(ids, suite.ID)
+	}
+	if !http2Capable {
+		return nil, fmt.Errorf("cipher_suites needs at least one ECDHE suite with AES-GCM or ChaCha20-Poly1305, which HTTP/2 requires for gRPC")
+	}
+	return ids, nil
+}
+
+func findCipherSuite(suites []*tls.CipherSuite, name string) *tls.CipherSuite {
+	for _, suite := range suites {
+		if suite.Name == name {
+			return suite
+		}
+	}
+	return nil
+}
+
+// validateTLSPolicy checks the protocol version, cipher suites and client auth modes of
+// the listeners, explaining what is wrong with them.
+func validateTLSPolicy(server ServerConfig) error {
+	if _, err := server.TLS.TLSMinVersion(); err != nil {
+		return fmt.Errorf("server.tls: %w", err)
+	}
+	if _, err := server.TLS.TLSCipherSuites(); err != nil {
+		return fmt.Errorf("server.tls: %w", err)
+	}
+	if _, err := ParseClientAuth(server.TLS.ClientAuth); err != nil {
+		return fmt.Errorf("server.tls: %w", err)
+	}
+	if !server.Admin.Enabled {
+		return nil
+	}
+	switch server.Admin.ClientAuth {
+	case "", ClientAuthRequireAndVerify:
+	default:
+		return fmt.Errorf("server.admin: client_auth %q is not allowed: every admin connection must present a client certificate the listener verified, so it must be %s",
+			server.Admin.ClientAuth, ClientAuthRequireAndVerify)
+	}
+	return nil
+}
@@ -1,7 +1,31 @@
 package config
 
-// VaultConfig represents the Vault configuration.
+// VaultConfig represents the Vault configuration used when bootstrap secrets are read
+// from Vault. Auth method is token, approle or kubernetes; each secret is the field
+// Field of the KV secret at its secret path in KVMount, and is decrypted with
+// TransitKey when stored as a transit ciphertext.
 type VaultConfig struct {
-	Address string `mapstructure:"address" validate:"required,url"`
-	Token   string `mapstructure:"token"   validate:"required"`
+	Address      string          `mapstructure:"address" validate:"required,url"`
+	Namespace    string          `mapstructure:"namespace"`
+	CACert       string          `mapstructure:"ca_cert"`
+	Token        string          `mapstructure:"token" sensitive:"true"`
+	Auth         VaultAuthConfig `mapstructure:"auth"`
+	KVMount      string          `mapstructure:"kv_mount"`
+	KVVersion    int             `mapstructure:"kv_version" validate:"omitempty,oneof=1 2"`
+	Field        string          `mapstructure:"field"`
+	TransitMount string          `mapstructure:"transit_mount"`
+	TransitKey   string          `mapstructure:"transit_key"`
+}
+
+// VaultAuthConfig selects how the service logs in to Vault. Token auth uses
+// VaultConfig.Token or VAULT_TOKEN; AppRole uses RoleID with SecretID or the content of
+// SecretIDFile; Kubernetes uses Role and the service account token at JWTPath.
+type VaultAuthConfig struct {
+	Method       string `mapstructure:"method" validate:"omitempty,oneof=token approle kubernetes"`
+	Mount        string `mapstructure:"mount"`
+	RoleID       string `mapstructure:"role_id" validate:"required_if=Method approle"`
+	SecretID     string `mapstructure:"secret_id" sensitive:"true"`
+	SecretIDFile string `mapstructure:"secret_id_file"`
+	Role         string `mapstructure:"role" validate:"required_if=Method kubernetes"`
+	JWTPath      string `mapstructure:"jwt_path"`
 }
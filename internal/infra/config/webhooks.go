@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// WebhooksConfig holds the configuration for outbound webhook notifications.
+type WebhooksConfig struct {
+	Enabled        bool                    `mapstructure:"enabled"`
+	Endpoints      []WebhookEndpointConfig `mapstructure:"endpoints"`
+	Timeout        time.Duration           `mapstructure:"timeout"`
+	MaxRetries     int                     `mapstructure:"max_retries"`
+	InitialBackoff time.Duration           `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration           `mapstructure:"max_backoff"`
+	QueueSize      int                     `mapstructure:"queue_size"`
+	Workers        int                     `mapstructure:"workers"`
+	// ShutdownTimeout bounds how long shutdown waits for queued deliveries, retries
+	// included; those still undelivered then are dropped and logged.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+// WebhookEndpointConfig describes one webhook receiver. Events lists the event types it
+// is subscribed to, such as "key.created" or "authz.denied"; empty means all of them.
+type WebhookEndpointConfig struct {
+	Name   string   `mapstructure:"name"`
+	URL    string   `mapstructure:"url"`
+	Secret string   `mapstructure:"secret" sensitive:"true"`
+	Events []string `mapstructure:"events"`
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+const defaultSubscriberBuffer = 256
+
+// Broker is an in-process fan-out of key events. Publishing never blocks: if a
+// subscriber falls behind its buffer, the event is dropped for that subscriber.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[uint64]chan domain.KeyEvent
+	nextID      uint64
+	buffer      int
+	logger      *slog.Logger
+}
+
+// NewBroker creates a new Broker whose subscriptions buffer up to bufferSize events.
+func NewBroker(logger *slog.Logger, bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = defaultSubscriberBuffer
+	}
+	return &Broker{
+		subscribers: make(map[uint64]chan domain.KeyEvent),
+		buffer:      bufferSize,
+		logger:      logger,
+	}
+}
+
+// Publish delivers the event to every current subscriber.
+func (b *Broker) Publish(ctx context.Context, event domain.KeyEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for id, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			b.logger.WarnContext(ctx, "key event subscriber is full, dropping event", "subscriber", id, "type", event.Type, "keyId", event.KeyID)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. The subscription is released when the
+// returned function is called or ctx is done, whichever happens first.
+func (b *Broker) Subscribe(ctx context.Context) (<-chan domain.KeyEvent, func()) {
+	ch := make(chan domain.KeyEvent, b.buffer)
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = ch
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, id)
+			b.mu.Unlock()
+			close(ch)
+			close(done)
+		})
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+
+	return ch, cancel
+}
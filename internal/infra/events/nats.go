@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+)
+
+const defaultNATSPublishTimeout = 5 * time.Second
+
+// natsEvent is the JSON body of a key event published to NATS. Its fields match those of
+// the webhook payload.
+type natsEvent struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	KeyID      string    `json:"key_id"`
+	Namespace  string    `json:"namespace"`
+	Version    int32     `json:"version"`
+}
+
+// NATSSink publishes key events to a JetStream stream, on
+// <subject prefix>.<namespace>.<event type>, with the event ID as the Nats-Msg-Id.
+type NATSSink struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	prefix  string
+	timeout time.Duration
+}
+
+var _ domain.KeyEventSink = (*NATSSink)(nil)
+
+// NewNATSSink connects to the NATS server of cfg, creating its stream when asked to and
+// it is missing.
+func NewNATSSink(ctx context.Context, cfg config.NATSEventsConfig) (*NATSSink, error) {
+	opts := []nats.Option{nats.Name("polykey"), nats.MaxReconnects(-1)}
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, nats.UserCredentials(cfg.CredentialsFile))
+	}
+	conn, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	if _, err := js.Stream(ctx, cfg.Stream); err != nil {
+		if !errors.Is(err, jetstream.ErrStreamNotFound) || !cfg.CreateStream {
+			conn.Close()
+			return nil, fmt.Errorf("failed to find JetStream stream %s: %w", cfg.Stream, err)
+		}
+		if _, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: cfg.Stream, Subjects: []string{cfg.SubjectPrefix + ".>"}}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create JetStream stream %s: %w", cfg.Stream, err)
+		}
+	}
+
+	timeout := cfg.PublishTimeout
+	if timeout <= 0 {
+		timeout = defaultNATSPublishTimeout
+	}
+	return &NATSSink{conn: conn, js: js, prefix: cfg.SubjectPrefix, timeout: timeout}, nil
+}
+
+func (s *NATSSink) Name() string {
+	return "nats"
+}
+
+// Deliver publishes event and waits for the stream to acknowledge it.
+func (s *NATSSink) Deliver(ctx context.Context, event domain.OutboxKeyEvent) error {
+	body, err := json.Marshal(natsEvent{
+		ID:         event.ID,
+		Type:       "key." + string(event.Type),
+		OccurredAt: event.OccurredAt,
+		KeyID:      event.KeyID,
+		Namespace:  event.Namespace,
+		Version:    event.Version,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal key event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if _, err := s.js.Publish(ctx, s.Subject(event.Namespace, event.Type), body, jetstream.WithMsgID(event.ID)); err != nil {
+		return fmt.Errorf("failed to publish key event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// Subject returns the subject the events of eventType in namespace are published on.
+func (s *NATSSink) Subject(namespace string, eventType domain.KeyEventType) string {
+	return s.prefix + "." + subjectToken(namespace) + "." + string(eventType)
+}
+
+// HealthCheck reports whether the connection to NATS is up.
+func (s *NATSSink) HealthCheck(context.Context) error {
+	if status := s.conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("NATS connection is %s", status)
+	}
+	return nil
+}
+
+// Close flushes and closes the connection.
+func (s *NATSSink) Close() {
+	_ = s.conn.Drain()
+}
+
+// subjectToken makes a namespace a single subject token, replacing the characters
+// subjects reserve.
+func subjectToken(namespace string) string {
+	if namespace == "" {
+		return "default"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, namespace)
+}
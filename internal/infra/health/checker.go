@@ -0,0 +1,158 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultProbeTimeout = 2 * time.Second
+
+// Status is the aggregated health of the service.
+type Status int
+
+const (
+	StatusHealthy Status = iota
+	StatusDegraded
+	StatusUnhealthy
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusHealthy:
+		return "healthy"
+	case StatusDegraded:
+		return "degraded"
+	default:
+		return "unhealthy"
+	}
+}
+
+// Component is a single dependency probed by the Checker. A failing critical
+// component makes the service unhealthy; a failing non-critical one degrades it.
+type Component struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+// ComponentStatus is the outcome of probing a single component.
+type ComponentStatus struct {
+	Name     string
+	Critical bool
+	Healthy  bool
+	Error    string
+	Latency  time.Duration
+}
+
+// Report is the outcome of probing every registered component.
+type Report struct {
+	Status     Status
+	Components []ComponentStatus
+	CheckedAt  time.Time
+}
+
+// Checker probes the registered components concurrently and remembers the last report.
+type Checker struct {
+	mu           sync.RWMutex
+	components   []Component
+	probeTimeout time.Duration
+	last         *Report
+
+	// ready is closed once the first report is in.
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// NewChecker creates a new Checker. A non-positive probeTimeout uses the default.
+func NewChecker(probeTimeout time.Duration, components ...Component) *Checker {
+	if probeTimeout <= 0 {
+		probeTimeout = defaultProbeTimeout
+	}
+	return &Checker{components: components, probeTimeout: probeTimeout, ready: make(chan struct{})}
+}
+
+// Register adds a component to be probed.
+func (c *Checker) Register(component Component) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.components = append(c.components, component)
+}
+
+// Check probes every component and returns the aggregated report.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	components := append([]Component(nil), c.components...)
+	c.mu.RUnlock()
+
+	statuses := make([]ComponentStatus, len(components))
+	var wg sync.WaitGroup
+	for i, component := range components {
+		wg.Add(1)
+		go func(i int, component Component) {
+			defer wg.Done()
+			statuses[i] = c.probe(ctx, component)
+		}(i, component)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusHealthy, Components: statuses, CheckedAt: time.Now()}
+	for _, status := range statuses {
+		if status.Healthy {
+			continue
+		}
+		if status.Critical {
+			report.Status = StatusUnhealthy
+			break
+		}
+		report.Status = StatusDegraded
+	}
+
+	c.mu.Lock()
+	c.last = &report
+	c.mu.Unlock()
+	c.readyOnce.Do(func() { close(c.ready) })
+
+	return report
+}
+
+// Last returns the most recent report, if any check has run.
+func (c *Checker) Last() (Report, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.last == nil {
+		return Report{}, false
+	}
+	return *c.last, true
+}
+
+// Wait returns the most recent report, waiting for the first check to finish when none
+// has run yet. Unlike Check it never probes the components, so callers can use it as
+// often as they like while a background loop keeps the report fresh.
+func (c *Checker) Wait(ctx context.Context) (Report, error) {
+	select {
+	case <-c.ready:
+	case <-ctx.Done():
+		return Report{}, ctx.Err()
+	}
+	report, _ := c.Last()
+	return report, nil
+}
+
+func (c *Checker) probe(ctx context.Context, component Component) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, c.probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := component.Check(ctx)
+	status := ComponentStatus{
+		Name:     component.Name,
+		Critical: component.Critical,
+		Healthy:  err == nil,
+		Latency:  time.Since(start),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
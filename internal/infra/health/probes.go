@@ -0,0 +1,143 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Phases of the service, as the readiness probe reports them.
+const (
+	PhaseStarting = "starting"
+	PhaseServing  = "serving"
+	PhaseDraining = "draining"
+)
+
+// ProbeServer serves /healthz and /readyz over plain HTTP. /healthz answers 200 as long
+// as the process serves at all. /readyz answers 200 once the service is serving and no
+// critical dependency is failing, with the status and components of the checker's last
+// report; it answers 503 while dependencies are being initialized, while a critical
+// dependency is failing and while the service drains.
+type ProbeServer struct {
+	httpServer *http.Server
+	lis        net.Listener
+	logger     *slog.Logger
+
+	mu      sync.RWMutex
+	phase   string
+	checker *Checker
+}
+
+// probeResponse is the body of both probes.
+type probeResponse struct {
+	Status     string           `json:"status"`
+	Phase      string           `json:"phase"`
+	CheckedAt  *time.Time       `json:"checked_at,omitempty"`
+	Components []probeComponent `json:"components,omitempty"`
+}
+
+type probeComponent struct {
+	Name     string `json:"name"`
+	Critical bool   `json:"critical"`
+	Healthy  bool   `json:"healthy"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NewProbeServer listens on port, in the starting phase, and returns the port.
+func NewProbeServer(port int, logger *slog.Logger) (*ProbeServer, int, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to listen: %w", err)
+	}
+	s := &ProbeServer{lis: lis, logger: logger, phase: PhaseStarting}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /readyz", s.readyz)
+	s.httpServer = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	return s, lis.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Start serves the probes in the background.
+func (s *ProbeServer) Start() {
+	s.logger.Info("probe server listening", "address", s.lis.Addr().String())
+	go func() {
+		if err := s.httpServer.Serve(s.lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Warn("probe server stopped", "error", err)
+		}
+	}()
+}
+
+// SetServing moves to the serving phase, in which readiness follows the reports of
+// checker. A nil checker makes the service ready as soon as it serves.
+func (s *ProbeServer) SetServing(checker *Checker) {
+	s.mu.Lock()
+	s.phase, s.checker = PhaseServing, checker
+	s.mu.Unlock()
+}
+
+// SetDraining moves to the draining phase, in which the service is not ready.
+func (s *ProbeServer) SetDraining() {
+	s.mu.Lock()
+	s.phase = PhaseDraining
+	s.mu.Unlock()
+}
+
+// Stop shuts the probe server down, waiting until ctx is done for open requests.
+func (s *ProbeServer) Stop(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *ProbeServer) healthz(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	phase := s.phase
+	s.mu.RUnlock()
+	writeProbe(w, http.StatusOK, probeResponse{Status: "alive", Phase: phase})
+}
+
+func (s *ProbeServer) readyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	phase, checker := s.phase, s.checker
+	s.mu.RUnlock()
+
+	if phase != PhaseServing {
+		writeProbe(w, http.StatusServiceUnavailable, probeResponse{Status: StatusUnhealthy.String(), Phase: phase})
+		return
+	}
+	if checker == nil {
+		writeProbe(w, http.StatusOK, probeResponse{Status: StatusHealthy.String(), Phase: phase})
+		return
+	}
+	// The server's health loop keeps the report fresh; a probe only checks itself
+	// before the first report.
+	report, ok := checker.Last()
+	if !ok {
+		report = checker.Check(r.Context())
+	}
+	resp := probeResponse{Status: report.Status.String(), Phase: phase, CheckedAt: &report.CheckedAt}
+	for _, component := range report.Components {
+		resp.Components = append(resp.Components, probeComponent{
+			Name:     component.Name,
+			Critical: component.Critical,
+			Healthy:  component.Healthy,
+			Error:    component.Error,
+		})
+	}
+	code := http.StatusOK
+	if report.Status == StatusUnhealthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeProbe(w, code, resp)
+}
+
+func writeProbe(w http.ResponseWriter, code int, resp probeResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+const correlationIDAttr = "correlation_id"
+
+// ContextHandler decorates a slog.Handler so that every record logged with a
+// request context carries that request's correlation ID. Records that already
+// set correlation_id themselves are left untouched.
+type ContextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler wraps h in a ContextHandler.
+func NewContextHandler(h slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: h}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if correlationID := domain.CorrelationIDFromContext(ctx); correlationID != "" && !hasAttr(r, correlationIDAttr) {
+		r.AddAttrs(slog.String(correlationIDAttr, correlationID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func hasAttr(r slog.Record, key string) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ModuleKey is the attribute that names the module a logger belongs to, as in
+// logger.With(logging.ModuleKey, "audit"). Modules can be given their own level.
+const ModuleKey = "module"
+
+// Levels holds the log levels that can be changed while the service runs: a global
+// level and per-module overrides. Changes apply at once to every logger whose handler
+// is a LevelHandler created from it.
+type Levels struct {
+	global   slog.LevelVar
+	baseline slog.Level
+	modules  atomic.Pointer[map[string]slog.Level]
+	mu       sync.Mutex // Serializes updates of the modules and baseline
+}
+
+// NewLevels parses the global level and module overrides, such as "debug" or "warn".
+// The global level is also the baseline ToggleDebug returns to.
+func NewLevels(global string, modules map[string]string) (*Levels, error) {
+	l := &Levels{}
+	level, err := ParseLevel(global)
+	if err != nil {
+		return nil, err
+	}
+	l.global.Set(level)
+	l.baseline = level
+
+	overrides := make(map[string]slog.Level, len(modules))
+	for module, name := range modules {
+		if overrides[module], err = ParseLevel(name); err != nil {
+			return nil, fmt.Errorf("module %s: %w", module, err)
+		}
+	}
+	l.modules.Store(&overrides)
+	return l, nil
+}
+
+// Reconfigure replaces the global level, which also becomes the baseline ToggleDebug
+// returns to, and every module override, dropping those set at runtime. Nothing is
+// changed when a level does not parse.
+func (l *Levels) Reconfigure(global string, modules map[string]string) error {
+	parsed, err := NewLevels(global, modules)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global.Set(parsed.global.Level())
+	l.baseline = parsed.baseline
+	l.modules.Store(parsed.modules.Load())
+	return nil
+}
+
+// ParseLevel parses a level name such as "debug", "INFO" or "warn+2".
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", name)
+	}
+	return level, nil
+}
+
+// Global returns the global level.
+func (l *Levels) Global() slog.Level {
+	return l.global.Level()
+}
+
+// SetGlobal changes the level of every module without an override.
+func (l *Levels) SetGlobal(level slog.Level) {
+	l.global.Set(level)
+}
+
+// ToggleDebug switches the global level to debug or, if it already is debug, back to
+// the configured level, and returns the new level.
+func (l *Levels) ToggleDebug() slog.Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	level := slog.LevelDebug
+	if l.global.Level() == slog.LevelDebug {
+		level = l.baseline
+	}
+	l.global.Set(level)
+	return level
+}
+
+// Modules returns a copy of the module overrides.
+func (l *Levels) Modules() map[string]slog.Level {
+	return maps.Clone(*l.modules.Load())
+}
+
+// SetModule gives module its own level.
+func (l *Levels) SetModule(module string, level slog.Level) {
+	l.updateModules(func(m map[string]slog.Level) { m[module] = level })
+}
+
+// ClearModule makes module follow the global level again.
+func (l *Levels) ClearModule(module string) {
+	l.updateModules(func(m map[string]slog.Level) { delete(m, module) })
+}
+
+func (l *Levels) updateModules(update func(map[string]slog.Level)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	modules := maps.Clone(*l.modules.Load())
+	update(modules)
+	l.modules.Store(&modules)
+}
+
+func (l *Levels) level(module string) slog.Level {
+	if module != "" {
+		if level, ok := (*l.modules.Load())[module]; ok {
+			return level
+		}
+	}
+	return l.global.Level()
+}
+
+// LevelHandler decorates a slog.Handler with the levels of a Levels, choosing the
+// module level by the ModuleKey attribute given to the logger. The wrapped handler
+// should accept every level.
+type LevelHandler struct {
+	slog.Handler
+	levels *Levels
+	module string
+}
+
+// NewLevelHandler wraps h in a LevelHandler.
+func NewLevelHandler(h slog.Handler, levels *Levels) *LevelHandler {
+	return &LevelHandler{Handler: h, levels: levels}
+}
+
+func (h *LevelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.levels.level(h.module)
+}
+
+func (h *LevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	module := h.module
+	for _, a := range attrs {
+		if a.Key == ModuleKey {
+			module = a.Value.String()
+		}
+	}
+	return &LevelHandler{Handler: h.Handler.WithAttrs(attrs), levels: h.levels, module: module}
+}
+
+func (h *LevelHandler) WithGroup(name string) slog.Handler {
+	return &LevelHandler{Handler: h.Handler.WithGroup(name), levels: h.levels, module: h.module}
+}
@@ -0,0 +1,127 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// DefaultRedactedFields are always redacted, whatever else is configured.
+var DefaultRedactedFields = []string{
+	"api_key",
+	"x_api_key",
+	"encrypted_dek",
+	"dek",
+	"authorization",
+	"proxy_authorization",
+	"private_key",
+	"master_key",
+	"client_secret",
+	"password",
+	"access_token",
+	"refresh_token",
+}
+
+var (
+	// pemBlock matches PEM blocks, including one cut off before its END line.
+	pemBlock    = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----(?s:.*?-----END [A-Z0-9 ]+-----|.*)`)
+	bearerToken = regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+)
+
+// RedactingHandler decorates a slog.Handler so that sensitive data never reaches the
+// output. Attributes named after a redacted field are replaced outright, in groups too;
+// in every other string, error and formatted value, including the message, PEM blocks,
+// bearer tokens and "field: value", "field=value" or JSON pairs of redacted fields are
+// masked, which catches requests and errors that carry such data without naming it.
+type RedactingHandler struct {
+	slog.Handler
+	fields map[string]bool
+	inline *regexp.Regexp
+}
+
+// NewRedactingHandler wraps h in a RedactingHandler that redacts DefaultRedactedFields
+// and fields. Field names match case-insensitively, with '-' and '_' alike.
+func NewRedactingHandler(h slog.Handler, fields []string) *RedactingHandler {
+	set := make(map[string]bool)
+	var names []string
+	for _, field := range slices.Concat(DefaultRedactedFields, fields) {
+		name := normalizeField(field)
+		if name == "" || set[name] {
+			continue
+		}
+		set[name] = true
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	// Field names in free text may use '-' or '_'; values end at a quote or separator.
+	pattern := strings.ReplaceAll(strings.Join(names, "|"), "_", "[-_]")
+	return &RedactingHandler{
+		Handler: h,
+		fields:  set,
+		inline:  regexp.MustCompile(`(?i)("?\b(?:` + pattern + `)"?\s*[:=]\s*)("(?:[^"\\]|\\.)*"|[^\s,;&}\]]+)`),
+	}
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	clean := slog.NewRecord(r.Time, r.Level, h.scrub(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, clean)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = h.redactAttr(a)
+	}
+	return &RedactingHandler{Handler: h.Handler.WithAttrs(clean), fields: h.fields, inline: h.inline}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{Handler: h.Handler.WithGroup(name), fields: h.fields, inline: h.inline}
+}
+
+func (h *RedactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	if h.fields[normalizeField(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.scrub(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		clean := make([]slog.Attr, len(group))
+		for i, member := range group {
+			clean[i] = h.redactAttr(member)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(clean...)}
+	case slog.KindAny:
+		// Only formatted values that actually held something sensitive lose their type.
+		formatted := fmt.Sprint(value.Any())
+		if scrubbed := h.scrub(formatted); scrubbed != formatted {
+			return slog.String(a.Key, scrubbed)
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: value}
+}
+
+// scrub masks PEM blocks, bearer tokens and inline values of redacted fields in s.
+func (h *RedactingHandler) scrub(s string) string {
+	if strings.Contains(s, "-----BEGIN ") {
+		s = pemBlock.ReplaceAllString(s, "[REDACTED PEM]")
+	}
+	s = bearerToken.ReplaceAllString(s, `${1}`+redacted)
+	return h.inline.ReplaceAllString(s, `${1}`+redacted)
+}
+
+func normalizeField(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+}
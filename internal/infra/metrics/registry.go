@@ -0,0 +1,156 @@
+// Package metrics keeps the service-level figures reported by HealthCheck: request
+// rate, error rate and latency over the last minute, process CPU and memory use, and
+// the number of active keys.
+package metrics
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/debug"
+	rtmetrics "runtime/metrics"
+	"sync"
+	"time"
+)
+
+// window is how far back request rates, error rates and latencies are averaged.
+const window = 60 * time.Second
+
+type bucket struct {
+	second   int64
+	requests int64
+	failures int64
+	latency  time.Duration
+}
+
+// Snapshot is the state of the service at one moment. MemoryUsagePercent is relative to
+// the Go memory limit and zero when none is set; ActiveKeys is -1 when it could not be
+// counted.
+type Snapshot struct {
+	UptimeSince         time.Time
+	RequestsPerSecond   float64
+	ErrorRatePercent    float64
+	AverageResponseTime time.Duration
+	TotalRequests       int64
+	CPUUsagePercent     float64
+	MemoryUsagePercent  float64
+	ActiveKeys          int64
+}
+
+// Registry collects request outcomes and samples the process on demand. It is safe for
+// concurrent use.
+type Registry struct {
+	startedAt  time.Time
+	activeKeys func(context.Context) (int64, error)
+
+	mu      sync.Mutex
+	buckets [int(window / time.Second)]bucket
+	total   int64
+
+	cpuMu      sync.Mutex
+	lastSample time.Time
+	lastCPU    time.Duration
+}
+
+// NewRegistry creates a registry for a process started at startedAt. activeKeys counts
+// the active keys and may be nil.
+func NewRegistry(startedAt time.Time, activeKeys func(context.Context) (int64, error)) *Registry {
+	return &Registry{
+		startedAt:  startedAt,
+		activeKeys: activeKeys,
+		lastSample: startedAt,
+	}
+}
+
+// Observe records one handled request, how long it took and whether it failed.
+func (r *Registry) Observe(duration time.Duration, failed bool) {
+	second := time.Now().Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := &r.buckets[second%int64(len(r.buckets))]
+	if b.second != second {
+		*b = bucket{second: second}
+	}
+	b.requests++
+	b.latency += duration
+	if failed {
+		b.failures++
+	}
+	r.total++
+}
+
+// Snapshot returns the current figures.
+func (r *Registry) Snapshot(ctx context.Context) Snapshot {
+	now := time.Now()
+	snap := Snapshot{UptimeSince: r.startedAt, ActiveKeys: -1}
+
+	var requests, failures int64
+	var latency time.Duration
+	r.mu.Lock()
+	for _, b := range r.buckets {
+		if age := now.Unix() - b.second; age >= 0 && age < int64(len(r.buckets)) {
+			requests += b.requests
+			failures += b.failures
+			latency += b.latency
+		}
+	}
+	snap.TotalRequests = r.total
+	r.mu.Unlock()
+
+	// Early in the process' life there is less than a full window to average over.
+	span := min(now.Sub(r.startedAt), window).Seconds()
+	if span > 0 {
+		snap.RequestsPerSecond = float64(requests) / math.Max(span, 1)
+	}
+	if requests > 0 {
+		snap.ErrorRatePercent = 100 * float64(failures) / float64(requests)
+		snap.AverageResponseTime = latency / time.Duration(requests)
+	}
+
+	snap.CPUUsagePercent = r.cpuPercent(now)
+	snap.MemoryUsagePercent = memoryPercent()
+
+	if r.activeKeys != nil {
+		if count, err := r.activeKeys(ctx); err == nil {
+			snap.ActiveKeys = count
+		}
+	}
+	return snap
+}
+
+// cpuPercent is the share of the machine's CPUs the process used since the previous
+// sample, or since it started for the first one.
+func (r *Registry) cpuPercent(now time.Time) float64 {
+	cpu, ok := processCPUTime()
+	if !ok {
+		return 0
+	}
+	r.cpuMu.Lock()
+	defer r.cpuMu.Unlock()
+	elapsed := now.Sub(r.lastSample)
+	used := cpu - r.lastCPU
+	r.lastSample, r.lastCPU = now, cpu
+	if elapsed <= 0 {
+		return 0
+	}
+	return 100 * used.Seconds() / (elapsed.Seconds() * float64(runtime.NumCPU()))
+}
+
+var memorySamples = []rtmetrics.Sample{
+	{Name: "/memory/classes/total:bytes"},
+	{Name: "/memory/classes/heap/released:bytes"},
+}
+
+// memoryPercent is the memory the Go runtime holds, less what it returned to the
+// operating system, as a share of the memory limit.
+func memoryPercent() float64 {
+	limit := debug.SetMemoryLimit(-1)
+	if limit <= 0 || limit == math.MaxInt64 {
+		return 0
+	}
+	samples := make([]rtmetrics.Sample, len(memorySamples))
+	copy(samples, memorySamples)
+	rtmetrics.Read(samples)
+	held := samples[0].Value.Uint64() - samples[1].Value.Uint64()
+	return 100 * float64(held) / float64(limit)
+}
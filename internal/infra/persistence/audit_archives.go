@@ -0,0 +1,156 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// ListArchivableAuditEvents returns events older than before that have not been archived:
+// first those written before the chain existed, then the chain from just after the
+// archived range. The chain part stops at the first event that is too recent, so that
+// every archive holds a contiguous range of sequences.
+func (r *AuditRepository) ListArchivableAuditEvents(ctx context.Context, before time.Time, limit int) ([]*domain.AuditEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, COALESCE(client_identity, ''), COALESCE(operation, ''), COALESCE(key_id, ''), COALESCE(auth_decision_id, ''),
+		       COALESCE(correlation_id, ''), COALESCE(success, false), COALESCE(error_message, ''), timestamp,
+		       COALESCE(seq, 0), COALESCE(prev_hash, ''), COALESCE(hash, ''),
+		       COALESCE(peer_ip, ''), COALESCE(user_agent, ''), COALESCE(cert_fingerprint, ''), changes
+		FROM audit_events
+		WHERE archive_key IS NULL
+		  AND ((seq IS NULL AND timestamp < $1) OR seq > (SELECT COALESCE(MAX(last_seq), 0) FROM audit_archives))
+		ORDER BY seq NULLS FIRST, timestamp, id
+		LIMIT $2`, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*domain.AuditEvent
+	for rows.Next() {
+		var event domain.AuditEvent
+		if err := rows.Scan(&event.ID, &event.ClientIdentity, &event.Operation, &event.KeyID, &event.AuthDecisionID, &event.CorrelationID,
+			&event.Success, &event.Error, &event.Timestamp, &event.Sequence, &event.PrevHash, &event.Hash,
+			&event.PeerIP, &event.UserAgent, &event.CertFingerprint, &event.Changes); err != nil {
+			return nil, err
+		}
+		if event.Sequence > 0 && !event.Timestamp.Before(before) {
+			break
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+// RecordAuditArchive registers the archive and deletes its events in one transaction.
+// Recording an archive that already exists, as happens when two instances archive the
+// same range, only deletes whatever of its events is left.
+func (r *AuditRepository) RecordAuditArchive(ctx context.Context, archive *domain.AuditArchive, eventIDs []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO audit_archives (object_key, first_seq, last_seq, last_hash, first_timestamp, last_timestamp, event_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (object_key) DO NOTHING`,
+		archive.ObjectKey, archive.FirstSequence, archive.LastSequence, optionalString(archive.LastHash),
+		archive.FirstTimestamp, archive.LastTimestamp, archive.EventCount); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM audit_events WHERE id = ANY($1::text[]::uuid[]) AND archive_key IS NULL`, eventIDs); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListAuditArchives returns the archives holding events logged in [from, to), oldest first.
+func (r *AuditRepository) ListAuditArchives(ctx context.Context, from, to time.Time) ([]*domain.AuditArchive, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT object_key, first_seq, last_seq, COALESCE(last_hash, ''), first_timestamp, last_timestamp, event_count, archived_at, restored_until
+		FROM audit_archives
+		WHERE last_timestamp >= $1 AND first_timestamp < $2
+		ORDER BY first_timestamp, object_key`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var archives []*domain.AuditArchive
+	for rows.Next() {
+		var archive domain.AuditArchive
+		if err := rows.Scan(&archive.ObjectKey, &archive.FirstSequence, &archive.LastSequence, &archive.LastHash, &archive.FirstTimestamp,
+			&archive.LastTimestamp, &archive.EventCount, &archive.ArchivedAt, &archive.RestoredUntil); err != nil {
+			return nil, err
+		}
+		archives = append(archives, &archive)
+	}
+	return archives, rows.Err()
+}
+
+// RestoreAuditEvents inserts the archived events, tagged with the archive they came from,
+// and extends the archive's restore to until. Events that are already present are skipped.
+func (r *AuditRepository) RestoreAuditEvents(ctx context.Context, archive *domain.AuditArchive, events []*domain.AuditEvent, until time.Time) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	batch := &pgx.Batch{}
+	for _, event := range events {
+		var seq *int64
+		if event.Sequence > 0 {
+			seq = &event.Sequence
+		}
+		batch.Queue(`
+			INSERT INTO audit_events (id, client_identity, operation, key_id, auth_decision_id, correlation_id, success, error_message, timestamp, seq, prev_hash, hash,
+			                          peer_ip, user_agent, cert_fingerprint, changes, archive_key)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+			ON CONFLICT DO NOTHING`,
+			event.ID, event.ClientIdentity, event.Operation, event.KeyID, event.AuthDecisionID, event.CorrelationID, event.Success,
+			event.Error, event.Timestamp, seq, optionalString(event.PrevHash), optionalString(event.Hash),
+			optionalString(event.PeerIP), optionalString(event.UserAgent), optionalString(event.CertFingerprint),
+			optionalChanges(event.Changes), archive.ObjectKey)
+	}
+
+	var restored int64
+	results := tx.SendBatch(ctx, batch)
+	for range events {
+		tag, err := results.Exec()
+		if err != nil {
+			_ = results.Close()
+			return 0, err
+		}
+		restored += tag.RowsAffected()
+	}
+	if err := results.Close(); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE audit_archives SET restored_until = GREATEST(COALESCE(restored_until, $2), $2)
+		WHERE object_key = $1`, archive.ObjectKey, until); err != nil {
+		return 0, err
+	}
+	return restored, tx.Commit(ctx)
+}
+
+// PurgeRestoredAuditEvents deletes the events of every restore that ended before now.
+func (r *AuditRepository) PurgeRestoredAuditEvents(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		WITH expired AS (
+			UPDATE audit_archives SET restored_until = NULL
+			WHERE restored_until < $1
+			RETURNING object_key
+		)
+		DELETE FROM audit_events WHERE archive_key IN (SELECT object_key FROM expired)`, now)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,12 +2,24 @@ package persistence
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spounge-ai/polykey/internal/domain"
 )
 
+// genesisHash is the prev_hash of the first event in the audit chain.
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
 type AuditRepository struct {
 	db *pgxpool.Pool
 }
@@ -17,36 +29,67 @@ func NewAuditRepository(db *pgxpool.Pool) (*AuditRepository, error) {
 }
 
 func (r *AuditRepository) CreateAuditEvent(ctx context.Context, event *domain.AuditEvent) error {
-	query := `INSERT INTO audit_events (id, client_identity, operation, key_id, auth_decision_id, success, error_message, timestamp) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err := r.db.Exec(ctx, query, event.ID, event.ClientIdentity, event.Operation, event.KeyID, event.AuthDecisionID, event.Success, event.Error, event.Timestamp)
-	return err
+	return r.CreateAuditEventsBatch(ctx, []*domain.AuditEvent{event})
 }
 
+// CreateAuditEventsBatch appends the events to the audit hash chain. Each event is given
+// the next sequence number, the hash of its predecessor and its own hash; the chain head
+// row is locked for the duration of the transaction, so concurrent writers, including
+// other instances, append one after another.
 func (r *AuditRepository) CreateAuditEventsBatch(ctx context.Context, events []*domain.AuditEvent) error {
 	if len(events) == 0 {
 		return nil
 	}
 
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var seq int64
+	var prevHash string
+	if err := tx.QueryRow(ctx, `SELECT seq, hash FROM audit_chain_head WHERE id = 1 FOR UPDATE`).Scan(&seq, &prevHash); err != nil {
+		return fmt.Errorf("failed to lock audit chain head: %w", err)
+	}
+
 	rows := make([][]interface{}, len(events))
 	for i, event := range events {
+		// Postgres keeps microseconds; hash what will be read back.
+		event.Timestamp = event.Timestamp.UTC().Truncate(time.Microsecond)
+		seq++
+		event.Sequence = seq
+		event.PrevHash = prevHash
+		event.Hash = auditEventHash(prevHash, event)
+		prevHash = event.Hash
+
 		rows[i] = []interface{}{
 			event.ID, event.ClientIdentity, event.Operation, event.KeyID,
-			event.AuthDecisionID, event.Success, event.Error, event.Timestamp,
+			event.AuthDecisionID, event.CorrelationID, event.Success, event.Error, event.Timestamp,
+			event.Sequence, event.PrevHash, event.Hash,
+			optionalString(event.PeerIP), optionalString(event.UserAgent), optionalString(event.CertFingerprint),
+			optionalChanges(event.Changes),
 		}
 	}
 
-	_, err := r.db.CopyFrom(
+	if _, err := tx.CopyFrom(
 		ctx,
 		pgx.Identifier{"audit_events"},
-		[]string{"id", "client_identity", "operation", "key_id", "auth_decision_id", "success", "error_message", "timestamp"},
+		[]string{"id", "client_identity", "operation", "key_id", "auth_decision_id", "correlation_id", "success", "error_message", "timestamp", "seq", "prev_hash", "hash",
+			"peer_ip", "user_agent", "cert_fingerprint", "changes"},
 		pgx.CopyFromRows(rows),
-	)
+	); err != nil {
+		return err
+	}
 
-	return err
+	if _, err := tx.Exec(ctx, `UPDATE audit_chain_head SET seq = $1, hash = $2, updated_at = now() WHERE id = 1`, seq, prevHash); err != nil {
+		return fmt.Errorf("failed to advance audit chain head: %w", err)
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *AuditRepository) GetAuditHistory(ctx context.Context, keyID string, limit int) ([]*domain.AuditEvent, error) {
-	query := `SELECT id, client_identity, operation, key_id, auth_decision_id, success, error_message, timestamp FROM audit_events WHERE key_id = $1 ORDER BY timestamp DESC LIMIT $2`
+	query := `SELECT id, client_identity, operation, key_id, auth_decision_id, COALESCE(correlation_id, ''), success, error_message, timestamp FROM audit_events WHERE key_id = $1 ORDER BY timestamp DESC LIMIT $2`
 	rows, err := r.db.Query(ctx, query, keyID, limit)
 	if err != nil {
 		return nil, err
@@ -56,7 +99,7 @@ func (r *AuditRepository) GetAuditHistory(ctx context.Context, keyID string, lim
 	var events []*domain.AuditEvent
 	for rows.Next() {
 		var event domain.AuditEvent
-		err := rows.Scan(&event.ID, &event.ClientIdentity, &event.Operation, &event.KeyID, &event.AuthDecisionID, &event.Success, &event.Error, &event.Timestamp)
+		err := rows.Scan(&event.ID, &event.ClientIdentity, &event.Operation, &event.KeyID, &event.AuthDecisionID, &event.CorrelationID, &event.Success, &event.Error, &event.Timestamp)
 		if err != nil {
 			return nil, err
 		}
@@ -65,3 +108,191 @@ func (r *AuditRepository) GetAuditHistory(ctx context.Context, keyID string, lim
 
 	return events, nil
 }
+
+// QueryAuditEvents returns the events matching the query, newest first, using keyset
+// pagination on (timestamp, id).
+func (r *AuditRepository) QueryAuditEvents(ctx context.Context, query domain.AuditQuery) ([]*domain.AuditEvent, error) {
+	var afterTimestamp *time.Time
+	var afterID *string
+	if query.After != nil {
+		afterTimestamp, afterID = &query.After.Timestamp, &query.After.ID
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, COALESCE(client_identity, ''), COALESCE(operation, ''), COALESCE(key_id, ''), COALESCE(auth_decision_id, ''),
+		       COALESCE(correlation_id, ''), COALESCE(success, false), COALESCE(error_message, ''), timestamp,
+		       COALESCE(seq, 0), COALESCE(prev_hash, ''), COALESCE(hash, ''),
+		       COALESCE(peer_ip, ''), COALESCE(user_agent, ''), COALESCE(cert_fingerprint, ''), changes
+		FROM audit_events
+		WHERE ($1::text IS NULL OR client_identity = $1)
+		  AND ($2::text IS NULL OR key_id = $2)
+		  AND ($3::text IS NULL OR operation = $3)
+		  AND ($4::boolean IS NULL OR success = $4)
+		  AND ($5::timestamptz IS NULL OR timestamp >= $5)
+		  AND ($6::timestamptz IS NULL OR timestamp < $6)
+		  AND ($7::timestamptz IS NULL OR (timestamp, id) < ($7, $8::uuid))
+		ORDER BY timestamp DESC, id DESC
+		LIMIT $9`,
+		optionalString(query.ClientIdentity), optionalString(query.KeyID), optionalString(query.Operation), query.Success,
+		optionalTime(query.From), optionalTime(query.To), afterTimestamp, afterID, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*domain.AuditEvent
+	for rows.Next() {
+		var event domain.AuditEvent
+		if err := rows.Scan(&event.ID, &event.ClientIdentity, &event.Operation, &event.KeyID, &event.AuthDecisionID, &event.CorrelationID,
+			&event.Success, &event.Error, &event.Timestamp, &event.Sequence, &event.PrevHash, &event.Hash,
+			&event.PeerIP, &event.UserAgent, &event.CertFingerprint, &event.Changes); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// optionalChanges stores events without changes with a NULL rather than a JSON null.
+func optionalChanges(changes []domain.AuditChange) any {
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// VerifyAuditIntegrity walks the audit chain in sequence order and recomputes every hash.
+// A gap in the sequence means events were deleted, a prev_hash that does not match its
+// predecessor or a hash that does not match the row means a row was altered, and a last
+// event behind the chain head means the tail was truncated. The walk starts right after
+// the archived part of the chain and the first stored event must link to the last
+// archived one, so only events that went through archiving may leave the table.
+// Events written before the chain existed carry no sequence and are not checked.
+func (r *AuditRepository) VerifyAuditIntegrity(ctx context.Context) (*domain.AuditIntegrityReport, error) {
+	// One snapshot for head, archives and events, so that concurrent appends and
+	// archiving are not seen as tampering.
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	report := &domain.AuditIntegrityReport{}
+	var headHash string
+	if err := tx.QueryRow(ctx, `SELECT seq, hash FROM audit_chain_head WHERE id = 1`).Scan(&report.HeadSequence, &headHash); err != nil {
+		return nil, fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+
+	lastHash := genesisHash
+	err = tx.QueryRow(ctx, `SELECT last_seq, last_hash FROM audit_archives WHERE last_seq > 0 ORDER BY last_seq DESC LIMIT 1`).Scan(&report.ArchivedSequence, &lastHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to read audit archives: %w", err)
+	}
+	report.LastSequence = report.ArchivedSequence
+
+	rows, err := tx.Query(ctx, `
+		SELECT seq, prev_hash, hash, id, COALESCE(client_identity, ''), COALESCE(operation, ''), COALESCE(key_id, ''), COALESCE(auth_decision_id, ''),
+		       COALESCE(correlation_id, ''), success, COALESCE(error_message, ''), timestamp,
+		       COALESCE(peer_ip, ''), COALESCE(user_agent, ''), COALESCE(cert_fingerprint, ''), changes
+		FROM audit_events
+		WHERE seq > $1 AND seq <= $2
+		ORDER BY seq`, report.ArchivedSequence, report.HeadSequence)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event domain.AuditEvent
+		if err := rows.Scan(&event.Sequence, &event.PrevHash, &event.Hash, &event.ID, &event.ClientIdentity, &event.Operation, &event.KeyID,
+			&event.AuthDecisionID, &event.CorrelationID, &event.Success, &event.Error, &event.Timestamp,
+			&event.PeerIP, &event.UserAgent, &event.CertFingerprint, &event.Changes); err != nil {
+			return nil, err
+		}
+
+		var reason string
+		switch {
+		case event.Sequence != report.LastSequence+1:
+			reason = fmt.Sprintf("events %d to %d are missing", report.LastSequence+1, event.Sequence-1)
+		case event.PrevHash != lastHash:
+			reason = "event does not link to its predecessor"
+		case auditEventHash(event.PrevHash, &event) != event.Hash:
+			reason = "event content does not match its hash"
+		}
+		if reason != "" {
+			report.BrokenAtSequence = event.Sequence
+			report.Reason = reason
+			return report, nil
+		}
+
+		if report.CheckedEvents == 0 {
+			report.FirstSequence = event.Sequence
+		}
+		report.CheckedEvents++
+		report.LastSequence = event.Sequence
+		lastHash = event.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if report.LastSequence != report.HeadSequence || (report.HeadSequence > 0 && lastHash != headHash) {
+		report.BrokenAtSequence = report.LastSequence + 1
+		report.Reason = fmt.Sprintf("chain head is at %d but the last stored event is %d", report.HeadSequence, report.LastSequence)
+		return report, nil
+	}
+
+	report.Valid = true
+	return report, nil
+}
+
+// auditEventHash is the SHA-256 over the previous hash and the event's stored fields, each
+// length-prefixed so that no two different events encode alike. The caller's network
+// details and the changes are only appended when there are any, which keeps the hashes
+// of events written before they were recorded.
+func auditEventHash(prevHash string, event *domain.AuditEvent) string {
+	h := sha256.New()
+	fields := []string{
+		prevHash,
+		strconv.FormatInt(event.Sequence, 10),
+		event.ID,
+		event.ClientIdentity,
+		event.Operation,
+		event.KeyID,
+		event.AuthDecisionID,
+		event.CorrelationID,
+		strconv.FormatBool(event.Success),
+		event.Error,
+		event.Timestamp.UTC().Format(time.RFC3339Nano),
+	}
+	if event.PeerIP != "" || event.UserAgent != "" || event.CertFingerprint != "" || len(event.Changes) > 0 {
+		fields = append(fields, event.PeerIP, event.UserAgent, event.CertFingerprint)
+	}
+	if len(event.Changes) > 0 {
+		changes, _ := json.Marshal(event.Changes)
+		fields = append(fields, string(changes))
+	}
+	for _, field := range fields {
+		writeHashField(h, field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeHashField(h hash.Hash, field string) {
+	_, _ = fmt.Fprintf(h, "%d:", len(field))
+	_, _ = h.Write([]byte(field))
+}
@@ -0,0 +1,62 @@
+package persistence
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/breaker"
+)
+
+// auditRepositoryBreakerName identifies the audit repository breaker in metrics and status.
+const auditRepositoryBreakerName = "audit_repository"
+
+// AuditRepositoryCircuitBreaker adds a circuit breaker to the appends and queries of an
+// AuditRepository, so that a struggling audit table fails audited requests, or the
+// asynchronous logger's batches, fast instead of holding connections. Integrity
+// verification and archiving are long-running background work and pass through.
+type AuditRepositoryCircuitBreaker struct {
+	domain.AuditRepository
+	breaker *breaker.Breaker
+}
+
+// NewAuditRepositoryCircuitBreaker wraps repo with a breaker that opens after
+// maxFailures consecutive failures for resetTimeout.
+func NewAuditRepositoryCircuitBreaker(repo domain.AuditRepository, logger *slog.Logger, maxFailures int, resetTimeout time.Duration) *AuditRepositoryCircuitBreaker {
+	return &AuditRepositoryCircuitBreaker{
+		AuditRepository: repo,
+		breaker:         breaker.New(auditRepositoryBreakerName, logger, maxFailures, resetTimeout),
+	}
+}
+
+// Breaker returns the breaker, for operators to inspect and override.
+func (cb *AuditRepositoryCircuitBreaker) Breaker() *breaker.Breaker {
+	return cb.breaker
+}
+
+func (cb *AuditRepositoryCircuitBreaker) CreateAuditEvent(ctx context.Context, event *domain.AuditEvent) error {
+	_, err := breaker.Execute(ctx, cb.breaker, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, cb.AuditRepository.CreateAuditEvent(ctx, event)
+	})
+	return err
+}
+
+func (cb *AuditRepositoryCircuitBreaker) CreateAuditEventsBatch(ctx context.Context, events []*domain.AuditEvent) error {
+	_, err := breaker.Execute(ctx, cb.breaker, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, cb.AuditRepository.CreateAuditEventsBatch(ctx, events)
+	})
+	return err
+}
+
+func (cb *AuditRepositoryCircuitBreaker) GetAuditHistory(ctx context.Context, keyID string, limit int) ([]*domain.AuditEvent, error) {
+	return breaker.Execute(ctx, cb.breaker, func(ctx context.Context) ([]*domain.AuditEvent, error) {
+		return cb.AuditRepository.GetAuditHistory(ctx, keyID, limit)
+	})
+}
+
+func (cb *AuditRepositoryCircuitBreaker) QueryAuditEvents(ctx context.Context, query domain.AuditQuery) ([]*domain.AuditEvent, error) {
+	return breaker.Execute(ctx, cb.breaker, func(ctx context.Context) ([]*domain.AuditEvent, error) {
+		return cb.AuditRepository.QueryAuditEvents(ctx, query)
+	})
+}
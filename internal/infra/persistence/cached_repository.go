@@ -2,12 +2,15 @@ package persistence
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
 	"github.com/spounge-ai/polykey/pkg/cache"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 )
@@ -67,7 +70,7 @@ func (cr *CachedRepository) onCacheEvict(cacheKey string, key *domain.Key) {
 
 func (cr *CachedRepository) GetKey(ctx context.Context, id domain.KeyID) (*domain.Key, error) {
 	cacheKey := cr.getCacheKey(id, 0)
-	if key, found := cr.cache.Get(ctx, cacheKey); found {
+	if key, found := cr.cachedKey(ctx, cacheKey); found {
 		return key, nil
 	}
 
@@ -82,7 +85,7 @@ func (cr *CachedRepository) GetKey(ctx context.Context, id domain.KeyID) (*domai
 
 func (cr *CachedRepository) GetKeyByVersion(ctx context.Context, id domain.KeyID, version int32) (*domain.Key, error) {
 	cacheKey := cr.getCacheKey(id, version)
-	if key, found := cr.cache.Get(ctx, cacheKey); found {
+	if key, found := cr.cachedKey(ctx, cacheKey); found {
 		return key, nil
 	}
 
@@ -97,7 +100,7 @@ func (cr *CachedRepository) GetKeyByVersion(ctx context.Context, id domain.KeyID
 
 func (cr *CachedRepository) GetKeyMetadata(ctx context.Context, id domain.KeyID) (*pk.KeyMetadata, error) {
 	cacheKey := cr.getCacheKey(id, 0) // 0 for latest version
-	if key, found := cr.cache.Get(ctx, cacheKey); found {
+	if key, found := cr.cachedKey(ctx, cacheKey); found {
 		return key.Metadata, nil
 	}
 	// If not in cache, go to repo. Don't cache the result here to avoid partial objects.
@@ -106,7 +109,7 @@ func (cr *CachedRepository) GetKeyMetadata(ctx context.Context, id domain.KeyID)
 
 func (cr *CachedRepository) GetKeyMetadataByVersion(ctx context.Context, id domain.KeyID, version int32) (*pk.KeyMetadata, error) {
 	cacheKey := cr.getCacheKey(id, version)
-	if key, found := cr.cache.Get(ctx, cacheKey); found {
+	if key, found := cr.cachedKey(ctx, cacheKey); found {
 		return key.Metadata, nil
 	}
 	// If not in cache, go to repo.
@@ -131,10 +134,10 @@ func (cr *CachedRepository) CreateBatchKeys(ctx context.Context, keys []*domain.
 	return err
 }
 
-func (cr *CachedRepository) ListKeys(ctx context.Context, lastCreatedAt *time.Time, limit int) ([]*domain.Key, error) {
+func (cr *CachedRepository) ListKeys(ctx context.Context, filter domain.KeyFilter, page domain.KeyPage, limit int) ([]*domain.Key, error) {
 	// Caching for ListKeys is complex and often not beneficial without proper invalidation strategies.
 	// For now, we bypass the cache for this operation.
-	return cr.repo.ListKeys(ctx, lastCreatedAt, limit)
+	return cr.repo.ListKeys(ctx, filter, page, limit)
 }
 
 func (cr *CachedRepository) UpdateKeyMetadata(ctx context.Context, id domain.KeyID, metadata *pk.KeyMetadata) error {
@@ -145,8 +148,17 @@ func (cr *CachedRepository) UpdateKeyMetadata(ctx context.Context, id domain.Key
 	return err
 }
 
-func (cr *CachedRepository) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte) (*domain.Key, error) {
-	rotatedKey, err := cr.repo.RotateKey(ctx, id, newEncryptedDEK)
+func (cr *CachedRepository) UpdateKeyMetadataIfOwner(ctx context.Context, id domain.KeyID, version int32, owner string, metadata *pk.KeyMetadata) error {
+	err := cr.repo.UpdateKeyMetadataIfOwner(ctx, id, version, owner, metadata)
+	// A conflict means the cached copy the caller read may be stale too.
+	if err == nil || errors.Is(err, app_errors.ErrConflict) {
+		cr.invalidateCache(id)
+	}
+	return err
+}
+
+func (cr *CachedRepository) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte, graceDeadline time.Time) (*domain.Key, error) {
+	rotatedKey, err := cr.repo.RotateKey(ctx, id, newEncryptedDEK, graceDeadline)
 	if err == nil {
 		cr.invalidateCache(id)
 	}
@@ -161,14 +173,26 @@ func (cr *CachedRepository) RevokeKey(ctx context.Context, id domain.KeyID) erro
 	return err
 }
 
+func (cr *CachedRepository) RestoreKey(ctx context.Context, id domain.KeyID, revokedSince time.Time) (*domain.Key, error) {
+	key, err := cr.repo.RestoreKey(ctx, id, revokedSince)
+	if err == nil {
+		cr.invalidateCache(id)
+	}
+	return key, err
+}
+
 func (cr *CachedRepository) GetKeyVersions(ctx context.Context, id domain.KeyID) ([]*domain.Key, error) {
 	// Bypassing cache for simplicity.
 	return cr.repo.GetKeyVersions(ctx, id)
 }
 
+func (cr *CachedRepository) CountKeys(ctx context.Context, namespace string) (int, error) {
+	return cr.repo.CountKeys(ctx, namespace)
+}
+
 func (cr *CachedRepository) Exists(ctx context.Context, id domain.KeyID) (bool, error) {
 	cacheKey := cr.getCacheKey(id, 0)
-	if _, found := cr.cache.Get(ctx, cacheKey); found {
+	if _, found := cr.cachedKey(ctx, cacheKey); found {
 		return true, nil
 	}
 	return cr.repo.Exists(ctx, id)
@@ -196,6 +220,42 @@ func (cr *CachedRepository) UpdateBatchKeyMetadata(ctx context.Context, updates
 	return cr.repo.UpdateBatchKeyMetadata(ctx, updates)
 }
 
+func (cr *CachedRepository) ExpireKeys(ctx context.Context, asOf time.Time, limit int) ([]*domain.Key, error) {
+	keys, err := cr.repo.ExpireKeys(ctx, asOf, limit)
+	for _, key := range keys {
+		cr.invalidateCache(key.ID)
+	}
+	return keys, err
+}
+
+func (cr *CachedRepository) ListExpiringKeys(ctx context.Context, after domain.KeyExpiryCursor, to time.Time, limit int) ([]*domain.Key, error) {
+	return cr.repo.ListExpiringKeys(ctx, after, to, limit)
+}
+
+func (cr *CachedRepository) PruneKeyVersions(ctx context.Context, retention domain.KeyVersionRetention, limit int) ([]*domain.Key, error) {
+	keys, err := cr.repo.PruneKeyVersions(ctx, retention, limit)
+	for _, key := range keys {
+		cr.invalidateCache(key.ID)
+	}
+	return keys, err
+}
+
+// HealthCheck verifies that the cache answers, without reading or writing any entry: it
+// fails when the cache stays locked, as by a stuck caller, until ctx is done.
+func (cr *CachedRepository) HealthCheck(ctx context.Context) error {
+	answered := make(chan struct{})
+	go func() {
+		cr.cache.Count()
+		close(answered)
+	}()
+	select {
+	case <-answered:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("cache did not answer: %w", ctx.Err())
+	}
+}
+
 // Helper methods
 
 func (cr *CachedRepository) getCacheKey(id domain.KeyID, version int32) string {
@@ -212,6 +272,17 @@ func (cr *CachedRepository) getCacheKey(id domain.KeyID, version int32) string {
 	return sb.String()
 }
 
+// cachedKey looks a key up in the cache. Entries are shared by all namespaces, so a key
+// of a namespace the context cannot see is treated as a miss and left to the repository,
+// which will not find it either.
+func (cr *CachedRepository) cachedKey(ctx context.Context, cacheKey string) (*domain.Key, bool) {
+	key, found := cr.cache.Get(ctx, cacheKey)
+	if !found || !domain.NamespaceVisible(ctx, key.Namespace) {
+		return nil, false
+	}
+	return key, true
+}
+
 func (cr *CachedRepository) storeInCache(cacheKey string, k *domain.Key) {
 	cr.cache.Set(context.Background(), cacheKey, k, 0)
 
@@ -238,4 +309,16 @@ func (cr *CachedRepository) invalidateCache(id domain.KeyID) {
 	for cacheKey := range keysToDel {
 		cr.cache.Delete(context.Background(), cacheKey)
 	}
-}
\ No newline at end of file
+}
+// FlushCache drops every cached key, so that the next reads go to the repository.
+// FlushKey drops the cached versions of one key.
+func (cr *CachedRepository) FlushKey(_ context.Context, id domain.KeyID) {
+	cr.invalidateCache(id)
+}
+
+func (cr *CachedRepository) FlushCache(ctx context.Context) {
+	cr.cacheIndexMux.Lock()
+	cr.cache.Clear(ctx)
+	cr.cacheIndex = make(map[string]map[string]struct{}, cacheIndexCapacity)
+	cr.cacheIndexMux.Unlock()
+}
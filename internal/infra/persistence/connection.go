@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"log/slog"
 	"strings"
 	
 
@@ -12,7 +13,30 @@ import (
 )
 
 // NewSecureConnectionPool creates a new database connection pool with enhanced security settings.
-func NewSecureConnectionPool(ctx context.Context, dbConfig config.NeonDBConfig, serverConfig config.ServerConfig, persistenceConfig config.PersistenceConfig) (*pgxpool.Pool, error) {
+func NewSecureConnectionPool(ctx context.Context, logger *slog.Logger, dbConfig config.NeonDBConfig, serverConfig config.ServerConfig, persistenceConfig config.PersistenceConfig) (*pgxpool.Pool, error) {
+	pool, _, err := NewRotatableConnectionPool(ctx, logger, dbConfig, serverConfig, persistenceConfig)
+	return pool, err
+}
+
+// NewLazyConnectionPool creates a pool that connects only when a connection is first
+// used, so that a database that is down, such as a standby in another region, does not
+// hold up startup.
+func NewLazyConnectionPool(dbConfig config.NeonDBConfig, serverConfig config.ServerConfig, persistenceConfig config.PersistenceConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := newPoolConfig(dbConfig, serverConfig, persistenceConfig)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.MinConns = 0
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	return pool, nil
+}
+
+// newPoolConfig parses the database URL into a pool config with the security and NeonDB
+// settings applied.
+func newPoolConfig(dbConfig config.NeonDBConfig, serverConfig config.ServerConfig, persistenceConfig config.PersistenceConfig) (*pgxpool.Config, error) {
 	poolConfig, err := pgxpool.ParseConfig(dbConfig.URL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse db config: %w", err)
@@ -48,12 +72,31 @@ func NewSecureConnectionPool(ctx context.Context, dbConfig config.NeonDBConfig,
 		}
 	}
 
-	poolConfig.MaxConns = persistenceConfig.Database.Connection.MaxConns
-	poolConfig.MinConns = persistenceConfig.Database.Connection.MinConns
-	poolConfig.MaxConnIdleTime = persistenceConfig.Database.Connection.MaxConnIdleTime
-	poolConfig.MaxConnLifetime = persistenceConfig.Database.Connection.MaxConnLifetime
-	poolConfig.HealthCheckPeriod = persistenceConfig.Database.Connection.HealthCheckPeriod
+	// Settings left zero keep the pool defaults, or those of the URL's pool_ parameters.
+	conn := persistenceConfig.Database.Connection
+	if conn.MaxConns > 0 {
+		poolConfig.MaxConns = conn.MaxConns
+	}
+	if conn.MinConns > 0 {
+		poolConfig.MinConns = min(conn.MinConns, poolConfig.MaxConns)
+	}
+	if conn.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = conn.MaxConnIdleTime
+	}
+	if conn.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = conn.MaxConnLifetime
+	}
+	if conn.MaxConnLifetimeJitter > 0 {
+		poolConfig.MaxConnLifetimeJitter = conn.MaxConnLifetimeJitter
+	}
+	if conn.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = conn.HealthCheckPeriod
+	}
+
+	return poolConfig, nil
+}
 
+func connectPool(ctx context.Context, poolConfig *pgxpool.Config) (*pgxpool.Pool, error) {
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
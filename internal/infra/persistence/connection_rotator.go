@@ -0,0 +1,83 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+)
+
+// ConnectionRotator points the new connections of a pool at the database URL it was last
+// given, so that database credentials rotate without replacing the pool its users hold.
+type ConnectionRotator struct {
+	pool              *pgxpool.Pool
+	serverConfig      config.ServerConfig
+	persistenceConfig config.PersistenceConfig
+
+	mu     sync.RWMutex
+	target *pgx.ConnConfig // Nil until the first rotation
+}
+
+// NewRotatableConnectionPool creates a pool like NewSecureConnectionPool, along with the
+// ConnectionRotator that rotates its database URL.
+func NewRotatableConnectionPool(ctx context.Context, logger *slog.Logger, dbConfig config.NeonDBConfig, serverConfig config.ServerConfig, persistenceConfig config.PersistenceConfig) (*pgxpool.Pool, *ConnectionRotator, error) {
+	poolConfig, err := newPoolConfig(dbConfig, serverConfig, persistenceConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	var slowQueries *SlowQueryTracer
+	if slowQuery := persistenceConfig.Database.SlowQuery; slowQuery.Enabled {
+		slowQueries = NewSlowQueryTracer(logger, slowQuery.Threshold)
+	}
+	poolConfig.ConnConfig.Tracer = NewPoolTracer(slowQueries)
+
+	r := &ConnectionRotator{serverConfig: serverConfig, persistenceConfig: persistenceConfig}
+	poolConfig.BeforeConnect = r.beforeConnect
+	pool, err := connectPool(ctx, poolConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.pool = pool
+	registerPoolMetrics(pool)
+	return pool, r, nil
+}
+
+// Rotate points new connections at url once a connection to it succeeds, then resets the
+// pool: idle connections are closed at once and connections in use when released.
+func (r *ConnectionRotator) Rotate(ctx context.Context, url string) error {
+	poolConfig, err := newPoolConfig(config.NeonDBConfig{URL: url}, r.serverConfig, r.persistenceConfig)
+	if err != nil {
+		return err
+	}
+	conn, err := pgx.ConnectConfig(ctx, poolConfig.ConnConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect with the rotated database URL: %w", err)
+	}
+	_ = conn.Close(ctx)
+
+	r.mu.Lock()
+	r.target = poolConfig.ConnConfig
+	r.mu.Unlock()
+	r.pool.Reset()
+	return nil
+}
+
+// beforeConnect replaces the connection target and credentials of a new connection with
+// those of the last rotation.
+func (r *ConnectionRotator) beforeConnect(_ context.Context, connConfig *pgx.ConnConfig) error {
+	r.mu.RLock()
+	target := r.target
+	r.mu.RUnlock()
+	if target == nil {
+		return nil
+	}
+
+	connConfig.Host, connConfig.Port, connConfig.Database = target.Host, target.Port, target.Database
+	connConfig.User, connConfig.Password = target.User, target.Password
+	connConfig.TLSConfig, connConfig.Fallbacks = target.TLSConfig, target.Fallbacks
+	return nil
+}
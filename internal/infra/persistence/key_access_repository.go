@@ -0,0 +1,47 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	consts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// KeyAccessRepository writes aggregated key reads into the keys table metadata.
+// It bypasses the key cache on purpose: access statistics are eventually consistent
+// and evicting hot keys on every flush would defeat the cache.
+type KeyAccessRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewKeyAccessRepository(db *pgxpool.Pool) *KeyAccessRepository {
+	return &KeyAccessRepository{db: db}
+}
+
+// RecordKeyAccesses applies every access in a single UPDATE.
+func (r *KeyAccessRepository) RecordKeyAccesses(ctx context.Context, accesses []domain.KeyAccess) error {
+	if len(accesses) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(accesses))
+	hits := make([]int64, len(accesses))
+	seconds := make([]int64, len(accesses))
+	nanos := make([]int32, len(accesses))
+	for i, access := range accesses {
+		ids[i] = access.KeyID.String()
+		hits[i] = access.Count
+		seconds[i] = access.LastAccessedAt.Unix()
+		nanos[i] = int32(access.LastAccessedAt.Nanosecond())
+	}
+
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
+	defer cancel()
+
+	if _, err := r.db.Exec(ctx, consts.Queries[consts.StmtRecordKeyAccesses], ids, hits, seconds, nanos); err != nil {
+		return fmt.Errorf("failed to record key accesses: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+)
+
+// KeyAliasRepository stores the aliases of keys in PostgreSQL.
+type KeyAliasRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewKeyAliasRepository(db *pgxpool.Pool) *KeyAliasRepository {
+	return &KeyAliasRepository{db: db}
+}
+
+func (r *KeyAliasRepository) GetKeyAlias(ctx context.Context, namespace, alias string) (domain.KeyID, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	var id string
+	err := r.db.QueryRow(ctx, `SELECT key_id::text FROM key_aliases WHERE namespace = $1 AND alias = $2`, namespace, alias).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.KeyID{}, fmt.Errorf("%w: no key has the alias %s", app_errors.ErrKeyNotFound, alias)
+	}
+	if err != nil {
+		return domain.KeyID{}, fmt.Errorf("failed to get key alias %s: %w", alias, err)
+	}
+	return domain.KeyIDFromString(id)
+}
+
+func (r *KeyAliasRepository) PutKeyAlias(ctx context.Context, namespace, alias string, id domain.KeyID) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	query := `INSERT INTO key_aliases (namespace, alias, key_id) VALUES ($1, $2, $3)
+		ON CONFLICT (namespace, alias) DO UPDATE SET key_id = EXCLUDED.key_id, updated_at = now()`
+	if _, err := r.db.Exec(ctx, query, namespace, alias, id.String()); err != nil {
+		return fmt.Errorf("failed to store key alias %s: %w", alias, err)
+	}
+	return nil
+}
+
+// WithKeyAliasLock holds a transaction-scoped advisory lock on the alias while fn runs.
+// fn does not run in that transaction; its queries use connections of their own.
+func (r *KeyAliasRepository) WithKeyAliasLock(ctx context.Context, namespace, alias string, fn func(context.Context) error) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended('key_alias:' || $1 || ':' || $2, 0))`, namespace, alias); err != nil {
+		return fmt.Errorf("failed to lock key alias %s: %w", alias, err)
+	}
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
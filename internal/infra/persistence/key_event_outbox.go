@@ -0,0 +1,104 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// PostgresKeyEventOutbox reads the key events a trigger on the keys table records in
+// key_event_outbox for every registered consumer, in the transaction that changed the key.
+type PostgresKeyEventOutbox struct {
+	db       *pgxpool.Pool
+	consumer string
+}
+
+var _ domain.KeyEventOutbox = (*PostgresKeyEventOutbox)(nil)
+
+// NewPostgresKeyEventOutbox creates the outbox of consumer.
+func NewPostgresKeyEventOutbox(db *pgxpool.Pool, consumer string) *PostgresKeyEventOutbox {
+	return &PostgresKeyEventOutbox{db: db, consumer: consumer}
+}
+
+func (o *PostgresKeyEventOutbox) Consumer() string {
+	return o.consumer
+}
+
+func (o *PostgresKeyEventOutbox) Register(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	if _, err := o.db.Exec(ctx, `INSERT INTO key_event_consumers (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, o.consumer); err != nil {
+		return fmt.Errorf("failed to register key event consumer %s: %w", o.consumer, err)
+	}
+	return nil
+}
+
+func (o *PostgresKeyEventOutbox) Unregister(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	if _, err := o.db.Exec(ctx, `DELETE FROM key_event_consumers WHERE name = $1`, o.consumer); err != nil {
+		return fmt.Errorf("failed to unregister key event consumer %s: %w", o.consumer, err)
+	}
+	return nil
+}
+
+func (o *PostgresKeyEventOutbox) Pending(ctx context.Context, limit int) ([]domain.OutboxKeyEvent, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	rows, err := o.db.Query(ctx, `
+		SELECT seq, event_id::text, event_type, key_id::text, version, namespace, occurred_at
+		FROM key_event_outbox
+		WHERE consumer = $1
+		ORDER BY seq
+		LIMIT $2`, o.consumer, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the key events of %s: %w", o.consumer, err)
+	}
+	defer rows.Close()
+
+	var events []domain.OutboxKeyEvent
+	for rows.Next() {
+		var event domain.OutboxKeyEvent
+		var eventType string
+		if err := rows.Scan(&event.Seq, &event.ID, &eventType, &event.KeyID, &event.Version, &event.Namespace, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan key event: %w", err)
+		}
+		event.Type = domain.KeyEventType(eventType)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the key events of %s: %w", o.consumer, err)
+	}
+	return events, nil
+}
+
+func (o *PostgresKeyEventOutbox) Delivered(ctx context.Context, seqs []int64) error {
+	if len(seqs) == 0 {
+		return nil
+	}
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	if _, err := o.db.Exec(ctx, `DELETE FROM key_event_outbox WHERE consumer = $1 AND seq = ANY($2)`, o.consumer, seqs); err != nil {
+		return fmt.Errorf("failed to delete the delivered key events of %s: %w", o.consumer, err)
+	}
+	return nil
+}
+
+func (o *PostgresKeyEventOutbox) Backlog(ctx context.Context) (domain.KeyEventBacklog, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	var backlog domain.KeyEventBacklog
+	err := o.db.QueryRow(ctx, `SELECT count(*), min(occurred_at) FROM key_event_outbox WHERE consumer = $1`, o.consumer).
+		Scan(&backlog.Pending, &backlog.Oldest)
+	if err != nil {
+		return backlog, fmt.Errorf("failed to read the key event backlog of %s: %w", o.consumer, err)
+	}
+	return backlog, nil
+}
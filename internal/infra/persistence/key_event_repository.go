@@ -0,0 +1,116 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/clock"
+)
+
+// KeyEventRepository is a decorator that publishes a key event after every
+// successful create, rotate, revoke and expiry, alongside the cache invalidation done
+// by CachedRepository. Reads are passed through untouched.
+type KeyEventRepository struct {
+	domain.KeyRepository
+	publisher domain.KeyEventPublisher
+	clock     clock.Clock
+}
+
+// NewKeyEventRepository creates a KeyEventRepository stamping the events it publishes
+// with the time of clk.
+func NewKeyEventRepository(repo domain.KeyRepository, publisher domain.KeyEventPublisher, clk clock.Clock) *KeyEventRepository {
+	return &KeyEventRepository{KeyRepository: repo, publisher: publisher, clock: clk}
+}
+
+func (r *KeyEventRepository) CreateKey(ctx context.Context, key *domain.Key) error {
+	if err := r.KeyRepository.CreateKey(ctx, key); err != nil {
+		return err
+	}
+	r.publish(ctx, domain.KeyEventCreated, key)
+	return nil
+}
+
+func (r *KeyEventRepository) CreateBatchKeys(ctx context.Context, keys []*domain.Key) error {
+	if err := r.KeyRepository.CreateBatchKeys(ctx, keys); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		r.publish(ctx, domain.KeyEventCreated, key)
+	}
+	return nil
+}
+
+func (r *KeyEventRepository) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte, graceDeadline time.Time) (*domain.Key, error) {
+	rotated, err := r.KeyRepository.RotateKey(ctx, id, newEncryptedDEK, graceDeadline)
+	if err != nil {
+		return nil, err
+	}
+	r.publish(ctx, domain.KeyEventRotated, rotated)
+	return rotated, nil
+}
+
+func (r *KeyEventRepository) RevokeKey(ctx context.Context, id domain.KeyID) error {
+	if err := r.KeyRepository.RevokeKey(ctx, id); err != nil {
+		return err
+	}
+	r.publishRevoked(ctx, id)
+	return nil
+}
+
+func (r *KeyEventRepository) RestoreKey(ctx context.Context, id domain.KeyID, revokedSince time.Time) (*domain.Key, error) {
+	key, err := r.KeyRepository.RestoreKey(ctx, id, revokedSince)
+	if err != nil {
+		return nil, err
+	}
+	r.publish(ctx, domain.KeyEventRestored, key)
+	return key, nil
+}
+
+func (r *KeyEventRepository) RevokeBatchKeys(ctx context.Context, ids []domain.KeyID) error {
+	if err := r.KeyRepository.RevokeBatchKeys(ctx, ids); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		r.publishRevoked(ctx, id)
+	}
+	return nil
+}
+
+func (r *KeyEventRepository) ExpireKeys(ctx context.Context, asOf time.Time, limit int) ([]*domain.Key, error) {
+	keys, err := r.KeyRepository.ExpireKeys(ctx, asOf, limit)
+	for _, key := range keys {
+		// Only rotated versions carry a grace deadline; the latest version of a key never does.
+		eventType := domain.KeyEventExpired
+		if key.GraceExpiresAt != nil {
+			eventType = domain.KeyEventVersionExpired
+		}
+		r.publish(ctx, eventType, key)
+	}
+	return keys, err
+}
+
+// publishRevoked reloads the key so subscribers can filter on its metadata.
+func (r *KeyEventRepository) publishRevoked(ctx context.Context, id domain.KeyID) {
+	key, err := r.KeyRepository.GetKey(ctx, id)
+	if err != nil {
+		key = &domain.Key{ID: id}
+	}
+	r.publish(ctx, domain.KeyEventRevoked, key)
+}
+
+func (r *KeyEventRepository) publish(ctx context.Context, eventType domain.KeyEventType, key *domain.Key) {
+	event := domain.KeyEvent{
+		Type:          eventType,
+		KeyID:         key.ID.String(),
+		Namespace:     keyNamespace(ctx, key),
+		Version:       key.Version,
+		Metadata:      key.Metadata,
+		CorrelationID: domain.CorrelationIDFromContext(ctx),
+		OccurredAt:    r.clock.Now(),
+	}
+	if user, ok := domain.UserFromContext(ctx); ok {
+		event.Actor = user.ID
+	}
+	r.publisher.Publish(ctx, event)
+}
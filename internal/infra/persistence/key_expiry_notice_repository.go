@@ -0,0 +1,60 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// KeyExpiryNoticeRepository keeps the watermark of expiry notices in
+// key_expiry_notice_watermark, shared by every replica.
+type KeyExpiryNoticeRepository struct {
+	db *pgxpool.Pool
+}
+
+var _ domain.KeyExpiryNoticeStore = (*KeyExpiryNoticeRepository)(nil)
+
+func NewKeyExpiryNoticeRepository(db *pgxpool.Pool) *KeyExpiryNoticeRepository {
+	return &KeyExpiryNoticeRepository{db: db}
+}
+
+func (r *KeyExpiryNoticeRepository) GetExpiryNoticeWatermark(ctx context.Context) (domain.KeyExpiryCursor, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	var watermark domain.KeyExpiryCursor
+	var lastKeyID *string
+	err := r.db.QueryRow(ctx, `SELECT expires_at, last_key_id::text FROM key_expiry_notice_watermark`).Scan(&watermark.ExpiresAt, &lastKeyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.KeyExpiryCursor{}, nil
+	}
+	if err != nil {
+		return domain.KeyExpiryCursor{}, fmt.Errorf("failed to read the expiry notice watermark: %w", err)
+	}
+	if lastKeyID != nil {
+		watermark.KeyID = *lastKeyID
+	}
+	return watermark, nil
+}
+
+func (r *KeyExpiryNoticeRepository) SaveExpiryNoticeWatermark(ctx context.Context, watermark domain.KeyExpiryCursor) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO key_expiry_notice_watermark (expires_at, last_key_id)
+		VALUES ($1, $2::uuid)
+		ON CONFLICT (singleton) DO UPDATE SET
+			expires_at = EXCLUDED.expires_at,
+			last_key_id = EXCLUDED.last_key_id,
+			updated_at = now()`,
+		watermark.ExpiresAt, nullableString(watermark.KeyID))
+	if err != nil {
+		return fmt.Errorf("failed to save the expiry notice watermark: %w", err)
+	}
+	return nil
+}
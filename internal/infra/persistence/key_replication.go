@@ -0,0 +1,218 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// OutboxReplicator ships key mutations from a primary database to a standby. A trigger on
+// the primary's keys table records each changed key version in key_outbox for every
+// registered target, in the transaction that changed it; ReplicateBatch copies the
+// version's current row, or its deletion, to the standby and then deletes the entries.
+// Shipping whole rows makes replays harmless, so an entry shipped twice after a failure
+// between the two commits does no damage.
+//
+// A version the standby updated later than the primary did is a conflict: the standby
+// was written on its own, as after a promotion, and its row is kept.
+type OutboxReplicator struct {
+	primary *pgxpool.Pool
+	standby *pgxpool.Pool
+	target  string
+}
+
+var _ domain.KeyReplicator = (*OutboxReplicator)(nil)
+
+// NewOutboxReplicator creates a replicator from primary to standby, recording mutations
+// under target.
+func NewOutboxReplicator(primary, standby *pgxpool.Pool, target string) *OutboxReplicator {
+	return &OutboxReplicator{primary: primary, standby: standby, target: target}
+}
+
+// CheckReplicableKMSKey fails unless the DEKs wrapped with the AWS KMS key kmsKeyARN can
+// be unwrapped in the standby region. Rows are shipped with their DEKs wrapped as the
+// primary wrapped them, so the key must be a multi-region key, whose replica in the
+// standby region shares its key material. An empty ARN means the aws provider is not in
+// use.
+func CheckReplicableKMSKey(kmsKeyARN string) error {
+	if kmsKeyARN == "" {
+		return nil
+	}
+	keyID := kmsKeyARN[strings.LastIndex(kmsKeyARN, "/")+1:]
+	if !strings.HasPrefix(keyID, "mrk-") {
+		return fmt.Errorf("aws kms key %s is not a multi-region key; the standby region could not unwrap the DEKs replicated to it", kmsKeyARN)
+	}
+	return nil
+}
+
+func (r *OutboxReplicator) Target() string {
+	return r.target
+}
+
+func (r *OutboxReplicator) Register(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
+	defer cancel()
+
+	tx, err := r.primary.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, `INSERT INTO replication_targets (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, r.target)
+	if err != nil {
+		return fmt.Errorf("failed to register replication target %s: %w", r.target, err)
+	}
+	if tag.RowsAffected() == 1 {
+		// The versions written from here on are recorded by the trigger, those before by
+		// this seed. Both may record one version; it is then shipped twice.
+		if _, err := tx.Exec(ctx, `INSERT INTO key_outbox (target, key_id, version) SELECT $1, id, version FROM keys ORDER BY created_at, id, version`, r.target); err != nil {
+			return fmt.Errorf("failed to seed the outbox of replication target %s: %w", r.target, err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *OutboxReplicator) Unregister(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
+	defer cancel()
+
+	if _, err := r.primary.Exec(ctx, `DELETE FROM replication_targets WHERE name = $1`, r.target); err != nil {
+		return fmt.Errorf("failed to unregister replication target %s: %w", r.target, err)
+	}
+	return nil
+}
+
+// outboxEntry is a key version to ship, with its row on the primary as JSON, nil when the
+// version no longer exists.
+type outboxEntry struct {
+	seq       int64
+	keyID     string
+	version   int32
+	row       []byte
+	updatedAt *time.Time
+}
+
+func (r *OutboxReplicator) ReplicateBatch(ctx context.Context, limit int) (domain.ReplicationBatch, error) {
+	var batch domain.ReplicationBatch
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
+	defer cancel()
+
+	tx, err := r.primary.Begin(ctx)
+	if err != nil {
+		return batch, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Locking the entries keeps a second replicator, such as a promotion draining the
+	// outbox, from shipping them at the same time.
+	rows, err := tx.Query(ctx, `
+		SELECT o.seq, o.key_id, o.version, row_to_json(k), k.updated_at
+		FROM key_outbox o
+		LEFT JOIN keys k ON k.id = o.key_id AND k.version = o.version
+		WHERE o.target = $1
+		ORDER BY o.seq
+		LIMIT $2
+		FOR UPDATE OF o SKIP LOCKED`, r.target, limit)
+	if err != nil {
+		return batch, fmt.Errorf("failed to read the outbox of %s: %w", r.target, err)
+	}
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (outboxEntry, error) {
+		var entry outboxEntry
+		err := row.Scan(&entry.seq, &entry.keyID, &entry.version, &entry.row, &entry.updatedAt)
+		return entry, err
+	})
+	if err != nil {
+		return batch, fmt.Errorf("failed to read the outbox of %s: %w", r.target, err)
+	}
+	if len(entries) == 0 {
+		return batch, nil
+	}
+
+	conflicts, err := r.apply(ctx, entries)
+	if err != nil {
+		return batch, err
+	}
+
+	seqs := make([]int64, len(entries))
+	for i, entry := range entries {
+		seqs[i] = entry.seq
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM key_outbox WHERE seq = ANY($1)`, seqs); err != nil {
+		return batch, fmt.Errorf("failed to delete shipped outbox entries: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return batch, fmt.Errorf("failed to commit shipped outbox entries: %w", err)
+	}
+	batch.Shipped = len(entries)
+	batch.Conflicts = conflicts
+	return batch, nil
+}
+
+// apply writes the entries to the standby in one transaction, each version once, and
+// returns the versions in conflict.
+func (r *OutboxReplicator) apply(ctx context.Context, entries []outboxEntry) ([]domain.KeyVersionCursor, error) {
+	tx, err := r.standby.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin standby transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var conflicts []domain.KeyVersionCursor
+	applied := make(map[domain.KeyVersionCursor]bool, len(entries))
+	for _, entry := range entries {
+		cursor := domain.KeyVersionCursor{KeyID: entry.keyID, Version: entry.version}
+		if applied[cursor] {
+			continue
+		}
+		applied[cursor] = true
+
+		if entry.row == nil {
+			if _, err := tx.Exec(ctx, `DELETE FROM keys WHERE id = $1::uuid AND version = $2`, entry.keyID, entry.version); err != nil {
+				return nil, fmt.Errorf("failed to delete key %s version %d on the standby: %w", entry.keyID, entry.version, err)
+			}
+			continue
+		}
+
+		var standbyUpdatedAt time.Time
+		err := tx.QueryRow(ctx, `SELECT updated_at FROM keys WHERE id = $1::uuid AND version = $2 FOR UPDATE`, entry.keyID, entry.version).Scan(&standbyUpdatedAt)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read key %s version %d on the standby: %w", entry.keyID, entry.version, err)
+		case standbyUpdatedAt.After(*entry.updatedAt):
+			conflicts = append(conflicts, cursor)
+			continue
+		}
+		// Copying through the keys row type keeps every column, whatever the schema adds.
+		if _, err := tx.Exec(ctx, `DELETE FROM keys WHERE id = $1::uuid AND version = $2`, entry.keyID, entry.version); err != nil {
+			return nil, fmt.Errorf("failed to replace key %s version %d on the standby: %w", entry.keyID, entry.version, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO keys SELECT * FROM json_populate_record(NULL::keys, $1::json)`, entry.row); err != nil {
+			return nil, fmt.Errorf("failed to replace key %s version %d on the standby: %w", entry.keyID, entry.version, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit standby transaction: %w", err)
+	}
+	return conflicts, nil
+}
+
+func (r *OutboxReplicator) Backlog(ctx context.Context) (domain.ReplicationBacklog, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	var backlog domain.ReplicationBacklog
+	err := r.primary.QueryRow(ctx, `SELECT count(*), min(created_at) FROM key_outbox WHERE target = $1`, r.target).
+		Scan(&backlog.Pending, &backlog.Oldest)
+	if err != nil {
+		return backlog, fmt.Errorf("failed to read the replication backlog of %s: %w", r.target, err)
+	}
+	return backlog, nil
+}
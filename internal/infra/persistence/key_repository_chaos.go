@@ -0,0 +1,207 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/patterns/chaos"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+// ChaosKeyRepository decorates a KeyRepository with injected faults, for tests and
+// development. Every call is delayed and may fail before reaching the repository.
+// Batches also fail item by item: batch reads leave out the failed items, as if they
+// were not found, and batch writes apply the items before the first failed one and then
+// fail, as a store without transactions would.
+type ChaosKeyRepository struct {
+	repo     domain.KeyRepository
+	injector *chaos.Injector
+}
+
+var _ domain.KeyRepository = (*ChaosKeyRepository)(nil)
+
+func NewChaosKeyRepository(repo domain.KeyRepository, injector *chaos.Injector) *ChaosKeyRepository {
+	return &ChaosKeyRepository{repo: repo, injector: injector}
+}
+
+// withChaos injects a fault into a call of fn.
+func withChaos[T any](ctx context.Context, r *ChaosKeyRepository, fn func(ctx context.Context) (T, error)) (T, error) {
+	if err := r.injector.Inject(ctx); err != nil {
+		var zero T
+		return zero, err
+	}
+	return fn(ctx)
+}
+
+func (r *ChaosKeyRepository) exec(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := r.injector.Inject(ctx); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
+
+// withoutFailedItems leaves out the items of a batch read that fail.
+func withoutFailedItems[T any](r *ChaosKeyRepository, items []T) []T {
+	kept := items[:0:0]
+	for _, item := range items {
+		if !r.injector.FailItem() {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// writePartially writes the items of a batch up to the first that fails, and then fails.
+func writePartially[T any](ctx context.Context, r *ChaosKeyRepository, items []T, write func(ctx context.Context, items []T) error) error {
+	if err := r.injector.Inject(ctx); err != nil {
+		return err
+	}
+	for n := range items {
+		if !r.injector.FailItem() {
+			continue
+		}
+		if n > 0 {
+			if err := write(ctx, items[:n]); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("%w: batch failed after %d of %d items", chaos.ErrInjected, n, len(items))
+	}
+	return write(ctx, items)
+}
+
+func (r *ChaosKeyRepository) GetKey(ctx context.Context, id domain.KeyID) (*domain.Key, error) {
+	return withChaos(ctx, r, func(ctx context.Context) (*domain.Key, error) {
+		return r.repo.GetKey(ctx, id)
+	})
+}
+
+func (r *ChaosKeyRepository) GetKeyByVersion(ctx context.Context, id domain.KeyID, version int32) (*domain.Key, error) {
+	return withChaos(ctx, r, func(ctx context.Context) (*domain.Key, error) {
+		return r.repo.GetKeyByVersion(ctx, id, version)
+	})
+}
+
+func (r *ChaosKeyRepository) GetKeyMetadata(ctx context.Context, id domain.KeyID) (*pk.KeyMetadata, error) {
+	return withChaos(ctx, r, func(ctx context.Context) (*pk.KeyMetadata, error) {
+		return r.repo.GetKeyMetadata(ctx, id)
+	})
+}
+
+func (r *ChaosKeyRepository) GetKeyMetadataByVersion(ctx context.Context, id domain.KeyID, version int32) (*pk.KeyMetadata, error) {
+	return withChaos(ctx, r, func(ctx context.Context) (*pk.KeyMetadata, error) {
+		return r.repo.GetKeyMetadataByVersion(ctx, id, version)
+	})
+}
+
+func (r *ChaosKeyRepository) CreateKey(ctx context.Context, key *domain.Key) error {
+	return r.exec(ctx, func(ctx context.Context) error {
+		return r.repo.CreateKey(ctx, key)
+	})
+}
+
+func (r *ChaosKeyRepository) CreateBatchKeys(ctx context.Context, keys []*domain.Key) error {
+	return writePartially(ctx, r, keys, r.repo.CreateBatchKeys)
+}
+
+func (r *ChaosKeyRepository) ListKeys(ctx context.Context, filter domain.KeyFilter, page domain.KeyPage, limit int) ([]*domain.Key, error) {
+	return withChaos(ctx, r, func(ctx context.Context) ([]*domain.Key, error) {
+		return r.repo.ListKeys(ctx, filter, page, limit)
+	})
+}
+
+func (r *ChaosKeyRepository) UpdateKeyMetadata(ctx context.Context, id domain.KeyID, metadata *pk.KeyMetadata) error {
+	return r.exec(ctx, func(ctx context.Context) error {
+		return r.repo.UpdateKeyMetadata(ctx, id, metadata)
+	})
+}
+
+func (r *ChaosKeyRepository) UpdateKeyMetadataIfOwner(ctx context.Context, id domain.KeyID, version int32, owner string, metadata *pk.KeyMetadata) error {
+	return r.exec(ctx, func(ctx context.Context) error {
+		return r.repo.UpdateKeyMetadataIfOwner(ctx, id, version, owner, metadata)
+	})
+}
+
+func (r *ChaosKeyRepository) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte, graceDeadline time.Time) (*domain.Key, error) {
+	return withChaos(ctx, r, func(ctx context.Context) (*domain.Key, error) {
+		return r.repo.RotateKey(ctx, id, newEncryptedDEK, graceDeadline)
+	})
+}
+
+func (r *ChaosKeyRepository) RevokeKey(ctx context.Context, id domain.KeyID) error {
+	return r.exec(ctx, func(ctx context.Context) error {
+		return r.repo.RevokeKey(ctx, id)
+	})
+}
+
+func (r *ChaosKeyRepository) RestoreKey(ctx context.Context, id domain.KeyID, revokedSince time.Time) (*domain.Key, error) {
+	return withChaos(ctx, r, func(ctx context.Context) (*domain.Key, error) {
+		return r.repo.RestoreKey(ctx, id, revokedSince)
+	})
+}
+
+func (r *ChaosKeyRepository) GetKeyVersions(ctx context.Context, id domain.KeyID) ([]*domain.Key, error) {
+	return withChaos(ctx, r, func(ctx context.Context) ([]*domain.Key, error) {
+		return r.repo.GetKeyVersions(ctx, id)
+	})
+}
+
+func (r *ChaosKeyRepository) CountKeys(ctx context.Context, namespace string) (int, error) {
+	return withChaos(ctx, r, func(ctx context.Context) (int, error) {
+		return r.repo.CountKeys(ctx, namespace)
+	})
+}
+
+func (r *ChaosKeyRepository) Exists(ctx context.Context, id domain.KeyID) (bool, error) {
+	return withChaos(ctx, r, func(ctx context.Context) (bool, error) {
+		return r.repo.Exists(ctx, id)
+	})
+}
+
+func (r *ChaosKeyRepository) GetBatchKeys(ctx context.Context, ids []domain.KeyID) ([]*domain.Key, error) {
+	keys, err := withChaos(ctx, r, func(ctx context.Context) ([]*domain.Key, error) {
+		return r.repo.GetBatchKeys(ctx, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withoutFailedItems(r, keys), nil
+}
+
+func (r *ChaosKeyRepository) GetBatchKeyMetadata(ctx context.Context, ids []domain.KeyID) ([]*pk.KeyMetadata, error) {
+	metadata, err := withChaos(ctx, r, func(ctx context.Context) ([]*pk.KeyMetadata, error) {
+		return r.repo.GetBatchKeyMetadata(ctx, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withoutFailedItems(r, metadata), nil
+}
+
+func (r *ChaosKeyRepository) RevokeBatchKeys(ctx context.Context, ids []domain.KeyID) error {
+	return writePartially(ctx, r, ids, r.repo.RevokeBatchKeys)
+}
+
+func (r *ChaosKeyRepository) UpdateBatchKeyMetadata(ctx context.Context, updates []*domain.Key) error {
+	return writePartially(ctx, r, updates, r.repo.UpdateBatchKeyMetadata)
+}
+
+func (r *ChaosKeyRepository) ExpireKeys(ctx context.Context, asOf time.Time, limit int) ([]*domain.Key, error) {
+	return withChaos(ctx, r, func(ctx context.Context) ([]*domain.Key, error) {
+		return r.repo.ExpireKeys(ctx, asOf, limit)
+	})
+}
+
+func (r *ChaosKeyRepository) ListExpiringKeys(ctx context.Context, after domain.KeyExpiryCursor, to time.Time, limit int) ([]*domain.Key, error) {
+	return withChaos(ctx, r, func(ctx context.Context) ([]*domain.Key, error) {
+		return r.repo.ListExpiringKeys(ctx, after, to, limit)
+	})
+}
+
+func (r *ChaosKeyRepository) PruneKeyVersions(ctx context.Context, retention domain.KeyVersionRetention, limit int) ([]*domain.Key, error) {
+	return withChaos(ctx, r, func(ctx context.Context) ([]*domain.Key, error) {
+		return r.repo.PruneKeyVersions(ctx, retention, limit)
+	})
+}
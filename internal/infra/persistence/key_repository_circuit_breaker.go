@@ -2,171 +2,194 @@ package persistence
 
 import (
 	"context"
+	"log/slog"
 	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
-	"github.com/spounge-ai/polykey/pkg/patterns/circuitbreaker"
+	"github.com/spounge-ai/polykey/internal/infra/breaker"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 )
 
-// KeyRepositoryCircuitBreaker adds a circuit breaker to a KeyRepository.
-// It uses multiple type-safe breakers to avoid runtime type assertions.
+// Names of the key repository breakers in metrics and status.
+const (
+	keyRepositoryReadsBreakerName  = "key_repository_reads"
+	keyRepositoryWritesBreakerName = "key_repository_writes"
+	keyRepositoryBatchBreakerName  = "key_repository_batch"
+)
+
+// KeyRepositoryCircuitBreaker adds circuit breakers to a KeyRepository, one per class of
+// operation: single-key reads, writes, including rotation and the lifecycle sweeps, and
+// batch operations. A failing write path, such as rotations timing out on lock
+// contention, then cannot stop reads from being served.
 type KeyRepositoryCircuitBreaker struct {
-	repo        domain.KeyRepository
-	voidBreaker *circuitbreaker.Breaker[any] // Single breaker for all methods
+	repo   domain.KeyRepository
+	reads  *breaker.Breaker
+	writes *breaker.Breaker
+	batch  *breaker.Breaker
 }
 
-// NewKeyRepositoryCircuitBreaker creates a new KeyRepository with a circuit breaker.
-func NewKeyRepositoryCircuitBreaker(repo domain.KeyRepository, maxFailures int, resetTimeout time.Duration) domain.KeyRepository {
-	opts := []circuitbreaker.Option[any]{
-		circuitbreaker.WithResetTimeout[any](resetTimeout),
-	}
+var _ domain.KeyRepository = (*KeyRepositoryCircuitBreaker)(nil)
 
+// NewKeyRepositoryCircuitBreaker creates a new KeyRepository with circuit breakers that
+// each open after maxFailures consecutive failures for resetTimeout. State changes are
+// logged and exported as metrics.
+func NewKeyRepositoryCircuitBreaker(repo domain.KeyRepository, logger *slog.Logger, maxFailures int, resetTimeout time.Duration) *KeyRepositoryCircuitBreaker {
 	return &KeyRepositoryCircuitBreaker{
-		repo:        repo,
-		voidBreaker: circuitbreaker.New(maxFailures, opts...),
+		repo:   repo,
+		reads:  breaker.New(keyRepositoryReadsBreakerName, logger, maxFailures, resetTimeout),
+		writes: breaker.New(keyRepositoryWritesBreakerName, logger, maxFailures, resetTimeout),
+		batch:  breaker.New(keyRepositoryBatchBreakerName, logger, maxFailures, resetTimeout),
+	}
+}
+
+// Breakers returns the reads, writes and batch breakers, for operators to inspect and
+// override.
+func (cb *KeyRepositoryCircuitBreaker) Breakers() []*breaker.Breaker {
+	return []*breaker.Breaker{cb.reads, cb.writes, cb.batch}
+}
+
+// SetLimits changes the failures that open each breaker and how long it stays open.
+func (cb *KeyRepositoryCircuitBreaker) SetLimits(maxFailures int, resetTimeout time.Duration) {
+	for _, b := range cb.Breakers() {
+		b.SetLimits(maxFailures, resetTimeout)
 	}
 }
 
 func (cb *KeyRepositoryCircuitBreaker) GetKey(ctx context.Context, id domain.KeyID) (*domain.Key, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
+	return breaker.Execute(ctx, cb.reads, func(ctx context.Context) (*domain.Key, error) {
 		return cb.repo.GetKey(ctx, id)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return result.(*domain.Key), nil
 }
 
 func (cb *KeyRepositoryCircuitBreaker) GetKeyByVersion(ctx context.Context, id domain.KeyID, version int32) (*domain.Key, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
+	return breaker.Execute(ctx, cb.reads, func(ctx context.Context) (*domain.Key, error) {
 		return cb.repo.GetKeyByVersion(ctx, id, version)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return result.(*domain.Key), nil
 }
 
 func (cb *KeyRepositoryCircuitBreaker) GetKeyMetadata(ctx context.Context, id domain.KeyID) (*pk.KeyMetadata, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
+	return breaker.Execute(ctx, cb.reads, func(ctx context.Context) (*pk.KeyMetadata, error) {
 		return cb.repo.GetKeyMetadata(ctx, id)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return result.(*pk.KeyMetadata), nil
 }
 
 func (cb *KeyRepositoryCircuitBreaker) GetKeyMetadataByVersion(ctx context.Context, id domain.KeyID, version int32) (*pk.KeyMetadata, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
+	return breaker.Execute(ctx, cb.reads, func(ctx context.Context) (*pk.KeyMetadata, error) {
 		return cb.repo.GetKeyMetadataByVersion(ctx, id, version)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return result.(*pk.KeyMetadata), nil
 }
 
 func (cb *KeyRepositoryCircuitBreaker) CreateKey(ctx context.Context, key *domain.Key) error {
-	_, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
-		return nil, cb.repo.CreateKey(ctx, key)
+	_, err := breaker.Execute(ctx, cb.writes, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, cb.repo.CreateKey(ctx, key)
 	})
 	return err
 }
 
 func (cb *KeyRepositoryCircuitBreaker) CreateBatchKeys(ctx context.Context, keys []*domain.Key) error {
-	_, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
-		return nil, cb.repo.CreateBatchKeys(ctx, keys)
+	_, err := breaker.Execute(ctx, cb.batch, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, cb.repo.CreateBatchKeys(ctx, keys)
 	})
 	return err
 }
 
-func (cb *KeyRepositoryCircuitBreaker) ListKeys(ctx context.Context, lastCreatedAt *time.Time, limit int) ([]*domain.Key, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
-		return cb.repo.ListKeys(ctx, lastCreatedAt, limit)
+func (cb *KeyRepositoryCircuitBreaker) ListKeys(ctx context.Context, filter domain.KeyFilter, page domain.KeyPage, limit int) ([]*domain.Key, error) {
+	return breaker.Execute(ctx, cb.reads, func(ctx context.Context) ([]*domain.Key, error) {
+		return cb.repo.ListKeys(ctx, filter, page, limit)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return result.([]*domain.Key), nil
 }
 
 func (cb *KeyRepositoryCircuitBreaker) UpdateKeyMetadata(ctx context.Context, id domain.KeyID, metadata *pk.KeyMetadata) error {
-	_, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
-		return nil, cb.repo.UpdateKeyMetadata(ctx, id, metadata)
+	_, err := breaker.Execute(ctx, cb.writes, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, cb.repo.UpdateKeyMetadata(ctx, id, metadata)
 	})
 	return err
 }
 
-func (cb *KeyRepositoryCircuitBreaker) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte) (*domain.Key, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
-		return cb.repo.RotateKey(ctx, id, newEncryptedDEK)
+func (cb *KeyRepositoryCircuitBreaker) UpdateKeyMetadataIfOwner(ctx context.Context, id domain.KeyID, version int32, owner string, metadata *pk.KeyMetadata) error {
+	_, err := breaker.Execute(ctx, cb.writes, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, cb.repo.UpdateKeyMetadataIfOwner(ctx, id, version, owner, metadata)
+	})
+	return err
+}
+
+func (cb *KeyRepositoryCircuitBreaker) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte, graceDeadline time.Time) (*domain.Key, error) {
+	return breaker.Execute(ctx, cb.writes, func(ctx context.Context) (*domain.Key, error) {
+		return cb.repo.RotateKey(ctx, id, newEncryptedDEK, graceDeadline)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return result.(*domain.Key), nil
 }
 
 func (cb *KeyRepositoryCircuitBreaker) RevokeKey(ctx context.Context, id domain.KeyID) error {
-	_, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
-		return nil, cb.repo.RevokeKey(ctx, id)
+	_, err := breaker.Execute(ctx, cb.writes, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, cb.repo.RevokeKey(ctx, id)
 	})
 	return err
 }
 
+func (cb *KeyRepositoryCircuitBreaker) RestoreKey(ctx context.Context, id domain.KeyID, revokedSince time.Time) (*domain.Key, error) {
+	return breaker.Execute(ctx, cb.writes, func(ctx context.Context) (*domain.Key, error) {
+		return cb.repo.RestoreKey(ctx, id, revokedSince)
+	})
+}
+
 func (cb *KeyRepositoryCircuitBreaker) GetKeyVersions(ctx context.Context, id domain.KeyID) ([]*domain.Key, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
+	return breaker.Execute(ctx, cb.reads, func(ctx context.Context) ([]*domain.Key, error) {
 		return cb.repo.GetKeyVersions(ctx, id)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return result.([]*domain.Key), nil
+}
+
+func (cb *KeyRepositoryCircuitBreaker) CountKeys(ctx context.Context, namespace string) (int, error) {
+	return breaker.Execute(ctx, cb.reads, func(ctx context.Context) (int, error) {
+		return cb.repo.CountKeys(ctx, namespace)
+	})
 }
 
 func (cb *KeyRepositoryCircuitBreaker) Exists(ctx context.Context, id domain.KeyID) (bool, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
+	return breaker.Execute(ctx, cb.reads, func(ctx context.Context) (bool, error) {
 		return cb.repo.Exists(ctx, id)
 	})
-	if err != nil {
-		return false, err
-	}
-	return result.(bool), nil
 }
 
 func (cb *KeyRepositoryCircuitBreaker) GetBatchKeys(ctx context.Context, ids []domain.KeyID) ([]*domain.Key, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
+	return breaker.Execute(ctx, cb.batch, func(ctx context.Context) ([]*domain.Key, error) {
 		return cb.repo.GetBatchKeys(ctx, ids)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return result.([]*domain.Key), nil
 }
 
 func (cb *KeyRepositoryCircuitBreaker) GetBatchKeyMetadata(ctx context.Context, ids []domain.KeyID) ([]*pk.KeyMetadata, error) {
-	result, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
+	return breaker.Execute(ctx, cb.batch, func(ctx context.Context) ([]*pk.KeyMetadata, error) {
 		return cb.repo.GetBatchKeyMetadata(ctx, ids)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return result.([]*pk.KeyMetadata), nil
 }
 
 func (cb *KeyRepositoryCircuitBreaker) RevokeBatchKeys(ctx context.Context, ids []domain.KeyID) error {
-	_, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
-		return nil, cb.repo.RevokeBatchKeys(ctx, ids)
+	_, err := breaker.Execute(ctx, cb.batch, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, cb.repo.RevokeBatchKeys(ctx, ids)
 	})
 	return err
 }
 
 func (cb *KeyRepositoryCircuitBreaker) UpdateBatchKeyMetadata(ctx context.Context, updates []*domain.Key) error {
-	_, err := cb.voidBreaker.Execute(ctx, func(ctx context.Context) (any, error) {
-		return nil, cb.repo.UpdateBatchKeyMetadata(ctx, updates)
+	_, err := breaker.Execute(ctx, cb.batch, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, cb.repo.UpdateBatchKeyMetadata(ctx, updates)
 	})
 	return err
 }
 
+func (cb *KeyRepositoryCircuitBreaker) ExpireKeys(ctx context.Context, asOf time.Time, limit int) ([]*domain.Key, error) {
+	return breaker.Execute(ctx, cb.writes, func(ctx context.Context) ([]*domain.Key, error) {
+		return cb.repo.ExpireKeys(ctx, asOf, limit)
+	})
+}
+
+func (cb *KeyRepositoryCircuitBreaker) ListExpiringKeys(ctx context.Context, after domain.KeyExpiryCursor, to time.Time, limit int) ([]*domain.Key, error) {
+	return breaker.Execute(ctx, cb.reads, func(ctx context.Context) ([]*domain.Key, error) {
+		return cb.repo.ListExpiringKeys(ctx, after, to, limit)
+	})
+}
+
+func (cb *KeyRepositoryCircuitBreaker) PruneKeyVersions(ctx context.Context, retention domain.KeyVersionRetention, limit int) ([]*domain.Key, error) {
+	return breaker.Execute(ctx, cb.writes, func(ctx context.Context) ([]*domain.Key, error) {
+		return cb.repo.PruneKeyVersions(ctx, retention, limit)
+	})
+}
@@ -0,0 +1,98 @@
+package persistence
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var hedgedReads, _ = meter.Int64Counter(
+	"polykey.persistence.hedged_reads",
+	metric.WithDescription("Number of key reads queried a second time because the first query was slow, by operation and by which query answered first."),
+)
+
+// HedgedKeyRepository hedges the key reads GetKey serves: when a query has not answered
+// within delay, the same query is sent again and the first successful answer is used,
+// the other query being cancelled. A slow connection or network path to the database
+// then costs delay instead of its full latency. At most maxInFlight hedges run at once,
+// so that a slow database is not sent twice its load.
+type HedgedKeyRepository struct {
+	domain.KeyRepository
+	delay       time.Duration
+	maxInFlight int64
+	inFlight    atomic.Int64
+}
+
+// NewHedgedKeyRepository wraps repo, hedging its GetKey and GetKeyByVersion queries
+// after delay.
+func NewHedgedKeyRepository(repo domain.KeyRepository, delay time.Duration, maxInFlight int) *HedgedKeyRepository {
+	return &HedgedKeyRepository{KeyRepository: repo, delay: delay, maxInFlight: int64(maxInFlight)}
+}
+
+func (r *HedgedKeyRepository) GetKey(ctx context.Context, id domain.KeyID) (*domain.Key, error) {
+	return hedge(ctx, r, "get_key", func(ctx context.Context) (*domain.Key, error) {
+		return r.KeyRepository.GetKey(ctx, id)
+	})
+}
+
+func (r *HedgedKeyRepository) GetKeyByVersion(ctx context.Context, id domain.KeyID, version int32) (*domain.Key, error) {
+	return hedge(ctx, r, "get_key_by_version", func(ctx context.Context) (*domain.Key, error) {
+		return r.KeyRepository.GetKeyByVersion(ctx, id, version)
+	})
+}
+
+type hedgedResult[T any] struct {
+	value  T
+	err    error
+	hedged bool
+}
+
+// hedge calls fn and, when it has not returned within r.delay and a hedge is available,
+// calls it a second time. It returns the first success or, when both calls fail, the
+// last error.
+func hedge[T any](ctx context.Context, r *HedgedKeyRepository, operation string, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgedResult[T], 2)
+	call := func(hedged bool) {
+		value, err := fn(ctx)
+		results <- hedgedResult[T]{value: value, err: err, hedged: hedged}
+	}
+	go call(false)
+
+	timer := time.NewTimer(r.delay)
+	defer timer.Stop()
+	select {
+	case result := <-results:
+		return result.value, result.err
+	case <-timer.C:
+	}
+	if r.inFlight.Add(1) > r.maxInFlight {
+		r.inFlight.Add(-1)
+		result := <-results
+		return result.value, result.err
+	}
+	go func() {
+		defer r.inFlight.Add(-1)
+		call(true)
+	}()
+
+	result := <-results
+	if result.err != nil {
+		result = <-results
+	}
+	winner := "primary"
+	if result.hedged {
+		winner = "hedge"
+	}
+	hedgedReads.Add(context.WithoutCancel(ctx), 1, metric.WithAttributes(
+		attribute.String("operation", operation),
+		attribute.String("winner", winner),
+	))
+	return result.value, result.err
+}
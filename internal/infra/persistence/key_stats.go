@@ -0,0 +1,38 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	consts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// ActiveKeyCounter returns a function counting the keys, across all namespaces, that
+// have an active version. The count is reused for ttl, so that frequent health checks
+// do not each scan the keys table.
+func ActiveKeyCounter(pool *pgxpool.Pool, ttl time.Duration) func(context.Context) (int64, error) {
+	var (
+		mu        sync.Mutex
+		count     int64
+		countedAt time.Time
+	)
+	return func(ctx context.Context) (int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !countedAt.IsZero() && time.Since(countedAt) < ttl {
+			return count, nil
+		}
+
+		ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+		defer cancel()
+		if err := pool.QueryRow(ctx, consts.Queries[consts.StmtCountActiveKeys], string(domain.KeyStatusActive)).Scan(&count); err != nil {
+			return 0, fmt.Errorf("failed to count active keys: %w", err)
+		}
+		countedAt = time.Now()
+		return count, nil
+	}
+}
@@ -0,0 +1,104 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+)
+
+// KeyTemplateRepository stores key templates as JSON documents keyed by name.
+type KeyTemplateRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewKeyTemplateRepository(db *pgxpool.Pool) *KeyTemplateRepository {
+	return &KeyTemplateRepository{db: db}
+}
+
+func (r *KeyTemplateRepository) PutKeyTemplate(ctx context.Context, template *domain.KeyTemplate) error {
+	definition, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key template: %w", err)
+	}
+
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	query := `INSERT INTO key_templates (name, definition) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET definition = EXCLUDED.definition, updated_at = now()`
+	if _, err := r.db.Exec(ctx, query, template.Name, definition); err != nil {
+		return fmt.Errorf("failed to store key template %s: %w", template.Name, err)
+	}
+	return nil
+}
+
+func (r *KeyTemplateRepository) GetKeyTemplate(ctx context.Context, name string) (*domain.KeyTemplate, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	row := r.db.QueryRow(ctx, `SELECT definition, created_at, updated_at FROM key_templates WHERE name = $1`, name)
+	template, err := scanKeyTemplate(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, app_errors.ErrTemplateNotFound
+	}
+	return template, err
+}
+
+func (r *KeyTemplateRepository) ListKeyTemplates(ctx context.Context) ([]*domain.KeyTemplate, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `SELECT definition, created_at, updated_at FROM key_templates ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list key templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []*domain.KeyTemplate
+	for rows.Next() {
+		template, err := scanKeyTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over key templates: %w", err)
+	}
+	return templates, nil
+}
+
+func (r *KeyTemplateRepository) DeleteKeyTemplate(ctx context.Context, name string) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx, `DELETE FROM key_templates WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete key template %s: %w", name, err)
+	}
+	if result.RowsAffected() == 0 {
+		return app_errors.ErrTemplateNotFound
+	}
+	return nil
+}
+
+func scanKeyTemplate(row pgx.Row) (*domain.KeyTemplate, error) {
+	var definition []byte
+	var template domain.KeyTemplate
+	if err := row.Scan(&definition, &template.CreatedAt, &template.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan key template: %w", err)
+	}
+	if err := json.Unmarshal(definition, &template); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal key template: %w", err)
+	}
+	return &template, nil
+}
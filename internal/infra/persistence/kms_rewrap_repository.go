@@ -0,0 +1,130 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	consts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// KMSRewrapRepository reads and replaces wrapped DEKs in the keys table directly, for
+// every namespace. Like key access statistics it bypasses the key cache, which the
+// re-wrap flushes itself.
+type KMSRewrapRepository struct {
+	db        *pgxpool.Pool
+	integrity *domain.MetadataIntegrity
+}
+
+var _ domain.KeyRewrapRepository = (*KMSRewrapRepository)(nil)
+
+func NewKMSRewrapRepository(db *pgxpool.Pool, integrity *domain.MetadataIntegrity) *KMSRewrapRepository {
+	return &KMSRewrapRepository{db: db, integrity: integrity}
+}
+
+func (r *KMSRewrapRepository) ListWrappedKeys(ctx context.Context, provider, defaultProvider string, after domain.KeyVersionCursor, limit int) ([]*domain.Key, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider
+		FROM keys
+		WHERE COALESCE(kms_provider, $2) = $1
+		  AND storage_type <> $3
+		  AND status <> $4
+		  AND ($5::uuid IS NULL OR (id, version) > ($5::uuid, $6))
+		ORDER BY id, version
+		LIMIT $7`
+	rows, err := r.db.Query(ctx, query, provider, defaultProvider, consts.StorageTypeHardened,
+		domain.KeyStatusDestroyed, nullableString(after.KeyID), after.Version, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys wrapped by %s: %w", provider, err)
+	}
+	defer rows.Close()
+
+	var keys []*domain.Key
+	for rows.Next() {
+		key, err := ScanKeyRowWithID(rows, r.integrity)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over wrapped keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *KMSRewrapRepository) ReplaceWrappedDEK(ctx context.Context, id domain.KeyID, version int32, oldDEK, newDEK []byte, provider string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx,
+		`UPDATE keys SET encrypted_dek = $1, kms_provider = $2 WHERE id = $3::uuid AND version = $4 AND encrypted_dek = $5`,
+		newDEK, provider, id.String(), version, oldDEK)
+	if err != nil {
+		return false, fmt.Errorf("failed to replace the DEK of key %s version %d: %w", id.String(), version, err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *KMSRewrapRepository) GetRewrapCheckpoint(ctx context.Context, fromProvider, toProvider string) (*domain.RewrapCheckpoint, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	checkpoint := domain.RewrapCheckpoint{FromProvider: fromProvider, ToProvider: toProvider}
+	var lastKeyID *string
+	err := r.db.QueryRow(ctx, `
+		SELECT last_key_id::text, last_version, rewrapped, failed, started_at, updated_at, completed_at
+		FROM kms_rewrap_checkpoints
+		WHERE from_provider = $1 AND to_provider = $2`, fromProvider, toProvider).Scan(
+		&lastKeyID, &checkpoint.After.Version, &checkpoint.Rewrapped, &checkpoint.Failed,
+		&checkpoint.StartedAt, &checkpoint.UpdatedAt, &checkpoint.CompletedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read re-wrap checkpoint %s to %s: %w", fromProvider, toProvider, err)
+	}
+	if lastKeyID != nil {
+		checkpoint.After.KeyID = *lastKeyID
+	}
+	return &checkpoint, nil
+}
+
+func (r *KMSRewrapRepository) SaveRewrapCheckpoint(ctx context.Context, checkpoint *domain.RewrapCheckpoint) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO kms_rewrap_checkpoints (from_provider, to_provider, last_key_id, last_version, rewrapped, failed, completed_at)
+		VALUES ($1, $2, $3::uuid, $4, $5, $6, $7)
+		ON CONFLICT (from_provider, to_provider) DO UPDATE SET
+			last_key_id = EXCLUDED.last_key_id,
+			last_version = EXCLUDED.last_version,
+			rewrapped = EXCLUDED.rewrapped,
+			failed = EXCLUDED.failed,
+			completed_at = EXCLUDED.completed_at,
+			updated_at = now()`,
+		checkpoint.FromProvider, checkpoint.ToProvider, nullableString(checkpoint.After.KeyID), checkpoint.After.Version,
+		checkpoint.Rewrapped, checkpoint.Failed, checkpoint.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save re-wrap checkpoint %s to %s: %w", checkpoint.FromProvider, checkpoint.ToProvider, err)
+	}
+	return nil
+}
+
+func (r *KMSRewrapRepository) DeleteRewrapCheckpoint(ctx context.Context, fromProvider, toProvider string) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	if _, err := r.db.Exec(ctx, `DELETE FROM kms_rewrap_checkpoints WHERE from_provider = $1 AND to_provider = $2`, fromProvider, toProvider); err != nil {
+		return fmt.Errorf("failed to delete re-wrap checkpoint %s to %s: %w", fromProvider, toProvider, err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package persistence
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+)
+
+const (
+	defaultLeaderLockName = "polykey-jobs"
+	defaultLeaderInterval = 10 * time.Second
+)
+
+// AdvisoryLockElector elects a leader among the replicas sharing a database with a
+// session-level Postgres advisory lock. The replica whose connection holds the lock leads
+// until it releases it on Stop or the connection drops, at which point Postgres frees the
+// lock and another replica takes it on its next campaign. The leader holds one pool
+// connection for as long as it leads.
+type AdvisoryLockElector struct {
+	pool     *pgxpool.Pool
+	logger   *slog.Logger
+	lockID   int64
+	lockName string
+	interval time.Duration
+
+	leading atomic.Bool
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	conn    *pgxpool.Conn
+	lastErr error
+}
+
+// NewAdvisoryLockElector creates an elector campaigning for the lock named in cfg.
+func NewAdvisoryLockElector(pool *pgxpool.Pool, logger *slog.Logger, cfg config.LeaderElectionConfig) *AdvisoryLockElector {
+	if cfg.LockName == "" {
+		cfg.LockName = defaultLeaderLockName
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultLeaderInterval
+	}
+	h := fnv.New64a()
+	h.Write([]byte(cfg.LockName))
+	return &AdvisoryLockElector{
+		pool:     pool,
+		logger:   logger,
+		lockID:   int64(h.Sum64()),
+		lockName: cfg.LockName,
+		interval: cfg.Interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// IsLeader reports whether this replica held the lock at its last campaign.
+func (e *AdvisoryLockElector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// Start campaigns once right away, so that a sole replica leads before the jobs run their
+// first sweep, then keeps campaigning in the background until Stop is called or ctx is
+// done.
+func (e *AdvisoryLockElector) Start(ctx context.Context) error {
+	e.startOnce.Do(func() {
+		e.mu.Lock()
+		e.started = true
+		e.mu.Unlock()
+		e.campaign(ctx)
+		go e.run(ctx)
+	})
+	return nil
+}
+
+// Stop ends the campaign and gives up the lock, so that another replica can take over
+// without waiting for this connection to close.
+func (e *AdvisoryLockElector) Stop(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stop) })
+
+	e.mu.Lock()
+	started := e.started
+	e.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	e.resign(ctx)
+	return nil
+}
+
+// Health reports whether the last campaign reached the database. Following is healthy.
+func (e *AdvisoryLockElector) Health(context.Context) lifecycle.HealthStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: false, Message: "leader election failed: " + e.lastErr.Error()}
+	}
+	if e.leading.Load() {
+		return lifecycle.HealthStatus{Ready: true, Message: "leading background jobs"}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: "following, another replica leads background jobs"}
+}
+
+func (e *AdvisoryLockElector) run(ctx context.Context) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.campaign(ctx)
+		}
+	}
+}
+
+// campaign checks that the leader's connection, and so its lock, is still alive, or tries
+// to take the lock when not leading.
+func (e *AdvisoryLockElector) campaign(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn != nil {
+		if err := e.conn.Ping(ctx); err != nil {
+			e.logger.WarnContext(ctx, "lost leadership, the connection holding the lock failed", "lock", e.lockName, "error", err)
+			// The session is gone or unusable: close it rather than return it to the pool,
+			// so that Postgres frees the lock if it has not already.
+			_ = e.conn.Conn().Close(context.Background())
+			e.conn.Release()
+			e.conn = nil
+			e.leading.Store(false)
+			e.lastErr = err
+		}
+		return
+	}
+
+	conn, err := e.pool.Acquire(ctx)
+	if err != nil {
+		e.lastErr = err
+		e.logger.ErrorContext(ctx, "leader election failed to acquire a connection", "lock", e.lockName, "error", err)
+		return
+	}
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, e.lockID).Scan(&acquired); err != nil {
+		conn.Release()
+		e.lastErr = err
+		e.logger.ErrorContext(ctx, "leader election failed to try the lock", "lock", e.lockName, "error", err)
+		return
+	}
+	e.lastErr = nil
+	if !acquired {
+		conn.Release()
+		return
+	}
+	e.conn = conn
+	e.leading.Store(true)
+	e.logger.InfoContext(ctx, "acquired leadership of background jobs", "lock", e.lockName)
+}
+
+// resign releases the lock and returns the connection to the pool.
+func (e *AdvisoryLockElector) resign(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return
+	}
+	e.leading.Store(false)
+	if _, err := e.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, e.lockID); err != nil {
+		// Closing the session frees the lock all the same.
+		_ = e.conn.Conn().Close(context.Background())
+	}
+	e.conn.Release()
+	e.conn = nil
+	e.logger.InfoContext(ctx, "gave up leadership of background jobs", "lock", e.lockName)
+}
@@ -0,0 +1,137 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/domain"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var metadataIntegrityFailures, _ = meter.Int64Counter(
+	"polykey.persistence.metadata_integrity_failures",
+	metric.WithDescription("Number of key metadata rows read whose metadata did not match its checksum, or had none, as after being changed outside Polykey."),
+)
+
+// resealBatchSize is the number of rows ResealMetadata reads at a time.
+const resealBatchSize = 500
+
+// resealTables are the tables holding key metadata.
+var resealTables = []string{"keys", "archived_key_versions"}
+
+// marshalMetadata sets the checksum of metadata and encodes it for the metadata column.
+func (a *PSQLAdapter) marshalMetadata(metadata *pk.KeyMetadata) ([]byte, error) {
+	metadata.MetadataChecksum = a.integrity.Checksum(metadata)
+	return a.optimizer.MarshalWithBuffer(metadata)
+}
+
+// verifyMetadata checks metadata read from a row against its checksum, counting the
+// rows that fail.
+func verifyMetadata(integrity *domain.MetadataIntegrity, metadata *pk.KeyMetadata) error {
+	if err := integrity.Verify(metadata); err != nil {
+		metadataIntegrityFailures.Add(context.Background(), 1)
+		return err
+	}
+	return nil
+}
+
+// ResealMetadata gives the metadata of every key version written before checksums were
+// keyed, in the keys and archived_key_versions tables, a checksum under integrity, so
+// that reads can reject metadata without one. Versions whose unkeyed checksum does not
+// match are left as they are, to fail on read, and counted. It is safe to run on
+// several servers at once, and does nothing once every version has been resealed.
+func ResealMetadata(ctx context.Context, db *pgxpool.Pool, integrity *domain.MetadataIntegrity) (resealed, mismatched int, err error) {
+	for _, table := range resealTables {
+		r, m, err := resealTable(ctx, db, integrity, table)
+		resealed += r
+		mismatched += m
+		if err != nil {
+			return resealed, mismatched, err
+		}
+	}
+	return resealed, mismatched, nil
+}
+
+func resealTable(ctx context.Context, db *pgxpool.Pool, integrity *domain.MetadataIntegrity, table string) (resealed, mismatched int, err error) {
+	selectQuery := fmt.Sprintf(`
+		SELECT id, version, metadata FROM %s
+		WHERE COALESCE(metadata->>'metadata_checksum', '') NOT LIKE 'hmac-sha256:%%'
+		  AND ($1::uuid IS NULL OR (id, version) > ($1::uuid, $2))
+		ORDER BY id, version
+		LIMIT $3`, table)
+	// The checksum is compared again, so that metadata rewritten meanwhile is kept.
+	updateQuery := fmt.Sprintf(`
+		UPDATE %s SET metadata = $3
+		WHERE id = $1 AND version = $2
+		  AND COALESCE(metadata->>'metadata_checksum', '') = $4`, table)
+
+	var after *string
+	var afterVersion int32
+	for {
+		batchCtx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
+		rows, err := db.Query(batchCtx, selectQuery, after, afterVersion, resealBatchSize)
+		if err != nil {
+			cancel()
+			return resealed, mismatched, fmt.Errorf("failed to list %s without keyed checksums: %w", table, err)
+		}
+		type row struct {
+			id       uuid.UUID
+			version  int32
+			metadata *pk.KeyMetadata
+		}
+		var batch []row
+		for rows.Next() {
+			r := row{metadata: &pk.KeyMetadata{}}
+			var raw []byte
+			if err := rows.Scan(&r.id, &r.version, &raw); err != nil {
+				rows.Close()
+				cancel()
+				return resealed, mismatched, fmt.Errorf("failed to scan %s row: %w", table, err)
+			}
+			if err := json.Unmarshal(raw, r.metadata); err != nil {
+				rows.Close()
+				cancel()
+				return resealed, mismatched, fmt.Errorf("failed to unmarshal metadata of %s %s version %d: %w", table, r.id, r.version, err)
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		err = rows.Err()
+		cancel()
+		if err != nil {
+			return resealed, mismatched, fmt.Errorf("error iterating over %s: %w", table, err)
+		}
+
+		for _, r := range batch {
+			if err := domain.VerifyLegacyChecksum(r.metadata); err != nil {
+				metadataIntegrityFailures.Add(ctx, 1)
+				mismatched++
+				continue
+			}
+			legacy := r.metadata.GetMetadataChecksum()
+			r.metadata.MetadataChecksum = integrity.Checksum(r.metadata)
+			raw, err := json.Marshal(r.metadata)
+			if err != nil {
+				return resealed, mismatched, fmt.Errorf("failed to marshal metadata: %w", err)
+			}
+			rowCtx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+			tag, err := db.Exec(rowCtx, updateQuery, r.id, r.version, raw, legacy)
+			cancel()
+			if err != nil {
+				return resealed, mismatched, fmt.Errorf("failed to reseal metadata of %s %s version %d: %w", table, r.id, r.version, err)
+			}
+			resealed += int(tag.RowsAffected())
+		}
+
+		if len(batch) < resealBatchSize {
+			return resealed, mismatched, nil
+		}
+		last := batch[len(batch)-1]
+		id := last.id.String()
+		after, afterVersion = &id, last.version
+	}
+}
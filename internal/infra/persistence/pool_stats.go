@@ -0,0 +1,145 @@
+package persistence
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	poolAcquireWait, _ = meter.Float64Histogram(
+		"polykey.persistence.pool.acquire_wait",
+		metric.WithDescription("Time spent acquiring a connection from the database pool, by outcome."),
+		metric.WithUnit("s"),
+	)
+	poolConnections, _ = meter.Int64ObservableGauge(
+		"polykey.persistence.pool.connections",
+		metric.WithDescription("Number of connections of the database pool, by state: acquired, idle, constructing, and max for the limit."),
+	)
+	poolEmptyAcquires, _ = meter.Int64ObservableCounter(
+		"polykey.persistence.pool.empty_acquires",
+		metric.WithDescription("Number of acquires that had to wait for a connection because none was idle."),
+	)
+)
+
+// AcquireWaitSampler returns a function reporting the average time spent acquiring a
+// connection from pool since the previous call. It returns zero when no connections
+// were acquired in between.
+func AcquireWaitSampler(pool *pgxpool.Pool) func() time.Duration {
+	var (
+		mu        sync.Mutex
+		lastCount int64
+		lastTotal time.Duration
+	)
+	return func() time.Duration {
+		stat := pool.Stat()
+		mu.Lock()
+		defer mu.Unlock()
+
+		count, total := stat.AcquireCount(), stat.AcquireDuration()
+		deltaCount, deltaTotal := count-lastCount, total-lastTotal
+		lastCount, lastTotal = count, total
+		if deltaCount <= 0 {
+			return 0
+		}
+		return deltaTotal / time.Duration(deltaCount)
+	}
+}
+
+// registerPoolMetrics reports the connections of pool while the process runs.
+func registerPoolMetrics(pool *pgxpool.Pool) {
+	state := func(s string) metric.ObserveOption {
+		return metric.WithAttributes(attribute.String("state", s))
+	}
+	_, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stat := pool.Stat()
+		o.ObserveInt64(poolConnections, int64(stat.AcquiredConns()), state("acquired"))
+		o.ObserveInt64(poolConnections, int64(stat.IdleConns()), state("idle"))
+		o.ObserveInt64(poolConnections, int64(stat.ConstructingConns()), state("constructing"))
+		o.ObserveInt64(poolConnections, int64(stat.MaxConns()), state("max"))
+		o.ObserveInt64(poolEmptyAcquires, stat.EmptyAcquireCount())
+		return nil
+	}, poolConnections, poolEmptyAcquires)
+}
+
+// PoolTracer is the tracer of the key database pool. It records how long every acquire
+// waits for a connection, and passes statements on to the slow query tracer, if any.
+type PoolTracer struct {
+	slowQueries *SlowQueryTracer
+}
+
+var (
+	_ pgxpool.AcquireTracer = (*PoolTracer)(nil)
+	_ pgx.QueryTracer       = (*PoolTracer)(nil)
+	_ pgx.BatchTracer       = (*PoolTracer)(nil)
+	_ pgx.CopyFromTracer    = (*PoolTracer)(nil)
+)
+
+// NewPoolTracer creates a pool tracer; slowQueries is nil when slow queries are not logged.
+func NewPoolTracer(slowQueries *SlowQueryTracer) *PoolTracer {
+	return &PoolTracer{slowQueries: slowQueries}
+}
+
+type acquireStartKey struct{}
+
+func (t *PoolTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	return context.WithValue(ctx, acquireStartKey{}, time.Now())
+}
+
+func (t *PoolTracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	start, ok := ctx.Value(acquireStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	outcome := "acquired"
+	if data.Err != nil {
+		outcome = "failed"
+	}
+	poolAcquireWait.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attribute.String("outcome", outcome)))
+}
+
+func (t *PoolTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if t.slowQueries == nil {
+		return ctx
+	}
+	return t.slowQueries.TraceQueryStart(ctx, conn, data)
+}
+
+func (t *PoolTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if t.slowQueries != nil {
+		t.slowQueries.TraceQueryEnd(ctx, conn, data)
+	}
+}
+
+func (t *PoolTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	if t.slowQueries == nil {
+		return ctx
+	}
+	return t.slowQueries.TraceBatchStart(ctx, conn, data)
+}
+
+func (t *PoolTracer) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {}
+
+func (t *PoolTracer) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	if t.slowQueries != nil {
+		t.slowQueries.TraceBatchEnd(ctx, conn, data)
+	}
+}
+
+func (t *PoolTracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	if t.slowQueries == nil {
+		return ctx
+	}
+	return t.slowQueries.TraceCopyFromStart(ctx, conn, data)
+}
+
+func (t *PoolTracer) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	if t.slowQueries != nil {
+		t.slowQueries.TraceCopyFromEnd(ctx, conn, data)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -14,6 +15,7 @@ import (
 	consts "github.com/spounge-ai/polykey/internal/constants"
 	"github.com/spounge-ai/polykey/internal/domain"
 	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/pkg/clock"
 	psql "github.com/spounge-ai/polykey/pkg/postgres"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 	
@@ -22,29 +24,68 @@ import (
 const (
 	defaultKeysCapacity = 100
 	versionsCapacity    = 10
+
+	// Fallback query timeouts, used only when the caller has not set a deadline.
+	defaultQueryTimeout      = 3 * time.Second
+	defaultBatchQueryTimeout = 5 * time.Second
 )
 
+// withQueryTimeout keeps the caller's deadline when there is one, so that the remaining
+// RPC budget is what bounds the query, and applies fallback otherwise.
+func withQueryTimeout(ctx context.Context, fallback time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, fallback)
+}
+
+// namespaceArg is the namespace filter passed to every key query: the namespace of a
+// request context, or NULL, which matches every namespace, for unscoped contexts.
+func namespaceArg(ctx context.Context) *string {
+	if namespace, ok := domain.NamespaceFromContext(ctx); ok {
+		return &namespace
+	}
+	return nil
+}
+
+// keyNamespace is the namespace a new key is stored in.
+func keyNamespace(ctx context.Context, key *domain.Key) string {
+	if key.Namespace != "" {
+		return key.Namespace
+	}
+	if namespace, ok := domain.NamespaceFromContext(ctx); ok {
+		return namespace
+	}
+	return domain.DefaultNamespace
+}
+
 type PSQLAdapter struct {
 	*PostgresBase
 	optimizer *QueryOptimizer
 	txManager *TransactionManager[*domain.Key]
+	clock     clock.Clock
+	integrity *domain.MetadataIntegrity
 }
 
-func NewPSQLAdapter(db *pgxpool.Pool, logger *slog.Logger) (*PSQLAdapter, error) {
+// NewPSQLAdapter creates a PSQLAdapter stamping the changes it makes with the time of clk
+// and checking the metadata it reads against checksums computed with integrity.
+func NewPSQLAdapter(db *pgxpool.Pool, logger *slog.Logger, clk clock.Clock, integrity *domain.MetadataIntegrity) (*PSQLAdapter, error) {
 	a := &PSQLAdapter{
 		PostgresBase: NewPostgresBase(db, logger),
 		optimizer:    NewQueryOptimizer(),
 		txManager:    NewTransactionManager[*domain.Key](logger),
+		clock:        clk,
+		integrity:    integrity,
 	}
 
 	return a, nil
 }
 
 func (a *PSQLAdapter) GetKey(ctx context.Context, id domain.KeyID) (*domain.Key, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
-	row := a.DB.QueryRow(ctx, consts.Queries[consts.StmtGetLatestKey], id.String())
-	key, err := ScanKeyRow(row)
+	row := a.DB.QueryRow(ctx, consts.Queries[consts.StmtGetLatestKey], id.String(), namespaceArg(ctx))
+	key, err := ScanKeyRow(row, a.integrity)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, psql.ErrKeyNotFound
@@ -60,11 +101,11 @@ func (a *PSQLAdapter) GetKeyByVersion(ctx context.Context, id domain.KeyID, vers
 	if version <= 0 {
 		return nil, psql.ErrInvalidVersion
 	}
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
 
-	row := a.DB.QueryRow(ctx, consts.Queries[consts.StmtGetKeyByVersion], id.String(), version)
-	key, err := ScanKeyRow(row)
+	row := a.DB.QueryRow(ctx, consts.Queries[consts.StmtGetKeyByVersion], id.String(), version, namespaceArg(ctx))
+	key, err := ScanKeyRow(row, a.integrity)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, psql.ErrKeyNotFound
@@ -78,10 +119,10 @@ func (a *PSQLAdapter) GetKeyByVersion(ctx context.Context, id domain.KeyID, vers
 }
 
 func (a *PSQLAdapter) GetKeyMetadata(ctx context.Context, id domain.KeyID) (*pk.KeyMetadata, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
 	var metadataRaw []byte
-	err := a.DB.QueryRow(ctx, consts.Queries[consts.StmtGetKeyMetadata], id.String()).Scan(&metadataRaw)
+	err := a.DB.QueryRow(ctx, consts.Queries[consts.StmtGetKeyMetadata], id.String(), namespaceArg(ctx)).Scan(&metadataRaw)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, psql.ErrKeyNotFound
@@ -93,6 +134,9 @@ func (a *PSQLAdapter) GetKeyMetadata(ctx context.Context, id domain.KeyID) (*pk.
 	if err := json.Unmarshal(metadataRaw, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
+	if err := verifyMetadata(a.integrity, &metadata); err != nil {
+		return nil, err
+	}
 
 	return &metadata, nil
 }
@@ -101,10 +145,10 @@ func (a *PSQLAdapter) GetKeyMetadataByVersion(ctx context.Context, id domain.Key
 	if version <= 0 {
 		return nil, psql.ErrInvalidVersion
 	}
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
 	var metadataRaw []byte
-	err := a.DB.QueryRow(ctx, consts.Queries[consts.StmtGetKeyMetadataByVersion], id.String(), version).Scan(&metadataRaw)
+	err := a.DB.QueryRow(ctx, consts.Queries[consts.StmtGetKeyMetadataByVersion], id.String(), version, namespaceArg(ctx)).Scan(&metadataRaw)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, psql.ErrKeyNotFound
@@ -116,6 +160,9 @@ func (a *PSQLAdapter) GetKeyMetadataByVersion(ctx context.Context, id domain.Key
 	if err := json.Unmarshal(metadataRaw, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
+	if err := verifyMetadata(a.integrity, &metadata); err != nil {
+		return nil, err
+	}
 
 	return &metadata, nil
 }
@@ -131,7 +178,7 @@ func (a *PSQLAdapter) CreateKey(ctx context.Context, key *domain.Key) error {
 		return errors.New("encrypted DEK cannot be empty")
 	}
 
-	metadataRaw, err := a.optimizer.MarshalWithBuffer(key.Metadata)
+	metadataRaw, err := a.marshalMetadata(key.Metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
@@ -142,14 +189,14 @@ func (a *PSQLAdapter) CreateKey(ctx context.Context, key *domain.Key) error {
 	rows := [][]interface{}{
 		{
 			key.ID.String(), key.Version, metadataRaw, key.EncryptedDEK,
-			key.Status, storageType, key.CreatedAt, key.UpdatedAt,
+			key.Status, storageType, key.CreatedAt, key.UpdatedAt, keyNamespace(ctx, key), nullableString(key.KMSProvider),
 		},
 	}
 
 	_, err = a.DB.CopyFrom(
 		ctx,
 		pgx.Identifier{"keys"},
-		[]string{"id", "version", "metadata", "encrypted_dek", "status", "storage_type", "created_at", "updated_at"},
+		[]string{"id", "version", "metadata", "encrypted_dek", "status", "storage_type", "created_at", "updated_at", "namespace", "kms_provider"},
 		pgx.CopyFromRows(rows),
 	)
 
@@ -171,18 +218,19 @@ func (a *PSQLAdapter) CreateBatchKeys(ctx context.Context, keys []*domain.Key) e
 
 	columnNames := []string{
 		"id", "version", "metadata", "encrypted_dek",
-		"status", "storage_type", "created_at", "updated_at",
+		"status", "storage_type", "created_at", "updated_at", "namespace", "kms_provider",
 	}
 
 	rows := make([][]interface{}, len(keys))
 	for i, key := range keys {
-		metadataRaw, err := a.optimizer.MarshalWithBuffer(key.Metadata)
+		metadataRaw, err := a.marshalMetadata(key.Metadata)
 		if err != nil {
 			return fmt.Errorf("failed to marshal metadata for key %s: %w", key.ID.String(), err)
 		}
 		rows[i] = []interface{}{
 			key.ID.String(), key.Version, metadataRaw, key.EncryptedDEK,
-			key.Status, getStorageTypeOptimized(key.Metadata.GetStorageType()), key.CreatedAt, key.UpdatedAt,
+			key.Status, getStorageTypeOptimized(key.Metadata.GetStorageType()), key.CreatedAt, key.UpdatedAt, keyNamespace(ctx, key),
+			nullableString(key.KMSProvider),
 		}
 	}
 
@@ -204,10 +252,30 @@ func (a *PSQLAdapter) CreateBatchKeys(ctx context.Context, keys []*domain.Key) e
 	return nil
 }
 
-func (a *PSQLAdapter) ListKeys(ctx context.Context, lastCreatedAt *time.Time, limit int) ([]*domain.Key, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+func (a *PSQLAdapter) ListKeys(ctx context.Context, filter domain.KeyFilter, page domain.KeyPage, limit int) ([]*domain.Key, error) {
+	order := page.Order.OrDefault()
+	if !slices.Contains(domain.KeySortFields, order.Field) {
+		return nil, fmt.Errorf("keys cannot be ordered by %q", order.Field)
+	}
+	var afterValue, afterID *string
+	if page.After != nil {
+		value, id := page.After.Value, page.After.ID.String()
+		afterValue, afterID = &value, &id
+	}
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
-	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtListKeys], lastCreatedAt, limit)
+	// Nil arrays match any key; empty ones would match none.
+	var keyTypes []int32
+	for _, keyType := range filter.KeyTypes {
+		keyTypes = append(keyTypes, int32(keyType))
+	}
+	var statuses []string
+	for _, status := range filter.Statuses {
+		statuses = append(statuses, string(status))
+	}
+	rows, err := a.DB.Query(ctx, consts.Queries[consts.ListKeysStatement(string(order.Field), order.Descending)], afterValue, limit,
+		namespaceArg(ctx), keyTypes, statuses, nullableString(filter.CreatorIdentity), nullableString(filter.DataClassification),
+		nullableString(filter.AuthorizedContext), afterID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query keys: %w", err)
 	}
@@ -215,11 +283,15 @@ func (a *PSQLAdapter) ListKeys(ctx context.Context, lastCreatedAt *time.Time, li
 
 	keys := make([]*domain.Key, 0, defaultKeysCapacity)
 	for rows.Next() {
-		key, err := ScanKeyRowWithID(rows)
+		var alias *string
+		key, err := ScanKeyRowWithID(aliasRow{Row: rows, alias: &alias}, a.integrity)
 		if err != nil {
 			a.logger.Error("failed to scan key row in ListKeys", "error", err)
 			continue
 		}
+		if alias != nil {
+			key.Alias = *alias
+		}
 		keys = append(keys, key)
 	}
 
@@ -230,19 +302,29 @@ func (a *PSQLAdapter) ListKeys(ctx context.Context, lastCreatedAt *time.Time, li
 	return keys, nil
 }
 
+// aliasRow scans a key row followed by the alias listings add to it.
+type aliasRow struct {
+	pgx.Row
+	alias **string
+}
+
+func (r aliasRow) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.alias)...)
+}
+
 func (a *PSQLAdapter) UpdateKeyMetadata(ctx context.Context, id domain.KeyID, metadata *pk.KeyMetadata) error {
 	if metadata == nil {
 		return errors.New("metadata cannot be nil")
 	}
 
-	metadataRaw, err := a.optimizer.MarshalWithBuffer(metadata)
+	metadataRaw, err := a.marshalMetadata(metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
-	result, err := a.DB.Exec(ctx, consts.Queries[consts.StmtUpdateMetadata], metadataRaw, time.Now(), id.String())
+	result, err := a.DB.Exec(ctx, consts.Queries[consts.StmtUpdateMetadata], metadataRaw, a.clock.Now(), id.String(), namespaceArg(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to update key metadata %s: %w", id.String(), err)
 	}
@@ -254,20 +336,50 @@ func (a *PSQLAdapter) UpdateKeyMetadata(ctx context.Context, id domain.KeyID, me
 	return nil
 }
 
-func (a *PSQLAdapter) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte) (*domain.Key, error) {
+func (a *PSQLAdapter) UpdateKeyMetadataIfOwner(ctx context.Context, id domain.KeyID, version int32, owner string, metadata *pk.KeyMetadata) error {
+	if metadata == nil {
+		return errors.New("metadata cannot be nil")
+	}
+
+	metadataRaw, err := a.marshalMetadata(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+	result, err := a.DB.Exec(ctx, consts.Queries[consts.StmtUpdateMetadataIfOwner], metadataRaw, a.clock.Now(), id.String(), namespaceArg(ctx), version, owner)
+	if err != nil {
+		return fmt.Errorf("failed to update key metadata %s: %w", id.String(), err)
+	}
+	if result.RowsAffected() > 0 {
+		return nil
+	}
+
+	exists, err := a.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return psql.ErrKeyNotFound
+	}
+	return fmt.Errorf("%w: key %s changed version or owner since it was read", app_errors.ErrConflict, id.String())
+}
+
+func (a *PSQLAdapter) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte, graceDeadline time.Time) (*domain.Key, error) {
 	if len(newEncryptedDEK) == 0 {
 		return nil, errors.New("new encrypted DEK cannot be empty")
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
 
 	return a.txManager.ExecuteInTransaction(ctx, a.DB, func(ctx context.Context, tx pgx.Tx) (*domain.Key, error) {
-		return a.rotateKeyInTx(ctx, tx, id, newEncryptedDEK)
+		return a.rotateKeyInTx(ctx, tx, id, newEncryptedDEK, graceDeadline)
 	})
 }
 
-func (a *PSQLAdapter) rotateKeyInTx(ctx context.Context, tx pgx.Tx, id domain.KeyID, newEncryptedDEK []byte) (*domain.Key, error) {
+func (a *PSQLAdapter) rotateKeyInTx(ctx context.Context, tx pgx.Tx, id domain.KeyID, newEncryptedDEK []byte, graceDeadline time.Time) (*domain.Key, error) {
 	lockID := a.GetLockID(id)
 	locked, err := a.TryAcquireLock(ctx, tx, lockID)
 	if err != nil {
@@ -280,12 +392,13 @@ func (a *PSQLAdapter) rotateKeyInTx(ctx context.Context, tx pgx.Tx, id domain.Ke
 	const rotateQuery = `
 		WITH old_key AS (
 			UPDATE keys
-			SET status = $1, updated_at = now()
-			WHERE id = $2 AND version = (SELECT MAX(version) FROM keys WHERE id = $2)
-			RETURNING id, metadata, storage_type
+			SET status = $1, grace_expires_at = $5, updated_at = $8
+			WHERE id = $2 AND version = (SELECT MAX(version) FROM keys WHERE id = $2) AND status = ANY($6)
+			  AND ($7::text IS NULL OR namespace = $7)
+			RETURNING id, metadata, storage_type, namespace, kms_provider
 		),
 		new_key AS (
-			INSERT INTO keys (id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at)
+			INSERT INTO keys (id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, namespace, kms_provider)
 			SELECT
 				id,
 				(metadata->>'version')::int + 1,
@@ -293,12 +406,14 @@ func (a *PSQLAdapter) rotateKeyInTx(ctx context.Context, tx pgx.Tx, id domain.Ke
 				$3,
 				$4,
 				storage_type,
-				now(),
-				now()
+				$8,
+				$8,
+				namespace,
+				kms_provider
 			FROM old_key
-			RETURNING id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at
+			RETURNING id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider
 		)
-		SELECT id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at FROM new_key;
+		SELECT id, version, metadata, encrypted_dek, status, storage_type, created_at, updated_at, revoked_at, grace_expires_at, namespace, kms_provider FROM new_key;
 	`
 
 	row := tx.QueryRow(ctx, rotateQuery,
@@ -306,12 +421,16 @@ func (a *PSQLAdapter) rotateKeyInTx(ctx context.Context, tx pgx.Tx, id domain.Ke
 		id.String(),
 		newEncryptedDEK,
 		domain.KeyStatusActive,
+		nullableTime(graceDeadline),
+		transitionGuard(domain.KeyStatusRotated),
+		namespaceArg(ctx),
+		a.clock.Now(),
 	)
 
-	key, err := ScanKeyRowWithID(row)
+	key, err := ScanKeyRowWithID(row, a.integrity)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, psql.ErrKeyNotFound
+			return nil, a.transitionFailure(ctx, tx, id, domain.KeyStatusRotated)
 		}
 		return nil, fmt.Errorf("failed to rotate key %s: %w", id.String(), err)
 	}
@@ -320,24 +439,53 @@ func (a *PSQLAdapter) rotateKeyInTx(ctx context.Context, tx pgx.Tx, id domain.Ke
 }
 
 func (a *PSQLAdapter) RevokeKey(ctx context.Context, id domain.KeyID) error {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
-	result, err := a.DB.Exec(ctx, consts.Queries[consts.StmtRevokeKey], domain.KeyStatusRevoked, time.Now(), id.String())
+	result, err := a.DB.Exec(ctx, consts.Queries[consts.StmtRevokeKey], domain.KeyStatusRevoked, a.clock.Now(), id.String(), transitionGuard(domain.KeyStatusRevoked), namespaceArg(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to revoke key %s: %w", id.String(), err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return psql.ErrKeyNotFound
+		return a.transitionFailure(ctx, a.DB, id, domain.KeyStatusRevoked)
 	}
 
 	return nil
 }
 
+// RestoreKey only reactivates the latest version; rotated versions revoked together with
+// it stay revoked.
+func (a *PSQLAdapter) RestoreKey(ctx context.Context, id domain.KeyID, revokedSince time.Time) (*domain.Key, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+	row := a.DB.QueryRow(ctx, consts.Queries[consts.StmtRestoreKey], domain.KeyStatusActive, id.String(), domain.KeyStatusRevoked, revokedSince, namespaceArg(ctx))
+	key, err := ScanKeyRow(row, a.integrity)
+	if err == nil {
+		key.ID = id
+		return key, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to restore key %s: %w", id.String(), err)
+	}
+
+	var current domain.KeyStatus
+	err = a.DB.QueryRow(ctx, consts.Queries[consts.StmtGetLatestStatus], id.String(), namespaceArg(ctx)).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, psql.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status of key %s: %w", id.String(), err)
+	}
+	if current != domain.KeyStatusRevoked {
+		return nil, &domain.KeyTransitionError{From: current, To: domain.KeyStatusActive}
+	}
+	return nil, app_errors.ErrRestoreWindowClosed
+}
+
 func (a *PSQLAdapter) GetKeyVersions(ctx context.Context, id domain.KeyID) ([]*domain.Key, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
-	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtGetVersions], id.String())
+	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtGetVersions], id.String(), namespaceArg(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query key versions: %w", err)
 	}
@@ -345,7 +493,7 @@ func (a *PSQLAdapter) GetKeyVersions(ctx context.Context, id domain.KeyID) ([]*d
 
 	keys := make([]*domain.Key, 0, versionsCapacity)
 	for rows.Next() {
-		key, err := ScanKeyRow(rows)
+		key, err := ScanKeyRow(rows, a.integrity)
 		if err != nil {
 			a.logger.Error("failed to scan key row in GetKeyVersions", "error", err)
 			continue
@@ -361,11 +509,22 @@ func (a *PSQLAdapter) GetKeyVersions(ctx context.Context, id domain.KeyID) ([]*d
 	return keys, nil
 }
 
+func (a *PSQLAdapter) CountKeys(ctx context.Context, namespace string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+	var count int
+	err := a.DB.QueryRow(ctx, consts.Queries[consts.StmtCountKeys], namespace, []string{string(domain.KeyStatusDestroyed)}).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count keys of namespace %s: %w", namespace, err)
+	}
+	return count, nil
+}
+
 func (a *PSQLAdapter) Exists(ctx context.Context, id domain.KeyID) (bool, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
 	var exists bool
-	err := a.DB.QueryRow(ctx, consts.Queries[consts.StmtCheckExists], id.String()).Scan(&exists)
+	err := a.DB.QueryRow(ctx, consts.Queries[consts.StmtCheckExists], id.String(), namespaceArg(ctx)).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check key existence %s: %w", id.String(), err)
 	}
@@ -383,10 +542,10 @@ func (a *PSQLAdapter) GetBatchKeys(ctx context.Context, ids []domain.KeyID) ([]*
 		stringIDs[i] = id.String()
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second) // Increased timeout for batch
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
 	defer cancel()
 
-	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtGetBatchKeys], stringIDs)
+	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtGetBatchKeys], stringIDs, namespaceArg(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get batch keys: %w", err)
 	}
@@ -394,7 +553,7 @@ func (a *PSQLAdapter) GetBatchKeys(ctx context.Context, ids []domain.KeyID) ([]*
 
 	keys := make([]*domain.Key, 0, len(ids))
 	for rows.Next() {
-		key, err := ScanKeyRowWithID(rows)
+		key, err := ScanKeyRowWithID(rows, a.integrity)
 		if err != nil {
 			a.logger.Error("failed to scan key row in GetBatchKeys", "error", err)
 			continue
@@ -419,10 +578,10 @@ func (a *PSQLAdapter) GetBatchKeyMetadata(ctx context.Context, ids []domain.KeyI
 		stringIDs[i] = id.String()
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second) // Increased timeout for batch
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
 	defer cancel()
 
-	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtGetBatchKeyMetadata], stringIDs)
+	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtGetBatchKeyMetadata], stringIDs, namespaceArg(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get batch key metadata: %w", err)
 	}
@@ -440,6 +599,10 @@ func (a *PSQLAdapter) GetBatchKeyMetadata(ctx context.Context, ids []domain.KeyI
 			a.logger.Error("failed to unmarshal metadata in GetBatchKeyMetadata", "error", err)
 			continue
 		}
+		if err := verifyMetadata(a.integrity, &metadata); err != nil {
+			a.logger.Error("skipping key metadata in GetBatchKeyMetadata", "error", err)
+			continue
+		}
 		metadataList = append(metadataList, &metadata)
 	}
 
@@ -460,10 +623,10 @@ func (a *PSQLAdapter) RevokeBatchKeys(ctx context.Context, ids []domain.KeyID) e
 		stringIDs[i] = id.String()
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
 	defer cancel()
 
-	result, err := a.DB.Exec(ctx, consts.Queries[consts.StmtRevokeBatchKeys], domain.KeyStatusRevoked, time.Now(), stringIDs)
+	result, err := a.DB.Exec(ctx, consts.Queries[consts.StmtRevokeBatchKeys], domain.KeyStatusRevoked, a.clock.Now(), stringIDs, transitionGuard(domain.KeyStatusRevoked), namespaceArg(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to revoke batch keys: %w", err)
 	}
@@ -487,14 +650,14 @@ func (a *PSQLAdapter) UpdateBatchKeyMetadata(ctx context.Context, updates []*dom
 		if key.Metadata == nil {
 			return errors.New("key metadata cannot be nil for batch update")
 		}
-		metadataRaw, err := a.optimizer.MarshalWithBuffer(key.Metadata)
+		metadataRaw, err := a.marshalMetadata(key.Metadata)
 		if err != nil {
 			return fmt.Errorf("failed to marshal metadata for key %s: %w", key.ID.String(), err)
 		}
-		batch.Queue(consts.Queries[consts.StmtUpdateMetadata], metadataRaw, time.Now(), key.ID.String())
+		batch.Queue(consts.Queries[consts.StmtUpdateMetadata], metadataRaw, a.clock.Now(), key.ID.String(), namespaceArg(ctx))
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second) // Increased timeout for batch
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
 	defer cancel()
 
 	br := a.DB.SendBatch(ctx, batch)
@@ -514,6 +677,109 @@ func (a *PSQLAdapter) UpdateBatchKeyMetadata(ctx context.Context, updates []*dom
 	return nil
 }
 
+func (a *PSQLAdapter) ExpireKeys(ctx context.Context, asOf time.Time, limit int) ([]*domain.Key, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
+	defer cancel()
+
+	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtExpireKeys], domain.KeyStatusExpired, domain.KeyStatusActive, asOf, limit, domain.KeyStatusRotated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire keys: %w", err)
+	}
+	return a.collectKeys(rows, "ExpireKeys")
+}
+
+func (a *PSQLAdapter) ListExpiringKeys(ctx context.Context, after domain.KeyExpiryCursor, to time.Time, limit int) ([]*domain.Key, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtListExpiringKeys], domain.KeyStatusActive, after.ExpiresAt, to, limit, nullableString(after.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiring keys: %w", err)
+	}
+	return a.collectKeys(rows, "ListExpiringKeys")
+}
+
+func (a *PSQLAdapter) PruneKeyVersions(ctx context.Context, retention domain.KeyVersionRetention, limit int) ([]*domain.Key, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultBatchQueryTimeout)
+	defer cancel()
+
+	rows, err := a.DB.Query(ctx, consts.Queries[consts.StmtPruneKeyVersions], domain.KeyStatusExpired, domain.KeyStatusRotated,
+		retention.Before, retention.KeepVersions, limit, retention.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune key versions: %w", err)
+	}
+	return a.collectKeys(rows, "PruneKeyVersions")
+}
+
+// transitionGuard lists the statuses a guarded update may move to the target status from.
+func transitionGuard(to domain.KeyStatus) []string {
+	from := domain.KeyStatusesTransitioningTo(to)
+	guard := make([]string, len(from))
+	for i, status := range from {
+		guard[i] = string(status)
+	}
+	return guard
+}
+
+// rowQuerier is satisfied by both the pool and a transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// transitionFailure explains why a guarded status update matched no rows: either the
+// key does not exist or its current status does not allow the transition.
+func (a *PSQLAdapter) transitionFailure(ctx context.Context, q rowQuerier, id domain.KeyID, to domain.KeyStatus) error {
+	var current domain.KeyStatus
+	err := q.QueryRow(ctx, consts.Queries[consts.StmtGetLatestStatus], id.String(), namespaceArg(ctx)).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return psql.ErrKeyNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read status of key %s: %w", id.String(), err)
+	}
+	if err := domain.ValidateKeyTransition(current, to); err != nil {
+		return err
+	}
+	// The status changed underneath the update; report it as a conflict.
+	return app_errors.ErrConflict
+}
+
+// nullableTime maps the zero time to SQL NULL.
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// nullableString maps the empty string to SQL NULL.
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// collectKeys drains rows of full key records, skipping rows that fail to scan.
+func (a *PSQLAdapter) collectKeys(rows pgx.Rows, op string) ([]*domain.Key, error) {
+	defer rows.Close()
+
+	keys := make([]*domain.Key, 0, defaultKeysCapacity)
+	for rows.Next() {
+		key, err := ScanKeyRowWithID(rows, a.integrity)
+		if err != nil {
+			a.logger.Error("failed to scan key row in "+op, "error", err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+	return keys, nil
+}
+
 func (a *PSQLAdapter) Close() error {
 	a.DB.Close()
 	return nil
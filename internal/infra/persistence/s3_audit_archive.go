@@ -0,0 +1,194 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+)
+
+// S3AuditArchiveStore keeps audit archives as gzip-compressed JSON lines in S3, encrypted
+// with SSE-KMS when a key is configured and SSE-S3 otherwise.
+type S3AuditArchiveStore struct {
+	client       *s3.Client
+	bucket       string
+	storageClass types.StorageClass
+	kmsKeyID     string
+	restoreDays  int32
+	restoreTier  types.Tier
+}
+
+var _ domain.AuditArchiveStore = (*S3AuditArchiveStore)(nil)
+
+func NewS3AuditArchiveStore(cfg aws.Config, retention config.AuditRetentionConfig) *S3AuditArchiveStore {
+	return &S3AuditArchiveStore{
+		client:       s3.NewFromConfig(cfg),
+		bucket:       retention.Bucket,
+		storageClass: types.StorageClass(retention.StorageClass),
+		kmsKeyID:     retention.KMSKeyID,
+		restoreDays:  max(retention.RestoreDays, 1),
+		restoreTier:  types.Tier(retention.RestoreTier),
+	}
+}
+
+type auditArchiveRecord struct {
+	ID              string               `json:"id"`
+	Timestamp       time.Time            `json:"timestamp"`
+	ClientIdentity  string               `json:"client_identity"`
+	Operation       string               `json:"operation"`
+	KeyID           string               `json:"key_id,omitempty"`
+	AuthDecisionID  string               `json:"auth_decision_id,omitempty"`
+	CorrelationID   string               `json:"correlation_id,omitempty"`
+	Success         bool                 `json:"success"`
+	Error           string               `json:"error,omitempty"`
+	PeerIP          string               `json:"peer_ip,omitempty"`
+	UserAgent       string               `json:"user_agent,omitempty"`
+	CertFingerprint string               `json:"cert_fingerprint,omitempty"`
+	Changes         []domain.AuditChange `json:"changes,omitempty"`
+	Sequence        int64                `json:"sequence,omitempty"`
+	PrevHash        string               `json:"prev_hash,omitempty"`
+	Hash            string               `json:"hash,omitempty"`
+}
+
+func (s *S3AuditArchiveStore) PutAuditArchive(ctx context.Context, objectKey string, events []*domain.AuditEvent) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, event := range events {
+		if err := enc.Encode(auditArchiveRecord{
+			ID:              event.ID,
+			Timestamp:       event.Timestamp,
+			ClientIdentity:  event.ClientIdentity,
+			Operation:       event.Operation,
+			KeyID:           event.KeyID,
+			AuthDecisionID:  event.AuthDecisionID,
+			CorrelationID:   event.CorrelationID,
+			Success:         event.Success,
+			Error:           event.Error,
+			PeerIP:          event.PeerIP,
+			UserAgent:       event.UserAgent,
+			CertFingerprint: event.CertFingerprint,
+			Changes:         event.Changes,
+			Sequence:        event.Sequence,
+			PrevHash:        event.PrevHash,
+			Hash:            event.Hash,
+		}); err != nil {
+			return fmt.Errorf("failed to encode audit archive: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress audit archive: %w", err)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:               &s.bucket,
+		Key:                  &objectKey,
+		Body:                 bytes.NewReader(buf.Bytes()),
+		ContentType:          aws.String("application/gzip"),
+		StorageClass:         s.storageClass,
+		ServerSideEncryption: types.ServerSideEncryptionAes256,
+	}
+	if s.kmsKeyID != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = &s.kmsKeyID
+	}
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload audit archive %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+// GetAuditArchive reads an archive back. An object in Glacier Flexible Retrieval or Deep
+// Archive is first retrieved to a temporary copy: the first call starts the retrieval
+// and, like every call until it completes, fails with ErrAuditArchiveNotReady.
+func (s *S3AuditArchiveStore) GetAuditArchive(ctx context.Context, objectKey string) ([]*domain.AuditEvent, error) {
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &s.bucket, Key: &objectKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect audit archive %s: %w", objectKey, err)
+	}
+	if head.StorageClass == types.StorageClassGlacier || head.StorageClass == types.StorageClassDeepArchive {
+		if head.Restore == nil {
+			if err := s.startRetrieval(ctx, objectKey); err != nil {
+				return nil, err
+			}
+			return nil, app_errors.ErrAuditArchiveNotReady
+		}
+		if strings.Contains(*head.Restore, `ongoing-request="true"`) {
+			return nil, app_errors.ErrAuditArchiveNotReady
+		}
+	}
+
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: &s.bucket, Key: &objectKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download audit archive %s: %w", objectKey, err)
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	gz, err := gzip.NewReader(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress audit archive %s: %w", objectKey, err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	var events []*domain.AuditEvent
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record auditArchiveRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to decode audit archive %s: %w", objectKey, err)
+		}
+		events = append(events, &domain.AuditEvent{
+			ID:              record.ID,
+			Timestamp:       record.Timestamp,
+			ClientIdentity:  record.ClientIdentity,
+			Operation:       record.Operation,
+			KeyID:           record.KeyID,
+			AuthDecisionID:  record.AuthDecisionID,
+			CorrelationID:   record.CorrelationID,
+			Success:         record.Success,
+			Error:           record.Error,
+			PeerIP:          record.PeerIP,
+			UserAgent:       record.UserAgent,
+			CertFingerprint: record.CertFingerprint,
+			Changes:         record.Changes,
+			Sequence:        record.Sequence,
+			PrevHash:        record.PrevHash,
+			Hash:            record.Hash,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit archive %s: %w", objectKey, err)
+	}
+	return events, nil
+}
+
+func (s *S3AuditArchiveStore) startRetrieval(ctx context.Context, objectKey string) error {
+	_, err := s.client.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket: &s.bucket,
+		Key:    &objectKey,
+		RestoreRequest: &types.RestoreRequest{
+			Days:                 &s.restoreDays,
+			GlacierJobParameters: &types.GlacierJobParameters{Tier: s.restoreTier},
+		},
+	})
+	// A retrieval requested by someone else in the meantime is just as good.
+	var apiErr smithy.APIError
+	if err != nil && !(errors.As(err, &apiErr) && apiErr.ErrorCode() == "RestoreAlreadyInProgress") {
+		return fmt.Errorf("failed to start retrieval of audit archive %s: %w", objectKey, err)
+	}
+	return nil
+}
@@ -18,6 +18,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/pkg/clock"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 )
 
@@ -25,25 +27,33 @@ type S3Storage struct {
 	client     *s3.Client
 	bucketName string
 	logger     *slog.Logger
+	integrity  *domain.MetadataIntegrity
+	clock      clock.Clock
 }
 
-func NewS3Storage(cfg aws.Config, bucketName string, logger *slog.Logger) (*S3Storage, error) {
+func NewS3Storage(cfg aws.Config, bucketName string, logger *slog.Logger, clk clock.Clock, integrity *domain.MetadataIntegrity) (*S3Storage, error) {
 	s3Client := s3.NewFromConfig(cfg)
 	return &S3Storage{
 		client:     s3Client,
 		bucketName: bucketName,
 		logger:     logger,
+		integrity:  integrity,
+		clock:      clk,
 	}, nil
 }
 
 type s3KeyObject struct {
-	ID            string          `json:"id"`
-	EncryptedDEK  []byte          `json:"encrypted_dek"`
-	Metadata      *pk.KeyMetadata `json:"metadata"`
-	Version       int32           `json:"version"`
-	Status        pk.KeyStatus    `json:"status"`
-	CreatedAt     int64           `json:"created_at"`
-	UpdatedAt     int64           `json:"updated_at"`
+	ID             string          `json:"id"`
+	Namespace      string          `json:"namespace,omitempty"`
+	EncryptedDEK   []byte          `json:"encrypted_dek"`
+	Metadata       *pk.KeyMetadata `json:"metadata"`
+	Version        int32           `json:"version"`
+	Status         pk.KeyStatus    `json:"status"`
+	CreatedAt      int64           `json:"created_at"`
+	UpdatedAt      int64           `json:"updated_at"`
+	GraceExpiresAt int64           `json:"grace_expires_at,omitempty"`
+	RevokedAt      int64           `json:"revoked_at,omitempty"`
+	KMSProvider    string          `json:"kms_provider,omitempty"`
 }
 
 func (s *S3Storage) GetKey(ctx context.Context, id domain.KeyID) (*domain.Key, error) {
@@ -103,37 +113,93 @@ func (s *S3Storage) getKeyFromPath(ctx context.Context, path string) (*domain.Ke
 	if err := json.NewDecoder(output.Body).Decode(&keyObj); err != nil {
 		return nil, fmt.Errorf("failed to decode key object from S3: %w", err)
 	}
+	if err := verifyMetadata(s.integrity, keyObj.Metadata); err != nil {
+		return nil, err
+	}
 
 	id, err := domain.KeyIDFromString(keyObj.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &domain.Key{
+	namespace := keyObj.Namespace
+	if namespace == "" {
+		namespace = domain.DefaultNamespace
+	}
+	if !domain.NamespaceVisible(ctx, namespace) {
+		return nil, app_errors.ErrKeyNotFound
+	}
+
+	key := &domain.Key{
 		ID:           id,
+		Namespace:    namespace,
 		EncryptedDEK: keyObj.EncryptedDEK,
 		Metadata:     keyObj.Metadata,
 		Version:      keyObj.Version,
 		Status:       domain.KeyStatus(pk.KeyStatus_name[int32(keyObj.Status)]),
 		CreatedAt:    time.Unix(keyObj.CreatedAt, 0),
 		UpdatedAt:    time.Unix(keyObj.UpdatedAt, 0),
-	}, nil
+		KMSProvider:  keyObj.KMSProvider,
+	}
+	if keyObj.GraceExpiresAt != 0 {
+		graceExpiresAt := time.Unix(keyObj.GraceExpiresAt, 0)
+		key.GraceExpiresAt = &graceExpiresAt
+	}
+	if keyObj.RevokedAt != 0 {
+		revokedAt := time.Unix(keyObj.RevokedAt, 0)
+		key.RevokedAt = &revokedAt
+	}
+	return key, nil
 }
 
 func (s *S3Storage) putKey(ctx context.Context, key *domain.Key) error {
+	data, versionPath, err := s.putVersion(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	latestPath := fmt.Sprintf("keys/%s/latest.json", key.ID.String())
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &s.bucketName,
+		Key:    &latestPath,
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		if _, delErr := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &s.bucketName, Key: &versionPath}); delErr != nil {
+			s.logger.Error("failed to roll back S3 object", "path", versionPath, "error", delErr)
+		}
+		return fmt.Errorf("failed to put latest key object to S3: %w", err)
+	}
+
+	return nil
+}
+
+// putVersion writes the object for a single key version, leaving latest.json untouched.
+func (s *S3Storage) putVersion(ctx context.Context, key *domain.Key) ([]byte, string, error) {
+	if key.Metadata != nil {
+		key.Metadata.MetadataChecksum = s.integrity.Checksum(key.Metadata)
+	}
 	keyObj := s3KeyObject{
 		ID:           key.ID.String(),
+		Namespace:    keyNamespace(ctx, key),
 		EncryptedDEK: key.EncryptedDEK,
 		Metadata:     key.Metadata,
 		Version:      key.Version,
 		Status:       pk.KeyStatus(pk.KeyStatus_value[string(key.Status)]),
 		CreatedAt:    key.CreatedAt.Unix(),
 		UpdatedAt:    key.UpdatedAt.Unix(),
+		KMSProvider:  key.KMSProvider,
+	}
+	if key.GraceExpiresAt != nil {
+		keyObj.GraceExpiresAt = key.GraceExpiresAt.Unix()
+	}
+	if key.RevokedAt != nil {
+		keyObj.RevokedAt = key.RevokedAt.Unix()
 	}
 
 	data, err := json.Marshal(keyObj)
 	if err != nil {
-		return fmt.Errorf("failed to marshal key object: %w", err)
+		return nil, "", fmt.Errorf("failed to marshal key object: %w", err)
 	}
 
 	versionPath := fmt.Sprintf("keys/%s/v%d.json", key.ID.String(), key.Version)
@@ -143,26 +209,12 @@ func (s *S3Storage) putKey(ctx context.Context, key *domain.Key) error {
 		Body:   bytes.NewReader(data),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to put versioned key object to S3: %w", err)
+		return nil, "", fmt.Errorf("failed to put versioned key object to S3: %w", err)
 	}
-
-	latestPath := fmt.Sprintf("keys/%s/latest.json", key.ID.String())
-	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: &s.bucketName,
-		Key:    &latestPath,
-		Body:   bytes.NewReader(data),
-	})
-	if err != nil {
-		if _, delErr := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &s.bucketName, Key: &versionPath}); delErr != nil {
-			s.logger.Error("failed to roll back S3 object", "path", versionPath, "error", delErr)
-		}
-		return fmt.Errorf("failed to put latest key object to S3: %w", err)
-	}
-
-	return nil
+	return data, versionPath, nil
 }
 
-func (s *S3Storage) ListKeys(ctx context.Context, lastCreatedAt *time.Time, limit int) ([]*domain.Key, error) {
+func (s *S3Storage) ListKeys(ctx context.Context, filter domain.KeyFilter, page domain.KeyPage, limit int) ([]*domain.Key, error) {
 	prefix := "keys/"
 	input := &s3.ListObjectsV2Input{
 		Bucket:    &s.bucketName,
@@ -186,11 +238,16 @@ func (s *S3Storage) ListKeys(ctx context.Context, lastCreatedAt *time.Time, limi
 				continue
 			}
 			key, err := s.GetKey(ctx, keyID)
+			if errors.Is(err, app_errors.ErrKeyNotFound) {
+				continue // another namespace's key
+			}
 			if err != nil {
 				s.logger.Error("failed to get key while listing", "keyID", keyID, "error", err)
 				continue
 			}
-			keys = append(keys, key)
+			if filter.Matches(key) {
+				keys = append(keys, key)
+			}
 		}
 	}
 
@@ -204,34 +261,66 @@ func (s *S3Storage) UpdateKeyMetadata(ctx context.Context, id domain.KeyID, meta
 	}
 
 	latestKey.Metadata = metadata
-	latestKey.UpdatedAt = time.Now()
+	latestKey.UpdatedAt = s.clock.Now()
 
 	return s.putKey(ctx, latestKey)
 }
 
-func (s *S3Storage) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte) (*domain.Key, error) {
+// UpdateKeyMetadataIfOwner checks the version and owner before writing. S3 has no
+// conditional write here, so a concurrent update between the read and the write can
+// still be lost.
+func (s *S3Storage) UpdateKeyMetadataIfOwner(ctx context.Context, id domain.KeyID, version int32, owner string, metadata *pk.KeyMetadata) error {
+	latestKey, err := s.GetKey(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get key for update: %w", err)
+	}
+	if latestKey.Version != version || latestKey.Metadata.GetCreatorIdentity() != owner {
+		return fmt.Errorf("%w: key %s changed version or owner since it was read", app_errors.ErrConflict, id.String())
+	}
+
+	latestKey.Metadata = metadata
+	latestKey.UpdatedAt = s.clock.Now()
+
+	return s.putKey(ctx, latestKey)
+}
+
+func (s *S3Storage) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte, graceDeadline time.Time) (*domain.Key, error) {
 	latestKey, err := s.GetKey(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get key for rotation: %w", err)
 	}
+	if err := domain.ValidateKeyTransition(latestKey.Status, domain.KeyStatusRotated); err != nil {
+		return nil, err
+	}
 
 	newVersion := latestKey.Version + 1
-	now := time.Now()
+	now := s.clock.Now()
 
 	rotatedKey := &domain.Key{
 		ID:           id,
+		Namespace:    latestKey.Namespace,
 		EncryptedDEK: newEncryptedDEK,
 		Metadata:     latestKey.Metadata,
 		Version:      newVersion,
 		Status:       domain.KeyStatusActive,
 		CreatedAt:    now,
 		UpdatedAt:    now,
+		KMSProvider:  latestKey.KMSProvider,
 	}
 
 	if err := s.putKey(ctx, rotatedKey); err != nil {
 		return nil, fmt.Errorf("failed to create new key version during rotation: %w", err)
 	}
 
+	latestKey.Status = domain.KeyStatusRotated
+	latestKey.UpdatedAt = now
+	if !graceDeadline.IsZero() {
+		latestKey.GraceExpiresAt = &graceDeadline
+	}
+	if _, _, err := s.putVersion(ctx, latestKey); err != nil {
+		s.logger.Error("failed to mark previous key version rotated", "keyID", id.String(), "version", latestKey.Version, "error", err)
+	}
+
 	return rotatedKey, nil
 }
 
@@ -240,13 +329,40 @@ func (s *S3Storage) RevokeKey(ctx context.Context, id domain.KeyID) error {
 	if err != nil {
 		return fmt.Errorf("failed to get key for revocation: %w", err)
 	}
+	if err := domain.ValidateKeyTransition(latestKey.Status, domain.KeyStatusRevoked); err != nil {
+		return err
+	}
 
+	now := s.clock.Now()
 	latestKey.Status = domain.KeyStatusRevoked
-	latestKey.UpdatedAt = time.Now()
+	latestKey.UpdatedAt = now
+	latestKey.RevokedAt = &now
 
 	return s.putKey(ctx, latestKey)
 }
 
+func (s *S3Storage) RestoreKey(ctx context.Context, id domain.KeyID, revokedSince time.Time) (*domain.Key, error) {
+	latestKey, err := s.GetKey(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key for restore: %w", err)
+	}
+	if latestKey.Status != domain.KeyStatusRevoked {
+		return nil, &domain.KeyTransitionError{From: latestKey.Status, To: domain.KeyStatusActive}
+	}
+	if latestKey.RevokedAt == nil || latestKey.RevokedAt.Before(revokedSince) {
+		return nil, app_errors.ErrRestoreWindowClosed
+	}
+
+	latestKey.Status = domain.KeyStatusActive
+	latestKey.UpdatedAt = s.clock.Now()
+	latestKey.RevokedAt = nil
+
+	if err := s.putKey(ctx, latestKey); err != nil {
+		return nil, err
+	}
+	return latestKey, nil
+}
+
 func (s *S3Storage) GetKeyVersions(ctx context.Context, id domain.KeyID) ([]*domain.Key, error) {
 	prefix := fmt.Sprintf("keys/%s/v", id.String())
 	input := &s3.ListObjectsV2Input{
@@ -279,7 +395,30 @@ func (s *S3Storage) GetKeyVersions(ctx context.Context, id domain.KeyID) ([]*dom
 	return versions, nil
 }
 
+func (s *S3Storage) CountKeys(ctx context.Context, namespace string) (int, error) {
+	keys, err := s.ListKeys(ctx, domain.KeyFilter{}, domain.KeyPage{}, 0)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, key := range keys {
+		if key.Namespace == namespace && key.Status != domain.KeyStatusDestroyed {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func (s *S3Storage) Exists(ctx context.Context, id domain.KeyID) (bool, error) {
+	if _, scoped := domain.NamespaceFromContext(ctx); scoped {
+		// The namespace is only recorded inside the object, so it has to be read.
+		_, err := s.GetKey(ctx, id)
+		var nsk *types.NoSuchKey
+		if errors.Is(err, app_errors.ErrKeyNotFound) || errors.As(err, &nsk) {
+			return false, nil
+		}
+		return err == nil, err
+	}
 	keyPath := fmt.Sprintf("keys/%s/latest.json", id.String())
 	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: &s.bucketName,
@@ -343,6 +482,107 @@ func (s *S3Storage) UpdateBatchKeyMetadata(ctx context.Context, updates []*domai
 	return nil
 }
 
+// ExpireKeys scans every key, as S3 offers no query on metadata.
+func (s *S3Storage) ExpireKeys(ctx context.Context, asOf time.Time, limit int) ([]*domain.Key, error) {
+	due, err := s.filterActiveByExpiry(ctx, domain.KeyExpiryCursor{}, asOf, limit)
+	if err != nil {
+		return nil, err
+	}
+	expired := make([]*domain.Key, 0, len(due))
+	for _, key := range due {
+		key.Status = domain.KeyStatusExpired
+		key.UpdatedAt = s.clock.Now()
+		if err := s.putKey(ctx, key); err != nil {
+			s.logger.Error("failed to expire key", "keyID", key.ID.String(), "error", err)
+			continue
+		}
+		expired = append(expired, key)
+	}
+	return expired, nil
+}
+
+func (s *S3Storage) ListExpiringKeys(ctx context.Context, after domain.KeyExpiryCursor, to time.Time, limit int) ([]*domain.Key, error) {
+	return s.filterActiveByExpiry(ctx, after, to, limit)
+}
+
+// PruneKeyVersions scans every key, as S3 offers no query on metadata. Archived versions
+// are copied under archive/ before they are deleted.
+func (s *S3Storage) PruneKeyVersions(ctx context.Context, retention domain.KeyVersionRetention, limit int) ([]*domain.Key, error) {
+	keys, err := s.ListKeys(ctx, domain.KeyFilter{}, domain.KeyPage{}, 0)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []*domain.Key
+	for _, latest := range keys {
+		versions, err := s.GetKeyVersions(ctx, latest.ID)
+		if err != nil {
+			return pruned, err
+		}
+		newest := versions[0].Version
+		for _, version := range versions {
+			if len(pruned) >= limit {
+				return pruned, nil
+			}
+			if version.Version > newest-int32(retention.KeepVersions) || !prunable(version, retention.Before) {
+				continue
+			}
+			versionPath := fmt.Sprintf("keys/%s/v%d.json", version.ID.String(), version.Version)
+			if retention.Archive {
+				archivePath := "archive/" + versionPath
+				_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+					Bucket:     &s.bucketName,
+					CopySource: aws.String(s.bucketName + "/" + versionPath),
+					Key:        &archivePath,
+				})
+				if err != nil {
+					return pruned, fmt.Errorf("failed to archive key version in S3: %w", err)
+				}
+			}
+			if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &s.bucketName, Key: &versionPath}); err != nil {
+				return pruned, fmt.Errorf("failed to delete key version from S3: %w", err)
+			}
+			pruned = append(pruned, version)
+		}
+	}
+	return pruned, nil
+}
+
+// prunable reports whether a version went out of service before the cutoff.
+func prunable(key *domain.Key, before time.Time) bool {
+	switch key.Status {
+	case domain.KeyStatusExpired:
+		return !key.UpdatedAt.After(before)
+	case domain.KeyStatusRotated:
+		return key.GraceExpiresAt != nil && !key.GraceExpiresAt.After(before)
+	default:
+		return false
+	}
+}
+
+func (s *S3Storage) filterActiveByExpiry(ctx context.Context, after domain.KeyExpiryCursor, to time.Time, limit int) ([]*domain.Key, error) {
+	keys, err := s.ListKeys(ctx, domain.KeyFilter{}, domain.KeyPage{}, 0)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*domain.Key
+	for _, key := range keys {
+		expiresAt := key.Metadata.GetExpiresAt()
+		if key.Status != domain.KeyStatusActive || expiresAt == nil {
+			continue
+		}
+		if cursor := domain.ExpiryCursorOf(key); after.Before(cursor) && !cursor.ExpiresAt.After(to) {
+			matched = append(matched, key)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return domain.ExpiryCursorOf(matched[i]).Before(domain.ExpiryCursorOf(matched[j]))
+	})
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched, nil
+}
+
 func (s *S3Storage) HealthCheck() error {
 	_, err := s.client.HeadBucket(context.Background(), &s3.HeadBucketInput{
 		Bucket: &s.bucketName,
@@ -10,11 +10,13 @@ import (
 )
 
 // ScanKeyRow scans a single row from a pgx.Row and returns a domain.Key, excluding the ID.
-// This is used for queries where the ID is already known.
-func ScanKeyRow(row pgx.Row) (*domain.Key, error) {
+// This is used for queries where the ID is already known. The metadata is checked against
+// its checksum with integrity.
+func ScanKeyRow(row pgx.Row, integrity *domain.MetadataIntegrity) (*domain.Key, error) {
 	var key domain.Key
 	var metadataRaw []byte
 	var storageType string
+	var kmsProvider *string
 
 	err := row.Scan(
 		&key.Version,
@@ -25,25 +27,36 @@ func ScanKeyRow(row pgx.Row) (*domain.Key, error) {
 		&key.CreatedAt,
 		&key.UpdatedAt,
 		&key.RevokedAt,
+		&key.GraceExpiresAt,
+		&key.Namespace,
+		&kmsProvider,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan key row: %w", err)
 	}
 
+	if kmsProvider != nil {
+		key.KMSProvider = *kmsProvider
+	}
+
 	if err := json.Unmarshal(metadataRaw, &key.Metadata); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
+	if err := verifyMetadata(integrity, key.Metadata); err != nil {
+		return nil, err
+	}
 
 	return &key, nil
 }
 
 // ScanKeyRowWithID scans a single row from a pgx.Row and returns a domain.Key, including the ID.
 // This is used for queries like ListKeys where the ID is part of the result set.
-func ScanKeyRowWithID(row pgx.Row) (*domain.Key, error) {
+func ScanKeyRowWithID(row pgx.Row, integrity *domain.MetadataIntegrity) (*domain.Key, error) {
 	var key domain.Key
 	var id uuid.UUID
 	var metadataRaw []byte
 	var storageType string
+	var kmsProvider *string
 
 	err := row.Scan(
 		&id,
@@ -55,6 +68,9 @@ func ScanKeyRowWithID(row pgx.Row) (*domain.Key, error) {
 		&key.CreatedAt,
 		&key.UpdatedAt,
 		&key.RevokedAt,
+		&key.GraceExpiresAt,
+		&key.Namespace,
+		&kmsProvider,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan key row: %w", err)
@@ -65,9 +81,16 @@ func ScanKeyRowWithID(row pgx.Row) (*domain.Key, error) {
 		return nil, fmt.Errorf("failed to create key id from uuid string: %w", err)
 	}
 
+	if kmsProvider != nil {
+		key.KMSProvider = *kmsProvider
+	}
+
 	if err := json.Unmarshal(metadataRaw, &key.Metadata); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal metadata for key %s: %w", key.ID.String(), err)
 	}
+	if err := verifyMetadata(integrity, key.Metadata); err != nil {
+		return nil, err
+	}
 
 	return &key, nil
 }
@@ -0,0 +1,159 @@
+package persistence
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	consts "github.com/spounge-ai/polykey/internal/constants"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/infra/persistence")
+
+var slowQueries, _ = meter.Int64Counter(
+	"polykey.persistence.slow_queries",
+	metric.WithDescription("Number of database statements that took longer than the slow query threshold."),
+)
+
+// statementNames maps the SQL of the named statements back to their names.
+var statementNames = func() map[string]string {
+	names := make(map[string]string, len(consts.Queries))
+	for name, sql := range consts.Queries {
+		names[sql] = name
+	}
+	return names
+}()
+
+// statementShape picks the verb and first table out of an unnamed statement.
+var statementShape = regexp.MustCompile(`(?is)^\s*(?:with\b.*?\)\s*)?(select|insert|update|delete)\b(?:.*?\b(?:from|into)\b)?\s+([a-z_][a-z0-9_.]*)`)
+
+// SlowQueryTracer is a pgx tracer that logs every statement, batch and copy that takes
+// at least threshold. A statement is identified by its name in constants.Queries or,
+// for inline SQL, by its verb and table; its bound parameters are logged only as
+// truncated SHA-256 digests, so that repeated values can be spotted without key
+// material or identifiers ever reaching the logs.
+type SlowQueryTracer struct {
+	logger    *slog.Logger
+	threshold time.Duration
+}
+
+var (
+	_ pgx.QueryTracer    = (*SlowQueryTracer)(nil)
+	_ pgx.BatchTracer    = (*SlowQueryTracer)(nil)
+	_ pgx.CopyFromTracer = (*SlowQueryTracer)(nil)
+)
+
+func NewSlowQueryTracer(logger *slog.Logger, threshold time.Duration) *SlowQueryTracer {
+	return &SlowQueryTracer{logger: logger, threshold: threshold}
+}
+
+type slowQueryTraceKey struct{}
+
+type slowQueryTrace struct {
+	start     time.Time
+	statement string
+	args      []any
+	queries   int
+}
+
+func (t *SlowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryTraceKey{}, &slowQueryTrace{start: time.Now(), statement: statementName(data.SQL), args: data.Args})
+}
+
+func (t *SlowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.finish(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+func (t *SlowQueryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	trace := &slowQueryTrace{start: time.Now(), statement: "batch", queries: data.Batch.Len()}
+	if data.Batch.Len() > 0 {
+		trace.statement = statementName(data.Batch.QueuedQueries[0].SQL)
+	}
+	return context.WithValue(ctx, slowQueryTraceKey{}, trace)
+}
+
+func (t *SlowQueryTracer) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {}
+
+func (t *SlowQueryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.finish(ctx, -1, data.Err)
+}
+
+func (t *SlowQueryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return context.WithValue(ctx, slowQueryTraceKey{}, &slowQueryTrace{start: time.Now(), statement: "copy_" + strings.Join(data.TableName, ".")})
+}
+
+func (t *SlowQueryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.finish(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+// finish logs the traced statement if it was slow. rows is -1 when unknown.
+func (t *SlowQueryTracer) finish(ctx context.Context, rows int64, err error) {
+	trace, ok := ctx.Value(slowQueryTraceKey{}).(*slowQueryTrace)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(trace.start)
+	if elapsed < t.threshold {
+		return
+	}
+
+	slowQueries.Add(ctx, 1, metric.WithAttributes(attribute.String("statement", trace.statement)))
+	attrs := []any{"statement", trace.statement, "duration", elapsed, "threshold", t.threshold}
+	if len(trace.args) > 0 {
+		attrs = append(attrs, "params", paramDigests(trace.args))
+	}
+	if trace.queries > 0 {
+		attrs = append(attrs, "queries", trace.queries)
+	}
+	if rows >= 0 {
+		attrs = append(attrs, "rows", rows)
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	t.logger.WarnContext(ctx, "slow database query", attrs...)
+}
+
+// statementName returns the name of a statement from constants.Queries, or one made of
+// the verb and table of inline SQL, such as "insert_audit_events".
+func statementName(sql string) string {
+	if name, ok := statementNames[sql]; ok {
+		return name
+	}
+	if m := statementShape.FindStringSubmatch(sql); m != nil {
+		return strings.ToLower(m[1]) + "_" + strings.ToLower(m[2])
+	}
+	return "unnamed"
+}
+
+// paramDigests describes each bound parameter by its type and the first 8 bytes of the
+// SHA-256 of its value.
+func paramDigests(args []any) []string {
+	digests := make([]string, len(args))
+	for i, arg := range args {
+		var value []byte
+		switch v := arg.(type) {
+		case nil:
+			digests[i] = "null"
+			continue
+		case []byte:
+			value = v
+		case string:
+			value = []byte(v)
+		default:
+			value = fmt.Appendf(nil, "%v", v)
+		}
+		sum := sha256.Sum256(value)
+		digests[i] = fmt.Sprintf("%T:%s", arg, hex.EncodeToString(sum[:8]))
+	}
+	return digests
+}
@@ -0,0 +1,112 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/clock"
+)
+
+// TokenStore keeps the access tokens issued to clients, and which of them are revoked,
+// in PostgreSQL, so that revocations hold across replicas and restarts.
+type TokenStore struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+	clock  clock.Clock
+}
+
+var _ domain.TokenSessionStore = (*TokenStore)(nil)
+
+// NewTokenStore creates a TokenStore that expires and revokes tokens at the times clk
+// reports.
+func NewTokenStore(db *pgxpool.Pool, logger *slog.Logger, clk clock.Clock) *TokenStore {
+	return &TokenStore{db: db, logger: logger, clock: clk}
+}
+
+// Revoke marks the token as revoked, recording it when it was not tracked, as for
+// tokens issued before tracking began, so that it stays revoked for ttl.
+func (s *TokenStore) Revoke(ctx context.Context, tokenID string, ttl time.Duration) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	now := s.clock.Now()
+	query := `INSERT INTO issued_tokens (token_id, client_id, expires_at, revoked_at) VALUES ($1, '', $2, $3)
+		ON CONFLICT (token_id) DO UPDATE SET revoked_at = COALESCE(issued_tokens.revoked_at, $3)`
+	if _, err := s.db.Exec(ctx, query, tokenID, now.Add(ttl), now); err != nil {
+		s.logger.ErrorContext(ctx, "failed to revoke token", "token_id", tokenID, "error", err)
+	}
+}
+
+// IsRevoked reports whether the token is revoked. When the store cannot be read, the
+// token is treated as revoked.
+func (s *TokenStore) IsRevoked(ctx context.Context, tokenID string) bool {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	var revoked bool
+	err := s.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM issued_tokens WHERE token_id = $1 AND revoked_at IS NOT NULL)`, tokenID).Scan(&revoked)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to check token revocation", "token_id", tokenID, "error", err)
+		return true
+	}
+	return revoked
+}
+
+// TrackToken records the session, pruning the expired tokens of its client.
+func (s *TokenStore) TrackToken(ctx context.Context, session domain.TokenSession) error {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	query := `WITH pruned AS (DELETE FROM issued_tokens WHERE client_id = $2 AND expires_at < $6)
+		INSERT INTO issued_tokens (token_id, client_id, namespace, issued_at, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := s.db.Exec(ctx, query, session.TokenID, session.ClientID, session.Namespace, session.IssuedAt, session.ExpiresAt, s.clock.Now()); err != nil {
+		return fmt.Errorf("failed to track token of client %s: %w", session.ClientID, err)
+	}
+	return nil
+}
+
+func (s *TokenStore) ListActiveTokens(ctx context.Context, clientID string) ([]domain.TokenSession, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	query := `SELECT token_id, client_id, namespace, issued_at, expires_at FROM issued_tokens
+		WHERE revoked_at IS NULL AND expires_at > $2 AND ($1 = '' OR client_id = $1)
+		ORDER BY issued_at, token_id`
+	rows, err := s.db.Query(ctx, query, clientID, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active tokens: %w", err)
+	}
+	return scanTokenSessions(rows)
+}
+
+func (s *TokenStore) RevokeAllForClient(ctx context.Context, clientID string) ([]domain.TokenSession, error) {
+	ctx, cancel := withQueryTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
+	query := `UPDATE issued_tokens SET revoked_at = $2
+		WHERE client_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		RETURNING token_id, client_id, namespace, issued_at, expires_at`
+	rows, err := s.db.Query(ctx, query, clientID, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke tokens of client %s: %w", clientID, err)
+	}
+	return scanTokenSessions(rows)
+}
+
+func scanTokenSessions(rows pgx.Rows) ([]domain.TokenSession, error) {
+	defer rows.Close()
+	var sessions []domain.TokenSession
+	for rows.Next() {
+		var session domain.TokenSession
+		if err := rows.Scan(&session.TokenID, &session.ClientID, &session.Namespace, &session.IssuedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
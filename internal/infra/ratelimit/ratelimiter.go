@@ -1,7 +1,9 @@
 package ratelimit
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
@@ -10,30 +12,49 @@ import (
 // This allows for different implementations (e.g., in-memory, distributed).
 type Limiter interface {
 	// Allow checks if a request is allowed for a given identifier (e.g., client ID).
-	Allow(identifier string) bool
+	Allow(ctx context.Context, identifier string) bool
+	// Configure turns limiting on or off and changes the rate and burst of every
+	// identifier, including those already seen.
+	Configure(enabled bool, r rate.Limit, b int)
+	// RetryAfter returns how long a refused identifier waits at most before its next
+	// request is allowed: the time its bucket takes to earn one token.
+	RetryAfter() time.Duration
 }
 
+var (
+	_ Limiter = (*InMemoryRateLimiter)(nil)
+	_ Limiter = (*RedisRateLimiter)(nil)
+)
+
 // NewInMemoryRateLimiter creates a new in-memory rate limiter.
 // It creates a new limiter for each identifier with the given rate and burst size.
-func NewInMemoryRateLimiter(r rate.Limit, b int) Limiter {
-	return &inMemoryRateLimiter{
-		rate:      r,
-		burst:     b,
-		clients:   make(map[string]*rate.Limiter),
+func NewInMemoryRateLimiter(r rate.Limit, b int) *InMemoryRateLimiter {
+	return &InMemoryRateLimiter{
+		enabled: true,
+		rate:    r,
+		burst:   b,
+		clients: make(map[string]*rate.Limiter),
 	}
 }
 
-type inMemoryRateLimiter struct {
-	rate      rate.Limit
-	burst     int
-	clients   map[string]*rate.Limiter
-	mu        sync.Mutex
+// InMemoryRateLimiter keeps a token bucket per identifier. Its limits can be changed
+// while it is in use.
+type InMemoryRateLimiter struct {
+	enabled bool
+	rate    rate.Limit
+	burst   int
+	clients map[string]*rate.Limiter
+	mu      sync.Mutex
 }
 
-func (l *inMemoryRateLimiter) Allow(identifier string) bool {
+func (l *InMemoryRateLimiter) Allow(_ context.Context, identifier string) bool {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if !l.enabled {
+		return true
+	}
+
 	limiter, exists := l.clients[identifier]
 	if !exists {
 		limiter = rate.NewLimiter(l.rate, l.burst)
@@ -42,3 +63,39 @@ func (l *inMemoryRateLimiter) Allow(identifier string) bool {
 
 	return limiter.Allow()
 }
+
+// Configure turns limiting on or off and applies the rate and burst to every
+// identifier, including those already seen.
+func (l *InMemoryRateLimiter) Configure(enabled bool, r rate.Limit, b int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.enabled, l.rate, l.burst = enabled, r, b
+	for _, limiter := range l.clients {
+		limiter.SetLimit(r)
+		limiter.SetBurst(b)
+	}
+}
+
+// RetryAfter returns the time a bucket takes to earn one token at the current rate.
+func (l *InMemoryRateLimiter) RetryAfter() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabled {
+		return 0
+	}
+	return emissionInterval(l.rate)
+}
+
+// emissionInterval returns the time between two tokens at rate r: none for an infinite
+// rate, and idleEmission for a zero rate.
+func emissionInterval(r rate.Limit) time.Duration {
+	switch {
+	case r == rate.Inf:
+		return 0
+	case r <= 0:
+		return idleEmission
+	default:
+		return time.Duration(float64(time.Second) / float64(r))
+	}
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+)
+
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/infra/ratelimit")
+
+var redisFallbacks, _ = meter.Int64Counter(
+	"polykey.ratelimit.redis_fallbacks",
+	metric.WithDescription("Number of rate limit checks decided by the replica's own limiter because Redis did not answer."),
+)
+
+// gcraScript is the generic cell rate algorithm: the key holds the theoretical arrival
+// time, in microseconds of the Redis clock, at which the bucket is full again. A request
+// is allowed when pushing it by one emission interval keeps it within burst intervals
+// of now. Using the server's clock keeps replicas with skewed clocks consistent.
+var gcraScript = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local next_tat = tat + emission
+if next_tat - now > burst * emission then
+	return 0
+end
+redis.call('SET', KEYS[1], string.format('%.0f', next_tat), 'PX', math.ceil((next_tat - now) / 1000))
+return 1
+`)
+
+// idleEmission is the emission interval used for a zero rate: the burst is allowed, and
+// then nothing for a day, much as a token bucket that never refills.
+const idleEmission = 24 * time.Hour
+
+// fallbackWarnInterval bounds how often falling back to the local limiter is logged.
+const fallbackWarnInterval = time.Minute
+
+// RedisRateLimiter keeps one bucket per identifier in Redis, shared by every replica,
+// so that the configured rate holds for the service as a whole rather than for each
+// replica. When Redis does not answer within the client's timeout, the check falls back
+// to an InMemoryRateLimiter with the same limits, which keeps a bound on each replica
+// without failing requests for an outage of the limiter.
+type RedisRateLimiter struct {
+	client   redis.UniversalClient
+	prefix   string
+	fallback *InMemoryRateLimiter
+	logger   *slog.Logger
+	lastWarn atomic.Int64
+
+	mu      sync.RWMutex
+	enabled bool
+	rate    rate.Limit
+	burst   int
+}
+
+// NewRedisRateLimiter creates a limiter keeping its buckets in client under keys
+// starting with prefix.
+func NewRedisRateLimiter(client redis.UniversalClient, prefix string, r rate.Limit, b int, logger *slog.Logger) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client:   client,
+		prefix:   prefix + "ratelimit:",
+		fallback: NewInMemoryRateLimiter(r, b),
+		logger:   logger,
+		enabled:  true,
+		rate:     r,
+		burst:    b,
+	}
+}
+
+func (l *RedisRateLimiter) Allow(ctx context.Context, identifier string) bool {
+	l.mu.RLock()
+	enabled, r, b := l.enabled, l.rate, l.burst
+	l.mu.RUnlock()
+	if !enabled || r == rate.Inf {
+		return true
+	}
+
+	emission := emissionInterval(r)
+	allowed, err := gcraScript.Run(ctx, l.client, []string{l.prefix + identifier}, max(emission.Microseconds(), 1), b).Int()
+	if err != nil {
+		redisFallbacks.Add(context.WithoutCancel(ctx), 1)
+		if now := time.Now().UnixNano(); now-l.lastWarn.Load() >= int64(fallbackWarnInterval) {
+			l.lastWarn.Store(now)
+			l.logger.WarnContext(ctx, "redis rate limiter unavailable, limiting per replica", "error", err)
+		}
+		return l.fallback.Allow(ctx, identifier)
+	}
+	return allowed == 1
+}
+
+// Configure turns limiting on or off and applies the rate and burst to every
+// identifier, the fallback's included. Buckets in Redis take the new limits from their
+// next check.
+func (l *RedisRateLimiter) Configure(enabled bool, r rate.Limit, b int) {
+	l.mu.Lock()
+	l.enabled, l.rate, l.burst = enabled, r, b
+	l.mu.Unlock()
+	l.fallback.Configure(enabled, r, b)
+}
+
+// RetryAfter returns the time a bucket takes to earn one token at the current rate.
+func (l *RedisRateLimiter) RetryAfter() time.Duration {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if !l.enabled {
+		return 0
+	}
+	return emissionInterval(l.rate)
+}
+
+// HealthCheck pings Redis.
+func (l *RedisRateLimiter) HealthCheck(ctx context.Context) error {
+	return l.client.Ping(ctx).Err()
+}
+
+// Close closes the Redis client.
+func (l *RedisRateLimiter) Close() error {
+	return l.client.Close()
+}
@@ -0,0 +1,46 @@
+package secrets
+
+import (
+	"context"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/secrets"
+	"github.com/spounge-ai/polykey/pkg/cache"
+)
+
+// CachedProvider decorates a BootstrapSecretProvider with a cache, so that reloads of
+// the dynamic config values do not call the provider more often than once per TTL.
+type CachedProvider struct {
+	provider secrets.BootstrapSecretProvider
+	cache    cache.Store[string, string]
+}
+
+var _ secrets.BootstrapSecretProvider = (*CachedProvider)(nil)
+
+// NewCachedProvider caches the secrets provider returns for ttl.
+func NewCachedProvider(provider secrets.BootstrapSecretProvider, ttl time.Duration) *CachedProvider {
+	return &CachedProvider{
+		provider: provider,
+		cache: cache.New[string, string](
+			cache.WithDefaultTTL[string, string](ttl),
+			cache.WithCleanupInterval[string, string](ttl),
+		),
+	}
+}
+
+func (p *CachedProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	if value, ok := p.cache.Get(ctx, name); ok {
+		return value, nil
+	}
+	value, err := p.provider.GetSecret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	p.cache.Set(ctx, name, value, 0)
+	return value, nil
+}
+
+// Invalidate drops the cached secrets, so that the next reads go to the provider.
+func (p *CachedProvider) Invalidate(ctx context.Context) {
+	p.cache.Clear(ctx)
+}
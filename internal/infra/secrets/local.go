@@ -0,0 +1,134 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// LocalSecretsKeyEnv holds the base64 AES-256 key of a sealed local secrets file.
+	LocalSecretsKeyEnv = "POLYKEY_LOCAL_SECRETS_KEY"
+	// localSecretEnvPrefix starts the environment variables that hold local secrets.
+	localSecretEnvPrefix = "POLYKEY_SECRET_"
+	// sealedHeader is the first line of a sealed secrets file.
+	sealedHeader = "POLYKEY-SEALED-SECRETS v1\n"
+)
+
+// Local reads secrets from environment variables and a local file, for development
+// without a cloud secret store. A secret at path polykey/kms/polykey_master_key, relative
+// to the base path, is read from POLYKEY_SECRET_POLYKEY_KMS_POLYKEY_MASTER_KEY or else
+// from the file, a YAML map from relative paths to values that may be sealed with
+// SealSecrets. Secrets found in neither are empty, leaving config validation to reject
+// missing required ones.
+type Local struct {
+	basePath string
+	file     map[string]string
+}
+
+// NewLocal creates a Local provider for secrets under basePath. file may be empty; a
+// sealed file is opened with the key in LocalSecretsKeyEnv.
+func NewLocal(basePath, file string) (*Local, error) {
+	l := &Local{basePath: strings.TrimRight(basePath, "/") + "/", file: map[string]string{}}
+	if file == "" {
+		return l, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local secrets file: %w", err)
+	}
+	if bytes.HasPrefix(content, []byte(sealedHeader)) {
+		key, err := base64.StdEncoding.DecodeString(os.Getenv(LocalSecretsKeyEnv))
+		if err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("local secrets file %s is sealed; set %s to its base64 key", file, LocalSecretsKeyEnv)
+		}
+		if content, err = OpenSecrets(content, key); err != nil {
+			return nil, err
+		}
+	}
+	if err := yaml.Unmarshal(content, &l.file); err != nil {
+		return nil, fmt.Errorf("failed to parse local secrets file: %w", err)
+	}
+	return l, nil
+}
+
+func (l *Local) GetSecret(_ context.Context, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("secret name cannot be empty")
+	}
+	path := strings.TrimPrefix(name, l.basePath)
+	if value, ok := os.LookupEnv(LocalSecretEnvVar(path)); ok {
+		return value, nil
+	}
+	return l.file[path], nil
+}
+
+// LocalSecretEnvVar returns the environment variable a Local provider reads the secret
+// at path from.
+func LocalSecretEnvVar(path string) string {
+	return localSecretEnvPrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.Trim(path, "/"))
+}
+
+// SealSecrets encrypts a secrets file with AES-256-GCM under key.
+func SealSecrets(plaintext, key []byte) ([]byte, error) {
+	gcm, err := newSecretsGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(sealedHeader))
+	return []byte(sealedHeader + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// OpenSecrets decrypts a secrets file sealed with SealSecrets.
+func OpenSecrets(sealed, key []byte) ([]byte, error) {
+	gcm, err := newSecretsGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(string(sealed), sealedHeader)))
+	if err != nil {
+		return nil, fmt.Errorf("malformed sealed secrets file: %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("malformed sealed secrets file: too short")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(sealedHeader))
+	if err != nil {
+		return nil, errors.New("failed to open sealed secrets file: wrong key or corrupted file")
+	}
+	return plaintext, nil
+}
+
+func newSecretsGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secrets file key must be 32 bytes, not %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,42 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// SecretsManager reads secrets from AWS Secrets Manager. Secret names are the secret
+// paths without their leading slash, and always resolve to the current version, so a
+// rotated secret is picked up on the next read.
+type SecretsManager struct {
+	client *secretsmanager.Client
+}
+
+func NewSecretsManager(cfg aws.Config) *SecretsManager {
+	return &SecretsManager{client: secretsmanager.NewFromConfig(cfg)}
+}
+
+func (sm *SecretsManager) GetSecret(ctx context.Context, name string) (string, error) {
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		return "", fmt.Errorf("secret name cannot be empty")
+	}
+
+	result, err := sm.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: &name})
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case result.SecretString != nil:
+		return *result.SecretString, nil
+	case result.SecretBinary != nil:
+		return string(result.SecretBinary), nil
+	default:
+		return "", fmt.Errorf("secret %s has no value", name)
+	}
+}
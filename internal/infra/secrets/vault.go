@@ -0,0 +1,224 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// Vault auth methods.
+const (
+	VaultAuthToken      = "token"
+	VaultAuthAppRole    = "approle"
+	VaultAuthKubernetes = "kubernetes"
+)
+
+const (
+	defaultVaultKVMount      = "secret"
+	defaultVaultField        = "value"
+	defaultKubernetesJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// transitCiphertextPrefix starts every ciphertext the transit engine produces.
+	transitCiphertextPrefix = "vault:v"
+)
+
+// VaultConfig holds the settings of a Vault-backed secret provider.
+type VaultConfig struct {
+	Address   string
+	Namespace string
+	CACert    string
+	// AuthMethod is token, approle or kubernetes. Token is used by the token method;
+	// with it unset, the VAULT_TOKEN environment variable is.
+	AuthMethod   string
+	Token        string
+	RoleID       string
+	SecretID     string
+	SecretIDFile string
+	// Role and JWTPath are used by the kubernetes method; JWTPath defaults to the
+	// service account token of the pod.
+	Role    string
+	JWTPath string
+	// AuthMount is the mount of the auth method, by default its name.
+	AuthMount string
+	// KVMount is the KV secrets engine the secrets are read from, and KVVersion 1 or 2.
+	// Each secret is the Field of the KV secret at its path.
+	KVMount   string
+	KVVersion int
+	Field     string
+	// TransitKey, when set, decrypts values stored as transit ciphertexts with this key
+	// of the transit engine at TransitMount, so that KV holds no plaintext.
+	TransitMount string
+	TransitKey   string
+}
+
+// Vault reads bootstrap secrets from a Vault KV engine, decrypting values stored as
+// transit ciphertexts. The secret name is the path within the KV mount.
+type Vault struct {
+	client *vault.Client
+	cfg    VaultConfig
+
+	mu sync.Mutex // Serializes logins
+}
+
+// NewVault creates a Vault secret provider and logs in with the configured method.
+func NewVault(ctx context.Context, cfg VaultConfig) (*Vault, error) {
+	if cfg.KVMount == "" {
+		cfg.KVMount = defaultVaultKVMount
+	}
+	if cfg.KVVersion == 0 {
+		cfg.KVVersion = 2
+	}
+	if cfg.Field == "" {
+		cfg.Field = defaultVaultField
+	}
+	if cfg.TransitMount == "" {
+		cfg.TransitMount = "transit"
+	}
+	if cfg.AuthMount == "" {
+		cfg.AuthMount = cfg.AuthMethod
+	}
+
+	clientCfg := vault.DefaultConfig()
+	if cfg.Address != "" {
+		clientCfg.Address = cfg.Address
+	}
+	if cfg.CACert != "" {
+		if err := clientCfg.ConfigureTLS(&vault.TLSConfig{CACert: cfg.CACert}); err != nil {
+			return nil, fmt.Errorf("failed to configure vault TLS: %w", err)
+		}
+	}
+	client, err := vault.NewClient(clientCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault client: %w", err)
+	}
+	if cfg.Namespace != "" {
+		client.SetNamespace(cfg.Namespace)
+	}
+
+	v := &Vault{client: client, cfg: cfg}
+	if err := v.login(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *Vault) GetSecret(ctx context.Context, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("secret name cannot be empty")
+	}
+
+	value, err := v.read(ctx, name)
+	// AppRole and Kubernetes tokens expire; log in again once and retry.
+	if isVaultPermissionDenied(err) && v.cfg.AuthMethod != VaultAuthToken {
+		if err = v.login(ctx); err == nil {
+			value, err = v.read(ctx, name)
+		}
+	}
+	return value, err
+}
+
+func (v *Vault) read(ctx context.Context, name string) (string, error) {
+	path := strings.Trim(name, "/")
+	var secret *vault.KVSecret
+	var err error
+	if v.cfg.KVVersion == 1 {
+		secret, err = v.client.KVv1(v.cfg.KVMount).Get(ctx, path)
+	} else {
+		secret, err = v.client.KVv2(v.cfg.KVMount).Get(ctx, path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secret.Data[v.cfg.Field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no string field %q", path, v.cfg.Field)
+	}
+	if v.cfg.TransitKey != "" && strings.HasPrefix(value, transitCiphertextPrefix) {
+		return v.decrypt(ctx, value)
+	}
+	return value, nil
+}
+
+// decrypt decrypts a transit ciphertext with the configured key.
+func (v *Vault) decrypt(ctx context.Context, ciphertext string) (string, error) {
+	path := fmt.Sprintf("%s/decrypt/%s", v.cfg.TransitMount, v.cfg.TransitKey)
+	secret, err := v.client.Logical().WriteWithContext(ctx, path, map[string]any{"ciphertext": ciphertext})
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt with vault transit key %s: %w", v.cfg.TransitKey, err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("vault transit returned no plaintext")
+	}
+	encoded, _ := secret.Data["plaintext"].(string)
+	plaintext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode vault transit plaintext: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// login sets the client token for the configured auth method.
+func (v *Vault) login(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var data map[string]any
+	switch v.cfg.AuthMethod {
+	case VaultAuthToken, "":
+		token := v.cfg.Token
+		if token == "" {
+			token = os.Getenv("VAULT_TOKEN")
+		}
+		if token == "" {
+			return fmt.Errorf("vault token auth requires a token")
+		}
+		v.client.SetToken(token)
+		return nil
+	case VaultAuthAppRole:
+		secretID := v.cfg.SecretID
+		if v.cfg.SecretIDFile != "" {
+			content, err := os.ReadFile(v.cfg.SecretIDFile)
+			if err != nil {
+				return fmt.Errorf("failed to read vault secret id: %w", err)
+			}
+			secretID = strings.TrimSpace(string(content))
+		}
+		data = map[string]any{"role_id": v.cfg.RoleID, "secret_id": secretID}
+	case VaultAuthKubernetes:
+		jwtPath := v.cfg.JWTPath
+		if jwtPath == "" {
+			jwtPath = defaultKubernetesJWTPath
+		}
+		jwt, err := os.ReadFile(jwtPath)
+		if err != nil {
+			return fmt.Errorf("failed to read kubernetes service account token: %w", err)
+		}
+		data = map[string]any{"role": v.cfg.Role, "jwt": strings.TrimSpace(string(jwt))}
+	default:
+		return fmt.Errorf("unsupported vault auth method %q", v.cfg.AuthMethod)
+	}
+
+	// Logging in must not send an expired token along.
+	v.client.ClearToken()
+	secret, err := v.client.Logical().WriteWithContext(ctx, "auth/"+v.cfg.AuthMount+"/login", data)
+	if err != nil {
+		return fmt.Errorf("vault %s login failed: %w", v.cfg.AuthMethod, err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return fmt.Errorf("vault %s login returned no token", v.cfg.AuthMethod)
+	}
+	v.client.SetToken(secret.Auth.ClientToken)
+	return nil
+}
+
+func isVaultPermissionDenied(err error) bool {
+	var respErr *vault.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusForbidden
+}
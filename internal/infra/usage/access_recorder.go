@@ -0,0 +1,184 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/clock"
+)
+
+const (
+	defaultFlushInterval  = 10 * time.Second
+	defaultMaxPendingKeys = 10000
+)
+
+// AccessRecorderConfig holds the configuration for the access recorder.
+type AccessRecorderConfig struct {
+	FlushInterval  time.Duration
+	MaxPendingKeys int
+}
+
+// AccessRecorder aggregates key reads in memory and periodically adds them to the
+// stored AccessCount and LastAccessedAt, so a hot key costs one row update per flush
+// rather than one per read.
+type AccessRecorder struct {
+	repo   domain.KeyAccessRepository
+	logger *slog.Logger
+	cfg    AccessRecorderConfig
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	pending map[domain.KeyID]*domain.KeyAccess
+	dropped int64
+
+	flushNow    chan struct{}
+	stop        chan struct{}
+	done        chan struct{}
+	startOnce   sync.Once
+	stopOnce    sync.Once
+	started     atomic.Bool
+	writeFailed atomic.Bool
+}
+
+// NewAccessRecorder creates a new AccessRecorder taking the read times from clk. Call
+// Start to begin flushing.
+func NewAccessRecorder(repo domain.KeyAccessRepository, logger *slog.Logger, cfg AccessRecorderConfig, clk clock.Clock) *AccessRecorder {
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.MaxPendingKeys <= 0 {
+		cfg.MaxPendingKeys = defaultMaxPendingKeys
+	}
+	return &AccessRecorder{
+		repo:     repo,
+		logger:   logger,
+		cfg:      cfg,
+		clock:    clk,
+		pending:  make(map[domain.KeyID]*domain.KeyAccess),
+		flushNow: make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// RecordAccess counts one read of the key. Once MaxPendingKeys distinct keys are
+// waiting an early flush is requested, and reads of further keys are dropped until it
+// has run.
+func (r *AccessRecorder) RecordAccess(id domain.KeyID) {
+	now := r.clock.Now().UTC()
+
+	r.mu.Lock()
+	access, ok := r.pending[id]
+	if !ok {
+		if len(r.pending) >= r.cfg.MaxPendingKeys {
+			r.dropped++
+			r.mu.Unlock()
+			r.requestFlush()
+			return
+		}
+		access = &domain.KeyAccess{KeyID: id}
+		r.pending[id] = access
+	}
+	access.Count++
+	access.LastAccessedAt = now
+	full := len(r.pending) >= r.cfg.MaxPendingKeys
+	r.mu.Unlock()
+
+	if full {
+		r.requestFlush()
+	}
+}
+
+func (r *AccessRecorder) requestFlush() {
+	select {
+	case r.flushNow <- struct{}{}:
+	default:
+	}
+}
+
+// Start begins the background flush loop.
+func (r *AccessRecorder) Start() {
+	r.startOnce.Do(func() {
+		r.started.Store(true)
+		go r.run()
+	})
+}
+
+// Stop ends the flush loop and writes whatever is still pending.
+func (r *AccessRecorder) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		if r.started.Load() {
+			<-r.done
+		} else {
+			r.flush()
+		}
+	})
+}
+
+func (r *AccessRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			r.flush()
+			return
+		case <-ticker.C:
+			r.flush()
+		case <-r.flushNow:
+			r.flush()
+		}
+	}
+}
+
+// flush swaps out the pending set and writes it in one batch. A failed batch is
+// logged and discarded: losing a few counts is preferable to growing without bound
+// while the database is unavailable.
+func (r *AccessRecorder) flush() {
+	r.mu.Lock()
+	if len(r.pending) == 0 && r.dropped == 0 {
+		r.mu.Unlock()
+		return
+	}
+	pending := r.pending
+	dropped := r.dropped
+	r.pending = make(map[domain.KeyID]*domain.KeyAccess, len(pending))
+	r.dropped = 0
+	r.mu.Unlock()
+
+	if dropped > 0 {
+		r.logger.Warn("key access recorder was full, reads were not counted", "dropped", dropped)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	accesses := make([]domain.KeyAccess, 0, len(pending))
+	for _, access := range pending {
+		accesses = append(accesses, *access)
+	}
+
+	if err := r.repo.RecordKeyAccesses(context.Background(), accesses); err != nil {
+		r.writeFailed.Store(true)
+		r.logger.Error("failed to write key access statistics", "error", err, "keys", len(accesses))
+		return
+	}
+	r.writeFailed.Store(false)
+}
+
+// HealthCheck reports an error when the last flush failed.
+func (r *AccessRecorder) HealthCheck(ctx context.Context) error {
+	if r.writeFailed.Load() {
+		return errors.New("last key access flush failed")
+	}
+	return nil
+}
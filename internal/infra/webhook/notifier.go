@@ -0,0 +1,310 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	cts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+)
+
+// Event types delivered to webhooks. Key events are named after their domain.KeyEventType.
+const (
+	EventKeyCreated        = "key." + string(domain.KeyEventCreated)
+	EventKeyRotated        = "key." + string(domain.KeyEventRotated)
+	EventKeyRevoked        = "key." + string(domain.KeyEventRevoked)
+	EventKeyRestored       = "key." + string(domain.KeyEventRestored)
+	EventKeyExpired        = "key." + string(domain.KeyEventExpired)
+	EventKeyVersionExpired = "key." + string(domain.KeyEventVersionExpired)
+	EventKeyExpiring       = "key." + string(domain.KeyEventExpiring)
+	EventAuthzDenied       = "authz.denied"
+)
+
+// Headers set on every delivery. The signature is the hex HMAC-SHA256, keyed with the
+// endpoint secret, of the timestamp header value, a ".", and the request body.
+const (
+	HeaderEvent     = "X-Polykey-Event"
+	HeaderDelivery  = "X-Polykey-Delivery"
+	HeaderTimestamp = "X-Polykey-Timestamp"
+	HeaderSignature = "X-Polykey-Signature"
+)
+
+// Payload is the JSON body of a webhook delivery.
+type Payload struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	KeyID         string    `json:"key_id,omitempty"`
+	Namespace     string    `json:"namespace,omitempty"`
+	Version       int32     `json:"version,omitempty"`
+	Actor         string    `json:"actor,omitempty"`
+	Operation     string    `json:"operation,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+}
+
+// defaultShutdownTimeout is how long Close waits for deliveries when the configuration
+// sets no ShutdownTimeout.
+const defaultShutdownTimeout = 10 * time.Second
+
+type delivery struct {
+	endpoint config.WebhookEndpointConfig
+	eventID  string
+	event    string
+	body     []byte
+}
+
+// Notifier sends signed webhook notifications for key lifecycle events and authorization
+// denials. Deliveries are queued and sent by background workers, retrying with
+// exponential backoff; a notification that is still failing after the configured
+// retries, that finds the queue full, or that is still undelivered ShutdownTimeout
+// after Close, is dropped and logged.
+//
+// Key events come from a KeyEventSubscriber passed to Start. Denials arrive through the
+// audit pipeline, which is why Notifier is also a domain.AuditSink.
+type Notifier struct {
+	cfg      config.WebhooksConfig
+	client   *http.Client
+	logger   *slog.Logger
+	scopes   map[string]bool
+	queue    chan delivery
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+	// ctx bounds the deliveries; Close cancels it once ShutdownTimeout has passed.
+	ctx        context.Context
+	stopWorker context.CancelFunc
+	// cancel releases the key event subscription and forwarded is closed once the
+	// forwarding goroutine has drained it; both are nil until Start.
+	cancel    func()
+	forwarded chan struct{}
+}
+
+var _ domain.AuditSink = (*Notifier)(nil)
+
+// NewNotifier validates the endpoints and creates a Notifier. A nil client gets a default
+// one bounded by cfg.Timeout.
+func NewNotifier(logger *slog.Logger, cfg config.WebhooksConfig, client *http.Client) (*Notifier, error) {
+	for _, endpoint := range cfg.Endpoints {
+		u, err := url.Parse(endpoint.URL)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %q has an invalid url: %w", endpoint.Name, err)
+		}
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("webhook %q must use https", endpoint.Name)
+		}
+		if endpoint.Secret == "" {
+			return nil, fmt.Errorf("webhook %q requires a signing secret", endpoint.Name)
+		}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+	workers := max(cfg.Workers, 1)
+	queueSize := max(cfg.QueueSize, 1)
+
+	scopes := make(map[string]bool, len(cts.MethodScopes))
+	for _, scope := range cts.MethodScopes {
+		scopes[scope] = true
+	}
+
+	ctx, stopWorker := context.WithCancel(context.Background())
+	n := &Notifier{
+		cfg:        cfg,
+		client:     client,
+		logger:     logger,
+		scopes:     scopes,
+		queue:      make(chan delivery, queueSize),
+		ctx:        ctx,
+		stopWorker: stopWorker,
+	}
+	n.wg.Add(workers)
+	for range workers {
+		go n.worker()
+	}
+	return n, nil
+}
+
+// Start forwards key events from the subscriber to the webhooks until Close is called.
+func (n *Notifier) Start(subscriber domain.KeyEventSubscriber) {
+	events, cancel := subscriber.Subscribe(context.Background())
+	n.cancel = cancel
+	n.forwarded = make(chan struct{})
+	go func() {
+		defer close(n.forwarded)
+		for event := range events {
+			n.notify(Payload{
+				ID:            uuid.New().String(),
+				Type:          "key." + string(event.Type),
+				OccurredAt:    event.OccurredAt,
+				KeyID:         event.KeyID,
+				Namespace:     event.Namespace,
+				Version:       event.Version,
+				Actor:         event.Actor,
+				CorrelationID: event.CorrelationID,
+			})
+		}
+	}()
+}
+
+func (n *Notifier) Name() string {
+	return "webhook"
+}
+
+// Publish notifies the webhooks of the authorization denials among the audit events. The
+// authorizer audits its decisions under the requested scope, which is how they are told
+// apart from the audit entries of the operations themselves.
+func (n *Notifier) Publish(_ context.Context, events []*domain.AuditEvent) error {
+	for _, event := range events {
+		if event.Success || !n.scopes[event.Operation] {
+			continue
+		}
+		n.notify(Payload{
+			ID:            event.ID,
+			Type:          EventAuthzDenied,
+			OccurredAt:    event.Timestamp,
+			KeyID:         event.KeyID,
+			Actor:         event.ClientIdentity,
+			Operation:     event.Operation,
+			Reason:        event.Error,
+			CorrelationID: event.CorrelationID,
+		})
+	}
+	return nil
+}
+
+func (n *Notifier) notify(payload Payload) {
+	var body []byte
+	for _, endpoint := range n.cfg.Endpoints {
+		if len(endpoint.Events) > 0 && !slices.Contains(endpoint.Events, payload.Type) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(payload); err != nil {
+				n.logger.Error("failed to marshal webhook payload", "type", payload.Type, "error", err)
+				return
+			}
+		}
+		select {
+		case n.queue <- delivery{endpoint: endpoint, eventID: payload.ID, event: payload.Type, body: body}:
+		default:
+			n.logger.Warn("webhook queue is full, dropping notification", "webhook", endpoint.Name, "type", payload.Type)
+		}
+	}
+}
+
+func (n *Notifier) worker() {
+	defer n.wg.Done()
+	for d := range n.queue {
+		if n.ctx.Err() != nil {
+			n.logger.Warn("webhook notifier shut down, dropping notification", "webhook", d.endpoint.Name, "type", d.event, "delivery", d.eventID)
+			continue
+		}
+		if err := n.deliverWithRetry(n.ctx, d); err != nil {
+			n.logger.Error("webhook delivery failed", "webhook", d.endpoint.Name, "type", d.event, "delivery", d.eventID, "error", err)
+		}
+	}
+}
+
+func (n *Notifier) deliverWithRetry(ctx context.Context, d delivery) error {
+	backoff := n.cfg.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := n.deliver(ctx, d)
+		if err == nil || attempt >= n.cfg.MaxRetries {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff *= 2
+		if n.cfg.MaxBackoff > 0 {
+			backoff = min(backoff, n.cfg.MaxBackoff)
+		}
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, d delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.event)
+	req.Header.Set(HeaderDelivery, d.eventID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(d.endpoint.Secret, timestamp, d.body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("webhook responded with " + resp.Status)
+	}
+	return nil
+}
+
+// Sign computes the signature of a delivery, for receivers verifying X-Polykey-Signature.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Close stops listening for key events and waits up to ShutdownTimeout for queued
+// deliveries to finish. The deliveries in progress are then cancelled, and those left
+// in the queue dropped and logged.
+func (n *Notifier) Close() error {
+	n.stopOnce.Do(func() {
+		if n.cancel != nil {
+			n.cancel()
+			<-n.forwarded
+		}
+		close(n.queue)
+		done := make(chan struct{})
+		go func() {
+			n.wg.Wait()
+			close(done)
+		}()
+		timer := time.NewTimer(n.cfg.ShutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+			n.logger.Warn("webhook deliveries did not finish in time, dropping the rest", "queued", len(n.queue))
+			n.stopWorker()
+			<-done
+		}
+		n.stopWorker()
+	})
+	return nil
+}
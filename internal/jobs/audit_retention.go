@@ -0,0 +1,219 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+)
+
+const (
+	defaultAuditHotRetention   = 90 * 24 * time.Hour
+	defaultAuditRetentionEvery = time.Hour
+	defaultAuditArchiveBatch   = 10000
+)
+
+// AuditRetentionJob keeps the audit table small. Each sweep moves events older than the
+// hot retention into the archive store, oldest first and one object per batch, and
+// removes events restored for an investigation once their restore has ended. Events are
+// deleted only after their archive is stored and recorded.
+type AuditRetentionJob struct {
+	repo   domain.AuditRepository
+	store  domain.AuditArchiveStore
+	logger *slog.Logger
+	cfg    config.AuditRetentionConfig
+	clock  clock.Clock
+
+	leaderGate
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	lastErr error
+}
+
+// NewAuditRetentionJob creates a new AuditRetentionJob.
+func NewAuditRetentionJob(repo domain.AuditRepository, store domain.AuditArchiveStore, logger *slog.Logger, cfg config.AuditRetentionConfig, clk clock.Clock) *AuditRetentionJob {
+	if cfg.HotRetention <= 0 {
+		cfg.HotRetention = defaultAuditHotRetention
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultAuditRetentionEvery
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultAuditArchiveBatch
+	}
+	return &AuditRetentionJob{
+		repo:   repo,
+		store:  store,
+		logger: logger,
+		cfg:    cfg,
+		clock:  clk,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start runs the job in the background until Stop is called or ctx is done.
+func (j *AuditRetentionJob) Start(ctx context.Context) error {
+	j.startOnce.Do(func() {
+		j.mu.Lock()
+		j.started = true
+		j.mu.Unlock()
+		go j.run(ctx)
+	})
+	return nil
+}
+
+// Stop signals the job to finish and waits for the current batch to complete.
+func (j *AuditRetentionJob) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() { close(j.stop) })
+
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports whether the last sweep succeeded.
+func (j *AuditRetentionJob) Health(context.Context) lifecycle.HealthStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: false, Message: "last audit retention sweep failed: " + j.lastErr.Error()}
+	}
+	if !j.leading() {
+		return lifecycle.HealthStatus{Ready: true, Message: "audit retention job is on standby, another replica leads"}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: "audit retention job is running"}
+}
+
+func (j *AuditRetentionJob) run(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if j.leading() {
+			_ = j.RunOnce(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single sweep: purge ended restores, then archive every due event.
+func (j *AuditRetentionJob) RunOnce(ctx context.Context) error {
+	now := j.clock.Now()
+
+	purged, err := j.repo.PurgeRestoredAuditEvents(ctx, now)
+	if err != nil {
+		err = fmt.Errorf("failed to purge restored audit events: %w", err)
+	} else {
+		if purged > 0 {
+			j.logger.InfoContext(ctx, "purged restored audit events", "events", purged)
+		}
+		err = j.archiveDue(ctx, now.Add(-j.cfg.HotRetention))
+	}
+
+	j.mu.Lock()
+	j.lastErr = err
+	j.mu.Unlock()
+
+	if err != nil {
+		j.logger.ErrorContext(ctx, "audit retention sweep failed", "error", err)
+	}
+	return err
+}
+
+func (j *AuditRetentionJob) archiveDue(ctx context.Context, cutoff time.Time) error {
+	for {
+		events, err := j.repo.ListArchivableAuditEvents(ctx, cutoff, j.cfg.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list archivable audit events: %w", err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		archive := j.newArchive(events)
+		if err := j.store.PutAuditArchive(ctx, archive.ObjectKey, events); err != nil {
+			return err
+		}
+		ids := make([]string, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		if err := j.repo.RecordAuditArchive(ctx, archive, ids); err != nil {
+			return fmt.Errorf("failed to record audit archive %s: %w", archive.ObjectKey, err)
+		}
+		j.logger.InfoContext(ctx, "archived audit events", "object", archive.ObjectKey, "events", archive.EventCount,
+			"firstSequence", archive.FirstSequence, "lastSequence", archive.LastSequence)
+
+		if len(events) < j.cfg.BatchSize {
+			return nil
+		}
+		select {
+		case <-j.stop:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// newArchive describes a batch. The object key is derived from its content, so that two
+// instances archiving the same batch write the same object.
+func (j *AuditRetentionJob) newArchive(events []*domain.AuditEvent) *domain.AuditArchive {
+	archive := &domain.AuditArchive{
+		FirstTimestamp: events[0].Timestamp,
+		LastTimestamp:  events[0].Timestamp,
+		EventCount:     len(events),
+	}
+	for _, event := range events {
+		if event.Sequence > 0 {
+			if archive.FirstSequence == 0 {
+				archive.FirstSequence = event.Sequence
+			}
+			archive.LastSequence = event.Sequence
+			archive.LastHash = event.Hash
+		}
+		if event.Timestamp.Before(archive.FirstTimestamp) {
+			archive.FirstTimestamp = event.Timestamp
+		}
+		if event.Timestamp.After(archive.LastTimestamp) {
+			archive.LastTimestamp = event.Timestamp
+		}
+	}
+	archive.ObjectKey = path.Join(j.cfg.Prefix, archive.FirstTimestamp.UTC().Format("2006/01/02"),
+		fmt.Sprintf("%020d-%020d-%s.jsonl.gz", archive.FirstSequence, archive.LastSequence, events[0].ID))
+	return archive
+}
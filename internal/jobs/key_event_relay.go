@@ -0,0 +1,232 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultEventRelayInterval = time.Second
+	defaultEventRelayBatch    = 100
+)
+
+var (
+	eventsRelayed, _ = meter.Int64Counter(
+		"polykey.events.relayed",
+		metric.WithDescription("Number of key events delivered from the outbox, by sink."),
+	)
+	eventRelayFailures, _ = meter.Int64Counter(
+		"polykey.events.relay_failures",
+		metric.WithDescription("Number of failed key event deliveries, retried on the next sweep, by sink."),
+	)
+	eventsPending, _ = meter.Int64ObservableGauge(
+		"polykey.events.pending",
+		metric.WithDescription("Number of key events not yet delivered, by sink."),
+	)
+	eventRelayLag, _ = meter.Float64ObservableGauge(
+		"polykey.events.lag",
+		metric.WithDescription("Age of the oldest key event not yet delivered, by sink."),
+		metric.WithUnit("s"),
+	)
+)
+
+// KeyEventRelayJob delivers the key events of an outbox to a sink. On its first sweep it
+// registers the sink's consumer, from which point key changes record events for it, and
+// every sweep delivers the recorded events in order until the outbox is empty. An event
+// is forgotten only after the sink accepted it; a failed delivery ends the sweep, and the
+// next sweep starts again from that event, so events are delivered at least once and in
+// order.
+type KeyEventRelayJob struct {
+	outbox    domain.KeyEventOutbox
+	sink      domain.KeyEventSink
+	logger    *slog.Logger
+	interval  time.Duration
+	batchSize int
+
+	leaderGate
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+
+	mu         sync.Mutex
+	started    bool
+	registered bool
+	lastErr    error
+	backlog    domain.KeyEventBacklog
+}
+
+// NewKeyEventRelayJob creates a job delivering the events of outbox to sink every
+// interval, batchSize at a time.
+func NewKeyEventRelayJob(outbox domain.KeyEventOutbox, sink domain.KeyEventSink, logger *slog.Logger, interval time.Duration, batchSize int) *KeyEventRelayJob {
+	if interval <= 0 {
+		interval = defaultEventRelayInterval
+	}
+	if batchSize <= 0 {
+		batchSize = defaultEventRelayBatch
+	}
+	j := &KeyEventRelayJob{
+		outbox:    outbox,
+		sink:      sink,
+		logger:    logger,
+		interval:  interval,
+		batchSize: batchSize,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	attrs := metric.WithAttributes(attribute.String("sink", sink.Name()))
+	_, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		j.mu.Lock()
+		backlog := j.backlog
+		j.mu.Unlock()
+		o.ObserveInt64(eventsPending, backlog.Pending, attrs)
+		lag := 0.0
+		if backlog.Oldest != nil {
+			lag = time.Since(*backlog.Oldest).Seconds()
+		}
+		o.ObserveFloat64(eventRelayLag, lag, attrs)
+		return nil
+	}, eventsPending, eventRelayLag)
+
+	return j
+}
+
+// Start runs the job in the background until Stop is called or ctx is done.
+func (j *KeyEventRelayJob) Start(ctx context.Context) error {
+	j.startOnce.Do(func() {
+		j.mu.Lock()
+		j.started = true
+		j.mu.Unlock()
+		go j.run(ctx)
+	})
+	return nil
+}
+
+// Stop signals the job to finish and waits for the current batch to complete.
+func (j *KeyEventRelayJob) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() { close(j.stop) })
+
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports whether the last sweep succeeded.
+func (j *KeyEventRelayJob) Health(context.Context) lifecycle.HealthStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: false, Message: "last key event relay sweep failed: " + j.lastErr.Error()}
+	}
+	if !j.leading() {
+		return lifecycle.HealthStatus{Ready: true, Message: "key event relay is on standby, another replica leads"}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: fmt.Sprintf("key event relay is running, %d events pending", j.backlog.Pending)}
+}
+
+func (j *KeyEventRelayJob) run(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if j.leading() {
+			_ = j.RunOnce(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single sweep, delivering every recorded event.
+func (j *KeyEventRelayJob) RunOnce(ctx context.Context) error {
+	err := j.relay(ctx)
+	backlog, backlogErr := j.outbox.Backlog(ctx)
+
+	j.mu.Lock()
+	j.lastErr = err
+	if backlogErr == nil {
+		j.backlog = backlog
+	}
+	j.mu.Unlock()
+
+	if err != nil {
+		j.logger.ErrorContext(ctx, "key event relay sweep failed", "sink", j.sink.Name(), "error", err)
+	}
+	return err
+}
+
+func (j *KeyEventRelayJob) relay(ctx context.Context) error {
+	j.mu.Lock()
+	registered := j.registered
+	j.mu.Unlock()
+	if !registered {
+		if err := j.outbox.Register(ctx); err != nil {
+			return err
+		}
+		j.mu.Lock()
+		j.registered = true
+		j.mu.Unlock()
+	}
+
+	attrs := metric.WithAttributes(attribute.String("sink", j.sink.Name()))
+	for {
+		events, err := j.outbox.Pending(ctx, j.batchSize)
+		if err != nil {
+			return err
+		}
+		delivered := make([]int64, 0, len(events))
+		var deliverErr error
+		for _, event := range events {
+			if deliverErr = j.sink.Deliver(ctx, event); deliverErr != nil {
+				eventRelayFailures.Add(ctx, 1, attrs)
+				break
+			}
+			delivered = append(delivered, event.Seq)
+		}
+		if err := j.outbox.Delivered(ctx, delivered); err != nil {
+			return err
+		}
+		eventsRelayed.Add(ctx, int64(len(delivered)), attrs)
+		if deliverErr != nil {
+			return deliverErr
+		}
+		if len(events) < j.batchSize {
+			return nil
+		}
+		select {
+		case <-j.stop:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+)
+
+const (
+	defaultExpirationInterval  = time.Minute
+	defaultExpirationBatchSize = 100
+)
+
+// KeyExpirationJob periodically moves keys past their ExpiresAt, and rotated versions
+// past their grace deadline, to the expired status.
+// When NotifyBefore is set it also publishes an "expiring" event for keys about to
+// expire, once per key, so owners watching their keys can act in time.
+type KeyExpirationJob struct {
+	repo      domain.KeyRepository
+	notices   domain.KeyExpiryNoticeStore
+	publisher domain.KeyEventPublisher
+	logger    *slog.Logger
+	cfg       config.KeyExpirationConfig
+	clock     clock.Clock
+
+	leaderGate
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+
+	mu       sync.Mutex
+	started  bool
+	lastErr  error
+	notified domain.KeyExpiryCursor
+}
+
+// NewKeyExpirationJob creates a new KeyExpirationJob. publisher may be nil, in which
+// case no advance notice is sent. notices keeps how far the notices have got; when it is
+// nil, that is only kept in memory, and a restarted job may announce keys again.
+func NewKeyExpirationJob(repo domain.KeyRepository, notices domain.KeyExpiryNoticeStore, publisher domain.KeyEventPublisher, logger *slog.Logger, cfg config.KeyExpirationConfig, clk clock.Clock) *KeyExpirationJob {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultExpirationInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultExpirationBatchSize
+	}
+	return &KeyExpirationJob{
+		repo:      repo,
+		notices:   notices,
+		publisher: publisher,
+		logger:    logger,
+		cfg:       cfg,
+		clock:     clk,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the job in the background until Stop is called or ctx is done.
+func (j *KeyExpirationJob) Start(ctx context.Context) error {
+	j.startOnce.Do(func() {
+		j.mu.Lock()
+		j.started = true
+		j.mu.Unlock()
+		go j.run(ctx)
+	})
+	return nil
+}
+
+// Stop signals the job to finish and waits for the current sweep to complete.
+func (j *KeyExpirationJob) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() { close(j.stop) })
+
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports whether the last sweep succeeded.
+func (j *KeyExpirationJob) Health(context.Context) lifecycle.HealthStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: false, Message: "last expiration sweep failed: " + j.lastErr.Error()}
+	}
+	if !j.leading() {
+		return lifecycle.HealthStatus{Ready: true, Message: "key expiration job is on standby, another replica leads"}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: "key expiration job is running"}
+}
+
+func (j *KeyExpirationJob) run(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if j.leading() {
+			_ = j.RunOnce(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single sweep: expire every due key, then send advance notices.
+func (j *KeyExpirationJob) RunOnce(ctx context.Context) error {
+	now := j.clock.Now()
+
+	err := j.expireDue(ctx, now)
+	if err == nil && j.cfg.NotifyBefore > 0 && j.publisher != nil {
+		err = j.notifyExpiring(ctx, now)
+	}
+
+	j.mu.Lock()
+	j.lastErr = err
+	j.mu.Unlock()
+
+	if err != nil {
+		j.logger.ErrorContext(ctx, "key expiration sweep failed", "error", err)
+	}
+	return err
+}
+
+func (j *KeyExpirationJob) expireDue(ctx context.Context, now time.Time) error {
+	for {
+		keys, err := j.repo.ExpireKeys(ctx, now, j.cfg.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to expire keys: %w", err)
+		}
+		for _, key := range keys {
+			j.logger.InfoContext(ctx, "key expired", "keyId", key.ID.String(), "version", key.Version)
+		}
+		if len(keys) < j.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// notifyExpiring announces keys expiring within NotifyBefore. Keys are walked in the
+// order of their expiry and ID from the last one announced, which is saved after every
+// batch, so a key is announced once even when several share an expiry time or the job
+// restarts.
+func (j *KeyExpirationJob) notifyExpiring(ctx context.Context, now time.Time) error {
+	after, err := j.watermark(ctx)
+	if err != nil {
+		return err
+	}
+	if after.ExpiresAt.Before(now) {
+		after = domain.KeyExpiryCursor{ExpiresAt: now}
+	}
+	horizon := now.Add(j.cfg.NotifyBefore)
+
+	for {
+		keys, err := j.repo.ListExpiringKeys(ctx, after, horizon, j.cfg.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list expiring keys: %w", err)
+		}
+		for _, key := range keys {
+			j.publisher.Publish(ctx, domain.KeyEvent{
+				Type:       domain.KeyEventExpiring,
+				KeyID:      key.ID.String(),
+				Namespace:  key.Namespace,
+				Version:    key.Version,
+				Metadata:   key.Metadata,
+				OccurredAt: now,
+			})
+		}
+		if len(keys) < j.cfg.BatchSize {
+			break
+		}
+		after = domain.ExpiryCursorOf(keys[len(keys)-1])
+		if err := j.saveWatermark(ctx, after); err != nil {
+			return err
+		}
+	}
+
+	// Every key expiring by the horizon has been announced.
+	return j.saveWatermark(ctx, domain.KeyExpiryCursor{ExpiresAt: horizon})
+}
+
+func (j *KeyExpirationJob) watermark(ctx context.Context) (domain.KeyExpiryCursor, error) {
+	if j.notices != nil {
+		return j.notices.GetExpiryNoticeWatermark(ctx)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.notified, nil
+}
+
+func (j *KeyExpirationJob) saveWatermark(ctx context.Context, watermark domain.KeyExpiryCursor) error {
+	if j.notices != nil {
+		return j.notices.SaveExpiryNoticeWatermark(ctx, watermark)
+	}
+	j.mu.Lock()
+	j.notified = watermark
+	j.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,219 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultReplicationInterval = 5 * time.Second
+	defaultReplicationBatch    = 500
+)
+
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/jobs")
+
+var (
+	replicationShipped, _ = meter.Int64Counter(
+		"polykey.replication.shipped",
+		metric.WithDescription("Number of recorded key mutations shipped to the standby, by target."),
+	)
+	replicationConflicts, _ = meter.Int64Counter(
+		"polykey.replication.conflicts",
+		metric.WithDescription("Number of key versions left as the standby had them because it changed them later than the primary, by target."),
+	)
+	replicationPending, _ = meter.Int64ObservableGauge(
+		"polykey.replication.pending",
+		metric.WithDescription("Number of recorded key mutations not yet shipped to the standby, by target."),
+	)
+	replicationLag, _ = meter.Float64ObservableGauge(
+		"polykey.replication.lag",
+		metric.WithDescription("Age of the oldest key mutation not yet shipped to the standby, by target."),
+		metric.WithUnit("s"),
+	)
+)
+
+// KeyReplicationJob ships key mutations to a standby database. On its first sweep it
+// registers the standby on the primary, which from then on records them, and every
+// sweep ships what was recorded until the outbox is empty. Conflicts are logged and
+// counted; the standby keeps its version of those keys.
+type KeyReplicationJob struct {
+	replicator domain.KeyReplicator
+	logger     *slog.Logger
+	cfg        config.ReplicationConfig
+
+	leaderGate
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+
+	mu         sync.Mutex
+	started    bool
+	registered bool
+	lastErr    error
+	backlog    domain.ReplicationBacklog
+}
+
+// NewKeyReplicationJob creates a new KeyReplicationJob.
+func NewKeyReplicationJob(replicator domain.KeyReplicator, logger *slog.Logger, cfg config.ReplicationConfig) *KeyReplicationJob {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultReplicationInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultReplicationBatch
+	}
+	j := &KeyReplicationJob{
+		replicator: replicator,
+		logger:     logger,
+		cfg:        cfg,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	target := metric.WithAttributes(attribute.String("target", replicator.Target()))
+	_, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		j.mu.Lock()
+		backlog := j.backlog
+		j.mu.Unlock()
+		o.ObserveInt64(replicationPending, backlog.Pending, target)
+		lag := 0.0
+		if backlog.Oldest != nil {
+			lag = time.Since(*backlog.Oldest).Seconds()
+		}
+		o.ObserveFloat64(replicationLag, lag, target)
+		return nil
+	}, replicationPending, replicationLag)
+
+	return j
+}
+
+// Start runs the job in the background until Stop is called or ctx is done.
+func (j *KeyReplicationJob) Start(ctx context.Context) error {
+	j.startOnce.Do(func() {
+		j.mu.Lock()
+		j.started = true
+		j.mu.Unlock()
+		go j.run(ctx)
+	})
+	return nil
+}
+
+// Stop signals the job to finish and waits for the current batch to complete.
+func (j *KeyReplicationJob) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() { close(j.stop) })
+
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports whether the last sweep succeeded.
+func (j *KeyReplicationJob) Health(context.Context) lifecycle.HealthStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: false, Message: "last key replication sweep failed: " + j.lastErr.Error()}
+	}
+	if !j.leading() {
+		return lifecycle.HealthStatus{Ready: true, Message: "key replication job is on standby, another replica leads"}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: fmt.Sprintf("key replication job is running, %d mutations pending", j.backlog.Pending)}
+}
+
+func (j *KeyReplicationJob) run(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if j.leading() {
+			_ = j.RunOnce(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single sweep, shipping every recorded mutation.
+func (j *KeyReplicationJob) RunOnce(ctx context.Context) error {
+	err := j.ship(ctx)
+	backlog, backlogErr := j.replicator.Backlog(ctx)
+
+	j.mu.Lock()
+	j.lastErr = err
+	if backlogErr == nil {
+		j.backlog = backlog
+	}
+	j.mu.Unlock()
+
+	if err != nil {
+		j.logger.ErrorContext(ctx, "key replication sweep failed", "target", j.replicator.Target(), "error", err)
+	}
+	return err
+}
+
+func (j *KeyReplicationJob) ship(ctx context.Context) error {
+	j.mu.Lock()
+	registered := j.registered
+	j.mu.Unlock()
+	if !registered {
+		if err := j.replicator.Register(ctx); err != nil {
+			return err
+		}
+		j.mu.Lock()
+		j.registered = true
+		j.mu.Unlock()
+	}
+
+	target := attribute.String("target", j.replicator.Target())
+	for {
+		batch, err := j.replicator.ReplicateBatch(ctx, j.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		replicationShipped.Add(ctx, int64(batch.Shipped), metric.WithAttributes(target))
+		for _, conflict := range batch.Conflicts {
+			replicationConflicts.Add(ctx, 1, metric.WithAttributes(target))
+			j.logger.WarnContext(ctx, "key version changed on the standby, not replicated", "target", j.replicator.Target(),
+				"keyId", conflict.KeyID, "version", conflict.Version)
+		}
+		if batch.Shipped < j.cfg.BatchSize {
+			return nil
+		}
+		select {
+		case <-j.stop:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+)
+
+const (
+	defaultScheduledRotationInterval = 10 * time.Minute
+	defaultScheduledRotationBatch    = 100
+)
+
+// DueKeyRotator rotates the keys whose rotation period has elapsed.
+type DueKeyRotator interface {
+	RotateDueKeys(ctx context.Context, pageSize int) (rotated, failed int, err error)
+}
+
+// KeyRotationScheduleJob enforces the rotation period of keys created from a template.
+// Each sweep rotates the active keys whose latest version is older than their period.
+type KeyRotationScheduleJob struct {
+	rotator DueKeyRotator
+	logger  *slog.Logger
+	cfg     config.KeyScheduledRotationConfig
+
+	leaderGate
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	lastErr error
+}
+
+// NewKeyRotationScheduleJob creates a new KeyRotationScheduleJob.
+func NewKeyRotationScheduleJob(rotator DueKeyRotator, logger *slog.Logger, cfg config.KeyScheduledRotationConfig) *KeyRotationScheduleJob {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultScheduledRotationInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultScheduledRotationBatch
+	}
+	return &KeyRotationScheduleJob{
+		rotator: rotator,
+		logger:  logger,
+		cfg:     cfg,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start runs the job in the background until Stop is called or ctx is done.
+func (j *KeyRotationScheduleJob) Start(ctx context.Context) error {
+	j.startOnce.Do(func() {
+		j.mu.Lock()
+		j.started = true
+		j.mu.Unlock()
+		go j.run(ctx)
+	})
+	return nil
+}
+
+// Stop signals the job to finish and waits for the current sweep to complete.
+func (j *KeyRotationScheduleJob) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() { close(j.stop) })
+
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports whether the last sweep succeeded.
+func (j *KeyRotationScheduleJob) Health(context.Context) lifecycle.HealthStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: false, Message: "last scheduled key rotation sweep failed: " + j.lastErr.Error()}
+	}
+	if !j.leading() {
+		return lifecycle.HealthStatus{Ready: true, Message: "scheduled key rotation job is on standby, another replica leads"}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: "scheduled key rotation job is running"}
+}
+
+func (j *KeyRotationScheduleJob) run(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if j.leading() {
+			_ = j.RunOnce(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single sweep, rotating every key that is due. A key that fails to
+// rotate is logged and tried again on the next sweep.
+func (j *KeyRotationScheduleJob) RunOnce(ctx context.Context) error {
+	rotated, failed, err := j.rotator.RotateDueKeys(ctx, j.cfg.BatchSize)
+	if err != nil {
+		err = fmt.Errorf("failed to rotate due keys: %w", err)
+	}
+
+	j.mu.Lock()
+	j.lastErr = err
+	j.mu.Unlock()
+
+	if err != nil {
+		j.logger.ErrorContext(ctx, "scheduled key rotation sweep failed", "rotated", rotated, "failed", failed, "error", err)
+		return err
+	}
+	if rotated > 0 || failed > 0 {
+		j.logger.InfoContext(ctx, "scheduled key rotation sweep finished", "rotated", rotated, "failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+)
+
+const (
+	defaultVersionRetentionInterval = time.Hour
+	defaultVersionRetentionBatch    = 100
+	defaultKeepVersions             = 5
+	defaultVersionMinAge            = 90 * 24 * time.Hour
+)
+
+// KeyVersionRetentionJob keeps the keys table from growing with every rotation. Each
+// sweep removes, or archives, the versions that have been out of service for MinAge and
+// are beyond the newest KeepVersions of their key. The newest version of a key is never
+// removed.
+type KeyVersionRetentionJob struct {
+	repo   domain.KeyRepository
+	logger *slog.Logger
+	cfg    config.KeyVersionRetentionConfig
+	clock  clock.Clock
+
+	leaderGate
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	lastErr error
+}
+
+// NewKeyVersionRetentionJob creates a new KeyVersionRetentionJob.
+func NewKeyVersionRetentionJob(repo domain.KeyRepository, logger *slog.Logger, cfg config.KeyVersionRetentionConfig, clk clock.Clock) *KeyVersionRetentionJob {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultVersionRetentionInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultVersionRetentionBatch
+	}
+	if cfg.KeepVersions <= 0 {
+		cfg.KeepVersions = defaultKeepVersions
+	}
+	if cfg.MinAge <= 0 {
+		cfg.MinAge = defaultVersionMinAge
+	}
+	return &KeyVersionRetentionJob{
+		repo:   repo,
+		logger: logger,
+		cfg:    cfg,
+		clock:  clk,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start runs the job in the background until Stop is called or ctx is done.
+func (j *KeyVersionRetentionJob) Start(ctx context.Context) error {
+	j.startOnce.Do(func() {
+		j.mu.Lock()
+		j.started = true
+		j.mu.Unlock()
+		go j.run(ctx)
+	})
+	return nil
+}
+
+// Stop signals the job to finish and waits for the current batch to complete.
+func (j *KeyVersionRetentionJob) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() { close(j.stop) })
+
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports whether the last sweep succeeded.
+func (j *KeyVersionRetentionJob) Health(context.Context) lifecycle.HealthStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: false, Message: "last key version retention sweep failed: " + j.lastErr.Error()}
+	}
+	if !j.leading() {
+		return lifecycle.HealthStatus{Ready: true, Message: "key version retention job is on standby, another replica leads"}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: "key version retention job is running"}
+}
+
+func (j *KeyVersionRetentionJob) run(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if j.leading() {
+			_ = j.RunOnce(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single sweep, removing every version the retention allows.
+func (j *KeyVersionRetentionJob) RunOnce(ctx context.Context) error {
+	err := j.pruneDue(ctx, domain.KeyVersionRetention{
+		KeepVersions: j.cfg.KeepVersions,
+		Before:       j.clock.Now().Add(-j.cfg.MinAge),
+		Archive:      j.cfg.Archive,
+	})
+
+	j.mu.Lock()
+	j.lastErr = err
+	j.mu.Unlock()
+
+	if err != nil {
+		j.logger.ErrorContext(ctx, "key version retention sweep failed", "error", err)
+	}
+	return err
+}
+
+func (j *KeyVersionRetentionJob) pruneDue(ctx context.Context, retention domain.KeyVersionRetention) error {
+	for {
+		keys, err := j.repo.PruneKeyVersions(ctx, retention, j.cfg.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to prune key versions: %w", err)
+		}
+		for _, key := range keys {
+			j.logger.InfoContext(ctx, "key version pruned", "keyId", key.ID.String(), "version", key.Version,
+				"archived", retention.Archive)
+		}
+		if len(keys) < j.cfg.BatchSize {
+			return nil
+		}
+		select {
+		case <-j.stop:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
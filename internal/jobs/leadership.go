@@ -0,0 +1,20 @@
+package jobs
+
+import "github.com/spounge-ai/polykey/internal/domain"
+
+// leaderGate keeps a job's scheduled sweeps to the replica that leads. Without a
+// leadership every replica sweeps. RunOnce is not gated, so that a sweep asked for
+// explicitly runs wherever it is asked.
+type leaderGate struct {
+	leader domain.Leadership
+}
+
+// SetLeadership makes the job sweep only while leader reports that this replica leads.
+// It must be called before Start.
+func (g *leaderGate) SetLeadership(leader domain.Leadership) {
+	g.leader = leader
+}
+
+func (g *leaderGate) leading() bool {
+	return g.leader == nil || g.leader.IsLeader()
+}
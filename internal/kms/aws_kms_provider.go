@@ -10,66 +10,60 @@ import (
 	"github.com/spounge-ai/polykey/pkg/execution"
 )
 
-const (
-	awsKmsTimeout    = 5 * time.Second
-	maxRetries       = 3
-	initialBackoff   = 100 * time.Millisecond
-	maxBackoff       = 1 * time.Second
-)
+// awsKmsTimeout bounds each attempt of a call; RetryingProvider makes the attempts.
+const awsKmsTimeout = 5 * time.Second
 
 type AWSKMSProvider struct {
 	client    *kms.Client
 	kmsKeyARN string
 }
 
+// NewAWSKMSProvider creates a provider making a single attempt per call: the SDK's own
+// retries are disabled so that the configured retry policy is the only one.
 func NewAWSKMSProvider(cfg aws.Config, kmsKeyARN string) *AWSKMSProvider {
 	return &AWSKMSProvider{
-		client:    kms.NewFromConfig(cfg),
+		client: kms.NewFromConfig(cfg, func(o *kms.Options) {
+			o.Retryer = aws.NopRetryer{}
+		}),
 		kmsKeyARN: kmsKeyARN,
 	}
 }
 
 func (p *AWSKMSProvider) EncryptDEK(ctx context.Context, plaintextDEK []byte, key *domain.Key) ([]byte, error) {
-	return execution.WithRetry(ctx, maxRetries, initialBackoff, maxBackoff, func(ctx context.Context) ([]byte, error) {
-		return execution.WithTimeout(ctx, awsKmsTimeout, func(ctx context.Context) ([]byte, error) {
-			input := &kms.EncryptInput{
-				KeyId:     &p.kmsKeyARN,
-				Plaintext: plaintextDEK,
-			}
+	return execution.WithTimeout(ctx, awsKmsTimeout, func(ctx context.Context) ([]byte, error) {
+		input := &kms.EncryptInput{
+			KeyId:     &p.kmsKeyARN,
+			Plaintext: plaintextDEK,
+		}
 
-			result, err := p.client.Encrypt(ctx, input)
-			if err != nil {
-				return nil, err
-			}
+		result, err := p.client.Encrypt(ctx, input)
+		if err != nil {
+			return nil, err
+		}
 
-			return result.CiphertextBlob, nil
-		})
+		return result.CiphertextBlob, nil
 	})
 }
 
 func (p *AWSKMSProvider) DecryptDEK(ctx context.Context, key *domain.Key) ([]byte, error) {
-	return execution.WithRetry(ctx, maxRetries, initialBackoff, maxBackoff, func(ctx context.Context) ([]byte, error) {
-		return execution.WithTimeout(ctx, awsKmsTimeout, func(ctx context.Context) ([]byte, error) {
-			input := &kms.DecryptInput{
-				CiphertextBlob: key.EncryptedDEK,
-				KeyId:          &p.kmsKeyARN,
-			}
+	return execution.WithTimeout(ctx, awsKmsTimeout, func(ctx context.Context) ([]byte, error) {
+		input := &kms.DecryptInput{
+			CiphertextBlob: key.EncryptedDEK,
+			KeyId:          &p.kmsKeyARN,
+		}
 
-			result, err := p.client.Decrypt(ctx, input)
-			if err != nil {
-				return nil, err
-			}
+		result, err := p.client.Decrypt(ctx, input)
+		if err != nil {
+			return nil, err
+		}
 
-			return result.Plaintext, nil
-		})
+		return result.Plaintext, nil
 	})
 }
 
 func (p *AWSKMSProvider) HealthCheck(ctx context.Context) error {
-	_, err := execution.WithRetry(ctx, maxRetries, initialBackoff, maxBackoff, func(ctx context.Context) (any, error) {
-		return execution.WithTimeout(ctx, awsKmsTimeout, func(ctx context.Context) (*kms.ListKeysOutput, error) {
-			return p.client.ListKeys(ctx, &kms.ListKeysInput{Limit: aws.Int32(1)})
-		})
+	_, err := execution.WithTimeout(ctx, awsKmsTimeout, func(ctx context.Context) (*kms.ListKeysOutput, error) {
+		return p.client.ListKeys(ctx, &kms.ListKeysInput{Limit: aws.Int32(1)})
 	})
 	return err
 }
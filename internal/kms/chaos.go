@@ -0,0 +1,56 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/smithy-go"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/patterns/chaos"
+)
+
+// ChaosProvider decorates a KMSProvider with injected faults, for tests and development.
+// Calls are delayed, and those that fail return a KMSInternalException as AWS KMS does
+// when it fails, which retries and circuit breakers treat as transient. Placed inside
+// them, it exercises both.
+type ChaosProvider struct {
+	KMSProvider
+	injector *chaos.Injector
+}
+
+func NewChaosProvider(provider KMSProvider, injector *chaos.Injector) *ChaosProvider {
+	return &ChaosProvider{KMSProvider: provider, injector: injector}
+}
+
+func (p *ChaosProvider) EncryptDEK(ctx context.Context, plaintextDEK []byte, key *domain.Key) ([]byte, error) {
+	if err := p.inject(ctx); err != nil {
+		return nil, err
+	}
+	return p.KMSProvider.EncryptDEK(ctx, plaintextDEK, key)
+}
+
+func (p *ChaosProvider) DecryptDEK(ctx context.Context, key *domain.Key) ([]byte, error) {
+	if err := p.inject(ctx); err != nil {
+		return nil, err
+	}
+	return p.KMSProvider.DecryptDEK(ctx, key)
+}
+
+func (p *ChaosProvider) HealthCheck(ctx context.Context) error {
+	if err := p.inject(ctx); err != nil {
+		return err
+	}
+	return p.KMSProvider.HealthCheck(ctx)
+}
+
+func (p *ChaosProvider) inject(ctx context.Context) error {
+	err := p.injector.Inject(ctx)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", err, &smithy.GenericAPIError{
+		Code:    "KMSInternalException",
+		Message: "injected fault",
+		Fault:   smithy.FaultServer,
+	})
+}
@@ -0,0 +1,66 @@
+package kms
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/breaker"
+	"github.com/spounge-ai/polykey/pkg/patterns/circuitbreaker"
+)
+
+// breakerCallTimeout bounds one call through a provider's breaker. It is longer than the
+// AWS provider's own per-attempt timeout, which should fire first.
+const breakerCallTimeout = awsKmsTimeout + time.Second
+
+// CircuitBreakerProvider decorates a KMSProvider with a circuit breaker, so that an
+// unavailable KMS fails key operations fast instead of tying up requests for the full
+// retry budget. Only transient failures count towards opening it: a DEK that does not
+// decrypt says nothing about the provider's health. Placed inside a RetryingProvider,
+// it counts every attempt, and an open breaker ends the retries at once.
+type CircuitBreakerProvider struct {
+	KMSProvider
+	breaker *breaker.Breaker
+}
+
+// NewCircuitBreakerProvider wraps provider, named name, with a breaker that opens after
+// maxFailures consecutive transient failures for resetTimeout.
+func NewCircuitBreakerProvider(name string, provider KMSProvider, logger *slog.Logger, maxFailures int, resetTimeout time.Duration) *CircuitBreakerProvider {
+	return &CircuitBreakerProvider{
+		KMSProvider: provider,
+		breaker: breaker.New("kms_"+name, logger, maxFailures, resetTimeout,
+			circuitbreaker.WithCallTimeout[any](breakerCallTimeout),
+			circuitbreaker.WithFailurePredicate[any](IsTransient),
+		),
+	}
+}
+
+// Breaker returns the breaker, for operators to inspect and override.
+func (p *CircuitBreakerProvider) Breaker() *breaker.Breaker {
+	return p.breaker
+}
+
+func (p *CircuitBreakerProvider) EncryptDEK(ctx context.Context, plaintextDEK []byte, key *domain.Key) ([]byte, error) {
+	return breaker.Execute(ctx, p.breaker, func(ctx context.Context) ([]byte, error) {
+		return p.KMSProvider.EncryptDEK(ctx, plaintextDEK, key)
+	})
+}
+
+func (p *CircuitBreakerProvider) DecryptDEK(ctx context.Context, key *domain.Key) ([]byte, error) {
+	return breaker.Execute(ctx, p.breaker, func(ctx context.Context) ([]byte, error) {
+		return p.KMSProvider.DecryptDEK(ctx, key)
+	})
+}
+
+// HealthCheck bypasses the breaker, so that an open breaker does not hide a provider
+// that has recovered, nor a manual trip a provider that has not.
+func (p *CircuitBreakerProvider) HealthCheck(ctx context.Context) error {
+	return p.KMSProvider.HealthCheck(ctx)
+}
+
+// isBreakerTimeout reports whether err is a call the breaker gave up waiting for.
+func isBreakerTimeout(err error) bool {
+	return errors.Is(err, circuitbreaker.ErrTimeout)
+}
@@ -0,0 +1,93 @@
+package kms
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/kms")
+
+var (
+	operationDuration, _ = meter.Float64Histogram(
+		"polykey.kms.operation.duration",
+		metric.WithDescription("Duration of KMS provider operations, by provider and operation."),
+		metric.WithUnit("s"),
+	)
+	operationErrors, _ = meter.Int64Counter(
+		"polykey.kms.operation.errors",
+		metric.WithDescription("Number of failed KMS provider operations, by provider, operation and error type."),
+	)
+)
+
+// throttlingCodes are the AWS error codes returned when KMS request quotas are exceeded.
+var throttlingCodes = map[string]bool{
+	"ThrottlingException":      true,
+	"LimitExceededException":   true,
+	"TooManyRequestsException": true,
+}
+
+// InstrumentedProvider decorates a KMSProvider with latency histograms and error
+// counters labeled by provider, so throttling of a remote KMS can be told apart from a
+// slow local provider. Durations include the retries of the wrapped provider.
+type InstrumentedProvider struct {
+	KMSProvider
+	name string
+}
+
+func NewInstrumentedProvider(name string, provider KMSProvider) *InstrumentedProvider {
+	return &InstrumentedProvider{KMSProvider: provider, name: name}
+}
+
+func (p *InstrumentedProvider) EncryptDEK(ctx context.Context, plaintextDEK []byte, key *domain.Key) ([]byte, error) {
+	start := time.Now()
+	encrypted, err := p.KMSProvider.EncryptDEK(ctx, plaintextDEK, key)
+	p.record(ctx, "encrypt_dek", start, err)
+	return encrypted, err
+}
+
+func (p *InstrumentedProvider) DecryptDEK(ctx context.Context, key *domain.Key) ([]byte, error) {
+	start := time.Now()
+	plaintext, err := p.KMSProvider.DecryptDEK(ctx, key)
+	p.record(ctx, "decrypt_dek", start, err)
+	return plaintext, err
+}
+
+func (p *InstrumentedProvider) record(ctx context.Context, operation string, start time.Time, err error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("provider", p.name),
+		attribute.String("operation", operation),
+		attribute.Bool("success", err == nil),
+	}
+	// Metrics must be recorded even when the caller's context is done.
+	ctx = context.WithoutCancel(ctx)
+	operationDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	if err != nil {
+		operationErrors.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("provider", p.name),
+			attribute.String("operation", operation),
+			attribute.String("error_type", ErrorType(err)),
+		))
+	}
+}
+
+// ErrorType sorts a provider error into "throttled", "timeout", "canceled" or "error".
+func ErrorType(err error) string {
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &apiErr) && throttlingCodes[apiErr.ErrorCode()]:
+		return "throttled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
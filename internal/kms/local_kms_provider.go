@@ -12,6 +12,7 @@ import (
 	"github.com/spounge-ai/polykey/internal/domain"
 	"github.com/spounge-ai/polykey/pkg/cache"
 	"github.com/spounge-ai/polykey/pkg/execution"
+	"github.com/spounge-ai/polykey/pkg/memory"
 )
 
 const (
@@ -21,7 +22,8 @@ const (
 )
 
 type LocalKMSProvider struct {
-	masterKey       []byte
+	// masterKey is kept in locked memory for the life of the process.
+	masterKey       *memory.LockedBuffer
 	derivedKeyCache cache.Store[string, []byte]
 }
 
@@ -31,7 +33,7 @@ func NewLocalKMSProvider(masterKey string) (*LocalKMSProvider, error) {
 		return nil, fmt.Errorf("failed to decode master key: %w", err)
 	}
 	return &LocalKMSProvider{
-		masterKey: key,
+		masterKey: memory.MoveToLockedBuffer(key),
 		derivedKeyCache: cache.New[string, []byte](
 			cache.WithDefaultTTL[string, []byte](derivedKeyCacheTTL),
 			cache.WithCleanupInterval[string, []byte](derivedKeyCacheClean),
@@ -47,7 +49,7 @@ func (p *LocalKMSProvider) getDerivedKey(ctx context.Context, key *domain.Key) (
 
 	info := []byte(key.ID.String())
 	salt := []byte("polykey-salt:" + key.ID.String())
-	derivedKey, err := DeriveKey(p.masterKey, salt, info, 32)
+	derivedKey, err := DeriveKey(p.masterKey.Bytes(), salt, info, 32)
 	if err != nil {
 		return nil, fmt.Errorf("failed to derive key: %w", err)
 	}
@@ -82,7 +84,7 @@ func (p *LocalKMSProvider) DecryptDEK(ctx context.Context, key *domain.Key) ([]b
 
 		// If decryption with the derived key fails, fall back to the old method (master key).
 		// This provides backward compatibility for keys encrypted before the KDF was introduced.
-		return p.decryptWithKey(p.masterKey, key.EncryptedDEK)
+		return p.decryptWithKey(p.masterKey.Bytes(), key.EncryptedDEK)
 	})
 }
 
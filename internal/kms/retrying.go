@@ -0,0 +1,106 @@
+package kms
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/pkg/execution"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var operationRetries, _ = meter.Int64Counter(
+	"polykey.kms.operation.retries",
+	metric.WithDescription("Number of KMS provider operations attempted again after a transient failure, by provider, operation and error type."),
+)
+
+// transientCodes are the AWS KMS error codes of failures that may succeed when tried
+// again, besides throttling.
+var transientCodes = map[string]bool{
+	"KMSInternalException":       true,
+	"DependencyTimeoutException": true,
+	"InternalFailure":            true,
+	"ServiceUnavailable":         true,
+	"RequestTimeout":             true,
+	"RequestTimeoutException":    true,
+}
+
+// RetryingProvider decorates a KMSProvider with a retry policy for transient failures:
+// throttling, KMS internal errors, 5xx responses, network errors and attempts that ran
+// out of their own time while the caller's context is still live. Encrypting and
+// decrypting a DEK have no side effects, so repeating them is safe. The wrapped provider
+// should not retry on its own, or the attempts multiply.
+type RetryingProvider struct {
+	KMSProvider
+	name   string
+	policy execution.RetryPolicy
+}
+
+// NewRetryingProvider wraps provider, named name in metrics, with policy. The policy's
+// Retryable and OnRetry are replaced.
+func NewRetryingProvider(name string, provider KMSProvider, policy execution.RetryPolicy) *RetryingProvider {
+	policy.Retryable = IsTransient
+	return &RetryingProvider{KMSProvider: provider, name: name, policy: policy}
+}
+
+func (p *RetryingProvider) EncryptDEK(ctx context.Context, plaintextDEK []byte, key *domain.Key) ([]byte, error) {
+	return execution.Retry(ctx, p.policyFor(ctx, "encrypt_dek"), func(ctx context.Context) ([]byte, error) {
+		return p.KMSProvider.EncryptDEK(ctx, plaintextDEK, key)
+	})
+}
+
+func (p *RetryingProvider) DecryptDEK(ctx context.Context, key *domain.Key) ([]byte, error) {
+	return execution.Retry(ctx, p.policyFor(ctx, "decrypt_dek"), func(ctx context.Context) ([]byte, error) {
+		return p.KMSProvider.DecryptDEK(ctx, key)
+	})
+}
+
+func (p *RetryingProvider) HealthCheck(ctx context.Context) error {
+	_, err := execution.Retry(ctx, p.policyFor(ctx, "health_check"), func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, p.KMSProvider.HealthCheck(ctx)
+	})
+	return err
+}
+
+// policyFor returns the policy counting the retries of one operation.
+func (p *RetryingProvider) policyFor(ctx context.Context, operation string) execution.RetryPolicy {
+	policy := p.policy
+	policy.OnRetry = func(_ int, err error) {
+		operationRetries.Add(context.WithoutCancel(ctx), 1, metric.WithAttributes(
+			attribute.String("provider", p.name),
+			attribute.String("operation", operation),
+			attribute.String("error_type", ErrorType(err)),
+		))
+	}
+	return policy
+}
+
+// IsTransient reports whether a KMS provider error may go away when the call is made
+// again. The caller's own cancellation is never transient; Retry stops on it before
+// asking.
+func IsTransient(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		if throttlingCodes[code] || transientCodes[code] {
+			return true
+		}
+	}
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && (respErr.HTTPStatusCode() >= 500 || respErr.HTTPStatusCode() == 429) {
+		return true
+	}
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorFault() == smithy.FaultServer
+	}
+	// A per-attempt timeout; Retry has already checked that the caller's context is live.
+	if errors.Is(err, context.DeadlineExceeded) || isBreakerTimeout(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
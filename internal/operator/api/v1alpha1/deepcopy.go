@@ -0,0 +1,88 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func (in *PolykeyKeySpec) DeepCopyInto(out *PolykeyKeySpec) {
+	*out = *in
+	if in.Tags != nil {
+		out.Tags = make(map[string]string, len(in.Tags))
+		for k, v := range in.Tags {
+			out.Tags[k] = v
+		}
+	}
+	if in.AuthorizedContexts != nil {
+		out.AuthorizedContexts = append([]string(nil), in.AuthorizedContexts...)
+	}
+	if in.RotationPeriod != nil {
+		period := *in.RotationPeriod
+		out.RotationPeriod = &period
+	}
+}
+
+func (in *PolykeyKeyStatus) DeepCopyInto(out *PolykeyKeyStatus) {
+	*out = *in
+	if in.VersionCreatedTime != nil {
+		out.VersionCreatedTime = in.VersionCreatedTime.DeepCopy()
+	}
+	if in.Tags != nil {
+		out.Tags = make(map[string]string, len(in.Tags))
+		for k, v := range in.Tags {
+			out.Tags[k] = v
+		}
+	}
+	if in.Conditions != nil {
+		out.Conditions = make([]metav1.Condition, len(in.Conditions))
+		for i := range in.Conditions {
+			in.Conditions[i].DeepCopyInto(&out.Conditions[i])
+		}
+	}
+}
+
+func (in *PolykeyKey) DeepCopyInto(out *PolykeyKey) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+func (in *PolykeyKey) DeepCopy() *PolykeyKey {
+	if in == nil {
+		return nil
+	}
+	out := new(PolykeyKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *PolykeyKey) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func (in *PolykeyKeyList) DeepCopyInto(out *PolykeyKeyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]PolykeyKey, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+func (in *PolykeyKeyList) DeepCopy() *PolykeyKeyList {
+	if in == nil {
+		return nil
+	}
+	out := new(PolykeyKeyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *PolykeyKeyList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
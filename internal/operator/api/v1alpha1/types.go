@@ -0,0 +1,94 @@
+// Package v1alpha1 holds the PolykeyKey custom resource, through which Kubernetes
+// manifests declare the Polykey keys a workload needs.
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupVersion is the API group and version of the resources in this package.
+var GroupVersion = schema.GroupVersion{Group: "polykey.spounge.ai", Version: "v1alpha1"}
+
+var (
+	schemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	// AddToScheme registers the resources of this package with a scheme.
+	AddToScheme = schemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(GroupVersion, &PolykeyKey{}, &PolykeyKeyList{})
+	metav1.AddToGroupVersion(scheme, GroupVersion)
+	return nil
+}
+
+// Deletion policies of a PolykeyKey.
+const (
+	// DeletionPolicyRetain leaves the key in Polykey when the resource is deleted.
+	DeletionPolicyRetain = "Retain"
+	// DeletionPolicyRevoke revokes the key when the resource is deleted.
+	DeletionPolicyRevoke = "Revoke"
+)
+
+// PolykeyKeySpec is the key a PolykeyKey declares.
+type PolykeyKeySpec struct {
+	// KeyType is the name of the key type, such as KEY_TYPE_AES_256. It cannot change once
+	// the key exists.
+	KeyType            string            `json:"keyType"`
+	Description        string            `json:"description,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	DataClassification string            `json:"dataClassification,omitempty"`
+	AuthorizedContexts []string          `json:"authorizedContexts,omitempty"`
+	// RotationPeriod rotates the key once this long has passed since its current
+	// version was created.
+	RotationPeriod *metav1.Duration `json:"rotationPeriod,omitempty"`
+	// RotationGeneration rotates the key once each time it is raised.
+	RotationGeneration int64 `json:"rotationGeneration,omitempty"`
+	// SecretName is the Secret the key reference is written to, the resource's name when
+	// empty.
+	SecretName string `json:"secretName,omitempty"`
+	// DeletionPolicy is Retain, the default, or Revoke.
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// PolykeyKeyStatus is the key a PolykeyKey was reconciled into.
+type PolykeyKeyStatus struct {
+	KeyID   string `json:"keyId,omitempty"`
+	Version int32  `json:"version,omitempty"`
+	// VersionCreatedTime is when the current version was created, from which the next
+	// periodic rotation is due.
+	VersionCreatedTime *metav1.Time `json:"versionCreatedTime,omitempty"`
+	// RotationGeneration is the spec's RotationGeneration the key was last rotated for.
+	RotationGeneration int64 `json:"rotationGeneration,omitempty"`
+	// Description and Tags are the metadata last applied to the key.
+	Description        string             `json:"description,omitempty"`
+	Tags               map[string]string  `json:"tags,omitempty"`
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// PolykeyKey declares a Polykey key, whose ID and version the operator writes to a
+// Secret.
+type PolykeyKey struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PolykeyKeySpec   `json:"spec,omitempty"`
+	Status PolykeyKeyStatus `json:"status,omitempty"`
+}
+
+// PolykeyKeyList is a list of PolykeyKeys.
+type PolykeyKeyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolykeyKey `json:"items"`
+}
+
+// SecretNameOrDefault returns the name of the Secret the key reference is written to.
+func (k *PolykeyKey) SecretNameOrDefault() string {
+	if k.Spec.SecretName != "" {
+		return k.Spec.SecretName
+	}
+	return k.Name
+}
@@ -0,0 +1,265 @@
+// Package operator reconciles PolykeyKey resources into Polykey keys: it creates the key
+// a resource declares, rotates it when due, keeps its description and tags in step, and
+// writes the key's ID and version to a Secret the workload mounts. The key material
+// itself never leaves Polykey.
+package operator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/operator/api/v1alpha1"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// finalizer holds a PolykeyKey with the Revoke deletion policy until its key is revoked.
+	finalizer = "polykey.spounge.ai/revoke"
+	// conditionReady reports whether the key and its Secret match the spec.
+	conditionReady = "Ready"
+
+	// Keys of the Secret a PolykeyKey writes.
+	SecretKeyID      = "keyId"
+	SecretKeyVersion = "keyVersion"
+)
+
+// RequesterSource supplies the requester context of the operator's Polykey client.
+type RequesterSource interface {
+	Requester(ctx context.Context) (*pk.RequesterContext, error)
+}
+
+// PolykeyKeyReconciler reconciles PolykeyKey resources with the keys of a Polykey server.
+type PolykeyKeyReconciler struct {
+	client.Client
+	Keys      pk.PolykeyServiceClient
+	Requester RequesterSource
+	Logger    *slog.Logger
+}
+
+// SetupWithManager registers the reconciler with mgr, watching PolykeyKeys and the
+// Secrets they own.
+func (r *PolykeyKeyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.PolykeyKey{}).
+		Owns(&corev1.Secret{}).
+		Complete(r)
+}
+
+func (r *PolykeyKeyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var key v1alpha1.PolykeyKey
+	if err := r.Get(ctx, req.NamespacedName, &key); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	logger := r.Logger.With("polykeyKey", req.String())
+	requester, err := r.Requester.Requester(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !key.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, &key, requester, logger)
+	}
+	if err := r.syncFinalizer(ctx, &key); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	requeueAfter, err := r.reconcileKey(ctx, &key, requester, logger)
+	if err == nil {
+		err = r.writeSecret(ctx, &key)
+	}
+
+	key.Status.ObservedGeneration = key.Generation
+	if err != nil {
+		meta.SetStatusCondition(&key.Status.Conditions, metav1.Condition{
+			Type: conditionReady, Status: metav1.ConditionFalse, Reason: "ReconcileFailed", Message: err.Error(), ObservedGeneration: key.Generation,
+		})
+	} else {
+		meta.SetStatusCondition(&key.Status.Conditions, metav1.Condition{
+			Type: conditionReady, Status: metav1.ConditionTrue, Reason: "Reconciled", Message: "key " + key.Status.KeyID + " is up to date", ObservedGeneration: key.Generation,
+		})
+	}
+	if statusErr := r.Status().Update(ctx, &key); statusErr != nil && err == nil {
+		err = statusErr
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, err
+}
+
+// reconcileKey creates, rotates and updates the key as the spec asks, and returns when
+// the next periodic rotation is due, zero when none is.
+func (r *PolykeyKeyReconciler) reconcileKey(ctx context.Context, key *v1alpha1.PolykeyKey, requester *pk.RequesterContext, logger *slog.Logger) (time.Duration, error) {
+	if key.Status.KeyID == "" {
+		if err := r.createKey(ctx, key, requester, logger); err != nil {
+			return 0, err
+		}
+	}
+
+	if due, _ := rotationDue(key); due {
+		resp, err := r.Keys.RotateKey(ctx, &pk.RotateKeyRequest{KeyId: key.Status.KeyID, RequesterContext: requester})
+		if err != nil {
+			return 0, fmt.Errorf("failed to rotate key %s: %w", key.Status.KeyID, err)
+		}
+		now := metav1.Now()
+		key.Status.Version = resp.GetNewVersion()
+		key.Status.VersionCreatedTime = &now
+		key.Status.RotationGeneration = key.Spec.RotationGeneration
+		logger.InfoContext(ctx, "rotated key", "keyId", key.Status.KeyID, "version", key.Status.Version)
+	}
+
+	if err := r.updateMetadata(ctx, key, requester); err != nil {
+		return 0, err
+	}
+
+	_, next := rotationDue(key)
+	return next, nil
+}
+
+// createKey creates the key and records it in the status at once, so that a failure
+// later in the reconcile does not create a second key.
+func (r *PolykeyKeyReconciler) createKey(ctx context.Context, key *v1alpha1.PolykeyKey, requester *pk.RequesterContext, logger *slog.Logger) error {
+	keyType, ok := pk.KeyType_value[key.Spec.KeyType]
+	if !ok {
+		return fmt.Errorf("unknown key type %q", key.Spec.KeyType)
+	}
+	resp, err := r.Keys.CreateKey(ctx, &pk.CreateKeyRequest{
+		KeyType:                   pk.KeyType(keyType),
+		RequesterContext:          requester,
+		Description:               key.Spec.Description,
+		Tags:                      key.Spec.Tags,
+		DataClassification:        key.Spec.DataClassification,
+		InitialAuthorizedContexts: key.Spec.AuthorizedContexts,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create key: %w", err)
+	}
+	now := metav1.Now()
+	key.Status.KeyID = resp.GetKeyId()
+	key.Status.Version = resp.GetMetadata().GetVersion()
+	key.Status.VersionCreatedTime = &now
+	key.Status.RotationGeneration = key.Spec.RotationGeneration
+	key.Status.Description = key.Spec.Description
+	key.Status.Tags = maps.Clone(key.Spec.Tags)
+	logger.InfoContext(ctx, "created key", "keyId", key.Status.KeyID)
+	if err := r.Status().Update(ctx, key); err != nil {
+		logger.ErrorContext(ctx, "created key not recorded, it will be created again", "keyId", key.Status.KeyID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// updateMetadata applies changes to the spec's description and tags.
+func (r *PolykeyKeyReconciler) updateMetadata(ctx context.Context, key *v1alpha1.PolykeyKey, requester *pk.RequesterContext) error {
+	add := make(map[string]string)
+	for name, value := range key.Spec.Tags {
+		if current, ok := key.Status.Tags[name]; !ok || current != value {
+			add[name] = value
+		}
+	}
+	var remove []string
+	for name := range key.Status.Tags {
+		if _, ok := key.Spec.Tags[name]; !ok {
+			remove = append(remove, name)
+		}
+	}
+	if key.Spec.Description == key.Status.Description && len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	_, err := r.Keys.UpdateKeyMetadata(ctx, &pk.UpdateKeyMetadataRequest{
+		KeyId:            key.Status.KeyID,
+		RequesterContext: requester,
+		Description:      &key.Spec.Description,
+		TagsToAdd:        add,
+		TagsToRemove:     remove,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the metadata of key %s: %w", key.Status.KeyID, err)
+	}
+	key.Status.Description = key.Spec.Description
+	key.Status.Tags = maps.Clone(key.Spec.Tags)
+	return nil
+}
+
+// rotationDue reports whether the key must be rotated now and, if not, how long until a
+// periodic rotation is due, zero when there is none.
+func rotationDue(key *v1alpha1.PolykeyKey) (bool, time.Duration) {
+	if key.Spec.RotationGeneration > key.Status.RotationGeneration {
+		return true, 0
+	}
+	if key.Spec.RotationPeriod == nil || key.Spec.RotationPeriod.Duration <= 0 || key.Status.VersionCreatedTime == nil {
+		return false, 0
+	}
+	remaining := time.Until(key.Status.VersionCreatedTime.Add(key.Spec.RotationPeriod.Duration))
+	if remaining <= 0 {
+		return true, 0
+	}
+	return false, remaining
+}
+
+// writeSecret writes the key's ID and version to the resource's Secret, owned by the
+// resource so that it goes away with it.
+func (r *PolykeyKeyReconciler) writeSecret(ctx context.Context, key *v1alpha1.PolykeyKey) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: key.SecretNameOrDefault(), Namespace: key.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Type = corev1.SecretTypeOpaque
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data[SecretKeyID] = []byte(key.Status.KeyID)
+		secret.Data[SecretKeyVersion] = []byte(strconv.Itoa(int(key.Status.Version)))
+		return controllerutil.SetControllerReference(key, secret, r.Scheme())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write secret %s: %w", secret.Name, err)
+	}
+	return nil
+}
+
+// syncFinalizer holds the resource for revocation when its deletion policy asks for it.
+func (r *PolykeyKeyReconciler) syncFinalizer(ctx context.Context, key *v1alpha1.PolykeyKey) error {
+	var changed bool
+	if key.Spec.DeletionPolicy == v1alpha1.DeletionPolicyRevoke {
+		changed = controllerutil.AddFinalizer(key, finalizer)
+	} else {
+		changed = controllerutil.RemoveFinalizer(key, finalizer)
+	}
+	if !changed {
+		return nil
+	}
+	return r.Update(ctx, key)
+}
+
+// finalize revokes the key of a deleted resource that holds the finalizer, and releases
+// the resource.
+func (r *PolykeyKeyReconciler) finalize(ctx context.Context, key *v1alpha1.PolykeyKey, requester *pk.RequesterContext, logger *slog.Logger) error {
+	if !controllerutil.ContainsFinalizer(key, finalizer) {
+		return nil
+	}
+	if key.Status.KeyID != "" {
+		_, err := r.Keys.RevokeKey(ctx, &pk.RevokeKeyRequest{
+			KeyId:            key.Status.KeyID,
+			RequesterContext: requester,
+			RevocationReason: "PolykeyKey " + key.Namespace + "/" + key.Name + " deleted",
+		})
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("failed to revoke key %s: %w", key.Status.KeyID, err)
+		}
+		logger.InfoContext(ctx, "revoked key", "keyId", key.Status.KeyID)
+	}
+	controllerutil.RemoveFinalizer(key, finalizer)
+	if err := r.Update(ctx, key); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -5,11 +5,23 @@ import (
 	"crypto/rand"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
 	"github.com/spounge-ai/polykey/internal/kms"
+	"github.com/spounge-ai/polykey/pkg/crypto"
 	"github.com/spounge-ai/polykey/pkg/memory"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/pipelines")
+
+var rotationQueueWait, _ = meter.Float64Histogram(
+	"polykey.rotation.queue_wait",
+	metric.WithDescription("Time rotation requests waited in the pipeline queue for a worker."),
+	metric.WithUnit("s"),
 )
 
 // KeyRotationRequest holds the data for a key rotation request.
@@ -18,6 +30,8 @@ type KeyRotationRequest struct {
 	KMSProvider        kms.KMSProvider
 	DEKPool            *memory.SecureDEKPool
 	GracePeriodSeconds int32
+	// GraceDeadline is when the version being replaced stops being readable.
+	GraceDeadline      time.Time
 	KeyType            pk.KeyType
 }
 
@@ -25,14 +39,44 @@ type KeyRotationRequest struct {
 type KeyRotationResult struct {
 	KeyID      domain.KeyID
 	RotatedKey *domain.Key
+	// KeyChecksum is the checksum of the new version's material.
+	KeyChecksum string
 	Error      error
 	GracePeriodSeconds int32
 }
 
+// KeyRotationFuture is the pending result of one rotation request. Every request gets
+// its own future, so concurrent callers never see each other's results.
+type KeyRotationFuture struct {
+	done chan KeyRotationResult
+}
+
+// Wait blocks until the rotation has finished or ctx is done. The worker does not
+// wait for the caller, so abandoning a future is safe.
+func (f *KeyRotationFuture) Wait(ctx context.Context) (KeyRotationResult, error) {
+	select {
+	case result := <-f.done:
+		return result, nil
+	case <-ctx.Done():
+		return KeyRotationResult{}, ctx.Err()
+	}
+}
+
+type pendingRotation struct {
+	// reqCtx is the context of the caller that queued the rotation.
+	reqCtx   context.Context
+	req      KeyRotationRequest
+	future   *KeyRotationFuture
+	queuedAt time.Time
+}
+
+func newPendingRotation(reqCtx context.Context, req KeyRotationRequest) pendingRotation {
+	return pendingRotation{reqCtx: reqCtx, req: req, future: &KeyRotationFuture{done: make(chan KeyRotationResult, 1)}, queuedAt: time.Now()}
+}
+
 // KeyRotationPipeline manages the concurrent processing of key rotations.
 type KeyRotationPipeline struct {
-	requests    chan KeyRotationRequest
-	results     chan KeyRotationResult
+	requests    chan pendingRotation
 	keyRepo     domain.KeyRepository
 	logger      *slog.Logger
 	workerCount int
@@ -41,8 +85,7 @@ type KeyRotationPipeline struct {
 // NewKeyRotationPipeline creates a new key rotation pipeline.
 func NewKeyRotationPipeline(keyRepo domain.KeyRepository, logger *slog.Logger, workerCount, queueDepth int) *KeyRotationPipeline {
 	return &KeyRotationPipeline{
-		requests:    make(chan KeyRotationRequest, queueDepth),
-		results:     make(chan KeyRotationResult, queueDepth),
+		requests:    make(chan pendingRotation, queueDepth),
 		keyRepo:     keyRepo,
 		logger:      logger,
 		workerCount: workerCount,
@@ -56,20 +99,37 @@ func (p *KeyRotationPipeline) Start(ctx context.Context) {
 	}
 }
 
-// Enqueue adds a new key rotation request to the pipeline.
-// It returns false if the queue is full (non-blocking).
-func (p *KeyRotationPipeline) Enqueue(req KeyRotationRequest) bool {
+// Enqueue adds a new key rotation request to the pipeline and returns the future for
+// its result. It returns false if the queue is full (non-blocking). The rotation runs
+// with the values of ctx, such as the namespace and the caller, and is skipped if ctx
+// is done by the time a worker picks it up. Once started, it runs to completion even if
+// the caller gives up waiting, so an abandoned rotation may still take effect.
+func (p *KeyRotationPipeline) Enqueue(ctx context.Context, req KeyRotationRequest) (*KeyRotationFuture, bool) {
+	pending := newPendingRotation(ctx, req)
 	select {
-	case p.requests <- req:
-		return true
+	case p.requests <- pending:
+		return pending.future, true
 	default:
-		return false // Queue is full
+		return nil, false // Queue is full
+	}
+}
+
+// Submit adds a rotation request to the pipeline, waiting for room in the queue
+// until ctx is done. Bulk callers use it to be throttled by the workers instead of
+// being turned away. The rotation uses ctx like Enqueue does.
+func (p *KeyRotationPipeline) Submit(ctx context.Context, req KeyRotationRequest) (*KeyRotationFuture, error) {
+	pending := newPendingRotation(ctx, req)
+	select {
+	case p.requests <- pending:
+		return pending.future, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 }
 
-// Results returns the channel for reading rotation results.
-func (p *KeyRotationPipeline) Results() <-chan KeyRotationResult {
-	return p.results
+// Backlog reports how many rotation requests are waiting and how many the queue can hold.
+func (p *KeyRotationPipeline) Backlog() (pending, capacity int) {
+	return len(p.requests), cap(p.requests)
 }
 
 // worker is a pipeline stage that processes key rotation requests.
@@ -78,27 +138,43 @@ func (p *KeyRotationPipeline) worker(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case req := <-p.requests:
-			rotatedKey, err := p.processRotation(ctx, req)
-			result := KeyRotationResult{RotatedKey: rotatedKey, Error: err, KeyID: req.KeyID, GracePeriodSeconds: req.GracePeriodSeconds}
-
-			// Send the result back
-			select {
-			case p.results <- result:
-			case <-ctx.Done():
-				// If the context is cancelled, don't block on sending the result.
-				return
+		case pending := <-p.requests:
+			rotationQueueWait.Record(ctx, time.Since(pending.queuedAt).Seconds())
+			req := pending.req
+			var rotatedKey *domain.Key
+			var checksum string
+			err := pending.reqCtx.Err()
+			if err == nil {
+				rotatedKey, checksum, err = p.runRotation(ctx, pending.reqCtx, req)
 			}
+			// The future is buffered for exactly this one result, so this never blocks.
+			pending.future.done <- KeyRotationResult{RotatedKey: rotatedKey, KeyChecksum: checksum, Error: err, KeyID: req.KeyID, GracePeriodSeconds: req.GracePeriodSeconds}
 		}
 	}
 }
 
-// processRotation contains the actual logic for rotating a key.
-func (p *KeyRotationPipeline) processRotation(ctx context.Context, req KeyRotationRequest) (*domain.Key, error) {
+// runRotation processes req with the values of reqCtx but without its cancellation,
+// so the rotation is not torn down halfway when the caller leaves. It is still bounded
+// by the worker's ctx, which stops it on shutdown.
+func (p *KeyRotationPipeline) runRotation(ctx, reqCtx context.Context, req KeyRotationRequest) (*domain.Key, string, error) {
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(reqCtx))
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	return p.processRotation(jobCtx, req)
+}
+
+// processRotation contains the actual logic for rotating a key. It returns the new
+// version and the checksum of its material.
+func (p *KeyRotationPipeline) processRotation(ctx context.Context, req KeyRotationRequest) (*domain.Key, string, error) {
 	currentKey, err := p.keyRepo.GetKey(ctx, req.KeyID)
 	if err != nil {
 		p.logger.ErrorContext(ctx, "failed to get current key for rotation", "keyId", req.KeyID, "error", err)
-		return nil, fmt.Errorf("failed to get current key: %w", err)
+		return nil, "", fmt.Errorf("failed to get current key: %w", err)
+	}
+
+	if err := domain.ValidateKeyTransition(currentKey.Status, domain.KeyStatusRotated); err != nil {
+		return nil, "", err
 	}
 
 	newDEK := req.DEKPool.Get()
@@ -106,21 +182,25 @@ func (p *KeyRotationPipeline) processRotation(ctx context.Context, req KeyRotati
 
 	if _, err := rand.Read(newDEK); err != nil {
 		p.logger.ErrorContext(ctx, "failed to generate new DEK", "error", err)
-		return nil, fmt.Errorf("failed to generate new DEK: %w", err)
+		return nil, "", fmt.Errorf("failed to generate new DEK: %w", err)
 	}
+	checksum := crypto.KeyChecksum(newDEK)
 
 	encryptedNewDEK, err := req.KMSProvider.EncryptDEK(ctx, newDEK, currentKey)
 	if err != nil {
 		p.logger.ErrorContext(ctx, "failed to encrypt new DEK", "error", err)
-		return nil, fmt.Errorf("failed to encrypt new DEK: %w", err)
+		return nil, "", fmt.Errorf("failed to encrypt new DEK: %w", err)
 	}
 
-	rotatedKey, err := p.keyRepo.RotateKey(ctx, req.KeyID, encryptedNewDEK)
+	rotatedKey, err := p.keyRepo.RotateKey(ctx, req.KeyID, encryptedNewDEK, req.GraceDeadline)
 	if err != nil {
 		p.logger.ErrorContext(ctx, "failed to rotate key in repository", "keyId", req.KeyID, "error", err)
-		return nil, fmt.Errorf("failed to rotate key: %w", err)
+		return nil, "", fmt.Errorf("failed to rotate key: %w", err)
+	}
+	if rotatedKey == nil {
+		return nil, "", fmt.Errorf("failed to rotate key: repository returned no key for %s", req.KeyID)
 	}
 
 	p.logger.InfoContext(ctx, "key rotated via pipeline", "keyId", req.KeyID, "newVersion", rotatedKey.Version)
-	return rotatedKey, nil
+	return rotatedKey, checksum, nil
 }
@@ -0,0 +1,27 @@
+package polykeyclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"google.golang.org/grpc/metadata"
+)
+
+// WithAuthenticateChallenge returns ctx carrying a fresh anti-replay challenge for an
+// Authenticate request as clientID with apiKey: a random nonce and the current time,
+// signed with apiKey.
+func WithAuthenticateChallenge(ctx context.Context, clientID, apiKey string) context.Context {
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return metadata.AppendToOutgoingContext(ctx,
+		domain.AuthenticateNonceHeader, encoded,
+		domain.AuthenticateTimestampHeader, timestamp,
+		domain.AuthenticateSignatureHeader, domain.SignAuthenticateChallenge(apiKey, clientID, encoded, timestamp),
+	)
+}
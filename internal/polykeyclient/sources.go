@@ -0,0 +1,125 @@
+package polykeyclient
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+)
+
+const (
+	// maxSources is the number of clients whose token sources are kept; the least recently
+	// used is dropped to make room for another.
+	maxSources = 1024
+	// sourceIdleTTL is how long the token source of a client that makes no calls is kept.
+	sourceIdleTTL = time.Hour
+)
+
+// TokenSources keeps a token source per client, for services that call the API for many
+// clients with the credentials of each request, such as the CSI provider. Only sources
+// that authenticated are kept, so that a request with a wrong API key neither replaces
+// the source of a client nor takes up room.
+type TokenSources struct {
+	conn grpc.ClientConnInterface
+
+	mu      sync.Mutex
+	sources map[string]*list.Element
+	// lru orders the sources from the most to the least recently used.
+	lru *list.List
+}
+
+// clientSource is the token source of a client, with a digest of the API key it was
+// created with.
+type clientSource struct {
+	clientID  string
+	tokens    *TokenSource
+	keyDigest [sha256.Size]byte
+	usedAt    time.Time
+}
+
+// NewTokenSources creates the token sources of clients authenticating on conn.
+func NewTokenSources(conn grpc.ClientConnInterface) *TokenSources {
+	return &TokenSources{conn: conn, sources: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Get returns the token source of clientID and its requester context. When apiKey is
+// not the key the kept source was created with, or there is none, a new source is
+// authenticated with it and kept only if that succeeds.
+func (s *TokenSources) Get(ctx context.Context, clientID, apiKey string) (*TokenSource, *pk.RequesterContext, error) {
+	digest := sha256.Sum256([]byte(apiKey))
+	if tokens := s.lookup(clientID, digest); tokens != nil {
+		requester, err := tokens.Requester(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tokens, requester, nil
+	}
+
+	tokens := NewTokenSource(clientID, apiKey)
+	tokens.SetConn(s.conn)
+	requester, err := tokens.Requester(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.store(&clientSource{clientID: clientID, tokens: tokens, keyDigest: digest})
+	return tokens, requester, nil
+}
+
+// lookup returns the kept source of clientID if it was created with the key of digest,
+// marking it used.
+func (s *TokenSources) lookup(clientID string, digest [sha256.Size]byte) *TokenSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictIdle()
+	elem, ok := s.sources[clientID]
+	if !ok {
+		return nil
+	}
+	source := elem.Value.(*clientSource)
+	if source.keyDigest != digest {
+		return nil
+	}
+	source.usedAt = time.Now()
+	s.lru.MoveToFront(elem)
+	return source.tokens
+}
+
+// store keeps source, replacing the one of its client and dropping the least recently
+// used when there are too many.
+func (s *TokenSources) store(source *clientSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	source.usedAt = time.Now()
+	if elem, ok := s.sources[source.clientID]; ok {
+		elem.Value = source
+		s.lru.MoveToFront(elem)
+		return
+	}
+	s.sources[source.clientID] = s.lru.PushFront(source)
+	for s.lru.Len() > maxSources {
+		s.remove(s.lru.Back())
+	}
+}
+
+// evictIdle drops the sources unused for sourceIdleTTL.
+func (s *TokenSources) evictIdle() {
+	for elem := s.lru.Back(); elem != nil && time.Since(elem.Value.(*clientSource).usedAt) > sourceIdleTTL; elem = s.lru.Back() {
+		s.remove(elem)
+	}
+}
+
+func (s *TokenSources) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.sources, elem.Value.(*clientSource).clientID)
+}
+
+// Len returns the number of token sources kept.
+func (s *TokenSources) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
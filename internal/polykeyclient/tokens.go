@@ -0,0 +1,159 @@
+// Package polykeyclient authenticates long-running clients of the Polykey API, such as
+// the Kubernetes operator and the CSI provider, with client credentials.
+package polykeyclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	cmn "github.com/spounge-ai/spounge-proto/gen/go/common/v2"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// renewBefore is how long before its expiry an access token is replaced, at least. Tokens
+// are replaced once a fifth of their lifetime is left, so that clients with long-lived
+// tokens, such as enterprise workloads, renew early enough to ride out an outage of the
+// server with the token they hold.
+const renewBefore = time.Minute
+
+// renewRetry is how long a client waits to renew its token again after failing to, while
+// the token is still valid.
+const renewRetry = 10 * time.Second
+
+// TokenSource exchanges client credentials for access tokens, and renews them before
+// they expire or when the server rejects them.
+type TokenSource struct {
+	clientID string
+	apiKey   string
+
+	mu      sync.Mutex
+	client  pk.PolykeyServiceClient
+	token   string
+	expires time.Time
+	renewAt time.Time
+	tier    cmn.ClientTier
+}
+
+// NewTokenSource creates a source authenticating as clientID with apiKey. It must be
+// given a connection with SetConn before its first token.
+func NewTokenSource(clientID, apiKey string) *TokenSource {
+	return &TokenSource{clientID: clientID, apiKey: apiKey}
+}
+
+// SetConn sets the connection tokens are requested on, usually the one the source's
+// interceptor is installed on.
+func (s *TokenSource) SetConn(conn grpc.ClientConnInterface) {
+	s.mu.Lock()
+	s.client = pk.NewPolykeyServiceClient(conn)
+	s.mu.Unlock()
+}
+
+// Token returns a valid access token, authenticating when there is none.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.renewAt) {
+		return s.token, nil
+	}
+	if s.client == nil {
+		return "", fmt.Errorf("token source has no connection")
+	}
+	resp, err := s.client.Authenticate(WithAuthenticateChallenge(ctx, s.clientID, s.apiKey), &pk.AuthenticateRequest{ClientId: s.clientID, ApiKey: s.apiKey})
+	if err != nil {
+		// A token that is being renewed early is still good until it expires; try again
+		// a little later rather than on every call.
+		if s.token != "" && time.Until(s.expires) > renewBefore {
+			s.renewAt = time.Now().Add(renewRetry)
+			return s.token, nil
+		}
+		return "", fmt.Errorf("authentication as %s failed: %w", s.clientID, err)
+	}
+	lifetime := time.Duration(resp.GetExpiresIn()) * time.Second
+	s.token = resp.GetAccessToken()
+	s.expires = time.Now().Add(lifetime)
+	s.renewAt = s.expires.Add(-max(lifetime/5, renewBefore))
+	s.tier = resp.GetClientTier()
+	return s.token, nil
+}
+
+// Requester returns the requester context of the authenticated client, with the tier
+// its token was issued for, authenticating when there is no token yet.
+func (s *TokenSource) Requester(ctx context.Context) (*pk.RequesterContext, error) {
+	if _, err := s.Token(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &pk.RequesterContext{ClientIdentity: s.clientID, ClientTier: s.tier}, nil
+}
+
+// invalidate drops token, unless it has been replaced already.
+func (s *TokenSource) invalidate(token string) {
+	s.mu.Lock()
+	if s.token == token {
+		s.token = ""
+	}
+	s.mu.Unlock()
+}
+
+// Invoke runs call with a context carrying an access token, and once more with a new
+// token if the server rejects it as Unauthenticated.
+func (s *TokenSource) Invoke(ctx context.Context, call func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		token, err := s.Token(ctx)
+		if err != nil {
+			return err
+		}
+		err = call(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token))
+		if status.Code(err) != codes.Unauthenticated || attempt > 0 {
+			return err
+		}
+		s.invalidate(token)
+	}
+}
+
+// UnaryInterceptor returns a client interceptor that makes every call but Authenticate
+// through Invoke.
+func (s *TokenSource) UnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method == pk.PolykeyService_Authenticate_FullMethodName {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return s.Invoke(ctx, func(ctx context.Context) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+// StreamInterceptor returns a client interceptor that opens every stream with an access
+// token. Unlike unary calls, a stream the server rejects is not retried: the rejection
+// only arrives with its first message.
+func (s *TokenSource) StreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		token, err := s.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token), desc, cc, method, opts...)
+	}
+}
+
+// Dial connects to address with opts, authenticating every call through source.
+func Dial(address string, source *TokenSource, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append(opts,
+		grpc.WithChainUnaryInterceptor(source.UnaryInterceptor()),
+		grpc.WithChainStreamInterceptor(source.StreamInterceptor()),
+	)
+	conn, err := grpc.NewClient(address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	source.SetConn(conn)
+	return conn, nil
+}
@@ -0,0 +1,16 @@
+package polykeyclient
+
+import (
+	"context"
+	"strings"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"google.golang.org/grpc/metadata"
+)
+
+// WithUpdateMask returns ctx carrying the field mask of an UpdateKeyMetadata request:
+// only the KeyMetadata fields named by paths are updated, and those the request leaves
+// unset are cleared.
+func WithUpdateMask(ctx context.Context, paths ...string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, domain.UpdateMaskHeader, strings.Join(paths, ","))
+}
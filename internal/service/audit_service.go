@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/pkg/clock"
+)
+
+const (
+	defaultAuditPageSize   = 100
+	maxAuditPageSize       = 1000
+	defaultAuditRestoreFor = 7 * 24 * time.Hour
+)
+
+// AuditService exposes the audit trail to administrators.
+type AuditService interface {
+	VerifyAuditIntegrity(ctx context.Context) (*domain.AuditIntegrityReport, error)
+	QueryAuditEvents(ctx context.Context, query domain.AuditQuery, pageToken string) ([]*domain.AuditEvent, string, error)
+	RestoreAuditArchives(ctx context.Context, from, to time.Time) (*domain.AuditRestoreResult, error)
+}
+
+type auditService struct {
+	auditRepo  domain.AuditRepository
+	archives   domain.AuditArchiveStore
+	restoreFor time.Duration
+	pageTokens pageTokens
+	logger     *slog.Logger
+	clock      clock.Clock
+}
+
+// NewAuditService creates a new audit service. archives is nil when audit archiving is
+// disabled; restoreFor is how long restored events are kept. Page tokens are signed with
+// a key derived from pageTokenSecret, which every replica must share.
+func NewAuditService(auditRepo domain.AuditRepository, archives domain.AuditArchiveStore, restoreFor time.Duration, pageTokenSecret string, logger *slog.Logger, clk clock.Clock) AuditService {
+	if restoreFor <= 0 {
+		restoreFor = defaultAuditRestoreFor
+	}
+	return &auditService{
+		auditRepo:  auditRepo,
+		archives:   archives,
+		restoreFor: restoreFor,
+		pageTokens: newPageTokens(pageTokenSecret),
+		logger:     logger,
+		clock:      clk,
+	}
+}
+
+// VerifyAuditIntegrity checks the audit hash chain. A broken chain is reported in the
+// result, not as an error, and is logged at error level for alerting.
+func (s *auditService) VerifyAuditIntegrity(ctx context.Context) (*domain.AuditIntegrityReport, error) {
+	ctx, span := tracer.Start(ctx, "VerifyAuditIntegrity")
+	defer span.End()
+
+	report, err := s.auditRepo.VerifyAuditIntegrity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Valid {
+		s.logger.ErrorContext(ctx, "audit chain integrity check failed", "brokenAt", report.BrokenAtSequence, "reason", report.Reason)
+	} else {
+		s.logger.InfoContext(ctx, "audit chain verified", "events", report.CheckedEvents, "first", report.FirstSequence, "last", report.LastSequence)
+	}
+	return report, nil
+}
+
+// QueryAuditEvents returns one page of matching audit events, newest first, and the token
+// of the next page, which is empty on the last page. query.Limit is the page size; it
+// defaults to 100 and is capped at 1000. query.After is taken from pageToken.
+func (s *auditService) QueryAuditEvents(ctx context.Context, query domain.AuditQuery, pageToken string) ([]*domain.AuditEvent, string, error) {
+	ctx, span := tracer.Start(ctx, "QueryAuditEvents")
+	defer span.End()
+
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, "", fmt.Errorf("%w: from must be before to", app_errors.ErrInvalidInput)
+	}
+	pageSize := query.Limit
+	if pageSize <= 0 {
+		pageSize = defaultAuditPageSize
+	}
+	pageSize = min(pageSize, maxAuditPageSize)
+
+	query.After = nil
+	query.Limit = 0
+	filters := query
+	if pageToken != "" {
+		cursor, err := s.decodeAuditPageToken(filters, pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		query.After = cursor
+	}
+
+	// One extra row tells whether another page follows.
+	query.Limit = pageSize + 1
+	events, err := s.auditRepo.QueryAuditEvents(ctx, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var nextPageToken string
+	if len(events) > pageSize {
+		events = events[:pageSize]
+		last := events[pageSize-1]
+		nextPageToken = s.encodeAuditPageToken(filters, domain.AuditCursor{Timestamp: last.Timestamp, ID: last.ID})
+	}
+	return events, nextPageToken, nil
+}
+
+// RestoreAuditArchives puts the archived events logged in [from, to) back into the
+// database, where QueryAuditEvents finds them until the restore ends. Archives in cold
+// storage are retrieved first; they are reported as pending and the call should be
+// repeated once S3 has made them readable, which for Glacier takes hours.
+func (s *auditService) RestoreAuditArchives(ctx context.Context, from, to time.Time) (*domain.AuditRestoreResult, error) {
+	ctx, span := tracer.Start(ctx, "RestoreAuditArchives")
+	defer span.End()
+
+	if s.archives == nil {
+		return nil, app_errors.ErrAuditArchivingDisabled
+	}
+	if from.IsZero() || to.IsZero() || !from.Before(to) {
+		return nil, fmt.Errorf("%w: from and to are required and from must be before to", app_errors.ErrInvalidInput)
+	}
+
+	archives, err := s.auditRepo.ListAuditArchives(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.AuditRestoreResult{Archives: len(archives), RestoredUntil: s.clock.Now().Add(s.restoreFor)}
+	for _, archive := range archives {
+		events, err := s.archives.GetAuditArchive(ctx, archive.ObjectKey)
+		if errors.Is(err, app_errors.ErrAuditArchiveNotReady) {
+			result.Pending = append(result.Pending, archive.ObjectKey)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		restored, err := s.auditRepo.RestoreAuditEvents(ctx, archive, events, result.RestoredUntil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore audit archive %s: %w", archive.ObjectKey, err)
+		}
+		result.RestoredEvents += restored
+	}
+
+	s.logger.InfoContext(ctx, "restored audit archives", "from", from, "to", to, "archives", result.Archives,
+		"events", result.RestoredEvents, "pending", len(result.Pending), "until", result.RestoredUntil)
+	return result, nil
+}
+
+// auditPageTokens is the list the page tokens of QueryAuditEvents belong to.
+const auditPageTokens = "audit_events"
+
+// encodeAuditPageToken returns the page token of cursor, bound to the filters of the
+// query, which has neither a cursor nor a limit.
+func (s *auditService) encodeAuditPageToken(filters domain.AuditQuery, cursor domain.AuditCursor) string {
+	return s.pageTokens.encode(auditPageTokens, filters, cursor.Timestamp.UTC().Format(time.RFC3339Nano)+"|"+cursor.ID)
+}
+
+func (s *auditService) decodeAuditPageToken(filters domain.AuditQuery, token string) (*domain.AuditCursor, error) {
+	position, err := s.pageTokens.decode(auditPageTokens, filters, token)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, id, ok := strings.Cut(position, "|")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed page token", app_errors.ErrInvalidInput)
+	}
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed page token", app_errors.ErrInvalidInput)
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: malformed page token", app_errors.ErrInvalidInput)
+	}
+	return &domain.AuditCursor{Timestamp: t, ID: id}, nil
+}
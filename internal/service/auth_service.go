@@ -2,55 +2,138 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/x509"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
 	"github.com/spounge-ai/polykey/internal/infra/auth"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/pkg/cache"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var authenticateFailures, _ = meter.Int64Counter(
+	"polykey.auth.authenticate_failures",
+	metric.WithDescription("Number of failed Authenticate attempts, by reason: invalid_credentials, invalid_challenge, invalid_otp, replayed or throttled."),
+)
+
 // AuthenticationResult is a domain-specific struct to hold the result of an authentication attempt.
 // This decouples the service layer from the transport layer's protobuf types.
 type AuthenticationResult struct {
 	AccessToken string
 	TokenType   string
 	ExpiresIn   int64
+	// ClientTier is the tier of the client, empty when it has none.
+	ClientTier domain.KeyTier
+}
+
+// AuthenticationRequest is a presentation of client credentials, with the anti-replay
+// challenge and one-time password it carries, if any, the IP it came from and the client
+// certificate of its connection, if any.
+type AuthenticationRequest struct {
+	ClientID   string
+	APIKey     string
+	Challenge  *domain.AuthenticateChallenge
+	OTP        string
+	SourceIP   string
+	ClientCert *x509.Certificate
 }
 
 // AuthService defines the interface for the authentication business logic.
 type AuthService interface {
-	Authenticate(ctx context.Context, clientID, clientSecret string) (*AuthenticationResult, error)
+	Authenticate(ctx context.Context, req AuthenticationRequest) (*AuthenticationResult, error)
 }
 
 type authService struct {
 	clientStore  domain.ClientStore
 	tokenManager *auth.TokenManager
-	tokenTTL     time.Duration
+	tokens       config.TokenConfig
+	cfg          config.AuthenticateConfig
+	clock        clock.Clock
+
+	// nonces holds the nonces used within the clock skew, by client ID and nonce.
+	nonces cache.Store[string, struct{}]
+	// failures counts the failed attempts of each client ID and source IP in the
+	// current window.
+	mu       sync.Mutex
+	failures map[string]*failureWindow
+}
+
+type failureWindow struct {
+	count int
+	ends  time.Time
 }
 
-// NewAuthService creates a new authentication service.
-func NewAuthService(clientStore domain.ClientStore, tokenManager *auth.TokenManager, tokenTTL time.Duration) AuthService {
+// NewAuthService creates a new authentication service, issuing tokens with the lifetimes
+// of tokens.
+func NewAuthService(clientStore domain.ClientStore, tokenManager *auth.TokenManager, tokens config.TokenConfig, cfg config.AuthenticateConfig, clk clock.Clock) AuthService {
 	return &authService{
 		clientStore:  clientStore,
 		tokenManager: tokenManager,
-		tokenTTL:     tokenTTL,
+		tokens:       tokens,
+		cfg:          cfg,
+		clock:        clk,
+		nonces: cache.New(
+			cache.WithCleanupInterval[string, struct{}](time.Minute),
+		),
+		failures: make(map[string]*failureWindow),
 	}
 }
 
-// Authenticate verifies client credentials and issues a JWT upon success.
-func (s *authService) Authenticate(ctx context.Context, clientID, clientSecret string) (*AuthenticationResult, error) {
-	client, err := s.clientStore.FindClientByID(ctx, clientID)
+// Authenticate verifies client credentials and issues a JWT upon success, bound to the
+// client certificate of the request when it has one. A request carrying a valid one-time
+// password of the client gets a token with the otp authentication method, which
+// satisfies the step-up keys require; a wrong or reused one fails the attempt. Clients
+// and source IPs with too many recent failures are refused with ErrRateLimit, to be
+// retried once their failure window ends, before their credentials are checked, and a
+// challenge that is stale, badly signed or already used fails the attempt.
+func (s *authService) Authenticate(ctx context.Context, req AuthenticationRequest) (*AuthenticationResult, error) {
+	now := s.clock.Now()
+	if wait := s.throttled(now, failureKeys(req)); wait > 0 {
+		authenticateFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "throttled")))
+		return nil, app_errors.WithRetryAfter(fmt.Errorf("%w: too many failed attempts", app_errors.ErrRateLimit), wait)
+	}
+
+	client, err := s.clientStore.FindClientByID(ctx, req.ClientID)
 	if err != nil {
+		s.recordFailure(ctx, now, req, "invalid_credentials")
 		return nil, fmt.Errorf("authentication failed: %w", err) // Consider a more generic error type here
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(client.HashedAPIKey), []byte(clientSecret))
+	err = bcrypt.CompareHashAndPassword([]byte(client.HashedAPIKey), []byte(req.APIKey))
 	if err != nil {
+		s.recordFailure(ctx, now, req, "invalid_credentials")
 		return nil, fmt.Errorf("authentication failed: invalid credentials")
 	}
 
-	accessToken, err := s.tokenManager.GenerateToken(client.ID, client.Permissions, s.tokenTTL)
+	if reason, err := s.checkChallenge(ctx, now, req); err != nil {
+		s.recordFailure(ctx, now, req, reason)
+		return nil, err
+	}
+
+	methods := []string{auth.AuthMethodPassword}
+	if req.OTP != "" {
+		if reason, err := s.checkOTP(ctx, now, client, req.OTP); err != nil {
+			s.recordFailure(ctx, now, req, reason)
+			return nil, err
+		}
+		methods = append(methods, auth.AuthMethodOTP)
+	}
+
+	opts := []auth.TokenOption{auth.WithNamespace(client.Namespace), auth.WithAuthMethods(methods...)}
+	if req.ClientCert != nil {
+		opts = append(opts, auth.WithCertificateBinding(req.ClientCert))
+	}
+	ttl := s.tokenTTL(client)
+	accessToken, err := s.tokenManager.GenerateToken(client.ID, client.Permissions, ttl, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
@@ -58,6 +141,133 @@ func (s *authService) Authenticate(ctx context.Context, clientID, clientSecret s
 	return &AuthenticationResult{
 		AccessToken: accessToken,
 		TokenType:   "Bearer",
-		ExpiresIn:   int64(s.tokenTTL.Seconds()),
+		ExpiresIn:   int64(ttl.Seconds()),
+		ClientTier:  client.Tier,
 	}, nil
 }
+
+// tokenTTL returns the lifetime of the tokens of client: its own, else its tier's, else
+// the default, capped by the maximum.
+func (s *authService) tokenTTL(client *domain.Client) time.Duration {
+	ttl := s.tokens.TTL
+	if tierTTL, ok := s.tokens.TierTTLs[string(client.Tier)]; ok && tierTTL > 0 {
+		ttl = tierTTL
+	}
+	if client.TokenTTL > 0 {
+		ttl = client.TokenTTL
+	}
+	if s.tokens.MaxTTL > 0 && ttl > s.tokens.MaxTTL {
+		ttl = s.tokens.MaxTTL
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return ttl
+}
+
+// checkChallenge verifies the challenge of req, once its credentials are known to be
+// valid, and consumes its nonce. It returns the failure reason with the error.
+func (s *authService) checkChallenge(ctx context.Context, now time.Time, req AuthenticationRequest) (string, error) {
+	challenge := req.Challenge
+	if challenge == nil {
+		if s.cfg.RequireChallenge {
+			return "invalid_challenge", fmt.Errorf("%w: an authentication challenge is required", app_errors.ErrAuthentication)
+		}
+		return "", nil
+	}
+
+	seconds, err := strconv.ParseInt(challenge.Timestamp, 10, 64)
+	if err != nil || challenge.Nonce == "" {
+		return "invalid_challenge", fmt.Errorf("%w: malformed authentication challenge", app_errors.ErrAuthentication)
+	}
+	expected := domain.SignAuthenticateChallenge(req.APIKey, req.ClientID, challenge.Nonce, challenge.Timestamp)
+	if !hmac.Equal([]byte(expected), []byte(challenge.Signature)) {
+		return "invalid_challenge", fmt.Errorf("%w: authentication challenge signature does not match", app_errors.ErrAuthentication)
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)).Abs(); skew > s.cfg.MaxClockSkew {
+		return "invalid_challenge", fmt.Errorf("%w: authentication challenge is %s off the server clock", app_errors.ErrAuthentication, skew.Round(time.Second))
+	}
+
+	// A nonce only needs remembering while its timestamp is within the skew.
+	key := req.ClientID + "\n" + challenge.Nonce
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, used := s.nonces.Get(ctx, key); used {
+		return "replayed", fmt.Errorf("%w: authentication challenge was already used", app_errors.ErrAuthentication)
+	}
+	s.nonces.Set(ctx, key, struct{}{}, 2*s.cfg.MaxClockSkew)
+	return "", nil
+}
+
+// checkOTP verifies a one-time password of client and consumes it, so that a code seen
+// in transit cannot be presented again within its validity. It returns the failure
+// reason with the error.
+func (s *authService) checkOTP(ctx context.Context, now time.Time, client *domain.Client, code string) (string, error) {
+	if client.TOTPSecret == "" {
+		return "invalid_otp", fmt.Errorf("%w: client has no one-time passwords", app_errors.ErrAuthentication)
+	}
+	step, ok := auth.VerifyTOTP(client.TOTPSecret, code, now)
+	if !ok {
+		return "invalid_otp", fmt.Errorf("%w: one-time password does not match", app_errors.ErrAuthentication)
+	}
+
+	// A code only needs remembering while it is accepted.
+	key := "otp\n" + client.ID + "\n" + strconv.FormatInt(step, 10)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, used := s.nonces.Get(ctx, key); used {
+		return "replayed", fmt.Errorf("%w: one-time password was already used", app_errors.ErrAuthentication)
+	}
+	s.nonces.Set(ctx, key, struct{}{}, auth.TOTPValidity)
+	return "", nil
+}
+
+// failureKeys are the keys failed attempts of req are counted under: its client ID and,
+// when known, its source IP.
+func failureKeys(req AuthenticationRequest) []string {
+	keys := []string{"client:" + req.ClientID}
+	if req.SourceIP != "" {
+		keys = append(keys, "ip:"+req.SourceIP)
+	}
+	return keys
+}
+
+// throttled returns how long until none of keys is at the failure limit of its window,
+// zero when none is now.
+func (s *authService) throttled(now time.Time, keys []string) time.Duration {
+	if s.cfg.MaxFailures <= 0 {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var wait time.Duration
+	for _, key := range keys {
+		if window, ok := s.failures[key]; ok && now.Before(window.ends) && window.count >= s.cfg.MaxFailures {
+			wait = max(wait, window.ends.Sub(now))
+		}
+	}
+	return wait
+}
+
+// recordFailure counts a failed attempt against the client ID and source IP of req.
+func (s *authService) recordFailure(ctx context.Context, now time.Time, req AuthenticationRequest, reason string) {
+	authenticateFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+	if s.cfg.MaxFailures <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, window := range s.failures {
+		if !now.Before(window.ends) {
+			delete(s.failures, key)
+		}
+	}
+	for _, key := range failureKeys(req) {
+		window, ok := s.failures[key]
+		if !ok {
+			window = &failureWindow{ends: now.Add(s.cfg.FailureWindow)}
+			s.failures[key] = window
+		}
+		window.count++
+	}
+}
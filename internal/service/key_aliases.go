@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+// ApplyKey makes the key of spec.Alias match spec, creating it if the alias names no
+// key, or only a revoked one, and otherwise replacing its description and tags with the
+// spec's. Applying the same spec again changes nothing, so a declarative tool can apply
+// it as often as it likes. The key type of an existing key cannot change.
+//
+// When spec.ETag is set, the spec is only applied to a key that is still in the state
+// the etag was read in; it fails with ErrETagMismatch otherwise, including when the key
+// has to be created. Applies of one alias run one at a time, but metadata updates made
+// through UpdateKeyMetadata between the check and the update are not detected.
+//
+// Every apply that changes something is audited as ApplyKey, with the fields changed.
+func (s *keyServiceImpl) ApplyKey(ctx context.Context, spec domain.KeySpec, requester *pk.RequesterContext) (*domain.ProvisionedKey, error) {
+	aliases, err := s.aliasRepo()
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.ValidateKeyAlias(spec.Alias); err != nil {
+		return nil, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
+	}
+	if spec.KeyType == pk.KeyType_KEY_TYPE_UNSPECIFIED {
+		return nil, fmt.Errorf("%w: key_type is required", app_errors.ErrInvalidInput)
+	}
+	if requester.GetClientIdentity() == "" {
+		return nil, app_errors.ErrInvalidInput
+	}
+
+	namespace := requestNamespace(ctx)
+	var provisioned *domain.ProvisionedKey
+	var changes []domain.AuditChange
+	err = aliases.WithKeyAliasLock(ctx, namespace, spec.Alias, func(ctx context.Context) error {
+		provisioned, changes, err = s.applyKey(ctx, aliases, namespace, spec, requester)
+		return err
+	})
+	if err != nil {
+		s.auditLogger.AuditLog(ctx, requester.GetClientIdentity(), "ApplyKey", "", "", false, err)
+		return nil, err
+	}
+	if len(changes) > 0 {
+		s.auditLogger.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), requester.GetClientIdentity(), "ApplyKey", provisioned.Metadata.GetKeyId(), "", true, nil)
+	}
+	return provisioned, nil
+}
+
+func (s *keyServiceImpl) applyKey(ctx context.Context, aliases domain.KeyAliasRepository, namespace string, spec domain.KeySpec, requester *pk.RequesterContext) (*domain.ProvisionedKey, []domain.AuditChange, error) {
+	key, err := s.aliasedKey(ctx, aliases, namespace, spec.Alias)
+	if err != nil && !errors.Is(err, app_errors.ErrKeyNotFound) {
+		return nil, nil, err
+	}
+	if key == nil || key.Status == domain.KeyStatusRevoked || key.Status == domain.KeyStatusPendingDeletion || key.Status == domain.KeyStatusDestroyed {
+		if spec.ETag != "" {
+			return nil, nil, fmt.Errorf("%w: alias %s names no live key", app_errors.ErrETagMismatch, spec.Alias)
+		}
+		return s.createAliasedKey(ctx, aliases, namespace, spec, requester)
+	}
+
+	if spec.ETag != "" && spec.ETag != domain.KeyETag(key.Metadata) {
+		return nil, nil, fmt.Errorf("%w: key %s changed since it was read", app_errors.ErrETagMismatch, key.ID)
+	}
+	if key.Metadata.GetKeyType() != spec.KeyType {
+		return nil, nil, fmt.Errorf("%w: alias %s names a %s key, whose type cannot change", app_errors.ErrInvalidInput, spec.Alias, key.Metadata.GetKeyType())
+	}
+
+	update := &pk.UpdateKeyMetadataRequest{KeyId: key.ID.String(), RequesterContext: requester}
+	if key.Metadata.GetDescription() != spec.Description {
+		update.Description = &spec.Description
+	}
+	if !maps.Equal(key.Metadata.GetTags(), spec.Tags) {
+		update.TagsToAdd = spec.Tags
+		for name := range key.Metadata.GetTags() {
+			if _, ok := spec.Tags[name]; !ok {
+				update.TagsToRemove = append(update.TagsToRemove, name)
+			}
+		}
+	}
+	if update.Description == nil && update.TagsToAdd == nil && update.TagsToRemove == nil {
+		return &domain.ProvisionedKey{Alias: spec.Alias, Metadata: key.Metadata, ETag: domain.KeyETag(key.Metadata)}, nil, nil
+	}
+
+	changes, err := s.updateKeyMetadata(ctx, key.ID, update)
+	if err != nil {
+		return nil, nil, err
+	}
+	updated, err := s.getKeyByRequest(ctx, key.ID, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &domain.ProvisionedKey{Alias: spec.Alias, Metadata: updated.Metadata, ETag: domain.KeyETag(updated.Metadata)}, changes, nil
+}
+
+// createAliasedKey creates the key of spec and binds its alias to it. A key whose alias
+// could not be bound is left behind unnamed.
+func (s *keyServiceImpl) createAliasedKey(ctx context.Context, aliases domain.KeyAliasRepository, namespace string, spec domain.KeySpec, requester *pk.RequesterContext) (*domain.ProvisionedKey, []domain.AuditChange, error) {
+	created, err := s.CreateKey(ctx, &pk.CreateKeyRequest{
+		KeyType:          spec.KeyType,
+		Description:      spec.Description,
+		Tags:             spec.Tags,
+		RequesterContext: requester,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	keyID, err := domain.KeyIDFromString(created.GetKeyId())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := aliases.PutKeyAlias(ctx, namespace, spec.Alias, keyID); err != nil {
+		return nil, nil, err
+	}
+
+	s.logger.InfoContext(ctx, "key created for alias", "keyId", keyID.String(), "alias", spec.Alias)
+	changes := []domain.AuditChange{{Field: "aliases." + spec.Alias, New: keyID.String()}}
+	return &domain.ProvisionedKey{Alias: spec.Alias, Metadata: created.Metadata, ETag: domain.KeyETag(created.Metadata), Created: true}, changes, nil
+}
+
+// GetKeyByAlias returns the key alias names in the caller's namespace, whatever its
+// status, with its etag.
+func (s *keyServiceImpl) GetKeyByAlias(ctx context.Context, alias string) (*domain.ProvisionedKey, error) {
+	aliases, err := s.aliasRepo()
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.ValidateKeyAlias(alias); err != nil {
+		return nil, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
+	}
+	key, err := s.aliasedKey(ctx, aliases, requestNamespace(ctx), alias)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ProvisionedKey{Alias: alias, Metadata: key.Metadata, ETag: domain.KeyETag(key.Metadata)}, nil
+}
+
+// aliasedKey returns the latest version of the key alias names in namespace.
+func (s *keyServiceImpl) aliasedKey(ctx context.Context, aliases domain.KeyAliasRepository, namespace, alias string) (*domain.Key, error) {
+	keyID, err := aliases.GetKeyAlias(ctx, namespace, alias)
+	if err != nil {
+		return nil, err
+	}
+	return s.getKeyByRequest(ctx, keyID, 0)
+}
+
+func (s *keyServiceImpl) aliasRepo() (domain.KeyAliasRepository, error) {
+	if s.aliases == nil {
+		return nil, app_errors.ErrKeyAliasesUnavailable
+	}
+	return s.aliases, nil
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/pipelines"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// rotationFilter selects the keys of a RotateKeysByFilter request. Empty fields match anything.
+type rotationFilter struct {
+	tags           map[string]string
+	creator        string
+	classification string
+	kmsProvider    string
+}
+
+func newRotationFilter(req *pk.ListKeysRequest) rotationFilter {
+	attrs := req.GetAttributes().GetCustomAttributes()
+	return rotationFilter{
+		tags:           req.GetTagFilters(),
+		creator:        attrs[cts.RotationFilterCreator],
+		classification: attrs[cts.RotationFilterClassification],
+		kmsProvider:    attrs[cts.RotationFilterKMSProvider],
+	}
+}
+
+func (f rotationFilter) empty() bool {
+	return len(f.tags) == 0 && f.creator == "" && f.classification == "" && f.kmsProvider == ""
+}
+
+func (s *keyServiceImpl) matchesRotationFilter(f rotationFilter, key *domain.Key) bool {
+	md := key.Metadata
+	for tag, value := range f.tags {
+		if md.GetTags()[tag] != value {
+			return false
+		}
+	}
+	if f.creator != "" && md.GetCreatorIdentity() != f.creator {
+		return false
+	}
+	if f.classification != "" && md.GetDataClassification() != f.classification {
+		return false
+	}
+	if f.kmsProvider != "" && s.keyKMSProviderName(key) != f.kmsProvider {
+		return false
+	}
+	return true
+}
+
+// RotateKeysByFilter rotates every active key that matches the request's tag filters
+// and the creator, classification and KMS provider custom attributes, walking the key
+// list server-side and feeding matches to the rotation pipeline a page at a time. At
+// least one filter is required so that an empty request cannot rotate the whole
+// keyspace. After each page with matches, send receives that page's results together
+// with the running success and failure counts; a final message without results carries
+// the totals once the walk is complete.
+func (s *keyServiceImpl) RotateKeysByFilter(ctx context.Context, req *pk.ListKeysRequest, send func(*pk.BatchRotateKeysResponse) error) error {
+	ctx, span := tracer.Start(ctx, "RotateKeysByFilter")
+	defer span.End()
+
+	if req == nil {
+		return app_errors.ErrInvalidInput
+	}
+	filter := newRotationFilter(req)
+	if filter.empty() {
+		return fmt.Errorf("%w: at least one filter is required", app_errors.ErrInvalidInput)
+	}
+
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultListPageSize
+	}
+
+	// The repository narrows the walk to active keys of the creator and classification;
+	// tags and the KMS provider are matched here.
+	listFilter := domain.KeyFilter{
+		Statuses:           []domain.KeyStatus{domain.KeyStatusActive},
+		CreatorIdentity:    filter.creator,
+		DataClassification: filter.classification,
+	}
+	// A scoped caller only rotates the keys it could rotate one at a time.
+	authorizedContext, scoped := domain.ListScopeFromContext(ctx)
+	listFilter.AuthorizedContext = authorizedContext
+
+	var succeeded, failed int32
+	err := func() error {
+		if scoped && authorizedContext == "" {
+			return nil
+		}
+		var page domain.KeyPage
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			keys, err := s.keyRepo.ListKeys(ctx, listFilter, page, pageSize)
+			if err != nil {
+				return err
+			}
+			if len(keys) == 0 {
+				return nil
+			}
+			// Take the cursor before rotating: a rotated key's new version is newer
+			// than the cursor and is not visited again.
+			cursor := page.Order.CursorAfter(keys[len(keys)-1])
+			page.After = &cursor
+
+			var matched []*domain.Key
+			for _, key := range keys {
+				if key.Status == domain.KeyStatusActive && s.matchesRotationFilter(filter, key) {
+					matched = append(matched, key)
+				}
+			}
+
+			if len(matched) > 0 {
+				results, err := s.rotateThroughPipeline(ctx, matched)
+				if err != nil {
+					return err
+				}
+				for _, result := range results {
+					if _, ok := result.Result.(*pk.BatchRotateKeysResult_Error); ok {
+						failed++
+					} else {
+						succeeded++
+					}
+				}
+				if err := send(&pk.BatchRotateKeysResponse{
+					Results:           results,
+					ResponseTimestamp: timestamppb.Now(),
+					SuccessfulCount:   succeeded,
+					FailedCount:       failed,
+				}); err != nil {
+					return err
+				}
+			}
+
+			if len(keys) < pageSize {
+				return nil
+			}
+		}
+	}()
+	if err == nil {
+		err = send(&pk.BatchRotateKeysResponse{
+			ResponseTimestamp: timestamppb.Now(),
+			SuccessfulCount:   succeeded,
+			FailedCount:       failed,
+		})
+	}
+
+	s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), "RotateKeysByFilter", "", "", err == nil, err)
+	s.logger.InfoContext(ctx, "rotate by filter finished", "rotated", succeeded, "failed", failed, "error", err)
+	return err
+}
+
+// RotateDueKeys rotates every active key whose rotation_period access policy, set when
+// it was created from a template with a rotation period, has elapsed since its latest
+// version was created. Keys without the policy, or with one that does not parse, are
+// left alone. Each rotation is audited on its own.
+func (s *keyServiceImpl) RotateDueKeys(ctx context.Context, pageSize int) (rotated, failed int, err error) {
+	ctx, span := tracer.Start(ctx, "RotateDueKeys")
+	defer span.End()
+
+	if pageSize <= 0 {
+		pageSize = defaultListPageSize
+	}
+	now := s.clock.Now()
+	filter := domain.KeyFilter{Statuses: []domain.KeyStatus{domain.KeyStatusActive}}
+
+	var page domain.KeyPage
+	for {
+		if err := ctx.Err(); err != nil {
+			return rotated, failed, err
+		}
+
+		keys, err := s.keyRepo.ListKeys(ctx, filter, page, pageSize)
+		if err != nil {
+			return rotated, failed, err
+		}
+		if len(keys) == 0 {
+			return rotated, failed, nil
+		}
+		cursor := page.Order.CursorAfter(keys[len(keys)-1])
+		page.After = &cursor
+
+		var due []*domain.Key
+		for _, key := range keys {
+			if key.Status == domain.KeyStatusActive && rotationDue(key, now) {
+				due = append(due, key)
+			}
+		}
+
+		if len(due) > 0 {
+			results, err := s.rotateThroughPipeline(ctx, due)
+			if err != nil {
+				return rotated, failed, err
+			}
+			for _, result := range results {
+				var rotateErr error
+				if e, ok := result.Result.(*pk.BatchRotateKeysResult_Error); ok {
+					rotateErr = errors.New(e.Error)
+					failed++
+				} else {
+					rotated++
+				}
+				s.auditLogger.AuditLog(ctx, "", "ScheduledRotateKey", result.GetKeyId(), "", rotateErr == nil, rotateErr)
+			}
+		}
+
+		if len(keys) < pageSize {
+			return rotated, failed, nil
+		}
+	}
+}
+
+// rotationDue reports whether the rotation period of key has elapsed at now.
+func rotationDue(key *domain.Key, now time.Time) bool {
+	raw := key.Metadata.GetAccessPolicies()[cts.PolicyRotationPeriod]
+	if raw == "" {
+		return false
+	}
+	period, err := time.ParseDuration(raw)
+	if err != nil || period <= 0 {
+		return false
+	}
+	return !key.CreatedAt.Add(period).After(now)
+}
+
+// rotateThroughPipeline submits the keys to the rotation pipeline and waits for all of
+// them. A failure to rotate one key is reported in its result rather than returned.
+func (s *keyServiceImpl) rotateThroughPipeline(ctx context.Context, keys []*domain.Key) ([]*pk.BatchRotateKeysResult, error) {
+	results := make([]*pk.BatchRotateKeysResult, 0, len(keys))
+
+	type submitted struct {
+		key           *domain.Key
+		future        *pipelines.KeyRotationFuture
+		rotatedAt     time.Time
+		graceDeadline time.Time
+	}
+	pending := make([]submitted, 0, len(keys))
+
+	for _, key := range keys {
+		kmsProvider, err := s.getKeyKMSProvider(key)
+		if err != nil {
+			results = append(results, rotateErrorResult(key.ID, err))
+			continue
+		}
+		dekPool, ok := s.dekPools[key.Metadata.GetKeyType()]
+		if !ok {
+			results = append(results, rotateErrorResult(key.ID, fmt.Errorf("%w: unsupported key type for pooling", ErrInvalidKeyType)))
+			continue
+		}
+
+		now := s.clock.Now()
+		graceDeadline := now.Add(s.gracePeriod(0))
+		future, err := s.keyRotationPipeline.Submit(ctx, pipelines.KeyRotationRequest{
+			KeyID:         key.ID,
+			KMSProvider:   kmsProvider,
+			DEKPool:       dekPool,
+			GraceDeadline: graceDeadline,
+		})
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, submitted{key: key, future: future, rotatedAt: now, graceDeadline: graceDeadline})
+	}
+
+	for _, sub := range pending {
+		result, err := sub.future.Wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if result.Error != nil {
+			results = append(results, rotateErrorResult(sub.key.ID, result.Error))
+			continue
+		}
+		results = append(results, &pk.BatchRotateKeysResult{
+			KeyId: sub.key.ID.String(),
+			Result: &pk.BatchRotateKeysResult_Success{Success: &pk.RotateKeyResponse{
+				KeyId:               sub.key.ID.String(),
+				NewVersion:          result.RotatedKey.Version,
+				PreviousVersion:     sub.key.Version,
+				Metadata:            result.RotatedKey.Metadata,
+				RotationTimestamp:   timestamppb.New(sub.rotatedAt),
+				OldVersionExpiresAt: timestamppb.New(sub.graceDeadline),
+			}},
+		})
+	}
+	return results, nil
+}
+
+func rotateErrorResult(keyID domain.KeyID, err error) *pk.BatchRotateKeysResult {
+	return &pk.BatchRotateKeysResult{
+		KeyId:  keyID.String(),
+		Result: &pk.BatchRotateKeysResult_Error{Error: err.Error()},
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"context"
 	"crypto/rand"
 	"fmt"
-	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
 	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/config"
 	"github.com/spounge-ai/polykey/pkg/authorization"
 	"github.com/spounge-ai/polykey/pkg/crypto"
 	"github.com/spounge-ai/polykey/pkg/patterns/batch"
@@ -17,9 +17,16 @@ import (
 
 const MaxKeyIDGenerationRetries = 10
 
+// createdKey is a key that was just created, with the checksum of its material, which
+// can only be computed until its plaintext DEK is zeroed.
+type createdKey struct {
+	key      *domain.Key
+	checksum string
+}
+
 // createKeyObject encapsulates the core logic for creating a new key domain object.
 // It handles DEK generation, encryption, and metadata population.
-func (s *keyServiceImpl) createKeyObject(ctx context.Context, item *pk.CreateKeyItem, clientIdentity string, storageProfile pk.StorageProfile) (*domain.Key, error) {
+func (s *keyServiceImpl) createKeyObject(ctx context.Context, item *pk.CreateKeyItem, clientIdentity string, storageProfile pk.StorageProfile) (*createdKey, error) {
 	description, err := domain.NewDescription(item.GetDescription())
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
@@ -36,21 +43,25 @@ func (s *keyServiceImpl) createKeyObject(ctx context.Context, item *pk.CreateKey
 	if _, err := rand.Read(dek); err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrKeyGenerationFail, err)
 	}
+	checksum := crypto.KeyChecksum(dek)
 
 	keyID := domain.NewKeyID()
-	now := time.Now()
+	now := s.clock.Now()
 
-	kmsProvider, err := s.getKMSProvider(storageProfile)
+	kmsProviderName := s.kmsProviderName(storageProfile)
+	kmsProvider, err := s.kmsProvider(kmsProviderName)
 	if err != nil {
 		return nil, err
 	}
 
 	finalKey := &domain.Key{
-		ID:        keyID,
-		Version:   1,
-		Status:    domain.KeyStatusActive,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:          keyID,
+		Namespace:   requestNamespace(ctx),
+		Version:     1,
+		Status:      domain.KeyStatusActive,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		KMSProvider: kmsProviderName,
 		Metadata: &pk.KeyMetadata{
 			KeyId:              keyID.String(),
 			KeyType:            item.GetKeyType(),
@@ -76,7 +87,34 @@ func (s *keyServiceImpl) createKeyObject(ctx context.Context, item *pk.CreateKey
 	}
 
 	finalKey.EncryptedDEK = encryptedDEK
-	return finalKey, nil
+	return &createdKey{key: finalKey, checksum: checksum}, nil
+}
+
+// requestNamespace is the namespace new keys are created in.
+func requestNamespace(ctx context.Context) string {
+	if namespace, ok := domain.NamespaceFromContext(ctx); ok {
+		return namespace
+	}
+	return domain.DefaultNamespace
+}
+
+// checkNamespaceQuota rejects adding keys when that would take the request's namespace
+// past its configured key quota. Concurrent creations can overshoot the quota by the
+// size of the requests that raced.
+func (s *keyServiceImpl) checkNamespaceQuota(ctx context.Context, adding int) error {
+	namespace := requestNamespace(ctx)
+	quota := s.cfg.Namespaces.KeyQuota(namespace)
+	if quota <= 0 || adding == 0 {
+		return nil
+	}
+	count, err := s.keyRepo.CountKeys(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to check key quota: %w", err)
+	}
+	if count+adding > quota {
+		return fmt.Errorf("%w: namespace %q holds %d of %d keys", app_errors.ErrNamespaceQuotaExceeded, namespace, count, quota)
+	}
+	return nil
 }
 
 func (s *keyServiceImpl) CreateKey(ctx context.Context, req *pk.CreateKeyRequest) (*pk.CreateKeyResponse, error) {
@@ -87,11 +125,6 @@ func (s *keyServiceImpl) CreateKey(ctx context.Context, req *pk.CreateKeyRequest
 	clientTier := authorization.FromProtoTier(req.GetRequesterContext().GetClientTier())
 	storageProfile := authorization.GetStorageProfileForTier(clientTier)
 
-	_, algorithm, err := crypto.GetCryptoDetails(req.GetKeyType())
-	if err != nil {
-		return nil, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
-	}
-
 	// Adapt the single request to the item format expected by the helper.
 	item := &pk.CreateKeyItem{
 		KeyType:                   req.GetKeyType(),
@@ -104,40 +137,188 @@ func (s *keyServiceImpl) CreateKey(ctx context.Context, req *pk.CreateKeyRequest
 		GenerationParams:          req.GetGenerationParams(),
 	}
 
-	finalKey, err := s.createKeyObject(ctx, item, req.RequesterContext.GetClientIdentity(), storageProfile)
+	item, err := s.applyTemplate(ctx, item, map[string]*domain.KeyTemplate{})
 	if err != nil {
 		return nil, err
 	}
 
-	if err := s.keyRepo.CreateKey(ctx, finalKey); err != nil {
+	_, algorithm, err := crypto.GetCryptoDetails(item.GetKeyType())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
+	}
+
+	if err := s.checkNamespaceQuota(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	created, err := s.createKeyObject(ctx, item, req.RequesterContext.GetClientIdentity(), storageProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.keyRepo.CreateKey(ctx, created.key); err != nil {
 		return nil, fmt.Errorf("failed to create key: %w", err)
 	}
 
-	s.logger.InfoContext(ctx, "key created", "keyId", finalKey.ID, "keyType", req.GetKeyType().String())
+	s.logger.InfoContext(ctx, "key created", "keyId", created.key.ID, "keyType", item.GetKeyType().String())
+
+	return createdKeyResponse(created, algorithm), nil
+}
 
+// createdKeyResponse describes a key that was just created, with its material unless
+// the key is not exportable.
+func createdKeyResponse(created *createdKey, algorithm string) *pk.CreateKeyResponse {
 	return &pk.CreateKeyResponse{
-		KeyId:    finalKey.ID.String(),
-		Metadata: finalKey.Metadata,
-		KeyMaterial: &pk.KeyMaterial{
-			EncryptedKeyData:    append([]byte(nil), finalKey.EncryptedDEK...),
-			EncryptionAlgorithm: algorithm,
-			KeyChecksum:         "sha256", // Note: This checksum is of the *encrypted* key, which is less useful.
-		},
+		KeyId:             created.key.ID.String(),
+		Metadata:          created.key.Metadata,
+		KeyMaterial:       keyMaterial(created.key.Metadata, created.key.EncryptedDEK, algorithm, created.checksum),
 		ResponseTimestamp: timestamppb.Now(),
-	}, nil
+	}
 }
 
 func (s *keyServiceImpl) BatchCreateKeys(ctx context.Context, req *pk.BatchCreateKeysRequest) (*pk.BatchCreateKeysResponse, error) {
+	items, processor, err := s.batchCreateProcessor(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := processor.ProcessBatch(ctx, items, req.GetContinueOnError())
+	if err != nil {
+		return nil, err
+	}
+
+	// Without continue_on_error, one invalid item fails the whole batch before any key
+	// is stored.
+	if !req.GetContinueOnError() {
+		for i, item := range results.Items {
+			if item.Error != nil {
+				return nil, fmt.Errorf("key %d of the batch: %w", i, item.Error)
+			}
+		}
+	}
+
+	createdKeys := make([]*domain.Key, 0, len(results.Items))
+	batchResults := make([]*pk.BatchCreateKeysResult, len(results.Items))
+	var successCount, failedCount int32
+	for i, item := range results.Items {
+		result, err := batchCreateKeysResult(i, item)
+		if err != nil {
+			return nil, err
+		}
+		batchResults[i] = result
+		if item.Error != nil {
+			failedCount++
+			continue
+		}
+		successCount++
+		createdKeys = append(createdKeys, item.Result.key)
+	}
+
+	if err := s.checkNamespaceQuota(ctx, len(createdKeys)); err != nil {
+		return nil, err
+	}
+
+	if err := s.keyRepo.CreateBatchKeys(ctx, createdKeys); err != nil {
+		return nil, fmt.Errorf("failed to create keys in batch: %w", err)
+	}
+	s.logger.InfoContext(ctx, "keys created in batch", "created", successCount, "failed", failedCount)
+
+	return &pk.BatchCreateKeysResponse{
+		Results:           batchResults,
+		ResponseTimestamp: timestamppb.Now(),
+		SuccessfulCount:   successCount,
+		FailedCount:       failedCount,
+	}, nil
+}
+
+// StreamBatchCreateKeys creates the keys of req like BatchCreateKeys, but stores each
+// key and sends its result as soon as it is created, in the order the creations
+// complete, instead of storing the batch in one write at the end. The namespace quota
+// is checked up front for every item. Without continue_on_error, the items are all
+// validated before any key is created, and a key that then fails to be created ends
+// the stream with its error; the keys sent before it stay created.
+func (s *keyServiceImpl) StreamBatchCreateKeys(ctx context.Context, req *pk.BatchCreateKeysRequest, send func(*pk.BatchCreateKeysResult) error) error {
+	ctx, span := tracer.Start(ctx, "StreamBatchCreateKeys")
+	defer span.End()
+
+	items, processor, err := s.batchCreateProcessor(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !req.GetContinueOnError() {
+		for i, item := range items {
+			if err := processor.Validate(item); err != nil {
+				return fmt.Errorf("key %d of the batch: %w", i, err)
+			}
+		}
+	}
+	if err := s.checkNamespaceQuota(ctx, len(items)); err != nil {
+		return err
+	}
+
+	create := processor.Process
+	processor.Process = func(ctx context.Context, item *pk.CreateKeyItem) (*createdKey, error) {
+		created, err := create(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.keyRepo.CreateKey(ctx, created.key); err != nil {
+			return nil, fmt.Errorf("failed to create key: %w", err)
+		}
+		return created, nil
+	}
+
+	var successCount, failedCount int
+	err = processor.StreamBatch(ctx, items, func(i int, item batch.BatchItem[*createdKey]) error {
+		if item.Error != nil && !req.GetContinueOnError() {
+			return fmt.Errorf("key %d of the batch: %w", i, item.Error)
+		}
+		result, err := batchCreateKeysResult(i, item)
+		if err != nil {
+			return err
+		}
+		if item.Error != nil {
+			failedCount++
+		} else {
+			successCount++
+		}
+		return send(result)
+	})
+	s.logger.InfoContext(ctx, "keys created in batch stream", "created", successCount, "failed", failedCount)
+	return err
+}
+
+// batchCreateProcessor resolves the templates of the keys of req and returns the
+// resulting items with the processor that validates and creates each of them, without
+// storing it.
+func (s *keyServiceImpl) batchCreateProcessor(ctx context.Context, req *pk.BatchCreateKeysRequest) ([]*pk.CreateKeyItem, *batch.BatchProcessor[*pk.CreateKeyItem, *createdKey], error) {
 	if req == nil || req.RequesterContext == nil || req.RequesterContext.GetClientIdentity() == "" {
-		return nil, app_errors.ErrInvalidInput
+		return nil, nil, app_errors.ErrInvalidInput
 	}
 
 	clientTier := authorization.FromProtoTier(req.GetRequesterContext().GetClientTier())
 	storageProfile := authorization.GetStorageProfileForTier(clientTier)
 
-	processor := batch.BatchProcessor[*pk.CreateKeyItem, *domain.Key]{
-		MaxConcurrency: 10, // Make this configurable
+	// Templates are resolved up front, since Validate has no context to load them with.
+	items := make([]*pk.CreateKeyItem, len(req.GetKeys()))
+	templateErrs := make(map[*pk.CreateKeyItem]error)
+	templateCache := make(map[string]*domain.KeyTemplate)
+	for i, item := range req.GetKeys() {
+		templated, err := s.applyTemplate(ctx, item, templateCache)
+		if err != nil {
+			templated = item
+			templateErrs[item] = err
+		}
+		items[i] = templated
+	}
+
+	return items, &batch.BatchProcessor[*pk.CreateKeyItem, *createdKey]{
+		MaxConcurrency: s.cfg.Batch.MaxConcurrency(config.BatchOperationCreate),
+		ObserveWait:    observeBatchWait(ctx, config.BatchOperationCreate),
 		Validate: func(item *pk.CreateKeyItem) error {
+			if err := templateErrs[item]; err != nil {
+				return err
+			}
 			if _, _, err := crypto.GetCryptoDetails(item.GetKeyType()); err != nil {
 				return fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
 			}
@@ -146,41 +327,26 @@ func (s *keyServiceImpl) BatchCreateKeys(ctx context.Context, req *pk.BatchCreat
 			}
 			return nil
 		},
-		Process: func(ctx context.Context, item *pk.CreateKeyItem) (*domain.Key, error) {
+		Process: func(ctx context.Context, item *pk.CreateKeyItem) (*createdKey, error) {
 			return s.createKeyObject(ctx, item, req.RequesterContext.GetClientIdentity(), storageProfile)
 		},
-	}
+	}, nil
+}
 
-	results, err := processor.ProcessBatch(ctx, req.Keys, req.GetContinueOnError())
+// batchCreateKeysResult is the result of the i-th key of a batch creation.
+func batchCreateKeysResult(i int, item batch.BatchItem[*createdKey]) (*pk.BatchCreateKeysResult, error) {
+	if item.Error != nil {
+		return &pk.BatchCreateKeysResult{
+			RequestIndex: int32(i),
+			Result:       &pk.BatchCreateKeysResult_Error{Error: item.Error.Error()},
+		}, nil
+	}
+	_, algorithm, err := crypto.GetCryptoDetails(item.Result.key.Metadata.GetKeyType())
 	if err != nil {
 		return nil, err
 	}
-
-	createdKeys := make([]*domain.Key, 0, len(results.Items))
-	batchResults := make([]*pk.BatchCreateKeysResult, len(results.Items))
-	for i, item := range results.Items {
-		if item.Error != nil {
-			batchResults[i] = &pk.BatchCreateKeysResult{
-				Result: &pk.BatchCreateKeysResult_Error{Error: item.Error.Error()},
-			}
-		} else {
-			createdKeys = append(createdKeys, item.Result)
-			batchResults[i] = &pk.BatchCreateKeysResult{
-				Result: &pk.BatchCreateKeysResult_Success{
-					Success: &pk.CreateKeyResponse{
-						KeyId:    item.Result.ID.String(),
-						Metadata: item.Result.Metadata,
-					},
-				},
-			}
-		}
-	}
-
-	if err := s.keyRepo.CreateBatchKeys(ctx, createdKeys); err != nil {
-		return nil, fmt.Errorf("failed to create keys in batch: %w", err)
-	}
-
-	return &pk.BatchCreateKeysResponse{
-		Results: batchResults,
+	return &pk.BatchCreateKeysResult{
+		RequestIndex: int32(i),
+		Result:       &pk.BatchCreateKeysResult_Success{Success: createdKeyResponse(item.Result, algorithm)},
 	}, nil
 }
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+
+	cts "github.com/spounge-ai/polykey/internal/constants"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+// exportPolicies are the access policies restricting what clients receive of a key's
+// material, which may be set to "false" but never back to "true".
+var exportPolicies = []string{cts.PolicyExportable, cts.PolicyPlaintextMaterialAllowed}
+
+// keyExportable reports whether the wrapped material of a key may be returned to clients.
+func keyExportable(metadata *pk.KeyMetadata) bool {
+	return metadata.GetAccessPolicies()[cts.PolicyExportable] != "false"
+}
+
+// keyMaterial describes the material of a key version, leaving out the wrapped DEK when
+// the key is not exportable.
+func keyMaterial(metadata *pk.KeyMetadata, wrappedDEK []byte, algorithm, checksum string) *pk.KeyMaterial {
+	material := &pk.KeyMaterial{
+		EncryptionAlgorithm: algorithm,
+		KeyChecksum:         checksum,
+	}
+	if keyExportable(metadata) {
+		material.EncryptedKeyData = append([]byte(nil), wrappedDEK...)
+	}
+	return material
+}
+
+// checkExportPolicyUpdate rejects updates setting an export policy of current back to
+// "true" once it is "false".
+func checkExportPolicyUpdate(current *pk.KeyMetadata, updates map[string]string) error {
+	for _, name := range exportPolicies {
+		value, ok := updates[name]
+		if ok && value != "false" && current.GetAccessPolicies()[name] == "false" {
+			return fmt.Errorf("%w: the %s policy of a key cannot be relaxed", app_errors.ErrInvalidInput, name)
+		}
+	}
+	return nil
+}
@@ -2,33 +2,82 @@ package service
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"strings"
 
+	"github.com/spounge-ai/polykey/internal/domain"
 	app_errors "github.com/spounge-ai/polykey/internal/errors"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+const defaultListPageSize = 100
+
+// keyListPageTokens is the list the page tokens of ListKeys and StreamListKeys belong to.
+const keyListPageTokens = "keys"
+
+// listOrderAttribute is the custom access attribute a listing request names its order
+// in, such as "alias" or "updated_at desc"; see domain.ParseKeyOrder.
+const listOrderAttribute = "order_by"
+
+// keyListPageFilters are what the page tokens of a key listing are bound to: the
+// namespace listed, the filter applied and the order.
+type keyListPageFilters struct {
+	Namespace string           `json:"namespace"`
+	Filter    domain.KeyFilter `json:"filter"`
+	Order     domain.KeyOrder  `json:"order"`
+}
+
+// keyListFilter returns the filter of the key types and statuses of req, limited to the
+// list scope of ctx, and false when nothing can match: when req only asks for statuses
+// no key reports, or the scope names no authorized context.
+func keyListFilter(ctx context.Context, req *pk.ListKeysRequest) (domain.KeyFilter, bool) {
+	filter := domain.KeyFilter{KeyTypes: req.GetKeyTypes()}
+	for _, status := range req.GetStatuses() {
+		filter.Statuses = append(filter.Statuses, domain.KeyStatusesFromProto(status)...)
+	}
+	authorizedContext, scoped := domain.ListScopeFromContext(ctx)
+	if scoped && authorizedContext == "" {
+		return filter, false
+	}
+	filter.AuthorizedContext = authorizedContext
+	return filter, len(req.GetStatuses()) == 0 || len(filter.Statuses) > 0
+}
+
+// keyListOrder returns the order req asks for, newest first by default.
+func keyListOrder(req *pk.ListKeysRequest) (domain.KeyOrder, error) {
+	order, err := domain.ParseKeyOrder(req.GetAttributes().GetCustomAttributes()[listOrderAttribute])
+	if err != nil {
+		return domain.KeyOrder{}, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
+	}
+	return order, nil
+}
+
 func (s *keyServiceImpl) ListKeys(ctx context.Context, req *pk.ListKeysRequest) (*pk.ListKeysResponse, error) {
 	if req == nil {
 		return nil, app_errors.ErrInvalidInput
 	}
 
-	var cursor *time.Time
-	if req.PageToken != "" {
-		t, err := time.Parse(time.RFC3339Nano, req.PageToken)
-		if err != nil {
-			return nil, app_errors.ErrInvalidInput
-		}
-		cursor = &t
-	}
-
 	limit := int(req.GetPageSize())
 	if limit == 0 {
-		limit = 100 // default page size
+		limit = defaultListPageSize
 	}
 
-	keys, err := s.keyRepo.ListKeys(ctx, cursor, limit)
+	order, err := keyListOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	filter, ok := keyListFilter(ctx, req)
+	if !ok {
+		return &pk.ListKeysResponse{ResponseTimestamp: timestamppb.Now()}, nil
+	}
+	pageFilters := keyListPageFiltersOf(ctx, filter, order)
+	cursor, err := s.parseListCursor(pageFilters, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.keyRepo.ListKeys(ctx, filter, domain.KeyPage{Order: order, After: cursor}, limit)
 	if err != nil {
 		return nil, err // The error from the repository is a standard Go error.
 	}
@@ -40,7 +89,7 @@ func (s *keyServiceImpl) ListKeys(ctx context.Context, req *pk.ListKeysRequest)
 
 	var nextPageToken string
 	if len(keys) == limit {
-		nextPageToken = keys[len(keys)-1].CreatedAt.Format(time.RFC3339Nano)
+		nextPageToken = s.listPageToken(pageFilters, order.CursorAfter(keys[len(keys)-1]))
 	}
 
 	resp := &pk.ListKeysResponse{
@@ -52,3 +101,160 @@ func (s *keyServiceImpl) ListKeys(ctx context.Context, req *pk.ListKeysRequest)
 	s.logger.InfoContext(ctx, "keys listed", "count", len(metadataKeys))
 	return resp, nil
 }
+
+// StreamListKeys walks the repository cursor and hands each page to send as it is read,
+// so the caller never has to hold more than one chunk in memory. The page size of the
+// request is used as the chunk size and the page token as the starting cursor.
+func (s *keyServiceImpl) StreamListKeys(ctx context.Context, req *pk.ListKeysRequest, send func(*pk.ListKeysResponse) error) error {
+	if req == nil {
+		return app_errors.ErrInvalidInput
+	}
+
+	chunkSize := int(req.GetPageSize())
+	if chunkSize == 0 {
+		chunkSize = defaultListPageSize
+	}
+
+	order, err := keyListOrder(req)
+	if err != nil {
+		return err
+	}
+	filter, ok := keyListFilter(ctx, req)
+	if !ok {
+		return nil
+	}
+	pageFilters := keyListPageFiltersOf(ctx, filter, order)
+	cursor, err := s.parseListCursor(pageFilters, req.PageToken)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		keys, err := s.keyRepo.ListKeys(ctx, filter, domain.KeyPage{Order: order, After: cursor}, chunkSize)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			break
+		}
+
+		chunk := make([]*pk.KeyMetadata, len(keys))
+		for i, key := range keys {
+			chunk[i] = key.Metadata
+		}
+
+		last := order.CursorAfter(keys[len(keys)-1])
+		cursor = &last
+
+		var nextPageToken string
+		if len(keys) == chunkSize {
+			nextPageToken = s.listPageToken(pageFilters, last)
+		}
+
+		if err := send(&pk.ListKeysResponse{
+			Keys:              chunk,
+			NextPageToken:     nextPageToken,
+			ResponseTimestamp: timestamppb.Now(),
+		}); err != nil {
+			return err
+		}
+
+		total += len(keys)
+		if len(keys) < chunkSize {
+			break
+		}
+	}
+
+	s.logger.InfoContext(ctx, "keys streamed", "count", total)
+	return nil
+}
+
+// ListKeyVersions returns the metadata of every version of a key, newest first, and
+// never any key material. Each entry reports the version's own status; CreatedAt is
+// when the version was introduced by creation or rotation, UpdatedAt its last status
+// change and, for a rotated version, ExpiresAt the end of its grace period.
+func (s *keyServiceImpl) ListKeyVersions(ctx context.Context, req *pk.GetKeyMetadataRequest) (*pk.ListKeysResponse, error) {
+	if req == nil {
+		return nil, app_errors.ErrInvalidInput
+	}
+	keyID, err := domain.KeyIDFromString(req.GetKeyId())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
+	}
+
+	versions, err := s.keyRepo.GetKeyVersions(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, app_errors.ErrKeyNotFound
+	}
+
+	metadata := make([]*pk.KeyMetadata, len(versions))
+	for i, key := range versions {
+		metadata[i] = versionMetadata(key)
+	}
+
+	s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), "ListKeyVersions", keyID.String(), "", true, nil)
+	s.logger.InfoContext(ctx, "key versions listed", "keyId", keyID.String(), "count", len(metadata))
+	return &pk.ListKeysResponse{
+		Keys:              metadata,
+		TotalCount:        int32(len(metadata)),
+		ResponseTimestamp: timestamppb.Now(),
+	}, nil
+}
+
+// versionMetadata copies the stored metadata of one version and overlays the fields
+// the keys table tracks per version.
+func versionMetadata(key *domain.Key) *pk.KeyMetadata {
+	md := &pk.KeyMetadata{}
+	if key.Metadata != nil {
+		md = proto.Clone(key.Metadata).(*pk.KeyMetadata)
+	}
+	md.KeyId = key.ID.String()
+	md.Version = key.Version
+	md.Status = key.Status.Proto()
+	md.CreatedAt = timestamppb.New(key.CreatedAt)
+	md.UpdatedAt = timestamppb.New(key.UpdatedAt)
+	if key.GraceExpiresAt != nil && (md.ExpiresAt == nil || key.GraceExpiresAt.Before(md.ExpiresAt.AsTime())) {
+		md.ExpiresAt = timestamppb.New(*key.GraceExpiresAt)
+	}
+	return md
+}
+
+func keyListPageFiltersOf(ctx context.Context, filter domain.KeyFilter, order domain.KeyOrder) keyListPageFilters {
+	namespace, _ := domain.NamespaceFromContext(ctx)
+	return keyListPageFilters{Namespace: namespace, Filter: filter, Order: order}
+}
+
+// listPageToken returns the page token of the keys listed after cursor.
+func (s *keyServiceImpl) listPageToken(filters keyListPageFilters, cursor domain.KeyCursor) string {
+	return s.pageTokens.encode(keyListPageTokens, filters, cursor.Value+"|"+cursor.ID.String())
+}
+
+// parseListCursor returns the cursor a page token continues after, or nil for the first
+// page.
+func (s *keyServiceImpl) parseListCursor(filters keyListPageFilters, pageToken string) (*domain.KeyCursor, error) {
+	if pageToken == "" {
+		return nil, nil
+	}
+	position, err := s.pageTokens.decode(keyListPageTokens, filters, pageToken)
+	if err != nil {
+		return nil, err
+	}
+	// Neither times nor aliases contain "|".
+	value, id, ok := strings.Cut(position, "|")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed page token", app_errors.ErrInvalidInput)
+	}
+	keyID, err := domain.KeyIDFromString(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed page token", app_errors.ErrInvalidInput)
+	}
+	return &domain.KeyCursor{Value: value, ID: keyID}, nil
+}
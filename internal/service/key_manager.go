@@ -5,34 +5,44 @@ import (
 	"crypto/rand"
 	"fmt"
 	"maps"
+	"slices"
 	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/config"
 	"github.com/spounge-ai/polykey/internal/pipelines"
+	"github.com/spounge-ai/polykey/pkg/authorization"
+	"github.com/spounge-ai/polykey/pkg/crypto"
 	"github.com/spounge-ai/polykey/pkg/patterns/batch"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// processRotation contains the core logic for rotating a single key.
+// processRotation contains the core logic for rotating a single key, returning the
+// version it replaced, the new one and the checksum of the new material.
 // It is designed to be called by both single and batch rotation methods.
-func (s *keyServiceImpl) processRotation(ctx context.Context, keyID domain.KeyID) (*domain.Key, *domain.Key, error) {
+func (s *keyServiceImpl) processRotation(ctx context.Context, keyID domain.KeyID, graceDeadline time.Time) (*domain.Key, *domain.Key, string, error) {
 	currentKey, err := s.keyRepo.GetKey(ctx, keyID)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to get current key for rotation", "keyId", keyID, "error", err)
-		return nil, nil, fmt.Errorf("failed to get current key: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to get current key: %w", err)
 	}
 
-	kmsProvider, err := s.getKMSProvider(currentKey.Metadata.GetStorageType())
+	if err := domain.ValidateKeyTransition(currentKey.Status, domain.KeyStatusRotated); err != nil {
+		return nil, nil, "", err
+	}
+
+	kmsProvider, err := s.getKeyKMSProvider(currentKey)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
 	dekPool, ok := s.dekPools[currentKey.Metadata.GetKeyType()]
 	if !ok {
-		return nil, nil, fmt.Errorf("%w: unsupported key type for pooling", ErrInvalidKeyType)
+		return nil, nil, "", fmt.Errorf("%w: unsupported key type for pooling", ErrInvalidKeyType)
 	}
 
 	newDEK := dekPool.Get()
@@ -40,25 +50,42 @@ func (s *keyServiceImpl) processRotation(ctx context.Context, keyID domain.KeyID
 
 	if _, err := rand.Read(newDEK); err != nil {
 		s.logger.ErrorContext(ctx, "failed to generate new DEK", "error", err)
-		return nil, nil, fmt.Errorf("failed to generate new DEK: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to generate new DEK: %w", err)
 	}
+	checksum := crypto.KeyChecksum(newDEK)
 
 	encryptedNewDEK, err := kmsProvider.EncryptDEK(ctx, newDEK, currentKey)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to encrypt new DEK", "error", err)
-		return nil, nil, fmt.Errorf("failed to encrypt new DEK: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to encrypt new DEK: %w", err)
 	}
 
-	rotatedKey, err := s.keyRepo.RotateKey(ctx, keyID, encryptedNewDEK)
+	rotatedKey, err := s.keyRepo.RotateKey(ctx, keyID, encryptedNewDEK, graceDeadline)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to rotate key in repository", "keyId", keyID, "error", err)
-		return nil, nil, fmt.Errorf("failed to rotate key: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to rotate key: %w", err)
+	}
+	if rotatedKey == nil {
+		return nil, nil, "", fmt.Errorf("failed to rotate key: repository returned no key for %s", keyID)
 	}
 
 	s.logger.InfoContext(ctx, "key rotated successfully", "keyId", keyID, "newVersion", rotatedKey.Version)
-	return currentKey, rotatedKey, nil
+	return currentKey, rotatedKey, checksum, nil
+}
+
+// gracePeriod returns the requested grace period for the version being replaced,
+// falling back to the configured default when the request leaves it unset.
+func (s *keyServiceImpl) gracePeriod(seconds int32) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return s.cfg.KeyLifecycle.Rotation.DefaultGracePeriod
 }
 
+// rotationQueueRetryAfter is how long clients are asked to wait before retrying a
+// rotation refused because the rotation queue was full.
+const rotationQueueRetryAfter = time.Second
+
 func (s *keyServiceImpl) RotateKey(ctx context.Context, req *pk.RotateKeyRequest) (*pk.RotateKeyResponse, error) {
 	if req == nil {
 		return nil, fmt.Errorf("%w: request is nil", ErrInvalidRequest)
@@ -76,7 +103,11 @@ func (s *keyServiceImpl) RotateKey(ctx context.Context, req *pk.RotateKeyRequest
 		return nil, fmt.Errorf("failed to get current key for rotation: %w", err)
 	}
 
-	kmsProvider, err := s.getKMSProvider(currentKey.Metadata.GetStorageType())
+	if err := domain.ValidateKeyTransition(currentKey.Status, domain.KeyStatusRotated); err != nil {
+		return nil, err
+	}
+
+	kmsProvider, err := s.getKeyKMSProvider(currentKey)
 	if err != nil {
 		return nil, err
 	}
@@ -86,46 +117,44 @@ func (s *keyServiceImpl) RotateKey(ctx context.Context, req *pk.RotateKeyRequest
 		return nil, fmt.Errorf("%w: unsupported key type for pooling", ErrInvalidKeyType)
 	}
 
+	now := s.clock.Now()
+	oldVersionExpiresAt := now.Add(s.gracePeriod(req.GetGracePeriodSeconds()))
+
 	rotationReq := pipelines.KeyRotationRequest{
-		KeyID:       keyID,
-		KMSProvider: kmsProvider,
-		DEKPool:     dekPool,
+		KeyID:              keyID,
+		KMSProvider:        kmsProvider,
+		DEKPool:            dekPool,
+		GracePeriodSeconds: req.GetGracePeriodSeconds(),
+		GraceDeadline:      oldVersionExpiresAt,
 	}
 
-	if !s.keyRotationPipeline.Enqueue(rotationReq) {
-		return nil, status.Errorf(codes.ResourceExhausted, "key rotation queue is full, please try again later")
+	future, ok := s.keyRotationPipeline.Enqueue(ctx, rotationReq)
+	if !ok {
+		return nil, app_errors.WithRetryAfter(app_errors.ErrRotationQueueFull, rotationQueueRetryAfter)
 	}
 
-	// Wait for the result from the pipeline
-	select {
-	case result := <-s.keyRotationPipeline.Results():
-		if result.Error != nil {
-			return nil, result.Error
-		}
-
-		rotatedKey := result.RotatedKey
-		gracePeriod := time.Duration(req.GetGracePeriodSeconds()) * time.Second
-		now := time.Now()
-
-		resp := &pk.RotateKeyResponse{
-			KeyId:           req.GetKeyId(),
-			NewVersion:      rotatedKey.Version,
-			PreviousVersion: currentKey.Version,
-			NewKeyMaterial: &pk.KeyMaterial{
-				EncryptedKeyData:    append([]byte(nil), rotatedKey.EncryptedDEK...),
-				EncryptionAlgorithm: "AES-256-GCM", // This should be dynamic based on key type
-				KeyChecksum:         "sha256",
-			},
-			Metadata:            rotatedKey.Metadata,
-			RotationTimestamp:   timestamppb.New(now),
-			OldVersionExpiresAt: timestamppb.New(now.Add(gracePeriod)),
-		}
+	// Wait for this request's own result from the pipeline.
+	result, err := future.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
 
-		return resp, nil
+	rotatedKey := result.RotatedKey
 
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	resp := &pk.RotateKeyResponse{
+		KeyId:               req.GetKeyId(),
+		NewVersion:          rotatedKey.Version,
+		PreviousVersion:     currentKey.Version,
+		NewKeyMaterial:      keyMaterial(rotatedKey.Metadata, rotatedKey.EncryptedDEK, "AES-256-GCM", result.KeyChecksum), // The algorithm should be dynamic based on key type
+		Metadata:            rotatedKey.Metadata,
+		RotationTimestamp:   timestamppb.New(now),
+		OldVersionExpiresAt: timestamppb.New(oldVersionExpiresAt),
 	}
+
+	return resp, nil
 }
 
 func (s *keyServiceImpl) BatchRotateKeys(ctx context.Context, req *pk.BatchRotateKeysRequest) (*pk.BatchRotateKeysResponse, error) {
@@ -137,33 +166,30 @@ func (s *keyServiceImpl) BatchRotateKeys(ctx context.Context, req *pk.BatchRotat
 	}
 
 	processor := batch.BatchProcessor[*pk.RotateKeyItem, *pk.RotateKeyResponse]{
-		MaxConcurrency: 10, // Make this configurable
+		MaxConcurrency: s.cfg.Batch.MaxConcurrency(config.BatchOperationRotate),
+		ObserveWait:    observeBatchWait(ctx, config.BatchOperationRotate),
 		Validate: func(item *pk.RotateKeyItem) error {
 			_, err := domain.KeyIDFromString(item.GetKeyId())
 			return err
 		},
 		Process: func(ctx context.Context, item *pk.RotateKeyItem) (*pk.RotateKeyResponse, error) {
 			keyID, _ := domain.KeyIDFromString(item.GetKeyId())
-			currentKey, rotatedKey, err := s.processRotation(ctx, keyID)
+			now := s.clock.Now()
+			oldVersionExpiresAt := now.Add(s.gracePeriod(item.GetGracePeriodSeconds()))
+
+			currentKey, rotatedKey, checksum, err := s.processRotation(ctx, keyID, oldVersionExpiresAt)
 			if err != nil {
 				return nil, err
 			}
 
-			gracePeriod := time.Duration(item.GetGracePeriodSeconds()) * time.Second
-			now := time.Now()
-
 			return &pk.RotateKeyResponse{
-				KeyId:           item.GetKeyId(),
-				NewVersion:      rotatedKey.Version,
-				PreviousVersion: currentKey.Version,
-				NewKeyMaterial: &pk.KeyMaterial{
-					EncryptedKeyData:    append([]byte(nil), rotatedKey.EncryptedDEK...),
-					EncryptionAlgorithm: "AES-256-GCM", // This should be dynamic
-					KeyChecksum:         "sha256",
-				},
+				KeyId:               item.GetKeyId(),
+				NewVersion:          rotatedKey.Version,
+				PreviousVersion:     currentKey.Version,
+				NewKeyMaterial:      keyMaterial(rotatedKey.Metadata, rotatedKey.EncryptedDEK, "AES-256-GCM", checksum), // The algorithm should be dynamic
 				Metadata:            rotatedKey.Metadata,
 				RotationTimestamp:   timestamppb.New(now),
-				OldVersionExpiresAt: timestamppb.New(now.Add(gracePeriod)),
+				OldVersionExpiresAt: timestamppb.New(oldVersionExpiresAt),
 			}, nil
 		},
 	}
@@ -217,6 +243,42 @@ func (s *keyServiceImpl) RevokeKey(ctx context.Context, req *pk.RevokeKeyRequest
 	return nil
 }
 
+// RestoreKey undoes a revocation of the key's latest version, provided the key was
+// revoked no longer ago than the restore window configured for the requester's tier.
+// Every attempt is audited as RestoreKey, successful or not.
+func (s *keyServiceImpl) RestoreKey(ctx context.Context, req *pk.RevokeKeyRequest) (*pk.GetKeyMetadataResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("%w: request is nil", ErrInvalidRequest)
+	}
+	keyID, err := domain.KeyIDFromString(req.GetKeyId())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
+	}
+	clientIdentity := req.GetRequesterContext().GetClientIdentity()
+
+	key, err := s.restoreKey(ctx, keyID, authorization.FromProtoTier(req.GetRequesterContext().GetClientTier()))
+	if err != nil {
+		s.auditLogger.AuditLog(ctx, clientIdentity, "RestoreKey", keyID.String(), "", false, err)
+		s.logger.WarnContext(ctx, "failed to restore key", "keyId", keyID.String(), "error", err)
+		return nil, err
+	}
+
+	s.auditLogger.AuditLog(ctx, clientIdentity, "RestoreKey", keyID.String(), "", true, nil)
+	s.logger.InfoContext(ctx, "key restored", "keyId", keyID.String(), "version", key.Version, "reason", req.GetRevocationReason())
+	return &pk.GetKeyMetadataResponse{
+		Metadata:          key.Metadata,
+		ResponseTimestamp: timestamppb.Now(),
+	}, nil
+}
+
+func (s *keyServiceImpl) restoreKey(ctx context.Context, keyID domain.KeyID, tier domain.KeyTier) (*domain.Key, error) {
+	window := s.cfg.KeyLifecycle.Restore.Windows[string(tier)]
+	if window <= 0 {
+		return nil, fmt.Errorf("%w: restore is not available for tier %s", app_errors.ErrRestoreWindowClosed, tier)
+	}
+	return s.keyRepo.RestoreKey(ctx, keyID, s.clock.Now().Add(-window))
+}
+
 func (s *keyServiceImpl) BatchRevokeKeys(ctx context.Context, req *pk.BatchRevokeKeysRequest) (*pk.BatchRevokeKeysResponse, error) {
 	ctx, span := tracer.Start(ctx, "BatchRevokeKeys")
 	defer span.End()
@@ -291,6 +353,9 @@ func (s *keyServiceImpl) BatchRevokeKeys(ctx context.Context, req *pk.BatchRevok
 	}, nil
 }
 
+// UpdateKeyMetadata applies the requested metadata changes. Every attempt is audited as
+// UpdateKeyMetadata; a successful one carries the fields changed with their old and new
+// values.
 func (s *keyServiceImpl) UpdateKeyMetadata(ctx context.Context, req *pk.UpdateKeyMetadataRequest) error {
 	if req == nil {
 		return fmt.Errorf("%w: request is nil", ErrInvalidRequest)
@@ -299,20 +364,54 @@ func (s *keyServiceImpl) UpdateKeyMetadata(ctx context.Context, req *pk.UpdateKe
 	if err != nil {
 		return err
 	}
+	clientIdentity := req.GetRequesterContext().GetClientIdentity()
 
+	changes, err := s.updateKeyMetadata(ctx, keyID, req)
+	if err != nil {
+		s.auditLogger.AuditLog(ctx, clientIdentity, "UpdateKeyMetadata", keyID.String(), "", false, err)
+		return err
+	}
+	s.auditLogger.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), clientIdentity, "UpdateKeyMetadata", keyID.String(), "", true, nil)
+	return nil
+}
+
+func (s *keyServiceImpl) updateKeyMetadata(ctx context.Context, keyID domain.KeyID, req *pk.UpdateKeyMetadataRequest) ([]domain.AuditChange, error) {
 	key, err := s.keyRepo.GetKey(ctx, keyID)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to get key for metadata update", "keyId", req.GetKeyId(), "error", err)
-		return fmt.Errorf("failed to get key: %w", err)
+		return nil, fmt.Errorf("failed to get key: %w", err)
 	}
 
+	before := proto.Clone(key.Metadata).(*pk.KeyMetadata)
 	metadata := key.Metadata
 	var updatedFields []string
+	if mask, ok := domain.UpdateMaskFromContext(ctx); ok {
+		updatedFields, err = applyMaskedUpdate(metadata, req, mask)
+	} else {
+		updatedFields, err = applyUpdate(metadata, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.UpdatedAt = timestamppb.New(s.clock.Now())
+
+	if err := s.keyRepo.UpdateKeyMetadata(ctx, keyID, metadata); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update key metadata", "keyId", req.GetKeyId(), "error", err)
+		return nil, fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "key metadata updated", "keyId", req.GetKeyId(), "fields", updatedFields)
+	return metadataChanges(before, metadata), nil
+}
 
+// applyUpdate applies to metadata the fields req sets, and returns their names.
+func applyUpdate(metadata *pk.KeyMetadata, req *pk.UpdateKeyMetadataRequest) ([]string, error) {
+	var updatedFields []string
 	if req.Description != nil {
 		description, err := domain.NewDescription(*req.Description)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		metadata.Description = description.String()
 		updatedFields = append(updatedFields, "description")
@@ -338,16 +437,74 @@ func (s *keyServiceImpl) UpdateKeyMetadata(ctx context.Context, req *pk.UpdateKe
 			delete(metadata.Tags, tag)
 		}
 	}
+	return updatedFields, nil
+}
 
-	metadata.UpdatedAt = timestamppb.Now()
+// applyMaskedUpdate sets the fields of metadata mask names to their value in req, and
+// clears those req leaves unset: an expires_at path without expires_at removes the
+// expiry. A tags path replaces the tags with tags_to_add. Fields req sets outside the
+// mask are left alone.
+func applyMaskedUpdate(metadata *pk.KeyMetadata, req *pk.UpdateKeyMetadataRequest, mask *fieldmaskpb.FieldMask) ([]string, error) {
+	for _, path := range mask.GetPaths() {
+		switch path {
+		case "description":
+			description, err := domain.NewDescription(req.GetDescription())
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
+			}
+			metadata.Description = description.String()
+		case "expires_at":
+			metadata.ExpiresAt = req.GetExpiresAt()
+		case "data_classification":
+			metadata.DataClassification = req.GetDataClassification()
+		case "tags":
+			metadata.Tags = maps.Clone(req.GetTagsToAdd())
+		default:
+			return nil, fmt.Errorf("%w: update mask path %q is not one of %v", app_errors.ErrInvalidInput, path, domain.UpdatableMetadataFields)
+		}
+	}
+	return mask.GetPaths(), nil
+}
 
-	if err := s.keyRepo.UpdateKeyMetadata(ctx, keyID, metadata); err != nil {
-		s.logger.ErrorContext(ctx, "failed to update key metadata", "keyId", req.GetKeyId(), "error", err)
-		return fmt.Errorf("failed to update metadata: %w", err)
+// metadataChanges lists the user-editable metadata fields that differ between before and
+// after. Tags and access policies are compared entry by entry, as "tags.<name>" and
+// "access_policies.<name>".
+func metadataChanges(before, after *pk.KeyMetadata) []domain.AuditChange {
+	var changes []domain.AuditChange
+	add := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, domain.AuditChange{Field: field, Old: oldValue, New: newValue})
+		}
 	}
+	add("description", before.GetDescription(), after.GetDescription())
+	add("data_classification", before.GetDataClassification(), after.GetDataClassification())
+	add("expires_at", formatTimestamp(before.GetExpiresAt()), formatTimestamp(after.GetExpiresAt()))
+	for _, m := range []struct {
+		prefix        string
+		before, after map[string]string
+	}{
+		{"tags.", before.GetTags(), after.GetTags()},
+		{"access_policies.", before.GetAccessPolicies(), after.GetAccessPolicies()},
+	} {
+		names := slices.Collect(maps.Keys(m.before))
+		for name := range m.after {
+			if _, ok := m.before[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			add(m.prefix+name, m.before[name], m.after[name])
+		}
+	}
+	return changes
+}
 
-	s.logger.InfoContext(ctx, "key metadata updated", "keyId", req.GetKeyId(), "fields", updatedFields)
-	return nil
+func formatTimestamp(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().UTC().Format(time.RFC3339Nano)
 }
 
 func (s *keyServiceImpl) BatchUpdateKeyMetadata(ctx context.Context, req *pk.BatchUpdateKeyMetadataRequest) (*pk.BatchUpdateKeyMetadataResponse, error) {
@@ -395,6 +552,18 @@ func (s *keyServiceImpl) BatchUpdateKeyMetadata(ctx context.Context, req *pk.Bat
 
 		metadata := currentKey.Metadata
 
+		if err := checkExportPolicyUpdate(metadata, item.GetPoliciesToUpdate()); err != nil {
+			failedCount++
+			results = append(results, &pk.BatchUpdateKeyMetadataResult{
+				KeyId:  item.GetKeyId(),
+				Result: &pk.BatchUpdateKeyMetadataResult_Error{Error: err.Error()},
+			})
+			if !req.GetContinueOnError() {
+				return nil, err
+			}
+			continue
+		}
+
 		if item.Description != nil {
 			description, err := domain.NewDescription(*item.Description)
 			if err != nil {
@@ -437,9 +606,10 @@ func (s *keyServiceImpl) BatchUpdateKeyMetadata(ctx context.Context, req *pk.Bat
 			maps.Copy(metadata.AccessPolicies, item.GetPoliciesToUpdate())
 		}
 
-		metadata.UpdatedAt = timestamppb.Now()
+		now := s.clock.Now()
+		metadata.UpdatedAt = timestamppb.New(now)
 		currentKey.Metadata = metadata
-		currentKey.UpdatedAt = time.Now()
+		currentKey.UpdatedAt = now
 		keysToUpdate = append(keysToUpdate, currentKey)
 	}
 
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	cts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TransferKeyOwnership hands a key to a new owner. The new owner is read from the
+// request's policies_to_update under "new_owner"; the authorized contexts are rewritten
+// by replacing the previous owner with the new one and then applying contexts_to_remove
+// and contexts_to_add. Owner and contexts change in a single metadata write, which fails
+// with ErrConflict when the key was rotated or changed owner since it was read.
+//
+// A successful transfer is audited on both sides, as TransferKeyOwnershipOut for the
+// previous owner and TransferKeyOwnershipIn for the new one, so that each party's audit
+// trail shows the hand-over. A failed transfer is audited once, for the requester.
+func (s *keyServiceImpl) TransferKeyOwnership(ctx context.Context, req *pk.UpdateKeyMetadataRequest) (*pk.GetKeyMetadataResponse, error) {
+	ctx, span := tracer.Start(ctx, "TransferKeyOwnership")
+	defer span.End()
+
+	if req == nil {
+		return nil, fmt.Errorf("%w: request is nil", ErrInvalidRequest)
+	}
+	keyID, err := domain.KeyIDFromString(req.GetKeyId())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", app_errors.ErrInvalidInput, err)
+	}
+	clientIdentity := req.GetRequesterContext().GetClientIdentity()
+
+	previousOwner, metadata, err := s.transferKeyOwnership(ctx, keyID, req)
+	if err != nil {
+		s.auditLogger.AuditLog(ctx, clientIdentity, "TransferKeyOwnership", keyID.String(), "", false, err)
+		s.logger.WarnContext(ctx, "failed to transfer key ownership", "keyId", keyID.String(), "error", err)
+		return nil, err
+	}
+
+	s.auditLogger.AuditLog(ctx, previousOwner, "TransferKeyOwnershipOut", keyID.String(), "", true, nil)
+	s.auditLogger.AuditLog(ctx, metadata.GetCreatorIdentity(), "TransferKeyOwnershipIn", keyID.String(), "", true, nil)
+	s.logger.InfoContext(ctx, "key ownership transferred", "keyId", keyID.String(), "from", previousOwner, "to", metadata.GetCreatorIdentity(), "requester", clientIdentity)
+	return &pk.GetKeyMetadataResponse{
+		Metadata:          metadata,
+		ResponseTimestamp: timestamppb.Now(),
+	}, nil
+}
+
+func (s *keyServiceImpl) transferKeyOwnership(ctx context.Context, keyID domain.KeyID, req *pk.UpdateKeyMetadataRequest) (string, *pk.KeyMetadata, error) {
+	newOwner := req.GetPoliciesToUpdate()[cts.TransferParamNewOwner]
+	if newOwner == "" {
+		return "", nil, fmt.Errorf("%w: %s is required", app_errors.ErrInvalidInput, cts.TransferParamNewOwner)
+	}
+
+	key, err := s.keyRepo.GetKey(ctx, keyID)
+	if err != nil {
+		return "", nil, err
+	}
+	if key.Metadata == nil {
+		return "", nil, ErrMissingMetadata
+	}
+	if key.Status != domain.KeyStatusActive {
+		return "", nil, fmt.Errorf("%w: only active keys can change owner", app_errors.ErrInvalidKeyTransition)
+	}
+
+	metadata := proto.Clone(key.Metadata).(*pk.KeyMetadata)
+	previousOwner := metadata.GetCreatorIdentity()
+	if previousOwner == newOwner {
+		return "", nil, fmt.Errorf("%w: key is already owned by %s", app_errors.ErrInvalidInput, newOwner)
+	}
+
+	metadata.AuthorizedContexts = rewriteAuthorizedContexts(metadata.GetAuthorizedContexts(), previousOwner, newOwner, req.GetContextsToRemove(), req.GetContextsToAdd())
+	metadata.CreatorIdentity = newOwner
+	metadata.UpdatedAt = timestamppb.New(s.clock.Now())
+
+	if err := s.keyRepo.UpdateKeyMetadataIfOwner(ctx, keyID, key.Version, previousOwner, metadata); err != nil {
+		return "", nil, fmt.Errorf("failed to update metadata: %w", err)
+	}
+	return previousOwner, metadata, nil
+}
+
+// rewriteAuthorizedContexts swaps the previous owner for the new one, then removes and
+// adds the given contexts. The new owner always ends up authorized.
+func rewriteAuthorizedContexts(contexts []string, previousOwner, newOwner string, remove, add []string) []string {
+	rewritten := make([]string, 0, len(contexts)+len(add)+1)
+	for _, c := range contexts {
+		if c == previousOwner {
+			c = newOwner
+		}
+		if slices.Contains(remove, c) && c != newOwner {
+			continue
+		}
+		if !slices.Contains(rewritten, c) {
+			rewritten = append(rewritten, c)
+		}
+	}
+	for _, c := range append([]string{newOwner}, add...) {
+		if !slices.Contains(rewritten, c) {
+			rewritten = append(rewritten, c)
+		}
+	}
+	return rewritten
+}
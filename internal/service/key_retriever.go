@@ -2,14 +2,13 @@ package service
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
+	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
 	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/config"
 	"github.com/spounge-ai/polykey/pkg/crypto"
-	"github.com/spounge-ai/polykey/pkg/memory"
 	"github.com/spounge-ai/polykey/pkg/patterns/batch"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 	"go.opentelemetry.io/otel"
@@ -46,25 +45,33 @@ func (s *keyServiceImpl) GetKey(ctx context.Context, req *pk.GetKeyRequest) (*pk
 		return nil, ErrMissingMetadata
 	}
 
-	kmsProvider, err := s.getKMSProvider(key.Metadata.GetStorageType())
+	if isExpired(key, s.clock.Now()) {
+		return nil, app_errors.ErrKeyExpired
+	}
+
+	if !keyExportable(key.Metadata) {
+		s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), "GetKey", keyID.String(), "", false, app_errors.ErrKeyNotExportable)
+		return nil, app_errors.ErrKeyNotExportable
+	}
+
+	kmsProvider, err := s.getKeyKMSProvider(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get KMS provider: %w", err)
 	}
 
-	decryptedDEK, err := kmsProvider.DecryptDEK(ctx, key)
+	decryptedDEK, err := decryptDEK(ctx, kmsProvider, key)
 	if err != nil {
 		s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), "GetKey", keyID.String(), "", false, err)
 		return nil, fmt.Errorf("%w: %w", app_errors.ErrKMSFailure, err)
 	}
-	defer memory.SecureZeroBytes(decryptedDEK)
+	defer decryptedDEK.Destroy()
 
 	_, algorithm, err := crypto.GetCryptoDetails(key.Metadata.GetKeyType())
 	if err != nil {
 		return nil, err
 	}
 
-	hash := sha256.Sum256(decryptedDEK)
-	checksum := hex.EncodeToString(hash[:])
+	checksum := crypto.KeyChecksum(decryptedDEK.Bytes())
 
 	resp := &pk.GetKeyResponse{
 		KeyMaterial: &pk.KeyMaterial{
@@ -79,11 +86,25 @@ func (s *keyServiceImpl) GetKey(ctx context.Context, req *pk.GetKeyRequest) (*pk
 		resp.Metadata = key.Metadata
 	}
 
+	s.recordAccess(keyID)
 	s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), "GetKey", keyID.String(), "", true, nil)
 	s.logger.InfoContext(ctx, "key retrieved and decrypted", "keyId", req.GetKeyId(), "version", key.Version)
 	return resp, nil
 }
 
+// isExpired reports whether key has been expired by the expiration job or is past its
+// ExpiresAt or, for a rotated version, its grace deadline and simply has not been swept yet.
+func isExpired(key *domain.Key, now time.Time) bool {
+	if key.Status == domain.KeyStatusExpired {
+		return true
+	}
+	if key.Status == domain.KeyStatusRotated && key.GraceExpiresAt != nil && !key.GraceExpiresAt.After(now) {
+		return true
+	}
+	expiresAt := key.Metadata.GetExpiresAt()
+	return expiresAt != nil && !expiresAt.AsTime().After(now)
+}
+
 func (s *keyServiceImpl) GetKeyMetadata(ctx context.Context, req *pk.GetKeyMetadataRequest) (*pk.GetKeyMetadataResponse, error) {
 	ctx, span := tracer.Start(ctx, "GetKeyMetadata")
 	defer span.End()
@@ -131,6 +152,56 @@ func (s *keyServiceImpl) BatchGetKeys(ctx context.Context, req *pk.BatchGetKeysR
 	ctx, span := tracer.Start(ctx, "BatchGetKeys")
 	defer span.End()
 
+	processor, err := s.batchGetProcessor(ctx, req, "BatchGetKeys")
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := processor.ProcessBatch(ctx, req.Keys, req.GetContinueOnError())
+	if err != nil {
+		return nil, err
+	}
+
+	batchResults := make([]*pk.BatchGetKeysResult, len(results.Items))
+	var successCount, failedCount int32
+	for i, item := range results.Items {
+		if item.Error != nil {
+			failedCount++
+		} else {
+			successCount++
+		}
+		batchResults[i] = batchGetKeysResult(req.Keys[i], item)
+	}
+
+	return &pk.BatchGetKeysResponse{
+		Results:           batchResults,
+		ResponseTimestamp: timestamppb.Now(),
+		SuccessfulCount:   successCount,
+		FailedCount:       failedCount,
+	}, nil
+}
+
+// StreamBatchGetKeys reads the keys of req like BatchGetKeys, but sends the result of
+// each key as soon as it is read instead of collecting them, in the order the reads
+// complete.
+func (s *keyServiceImpl) StreamBatchGetKeys(ctx context.Context, req *pk.BatchGetKeysRequest, send func(*pk.BatchGetKeysResult) error) error {
+	ctx, span := tracer.Start(ctx, "StreamBatchGetKeys")
+	defer span.End()
+
+	processor, err := s.batchGetProcessor(ctx, req, "StreamBatchGetKeys")
+	if err != nil {
+		return err
+	}
+	return processor.StreamBatch(ctx, req.Keys, func(i int, item batch.BatchItem[*pk.GetKeyResponse]) error {
+		return send(batchGetKeysResult(req.Keys[i], item))
+	})
+}
+
+// batchGetProcessor loads the keys of req in one repository call and returns the
+// processor that reads each of them, audited as operation. Each key is held to the
+// checks GetKey applies to it: the per-key authorization of ctx, which covers step-up,
+// authorized contexts and tier, and its status.
+func (s *keyServiceImpl) batchGetProcessor(ctx context.Context, req *pk.BatchGetKeysRequest, operation string) (*batch.BatchProcessor[*pk.KeyRequestItem, *pk.GetKeyResponse], error) {
 	if req == nil || req.RequesterContext == nil || req.RequesterContext.GetClientIdentity() == "" {
 		return nil, app_errors.ErrInvalidInput
 	}
@@ -154,37 +225,51 @@ func (s *keyServiceImpl) BatchGetKeys(ctx context.Context, req *pk.BatchGetKeysR
 		keyMap[key.ID.String()] = key
 	}
 
-	processor := batch.BatchProcessor[*pk.KeyRequestItem, *pk.GetKeyResponse]{
-		MaxConcurrency: 10, // Make this configurable
+	return &batch.BatchProcessor[*pk.KeyRequestItem, *pk.GetKeyResponse]{
+		MaxConcurrency: s.cfg.Batch.MaxConcurrency(config.BatchOperationGet),
+		ObserveWait:    observeBatchWait(ctx, config.BatchOperationGet),
 		Validate: func(item *pk.KeyRequestItem) error {
-			_, ok := keyMap[item.GetKeyId()]
+			key, ok := keyMap[item.GetKeyId()]
 			if !ok {
 				return fmt.Errorf("key not found: %s", item.GetKeyId())
 			}
+			if check, ok := domain.KeyCheckFromContext(ctx); ok {
+				if err := check(ctx, key.ID); err != nil {
+					return err
+				}
+			}
+			if key.Status == domain.KeyStatusRevoked {
+				return app_errors.ErrKeyRevoked
+			}
+			if isExpired(key, s.clock.Now()) {
+				return app_errors.ErrKeyExpired
+			}
+			if !keyExportable(key.Metadata) {
+				return app_errors.ErrKeyNotExportable
+			}
 			return nil
 		},
 		Process: func(ctx context.Context, item *pk.KeyRequestItem) (*pk.GetKeyResponse, error) {
 			key := keyMap[item.GetKeyId()]
 
-			kmsProvider, err := s.getKMSProvider(key.Metadata.GetStorageType())
+			kmsProvider, err := s.getKeyKMSProvider(key)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get KMS provider: %w", err)
 			}
 
-			decryptedDEK, err := kmsProvider.DecryptDEK(ctx, key)
+			decryptedDEK, err := decryptDEK(ctx, kmsProvider, key)
 			if err != nil {
-				s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), "BatchGetKeys", key.ID.String(), "", false, err)
+				s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), operation, key.ID.String(), "", false, err)
 				return nil, fmt.Errorf("%w: %w", app_errors.ErrKMSFailure, err)
 			}
-			defer memory.SecureZeroBytes(decryptedDEK)
+			defer decryptedDEK.Destroy()
 
 			_, algorithm, err := crypto.GetCryptoDetails(key.Metadata.GetKeyType())
 			if err != nil {
 				return nil, err
 			}
 
-			hash := sha256.Sum256(decryptedDEK)
-			checksum := hex.EncodeToString(hash[:])
+			checksum := crypto.KeyChecksum(decryptedDEK.Bytes())
 
 			resp := &pk.GetKeyResponse{
 				KeyMaterial: &pk.KeyMaterial{
@@ -198,40 +283,24 @@ func (s *keyServiceImpl) BatchGetKeys(ctx context.Context, req *pk.BatchGetKeysR
 			if !item.GetSkipMetadata() {
 				resp.Metadata = key.Metadata
 			}
-			s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), "BatchGetKeys", key.ID.String(), "", true, nil)
+			s.recordAccess(key.ID)
+			s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), operation, key.ID.String(), "", true, nil)
 			return resp, nil
 		},
-	}
-
-	results, err := processor.ProcessBatch(ctx, req.Keys, req.GetContinueOnError())
-	if err != nil {
-		return nil, err
-	}
+	}, nil
+}
 
-	batchResults := make([]*pk.BatchGetKeysResult, len(results.Items))
-	var successCount, failedCount int32
-	for i, item := range results.Items {
-		if item.Error != nil {
-			failedCount++
-			batchResults[i] = &pk.BatchGetKeysResult{
-				KeyId:  req.Keys[i].GetKeyId(),
-				Result: &pk.BatchGetKeysResult_Error{Error: item.Error.Error()},
-			}
-		} else {
-			successCount++
-			batchResults[i] = &pk.BatchGetKeysResult{
-				KeyId:  req.Keys[i].GetKeyId(),
-				Result: &pk.BatchGetKeysResult_Success{Success: item.Result},
-			}
+func batchGetKeysResult(req *pk.KeyRequestItem, item batch.BatchItem[*pk.GetKeyResponse]) *pk.BatchGetKeysResult {
+	if item.Error != nil {
+		return &pk.BatchGetKeysResult{
+			KeyId:  req.GetKeyId(),
+			Result: &pk.BatchGetKeysResult_Error{Error: item.Error.Error()},
 		}
 	}
-
-	return &pk.BatchGetKeysResponse{
-		Results:           batchResults,
-		ResponseTimestamp: timestamppb.Now(),
-		SuccessfulCount:   successCount,
-		FailedCount:       failedCount,
-	}, nil
+	return &pk.BatchGetKeysResult{
+		KeyId:  req.GetKeyId(),
+		Result: &pk.BatchGetKeysResult_Success{Success: item.Result},
+	}
 }
 
 func (s *keyServiceImpl) BatchGetKeyMetadata(ctx context.Context, req *pk.BatchGetKeyMetadataRequest) (*pk.BatchGetKeyMetadataResponse, error) {
@@ -11,6 +11,7 @@ import (
 	"github.com/spounge-ai/polykey/internal/infra/config"
 	"github.com/spounge-ai/polykey/internal/kms"
 	"github.com/spounge-ai/polykey/internal/pipelines"
+	"github.com/spounge-ai/polykey/pkg/clock"
 	"github.com/spounge-ai/polykey/pkg/crypto"
 	"github.com/spounge-ai/polykey/pkg/memory"
 	"github.com/spounge-ai/polykey/pkg/postgres"
@@ -29,16 +30,30 @@ type KeyService interface {
 	CreateKey(ctx context.Context, req *pk.CreateKeyRequest) (*pk.CreateKeyResponse, error)
 	GetKey(ctx context.Context, req *pk.GetKeyRequest) (*pk.GetKeyResponse, error)
 	ListKeys(ctx context.Context, req *pk.ListKeysRequest) (*pk.ListKeysResponse, error)
+	StreamListKeys(ctx context.Context, req *pk.ListKeysRequest, send func(*pk.ListKeysResponse) error) error
+	ListKeyVersions(ctx context.Context, req *pk.GetKeyMetadataRequest) (*pk.ListKeysResponse, error)
 	RotateKey(ctx context.Context, req *pk.RotateKeyRequest) (*pk.RotateKeyResponse, error)
+	RotateKeysByFilter(ctx context.Context, req *pk.ListKeysRequest, send func(*pk.BatchRotateKeysResponse) error) error
+	RotateDueKeys(ctx context.Context, pageSize int) (rotated, failed int, err error)
 	RevokeKey(ctx context.Context, req *pk.RevokeKeyRequest) error
+	RestoreKey(ctx context.Context, req *pk.RevokeKeyRequest) (*pk.GetKeyMetadataResponse, error)
 	UpdateKeyMetadata(ctx context.Context, req *pk.UpdateKeyMetadataRequest) error
+	TransferKeyOwnership(ctx context.Context, req *pk.UpdateKeyMetadataRequest) (*pk.GetKeyMetadataResponse, error)
 	GetKeyMetadata(ctx context.Context, req *pk.GetKeyMetadataRequest) (*pk.GetKeyMetadataResponse, error)
 	BatchCreateKeys(ctx context.Context, req *pk.BatchCreateKeysRequest) (*pk.BatchCreateKeysResponse, error)
 	BatchGetKeys(ctx context.Context, req *pk.BatchGetKeysRequest) (*pk.BatchGetKeysResponse, error)
+	StreamBatchCreateKeys(ctx context.Context, req *pk.BatchCreateKeysRequest, send func(*pk.BatchCreateKeysResult) error) error
+	StreamBatchGetKeys(ctx context.Context, req *pk.BatchGetKeysRequest, send func(*pk.BatchGetKeysResult) error) error
 	BatchGetKeyMetadata(ctx context.Context, req *pk.BatchGetKeyMetadataRequest) (*pk.BatchGetKeyMetadataResponse, error)
 	BatchRotateKeys(ctx context.Context, req *pk.BatchRotateKeysRequest) (*pk.BatchRotateKeysResponse, error)
 	BatchRevokeKeys(ctx context.Context, req *pk.BatchRevokeKeysRequest) (*pk.BatchRevokeKeysResponse, error)
 	BatchUpdateKeyMetadata(ctx context.Context, req *pk.BatchUpdateKeyMetadataRequest) (*pk.BatchUpdateKeyMetadataResponse, error)
+	PutKeyTemplate(ctx context.Context, req *pk.CreateKeyRequest) error
+	ListKeyTemplates(ctx context.Context, req *pk.ListKeysRequest) (*pk.BatchCreateKeysRequest, error)
+	DeleteKeyTemplate(ctx context.Context, req *pk.CreateKeyRequest) error
+	ApplyKey(ctx context.Context, spec domain.KeySpec, requester *pk.RequesterContext) (*domain.ProvisionedKey, error)
+	GetKeyByAlias(ctx context.Context, alias string) (*domain.ProvisionedKey, error)
+	RotationBacklog() (pending, capacity int)
 }
 
 type keyServiceImpl struct {
@@ -50,15 +65,22 @@ type keyServiceImpl struct {
 	dekPools            map[pk.KeyType]*memory.SecureDEKPool
 	auditLogger         domain.AuditLogger
 	keyRotationPipeline *pipelines.KeyRotationPipeline
+	accessRecorder      domain.KeyAccessRecorder
+	templates           domain.KeyTemplateRepository
+	aliases             domain.KeyAliasRepository
+	pageTokens          pageTokens
+	clock               clock.Clock
 }
 
-func NewKeyService(cfg *config.Config, keyRepo domain.KeyRepository, kmsProviders map[string]kms.KMSProvider, logger *slog.Logger, errorClassifier *app_errors.ErrorClassifier, auditLogger domain.AuditLogger) KeyService {
+func NewKeyService(cfg *config.Config, keyRepo domain.KeyRepository, kmsProviders map[string]kms.KMSProvider, logger *slog.Logger, errorClassifier *app_errors.ErrorClassifier, auditLogger domain.AuditLogger, accessRecorder domain.KeyAccessRecorder, templates domain.KeyTemplateRepository, aliases domain.KeyAliasRepository, clk clock.Clock) KeyService {
 	dekPools := make(map[pk.KeyType]*memory.SecureDEKPool)
 	if size, _, err := crypto.GetCryptoDetails(pk.KeyType_KEY_TYPE_AES_256); err == nil {
-		dekPools[pk.KeyType_KEY_TYPE_AES_256] = memory.NewSecureDEKPool(size)
+		dekPools[pk.KeyType_KEY_TYPE_AES_256] = memory.NewSecureDEKPool(size, cfg.DEKPools.PoolCapacity(pk.KeyType_KEY_TYPE_AES_256))
 	}
+	registerDEKPoolMetrics(dekPools)
 
-	rotationPipeline := pipelines.NewKeyRotationPipeline(keyRepo, logger, 5, 100) // 5 workers, 100 queue depth
+	workers, queueDepth := cfg.Batch.RotationPipelineSize()
+	rotationPipeline := pipelines.NewKeyRotationPipeline(keyRepo, logger, workers, queueDepth)
 	rotationPipeline.Start(context.Background()) // Start the pipeline
 
 	return &keyServiceImpl{
@@ -70,15 +92,60 @@ func NewKeyService(cfg *config.Config, keyRepo domain.KeyRepository, kmsProvider
 		dekPools:            dekPools,
 		auditLogger:         auditLogger,
 		keyRotationPipeline: rotationPipeline,
+		accessRecorder:      accessRecorder,
+		templates:           templates,
+		aliases:             aliases,
+		pageTokens:          newPageTokens(cfg.BootstrapSecrets.JWTRSAPrivateKey),
+		clock:               clk,
 	}
 }
 
-func (s *keyServiceImpl) getKMSProvider(profile pk.StorageProfile) (kms.KMSProvider, error) {
-	providerName := s.cfg.DefaultKMSProvider
+func (s *keyServiceImpl) RotationBacklog() (pending, capacity int) {
+	return s.keyRotationPipeline.Backlog()
+}
+
+// recordAccess counts a successful read of key material. accessRecorder is nil when
+// access statistics are disabled.
+func (s *keyServiceImpl) recordAccess(id domain.KeyID) {
+	if s.accessRecorder != nil {
+		s.accessRecorder.RecordAccess(id)
+	}
+}
+
+// kmsProviderName names the KMS provider that wraps keys of the given storage profile.
+func (s *keyServiceImpl) kmsProviderName(profile pk.StorageProfile) string {
 	if profile == pk.StorageProfile_STORAGE_PROFILE_HARDENED {
-		providerName = "aws"
+		return "aws"
+	}
+	return s.cfg.DefaultKMSProvider
+}
+
+// keyKMSProviderName names the KMS provider that wrapped the DEK of key: the one it
+// records, or for versions that record none, the one its storage profile selects.
+func (s *keyServiceImpl) keyKMSProviderName(key *domain.Key) string {
+	if key.KMSProvider != "" {
+		return key.KMSProvider
 	}
+	return s.kmsProviderName(key.Metadata.GetStorageType())
+}
+
+// getKeyKMSProvider returns the KMS provider that wrapped the DEK of key.
+func (s *keyServiceImpl) getKeyKMSProvider(key *domain.Key) (kms.KMSProvider, error) {
+	return s.kmsProvider(s.keyKMSProviderName(key))
+}
+
+// decryptDEK unwraps the DEK of key with provider into a locked buffer, zeroing the copy
+// the provider returned. The caller destroys the buffer.
+func decryptDEK(ctx context.Context, provider kms.KMSProvider, key *domain.Key) (*memory.LockedBuffer, error) {
+	plaintext, err := provider.DecryptDEK(ctx, key)
+	if err != nil {
+		memory.SecureZeroBytes(plaintext)
+		return nil, err
+	}
+	return memory.MoveToLockedBuffer(plaintext), nil
+}
 
+func (s *keyServiceImpl) kmsProvider(providerName string) (kms.KMSProvider, error) {
 	provider, ok := s.kmsProviders[providerName]
 	if !ok {
 		return nil, fmt.Errorf("%s kms provider not found", providerName)
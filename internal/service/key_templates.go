@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cts "github.com/spounge-ai/polykey/internal/constants"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+// PutKeyTemplate creates or replaces the template named by the request's "template"
+// generation parameter. The remaining request fields are the template's defaults and an
+// optional "rotation_period" generation parameter sets its rotation period.
+func (s *keyServiceImpl) PutKeyTemplate(ctx context.Context, req *pk.CreateKeyRequest) error {
+	templates, err := s.templateRepo()
+	if err != nil {
+		return err
+	}
+	name := req.GetGenerationParams()[cts.GenerationParamTemplate]
+	if name == "" {
+		return fmt.Errorf("%w: template name is required", app_errors.ErrInvalidInput)
+	}
+
+	var rotationPeriod time.Duration
+	if raw := req.GetGenerationParams()[cts.GenerationParamRotationPeriod]; raw != "" {
+		rotationPeriod, err = time.ParseDuration(raw)
+		if err != nil || rotationPeriod < 0 {
+			return fmt.Errorf("%w: invalid rotation period %q", app_errors.ErrInvalidInput, raw)
+		}
+	}
+
+	template := &domain.KeyTemplate{
+		Name:               name,
+		KeyType:            req.GetKeyType(),
+		Description:        req.GetDescription(),
+		DataClassification: req.GetDataClassification(),
+		Tags:               req.GetTags(),
+		AuthorizedContexts: req.GetInitialAuthorizedContexts(),
+		AccessPolicies:     req.GetAccessPolicies(),
+		RotationPeriod:     rotationPeriod,
+	}
+	if err := templates.PutKeyTemplate(ctx, template); err != nil {
+		return err
+	}
+
+	s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), "PutKeyTemplate", "", "", true, nil)
+	s.logger.InfoContext(ctx, "key template stored", "template", name)
+	return nil
+}
+
+// ListKeyTemplates returns every template as a CreateKeyItem carrying the template's
+// name and rotation period in its generation parameters, mirroring PutKeyTemplate.
+func (s *keyServiceImpl) ListKeyTemplates(ctx context.Context, req *pk.ListKeysRequest) (*pk.BatchCreateKeysRequest, error) {
+	templates, err := s.templateRepo()
+	if err != nil {
+		return nil, err
+	}
+	list, err := templates.ListKeyTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*pk.CreateKeyItem, len(list))
+	for i, template := range list {
+		params := map[string]string{cts.GenerationParamTemplate: template.Name}
+		if template.RotationPeriod > 0 {
+			params[cts.GenerationParamRotationPeriod] = template.RotationPeriod.String()
+		}
+		items[i] = &pk.CreateKeyItem{
+			KeyType:                   template.KeyType,
+			Description:               template.Description,
+			Tags:                      template.Tags,
+			InitialAuthorizedContexts: template.AuthorizedContexts,
+			AccessPolicies:            template.AccessPolicies,
+			DataClassification:        template.DataClassification,
+			GenerationParams:          params,
+		}
+	}
+	return &pk.BatchCreateKeysRequest{Keys: items}, nil
+}
+
+// DeleteKeyTemplate removes the template named by the request's "template" generation
+// parameter. Keys already created from it are unaffected.
+func (s *keyServiceImpl) DeleteKeyTemplate(ctx context.Context, req *pk.CreateKeyRequest) error {
+	templates, err := s.templateRepo()
+	if err != nil {
+		return err
+	}
+	name := req.GetGenerationParams()[cts.GenerationParamTemplate]
+	if name == "" {
+		return fmt.Errorf("%w: template name is required", app_errors.ErrInvalidInput)
+	}
+	if err := templates.DeleteKeyTemplate(ctx, name); err != nil {
+		return err
+	}
+
+	s.auditLogger.AuditLog(ctx, req.GetRequesterContext().GetClientIdentity(), "DeleteKeyTemplate", "", "", true, nil)
+	s.logger.InfoContext(ctx, "key template deleted", "template", name)
+	return nil
+}
+
+func (s *keyServiceImpl) templateRepo() (domain.KeyTemplateRepository, error) {
+	if s.templates == nil {
+		return nil, fmt.Errorf("%w: key templates are not enabled on this server", app_errors.ErrTemplateNotFound)
+	}
+	return s.templates, nil
+}
+
+// applyTemplate returns item with the template it names merged in, or item itself when
+// it names none. The request is never modified in place.
+func (s *keyServiceImpl) applyTemplate(ctx context.Context, item *pk.CreateKeyItem, cache map[string]*domain.KeyTemplate) (*pk.CreateKeyItem, error) {
+	name := item.GetGenerationParams()[cts.GenerationParamTemplate]
+	if name == "" {
+		return item, nil
+	}
+
+	template, ok := cache[name]
+	if !ok {
+		templates, err := s.templateRepo()
+		if err != nil {
+			return nil, err
+		}
+		template, err = templates.GetKeyTemplate(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		cache[name] = template
+	}
+
+	templated := proto.Clone(item).(*pk.CreateKeyItem)
+	if err := template.Apply(templated, cts.PolicyRotationPeriod); err != nil {
+		return nil, err
+	}
+	return templated, nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/kms"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"github.com/spounge-ai/polykey/pkg/crypto"
+)
+
+const (
+	defaultRewrapBatchSize = 100
+	maxRewrapBatchSize     = 1000
+)
+
+// KMSRewrapService moves the wrapped DEKs of keys from one KMS provider to another, so
+// that a provider migration never exposes key material outside the service.
+type KMSRewrapService interface {
+	RewrapKeys(ctx context.Context, req domain.RewrapRequest) (*domain.RewrapCheckpoint, error)
+}
+
+type kmsRewrapService struct {
+	repo      domain.KeyRewrapRepository
+	providers map[string]kms.KMSProvider
+	cfg       *config.Config
+	keyCache  domain.CacheFlusher
+	logger    *slog.Logger
+	clock     clock.Clock
+
+	// running allows one re-wrap at a time in this instance. Instances running the same
+	// re-wrap concurrently do no harm, since a DEK is only replaced if it is unchanged.
+	running sync.Mutex
+}
+
+// NewKMSRewrapService creates a KMSRewrapService. keyCache is flushed after every batch
+// so that no stale wrapped DEK is served from it, and is nil when keys are not cached.
+func NewKMSRewrapService(repo domain.KeyRewrapRepository, providers map[string]kms.KMSProvider, cfg *config.Config, keyCache domain.CacheFlusher, logger *slog.Logger, clk clock.Clock) KMSRewrapService {
+	return &kmsRewrapService{
+		repo:      repo,
+		providers: providers,
+		cfg:       cfg,
+		keyCache:  keyCache,
+		logger:    logger,
+		clock:     clk,
+	}
+}
+
+// RewrapKeys re-wraps batches of key versions, resuming from the checkpoint of the
+// provider pair, and returns the checkpoint it reached. Each DEK is unwrapped by the
+// source provider, wrapped by the target and checked to unwrap to the same DEK before it
+// replaces the old one. A version that fails is counted and skipped; once the re-wrap
+// completes, restarting it retries what is still wrapped by the source.
+func (s *kmsRewrapService) RewrapKeys(ctx context.Context, req domain.RewrapRequest) (*domain.RewrapCheckpoint, error) {
+	from, to, err := s.rewrapProviders(req)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := req.BatchSize
+	switch {
+	case batchSize == 0:
+		batchSize = defaultRewrapBatchSize
+	case batchSize < 0 || batchSize > maxRewrapBatchSize:
+		return nil, fmt.Errorf("%w: batch_size must be between 1 and %d", app_errors.ErrInvalidInput, maxRewrapBatchSize)
+	}
+	if req.MaxBatches < 0 || req.BatchInterval < 0 {
+		return nil, fmt.Errorf("%w: max_batches and batch_interval must not be negative", app_errors.ErrInvalidInput)
+	}
+
+	if !s.running.TryLock() {
+		return nil, app_errors.ErrKMSRewrapInProgress
+	}
+	defer s.running.Unlock()
+
+	if req.Restart {
+		if err := s.repo.DeleteRewrapCheckpoint(ctx, req.FromProvider, req.ToProvider); err != nil {
+			return nil, err
+		}
+	}
+	checkpoint, err := s.repo.GetRewrapCheckpoint(ctx, req.FromProvider, req.ToProvider)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint == nil {
+		checkpoint = &domain.RewrapCheckpoint{FromProvider: req.FromProvider, ToProvider: req.ToProvider, StartedAt: s.clock.Now()}
+	}
+	if checkpoint.CompletedAt != nil {
+		return checkpoint, nil
+	}
+
+	for batch := 0; req.MaxBatches == 0 || batch < req.MaxBatches; batch++ {
+		if batch > 0 && req.BatchInterval > 0 {
+			select {
+			case <-ctx.Done():
+				return checkpoint, ctx.Err()
+			case <-time.After(req.BatchInterval):
+			}
+		}
+
+		keys, err := s.repo.ListWrappedKeys(ctx, req.FromProvider, s.cfg.DefaultKMSProvider, checkpoint.After, batchSize)
+		if err != nil {
+			return checkpoint, err
+		}
+		if len(keys) == 0 {
+			now := s.clock.Now()
+			checkpoint.CompletedAt = &now
+			if err := s.repo.SaveRewrapCheckpoint(ctx, checkpoint); err != nil {
+				return checkpoint, err
+			}
+			s.logger.InfoContext(ctx, "kms re-wrap completed", "from", req.FromProvider, "to", req.ToProvider,
+				"rewrapped", checkpoint.Rewrapped, "failed", checkpoint.Failed)
+			break
+		}
+
+		err = s.rewrapBatch(ctx, keys, from, to, req.ToProvider, checkpoint)
+		if s.keyCache != nil {
+			s.keyCache.FlushCache(ctx)
+		}
+		// The checkpoint is saved even when the batch was interrupted, so that the next
+		// call resumes after the last version finished. It must outlive ctx for that.
+		if saveErr := s.repo.SaveRewrapCheckpoint(context.WithoutCancel(ctx), checkpoint); saveErr != nil {
+			return checkpoint, errors.Join(err, saveErr)
+		}
+		if err != nil {
+			return checkpoint, err
+		}
+		s.logger.InfoContext(ctx, "kms re-wrap batch finished", "from", req.FromProvider, "to", req.ToProvider,
+			"keys", len(keys), "rewrapped", checkpoint.Rewrapped, "failed", checkpoint.Failed)
+	}
+	return checkpoint, nil
+}
+
+func (s *kmsRewrapService) rewrapProviders(req domain.RewrapRequest) (from, to kms.KMSProvider, err error) {
+	if req.FromProvider == "" || req.ToProvider == "" {
+		return nil, nil, fmt.Errorf("%w: from_provider and to_provider are required", app_errors.ErrInvalidInput)
+	}
+	if req.FromProvider == req.ToProvider {
+		return nil, nil, fmt.Errorf("%w: from_provider and to_provider must differ", app_errors.ErrInvalidInput)
+	}
+	from, ok := s.providers[req.FromProvider]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", app_errors.ErrKMSProviderUnavailable, req.FromProvider)
+	}
+	to, ok = s.providers[req.ToProvider]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", app_errors.ErrKMSProviderUnavailable, req.ToProvider)
+	}
+	return from, to, nil
+}
+
+// rewrapBatch re-wraps keys in order, advancing checkpoint past each version it
+// finishes. It stops early only when ctx is done.
+func (s *kmsRewrapService) rewrapBatch(ctx context.Context, keys []*domain.Key, from, to kms.KMSProvider, toName string, checkpoint *domain.RewrapCheckpoint) error {
+	for _, key := range keys {
+		replaced, err := s.rewrapKey(ctx, key, from, to, toName)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			checkpoint.Failed++
+			s.logger.WarnContext(ctx, "failed to re-wrap key version, skipping it",
+				"key_id", key.ID.String(), "version", key.Version, "error", err)
+		} else if replaced {
+			checkpoint.Rewrapped++
+		}
+		checkpoint.After = domain.KeyVersionCursor{KeyID: key.ID.String(), Version: key.Version}
+	}
+	return nil
+}
+
+// rewrapKey replaces the DEK of key, wrapped by from, with the same DEK wrapped by to.
+// It reports false if the DEK changed since it was read.
+func (s *kmsRewrapService) rewrapKey(ctx context.Context, key *domain.Key, from, to kms.KMSProvider, toName string) (bool, error) {
+	dek, err := decryptDEK(ctx, from, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to unwrap DEK: %w", err)
+	}
+	defer dek.Destroy()
+
+	rewrapped, err := to.EncryptDEK(ctx, dek.Bytes(), key)
+	if err != nil {
+		return false, fmt.Errorf("failed to wrap DEK: %w", err)
+	}
+
+	check := *key
+	check.EncryptedDEK = rewrapped
+	roundTrip, err := decryptDEK(ctx, to, &check)
+	if err != nil {
+		return false, fmt.Errorf("failed to unwrap the re-wrapped DEK: %w", err)
+	}
+	defer roundTrip.Destroy()
+	if err := crypto.VerifyKeyChecksum(roundTrip.Bytes(), crypto.KeyChecksum(dek.Bytes())); err != nil {
+		return false, fmt.Errorf("the re-wrapped DEK does not unwrap to the original: %w", err)
+	}
+
+	return s.repo.ReplaceWrappedDEK(ctx, key.ID, key.Version, key.EncryptedDEK, rewrapped, toName)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/spounge-ai/polykey/pkg/memory"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otel.Meter("github.com/spounge-ai/polykey/internal/service")
+
+var (
+	batchQueueWait, _ = meter.Float64Histogram(
+		"polykey.batch.queue_wait",
+		metric.WithDescription("Time items of batch RPCs waited for a worker, by operation."),
+		metric.WithUnit("s"),
+	)
+	dekPoolGets, _ = meter.Int64ObservableCounter(
+		"polykey.dek_pool.gets",
+		metric.WithDescription("Number of DEK buffers taken from the pools, by key type."),
+	)
+	dekPoolPuts, _ = meter.Int64ObservableCounter(
+		"polykey.dek_pool.puts",
+		metric.WithDescription("Number of DEK buffers zeroed and returned to the pools, by key type."),
+	)
+	dekPoolMisses, _ = meter.Int64ObservableCounter(
+		"polykey.dek_pool.misses",
+		metric.WithDescription("Number of DEK buffers allocated because no idle one was pooled, by key type."),
+	)
+	dekPoolOutstanding, _ = meter.Int64ObservableGauge(
+		"polykey.dek_pool.outstanding",
+		metric.WithDescription("Number of DEK buffers taken and not yet returned, by key type."),
+	)
+	dekPoolIdle, _ = meter.Int64ObservableGauge(
+		"polykey.dek_pool.idle",
+		metric.WithDescription("Number of zeroed DEK buffers waiting in the pools, by key type."),
+	)
+)
+
+// observeBatchWait records the queue wait of the items of a batch of operation.
+func observeBatchWait(ctx context.Context, operation string) func(time.Duration) {
+	attrs := metric.WithAttributes(attribute.String("operation", operation))
+	return func(wait time.Duration) {
+		batchQueueWait.Record(ctx, wait.Seconds(), attrs)
+	}
+}
+
+// registerDEKPoolMetrics reports the use of pools while the process runs. A steadily
+// growing outstanding count means a caller does not return, and so never zeroes, its
+// buffers.
+func registerDEKPoolMetrics(pools map[pk.KeyType]*memory.SecureDEKPool) {
+	_, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for keyType, pool := range pools {
+			stats := pool.Stats()
+			attrs := metric.WithAttributes(attribute.String("key_type", keyType.String()))
+			o.ObserveInt64(dekPoolGets, stats.Gets, attrs)
+			o.ObserveInt64(dekPoolPuts, stats.Puts, attrs)
+			o.ObserveInt64(dekPoolMisses, stats.Misses, attrs)
+			o.ObserveInt64(dekPoolOutstanding, stats.Outstanding, attrs)
+			o.ObserveInt64(dekPoolIdle, int64(stats.Idle), attrs)
+		}
+		return nil
+	}, dekPoolGets, dekPoolPuts, dekPoolMisses, dekPoolOutstanding, dekPoolIdle)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+)
+
+// pageTokenContext separates the page token key from other keys derived from the same
+// secret.
+const pageTokenContext = "polykey page tokens\n"
+
+// pageTokens encodes the cursors of paginated lists as opaque page tokens: the list,
+// a hash of the filters it was read with and the position reached, signed with
+// HMAC-SHA256. A token that was altered, comes from another list, or is presented with
+// other filters than the ones it was issued for is rejected, so that a listing cannot
+// be steered into rows its filters exclude.
+type pageTokens struct {
+	key []byte
+}
+
+// newPageTokens derives the signing key of page tokens from secret, which every replica
+// must share.
+func newPageTokens(secret string) pageTokens {
+	key := sha256.Sum256([]byte(pageTokenContext + secret))
+	return pageTokens{key: key[:]}
+}
+
+type pageTokenPayload struct {
+	List     string `json:"l"`
+	Filters  string `json:"f"`
+	Position string `json:"p"`
+}
+
+// encode returns the page token of position in list, read with filters.
+func (p pageTokens) encode(list string, filters any, position string) string {
+	payload, _ := json.Marshal(pageTokenPayload{List: list, Filters: filterHash(filters), Position: position})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(p.sign(encoded))
+}
+
+// decode returns the position of token, checking that it was issued by encode for list
+// and filters.
+func (p pageTokens) decode(list string, filters any, token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", fmt.Errorf("%w: malformed page token", app_errors.ErrInvalidInput)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, p.sign(encoded)) {
+		return "", fmt.Errorf("%w: page token signature does not match", app_errors.ErrInvalidInput)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed page token", app_errors.ErrInvalidInput)
+	}
+	var payload pageTokenPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return "", fmt.Errorf("%w: malformed page token", app_errors.ErrInvalidInput)
+	}
+	if payload.List != list {
+		return "", fmt.Errorf("%w: page token belongs to another list", app_errors.ErrInvalidInput)
+	}
+	if payload.Filters != filterHash(filters) {
+		return "", fmt.Errorf("%w: filters changed since the page token was issued; start again from the first page", app_errors.ErrInvalidInput)
+	}
+	return payload.Position, nil
+}
+
+func (p pageTokens) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// filterHash returns a short hash of the JSON encoding of filters.
+func filterHash(filters any) string {
+	data, _ := json.Marshal(filters)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
@@ -0,0 +1,83 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+// secretPatterns match the secrets most often pasted into free text by mistake. Each
+// names what it found, since the value itself must not be echoed back or logged.
+var secretPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"a PEM block", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----`)},
+	{"an AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`)},
+	{"an AWS secret access key", regexp.MustCompile(`(?i)aws.{0,20}secret.{0,20}[:=]\s*["']?[A-Za-z0-9/+]{40}\b`)},
+	{"a GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"a GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{"a Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"a Stripe key", regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{20,}\b`)},
+	{"a Google API key", regexp.MustCompile(`\bAIza[A-Za-z0-9_-]{35}\b`)},
+	{"a JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+}
+
+// scanForSecrets fails with ErrSecretInMetadata when value looks like it holds a
+// secret. field names the value in the error.
+func scanForSecrets(field, value string) error {
+	for _, p := range secretPatterns {
+		if p.pattern.MatchString(value) {
+			return fmt.Errorf("%w: %s holds %s", app_errors.ErrSecretInMetadata, field, p.name)
+		}
+	}
+	return nil
+}
+
+func scanMapForSecrets(field string, values map[string]string) error {
+	for name, value := range values {
+		if err := scanForSecrets(field+"."+name, value); err != nil {
+			return err
+		}
+		if err := scanForSecrets(field+" name", name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanMetadataForSecrets scans the free-text metadata a request sets: its description,
+// tags and access policies.
+func scanMetadataForSecrets(description string, tags, policies map[string]string) error {
+	if err := scanForSecrets("description", description); err != nil {
+		return err
+	}
+	if err := scanMapForSecrets("tags", tags); err != nil {
+		return err
+	}
+	return scanMapForSecrets("access_policies", policies)
+}
+
+// ValidateBatchCreateKeysRequest scans every key of a batch for secrets in its metadata,
+// the items being otherwise validated one by one as they are created.
+func (rv *RequestValidator) ValidateBatchCreateKeysRequest(req *pk.BatchCreateKeysRequest) error {
+	for i, item := range req.GetKeys() {
+		if err := scanMetadataForSecrets(item.GetDescription(), item.GetTags(), item.GetAccessPolicies()); err != nil {
+			return fmt.Errorf("key %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateBatchUpdateKeyMetadataRequest scans every update of a batch for secrets in the
+// metadata it sets.
+func (rv *RequestValidator) ValidateBatchUpdateKeyMetadataRequest(req *pk.BatchUpdateKeyMetadataRequest) error {
+	for i, item := range req.GetKeys() {
+		if err := scanMetadataForSecrets(item.GetDescription(), item.GetTagsToAdd(), item.GetPoliciesToUpdate()); err != nil {
+			return fmt.Errorf("key %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/spounge-ai/polykey/internal/constants"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 	pkgvalidator "github.com/spounge-ai/polykey/pkg/validator"
 )
@@ -58,7 +59,8 @@ func (rv *RequestValidator) ValidateCreateKeyRequest(ctx context.Context, req *p
 		return fmt.Errorf("request size validation failed: %w", err)
 	}
 
-	if req.GetKeyType() == pk.KeyType_KEY_TYPE_UNSPECIFIED {
+	// A template supplies the key type, so only an untemplated request must name one.
+	if req.GetKeyType() == pk.KeyType_KEY_TYPE_UNSPECIFIED && req.GetGenerationParams()[constants.GenerationParamTemplate] == "" {
 		return fmt.Errorf("key type is required")
 	}
 
@@ -82,6 +84,10 @@ func (rv *RequestValidator) ValidateCreateKeyRequest(ctx context.Context, req *p
 		return fmt.Errorf("data classification validation failed: %w", err)
 	}
 
+	if err := scanMetadataForSecrets(req.GetDescription(), req.GetTags(), req.GetAccessPolicies()); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -118,6 +124,10 @@ func (rv *RequestValidator) ValidateUpdateKeyMetadataRequest(ctx context.Context
 		return fmt.Errorf("data classification validation failed: %w", err)
 	}
 
+	if err := scanMetadataForSecrets(req.GetDescription(), req.GetTagsToAdd(), req.GetPoliciesToUpdate()); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -194,6 +204,10 @@ func (rv *RequestValidator) validateAccessPolicies(policies map[string]string) e
 		if err := json.Unmarshal([]byte(policy), &policyObj); err != nil {
 			return fmt.Errorf("invalid policy JSON for '%s': %w", name, err)
 		}
+
+		if (name == constants.PolicyExportable || name == constants.PolicyPlaintextMaterialAllowed) && policy != "true" && policy != "false" {
+			return fmt.Errorf("policy '%s' must be true or false", name)
+		}
 	}
 
 	return nil
@@ -0,0 +1,197 @@
+package wiring
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+)
+
+// certificateExpiryWarning is how close to its expiry the server certificate in use is
+// logged as expiring on every check that finds no renewal.
+const certificateExpiryWarning = 14 * 24 * time.Hour
+
+// CertificateWatcher checks the source of the TLS server certificate at an interval and
+// switches the server to a renewed certificate once it appears, so that replacing a
+// certificate before it expires needs no restart. The source is cert_file and key_file
+// when they are set, or else the bootstrap secrets. A client_ca_file is checked as well,
+// so that a CA cert-manager renews is trusted without a restart too.
+type CertificateWatcher struct {
+	tls      *ReloadableTLS
+	fetch    func(ctx context.Context) (certPEM, keyPEM []byte, err error)
+	caFile   string
+	interval time.Duration
+	logger   *slog.Logger
+
+	// caPEM is the content of caFile the server uses.
+	caPEM []byte
+
+	mu      sync.Mutex
+	lastErr error
+	started bool
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+var _ lifecycle.ManagedResource = (*CertificateWatcher)(nil)
+
+// NewCertificateWatcher creates a CertificateWatcher that renews the certificate of
+// reloadable from the source cfg configures.
+func NewCertificateWatcher(reloadable *ReloadableTLS, cfg *infra_config.Config, logger *slog.Logger) *CertificateWatcher {
+	fetch := func(ctx context.Context) ([]byte, []byte, error) {
+		certPEM, keyPEM, err := cfg.FetchServerCertificate(ctx)
+		return []byte(certPEM), []byte(keyPEM), err
+	}
+	if reloadable.certFromFiles() {
+		fetch = func(context.Context) ([]byte, []byte, error) {
+			return readCertificateFiles(cfg.Server.TLS)
+		}
+	}
+	w := &CertificateWatcher{
+		tls:      reloadable,
+		fetch:    fetch,
+		interval: cfg.Server.TLS.ReloadInterval,
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if reloadable.caFromFile() {
+		w.caFile = cfg.Server.TLS.ClientCAFile
+		w.caPEM, _ = os.ReadFile(w.caFile)
+	}
+	return w
+}
+
+// Start checks for a renewed certificate in the background until Stop is called or ctx
+// is done.
+func (w *CertificateWatcher) Start(ctx context.Context) error {
+	w.startOnce.Do(func() {
+		w.mu.Lock()
+		w.started = true
+		w.mu.Unlock()
+		go w.run(ctx)
+	})
+	return nil
+}
+
+// Stop ends the checks and waits for one in progress to finish.
+func (w *CertificateWatcher) Stop(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	w.mu.Lock()
+	started := w.started
+	w.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports the expiry of the certificate in use and whether the last check
+// succeeded. The server keeps its current certificate when a check fails, so it stays
+// ready.
+func (w *CertificateWatcher) Health(context.Context) lifecycle.HealthStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: true, Message: "last certificate check failed: " + w.lastErr.Error()}
+	}
+	if cert := w.tls.Certificate(); cert != nil && cert.Leaf != nil {
+		return lifecycle.HealthStatus{Ready: true, Message: "server certificate expires " + cert.Leaf.NotAfter.Format(time.RFC3339)}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: "certificate watcher is running"}
+}
+
+func (w *CertificateWatcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			_ = w.CheckOnce(ctx)
+		}
+	}
+}
+
+// CheckOnce reads the certificate source and switches to its certificate if it differs
+// from the one in use, and likewise for the client CA file. A certificate that does not
+// match its key or has expired is rejected.
+func (w *CertificateWatcher) CheckOnce(ctx context.Context) error {
+	err := errors.Join(w.check(ctx), w.checkClientCA(ctx))
+	w.mu.Lock()
+	w.lastErr = err
+	w.mu.Unlock()
+	if err != nil {
+		w.logger.ErrorContext(ctx, "failed to check for a renewed TLS server certificate, keeping the current one", "error", err)
+	}
+	return err
+}
+
+func (w *CertificateWatcher) check(ctx context.Context) error {
+	certPEM, keyPEM, err := w.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load server TLS key pair: %w", err)
+	}
+
+	current := w.tls.Certificate()
+	if current != nil && bytes.Equal(current.Certificate[0], cert.Certificate[0]) {
+		if current.Leaf != nil && time.Until(current.Leaf.NotAfter) < certificateExpiryWarning {
+			w.logger.WarnContext(ctx, "TLS server certificate expires soon and no renewal was found", "not_after", current.Leaf.NotAfter)
+		}
+		return nil
+	}
+	if time.Now().After(cert.Leaf.NotAfter) {
+		return errors.New("the new server certificate has expired")
+	}
+
+	w.tls.SetCertificate(&cert)
+	w.logger.InfoContext(ctx, "loaded renewed TLS server certificate", "serial", cert.Leaf.SerialNumber.String(), "not_after", cert.Leaf.NotAfter)
+	return nil
+}
+
+func (w *CertificateWatcher) checkClientCA(ctx context.Context) error {
+	if w.caFile == "" {
+		return nil
+	}
+	caPEM, err := os.ReadFile(w.caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TLS client_ca_file: %w", err)
+	}
+	if bytes.Equal(caPEM, w.caPEM) {
+		return nil
+	}
+	if err := w.tls.ReloadClientCA(); err != nil {
+		return err
+	}
+	w.caPEM = caPEM
+	w.logger.InfoContext(ctx, "loaded renewed TLS client CA", "file", w.caFile)
+	return nil
+}
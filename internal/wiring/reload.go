@@ -0,0 +1,136 @@
+package wiring
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	infra_audit "github.com/spounge-ai/polykey/internal/infra/audit"
+	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/infra/logging"
+	"github.com/spounge-ai/polykey/internal/infra/persistence"
+	"github.com/spounge-ai/polykey/internal/infra/ratelimit"
+	"golang.org/x/time/rate"
+)
+
+// configReloadIdentity is the client identity of the audit entries of config reloads.
+const configReloadIdentity = "system:config-reload"
+
+// restartOnlySettings are reloadable sections' settings that size or create components
+// at startup, so a change only takes effect on restart.
+var restartOnlySettings = []string{
+	"server.rate_limiter.backend",
+	"persistence.circuit_breaker.enabled",
+	"auditing.asynchronous.enabled",
+	"auditing.asynchronous.channel_buffer_size",
+	"auditing.asynchronous.worker_count",
+	"auditing.asynchronous.spill.",
+}
+
+// ConfigReloader applies reloaded settings to the running rate limiter, key and audit
+// repository circuit breakers, asynchronous audit logger and log levels, and audits the
+// changes it applied. Changes it cannot apply are logged as waiting for a restart.
+type ConfigReloader struct {
+	limiter      ratelimit.Limiter
+	breaker      *persistence.KeyRepositoryCircuitBreaker
+	auditBreaker *persistence.AuditRepositoryCircuitBreaker
+	asyncAudit   *infra_audit.AsyncAuditLogger
+	levels       *logging.Levels
+	audit        domain.AuditLogger
+	logger       *slog.Logger
+}
+
+// ConfigReloader returns a ConfigReloader for the container's components and the given
+// rate limiter and log levels, either of which may be nil. Dependencies must have been
+// initialized.
+func (c *Container) ConfigReloader(limiter ratelimit.Limiter, levels *logging.Levels) *ConfigReloader {
+	return &ConfigReloader{
+		limiter:      limiter,
+		breaker:      c.keyBreaker,
+		auditBreaker: c.auditBreaker,
+		asyncAudit:   c.asyncAudit,
+		levels:       levels,
+		audit:        c.auditLogger,
+		logger:       c.moduleLogger("config"),
+	}
+}
+
+// Apply applies changes, which take old to next; it has the signature
+// infra_config.NewWatcher expects.
+func (r *ConfigReloader) Apply(ctx context.Context, old, next *infra_config.Config, changes []infra_config.Change) {
+	var applied []domain.AuditChange
+	var pending []string
+	for _, change := range changes {
+		if !r.canApply(change.Key) {
+			pending = append(pending, change.Key)
+			continue
+		}
+		applied = append(applied, domain.AuditChange{Field: change.Key, Old: change.Old, New: change.New})
+	}
+
+	if changed(applied, "server.rate_limiter.") {
+		limits := next.Server.RateLimiter
+		r.limiter.Configure(limits.Enabled, rate.Limit(limits.Rate), limits.Burst)
+	}
+	if changed(applied, "persistence.circuit_breaker.") {
+		limits := next.Persistence.CircuitBreaker
+		r.breaker.SetLimits(limits.MaxFailures, limits.ResetTimeout)
+		if r.auditBreaker != nil {
+			r.auditBreaker.Breaker().SetLimits(limits.MaxFailures, limits.ResetTimeout)
+		}
+	}
+	if changed(applied, "auditing.asynchronous.") {
+		r.asyncAudit.SetBatching(next.Auditing.Asynchronous.BatchSize, next.Auditing.Asynchronous.BatchTimeout)
+	}
+	if changed(applied, "logging.") {
+		if err := r.levels.Reconfigure(next.Logging.Level, next.Logging.ModuleLevels()); err != nil {
+			r.logger.ErrorContext(ctx, "failed to apply reloaded log levels", "error", err)
+			applied = slices.DeleteFunc(applied, func(change domain.AuditChange) bool {
+				return strings.HasPrefix(change.Field, "logging.")
+			})
+		}
+	}
+
+	if len(pending) > 0 {
+		r.logger.WarnContext(ctx, "config changes take effect only after a restart", "settings", strings.Join(pending, ","))
+	}
+	if len(applied) == 0 {
+		return
+	}
+	r.logger.InfoContext(ctx, "applied reloaded config", "changes", len(applied))
+	if r.audit != nil {
+		r.audit.AuditLog(domain.NewContextWithAuditChanges(ctx, applied), configReloadIdentity, "ReloadConfig", "", "", true, nil)
+	}
+}
+
+// canApply reports whether a change of key can take effect without a restart.
+func (r *ConfigReloader) canApply(key string) bool {
+	for _, setting := range restartOnlySettings {
+		if key == setting || (strings.HasSuffix(setting, ".") && strings.HasPrefix(key, setting)) {
+			return false
+		}
+	}
+	switch {
+	case strings.HasPrefix(key, "server.rate_limiter."):
+		return r.limiter != nil
+	case strings.HasPrefix(key, "persistence.circuit_breaker."):
+		return r.breaker != nil
+	case strings.HasPrefix(key, "auditing.asynchronous."):
+		return r.asyncAudit != nil
+	case strings.HasPrefix(key, "logging."):
+		return r.levels != nil
+	default:
+		return false
+	}
+}
+
+func changed(changes []domain.AuditChange, prefix string) bool {
+	for _, change := range changes {
+		if strings.HasPrefix(change.Field, prefix) {
+			return true
+		}
+	}
+	return false
+}
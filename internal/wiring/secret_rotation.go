@@ -0,0 +1,219 @@
+package wiring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	infra_auth "github.com/spounge-ai/polykey/internal/infra/auth"
+	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/infra/persistence"
+	"github.com/spounge-ai/polykey/pkg/patterns/lifecycle"
+)
+
+// secretRotationIdentity is the client identity of the audit entries of scheduled
+// secret rotations.
+const secretRotationIdentity = "system:secret-rotation"
+
+// Secret paths of the rotatable bootstrap secrets, as RotateSecrets reports them.
+const (
+	neonDBURLSecret     = "polykey/db/neondb_url"
+	jwtPrivateKeySecret = "polykey/jwt/jwt_rsa_private_key"
+	tlsCertSecret       = "polykey/tls/server-cert.pem"
+	tlsKeySecret        = "polykey/tls/server-key.pem"
+	tlsCASecret         = "tls/ca.pem"
+)
+
+// SecretRotator reads the rotatable bootstrap secrets from the bootstrap secret provider
+// again, at an interval while it runs or on demand, and applies those that changed: a
+// new database URL replaces the pool's connections, a new JWT private key signs the
+// tokens issued from then on, and new TLS materials serve the handshakes that follow. A
+// secret that fails to apply stays at its current value and is tried again on the next
+// rotation.
+type SecretRotator struct {
+	cfg      *infra_config.Config
+	db       *persistence.ConnectionRotator
+	tokens   *infra_auth.TokenManager
+	tls      *ReloadableTLS
+	audit    domain.AuditLogger
+	logger   *slog.Logger
+	interval time.Duration
+
+	mu      sync.Mutex // Serializes rotations
+	current infra_config.BootstrapSecrets
+	lastErr error
+	started bool
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+var (
+	_ lifecycle.ManagedResource = (*SecretRotator)(nil)
+	_ domain.SecretRotator      = (*SecretRotator)(nil)
+)
+
+// SecretRotator returns a SecretRotator for the container's database pool and token
+// manager and the given TLS, which is nil when TLS is disabled. Dependencies must have
+// been initialized.
+func (c *Container) SecretRotator(tls *ReloadableTLS) *SecretRotator {
+	return &SecretRotator{
+		cfg:      c.config,
+		db:       c.dbRotator,
+		tokens:   c.tokenManager,
+		tls:      tls,
+		audit:    c.auditLogger,
+		logger:   c.moduleLogger("secrets"),
+		interval: c.config.SecretRotation.Interval,
+		current:  c.config.BootstrapSecrets,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start rotates at the configured interval in the background until Stop is called or
+// ctx is done.
+func (r *SecretRotator) Start(ctx context.Context) error {
+	r.startOnce.Do(func() {
+		r.mu.Lock()
+		r.started = true
+		r.mu.Unlock()
+		go r.run(ctx)
+	})
+	return nil
+}
+
+// Stop ends the scheduled rotations and waits for one in progress to finish.
+func (r *SecretRotator) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	r.mu.Lock()
+	started := r.started
+	r.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports whether the last rotation succeeded. A failed rotation leaves the
+// service running on its current secrets, so it stays ready.
+func (r *SecretRotator) Health(context.Context) lifecycle.HealthStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lastErr != nil {
+		return lifecycle.HealthStatus{Ready: true, Message: "last secret rotation failed: " + r.lastErr.Error()}
+	}
+	return lifecycle.HealthStatus{Ready: true, Message: "secret rotation is scheduled"}
+}
+
+func (r *SecretRotator) run(ctx context.Context) {
+	defer close(r.done)
+
+	interval := r.interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			_, _ = r.RotateSecrets(ctx)
+		}
+	}
+}
+
+// RotateSecrets reads the rotatable secrets and applies those that changed. The secrets
+// that changed are audited, with the identity of the user in ctx or, for scheduled
+// rotations, secretRotationIdentity.
+func (r *SecretRotator) RotateSecrets(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fetched, err := r.cfg.FetchRotatableSecrets(ctx)
+	if err != nil {
+		r.lastErr = err
+		r.logger.ErrorContext(ctx, "failed to read bootstrap secrets for rotation", "error", err)
+		return nil, err
+	}
+
+	var rotated, failed []string
+	var errs []error
+	if r.db != nil && fetched.NeonDBURL != r.current.NeonDBURL {
+		if err := r.db.Rotate(ctx, fetched.NeonDBURL); err != nil {
+			failed, errs = append(failed, neonDBURLSecret), append(errs, fmt.Errorf("%s: %w", neonDBURLSecret, err))
+		} else {
+			r.current.NeonDBURL = fetched.NeonDBURL
+			rotated = append(rotated, neonDBURLSecret)
+		}
+	}
+	if r.tokens != nil && fetched.JWTRSAPrivateKey != r.current.JWTRSAPrivateKey {
+		if err := r.tokens.RotateKey(fetched.JWTRSAPrivateKey); err != nil {
+			failed, errs = append(failed, jwtPrivateKeySecret), append(errs, fmt.Errorf("%s: %w", jwtPrivateKeySecret, err))
+		} else {
+			r.current.JWTRSAPrivateKey = fetched.JWTRSAPrivateKey
+			rotated = append(rotated, jwtPrivateKeySecret)
+		}
+	}
+	if r.tls != nil {
+		var changed []string
+		if fetched.TLSServerCert != r.current.TLSServerCert {
+			changed = append(changed, tlsCertSecret)
+		}
+		if fetched.TLSServerKey != r.current.TLSServerKey {
+			changed = append(changed, tlsKeySecret)
+		}
+		if fetched.SpoungeCA != r.current.SpoungeCA {
+			changed = append(changed, tlsCASecret)
+		}
+		if len(changed) > 0 {
+			next := r.current
+			next.TLSServerCert, next.TLSServerKey, next.SpoungeCA = fetched.TLSServerCert, fetched.TLSServerKey, fetched.SpoungeCA
+			if err := r.tls.Update(next); err != nil {
+				failed, errs = append(failed, changed...), append(errs, fmt.Errorf("TLS: %w", err))
+			} else {
+				r.current = next
+				rotated = append(rotated, changed...)
+			}
+		}
+	}
+
+	err = errors.Join(errs...)
+	r.lastErr = err
+	if len(rotated) > 0 {
+		r.logger.InfoContext(ctx, "rotated bootstrap secrets", "secrets", rotated)
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "failed to rotate bootstrap secrets, keeping the current ones", "secrets", failed, "error", err)
+	}
+	if len(rotated)+len(failed) > 0 && r.audit != nil {
+		changes := make([]domain.AuditChange, 0, len(rotated)+len(failed))
+		for _, secret := range append(rotated, failed...) {
+			changes = append(changes, domain.AuditChange{Field: "bootstrap_secrets." + secret})
+		}
+		identity := secretRotationIdentity
+		if user, ok := domain.UserFromContext(ctx); ok {
+			identity = user.ID
+		}
+		r.audit.AuditLog(domain.NewContextWithAuditChanges(ctx, changes), identity, "RotateBootstrapSecrets", "", "", err == nil, err)
+	}
+	return rotated, err
+}
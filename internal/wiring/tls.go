@@ -33,35 +33,57 @@ func ConfigureTLS(cfg config.TLS, bootstrapSecrets config.BootstrapSecrets) (*tl
 
 	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{serverCert},
-		MinVersion:   tls.VersionTLS12,
+	}
+	if err := applyTLSPolicy(tlsConfig, cfg); err != nil {
+		return nil, err
+	}
+	if err := configureClientAuth(tlsConfig, cfg, bootstrapSecrets); err != nil {
+		return nil, err
 	}
 
-	// Use CA from bootstrap secrets
-	if bootstrapSecrets.SpoungeCA != "" {
-		caCert := []byte(bootstrapSecrets.SpoungeCA)
+	return tlsConfig, nil
+}
+
+// configureClientAuth sets the client CA, from client_ca_file or else the bootstrap
+// secrets, and the client auth policy of tlsConfig.
+func configureClientAuth(tlsConfig *tls.Config, cfg config.TLS, bootstrapSecrets config.BootstrapSecrets) error {
+	caCert := []byte(bootstrapSecrets.SpoungeCA)
+	if cfg.ClientCAFile != "" {
+		var err error
+		if caCert, err = os.ReadFile(cfg.ClientCAFile); err != nil {
+			return fmt.Errorf("failed to read TLS client_ca_file: %w", err)
+		}
+	}
+	if len(caCert) > 0 {
 		caCertPool := x509.NewCertPool()
 		if !caCertPool.AppendCertsFromPEM(caCert) {
-			return nil, fmt.Errorf("failed to add client CA certificate")
+			return fmt.Errorf("failed to add client CA certificate")
 		}
 		tlsConfig.ClientCAs = caCertPool
 	}
 
-	switch cfg.ClientAuth {
-	case "RequestClientCert":
-		tlsConfig.ClientAuth = tls.RequestClientCert
-	case "RequireAnyClientCert":
-		tlsConfig.ClientAuth = tls.RequireAnyClientCert
-	case "VerifyClientCertIfGiven":
-		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
-	case "RequireAndVerifyClientCert":
-		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
-	case "NoClientCert", "":
-		tlsConfig.ClientAuth = tls.NoClientCert
-	default:
-		return nil, fmt.Errorf("unsupported client_auth type: %s", cfg.ClientAuth)
+	clientAuth, err := config.ParseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return err
 	}
+	tlsConfig.ClientAuth = clientAuth
+	return nil
+}
 
-	return tlsConfig, nil
+// applyTLSPolicy sets the minimum protocol version and the TLS 1.2 cipher suites of
+// tlsConfig from cfg.
+func applyTLSPolicy(tlsConfig *tls.Config, cfg config.TLS) error {
+	minVersion, err := cfg.TLSMinVersion()
+	if err != nil {
+		return err
+	}
+	cipherSuites, err := cfg.TLSCipherSuites()
+	if err != nil {
+		return err
+	}
+	tlsConfig.MinVersion = minVersion
+	tlsConfig.CipherSuites = cipherSuites
+	return nil
 }
 
 // ConfigureClientTLS creates a new tls.Config for a gRPC client.
@@ -77,12 +99,17 @@ func ConfigureClientTLS(path string) (*tls.Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal client TLS config: %w", err)
 	}
 
-	clientCert, err := tls.LoadX509KeyPair(tlsFileCfg.CertFile, tlsFileCfg.KeyFile)
+	return tlsFileCfg.TLSConfig()
+}
+
+// TLSConfig creates the tls.Config for a gRPC client from c.
+func (c ClientTLSConfig) TLSConfig() (*tls.Config, error) {
+	clientCert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load client key pair: %w", err)
 	}
 
-	caCert, err := os.ReadFile(tlsFileCfg.CAFile)
+	caCert, err := os.ReadFile(c.CAFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read server CA file: %w", err)
 	}
@@ -97,4 +124,24 @@ func ConfigureClientTLS(path string) (*tls.Config, error) {
 		RootCAs:      caCertPool,
 		MinVersion:   tls.VersionTLS12,
 	}, nil
-}
\ No newline at end of file
+}
+
+// configureSyslogTLS creates the tls.Config for a syslog collector, trusting the system
+// roots unless a CA file is given.
+func configureSyslogTLS(caFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return tlsConfig, nil
+	}
+
+	caCert, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read syslog CA file: %w", err)
+	}
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("failed to add syslog CA certificate")
+	}
+	tlsConfig.RootCAs = caCertPool
+	return tlsConfig, nil
+}
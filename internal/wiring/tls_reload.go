@@ -0,0 +1,181 @@
+package wiring
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync/atomic"
+
+	"github.com/spounge-ai/polykey/internal/infra/config"
+)
+
+// ReloadableTLS serves TLS with the server certificate and client CA it was last given,
+// so that they can rotate while the server runs.
+type ReloadableTLS struct {
+	cfg     config.TLS
+	cert    atomic.Pointer[tls.Certificate]
+	current atomic.Pointer[tls.Config]
+}
+
+// NewReloadableTLS configures TLS from the bootstrap secrets as ConfigureTLS does, with
+// the server certificate read from cert_file and key_file instead when they are set. It
+// returns nil when TLS is disabled.
+func NewReloadableTLS(cfg config.TLS, bootstrapSecrets config.BootstrapSecrets) (*ReloadableTLS, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	r := &ReloadableTLS{cfg: cfg}
+	if r.certFromFiles() {
+		certPEM, keyPEM, err := readCertificateFiles(cfg)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load server TLS key pair: %w", err)
+		}
+		r.cert.Store(&cert)
+	}
+	if err := r.Update(bootstrapSecrets); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Update switches the handshakes that follow to the client CA and server certificate in
+// bootstrapSecrets, or those of the TLS files where they are set. If they are invalid,
+// the current ones stay in use.
+func (r *ReloadableTLS) Update(bootstrapSecrets config.BootstrapSecrets) error {
+	var cert *tls.Certificate
+	if !r.certFromFiles() {
+		serverCert, err := tls.X509KeyPair([]byte(bootstrapSecrets.TLSServerCert), []byte(bootstrapSecrets.TLSServerKey))
+		if err != nil {
+			return fmt.Errorf("failed to load server TLS key pair: %w", err)
+		}
+		cert = &serverCert
+	}
+
+	// The config replaces the server's for the whole handshake, so it must offer HTTP/2
+	// itself for gRPC clients to negotiate it.
+	tlsConfig := &tls.Config{
+		NextProtos:     []string{"h2"},
+		GetCertificate: r.getCertificate,
+	}
+	if err := applyTLSPolicy(tlsConfig, r.cfg); err != nil {
+		return err
+	}
+	if err := configureClientAuth(tlsConfig, r.cfg, bootstrapSecrets); err != nil {
+		return err
+	}
+	if cert != nil {
+		r.cert.Store(cert)
+	}
+	r.current.Store(tlsConfig)
+	return nil
+}
+
+// ReloadClientCA reads client_ca_file again and switches the handshakes that follow to
+// its CA. If it is invalid, the current CA stays in use.
+func (r *ReloadableTLS) ReloadClientCA() error {
+	tlsConfig := r.current.Load().Clone()
+	if err := configureClientAuth(tlsConfig, r.cfg, config.BootstrapSecrets{}); err != nil {
+		return err
+	}
+	r.current.Store(tlsConfig)
+	return nil
+}
+
+// SetCertificate switches the handshakes that follow to cert.
+func (r *ReloadableTLS) SetCertificate(cert *tls.Certificate) {
+	r.cert.Store(cert)
+}
+
+// Certificate returns the server certificate in use.
+func (r *ReloadableTLS) Certificate() *tls.Certificate {
+	return r.cert.Load()
+}
+
+// ServerConfig returns the tls.Config for the server, which hands every handshake the
+// current config.
+func (r *ReloadableTLS) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.current.Load().MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// AdminServerConfig returns the tls.Config for the admin server. It serves the current
+// certificate like ServerConfig, but with the client auth mode of admin instead of
+// server.tls.client_auth, always requiring a client certificate signed by the CA, and
+// when admin.AllowedIdentities is set, one whose common name or a DNS name is in it.
+func (r *ReloadableTLS) AdminServerConfig(admin config.AdminServerConfig) (*tls.Config, error) {
+	mode := admin.ClientAuth
+	if mode == "" {
+		mode = config.DefaultAdminClientAuth
+	}
+	clientAuth, err := config.ParseClientAuth(mode)
+	if err != nil {
+		return nil, fmt.Errorf("admin listener: %w", err)
+	}
+	if clientAuth != tls.RequireAndVerifyClientCert {
+		return nil, fmt.Errorf("admin listener: client_auth %s does not require a verified client certificate", mode)
+	}
+	allowedIdentities := admin.AllowedIdentities
+	return &tls.Config{
+		MinVersion: r.current.Load().MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			tlsConfig := r.current.Load().Clone()
+			tlsConfig.ClientAuth = clientAuth
+			if len(allowedIdentities) > 0 {
+				tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+					return checkAllowedIdentity(state, allowedIdentities)
+				}
+			}
+			return tlsConfig, nil
+		},
+	}, nil
+}
+
+// checkAllowedIdentity accepts a connection whose verified client certificate names one
+// of allowedIdentities.
+func checkAllowedIdentity(state tls.ConnectionState, allowedIdentities []string) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("admin listener requires a client certificate")
+	}
+	leaf := state.PeerCertificates[0]
+	if slices.Contains(allowedIdentities, leaf.Subject.CommonName) {
+		return nil
+	}
+	for _, name := range leaf.DNSNames {
+		if slices.Contains(allowedIdentities, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("client certificate %q is not allowed on the admin listener", leaf.Subject.CommonName)
+}
+
+func (r *ReloadableTLS) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+func (r *ReloadableTLS) certFromFiles() bool {
+	return r.cfg.CertFile != ""
+}
+
+func (r *ReloadableTLS) caFromFile() bool {
+	return r.cfg.ClientCAFile != ""
+}
+
+func readCertificateFiles(cfg config.TLS) (certPEM, keyPEM []byte, err error) {
+	if certPEM, err = os.ReadFile(cfg.CertFile); err != nil {
+		return nil, nil, fmt.Errorf("failed to read TLS cert_file: %w", err)
+	}
+	if keyPEM, err = os.ReadFile(cfg.KeyFile); err != nil {
+		return nil, nil, fmt.Errorf("failed to read TLS key_file: %w", err)
+	}
+	return certPEM, keyPEM, nil
+}
@@ -2,50 +2,94 @@ package wiring
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
 	"github.com/spounge-ai/polykey/internal/domain"
 	app_errors "github.com/spounge-ai/polykey/internal/errors"
 	infra_audit "github.com/spounge-ai/polykey/internal/infra/audit"
 	infra_auth "github.com/spounge-ai/polykey/internal/infra/auth"
 	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	infra_events "github.com/spounge-ai/polykey/internal/infra/events"
+	infra_health "github.com/spounge-ai/polykey/internal/infra/health"
+	"github.com/spounge-ai/polykey/internal/infra/logging"
 	"github.com/spounge-ai/polykey/internal/infra/persistence"
+	"github.com/spounge-ai/polykey/internal/infra/ratelimit"
+	"github.com/spounge-ai/polykey/internal/infra/telemetry"
+	"github.com/spounge-ai/polykey/internal/infra/usage"
+	"github.com/spounge-ai/polykey/internal/infra/webhook"
+	"github.com/spounge-ai/polykey/internal/jobs"
 	"github.com/spounge-ai/polykey/internal/kms"
 	"github.com/spounge-ai/polykey/internal/service"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"github.com/spounge-ai/polykey/pkg/execution"
+	"github.com/spounge-ai/polykey/pkg/patterns/chaos"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/time/rate"
 )
 
 type Container struct {
 	config       *infra_config.Config
 	logger       *slog.Logger
+	clock        clock.Clock
 	pgxPool      *pgxpool.Pool
-	pgxPoolOnce  sync.Once
+	pgxPoolMu    sync.Mutex
+	dbRotator    *persistence.ConnectionRotator
 	kmsProviders map[string]kms.KMSProvider
+	integrity    *domain.MetadataIntegrity
 	keyRepo      domain.KeyRepository
+	keyCache     *persistence.CachedRepository
+	keyBreaker   *persistence.KeyRepositoryCircuitBreaker
+	auditBreaker *persistence.AuditRepositoryCircuitBreaker
+	kmsBreakers  []*kms.CircuitBreakerProvider
+	keyEvents    *infra_events.Broker
 	auditRepo    domain.AuditRepository
 	clientStore  domain.ClientStore
 	tokenManager *infra_auth.TokenManager
 	tokenStore   infra_auth.TokenStore
 	auditLogger  domain.AuditLogger
+	asyncAudit   *infra_audit.AsyncAuditLogger
+	auditSinks   []domain.AuditSink
 	authorizer   domain.Authorizer
 	keyService   service.KeyService
 	authService  service.AuthService
+	auditService service.AuditService
+	kmsRewrap    service.KMSRewrapService
+	health       *infra_health.Checker
+	expiration   *jobs.KeyExpirationJob
+	accessStats  *usage.AccessRecorder
+	archives     domain.AuditArchiveStore
+	retention    *jobs.AuditRetentionJob
+	versions     *jobs.KeyVersionRetentionJob
+	rotations    *jobs.KeyRotationScheduleJob
+	replication  *jobs.KeyReplicationJob
+	standbyPool  *pgxpool.Pool
+	eventRelay   *jobs.KeyEventRelayJob
+	natsSink     *infra_events.NATSSink
+	leader       *persistence.AdvisoryLockElector
+	rateLimiter  ratelimit.Limiter
+	tracing      *sdktrace.TracerProvider
 }
 
 func NewContainer(cfg *infra_config.Config, logger *slog.Logger) *Container {
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &Container{config: cfg, logger: logger}
+	return &Container{config: cfg, logger: logger, clock: clock.System()}
 }
 
 type Dependencies struct {
 	KMSProviders map[string]kms.KMSProvider
 	KeyRepo      domain.KeyRepository
+	KeyEvents    domain.KeyEventSubscriber
 	AuditRepo    domain.AuditRepository
 	AuditLogger  domain.AuditLogger
 	ClientStore  domain.ClientStore
@@ -53,29 +97,167 @@ type Dependencies struct {
 	Authorizer   domain.Authorizer
 	KeyService   service.KeyService
 	AuthService  service.AuthService
+	AuditService service.AuditService
+	Health       *infra_health.Checker
+	// ExpirationJob is nil when key expiration is disabled.
+	ExpirationJob *jobs.KeyExpirationJob
+	// RetentionJob is nil when audit archiving is disabled.
+	RetentionJob *jobs.AuditRetentionJob
+	// VersionRetentionJob is nil when key version retention is disabled.
+	VersionRetentionJob *jobs.KeyVersionRetentionJob
+	// RotationScheduleJob is nil when scheduled key rotation is disabled.
+	RotationScheduleJob *jobs.KeyRotationScheduleJob
+	// ReplicationJob is nil when replication to a standby is disabled.
+	ReplicationJob *jobs.KeyReplicationJob
+	// EventRelayJob is nil when publishing key events to NATS is disabled.
+	EventRelayJob *jobs.KeyEventRelayJob
+	// LeaderElector is nil when leader election is disabled. It must be started before
+	// the jobs, which only sweep while it leads.
+	LeaderElector *persistence.AdvisoryLockElector
+	// CircuitBreakers are the breakers of the key repository, the audit repository and
+	// the KMS providers that are enabled.
+	CircuitBreakers []domain.CircuitBreakerControl
+	// ClientManager and RoleManager are nil when the client store or authorizer cannot
+	// be changed at runtime.
+	ClientManager domain.ClientManager
+	RoleManager   domain.RoleManager
+	// Sessions lists and revokes the tokens issued to clients, and is nil when the token
+	// store does not track them.
+	Sessions domain.TokenSessionStore
+	// Caches are the caches operators can flush, by name.
+	Caches map[string]domain.CacheFlusher
+	// KMSRewrap moves wrapped DEKs from one KMS provider to another.
+	KMSRewrap service.KMSRewrapService
+	// RateLimiter limits authenticated requests per client, in this replica or, with the
+	// redis backend, across all of them.
+	RateLimiter ratelimit.Limiter
 }
 
+// moduleLogger returns the logger of one module, whose level can be changed on its own.
+func (c *Container) moduleLogger(module string) *slog.Logger {
+	return c.logger.With(logging.ModuleKey, module)
+}
+
+// GetDependencies initializes every dependency and returns them. While a dependency
+// cannot be reached, it tries again with backoff for up to startup.timeout.
 func (c *Container) GetDependencies(ctx context.Context) (*Dependencies, error) {
-	if err := c.initializeAll(ctx); err != nil {
+	if err := c.initializeWaiting(ctx); err != nil {
 		return nil, fmt.Errorf("failed to initialize dependencies: %w", err)
 	}
-	return &Dependencies{
-		KMSProviders: c.kmsProviders,
-		KeyRepo:      c.keyRepo,
-		AuditRepo:    c.auditRepo,
-		AuditLogger:  c.auditLogger,
-		ClientStore:  c.clientStore,
-		TokenManager: c.tokenManager,
-		Authorizer:   c.authorizer,
-		KeyService:   c.keyService,
-		AuthService:  c.authService,
-	}, nil
+	deps := &Dependencies{
+		KMSProviders:  c.kmsProviders,
+		KeyRepo:       c.keyRepo,
+		KeyEvents:     c.keyEvents,
+		AuditRepo:     c.auditRepo,
+		AuditLogger:   c.auditLogger,
+		ClientStore:   c.clientStore,
+		TokenManager:  c.tokenManager,
+		Authorizer:    c.authorizer,
+		KeyService:    c.keyService,
+		AuthService:   c.authService,
+		AuditService:  c.auditService,
+		Health:        c.health,
+		ExpirationJob: c.expiration,
+		RetentionJob:  c.retention,
+		KMSRewrap:     c.kmsRewrap,
+		LeaderElector: c.leader,
+		RateLimiter:   c.rateLimiter,
+
+		VersionRetentionJob: c.versions,
+		RotationScheduleJob: c.rotations,
+		ReplicationJob:      c.replication,
+		EventRelayJob:       c.eventRelay,
+	}
+	if c.keyBreaker != nil {
+		for _, b := range c.keyBreaker.Breakers() {
+			deps.CircuitBreakers = append(deps.CircuitBreakers, b)
+		}
+	}
+	if c.auditBreaker != nil {
+		deps.CircuitBreakers = append(deps.CircuitBreakers, c.auditBreaker.Breaker())
+	}
+	for _, provider := range c.kmsBreakers {
+		deps.CircuitBreakers = append(deps.CircuitBreakers, provider.Breaker())
+	}
+	if manager, ok := c.clientStore.(domain.ClientManager); ok {
+		deps.ClientManager = manager
+	}
+	if c.tokenManager != nil {
+		deps.Sessions = c.tokenManager.Sessions()
+	}
+	if manager, ok := c.authorizer.(domain.RoleManager); ok {
+		deps.RoleManager = manager
+	}
+	deps.Caches = c.caches()
+	return deps, nil
+}
+
+// caches returns the caches of the dependencies that have one, keyed by the name
+// FlushCaches knows them by.
+func (c *Container) caches() map[string]domain.CacheFlusher {
+	caches := make(map[string]domain.CacheFlusher)
+	if c.keyCache != nil {
+		caches["keys"] = c.keyCache
+	}
+	if flusher, ok := c.authorizer.(domain.CacheFlusher); ok {
+		caches["authorization"] = flusher
+	}
+	return caches
+}
+
+// initializeWaiting runs initializeAll until it succeeds or fails with an error other
+// than an unreachable dependency, for up to startup.timeout. Each attempt skips what the
+// attempts before it initialized.
+func (c *Container) initializeWaiting(ctx context.Context) error {
+	startup := c.config.Startup
+	attempts := 1
+	if startup.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, startup.Timeout)
+		defer cancel()
+		attempts = math.MaxInt
+	}
+	_, err := execution.Retry(ctx, execution.RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: startup.InitialBackoff,
+		MaxBackoff:     startup.MaxBackoff,
+		Retryable:      isUnavailable,
+		OnRetry: func(attempt int, err error) {
+			c.logger.Warn("dependency unavailable, retrying", "attempt", attempt, "error", err)
+		},
+	}, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, c.initializeAll(ctx)
+	})
+	return err
+}
+
+// unavailableError marks a failure to reach a dependency, which may go away on its own.
+type unavailableError struct {
+	err error
+}
+
+func (e unavailableError) Error() string { return e.err.Error() }
+func (e unavailableError) Unwrap() error { return e.err }
+
+// unavailable marks err, if any, as a failure to reach a dependency.
+func unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return unavailableError{err: err}
+}
+
+func isUnavailable(err error) bool {
+	var target unavailableError
+	return errors.As(err, &target)
 }
 
 func (c *Container) initializeAll(ctx context.Context) error {
 	initializers := []func(context.Context) error{
+		c.initTracing,
 		c.initPgxPool,
 		c.initKMSProviders,
+		c.initMetadataIntegrity,
 		func(context.Context) error { return c.initTokenStore() },
 		func(context.Context) error { return c.initKeyRepository() },
 		func(context.Context) error { return c.initAuditRepository() },
@@ -83,8 +265,21 @@ func (c *Container) initializeAll(ctx context.Context) error {
 		func(context.Context) error { return c.initClientStore() },
 		func(context.Context) error { return c.initTokenManager() },
 		func(context.Context) error { return c.initAuthorizer() },
+		func(context.Context) error { return c.initAccessRecorder() },
 		func(context.Context) error { return c.initKeyService() },
+		func(context.Context) error { return c.initKMSRewrapService() },
 		func(context.Context) error { return c.initAuthService() },
+		c.initAuditArchiveStore,
+		func(context.Context) error { return c.initAuditService() },
+		func(context.Context) error { return c.initRateLimiter() },
+		func(context.Context) error { return c.initHealthChecker() },
+		func(context.Context) error { return c.initLeaderElector() },
+		func(context.Context) error { return c.initExpirationJob() },
+		func(context.Context) error { return c.initRetentionJob() },
+		func(context.Context) error { return c.initVersionRetentionJob() },
+		func(context.Context) error { return c.initRotationScheduleJob() },
+		func(context.Context) error { return c.initReplicationJob() },
+		c.initEventRelayJob,
 	}
 	for _, initFn := range initializers {
 		if err := initFn(ctx); err != nil {
@@ -94,6 +289,22 @@ func (c *Container) initializeAll(ctx context.Context) error {
 	return nil
 }
 
+// initTracing starts exporting spans when tracing is enabled. It runs first so that the
+// tracers of everything initialized after it record to the configured provider.
+func (c *Container) initTracing(ctx context.Context) error {
+	if c.tracing != nil || !c.config.Telemetry.Tracing.Enabled {
+		return nil
+	}
+	provider, err := telemetry.NewTracerProvider(ctx, c.config.Telemetry, c.config.ServiceVersion)
+	if err != nil {
+		return err
+	}
+	c.tracing = provider
+	c.logger.Debug("initialized OTLP tracing", "endpoint", c.config.Telemetry.Tracing.Endpoint,
+		"sampleRatio", c.config.Telemetry.Tracing.SampleRatio)
+	return nil
+}
+
 func (c *Container) initAuthorizer() error {
 	if c.authorizer != nil {
 		return nil
@@ -116,6 +327,9 @@ func (c *Container) initAuditLogger() error {
 	if c.auditRepo == nil {
 		return fmt.Errorf("audit repository not initialized")
 	}
+	if err := c.initAuditSinks(); err != nil {
+		return err
+	}
 
 	if c.config.Auditing.Asynchronous.Enabled {
 		asyncConfig := infra_audit.AsyncAuditLoggerConfig{
@@ -124,15 +338,130 @@ func (c *Container) initAuditLogger() error {
 			BatchSize:         c.config.Auditing.Asynchronous.BatchSize,
 			BatchTimeout:      c.config.Auditing.Asynchronous.BatchTimeout,
 		}
-		asyncLogger := infra_audit.NewAsyncAuditLogger(c.logger, c.auditRepo, asyncConfig)
+		asyncLogger := infra_audit.NewAsyncAuditLogger(c.moduleLogger("audit"), c.auditRepo, asyncConfig, c.clock, c.auditSinks...)
+		if spillCfg := c.config.Auditing.Asynchronous.Spill; spillCfg.Enabled {
+			if err := asyncLogger.EnableSpill(infra_audit.AuditSpillConfig{
+				Dir:            spillCfg.Dir,
+				MaxBytes:       spillCfg.MaxBytes,
+				ReplayInterval: spillCfg.ReplayInterval,
+			}); err != nil {
+				return fmt.Errorf("failed to enable audit spill buffer: %w", err)
+			}
+			c.logger.Debug("enabled audit spill buffer", "dir", spillCfg.Dir)
+		}
 		asyncLogger.Start()
+		c.asyncAudit = asyncLogger
 		c.auditLogger = asyncLogger
 		c.logger.Debug("initialized asynchronous audit logger")
 	} else {
-		c.auditLogger = infra_audit.NewAuditLogger(c.logger, c.auditRepo)
+		c.auditLogger = infra_audit.NewAuditLogger(c.moduleLogger("audit"), c.auditRepo, c.clock, c.auditSinks...)
 		c.logger.Debug("initialized synchronous audit logger")
 	}
 
+	if samplingCfg := c.config.Auditing.Sampling; samplingCfg.Enabled {
+		rules := make([]infra_audit.SamplingRule, len(samplingCfg.Rules))
+		for i, rule := range samplingCfg.Rules {
+			rules[i] = infra_audit.SamplingRule{Operation: rule.Operation, OneIn: rule.OneIn}
+		}
+		sampler, err := infra_audit.NewSamplingLogger(c.auditLogger, rules)
+		if err != nil {
+			return fmt.Errorf("failed to configure audit sampling: %w", err)
+		}
+		c.auditLogger = sampler
+		c.logger.Debug("enabled audit sampling", "rules", len(rules))
+	}
+
+	return c.applyAuditVerbosity()
+}
+
+// applyAuditVerbosity wraps the audit logger when any operation is audited at less than
+// full verbosity.
+func (c *Container) applyAuditVerbosity() error {
+	verbosityCfg := c.config.Auditing.Verbosity
+	defaultLevel := infra_audit.VerbosityFull
+	if verbosityCfg.Default != "" {
+		var err error
+		if defaultLevel, err = infra_audit.ParseVerbosity(verbosityCfg.Default); err != nil {
+			return err
+		}
+	}
+	levels := make(map[string]infra_audit.Verbosity, len(verbosityCfg.Operations))
+	for _, op := range verbosityCfg.Operations {
+		level, err := infra_audit.ParseVerbosity(op.Level)
+		if err != nil {
+			return fmt.Errorf("invalid audit verbosity for %s: %w", op.Operation, err)
+		}
+		levels[op.Operation] = level
+	}
+	if defaultLevel == infra_audit.VerbosityFull && len(levels) == 0 {
+		return nil
+	}
+	c.auditLogger = infra_audit.NewVerbosityLogger(c.auditLogger, defaultLevel, levels)
+	c.logger.Debug("configured audit verbosity", "default", defaultLevel, "operations", len(levels))
+	return nil
+}
+
+func (c *Container) initAuditSinks() error {
+	if kafkaCfg := c.config.Auditing.Kafka; kafkaCfg.Enabled {
+		sink, err := infra_audit.NewKafkaSink(c.moduleLogger("audit"), infra_audit.KafkaSinkConfig{
+			Brokers:        kafkaCfg.Brokers,
+			Topic:          kafkaCfg.Topic,
+			MaxRetries:     kafkaCfg.MaxRetries,
+			RetryBackoff:   kafkaCfg.RetryBackoff,
+			WriteTimeout:   kafkaCfg.WriteTimeout,
+			DeadLetterPath: kafkaCfg.DeadLetterPath,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create kafka audit sink: %w", err)
+		}
+		c.auditSinks = append(c.auditSinks, sink)
+		c.logger.Debug("initialized kafka audit sink", "topic", kafkaCfg.Topic)
+	}
+	if syslogCfg := c.config.Auditing.Syslog; syslogCfg.Enabled {
+		var tlsConfig *tls.Config
+		if syslogCfg.Network == "tls" {
+			var err error
+			if tlsConfig, err = configureSyslogTLS(syslogCfg.CAFile); err != nil {
+				return err
+			}
+		}
+		sink, err := infra_audit.NewSyslogSink(c.moduleLogger("audit"), infra_audit.SyslogSinkConfig{
+			Network:      syslogCfg.Network,
+			Address:      syslogCfg.Address,
+			Format:       syslogCfg.Format,
+			Facility:     syslogCfg.Facility,
+			AppName:      syslogCfg.AppName,
+			Hostname:     syslogCfg.Hostname,
+			TLSConfig:    tlsConfig,
+			MaxRetries:   syslogCfg.MaxRetries,
+			WriteTimeout: syslogCfg.WriteTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create syslog audit sink: %w", err)
+		}
+		c.auditSinks = append(c.auditSinks, sink)
+		c.logger.Debug("initialized syslog audit sink", "address", syslogCfg.Address, "format", syslogCfg.Format)
+	}
+	if c.config.Webhooks.Enabled {
+		notifier, err := webhook.NewNotifier(c.moduleLogger("webhook"), c.config.Webhooks, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create webhook notifier: %w", err)
+		}
+		if c.keyEvents != nil {
+			notifier.Start(c.keyEvents)
+		}
+		c.auditSinks = append(c.auditSinks, notifier)
+		c.logger.Debug("initialized webhook notifier", "endpoints", len(c.config.Webhooks.Endpoints))
+	}
+	// Feed every sink through a queue of its own, so that a slow one holds up no other.
+	queueCfg := c.config.Auditing.SinkQueue
+	for i, sink := range c.auditSinks {
+		c.auditSinks[i] = infra_audit.NewQueuedSink(c.moduleLogger("audit"), sink, infra_audit.QueuedSinkConfig{
+			QueueSize:      queueCfg.Size,
+			DeadLetterPath: queueCfg.DeadLetterPath,
+			DrainTimeout:   queueCfg.DrainTimeout,
+		})
+	}
 	return nil
 }
 
@@ -143,23 +472,36 @@ func (c *Container) GetPgxPool(ctx context.Context) (*pgxpool.Pool, error) {
 	return c.pgxPool, nil
 }
 
+// GetKMSProviders returns the configured KMS providers by name.
+func (c *Container) GetKMSProviders(ctx context.Context) (map[string]kms.KMSProvider, error) {
+	if err := c.initKMSProviders(ctx); err != nil {
+		return nil, err
+	}
+	return c.kmsProviders, nil
+}
+
 func (c *Container) initPgxPool(ctx context.Context) error {
-	var err error
-	c.pgxPoolOnce.Do(func() {
-		dbConfig := infra_config.NeonDBConfig{URL: c.config.BootstrapSecrets.NeonDBURL}
-		c.pgxPool, err = persistence.NewSecureConnectionPool(ctx, dbConfig, c.config.Server, c.config.Persistence)
-		if err != nil {
-			c.logger.Error("failed to create database connection pool", "error", err)
-		}
-	})
-	return err
+	c.pgxPoolMu.Lock()
+	defer c.pgxPoolMu.Unlock()
+	if c.pgxPool != nil {
+		return nil
+	}
+	dbConfig := infra_config.NeonDBConfig{URL: c.config.BootstrapSecrets.NeonDBURL}
+	pool, rotator, err := persistence.NewRotatableConnectionPool(ctx, c.moduleLogger("persistence"), dbConfig, c.config.Server, c.config.Persistence)
+	if err != nil {
+		c.logger.Error("failed to create database connection pool", "error", err)
+		return unavailable(err)
+	}
+	c.pgxPool, c.dbRotator = pool, rotator
+	return nil
 }
 
 func (c *Container) initKMSProviders(ctx context.Context) error {
 	if c.kmsProviders != nil {
 		return nil
 	}
-	c.kmsProviders = make(map[string]kms.KMSProvider)
+	providers := make(map[string]kms.KMSProvider)
+	var breakers []*kms.CircuitBreakerProvider
 
 	// Initialize local provider if configured
 	if c.config.BootstrapSecrets.PolykeyMasterKey != "" {
@@ -167,7 +509,7 @@ func (c *Container) initKMSProviders(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("failed to create local KMS provider: %w", err)
 		}
-		c.kmsProviders["local"] = localProvider
+		providers["local"] = kms.NewInstrumentedProvider("local", c.withKMSChaos("local", localProvider))
 		c.logger.Debug("initialized local KMS provider")
 	}
 
@@ -175,23 +517,79 @@ func (c *Container) initKMSProviders(ctx context.Context) error {
 	if c.config.AWS.Enabled && c.config.BootstrapSecrets.AWSKMSKeyARN != "" {
 		awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(c.config.AWS.Region))
 		if err != nil {
-			return fmt.Errorf("failed to load AWS config: %w", err)
+			return unavailable(fmt.Errorf("failed to load AWS config: %w", err))
 		}
 
 		kmsKeyARN := c.config.BootstrapSecrets.AWSKMSKeyARN
-		c.kmsProviders["aws"] = kms.NewAWSKMSProvider(awsCfg, kmsKeyARN)
+		awsProvider := c.withKMSChaos("aws", kms.NewAWSKMSProvider(awsCfg, kmsKeyARN))
+		if breakerCfg := c.config.KMS.CircuitBreaker; breakerCfg.Enabled {
+			breaker := kms.NewCircuitBreakerProvider("aws", awsProvider, c.moduleLogger("kms"), breakerCfg.MaxFailures, breakerCfg.ResetTimeout)
+			breakers = append(breakers, breaker)
+			awsProvider = breaker
+		}
+		retry := c.config.KMS.Retry
+		awsProvider = kms.NewRetryingProvider("aws", awsProvider, execution.RetryPolicy{
+			MaxAttempts:    retry.MaxAttempts,
+			InitialBackoff: retry.InitialBackoff,
+			MaxBackoff:     retry.MaxBackoff,
+		})
+		providers["aws"] = kms.NewInstrumentedProvider("aws", awsProvider)
 		c.logger.Debug("initialized AWS KMS provider", "region", c.config.AWS.Region)
 	}
 
-	// Set CA cert in TLS config
-	if c.config.Server.TLS.Enabled && c.config.BootstrapSecrets.SpoungeCA != "" {
-		c.config.Server.TLS.ClientCAFile = c.config.BootstrapSecrets.SpoungeCA
+	if len(providers) == 0 {
+		return fmt.Errorf("no KMS provider configured")
 	}
+	c.kmsProviders, c.kmsBreakers = providers, breakers
 
-	if len(c.kmsProviders) == 0 {
-		return fmt.Errorf("no KMS provider configured")
+	return nil
+}
+
+// chaosInjector returns an injector of the faults of cfg.
+func chaosInjector(cfg infra_config.FaultConfig) *chaos.Injector {
+	return chaos.NewInjector(chaos.Faults{
+		Latency:         cfg.Latency,
+		Jitter:          cfg.Jitter,
+		ErrorRate:       cfg.ErrorRate,
+		ItemFailureRate: cfg.ItemFailureRate,
+		Seed:            cfg.Seed,
+	})
+}
+
+// withKMSChaos injects the configured KMS faults into provider when chaos is enabled,
+// inside its retries and circuit breaker.
+func (c *Container) withKMSChaos(name string, provider kms.KMSProvider) kms.KMSProvider {
+	if !c.config.Chaos.Enabled {
+		return provider
 	}
+	c.logger.Warn("injecting faults into KMS provider", "provider", name, "faults", c.config.Chaos.KMS)
+	return kms.NewChaosProvider(provider, chaosInjector(c.config.Chaos.KMS))
+}
 
+// initMetadataIntegrity loads the key metadata checksums are computed with and gives the
+// key versions written before checksums were keyed one, before anything reads them.
+func (c *Container) initMetadataIntegrity(ctx context.Context) error {
+	if c.integrity != nil {
+		return nil
+	}
+	if c.pgxPool == nil {
+		return fmt.Errorf("database pool not initialized")
+	}
+	integrity, err := domain.NewMetadataIntegrity(c.config.BootstrapSecrets.MetadataIntegrityKey)
+	if err != nil {
+		return err
+	}
+	resealed, mismatched, err := persistence.ResealMetadata(ctx, c.pgxPool, integrity)
+	if err != nil {
+		return unavailable(err)
+	}
+	if resealed > 0 {
+		c.logger.Info("keyed the checksums of key metadata", "versions", resealed)
+	}
+	if mismatched > 0 {
+		c.logger.Error("key metadata does not match its checksum and will not be served", "versions", mismatched)
+	}
+	c.integrity = integrity
 	return nil
 }
 
@@ -204,26 +602,45 @@ func (c *Container) initKeyRepository() error {
 	}
 	var err error
 	// Create the base repository
-	baseRepo, err := persistence.NewPSQLAdapter(c.pgxPool, c.logger)
+	baseRepo, err := persistence.NewPSQLAdapter(c.pgxPool, c.moduleLogger("persistence"), c.clock, c.integrity)
 	if err != nil {
 		return err
 	}
 
+	var queryRepo domain.KeyRepository = baseRepo
+	if c.config.Chaos.Enabled {
+		c.logger.Warn("injecting faults into key repository", "faults", c.config.Chaos.Repository)
+		queryRepo = persistence.NewChaosKeyRepository(baseRepo, chaosInjector(c.config.Chaos.Repository))
+	}
+
+	// Hedge the queries themselves, so that cache hits are never sent twice
+	if hedging := c.config.Persistence.Hedging; hedging.Enabled {
+		queryRepo = persistence.NewHedgedKeyRepository(queryRepo, hedging.Delay, hedging.MaxInFlight)
+		c.logger.Debug("hedging key reads", "delay", hedging.Delay)
+	}
+
 	// Wrap it with the cache decorator
-	cachedRepo := persistence.NewCachedRepository(baseRepo, c.logger)
+	cachedRepo := persistence.NewCachedRepository(queryRepo, c.moduleLogger("persistence"))
+	c.keyCache = cachedRepo
+
+	var repo domain.KeyRepository = cachedRepo
 
 	// Check if the circuit breaker is enabled
 	if c.config.Persistence.CircuitBreaker.Enabled {
 		c.logger.Debug("wrapping key repository with circuit breaker")
-		c.keyRepo = persistence.NewKeyRepositoryCircuitBreaker(
+		c.keyBreaker = persistence.NewKeyRepositoryCircuitBreaker(
 			cachedRepo,
+			c.moduleLogger("persistence"),
 			c.config.Persistence.CircuitBreaker.MaxFailures,
 			c.config.Persistence.CircuitBreaker.ResetTimeout,
 		)
-	} else {
-		c.keyRepo = cachedRepo
+		repo = c.keyBreaker
 	}
 
+	// Publish key events only once the write has gone through every other layer.
+	c.keyEvents = infra_events.NewBroker(c.moduleLogger("events"), 0)
+	c.keyRepo = persistence.NewKeyEventRepository(repo, c.keyEvents, c.clock)
+
 	c.logger.Debug("initialized key repository")
 	return nil
 }
@@ -235,12 +652,17 @@ func (c *Container) initAuditRepository() error {
 	if c.pgxPool == nil {
 		return fmt.Errorf("database pool not initialized")
 	}
-	var err error
-	c.auditRepo, err = persistence.NewAuditRepository(c.pgxPool)
-	if err == nil {
-		c.logger.Debug("initialized audit repository")
+	repo, err := persistence.NewAuditRepository(c.pgxPool)
+	if err != nil {
+		return err
 	}
-	return err
+	c.auditRepo = repo
+	if breakerCfg := c.config.Persistence.CircuitBreaker; breakerCfg.Enabled {
+		c.auditBreaker = persistence.NewAuditRepositoryCircuitBreaker(repo, c.moduleLogger("persistence"), breakerCfg.MaxFailures, breakerCfg.ResetTimeout)
+		c.auditRepo = c.auditBreaker
+	}
+	c.logger.Debug("initialized audit repository")
+	return nil
 }
 
 func (c *Container) initClientStore() error {
@@ -259,8 +681,13 @@ func (c *Container) initTokenStore() error {
 	if c.tokenStore != nil {
 		return nil
 	}
-	c.tokenStore = infra_auth.NewInMemoryTokenStore()
-	c.logger.Debug("initialized in-memory token store")
+	if c.pgxPool == nil {
+		c.tokenStore = infra_auth.NewInMemoryTokenStore(c.clock)
+		c.logger.Debug("initialized in-memory token store")
+		return nil
+	}
+	c.tokenStore = persistence.NewTokenStore(c.pgxPool, c.moduleLogger("auth"), c.clock)
+	c.logger.Debug("initialized persistent token store")
 	return nil
 }
 
@@ -272,7 +699,7 @@ func (c *Container) initTokenManager() error {
 		return fmt.Errorf("token store not initialized")
 	}
 	var err error
-	c.tokenManager, err = infra_auth.NewTokenManager(c.config.BootstrapSecrets.JWTRSAPrivateKey, c.tokenStore, c.auditLogger)
+	c.tokenManager, err = infra_auth.NewTokenManager(c.config.BootstrapSecrets.JWTRSAPrivateKey, c.tokenStore, c.auditLogger, c.clock)
 	if err == nil {
 		c.logger.Debug("initialized token manager")
 	}
@@ -292,12 +719,50 @@ func (c *Container) initKeyService() error {
 	if c.auditLogger == nil {
 		return fmt.Errorf("audit logger not initialized")
 	}
-	errorClassifier := app_errors.NewErrorClassifier(c.logger)
-	c.keyService = service.NewKeyService(c.config, c.keyRepo, c.kmsProviders, c.logger, errorClassifier, c.auditLogger)
+	var accessRecorder domain.KeyAccessRecorder
+	if c.accessStats != nil {
+		accessRecorder = c.accessStats
+	}
+	errorClassifier := app_errors.NewErrorClassifier(c.moduleLogger("service"))
+	templates := persistence.NewKeyTemplateRepository(c.pgxPool)
+	aliases := persistence.NewKeyAliasRepository(c.pgxPool)
+	c.keyService = service.NewKeyService(c.config, c.keyRepo, c.kmsProviders, c.moduleLogger("service"), errorClassifier, c.auditLogger, accessRecorder, templates, aliases, c.clock)
 	c.logger.Debug("initialized key service")
 	return nil
 }
 
+func (c *Container) initKMSRewrapService() error {
+	if c.kmsRewrap != nil {
+		return nil
+	}
+	if c.pgxPool == nil {
+		return fmt.Errorf("database pool not initialized")
+	}
+	var keyCache domain.CacheFlusher
+	if c.keyCache != nil {
+		keyCache = c.keyCache
+	}
+	c.kmsRewrap = service.NewKMSRewrapService(persistence.NewKMSRewrapRepository(c.pgxPool, c.integrity), c.kmsProviders, c.config, keyCache, c.moduleLogger("service"), c.clock)
+	c.logger.Debug("initialized kms re-wrap service")
+	return nil
+}
+
+func (c *Container) initAccessRecorder() error {
+	if c.accessStats != nil || !c.config.KeyLifecycle.AccessStats.Enabled {
+		return nil
+	}
+	if c.pgxPool == nil {
+		return fmt.Errorf("database pool not initialized")
+	}
+	c.accessStats = usage.NewAccessRecorder(persistence.NewKeyAccessRepository(c.pgxPool), c.moduleLogger("usage"), usage.AccessRecorderConfig{
+		FlushInterval:  c.config.KeyLifecycle.AccessStats.FlushInterval,
+		MaxPendingKeys: c.config.KeyLifecycle.AccessStats.MaxPendingKeys,
+	}, c.clock)
+	c.accessStats.Start()
+	c.logger.Debug("initialized key access recorder")
+	return nil
+}
+
 func (c *Container) initAuthService() error {
 	if c.authService != nil {
 		return nil
@@ -308,11 +773,249 @@ func (c *Container) initAuthService() error {
 	if c.tokenManager == nil {
 		return fmt.Errorf("token manager not initialized")
 	}
-	c.authService = service.NewAuthService(c.clientStore, c.tokenManager, time.Hour)
+	c.authService = service.NewAuthService(c.clientStore, c.tokenManager, c.config.Authorization.Tokens, c.config.Authorization.Authenticate, c.clock)
 	c.logger.Debug("initialized auth service")
 	return nil
 }
 
+func (c *Container) initAuditArchiveStore(ctx context.Context) error {
+	if c.archives != nil || !c.config.Auditing.Retention.Enabled {
+		return nil
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(c.config.AWS.Region))
+	if err != nil {
+		return unavailable(fmt.Errorf("failed to load AWS config for audit archives: %w", err))
+	}
+	c.archives = persistence.NewS3AuditArchiveStore(awsCfg, c.config.Auditing.Retention)
+	c.logger.Debug("initialized audit archive store", "bucket", c.config.Auditing.Retention.Bucket)
+	return nil
+}
+
+func (c *Container) initAuditService() error {
+	if c.auditService != nil {
+		return nil
+	}
+	if c.auditRepo == nil {
+		return fmt.Errorf("audit repository not initialized")
+	}
+	c.auditService = service.NewAuditService(c.auditRepo, c.archives, c.config.Auditing.Retention.RestoreFor, c.config.BootstrapSecrets.JWTRSAPrivateKey, c.moduleLogger("service"), c.clock)
+	c.logger.Debug("initialized audit service")
+	return nil
+}
+
+// initHealthChecker registers a probe for every dependency the service talks to.
+// The database and the default KMS provider are critical; the rest only degrade.
+func (c *Container) initHealthChecker() error {
+	if c.health != nil {
+		return nil
+	}
+	if c.pgxPool == nil {
+		return fmt.Errorf("database pool not initialized")
+	}
+
+	checker := infra_health.NewChecker(0, infra_health.Component{
+		Name:     "database",
+		Critical: true,
+		Check:    c.pgxPool.Ping,
+	})
+	for name, provider := range c.kmsProviders {
+		checker.Register(infra_health.Component{
+			Name:     "kms." + name,
+			Critical: name == c.config.DefaultKMSProvider,
+			Check:    provider.HealthCheck,
+		})
+	}
+	if c.keyCache != nil {
+		checker.Register(infra_health.Component{Name: "cache", Check: c.keyCache.HealthCheck})
+	}
+	if probe, ok := c.auditLogger.(interface{ HealthCheck(context.Context) error }); ok {
+		checker.Register(infra_health.Component{Name: "audit", Check: probe.HealthCheck})
+	}
+	if c.accessStats != nil {
+		checker.Register(infra_health.Component{Name: "access_stats", Check: c.accessStats.HealthCheck})
+	}
+	if probe, ok := c.rateLimiter.(interface{ HealthCheck(context.Context) error }); ok {
+		checker.Register(infra_health.Component{Name: "rate_limiter", Check: probe.HealthCheck})
+	}
+
+	c.health = checker
+	c.logger.Debug("initialized health checker")
+	return nil
+}
+
+// initRateLimiter creates the per-client rate limiter of the authentication
+// interceptors: a bucket per client in this replica, or with the redis backend one
+// shared by every replica.
+func (c *Container) initRateLimiter() error {
+	if c.rateLimiter != nil {
+		return nil
+	}
+	limits := c.config.Server.RateLimiter
+	if limits.Backend != "redis" {
+		limiter := ratelimit.NewInMemoryRateLimiter(rate.Limit(limits.Rate), limits.Burst)
+		limiter.Configure(limits.Enabled, rate.Limit(limits.Rate), limits.Burst)
+		c.rateLimiter = limiter
+		return nil
+	}
+
+	redisCfg := c.config.Redis
+	options := &redis.Options{
+		Addr:         redisCfg.Address,
+		Username:     redisCfg.Username,
+		Password:     redisCfg.Password,
+		DB:           redisCfg.DB,
+		DialTimeout:  redisCfg.Timeout,
+		ReadTimeout:  redisCfg.Timeout,
+		WriteTimeout: redisCfg.Timeout,
+		// A check that fails falls back to the local limiter; retrying would only add
+		// latency to the request.
+		MaxRetries: -1,
+	}
+	if redisCfg.TLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	limiter := ratelimit.NewRedisRateLimiter(redis.NewClient(options), redisCfg.KeyPrefix, rate.Limit(limits.Rate), limits.Burst, c.moduleLogger("ratelimit"))
+	limiter.Configure(limits.Enabled, rate.Limit(limits.Rate), limits.Burst)
+	c.rateLimiter = limiter
+	c.logger.Debug("initialized redis rate limiter", "address", redisCfg.Address)
+	return nil
+}
+
+// initLeaderElector creates the elector that the background jobs wait on when leader
+// election is enabled.
+func (c *Container) initLeaderElector() error {
+	if c.leader != nil || !c.config.LeaderElection.Enabled {
+		return nil
+	}
+	if c.pgxPool == nil {
+		return fmt.Errorf("database pool not initialized")
+	}
+	c.leader = persistence.NewAdvisoryLockElector(c.pgxPool, c.moduleLogger("jobs"), c.config.LeaderElection)
+	c.logger.Debug("initialized leader election", "lock", c.config.LeaderElection.LockName)
+	return nil
+}
+
+func (c *Container) initExpirationJob() error {
+	if c.expiration != nil || !c.config.KeyLifecycle.Expiration.Enabled {
+		return nil
+	}
+	if c.keyRepo == nil {
+		return fmt.Errorf("key repository not initialized")
+	}
+	var publisher domain.KeyEventPublisher
+	if c.keyEvents != nil {
+		publisher = c.keyEvents
+	}
+	var notices domain.KeyExpiryNoticeStore
+	if c.pgxPool != nil {
+		notices = persistence.NewKeyExpiryNoticeRepository(c.pgxPool)
+	}
+	c.expiration = jobs.NewKeyExpirationJob(c.keyRepo, notices, publisher, c.moduleLogger("jobs"), c.config.KeyLifecycle.Expiration, c.clock)
+	if c.leader != nil {
+		c.expiration.SetLeadership(c.leader)
+	}
+	c.logger.Debug("initialized key expiration job")
+	return nil
+}
+
+func (c *Container) initRetentionJob() error {
+	if c.retention != nil || c.archives == nil {
+		return nil
+	}
+	if c.auditRepo == nil {
+		return fmt.Errorf("audit repository not initialized")
+	}
+	c.retention = jobs.NewAuditRetentionJob(c.auditRepo, c.archives, c.moduleLogger("jobs"), c.config.Auditing.Retention, c.clock)
+	if c.leader != nil {
+		c.retention.SetLeadership(c.leader)
+	}
+	c.logger.Debug("initialized audit retention job")
+	return nil
+}
+
+func (c *Container) initVersionRetentionJob() error {
+	if c.versions != nil || !c.config.KeyLifecycle.VersionRetention.Enabled {
+		return nil
+	}
+	if c.keyRepo == nil {
+		return fmt.Errorf("key repository not initialized")
+	}
+	c.versions = jobs.NewKeyVersionRetentionJob(c.keyRepo, c.moduleLogger("jobs"), c.config.KeyLifecycle.VersionRetention, c.clock)
+	if c.leader != nil {
+		c.versions.SetLeadership(c.leader)
+	}
+	c.logger.Debug("initialized key version retention job")
+	return nil
+}
+
+func (c *Container) initRotationScheduleJob() error {
+	if c.rotations != nil || !c.config.KeyLifecycle.Rotation.Scheduled.Enabled {
+		return nil
+	}
+	if c.keyService == nil {
+		return fmt.Errorf("key service not initialized")
+	}
+	c.rotations = jobs.NewKeyRotationScheduleJob(c.keyService, c.moduleLogger("jobs"), c.config.KeyLifecycle.Rotation.Scheduled)
+	if c.leader != nil {
+		c.rotations.SetLeadership(c.leader)
+	}
+	c.logger.Debug("initialized scheduled key rotation job")
+	return nil
+}
+
+func (c *Container) initReplicationJob() error {
+	if c.replication != nil || !c.config.Replication.Enabled {
+		return nil
+	}
+	if c.pgxPool == nil {
+		return fmt.Errorf("database pool not initialized")
+	}
+	if c.config.AWS.Enabled {
+		if err := persistence.CheckReplicableKMSKey(c.config.BootstrapSecrets.AWSKMSKeyARN); err != nil {
+			return err
+		}
+	}
+	standby, err := persistence.NewLazyConnectionPool(infra_config.NeonDBConfig{URL: c.config.Replication.StandbyURL}, c.config.Server, c.config.Persistence)
+	if err != nil {
+		return fmt.Errorf("failed to create standby connection pool: %w", err)
+	}
+	c.standbyPool = standby
+	replicator := persistence.NewOutboxReplicator(c.pgxPool, standby, c.config.Replication.Target)
+	c.replication = jobs.NewKeyReplicationJob(replicator, c.moduleLogger("jobs"), c.config.Replication)
+	if c.leader != nil {
+		c.replication.SetLeadership(c.leader)
+	}
+	c.logger.Debug("initialized key replication job", "target", c.config.Replication.Target)
+	return nil
+}
+
+// initEventRelayJob publishes the key events recorded in the outbox to NATS JetStream.
+func (c *Container) initEventRelayJob(ctx context.Context) error {
+	cfg := c.config.Events.NATS
+	if c.eventRelay != nil || !cfg.Enabled {
+		return nil
+	}
+	if c.pgxPool == nil {
+		return fmt.Errorf("database pool not initialized")
+	}
+	sink, err := infra_events.NewNATSSink(ctx, cfg)
+	if err != nil {
+		return unavailable(err)
+	}
+	c.natsSink = sink
+	if c.health != nil {
+		c.health.Register(infra_health.Component{Name: "nats", Check: sink.HealthCheck})
+	}
+
+	outbox := persistence.NewPostgresKeyEventOutbox(c.pgxPool, sink.Name())
+	c.eventRelay = jobs.NewKeyEventRelayJob(outbox, sink, c.moduleLogger("jobs"), cfg.Interval, cfg.BatchSize)
+	if c.leader != nil {
+		c.eventRelay.SetLeadership(c.leader)
+	}
+	c.logger.Debug("initialized key event relay job", "sink", sink.Name(), "stream", cfg.Stream)
+	return nil
+}
+
 func (c *Container) Close() error {
 	// Stop the audit logger first to ensure all events are flushed before dependencies close.
 	if c.auditLogger != nil {
@@ -320,8 +1023,28 @@ func (c *Container) Close() error {
 			logger.Stop()
 		}
 	}
-
 	var errs []error
+	for _, sink := range c.auditSinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close audit sink %s: %w", sink.Name(), err))
+		}
+	}
+	if c.accessStats != nil {
+		c.accessStats.Stop()
+	}
+
+	if closer, ok := c.rateLimiter.(interface{ Close() error }); ok {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close rate limiter: %w", err))
+		}
+	}
+
+	if c.standbyPool != nil {
+		c.standbyPool.Close()
+	}
+	if c.natsSink != nil {
+		c.natsSink.Close()
+	}
 	if c.pgxPool != nil {
 		c.pgxPool.Close()
 		c.logger.Debug("closed database connection pool")
@@ -333,18 +1056,29 @@ func (c *Container) Close() error {
 			}
 		}
 	}
+	// Flush spans last, so that those of the shutdown itself are exported too.
+	if c.tracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		if err := c.tracing.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush traces: %w", err))
+		}
+		cancel()
+	}
 	if len(errs) > 0 {
 		return fmt.Errorf("errors during cleanup: %v", errs)
 	}
 	return nil
 }
 
+// tracingShutdownTimeout bounds how long Close waits for buffered spans to be exported.
+const tracingShutdownTimeout = 5 * time.Second
+
 func (c *Container) GetS3KeyRepository(ctx context.Context) (domain.KeyRepository, error) {
 	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(c.config.AWS.Region))
 	if err != nil {
 		return nil, err
 	}
-	return persistence.NewS3Storage(awsCfg, c.config.AWS.S3Bucket, c.logger)
+	return persistence.NewS3Storage(awsCfg, c.config.AWS.S3Bucket, c.moduleLogger("persistence"), c.clock, c.integrity)
 }
 
 
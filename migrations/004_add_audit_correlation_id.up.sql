@@ -0,0 +1,3 @@
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(128);
+
+CREATE INDEX IF NOT EXISTS idx_audit_correlation_id ON audit_events(correlation_id);
@@ -0,0 +1,4 @@
+-- Supports the expiration job, which scans active keys by metadata expires_at.
+CREATE INDEX IF NOT EXISTS idx_keys_active_expires_at
+    ON keys (((metadata->'expires_at'->>'seconds')::bigint))
+    WHERE status = 'active';
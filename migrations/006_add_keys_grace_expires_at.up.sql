@@ -0,0 +1,4 @@
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS grace_expires_at TIMESTAMPTZ;
+
+-- Supports the expiration job's sweep of rotated versions past their grace period.
+CREATE INDEX IF NOT EXISTS idx_keys_rotated_grace_expires_at ON keys(grace_expires_at) WHERE status = 'rotated';
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS key_templates (
+    name VARCHAR(128) PRIMARY KEY,
+    definition JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,5 @@
+-- Keys created before namespaces existed belong to the default namespace.
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS namespace VARCHAR(63) NOT NULL DEFAULT 'default';
+
+-- Supports namespace-scoped listing and quota counts.
+CREATE INDEX IF NOT EXISTS idx_keys_namespace_created_at ON keys(namespace, created_at DESC);
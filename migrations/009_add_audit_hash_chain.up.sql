@@ -0,0 +1,16 @@
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS prev_hash CHAR(64);
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS hash CHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_seq ON audit_events(seq);
+
+-- The chain head records the last sequence and hash written. Writers lock this row to
+-- append, and verification compares it with the last stored event to detect truncation.
+CREATE TABLE IF NOT EXISTS audit_chain_head (
+    id SMALLINT PRIMARY KEY CHECK (id = 1),
+    seq BIGINT NOT NULL,
+    hash CHAR(64) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO audit_chain_head (id, seq, hash) VALUES (1, 0, repeat('0', 64)) ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Keyset pagination of audit queries walks (timestamp, id) newest first.
+CREATE INDEX IF NOT EXISTS idx_audit_ts_id ON audit_events(timestamp DESC, id DESC);
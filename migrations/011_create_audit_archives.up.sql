@@ -0,0 +1,23 @@
+-- Each row describes one object of audit events moved to cold storage. The archived part
+-- of the chain is the range up to the highest last_seq; verification starts after it.
+CREATE TABLE IF NOT EXISTS audit_archives (
+    object_key TEXT PRIMARY KEY,
+    first_seq BIGINT NOT NULL,
+    last_seq BIGINT NOT NULL,
+    last_hash CHAR(64),
+    first_timestamp TIMESTAMPTZ NOT NULL,
+    last_timestamp TIMESTAMPTZ NOT NULL,
+    event_count INTEGER NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    restored_until TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_archives_last_seq ON audit_archives(last_seq DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_archives_timestamps ON audit_archives(first_timestamp, last_timestamp);
+
+-- Events restored from an archive for an investigation carry its key, so that they are
+-- not archived again and can be removed once the restore expires.
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS archive_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_audit_archive_key ON audit_events(archive_key) WHERE archive_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_timestamp_unchained ON audit_events(timestamp) WHERE seq IS NULL;
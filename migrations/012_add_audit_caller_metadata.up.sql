@@ -0,0 +1,5 @@
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS peer_ip TEXT;
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS cert_fingerprint CHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_audit_peer_ip_ts ON audit_events(peer_ip, timestamp DESC) WHERE peer_ip IS NOT NULL;
@@ -0,0 +1,19 @@
+-- Names the KMS provider that wrapped the DEK of each key version. Versions written
+-- before it existed have none, and are wrapped by the provider their storage profile
+-- selects under default_kms_provider.
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS kms_provider VARCHAR(32);
+
+-- Each row records how far a re-wrap of DEKs from one KMS provider to another has got,
+-- so that an interrupted migration resumes after the last key version it finished.
+CREATE TABLE IF NOT EXISTS kms_rewrap_checkpoints (
+    from_provider VARCHAR(32) NOT NULL,
+    to_provider VARCHAR(32) NOT NULL,
+    last_key_id UUID,
+    last_version INTEGER NOT NULL DEFAULT 0,
+    rewrapped BIGINT NOT NULL DEFAULT 0,
+    failed BIGINT NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at TIMESTAMPTZ,
+    PRIMARY KEY (from_provider, to_provider)
+);
@@ -0,0 +1,21 @@
+-- Key versions pruned by the version retention job when archiving is enabled. They are
+-- no longer served, but their wrapped DEKs stay recoverable by an operator.
+CREATE TABLE IF NOT EXISTS archived_key_versions (
+    id UUID NOT NULL,
+    version INT NOT NULL,
+    metadata JSONB NOT NULL,
+    encrypted_dek BYTEA NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    storage_type VARCHAR(20) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    grace_expires_at TIMESTAMPTZ,
+    namespace VARCHAR(63) NOT NULL,
+    kms_provider VARCHAR(32),
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (id, version)
+);
+
+-- Supports the retention job's sweep of versions that went out of service long ago.
+CREATE INDEX IF NOT EXISTS idx_keys_expired_updated_at ON keys(updated_at) WHERE status = 'expired';
@@ -0,0 +1,34 @@
+-- Each registered target is a standby database the key replication job ships key
+-- mutations to. Mutations are only recorded while a target is registered.
+CREATE TABLE IF NOT EXISTS replication_targets (
+    name VARCHAR(63) PRIMARY KEY,
+    registered_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Key versions changed since they were last shipped to a target, recorded by the trigger
+-- below in the transaction that changed them. The job ships the current state of each
+-- version, or its absence, and then deletes the entry.
+CREATE TABLE IF NOT EXISTS key_outbox (
+    seq BIGSERIAL PRIMARY KEY,
+    target VARCHAR(63) NOT NULL REFERENCES replication_targets(name) ON DELETE CASCADE,
+    key_id UUID NOT NULL,
+    version INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_key_outbox_target_seq ON key_outbox(target, seq);
+
+CREATE OR REPLACE FUNCTION record_key_outbox() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO key_outbox (target, key_id, version) SELECT name, OLD.id, OLD.version FROM replication_targets;
+        RETURN OLD;
+    END IF;
+    INSERT INTO key_outbox (target, key_id, version) SELECT name, NEW.id, NEW.version FROM replication_targets;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS keys_outbox ON keys;
+CREATE TRIGGER keys_outbox AFTER INSERT OR UPDATE OR DELETE ON keys
+    FOR EACH ROW EXECUTE FUNCTION record_key_outbox();
@@ -0,0 +1,52 @@
+-- Each registered consumer is an event relay, such as the NATS publisher, that delivers
+-- the key lifecycle events below. Events are only recorded while a consumer is registered.
+CREATE TABLE IF NOT EXISTS key_event_consumers (
+    name VARCHAR(63) PRIMARY KEY,
+    registered_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Key lifecycle events not yet delivered by a consumer, recorded by the trigger below in
+-- the transaction that changed the key, so that no committed change goes unreported. The
+-- consumer deletes an event once it is delivered; event_id lets receivers drop the
+-- duplicates of an event delivered again after a failure.
+CREATE TABLE IF NOT EXISTS key_event_outbox (
+    seq BIGSERIAL PRIMARY KEY,
+    consumer VARCHAR(63) NOT NULL REFERENCES key_event_consumers(name) ON DELETE CASCADE,
+    event_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    event_type VARCHAR(20) NOT NULL,
+    key_id UUID NOT NULL,
+    version INT NOT NULL,
+    namespace VARCHAR(63) NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_key_event_outbox_consumer_seq ON key_event_outbox(consumer, seq);
+
+-- A new version is a creation or a rotation. Status changes of the latest version are
+-- revocations, restorations and expiries; revoking a key revokes all its versions, and
+-- the older ones are not reported.
+CREATE OR REPLACE FUNCTION record_key_event() RETURNS trigger AS $$
+DECLARE
+    event VARCHAR(20);
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        event := CASE WHEN NEW.version = 1 THEN 'created' ELSE 'rotated' END;
+    ELSIF NEW.status IS DISTINCT FROM OLD.status
+        AND NEW.version = (SELECT MAX(version) FROM keys WHERE id = NEW.id) THEN
+        event := CASE
+            WHEN NEW.status = 'revoked' THEN 'revoked'
+            WHEN NEW.status = 'expired' THEN 'expired'
+            WHEN NEW.status = 'active' AND OLD.status = 'revoked' THEN 'restored'
+        END;
+    END IF;
+    IF event IS NOT NULL THEN
+        INSERT INTO key_event_outbox (consumer, event_type, key_id, version, namespace)
+            SELECT name, event, NEW.id, NEW.version, NEW.namespace FROM key_event_consumers;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS keys_event_outbox ON keys;
+CREATE TRIGGER keys_event_outbox AFTER INSERT OR UPDATE ON keys
+    FOR EACH ROW EXECUTE FUNCTION record_key_event();
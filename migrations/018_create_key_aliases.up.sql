@@ -0,0 +1,14 @@
+-- Aliases are names declarative tools such as Terraform choose for the keys they manage,
+-- so that they can find a key again without having recorded its ID. An alias is unique
+-- within its namespace and is moved to a new key when its key is revoked and applied
+-- again.
+CREATE TABLE IF NOT EXISTS key_aliases (
+    namespace VARCHAR(63) NOT NULL,
+    alias VARCHAR(128) NOT NULL,
+    key_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (namespace, alias)
+);
+
+CREATE INDEX IF NOT EXISTS idx_key_aliases_key_id ON key_aliases(key_id);
@@ -0,0 +1,37 @@
+-- The metadata fields lists and the expiration job filter on, kept as columns so that
+-- they can be indexed and filtered without reading metadata. A trigger derives them
+-- from metadata on every write, so that every writer, the standby's replication
+-- included, keeps them current. key_type holds the KeyType enum number.
+ALTER TABLE keys
+    ADD COLUMN IF NOT EXISTS key_type INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS creator_identity TEXT,
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS data_classification TEXT;
+
+CREATE OR REPLACE FUNCTION set_key_metadata_columns() RETURNS trigger AS $$
+BEGIN
+    NEW.key_type := COALESCE((NEW.metadata->>'key_type')::int, 0);
+    NEW.creator_identity := NULLIF(NEW.metadata->>'creator_identity', '');
+    NEW.expires_at := to_timestamp((NEW.metadata->'expires_at'->>'seconds')::bigint);
+    NEW.data_classification := NULLIF(NEW.metadata->>'data_classification', '');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS keys_metadata_columns ON keys;
+CREATE TRIGGER keys_metadata_columns BEFORE INSERT OR UPDATE OF metadata ON keys
+    FOR EACH ROW EXECUTE FUNCTION set_key_metadata_columns();
+
+-- Backfill the existing versions.
+UPDATE keys SET
+    key_type = COALESCE((metadata->>'key_type')::int, 0),
+    creator_identity = NULLIF(metadata->>'creator_identity', ''),
+    expires_at = to_timestamp((metadata->'expires_at'->>'seconds')::bigint),
+    data_classification = NULLIF(metadata->>'data_classification', '');
+
+-- The expiration job now scans the column.
+DROP INDEX IF EXISTS idx_keys_active_expires_at;
+CREATE INDEX IF NOT EXISTS idx_keys_active_expires_at ON keys(expires_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_keys_namespace_key_type ON keys(namespace, key_type);
+CREATE INDEX IF NOT EXISTS idx_keys_namespace_creator ON keys(namespace, creator_identity);
+CREATE INDEX IF NOT EXISTS idx_keys_data_classification ON keys(data_classification) WHERE data_classification IS NOT NULL;
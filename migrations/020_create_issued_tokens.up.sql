@@ -0,0 +1,14 @@
+-- Issued tokens are the access tokens handed out by Authenticate, tracked so that the
+-- sessions of a client can be listed and revoked together during incident response.
+-- Rows are also the revocation list: a token is revoked once revoked_at is set. Rows of
+-- expired tokens are pruned as their client is issued new ones.
+CREATE TABLE IF NOT EXISTS issued_tokens (
+    token_id VARCHAR(64) PRIMARY KEY,
+    client_id VARCHAR(255) NOT NULL,
+    namespace VARCHAR(63) NOT NULL DEFAULT '',
+    issued_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_issued_tokens_client_id ON issued_tokens(client_id, expires_at);
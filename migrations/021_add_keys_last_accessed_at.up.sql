@@ -0,0 +1,24 @@
+-- Keys can be listed by their last access, kept as a column so that listings can order
+-- by it without reading metadata. Like the columns of 019, the trigger derives it from
+-- metadata on every write; last accesses are kept to the second.
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS last_accessed_at TIMESTAMPTZ;
+
+CREATE OR REPLACE FUNCTION set_key_metadata_columns() RETURNS trigger AS $$
+BEGIN
+    NEW.key_type := COALESCE((NEW.metadata->>'key_type')::int, 0);
+    NEW.creator_identity := NULLIF(NEW.metadata->>'creator_identity', '');
+    NEW.expires_at := to_timestamp((NEW.metadata->'expires_at'->>'seconds')::bigint);
+    NEW.data_classification := NULLIF(NEW.metadata->>'data_classification', '');
+    NEW.last_accessed_at := to_timestamp((NEW.metadata->'last_accessed_at'->>'seconds')::bigint);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Backfill the existing versions.
+UPDATE keys SET last_accessed_at = to_timestamp((metadata->'last_accessed_at'->>'seconds')::bigint)
+WHERE metadata ? 'last_accessed_at';
+
+-- Support the orders listings offer besides creation time; aliases are ordered by the
+-- primary key of key_aliases.
+CREATE INDEX IF NOT EXISTS idx_keys_namespace_updated_at ON keys(namespace, updated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_keys_namespace_last_accessed_at ON keys(namespace, last_accessed_at DESC);
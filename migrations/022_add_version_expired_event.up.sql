@@ -0,0 +1,26 @@
+-- A rotated version whose grace period ended is reported as version_expired, so that
+-- consumers can tell it from the expiry of the key itself and drop only that version.
+-- event_type is wide enough for the new name.
+CREATE OR REPLACE FUNCTION record_key_event() RETURNS trigger AS $$
+DECLARE
+    event VARCHAR(20);
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        event := CASE WHEN NEW.version = 1 THEN 'created' ELSE 'rotated' END;
+    ELSIF NEW.status IS DISTINCT FROM OLD.status
+        AND NEW.version = (SELECT MAX(version) FROM keys WHERE id = NEW.id) THEN
+        event := CASE
+            WHEN NEW.status = 'revoked' THEN 'revoked'
+            WHEN NEW.status = 'expired' THEN 'expired'
+            WHEN NEW.status = 'active' AND OLD.status = 'revoked' THEN 'restored'
+        END;
+    ELSIF NEW.status = 'expired' AND OLD.status = 'rotated' THEN
+        event := 'version_expired';
+    END IF;
+    IF event IS NOT NULL THEN
+        INSERT INTO key_event_outbox (consumer, event_type, key_id, version, namespace)
+            SELECT name, event, NEW.id, NEW.version, NEW.namespace FROM key_event_consumers;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
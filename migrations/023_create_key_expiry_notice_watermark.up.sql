@@ -0,0 +1,10 @@
+-- The key expiration job announces keys about to expire in the order of their expiry and
+-- ID. This single row records the last key it announced, so that a restarted job, or one
+-- on another replica after a leader change, resumes there instead of announcing keys
+-- again or skipping them.
+CREATE TABLE IF NOT EXISTS key_expiry_notice_watermark (
+    singleton BOOLEAN PRIMARY KEY DEFAULT true CHECK (singleton),
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_key_id UUID,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
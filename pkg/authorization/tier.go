@@ -22,6 +22,20 @@ func FromProtoTier(tier cmn.ClientTier) domain.KeyTier {
 	}
 }
 
+// ToProtoTier converts a domain KeyTier to its protobuf ClientTier enum.
+func ToProtoTier(tier domain.KeyTier) cmn.ClientTier {
+	switch tier {
+	case domain.TierFree:
+		return cmn.ClientTier_CLIENT_TIER_FREE
+	case domain.TierPro:
+		return cmn.ClientTier_CLIENT_TIER_PRO
+	case domain.TierEnterprise:
+		return cmn.ClientTier_CLIENT_TIER_ENTERPRISE
+	default:
+		return cmn.ClientTier_CLIENT_TIER_UNSPECIFIED
+	}
+}
+
 // ValidateTierForProfile checks if a client of a certain tier can use the specified storage profile.
 // It includes input validation and returns a structured error.
 func ValidateTierForProfile(tier domain.KeyTier, profile pk.StorageProfile) error {
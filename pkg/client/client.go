@@ -0,0 +1,176 @@
+// Package client is the Go client of the Polykey API. It manages the connection,
+// authenticates with client credentials and renews the access token, retries the calls
+// the server rejected without running them, tags mutations with signed idempotency keys,
+// and encrypts data with Polykey keys through EncryptWithKey and DecryptWithKey.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/polykeyclient"
+	"github.com/spounge-ai/polykey/pkg/execution"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Defaults of a Config.
+const (
+	DefaultMaxAttempts    = 4
+	DefaultInitialBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff     = 5 * time.Second
+)
+
+// Config configures a Client.
+type Config struct {
+	// Address is the host:port of the Polykey gRPC server.
+	Address string
+	// ClientID and APIKey are the client credentials the client authenticates with.
+	ClientID string
+	APIKey   string
+	// TLS configures the connection, with the client certificate when the server requires
+	// mTLS. Nil connects without TLS, which only suits local development.
+	TLS *tls.Config
+	// MaxAttempts, InitialBackoff and MaxBackoff bound the retries of a call, the first
+	// attempt included. Zero values take the defaults; a MaxAttempts of 1 disables retries.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DialOptions are added to the options the connection is created with.
+	DialOptions []grpc.DialOption
+}
+
+// Client is a connection to a Polykey server, authenticated as one client. It is safe
+// for concurrent use.
+type Client struct {
+	conn   *grpc.ClientConn
+	tokens *polykeyclient.TokenSource
+	keys   pk.PolykeyServiceClient
+}
+
+// New connects to the server of cfg. The connection is established and the client
+// authenticated on the first call.
+func New(cfg Config) (*Client, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("client: an address is required")
+	}
+	if cfg.ClientID == "" || cfg.APIKey == "" {
+		return nil, errors.New("client: a client ID and API key are required")
+	}
+
+	creds := insecure.NewCredentials()
+	if cfg.TLS != nil {
+		creds = credentials.NewTLS(cfg.TLS)
+	}
+	policy := execution.RetryPolicy{
+		MaxAttempts:    cfg.MaxAttempts,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultMaxAttempts
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = DefaultInitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DefaultMaxBackoff
+	}
+
+	tokens := polykeyclient.NewTokenSource(cfg.ClientID, cfg.APIKey)
+	// The retries run outside the token interceptor, so that every attempt carries a
+	// valid token.
+	opts := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(retryInterceptor(policy, tokens)),
+	}, cfg.DialOptions...)
+	conn, err := polykeyclient.Dial(cfg.Address, tokens, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, tokens: tokens, keys: pk.NewPolykeyServiceClient(conn)}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Conn returns the connection, for the services the client has no methods for. Calls on
+// it are authenticated and retried like those of the client.
+func (c *Client) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
+// Keys returns the generated client of the key service, for the calls the client has no
+// methods for. Its requests need a RequesterContext, which Requester returns.
+func (c *Client) Keys() pk.PolykeyServiceClient {
+	return c.keys
+}
+
+// Requester returns the requester context of the authenticated client.
+func (c *Client) Requester(ctx context.Context) (*pk.RequesterContext, error) {
+	return c.tokens.Requester(ctx)
+}
+
+// CreateKey creates a key as described by req, as the authenticated client when req
+// names no requester.
+func (c *Client) CreateKey(ctx context.Context, req *pk.CreateKeyRequest) (*pk.CreateKeyResponse, error) {
+	if req.RequesterContext == nil {
+		requester, err := c.Requester(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.RequesterContext = requester
+	}
+	return c.keys.CreateKey(ctx, req)
+}
+
+// GetKey returns version of keyID, or its current version when version is zero.
+func (c *Client) GetKey(ctx context.Context, keyID string, version int32) (*pk.GetKeyResponse, error) {
+	requester, err := c.Requester(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.keys.GetKey(ctx, &pk.GetKeyRequest{KeyId: keyID, Version: version, RequesterContext: requester})
+}
+
+// GetKeyMetadata returns the metadata of the current version of keyID.
+func (c *Client) GetKeyMetadata(ctx context.Context, keyID string) (*pk.KeyMetadata, error) {
+	requester, err := c.Requester(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.keys.GetKeyMetadata(ctx, &pk.GetKeyMetadataRequest{KeyId: keyID, RequesterContext: requester})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetMetadata(), nil
+}
+
+// RotateKey creates a new version of keyID and returns its number.
+func (c *Client) RotateKey(ctx context.Context, keyID string) (int32, error) {
+	requester, err := c.Requester(ctx)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.keys.RotateKey(ctx, &pk.RotateKeyRequest{KeyId: keyID, RequesterContext: requester})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetNewVersion(), nil
+}
+
+// RevokeKey revokes keyID, with all its versions.
+func (c *Client) RevokeKey(ctx context.Context, keyID string) error {
+	requester, err := c.Requester(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = c.keys.RevokeKey(ctx, &pk.RevokeKeyRequest{KeyId: keyID, RequesterContext: requester})
+	return err
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/spounge-ai/polykey/pkg/memory"
+)
+
+// envelopeFormat is the first byte of a ciphertext of EncryptWithKey.
+const envelopeFormat byte = 1
+
+// ErrInvalidCiphertext is returned by DecryptWithKey for data that is not a ciphertext of
+// EncryptWithKey, or that was encrypted with another key or modified since.
+var ErrInvalidCiphertext = errors.New("client: invalid ciphertext")
+
+// EncryptWithKey encrypts plaintext with the current version of keyID, authenticating aad
+// along with it. The ciphertext names the key and version it was encrypted with, so that
+// it can be decrypted after the key rotates, as long as that version can still be read:
+//
+//	format (1) | key version (4) | key ID length (2) | key ID | nonce (12) | AES-256-GCM
+//
+// The AES-256-GCM key is derived with HKDF-SHA256 from the key material GetKey returns
+// for the version. Rewrapping keys under another KMS provider changes that material, so
+// data must be decrypted before its key is rewrapped and encrypted again after.
+func (c *Client) EncryptWithKey(ctx context.Context, keyID string, plaintext, aad []byte) ([]byte, error) {
+	if len(keyID) > math.MaxUint16 {
+		return nil, fmt.Errorf("client: key ID of %d bytes is too long", len(keyID))
+	}
+	key, err := c.GetKey(ctx, keyID, 0)
+	if err != nil {
+		return nil, err
+	}
+	version := key.GetMetadata().GetVersion()
+	aead, err := envelopeAEAD(key.GetKeyMaterial().GetEncryptedKeyData(), keyID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, 7+len(keyID)+aead.NonceSize())
+	header = append(header, envelopeFormat)
+	header = binary.BigEndian.AppendUint32(header, uint32(version))
+	header = binary.BigEndian.AppendUint16(header, uint16(len(keyID)))
+	header = append(header, keyID...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("client: failed to generate a nonce: %w", err)
+	}
+	header = append(header, nonce...)
+	return aead.Seal(header, nonce, plaintext, envelopeAAD(header, aad)), nil
+}
+
+// DecryptWithKey decrypts a ciphertext EncryptWithKey made with keyID and aad, reading
+// the key version it names.
+func (c *Client) DecryptWithKey(ctx context.Context, keyID string, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < 7 || ciphertext[0] != envelopeFormat {
+		return nil, ErrInvalidCiphertext
+	}
+	version := int32(binary.BigEndian.Uint32(ciphertext[1:5]))
+	idLen := int(binary.BigEndian.Uint16(ciphertext[5:7]))
+	if len(ciphertext) < 7+idLen || string(ciphertext[7:7+idLen]) != keyID || version <= 0 {
+		return nil, ErrInvalidCiphertext
+	}
+
+	key, err := c.GetKey(ctx, keyID, version)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := envelopeAEAD(key.GetKeyMaterial().GetEncryptedKeyData(), keyID, version)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := 7 + idLen + aead.NonceSize()
+	if len(ciphertext) < headerLen+aead.Overhead() {
+		return nil, ErrInvalidCiphertext
+	}
+	header := ciphertext[:headerLen]
+	plaintext, err := aead.Open(nil, header[headerLen-aead.NonceSize():], ciphertext[headerLen:], envelopeAAD(header, aad))
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+// envelopeAEAD derives the AES-256-GCM cipher of version of keyID from its material.
+func envelopeAEAD(material []byte, keyID string, version int32) (cipher.AEAD, error) {
+	if len(material) == 0 {
+		return nil, fmt.Errorf("client: key %s has no material", keyID)
+	}
+	secret, err := hkdf.Key(sha256.New, material, nil, "polykey envelope v1 "+keyID+" "+strconv.Itoa(int(version)), 32)
+	if err != nil {
+		return nil, fmt.Errorf("client: failed to derive the data key: %w", err)
+	}
+	defer memory.SecureZeroBytes(secret)
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// envelopeAAD authenticates the header of a ciphertext with the caller's aad.
+func envelopeAAD(header, aad []byte) []byte {
+	return append(append(make([]byte, 0, len(header)+len(aad)), header...), aad...)
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spounge-ai/polykey/internal/polykeyclient"
+	"github.com/spounge-ai/polykey/pkg/execution"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata a mutation carries. The key is the same for every attempt of a call, so that
+// a retry can be told from a new request; the signature, an HMAC-SHA256 keyed with the
+// attempt's access token over "<method>\n<key>\n<timestamp>", binds the key to the
+// client, the method and the time of the attempt.
+const (
+	IdempotencyKeyHeader       = "idempotency-key"
+	IdempotencyTimestampHeader = "idempotency-timestamp"
+	IdempotencySignatureHeader = "idempotency-signature"
+)
+
+// readMethods are the calls that change nothing, which are safe to run again.
+var readMethods = map[string]bool{
+	pk.PolykeyService_HealthCheck_FullMethodName:         true,
+	pk.PolykeyService_GetKey_FullMethodName:              true,
+	pk.PolykeyService_ListKeys_FullMethodName:            true,
+	pk.PolykeyService_GetKeyMetadata_FullMethodName:      true,
+	pk.PolykeyService_BatchGetKeys_FullMethodName:        true,
+	pk.PolykeyService_BatchGetKeyMetadata_FullMethodName: true,
+}
+
+// tokenMethods manage the access token itself, and are left to the token source.
+var tokenMethods = map[string]bool{
+	pk.PolykeyService_Authenticate_FullMethodName: true,
+	pk.PolykeyService_RefreshToken_FullMethodName: true,
+	pk.PolykeyService_RevokeToken_FullMethodName:  true,
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes the mutation called with ctx use key as its idempotency key,
+// instead of a new one, so that a caller repeating a request after a crash can reuse the
+// key of the first attempt.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// retryInterceptor retries the calls the server rejected before running them: reads when
+// the server is unavailable, overloaded or aborted them, and mutations only when it
+// rejected them as overloaded or rate limited, which happens before the handler runs.
+// A retry waits at least the delay the server's RetryInfo asks for.
+func retryInterceptor(policy execution.RetryPolicy, tokens *polykeyclient.TokenSource) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if tokenMethods[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		read := readMethods[method]
+		callPolicy := policy
+		callPolicy.Retryable = func(err error) bool {
+			switch status.Code(err) {
+			case codes.ResourceExhausted:
+				return true
+			case codes.Unavailable, codes.Aborted:
+				return read
+			}
+			return false
+		}
+		callPolicy.RetryAfter = retryDelay
+
+		key, _ := ctx.Value(idempotencyKey{}).(string)
+		if !read && key == "" {
+			key = uuid.NewString()
+		}
+		_, err := execution.Retry(ctx, callPolicy, func(ctx context.Context) (struct{}, error) {
+			if !read {
+				token, err := tokens.Token(ctx)
+				if err != nil {
+					return struct{}{}, err
+				}
+				timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+				ctx = metadata.AppendToOutgoingContext(ctx,
+					IdempotencyKeyHeader, key,
+					IdempotencyTimestampHeader, timestamp,
+					IdempotencySignatureHeader, SignIdempotencyKey(token, method, key, timestamp),
+				)
+			}
+			return struct{}{}, invoker(ctx, method, req, reply, cc, opts...)
+		})
+		return err
+	}
+}
+
+// retryDelay returns the delay of the google.rpc.RetryInfo of err, zero without one.
+func retryDelay(err error) time.Duration {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.GetRetryDelay().AsDuration()
+		}
+	}
+	return 0
+}
+
+// SignIdempotencyKey returns the signature of an idempotency key sent to method at
+// timestamp, in Unix seconds, with token.
+func SignIdempotencyKey(token, method, key, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(method + "\n" + key + "\n" + timestamp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,43 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyChecksumAlgorithm prefixes every key checksum, naming the digest it was computed
+// with, so that another one can be introduced without ambiguity.
+const KeyChecksumAlgorithm = "sha256"
+
+// ErrKeyChecksumMismatch is returned by VerifyKeyChecksum for key material that does not
+// match its checksum.
+var ErrKeyChecksumMismatch = errors.New("key checksum mismatch")
+
+// KeyChecksum returns the checksum of the plaintext key material of a key version,
+// "sha256:" followed by the hex SHA-256 digest of it. It identifies the material without
+// revealing it, so that clients can tell versions apart and check what they unwrapped.
+func KeyChecksum(plaintext []byte) string {
+	digest := sha256.Sum256(plaintext)
+	return KeyChecksumAlgorithm + ":" + hex.EncodeToString(digest[:])
+}
+
+// VerifyKeyChecksum checks plaintext against checksum, in constant time. It returns
+// ErrKeyChecksumMismatch if they differ, and an error naming the problem if checksum is
+// not of the form KeyChecksum produces.
+func VerifyKeyChecksum(plaintext []byte, checksum string) error {
+	algorithm, digest, ok := strings.Cut(checksum, ":")
+	if !ok || digest == "" {
+		return fmt.Errorf("key checksum %q is not of the form <algorithm>:<hex digest>", checksum)
+	}
+	if algorithm != KeyChecksumAlgorithm {
+		return fmt.Errorf("unsupported key checksum algorithm %q", algorithm)
+	}
+	if subtle.ConstantTimeCompare([]byte(KeyChecksum(plaintext)), []byte(checksum)) != 1 {
+		return ErrKeyChecksumMismatch
+	}
+	return nil
+}
@@ -2,35 +2,89 @@ package execution
 
 import (
 	"context"
+	"math/rand/v2"
 	"time"
-	"math/rand"
 )
 
 // RetryableFunc is a function that can be retried.
-// It returns a result of type T and an error._
-// The error should be nil if the function was successful._
+// It returns a result of type T and an error.
+// The error should be nil if the function was successful.
 type RetryableFunc[T any] func(ctx context.Context) (T, error)
 
-// WithRetry executes a function with a retry mechanism.
-// It uses exponential backoff with jitter to space out retries._
-func WithRetry[T any](ctx context.Context, maxRetries int, initialBackoff time.Duration, maxBackoff time.Duration, fn RetryableFunc[T]) (T, error) {
-	var result T
-	var err error
+// RetryPolicy describes how often and how far apart a failed call is attempted again.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, the first included. Values below 1
+	// mean a single attempt.
+	MaxAttempts int
+	// InitialBackoff is the longest wait before the second attempt; the bound doubles with
+	// each further attempt up to MaxBackoff. Each wait is drawn uniformly below the bound
+	// ("full jitter"), so that callers throttled together do not retry together.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Retryable reports whether an error is worth another attempt. Nil retries every error.
+	Retryable func(error) bool
+	// OnRetry, if set, is called before each wait with the number of the failed attempt.
+	OnRetry func(attempt int, err error)
+	// RetryAfter, if set, returns the least wait an error asks for, such as the delay a
+	// server gives in a google.rpc.RetryInfo. A longer backoff is kept.
+	RetryAfter func(error) time.Duration
+}
 
-	for i := 0; i < maxRetries; i++ {
-		result, err = fn(ctx)
-		if err == nil {
-			return result, nil
+// Retry calls fn until it succeeds, returns an error the policy does not retry, or the
+// attempts run out, and returns the last result. It never waits past ctx's deadline: when
+// the next wait would end after it, or ctx is done, the last error is returned at once.
+func Retry[T any](ctx context.Context, policy RetryPolicy, fn RetryableFunc[T]) (T, error) {
+	attempts := max(policy.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		result, err := fn(ctx)
+		if err == nil || attempt >= attempts || ctx.Err() != nil {
+			return result, err
+		}
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return result, err
 		}
 
-		backoff := initialBackoff * (1 << i) // Exponential backoff
-		if backoff > maxBackoff {
-			backoff = maxBackoff
+		wait := policy.backoff(attempt)
+		if policy.RetryAfter != nil {
+			wait = max(wait, policy.RetryAfter(err))
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return result, err
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
 		}
+	}
+}
 
-		jitter := time.Duration(rand.Intn(100)) * time.Millisecond
-		time.Sleep(backoff + jitter)
+// backoff returns the wait after the given failed attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	bound := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || bound < p.MaxBackoff); i++ {
+		bound *= 2
+	}
+	if p.MaxBackoff > 0 && bound > p.MaxBackoff {
+		bound = p.MaxBackoff
 	}
+	if bound <= 0 {
+		return 0
+	}
+	return rand.N(bound)
+}
 
-	return result, err
+// WithRetry executes a function up to maxRetries times, retrying every error with
+// exponential backoff and jitter between attempts.
+func WithRetry[T any](ctx context.Context, maxRetries int, initialBackoff time.Duration, maxBackoff time.Duration, fn RetryableFunc[T]) (T, error) {
+	return Retry(ctx, RetryPolicy{
+		MaxAttempts:    maxRetries,
+		InitialBackoff: initialBackoff,
+		MaxBackoff:     maxBackoff,
+	}, fn)
 }
@@ -0,0 +1,61 @@
+package memory
+
+import "runtime"
+
+// LockedBuffer holds secret bytes in memory locked into RAM, so that they are never
+// written to swap, whenever the platform and the process's memlock limit allow it, and
+// in ordinary memory otherwise. Destroy zeroes and releases it; a buffer that is never
+// destroyed is released once it is garbage collected, but is only zeroed by Destroy.
+type LockedBuffer struct {
+	buf     []byte
+	mapped  []byte
+	cleanup runtime.Cleanup
+}
+
+// NewLockedBuffer returns a zeroed buffer of size bytes.
+func NewLockedBuffer(size int) *LockedBuffer {
+	if size <= 0 {
+		return &LockedBuffer{buf: []byte{}}
+	}
+	mapped, ok := lockedAlloc(size)
+	if !ok {
+		return &LockedBuffer{buf: make([]byte, size)}
+	}
+	b := &LockedBuffer{buf: mapped[:size:size], mapped: mapped}
+	b.cleanup = runtime.AddCleanup(b, lockedFree, mapped)
+	return b
+}
+
+// MoveToLockedBuffer copies src into a new buffer and zeroes src, so that the secret is
+// only left in the buffer.
+func MoveToLockedBuffer(src []byte) *LockedBuffer {
+	b := NewLockedBuffer(len(src))
+	copy(b.buf, src)
+	SecureZeroBytes(src)
+	return b
+}
+
+// Bytes returns the contents of the buffer, which are only valid until Destroy.
+func (b *LockedBuffer) Bytes() []byte {
+	return b.buf
+}
+
+// Locked reports whether the buffer is locked into RAM, rather than having fallen back
+// to ordinary memory.
+func (b *LockedBuffer) Locked() bool {
+	return b.mapped != nil
+}
+
+// Destroy zeroes the buffer and releases its memory. It is safe to call more than once.
+func (b *LockedBuffer) Destroy() {
+	if b == nil {
+		return
+	}
+	SecureZeroBytes(b.buf)
+	b.buf = nil
+	if b.mapped != nil {
+		b.cleanup.Stop()
+		lockedFree(b.mapped)
+		b.mapped = nil
+	}
+}
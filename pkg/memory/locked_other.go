@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package memory
+
+// lockedAlloc is not available on this platform, where locked buffers fall back to
+// ordinary memory.
+func lockedAlloc(size int) ([]byte, bool) {
+	return nil, false
+}
+
+func lockedFree(mem []byte) {}
@@ -0,0 +1,28 @@
+//go:build linux || darwin
+
+package memory
+
+import "syscall"
+
+// lockedAlloc maps whole pages for size bytes and locks them into RAM. It reports false
+// when either fails, typically because the memlock limit is reached.
+func lockedAlloc(size int) ([]byte, bool) {
+	pageSize := syscall.Getpagesize()
+	length := (size + pageSize - 1) / pageSize * pageSize
+	mem, err := syscall.Mmap(-1, 0, length, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, false
+	}
+	if err := syscall.Mlock(mem); err != nil {
+		_ = syscall.Munmap(mem)
+		return nil, false
+	}
+	return mem, true
+}
+
+// lockedFree zeroes, unlocks and unmaps memory of lockedAlloc.
+func lockedFree(mem []byte) {
+	SecureZeroBytes(mem)
+	_ = syscall.Munlock(mem)
+	_ = syscall.Munmap(mem)
+}
@@ -1,35 +1,83 @@
 package memory
 
 import (
-	"sync"
+	"sync/atomic"
 )
 
-// SecureDEKPool is a sync.Pool for managing DEKs with proper cleanup.
+// DefaultDEKPoolCapacity is the number of idle buffers a SecureDEKPool keeps by default.
+const DefaultDEKPoolCapacity = 64
+
+// SecureDEKPool recycles DEK buffers of one size, zeroing every buffer as it comes back.
+// It keeps up to its capacity of idle buffers; Get allocates when none is idle, and Put
+// drops a buffer when the pool is full.
 type SecureDEKPool struct {
-	pool *sync.Pool
+	idle chan []byte
 	size int
+
+	gets   atomic.Int64
+	puts   atomic.Int64
+	misses atomic.Int64
+}
+
+// DEKPoolStats counts the use of a SecureDEKPool since it was created. Misses are the
+// gets that had to allocate; Outstanding is the buffers taken and not yet put back,
+// which only grows if callers forget to return them.
+type DEKPoolStats struct {
+	Gets        int64
+	Puts        int64
+	Misses      int64
+	Outstanding int64
+	Idle        int
 }
 
-// NewSecureDEKPool creates a new SecureDEKPool.
-func NewSecureDEKPool(size int) *SecureDEKPool {
-	return &SecureDEKPool{
-		size: size,
-		pool: &sync.Pool{
-			New: func() interface{} {
-				b := make([]byte, size)
-				return &b
-			},
-		},
+// NewSecureDEKPool creates a pool of buffers of size bytes, keeping up to capacity of
+// them idle; a capacity of zero or less takes DefaultDEKPoolCapacity.
+func NewSecureDEKPool(size, capacity int) *SecureDEKPool {
+	if capacity <= 0 {
+		capacity = DefaultDEKPoolCapacity
 	}
+	return &SecureDEKPool{idle: make(chan []byte, capacity), size: size}
 }
 
-// Get gets a buffer from the pool.
+// Get gets a zeroed buffer from the pool.
 func (p *SecureDEKPool) Get() []byte {
-	return *p.pool.Get().(*[]byte)
+	p.gets.Add(1)
+	select {
+	case buf := <-p.idle:
+		return buf
+	default:
+		p.misses.Add(1)
+		return make([]byte, p.size)
+	}
 }
 
-// Put returns a buffer to the pool.
+// Put zeroes buf, up to its capacity, and returns it to the pool. Buffers of another
+// size are zeroed and dropped.
 func (p *SecureDEKPool) Put(buf []byte) {
-	SecureZeroBytes(buf) // Always zero before returning
-	p.pool.Put(&buf)
-}
\ No newline at end of file
+	SecureZeroBytes(buf[:cap(buf)]) // Always zero before returning
+	p.puts.Add(1)
+	if len(buf) != p.size || cap(buf) != p.size {
+		return
+	}
+	select {
+	case p.idle <- buf:
+	default:
+	}
+}
+
+// Size returns the length of the buffers of the pool.
+func (p *SecureDEKPool) Size() int {
+	return p.size
+}
+
+// Stats returns the counts of the pool.
+func (p *SecureDEKPool) Stats() DEKPoolStats {
+	gets, puts := p.gets.Load(), p.puts.Load()
+	return DEKPoolStats{
+		Gets:        gets,
+		Puts:        puts,
+		Misses:      p.misses.Load(),
+		Outstanding: gets - puts,
+		Idle:        len(p.idle),
+	}
+}
@@ -2,7 +2,9 @@ package batch
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 )
 
 // BatchItem represents a single item in a batch operation.
@@ -39,6 +41,8 @@ type BatchProcessor[TRequest, TResult any] struct {
 	MaxConcurrency int
 	Validate      func(TRequest) error
 	Process       func(context.Context, TRequest) (TResult, error)
+	// ObserveWait, when set, is called with how long each request waited for a slot.
+	ObserveWait func(time.Duration)
 }
 
 // ProcessBatch processes a batch of requests.
@@ -59,12 +63,23 @@ func (bp *BatchProcessor[TRequest, TResult]) ProcessBatch(
 	semaphore := make(chan struct{}, bp.MaxConcurrency)
 	
 	var wg sync.WaitGroup
+	start := time.Now()
 	for i, req := range requests {
 		wg.Add(1)
 		go func(index int, request TRequest) {
 			defer wg.Done()
+			// A panicking request fails alone, after its deferred cleanup, such as the
+			// zeroing of key material, has run, instead of taking down the process.
+			defer func() {
+				if r := recover(); r != nil {
+					results[index] = BatchItem[TResult]{Error: fmt.Errorf("batch: request %d panicked: %v", index, r)}
+				}
+			}()
 			semaphore <- struct{}{} // Acquire
 			defer func() { <-semaphore }() // Release
+			if bp.ObserveWait != nil {
+				bp.ObserveWait(time.Since(start))
+			}
 			
 			if err := bp.Validate(request); err != nil {
 				results[index] = BatchItem[TResult]{Error: err}
@@ -79,3 +94,73 @@ func (bp *BatchProcessor[TRequest, TResult]) ProcessBatch(
 	wg.Wait()
 	return &BatchResult[TResult]{Items: results}, nil
 }
+
+// StreamBatch processes requests like ProcessBatch, but hands each result to emit as
+// soon as it is ready instead of collecting them, so that a large batch is never held in
+// memory whole. emit is called from one goroutine at a time, in completion order, with
+// the index of the request. When emit fails, the requests still waiting for a slot are
+// given up, each with the context's error, and StreamBatch returns emit's error once the
+// running ones are done.
+func (bp *BatchProcessor[TRequest, TResult]) StreamBatch(
+	ctx context.Context,
+	requests []TRequest,
+	emit func(int, BatchItem[TResult]) error,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type completion struct {
+		index int
+		item  BatchItem[TResult]
+	}
+	completions := make(chan completion)
+	semaphore := make(chan struct{}, bp.MaxConcurrency)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i, req := range requests {
+		wg.Add(1)
+		go func(index int, request TRequest) {
+			defer wg.Done()
+			var item BatchItem[TResult]
+			defer func() {
+				if r := recover(); r != nil {
+					item = BatchItem[TResult]{Error: fmt.Errorf("batch: request %d panicked: %v", index, r)}
+				}
+				completions <- completion{index: index, item: item}
+			}()
+			select {
+			case semaphore <- struct{}{}: // Acquire
+			case <-ctx.Done():
+				item.Error = ctx.Err()
+				return
+			}
+			defer func() { <-semaphore }() // Release
+			if bp.ObserveWait != nil {
+				bp.ObserveWait(time.Since(start))
+			}
+
+			if err := bp.Validate(request); err != nil {
+				item.Error = err
+				return
+			}
+			item.Result, item.Error = bp.Process(ctx, request)
+		}(i, req)
+	}
+	go func() {
+		wg.Wait()
+		close(completions)
+	}()
+
+	var emitErr error
+	for c := range completions {
+		if emitErr != nil {
+			continue
+		}
+		if err := emit(c.index, c.item); err != nil {
+			emitErr = err
+			cancel()
+		}
+	}
+	return emitErr
+}
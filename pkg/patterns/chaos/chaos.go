@@ -0,0 +1,92 @@
+// Package chaos injects faults into calls to a dependency, so that the retries, circuit
+// breakers and degraded modes built around it can be exercised under controlled
+// failure. It is meant for tests and development, never production.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrInjected is wrapped by every fault an Injector injects.
+var ErrInjected = errors.New("injected fault")
+
+// Faults describes the faults to inject.
+type Faults struct {
+	// Latency delays every call; up to Jitter more is added at random.
+	Latency time.Duration
+	Jitter  time.Duration
+	// ErrorRate is the fraction of calls that fail, from 0 to 1.
+	ErrorRate float64
+	// ItemFailureRate is the fraction of the items of a batch that fail, from 0 to 1.
+	ItemFailureRate float64
+	// Seed makes the injected faults repeatable; 0 draws them from a random source.
+	Seed uint64
+}
+
+// Injector decides which calls are delayed and which fail. It is safe for concurrent
+// use.
+type Injector struct {
+	faults Faults
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewInjector creates an Injector of faults.
+func NewInjector(faults Faults) *Injector {
+	seed := faults.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Injector{faults: faults, rand: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Inject delays a call and decides whether it fails, returning an error wrapping
+// ErrInjected when it does, or the error of ctx when it ends first.
+func (i *Injector) Inject(ctx context.Context) error {
+	if err := i.delay(ctx); err != nil {
+		return err
+	}
+	if i.chance(i.faults.ErrorRate) {
+		return ErrInjected
+	}
+	return nil
+}
+
+// FailItem decides whether an item of a batch fails.
+func (i *Injector) FailItem() bool {
+	return i.chance(i.faults.ItemFailureRate)
+}
+
+func (i *Injector) delay(ctx context.Context) error {
+	d := i.faults.Latency
+	if i.faults.Jitter > 0 {
+		i.mu.Lock()
+		d += time.Duration(i.rand.Int64N(int64(i.faults.Jitter)))
+		i.mu.Unlock()
+	}
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (i *Injector) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Float64() < rate
+}
@@ -15,6 +15,19 @@ const (
 	StateHalfOpen
 )
 
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
 var ErrOpen = errors.New("circuit breaker is open")
 var ErrTimeout = errors.New("circuit breaker operation timed out")
 
@@ -24,17 +37,20 @@ type StateChangeCallback func(from, to State)
 // Breaker is a generic, thread-safe, context-aware circuit breaker.
 type Breaker[T any] struct {
 	// Configuration
-	maxFailures      int64
-	resetTimeout     time.Duration
+	maxFailures      atomic.Int64
+	resetTimeout     atomic.Int64 // Nanoseconds
 	callTimeout      time.Duration
 	halfOpenRequests int64
 	onStateChange    StateChangeCallback
+	isFailure        func(error) bool
 
 	// Internal state
 	state           atomic.Int32
 	failures        atomic.Int64
 	lastFailureTime atomic.Int64 // Unix nano
 	successCount    atomic.Int64
+	trips           atomic.Int64
+	forced          atomic.Bool // Opened by Trip; held open until Reset
 }
 
 // Option configures a Breaker.
@@ -43,7 +59,7 @@ type Option[T any] func(*Breaker[T])
 // WithResetTimeout sets the duration the breaker remains open before transitioning to half-open.
 func WithResetTimeout[T any](d time.Duration) Option[T] {
 	return func(b *Breaker[T]) {
-		b.resetTimeout = d
+		b.resetTimeout.Store(int64(d))
 	}
 }
 
@@ -70,15 +86,24 @@ func WithStateChangeCallback[T any](cb StateChangeCallback) Option[T] {
 	}
 }
 
+// WithFailurePredicate sets which errors count towards opening the breaker. Errors it
+// rejects are still returned to the caller, but count as the dependency answering. By
+// default every error counts.
+func WithFailurePredicate[T any](isFailure func(error) bool) Option[T] {
+	return func(b *Breaker[T]) {
+		b.isFailure = isFailure
+	}
+}
+
 // New creates a new generic Circuit Breaker.
 func New[T any](maxFailures int, opts ...Option[T]) *Breaker[T] {
 	b := &Breaker[T]{
-		maxFailures:      int64(maxFailures),
-		resetTimeout:     5 * time.Second, // Default reset timeout
 		callTimeout:      2 * time.Second, // Default call timeout
 		halfOpenRequests: 1,
 		onStateChange:    func(from, to State) {}, // No-op callback by default
 	}
+	b.maxFailures.Store(int64(maxFailures))
+	b.resetTimeout.Store(int64(5 * time.Second)) // Default reset timeout
 
 	for _, opt := range opts {
 		opt(b)
@@ -116,7 +141,11 @@ func (b *Breaker[T]) Execute(ctx context.Context, fn func(ctx context.Context) (
 		b.recordResult(nil)
 		return result, nil
 	case err := <-errChan:
-		b.recordResult(err)
+		if b.isFailure != nil && !b.isFailure(err) {
+			b.recordResult(nil)
+		} else {
+			b.recordResult(err)
+		}
 		return zero, err
 	case <-callCtx.Done():
 		// Check if the cancellation came from our timeout or the parent context.
@@ -131,6 +160,61 @@ func (b *Breaker[T]) Execute(ctx context.Context, fn func(ctx context.Context) (
 	}
 }
 
+// State returns the breaker's current state.
+func (b *Breaker[T]) State() State {
+	return State(b.state.Load())
+}
+
+// Failures returns the number of failures counted towards opening the breaker.
+func (b *Breaker[T]) Failures() int64 {
+	return b.failures.Load()
+}
+
+// Trips returns how many times the breaker has opened, including by Trip.
+func (b *Breaker[T]) Trips() int64 {
+	return b.trips.Load()
+}
+
+// Forced reports whether the breaker is held open by Trip.
+func (b *Breaker[T]) Forced() bool {
+	return b.forced.Load()
+}
+
+// SetLimits changes the number of failures that open the breaker and how long it stays
+// open, taking effect from the next call.
+func (b *Breaker[T]) SetLimits(maxFailures int, resetTimeout time.Duration) {
+	b.maxFailures.Store(int64(maxFailures))
+	b.resetTimeout.Store(int64(resetTimeout))
+}
+
+// Trip opens the breaker and holds it open, without moving to half-open after the
+// reset timeout, until Reset is called.
+func (b *Breaker[T]) Trip() {
+	b.forced.Store(true)
+	b.lastFailureTime.Store(time.Now().UnixNano())
+	for {
+		current := State(b.state.Load())
+		if current == StateOpen || b.transition(current, StateOpen) {
+			return
+		}
+	}
+}
+
+// Reset closes the breaker and clears its failure count, releasing a Trip.
+func (b *Breaker[T]) Reset() {
+	b.forced.Store(false)
+	for {
+		current := State(b.state.Load())
+		if current == StateClosed {
+			b.failures.Store(0)
+			return
+		}
+		if b.transition(current, StateClosed) {
+			return
+		}
+	}
+}
+
 func (b *Breaker[T]) canExecute() bool {
 	currentState := State(b.state.Load())
 
@@ -138,8 +222,11 @@ func (b *Breaker[T]) canExecute() bool {
 	case StateClosed:
 		return true
 	case StateOpen:
+		if b.forced.Load() {
+			return false
+		}
 		// Check if the reset timeout has passed.
-		if time.Now().UnixNano() > b.lastFailureTime.Load()+b.resetTimeout.Nanoseconds() {
+		if time.Now().UnixNano() > b.lastFailureTime.Load()+b.resetTimeout.Load() {
 			b.transition(StateOpen, StateHalfOpen)
 			return true
 		}
@@ -159,7 +246,7 @@ func (b *Breaker[T]) recordResult(err error) {
 		b.lastFailureTime.Store(time.Now().UnixNano())
 
 		currentState := State(b.state.Load())
-		if currentState == StateHalfOpen || (currentState == StateClosed && newFailures >= b.maxFailures) {
+		if currentState == StateHalfOpen || (currentState == StateClosed && newFailures >= b.maxFailures.Load()) {
 			b.transition(currentState, StateOpen)
 		}
 	} else {
@@ -176,11 +263,12 @@ func (b *Breaker[T]) recordResult(err error) {
 	}
 }
 
-func (b *Breaker[T]) transition(from, to State) {
+func (b *Breaker[T]) transition(from, to State) bool {
 	if b.state.CompareAndSwap(int32(from), int32(to)) {
 		// Reset counters on state change.
 		switch to {
 		case StateOpen:
+			b.trips.Add(1)
 			b.successCount.Store(0)
 		case StateHalfOpen:
 			b.successCount.Store(0)
@@ -191,5 +279,7 @@ func (b *Breaker[T]) transition(from, to State) {
 
 		// Fire the callback.
 		b.onStateChange(from, to)
+		return true
 	}
+	return false
 }
@@ -0,0 +1,58 @@
+package bench_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/spounge-ai/polykey/pkg/patterns/batch"
+)
+
+var errOdd = errors.New("odd request")
+
+// newProcessor returns a processor failing odd requests, with the concurrency of the
+// key service's batch operations.
+func newProcessor() *batch.BatchProcessor[int, int] {
+	return &batch.BatchProcessor[int, int]{
+		MaxConcurrency: 10,
+		Validate:       func(int) error { return nil },
+		Process: func(_ context.Context, n int) (int, error) {
+			if n%2 == 1 {
+				return 0, errOdd
+			}
+			return n * 2, nil
+		},
+	}
+}
+
+func BenchmarkBatchProcessor(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range []int{10, 100, 1000} {
+		requests := make([]int, size)
+		for i := range requests {
+			requests[i] = i
+		}
+
+		b.Run(fmt.Sprintf("op=process/items=%d", size), func(b *testing.B) {
+			processor := newProcessor()
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := processor.ProcessBatch(ctx, requests, true); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("op=stream/items=%d", size), func(b *testing.B) {
+			processor := newProcessor()
+			emit := func(int, batch.BatchItem[int]) error { return nil }
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := processor.StreamBatch(ctx, requests, emit); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
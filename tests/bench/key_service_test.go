@@ -0,0 +1,221 @@
+// Package bench_test holds benchmarks of the hot paths of the key service, so that a
+// refactor made for performance comes with numbers from before and after it:
+//
+//	go test ./tests/bench -run '^$' -bench . -count 10 > old.txt
+//	benchstat old.txt new.txt
+//
+// They run against an in-memory repository that stores rows as Postgres returns them,
+// so reads pay for decoding metadata, and need no containers.
+package bench_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/infra/persistence"
+	"github.com/spounge-ai/polykey/internal/kms"
+	"github.com/spounge-ai/polykey/internal/service"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+)
+
+var requester = &pk.RequesterContext{ClientIdentity: "bench-client"}
+
+// rowRepository is a KeyRepository keeping the latest version of each key as the row
+// Postgres returns for it. Only the methods creating and reading single keys are
+// implemented; the others panic.
+type rowRepository struct {
+	domain.KeyRepository
+
+	mu   sync.RWMutex
+	rows map[domain.KeyID]keyRow
+}
+
+func newRowRepository() *rowRepository {
+	return &rowRepository{rows: make(map[domain.KeyID]keyRow)}
+}
+
+func (r *rowRepository) CreateKey(_ context.Context, key *domain.Key) error {
+	row, err := newKeyRow(key)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rows[key.ID] = row
+	return nil
+}
+
+func (r *rowRepository) GetKey(_ context.Context, id domain.KeyID) (*domain.Key, error) {
+	r.mu.RLock()
+	row, ok := r.rows[id]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("key %s not found", id)
+	}
+	key, err := persistence.ScanKeyRow(row, integrity)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = id
+	return key, nil
+}
+
+func (r *rowRepository) CountKeys(context.Context, string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.rows), nil
+}
+
+// discardAudit drops audit events.
+type discardAudit struct{}
+
+func (discardAudit) AuditLog(context.Context, string, string, string, string, bool, error) {}
+
+// newKeyService returns a key service reading keys from repo and wrapping them with the
+// local KMS provider.
+func newKeyService(b *testing.B, repo domain.KeyRepository) service.KeyService {
+	masterKey := make([]byte, 32)
+	if _, err := rand.Read(masterKey); err != nil {
+		b.Fatal(err)
+	}
+	local, err := kms.NewLocalKMSProvider(base64.StdEncoding.EncodeToString(masterKey))
+	if err != nil {
+		b.Fatal(err)
+	}
+	logger := slog.New(slog.DiscardHandler)
+	cfg := &infra_config.Config{DefaultKMSProvider: "local"}
+	return service.NewKeyService(cfg, repo, map[string]kms.KMSProvider{"local": local}, logger,
+		app_errors.NewErrorClassifier(logger), discardAudit{}, nil, nil, nil, clock.System())
+}
+
+// createKeys creates n keys with svc, returning their IDs.
+func createKeys(b *testing.B, svc service.KeyService, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		resp, err := svc.CreateKey(context.Background(), &pk.CreateKeyRequest{
+			KeyType:          pk.KeyType_KEY_TYPE_AES_256,
+			Description:      "benchmark key",
+			Tags:             map[string]string{"team": "platform", "env": "bench"},
+			RequesterContext: requester,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		ids[i] = resp.GetKeyId()
+	}
+	return ids
+}
+
+func BenchmarkGetKey(b *testing.B) {
+	ctx := context.Background()
+
+	b.Run("cache=hit", func(b *testing.B) {
+		svc := newKeyService(b, persistence.NewCachedRepository(newRowRepository(), slog.New(slog.DiscardHandler)))
+		id := createKeys(b, svc, 1)[0]
+		req := &pk.GetKeyRequest{KeyId: id, RequesterContext: requester}
+		if _, err := svc.GetKey(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for range b.N {
+			if _, err := svc.GetKey(ctx, req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// Each read is of a key not read before, so it misses the cache and decodes a row.
+	b.Run("cache=miss", func(b *testing.B) {
+		svc := newKeyService(b, persistence.NewCachedRepository(newRowRepository(), slog.New(slog.DiscardHandler)))
+		ids := createKeys(b, svc, b.N)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := range b.N {
+			if _, err := svc.GetKey(ctx, &pk.GetKeyRequest{KeyId: ids[i], RequesterContext: requester}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCreateKey(b *testing.B) {
+	svc := newKeyService(b, newRowRepository())
+	req := &pk.CreateKeyRequest{
+		KeyType:          pk.KeyType_KEY_TYPE_AES_256,
+		Description:      "benchmark key",
+		Tags:             map[string]string{"team": "platform", "env": "bench"},
+		RequesterContext: requester,
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := svc.CreateKey(context.Background(), req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// keyRow is a row of the keys table, in the columns ScanKeyRow reads.
+type keyRow []any
+
+func newKeyRow(key *domain.Key) (keyRow, error) {
+	metadata, err := marshalMetadata(key.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	var kmsProvider *string
+	if key.KMSProvider != "" {
+		kmsProvider = &key.KMSProvider
+	}
+	return keyRow{
+		key.Version,
+		metadata,
+		key.EncryptedDEK,
+		key.Status,
+		key.Metadata.GetStorageType().String(),
+		key.CreatedAt,
+		key.UpdatedAt,
+		key.RevokedAt,
+		key.GraceExpiresAt,
+		key.Namespace,
+		kmsProvider,
+	}, nil
+}
+
+// Scan copies the columns of r into dest, as pgx does for the types ScanKeyRow scans.
+func (r keyRow) Scan(dest ...any) error {
+	if len(dest) != len(r) {
+		return fmt.Errorf("scanning %d columns into %d values", len(r), len(dest))
+	}
+	for i, value := range r {
+		switch d := dest[i].(type) {
+		case *int32:
+			*d = value.(int32)
+		case *[]byte:
+			*d = append([]byte(nil), value.([]byte)...)
+		case *domain.KeyStatus:
+			*d = value.(domain.KeyStatus)
+		case *string:
+			*d = value.(string)
+		case *time.Time:
+			*d = value.(time.Time)
+		case **time.Time:
+			*d = value.(*time.Time)
+		case **string:
+			*d = value.(*string)
+		default:
+			return fmt.Errorf("cannot scan column %d into %T", i, dest[i])
+		}
+	}
+	return nil
+}
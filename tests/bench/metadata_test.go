@@ -0,0 +1,103 @@
+package bench_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/spounge-ai/polykey/internal/infra/persistence"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var optimizer = persistence.NewQueryOptimizer()
+
+// integrity keys the metadata checksums of the benchmarks.
+var integrity = func() *domain.MetadataIntegrity {
+	integrity, err := domain.NewMetadataIntegrity("YmVuY2htYXJrLW1ldGFkYXRhLWludGVncml0eS1rZXk=")
+	if err != nil {
+		panic(err)
+	}
+	return integrity
+}()
+
+// marshalMetadata encodes metadata for the metadata column, with its checksum, as the
+// Postgres adapter writes it.
+func marshalMetadata(metadata *pk.KeyMetadata) ([]byte, error) {
+	metadata.MetadataChecksum = integrity.Checksum(metadata)
+	return optimizer.MarshalWithBuffer(metadata)
+}
+
+// benchKey returns a key with the metadata of a typical production key.
+func benchKey() *domain.Key {
+	now := time.Now()
+	return &domain.Key{
+		ID:           domain.NewKeyID(),
+		Namespace:    domain.DefaultNamespace,
+		Version:      3,
+		EncryptedDEK: make([]byte, 60),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		KMSProvider:  "local",
+		Metadata: &pk.KeyMetadata{
+			KeyType:            pk.KeyType_KEY_TYPE_AES_256,
+			Status:             pk.KeyStatus_KEY_STATUS_ACTIVE,
+			Version:            3,
+			CreatedAt:          timestamppb.New(now),
+			UpdatedAt:          timestamppb.New(now),
+			ExpiresAt:          timestamppb.New(now.Add(365 * 24 * time.Hour)),
+			CreatorIdentity:    "payments-service",
+			AuthorizedContexts: []string{"payments", "billing.eu-west-1", "ledger"},
+			AccessPolicies:     map[string]string{"exportable": "true", "max_uses": "1000"},
+			Description:        "Envelope key for payment tokens",
+			Tags:               map[string]string{"team": "payments", "env": "production", "cost-center": "cc-1042"},
+			DataClassification: "confidential",
+		},
+	}
+}
+
+func BenchmarkMetadata(b *testing.B) {
+	key := benchKey()
+
+	b.Run("op=marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := marshalMetadata(key.Metadata); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("op=unmarshal", func(b *testing.B) {
+		raw, err := marshalMetadata(key.Metadata)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for b.Loop() {
+			var metadata pk.KeyMetadata
+			if err := json.Unmarshal(raw, &metadata); err != nil {
+				b.Fatal(err)
+			}
+			if err := integrity.Verify(&metadata); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// Scanning a whole key row decodes and verifies its metadata, as every uncached read does.
+	b.Run("op=scan", func(b *testing.B) {
+		row, err := newKeyRow(key)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := persistence.ScanKeyRow(row, integrity); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package fuzz_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzKeyIDFromString(f *testing.F) {
+	for _, seed := range []string{
+		"3f2504e0-4f89-41d3-9a0c-0305e82c3301",
+		"3F2504E0-4F89-41D3-9A0C-0305E82C3301",
+		"{3f2504e0-4f89-41d3-9a0c-0305e82c3301}",
+		"urn:uuid:3f2504e0-4f89-41d3-9a0c-0305e82c3301",
+		"3f2504e04f8941d39a0c0305e82c3301",
+		"00000000-0000-0000-0000-000000000000",
+		"3f2504e0-4f89-41d3-9a0c-0305e82c330",
+		"3f2504e0-4f89-41d3-9a0c-0305e82c3301\n",
+		"",
+	} {
+		f.Add(seed)
+	}
+	v := newValidator(f)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		id, err := domain.KeyIDFromString(s)
+		if err == nil {
+			// Every spelling of an ID parses to the one its canonical form does.
+			canonical := id.String()
+			require.Len(t, canonical, 36)
+			require.Equal(t, strings.ToLower(canonical), canonical)
+			again, err := domain.KeyIDFromString(canonical)
+			require.NoError(t, err)
+			require.Equal(t, id, again)
+
+			data, err := json.Marshal(id)
+			require.NoError(t, err)
+			var decoded domain.KeyID
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			require.Equal(t, id, decoded)
+		}
+
+		// The validator admits only canonical IDs, so the ID a request names is the one
+		// that is stored, logged and audited.
+		verr := v.ValidateGetKeyRequest(context.Background(), &pk.GetKeyRequest{
+			KeyId:            s,
+			RequesterContext: &pk.RequesterContext{ClientIdentity: "fuzz"},
+		})
+		if verr == nil {
+			require.NoError(t, err, "validator accepted key id %q", s)
+			require.Equal(t, s, id.String())
+		}
+	})
+}
@@ -0,0 +1,425 @@
+package integration_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/domain"
+	infra_audit "github.com/spounge-ai/polykey/internal/infra/audit"
+	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	infra_events "github.com/spounge-ai/polykey/internal/infra/events"
+	"github.com/spounge-ai/polykey/internal/infra/webhook"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/peer"
+)
+
+func TestKafkaSink_DeadLetter(t *testing.T) {
+	deadLetterPath := filepath.Join(t.TempDir(), "audit-deadletter.jsonl")
+	sink, err := infra_audit.NewKafkaSink(slog.Default(), infra_audit.KafkaSinkConfig{
+		// Nothing listens on port 1, so every delivery attempt fails.
+		Brokers:        []string{"127.0.0.1:1"},
+		Topic:          "polykey.audit",
+		MaxRetries:     1,
+		RetryBackoff:   10 * time.Millisecond,
+		WriteTimeout:   time.Second,
+		DeadLetterPath: deadLetterPath,
+	})
+	require.NoError(t, err)
+	defer func() { _ = sink.Close() }()
+
+	events := []*domain.AuditEvent{
+		{ID: "evt-1", ClientIdentity: "polykey-dev-client", Operation: "CreateKey", KeyID: "key-1", Success: true, Timestamp: time.Now().UTC()},
+		{ID: "evt-2", ClientIdentity: "polykey-dev-client", Operation: "RevokeKey", KeyID: "key-1", Success: false, Error: "denied", Timestamp: time.Now().UTC()},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, sink.Publish(ctx, events))
+
+	f, err := os.Open(deadLetterPath)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record struct {
+			ID string `json:"id"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		ids = append(ids, record.ID)
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, []string{"evt-1", "evt-2"}, ids)
+}
+
+// blockingSink is an audit sink whose Publish blocks until released or cancelled.
+type blockingSink struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSink) Name() string { return "blocking" }
+
+func (s *blockingSink) Publish(ctx context.Context, _ []*domain.AuditEvent) error {
+	s.started <- struct{}{}
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+func TestQueuedSink_Overflow(t *testing.T) {
+	deadLetterPath := filepath.Join(t.TempDir(), "audit-deadletter.jsonl")
+	sink := &blockingSink{started: make(chan struct{}, 4), release: make(chan struct{})}
+	queued := infra_audit.NewQueuedSink(slog.Default(), sink, infra_audit.QueuedSinkConfig{
+		QueueSize:      1,
+		DeadLetterPath: deadLetterPath,
+		DrainTimeout:   50 * time.Millisecond,
+	})
+	event := func(id string) []*domain.AuditEvent {
+		return []*domain.AuditEvent{{ID: id, Operation: "GetKey", Success: true, Timestamp: time.Now().UTC()}}
+	}
+	ctx := context.Background()
+
+	// The first batch is being delivered and the second fills the queue, so the third
+	// goes to the dead-letter file without waiting for the sink.
+	require.NoError(t, queued.Publish(ctx, event("evt-1")))
+	<-sink.started
+	require.NoError(t, queued.Publish(ctx, event("evt-2")))
+	require.NoError(t, queued.Publish(ctx, event("evt-3")))
+
+	// The sink never finishes, so Close cancels it and dead-letters the queued batch.
+	require.NoError(t, queued.Close())
+	require.NoError(t, queued.Publish(ctx, event("evt-4")))
+
+	data, err := os.ReadFile(deadLetterPath)
+	require.NoError(t, err)
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record struct {
+			Sink string `json:"sink"`
+			ID   string `json:"id"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		require.Equal(t, "blocking", record.Sink)
+		ids = append(ids, record.ID)
+	}
+	require.Equal(t, []string{"evt-3", "evt-2", "evt-4"}, ids)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	const secret = "test-signing-secret"
+	received := make(chan webhook.Payload, 4)
+	attempts := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt to exercise the retry.
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "sha256="+webhook.Sign(secret, r.Header.Get(webhook.HeaderTimestamp), body), r.Header.Get(webhook.HeaderSignature))
+
+		var payload webhook.Payload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		require.Equal(t, payload.Type, r.Header.Get(webhook.HeaderEvent))
+		received <- payload
+	}))
+	defer server.Close()
+
+	notifier, err := webhook.NewNotifier(slog.Default(), infra_config.WebhooksConfig{
+		Endpoints: []infra_config.WebhookEndpointConfig{{
+			Name:   "test",
+			URL:    server.URL,
+			Secret: secret,
+			Events: []string{webhook.EventKeyRevoked, webhook.EventAuthzDenied},
+		}},
+		MaxRetries:     2,
+		InitialBackoff: 10 * time.Millisecond,
+		QueueSize:      10,
+		Workers:        1,
+	}, server.Client())
+	require.NoError(t, err)
+
+	broker := infra_events.NewBroker(slog.Default(), 0)
+	notifier.Start(broker)
+
+	ctx := context.Background()
+	// Not subscribed, so only the revocation is delivered.
+	broker.Publish(ctx, domain.KeyEvent{Type: domain.KeyEventCreated, KeyID: "key-1", OccurredAt: time.Now()})
+	broker.Publish(ctx, domain.KeyEvent{Type: domain.KeyEventRevoked, KeyID: "key-1", Version: 1, OccurredAt: time.Now()})
+
+	payload := <-received
+	require.Equal(t, webhook.EventKeyRevoked, payload.Type)
+	require.Equal(t, "key-1", payload.KeyID)
+
+	require.NoError(t, notifier.Publish(ctx, []*domain.AuditEvent{
+		{ID: "evt-1", ClientIdentity: "polykey-dev-client", Operation: "RevokeKey", KeyID: "key-1", Success: false},
+		{ID: "evt-2", ClientIdentity: "polykey-dev-client", Operation: "keys:read", KeyID: "key-1", Success: false, Error: "insufficient_key_permissions"},
+	}))
+	require.NoError(t, notifier.Close())
+
+	payload = <-received
+	require.Equal(t, webhook.EventAuthzDenied, payload.Type)
+	require.Equal(t, "evt-2", payload.ID)
+	require.Equal(t, "insufficient_key_permissions", payload.Reason)
+	require.Empty(t, received)
+}
+
+func TestWebhookNotifier_ShutdownTimeout(t *testing.T) {
+	// The receiver does not answer before the test ends, so the delivery in progress only
+	// ends when cancelled.
+	requests := make(chan struct{}, 4)
+	release := make(chan struct{})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	notifier, err := webhook.NewNotifier(slog.Default(), infra_config.WebhooksConfig{
+		Endpoints:       []infra_config.WebhookEndpointConfig{{Name: "test", URL: server.URL, Secret: "test-signing-secret"}},
+		MaxRetries:      5,
+		InitialBackoff:  time.Hour,
+		QueueSize:       10,
+		Workers:         1,
+		ShutdownTimeout: 100 * time.Millisecond,
+	}, server.Client())
+	require.NoError(t, err)
+
+	denied := func(id string) []*domain.AuditEvent {
+		return []*domain.AuditEvent{{ID: id, Operation: "keys:read", Success: false}}
+	}
+	ctx := context.Background()
+	require.NoError(t, notifier.Publish(ctx, denied("evt-1")))
+	<-requests
+	require.NoError(t, notifier.Publish(ctx, denied("evt-2")))
+
+	// Close gives up on the hung delivery and its hour of backoff, and drops the queued one.
+	started := time.Now()
+	require.NoError(t, notifier.Close())
+	require.Less(t, time.Since(started), 5*time.Second)
+	require.Empty(t, requests)
+}
+
+func TestSyslogSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+
+	received := make(chan string, 4)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		// Messages are framed by octet counting: the length, a space, then the message.
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(length))
+			if err != nil {
+				return
+			}
+			message := make([]byte, n)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				return
+			}
+			received <- string(message)
+		}
+	}()
+
+	events := []*domain.AuditEvent{
+		{ID: "evt-1", ClientIdentity: "polykey-dev-client", Operation: "CreateKey", KeyID: "key-1", Success: true, Timestamp: time.Now().UTC(), Sequence: 7, Hash: "abc"},
+		{ID: "evt-2", ClientIdentity: "polykey-dev-client", Operation: "RevokeKey", KeyID: "key-1", Success: false, Error: `denied "key]=1"`, Timestamp: time.Now().UTC()},
+	}
+
+	sink, err := infra_audit.NewSyslogSink(slog.Default(), infra_audit.SyslogSinkConfig{
+		Network:      "tcp",
+		Address:      listener.Addr().String(),
+		Facility:     13,
+		Hostname:     "polykey-test",
+		WriteTimeout: time.Second,
+	})
+	require.NoError(t, err)
+	require.NoError(t, sink.Publish(context.Background(), events))
+
+	message := <-received
+	require.True(t, strings.HasPrefix(message, "<110>1 "), message)
+	require.Contains(t, message, " polykey-test polykey ")
+	require.Contains(t, message, `[polykey@32473 id="evt-1" client="polykey-dev-client" operation="CreateKey" keyId="key-1" success="true" seq="7" hash="abc"]`)
+	message = <-received
+	require.True(t, strings.HasPrefix(message, "<108>1 "), message)
+	require.Contains(t, message, `error="denied \"key\]=1\""`)
+	require.NoError(t, sink.Close())
+
+	// A second sink in CEF format reuses the listener through a new connection.
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		reader := bufio.NewReader(conn)
+		if _, err := reader.ReadString(' '); err != nil {
+			return
+		}
+		line, _ := reader.ReadString('\n')
+		received <- line
+	}()
+	cefSink, err := infra_audit.NewSyslogSink(slog.Default(), infra_audit.SyslogSinkConfig{
+		Network:      "tcp",
+		Address:      listener.Addr().String(),
+		Format:       infra_audit.SyslogFormatCEF,
+		Facility:     13,
+		WriteTimeout: time.Second,
+	})
+	require.NoError(t, err)
+	require.NoError(t, cefSink.Publish(context.Background(), events[1:]))
+	require.NoError(t, cefSink.Close())
+
+	message = <-received
+	require.Contains(t, message, "CEF:0|Spounge|Polykey|")
+	require.Contains(t, message, "|RevokeKey|RevokeKey|7|")
+	require.Contains(t, message, `outcome=failure`)
+	require.Contains(t, message, `reason=denied "key]\=1"`)
+}
+
+// flakyAuditRepository stores events in memory and fails writes while down is set.
+type flakyAuditRepository struct {
+	domain.AuditRepository
+	mu     sync.Mutex
+	down   bool
+	events []*domain.AuditEvent
+}
+
+func (r *flakyAuditRepository) CreateAuditEventsBatch(_ context.Context, events []*domain.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		return errors.New("database unavailable")
+	}
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *flakyAuditRepository) CreateAuditEvent(ctx context.Context, event *domain.AuditEvent) error {
+	return r.CreateAuditEventsBatch(ctx, []*domain.AuditEvent{event})
+}
+
+func (r *flakyAuditRepository) setDown(down bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = down
+}
+
+func (r *flakyAuditRepository) stored() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func TestAsyncAuditLogger_SpillAndReplay(t *testing.T) {
+	spillDir := t.TempDir()
+	repo := &flakyAuditRepository{down: true}
+	logger := infra_audit.NewAsyncAuditLogger(slog.Default(), repo, infra_audit.AsyncAuditLoggerConfig{
+		ChannelBufferSize: 10,
+		WorkerCount:       1,
+		BatchSize:         2,
+		BatchTimeout:      10 * time.Millisecond,
+	}, clock.System())
+	require.NoError(t, logger.EnableSpill(infra_audit.AuditSpillConfig{
+		Dir:            spillDir,
+		MaxBytes:       1 << 20,
+		ReplayInterval: 20 * time.Millisecond,
+	}))
+	logger.Start()
+	defer logger.Stop()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		logger.AuditLog(ctx, "polykey-dev-client", "CreateKey", "key-1", "", true, nil)
+	}
+
+	// While the database is down the events wait on disk.
+	require.Eventually(t, func() bool {
+		segments, _ := filepath.Glob(filepath.Join(spillDir, "*.jsonl"))
+		return len(segments) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Error(t, logger.HealthCheck(ctx))
+	require.Zero(t, repo.stored())
+
+	repo.setDown(false)
+	require.Eventually(t, func() bool { return repo.stored() == 5 }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		segments, _ := filepath.Glob(filepath.Join(spillDir, "*.jsonl"))
+		return len(segments) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSamplingLogger(t *testing.T) {
+	_, err := infra_audit.NewSamplingLogger(nil, []infra_audit.SamplingRule{{Operation: "RevokeKey", OneIn: 10}})
+	require.Error(t, err, "mutations must not be sampled")
+
+	repo := &flakyAuditRepository{}
+	logger, err := infra_audit.NewSamplingLogger(infra_audit.NewAuditLogger(slog.Default(), repo, clock.System()),
+		[]infra_audit.SamplingRule{{Operation: "GetKey", OneIn: 10}})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 25; i++ {
+		logger.AuditLog(ctx, "polykey-dev-client", "GetKey", "key-1", "", true, nil)
+	}
+	require.Equal(t, 3, repo.stored())
+
+	logger.AuditLog(ctx, "polykey-dev-client", "GetKey", "key-1", "", false, errors.New("not found"))
+	logger.AuditLog(ctx, "polykey-dev-client", "RotateKey", "key-1", "", true, nil)
+	require.Equal(t, 5, repo.stored())
+}
+
+func TestVerbosityLogger(t *testing.T) {
+	_, err := infra_audit.ParseVerbosity("verbose")
+	require.Error(t, err)
+
+	repo := &flakyAuditRepository{}
+	logger := infra_audit.NewVerbosityLogger(infra_audit.NewAuditLogger(slog.Default(), repo, clock.System()), infra_audit.VerbosityFull,
+		map[string]infra_audit.Verbosity{"ListKeys": infra_audit.VerbosityMinimal, "GetKeyMetadata": infra_audit.VerbosityOff})
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 51234}})
+	ctx = domain.NewContextWithAuditChanges(ctx, []domain.AuditChange{{Field: "description", Old: "a", New: "b"}})
+	logger.AuditLog(ctx, "polykey-dev-client", "UpdateKeyMetadata", "key-1", "", true, nil)
+	logger.AuditLog(ctx, "polykey-dev-client", "ListKeys", "", "", true, nil)
+	logger.AuditLog(ctx, "polykey-dev-client", "GetKeyMetadata", "key-1", "", true, nil)
+
+	require.Len(t, repo.events, 2)
+	require.Equal(t, "10.0.0.7", repo.events[0].PeerIP)
+	require.Equal(t, []domain.AuditChange{{Field: "description", Old: "a", New: "b"}}, repo.events[0].Changes)
+	require.Empty(t, repo.events[1].PeerIP)
+	require.Empty(t, repo.events[1].Changes)
+}
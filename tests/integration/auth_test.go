@@ -8,16 +8,23 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
 	infra_audit "github.com/spounge-ai/polykey/internal/infra/audit"
 	"github.com/spounge-ai/polykey/internal/infra/auth"
 	"github.com/spounge-ai/polykey/internal/infra/config"
 	"github.com/spounge-ai/polykey/internal/infra/persistence"
+	"github.com/spounge-ai/polykey/internal/service"
+	"github.com/spounge-ai/polykey/pkg/clock"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func setupAuth(t *testing.T) (*auth.TokenManager, domain.Authorizer, domain.KeyRepository, func()) {
@@ -42,23 +49,26 @@ func setupAuth(t *testing.T) (*auth.TokenManager, domain.Authorizer, domain.KeyR
 					AllowedOperations: []string{"*"},
 				},
 			},
+			StepUp: config.StepUpConfig{
+				AcceptedAMR: []string{"mfa"},
+			},
 		},
 		BootstrapSecrets: config.BootstrapSecrets{
 			JWTRSAPrivateKey: string(privateKeyPEM),
 		},
 	}
 
-	keyRepo, err := persistence.NewPSQLAdapter(dbpool, slog.Default())
+	keyRepo, err := persistence.NewPSQLAdapter(dbpool, slog.Default(), clock.System(), metadataIntegrity)
 	require.NoError(t, err)
 
 	auditRepo, err := persistence.NewAuditRepository(dbpool)
 	require.NoError(t, err)
-	auditLogger := infra_audit.NewAuditLogger(slog.Default(), auditRepo)
+	auditLogger := infra_audit.NewAuditLogger(slog.Default(), auditRepo, clock.System())
 
 	authorizer := auth.NewAuthorizer(cfg.Authorization, keyRepo, auditLogger)
 
-	tokenStore := auth.NewInMemoryTokenStore()
-	tokenManager, err := auth.NewTokenManager(cfg.BootstrapSecrets.JWTRSAPrivateKey, tokenStore, auditLogger)
+	tokenStore := auth.NewInMemoryTokenStore(clock.System())
+	tokenManager, err := auth.NewTokenManager(cfg.BootstrapSecrets.JWTRSAPrivateKey, tokenStore, auditLogger, clock.System())
 	require.NoError(t, err)
 
 	return tokenManager, authorizer, keyRepo, func() {}
@@ -87,6 +97,36 @@ func TestTokenManager(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestTokenCertificateBinding(t *testing.T) {
+	tokenManager, _, _, cleanup := setupAuth(t)
+	defer cleanup()
+	parse := func(certPEM string) *x509.Certificate {
+		block, _ := pem.Decode([]byte(certPEM))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		return cert
+	}
+	certPEM, _ := selfSignedCertFor(t, 1, "client")
+	otherPEM, _ := selfSignedCertFor(t, 2, "client")
+	cert, other := parse(certPEM), parse(otherPEM)
+
+	token, err := tokenManager.GenerateToken("test-user", []string{"user"}, time.Hour, auth.WithCertificateBinding(cert))
+	require.NoError(t, err)
+	claims, err := tokenManager.ValidateToken(context.Background(), token)
+	require.NoError(t, err)
+	require.Equal(t, auth.CertificateThumbprint(cert), claims.Confirmation.X5tS256)
+
+	require.NoError(t, auth.VerifyCertificateBinding(claims, cert))
+	require.ErrorIs(t, auth.VerifyCertificateBinding(claims, other), auth.ErrCertificateBinding)
+	require.ErrorIs(t, auth.VerifyCertificateBinding(claims, nil), auth.ErrCertificateBinding, "a bound token needs a certificate")
+
+	unbound, err := tokenManager.GenerateToken("test-user", []string{"user"}, time.Hour)
+	require.NoError(t, err)
+	claims, err = tokenManager.ValidateToken(context.Background(), unbound)
+	require.NoError(t, err)
+	require.NoError(t, auth.VerifyCertificateBinding(claims, nil))
+}
+
 func TestAuthorizer(t *testing.T) {
 	_, authorizer, keyRepo, cleanup := setupAuth(t)
 	defer cleanup()
@@ -129,3 +169,152 @@ func TestAuthorizer(t *testing.T) {
 	allowed, reason = authorizer.Authorize(ctxAdmin, &pk.RequesterContext{ClientIdentity: "admin-user"}, nil, "keys:read", keyID)
 	require.True(t, allowed, reason)
 }
+
+func TestAuthorizerStepUp(t *testing.T) {
+	_, authorizer, keyRepo, cleanup := setupAuth(t)
+	defer cleanup()
+
+	user := &domain.AuthenticatedUser{ID: "test-user", Permissions: []string{"user"}}
+	stepUpUser := &domain.AuthenticatedUser{ID: "test-user", Permissions: []string{"user"}, AuthMethods: []string{"pwd", "mfa"}}
+
+	keyID := domain.NewKeyID()
+	key := &domain.Key{
+		ID:      keyID,
+		Version: 1,
+		Metadata: &pk.KeyMetadata{
+			Description:        "hardened key",
+			KeyType:            pk.KeyType_KEY_TYPE_AES_256,
+			AuthorizedContexts: []string{"test-user"},
+			AccessPolicies:     map[string]string{"require_step_up": "true"},
+		},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, keyRepo.CreateKey(context.Background(), key))
+
+	reqContext := &pk.RequesterContext{ClientIdentity: "test-user"}
+
+	// Without an accepted amr claim the key is rejected with a step-up reason.
+	allowed, reason := authorizer.Authorize(domain.NewContextWithUser(context.Background(), user), reqContext, nil, "keys:read", keyID)
+	require.False(t, allowed)
+	require.Equal(t, domain.ReasonStepUpRequired, reason)
+
+	allowed, reason = authorizer.Authorize(domain.NewContextWithUser(context.Background(), stepUpUser), reqContext, nil, "keys:read", keyID)
+	require.True(t, allowed, reason)
+
+	// A cached step-up decision must not leak to a token without step-up.
+	allowed, _ = authorizer.Authorize(domain.NewContextWithUser(context.Background(), user), reqContext, nil, "keys:read", keyID)
+	require.False(t, allowed)
+}
+
+func TestFileClientStoreManagement(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "clients.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("clients:\n  existing:\n    hashed_api_key: \""+string(hash)+"\"\n    permissions: [\"user\"]\n    description: \"kept on rewrite\"\n"), 0o600))
+
+	store, err := auth.NewFileClientStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.PutClient(ctx, domain.Client{ID: "added", HashedAPIKey: string(hash), Permissions: []string{"admin"}, Namespace: "team-a"}))
+	require.ErrorIs(t, store.PutClient(ctx, domain.Client{ID: "invalid", HashedAPIKey: "plain", Permissions: []string{"admin"}}), auth.ErrInvalidConfig)
+
+	reloaded, err := auth.NewFileClientStore(path)
+	require.NoError(t, err)
+	clients, err := reloaded.ListClients(ctx)
+	require.NoError(t, err)
+	require.Len(t, clients, 2)
+	require.Equal(t, "added", clients[0].ID)
+	require.Equal(t, "team-a", clients[0].Namespace)
+	require.Equal(t, domain.DefaultNamespace, clients[1].Namespace)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "kept on rewrite")
+
+	require.NoError(t, store.DeleteClient(ctx, "added"))
+	require.ErrorIs(t, store.DeleteClient(ctx, "added"), auth.ErrClientNotFound)
+	require.ErrorIs(t, store.DeleteClient(ctx, "existing"), auth.ErrInvalidConfig, "the last client is kept")
+	_, err = store.FindClientByID(ctx, "added")
+	require.ErrorIs(t, err, auth.ErrClientNotFound)
+}
+
+func TestAuthServiceChallenge(t *testing.T) {
+	ctx := context.Background()
+	tokenManager, _, _, cleanup := setupAuth(t)
+	defer cleanup()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "clients.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("clients:\n  client:\n    hashed_api_key: \""+string(hash)+"\"\n    permissions: [\"user\"]\n"), 0o600))
+	store, err := auth.NewFileClientStore(path)
+	require.NoError(t, err)
+
+	clk := clock.NewFake(time.Now())
+	authService := service.NewAuthService(store, tokenManager, config.TokenConfig{TTL: time.Hour}, config.AuthenticateConfig{
+		RequireChallenge: true,
+		MaxClockSkew:     time.Minute,
+		MaxFailures:      3,
+		FailureWindow:    time.Minute,
+	}, clk)
+	challenge := func(nonce string, at time.Time) *domain.AuthenticateChallenge {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		return &domain.AuthenticateChallenge{Nonce: nonce, Timestamp: timestamp, Signature: domain.SignAuthenticateChallenge("secret", "client", nonce, timestamp)}
+	}
+	request := func(c *domain.AuthenticateChallenge) service.AuthenticationRequest {
+		return service.AuthenticationRequest{ClientID: "client", APIKey: "secret", Challenge: c, SourceIP: "192.0.2.1"}
+	}
+
+	first := challenge("n1", clk.Now())
+	_, err = authService.Authenticate(ctx, request(first))
+	require.NoError(t, err)
+	_, err = authService.Authenticate(ctx, request(first))
+	require.ErrorIs(t, err, app_errors.ErrAuthentication, "a replayed challenge is rejected")
+	_, err = authService.Authenticate(ctx, request(challenge("n2", clk.Now().Add(-time.Hour))))
+	require.ErrorIs(t, err, app_errors.ErrAuthentication, "a stale challenge is rejected")
+	_, err = authService.Authenticate(ctx, request(nil))
+	require.ErrorIs(t, err, app_errors.ErrAuthentication, "a challenge is required")
+
+	_, err = authService.Authenticate(ctx, request(challenge("n3", clk.Now())))
+	require.ErrorIs(t, err, app_errors.ErrRateLimit, "the client is throttled after three failures")
+	_, err = authService.Authenticate(ctx, service.AuthenticationRequest{ClientID: "other", APIKey: "secret", SourceIP: "192.0.2.1"})
+	require.ErrorIs(t, err, app_errors.ErrRateLimit, "so is its source IP")
+
+	clk.Advance(time.Minute + time.Second)
+	_, err = authService.Authenticate(ctx, request(challenge("n4", clk.Now())))
+	require.NoError(t, err, "the throttle lifts when the failure window ends")
+}
+
+func TestAuthServiceTokenTTL(t *testing.T) {
+	ctx := context.Background()
+	tokenManager, _, _, cleanup := setupAuth(t)
+	defer cleanup()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "clients.yaml")
+	clients := "clients:\n" +
+		"  basic:\n    hashed_api_key: \"" + string(hash) + "\"\n    permissions: [\"user\"]\n" +
+		"  enterprise:\n    hashed_api_key: \"" + string(hash) + "\"\n    permissions: [\"user\"]\n    tier: enterprise\n" +
+		"  batch:\n    hashed_api_key: \"" + string(hash) + "\"\n    permissions: [\"user\"]\n    tier: enterprise\n    token_ttl: 48h\n"
+	require.NoError(t, os.WriteFile(path, []byte(clients), 0o600))
+	store, err := auth.NewFileClientStore(path)
+	require.NoError(t, err)
+
+	authService := service.NewAuthService(store, tokenManager, config.TokenConfig{
+		TTL:      time.Hour,
+		TierTTLs: map[string]time.Duration{"enterprise": 8 * time.Hour},
+		MaxTTL:   24 * time.Hour,
+	}, config.AuthenticateConfig{}, clock.System())
+	for client, expected := range map[string]time.Duration{
+		"basic":      time.Hour,
+		"enterprise": 8 * time.Hour,
+		"batch":      24 * time.Hour, // the client's override is capped
+	} {
+		result, err := authService.Authenticate(ctx, service.AuthenticationRequest{ClientID: client, APIKey: "secret"})
+		require.NoError(t, err)
+		require.Equal(t, int64(expected.Seconds()), result.ExpiresIn, client)
+	}
+
+	require.ErrorIs(t, store.PutClient(ctx, domain.Client{ID: "invalid", HashedAPIKey: string(hash), Permissions: []string{"user"}, Tier: "gold"}), auth.ErrInvalidConfig)
+}
@@ -0,0 +1,201 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/infra/ratelimit"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"golang.org/x/time/rate"
+)
+
+const reloadTestConfig = `
+server:
+  port: 50053
+  tls:
+    enabled: false
+  rate_limiter:
+    enabled: true
+    rate: %s
+    burst: 20
+persistence:
+  type: s3
+aws:
+  enabled: false
+logging:
+  level: %s
+  modules:
+    - module: audit
+      level: warn
+`
+
+func TestConfigWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(rate, level string) {
+		require.NoError(t, os.WriteFile(path, fmt.Appendf(nil, reloadTestConfig, rate, level), 0o600))
+	}
+	writeConfig("10", "info")
+
+	current := &infra_config.Config{BootstrapSecrets: infra_config.BootstrapSecrets{
+		PolykeyMasterKey: "/kH+AgL+tN2qrA8I+nXL7is4ORj23p2YVhpTjAz2YIs=",
+		JWTRSAPrivateKey: "test-jwt-key",
+	}}
+	current, err := infra_config.Reload(path, current)
+	require.NoError(t, err)
+	require.Equal(t, "test-jwt-key", current.BootstrapSecrets.JWTRSAPrivateKey)
+	require.True(t, current.Reload.Enabled)
+
+	var applied [][]infra_config.Change
+	watcher := infra_config.NewWatcher(path, current, slog.Default(), func(_ context.Context, old, next *infra_config.Config, changes []infra_config.Change) {
+		require.Equal(t, "test-jwt-key", next.BootstrapSecrets.JWTRSAPrivateKey)
+		applied = append(applied, changes)
+	})
+
+	require.NoError(t, watcher.ReloadOnce(context.Background()))
+	require.Empty(t, applied)
+
+	writeConfig("50", "debug")
+	require.NoError(t, watcher.ReloadOnce(context.Background()))
+	require.Equal(t, [][]infra_config.Change{{
+		{Key: "logging.level", Old: "info", New: "debug"},
+		{Key: "server.rate_limiter.rate", Old: "10", New: "50"},
+	}}, applied)
+
+	// A broken file is skipped and the last good config kept.
+	require.NoError(t, os.WriteFile(path, []byte("server: ["), 0o600))
+	require.Error(t, watcher.ReloadOnce(context.Background()))
+	writeConfig("50", "debug")
+	require.NoError(t, watcher.ReloadOnce(context.Background()))
+	require.Len(t, applied, 1)
+}
+
+func TestConfigDiffModules(t *testing.T) {
+	old := &infra_config.Config{Logging: infra_config.LoggingConfig{Modules: []infra_config.ModuleLogLevel{
+		{Module: "audit", Level: "warn"}, {Module: "grpc", Level: "debug"},
+	}}}
+	next := &infra_config.Config{Logging: infra_config.LoggingConfig{Modules: []infra_config.ModuleLogLevel{
+		{Module: "grpc", Level: "debug"}, {Module: "jobs", Level: "error"},
+	}}}
+	next.Persistence.CircuitBreaker.ResetTimeout = time.Minute
+
+	require.Equal(t, []infra_config.Change{
+		{Key: "logging.modules.audit", Old: "warn"},
+		{Key: "logging.modules.jobs", New: "error"},
+		{Key: "persistence.circuit_breaker.reset_timeout", Old: "0s", New: "1m0s"},
+	}, infra_config.Diff(old, next))
+}
+
+func TestRateLimiterConfigure(t *testing.T) {
+	ctx := context.Background()
+	limiter := ratelimit.NewInMemoryRateLimiter(0, 1)
+	require.True(t, limiter.Allow(ctx, "client"))
+	require.False(t, limiter.Allow(ctx, "client"))
+
+	limiter.Configure(false, 0, 1)
+	require.True(t, limiter.Allow(ctx, "client"))
+
+	// New clients get the new burst; known ones keep their drained bucket.
+	limiter.Configure(true, 0, 2)
+	require.False(t, limiter.Allow(ctx, "client"))
+	require.True(t, limiter.Allow(ctx, "other"))
+	require.True(t, limiter.Allow(ctx, "other"))
+	require.False(t, limiter.Allow(ctx, "other"))
+
+	limiter.Configure(true, rate.Inf, 0)
+	require.True(t, limiter.Allow(ctx, "client"))
+}
+
+func TestConfigSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  tls:
+    enabled: false
+persistence:
+  type: s3
+bootstrap_secrets_provider: local
+bootstrap_secrets_base_path: /polykey/test
+webhooks:
+  endpoints:
+    - name: ops
+      url: https://hooks.example/polykey
+      secret: webhook-secret
+`), 0o600))
+	t.Setenv("POLYKEY_SERVER_PORT", "6000")
+	t.Setenv("POLYKEY_SECRET_POLYKEY_KMS_POLYKEY_MASTER_KEY", "/kH+AgL+tN2qrA8I+nXL7is4ORj23p2YVhpTjAz2YIs=")
+	t.Setenv("POLYKEY_SECRET_POLYKEY_JWT_JWT_RSA_PRIVATE_KEY", "test-jwt-key")
+	t.Setenv("POLYKEY_SECRET_POLYKEY_SERVER_RATE_LIMITER", `{"rate": 50}`)
+
+	cfg, err := infra_config.Load(path)
+	require.NoError(t, err)
+
+	settings := make(map[string]infra_config.Setting)
+	for _, setting := range cfg.Settings() {
+		settings[setting.Key] = setting
+	}
+	for key, want := range map[string]infra_config.Setting{
+		"server.port":                                       {Value: "6000", Source: infra_config.SourceEnv},
+		"persistence.type":                                  {Value: "s3", Source: infra_config.SourceFile},
+		"server.rate_limiter.rate":                          {Value: "50", Source: infra_config.SourceBootstrapSecret},
+		"server.rate_limiter.burst":                         {Value: "20", Source: infra_config.SourceDefault},
+		"webhooks.endpoints.0.url":                          {Value: "https://hooks.example/polykey", Source: infra_config.SourceFile},
+		"webhooks.endpoints.0.secret":                       {Value: "******", Source: infra_config.SourceFile},
+		"bootstrap_secrets.polykey/jwt/jwt_rsa_private_key": {Value: "******", Source: infra_config.SourceBootstrapSecret},
+		"bootstrap_secrets.polykey/tls/server-cert.pem":     {Value: "", Source: infra_config.SourceBootstrapSecret},
+	} {
+		want.Key = key
+		require.Equal(t, want, settings[key])
+	}
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	container, err := testcontainers.Run(ctx, "redis:7.4",
+		testcontainers.WithExposedPorts("6379/tcp"),
+		testcontainers.WithWaitStrategy(wait.ForLog("Ready to accept connections")),
+	)
+	testcontainers.CleanupContainer(t, container)
+	require.NoError(t, err)
+	addr, err := container.PortEndpoint(ctx, "6379/tcp", "")
+	require.NoError(t, err)
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	require.NoError(t, client.Ping(ctx).Err())
+
+	// Two replicas share one bucket per client.
+	first := ratelimit.NewRedisRateLimiter(client, "test:", 0, 2, slog.Default())
+	second := ratelimit.NewRedisRateLimiter(client, "test:", 0, 2, slog.Default())
+	require.True(t, first.Allow(ctx, "client"))
+	require.True(t, second.Allow(ctx, "client"))
+	require.False(t, first.Allow(ctx, "client"))
+	require.False(t, second.Allow(ctx, "client"))
+	require.True(t, second.Allow(ctx, "other"))
+
+	first.Configure(false, 0, 2)
+	require.True(t, first.Allow(ctx, "client"))
+	first.Configure(true, rate.Inf, 0)
+	require.True(t, first.Allow(ctx, "client"))
+
+	// The bucket refills at the configured rate.
+	first.Configure(true, 20, 1)
+	require.True(t, first.Allow(ctx, "refill"))
+	require.False(t, first.Allow(ctx, "refill"))
+	require.Eventually(t, func() bool { return first.Allow(ctx, "refill") }, time.Second, 10*time.Millisecond)
+
+	// Without Redis, each replica limits on its own.
+	unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 50 * time.Millisecond})
+	offline := ratelimit.NewRedisRateLimiter(unreachable, "test:", 0, 1, slog.Default())
+	defer offline.Close()
+	require.Error(t, offline.HealthCheck(ctx))
+	require.True(t, offline.Allow(ctx, "client"))
+	require.False(t, offline.Allow(ctx, "client"))
+}
@@ -0,0 +1,287 @@
+package integration_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	awskms "github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go"
+	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	infra_audit "github.com/spounge-ai/polykey/internal/infra/audit"
+	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/infra/persistence"
+	"github.com/spounge-ai/polykey/internal/kms"
+	"github.com/spounge-ai/polykey/internal/service"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"github.com/spounge-ai/polykey/pkg/execution"
+	"github.com/spounge-ai/polykey/pkg/patterns/chaos"
+	kms_mocks "github.com/spounge-ai/polykey/tests/mocks/kms"
+	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedKMSProvider(t *testing.T) {
+	masterKey := make([]byte, 32)
+	_, err := rand.Read(masterKey)
+	require.NoError(t, err)
+	local, err := kms.NewLocalKMSProvider(base64.StdEncoding.EncodeToString(masterKey))
+	require.NoError(t, err)
+	provider := kms.NewInstrumentedProvider("local", local)
+
+	key := &domain.Key{ID: domain.NewKeyID()}
+	dek := []byte("0123456789abcdef0123456789abcdef")
+	key.EncryptedDEK, err = provider.EncryptDEK(context.Background(), dek, key)
+	require.NoError(t, err)
+	decrypted, err := provider.DecryptDEK(context.Background(), key)
+	require.NoError(t, err)
+	require.Equal(t, dek, decrypted)
+	require.NoError(t, provider.HealthCheck(context.Background()))
+
+	key.EncryptedDEK = []byte("not a ciphertext")
+	_, err = provider.DecryptDEK(context.Background(), key)
+	require.Error(t, err)
+}
+
+func TestKMSErrorType(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	require.Equal(t, "throttled", kms.ErrorType(fmt.Errorf("encrypt: %w", throttled)))
+	require.Equal(t, "error", kms.ErrorType(&smithy.GenericAPIError{Code: "NotFoundException"}))
+	require.Equal(t, "timeout", kms.ErrorType(fmt.Errorf("encrypt: %w", context.DeadlineExceeded)))
+	require.Equal(t, "canceled", kms.ErrorType(context.Canceled))
+	require.Equal(t, "error", kms.ErrorType(errors.New("cipher: message authentication failed")))
+}
+
+// flakyKMSProvider fails its first calls with err, then succeeds.
+type flakyKMSProvider struct {
+	kms.KMSProvider
+	failures int
+	err      error
+	calls    int
+}
+
+func (p *flakyKMSProvider) DecryptDEK(context.Context, *domain.Key) ([]byte, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return nil, p.err
+	}
+	return []byte("dek"), nil
+}
+
+func TestRetryingKMSProvider(t *testing.T) {
+	policy := execution.RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+
+	// Throttling is retried until the call goes through.
+	flaky := &flakyKMSProvider{failures: 2, err: throttled}
+	dek, err := kms.NewRetryingProvider("aws", flaky, policy).DecryptDEK(context.Background(), &domain.Key{})
+	require.NoError(t, err)
+	require.Equal(t, []byte("dek"), dek)
+	require.Equal(t, 3, flaky.calls)
+
+	// Attempts run out.
+	flaky = &flakyKMSProvider{failures: 10, err: throttled}
+	_, err = kms.NewRetryingProvider("aws", flaky, policy).DecryptDEK(context.Background(), &domain.Key{})
+	require.ErrorAs(t, err, new(smithy.APIError))
+	require.Equal(t, 4, flaky.calls)
+
+	// Errors that will not go away fail at once.
+	flaky = &flakyKMSProvider{failures: 10, err: &smithy.GenericAPIError{Code: "InvalidCiphertextException"}}
+	_, err = kms.NewRetryingProvider("aws", flaky, policy).DecryptDEK(context.Background(), &domain.Key{})
+	require.Error(t, err)
+	require.Equal(t, 1, flaky.calls)
+
+	// No wait outlasts the caller's deadline.
+	slow := execution.RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	flaky = &flakyKMSProvider{failures: 10, err: throttled}
+	started := time.Now()
+	_, err = kms.NewRetryingProvider("aws", flaky, slow).DecryptDEK(ctx, &domain.Key{})
+	require.Error(t, err)
+	require.Less(t, time.Since(started), time.Second)
+
+	require.True(t, kms.IsTransient(fmt.Errorf("decrypt: %w", context.DeadlineExceeded)))
+	require.False(t, kms.IsTransient(&smithy.GenericAPIError{Code: "AccessDeniedException", Fault: smithy.FaultClient}))
+}
+
+func TestChaosKMSProvider(t *testing.T) {
+	ctx := context.Background()
+	masterKey := make([]byte, 32)
+	_, err := rand.Read(masterKey)
+	require.NoError(t, err)
+	local, err := kms.NewLocalKMSProvider(base64.StdEncoding.EncodeToString(masterKey))
+	require.NoError(t, err)
+	key := &domain.Key{ID: domain.NewKeyID()}
+	dek := []byte("0123456789abcdef0123456789abcdef")
+
+	// Injected failures are transient, so they are retried and open the breaker.
+	failing := kms.NewChaosProvider(local, chaos.NewInjector(chaos.Faults{ErrorRate: 1}))
+	breaker := kms.NewCircuitBreakerProvider("chaos", failing, slog.Default(), 3, time.Minute)
+	policy := execution.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	_, err = kms.NewRetryingProvider("chaos", breaker, policy).EncryptDEK(ctx, dek, key)
+	require.ErrorIs(t, err, chaos.ErrInjected)
+	require.True(t, kms.IsTransient(err))
+	require.Equal(t, "open", breaker.Breaker().Status().State)
+
+	// Latency counts against the caller's deadline.
+	slow := kms.NewChaosProvider(local, chaos.NewInjector(chaos.Faults{Latency: time.Second}))
+	deadline, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = slow.EncryptDEK(deadline, dek, key)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Without faults, calls go through.
+	healthy := kms.NewChaosProvider(local, chaos.NewInjector(chaos.Faults{}))
+	key.EncryptedDEK, err = healthy.EncryptDEK(ctx, dek, key)
+	require.NoError(t, err)
+	decrypted, err := healthy.DecryptDEK(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, dek, decrypted)
+}
+
+func TestAWSKMSProvider(t *testing.T) {
+	awsCfg := localStackConfig(t)
+	ctx := context.Background()
+	created, err := awskms.NewFromConfig(awsCfg).CreateKey(ctx, &awskms.CreateKeyInput{})
+	require.NoError(t, err)
+
+	provider := kms.NewAWSKMSProvider(awsCfg, *created.KeyMetadata.Arn)
+	require.NoError(t, provider.HealthCheck(ctx))
+
+	key := &domain.Key{ID: domain.NewKeyID()}
+	dek := []byte("0123456789abcdef0123456789abcdef")
+	key.EncryptedDEK, err = provider.EncryptDEK(ctx, dek, key)
+	require.NoError(t, err)
+	require.NotEqual(t, dek, key.EncryptedDEK)
+
+	decrypted, err := provider.DecryptDEK(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, dek, decrypted)
+
+	key.EncryptedDEK = []byte("not a ciphertext")
+	_, err = provider.DecryptDEK(ctx, key)
+	require.Error(t, err)
+}
+
+func TestKeyServiceKMSFailures(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+	mock := kms_mocks.NewMockKMSProvider()
+	policy := execution.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	cfg := &infra_config.Config{DefaultKMSProvider: "mock"}
+	keyRepo, err := persistence.NewPSQLAdapter(dbpool, slog.Default(), clock.System(), metadataIntegrity)
+	require.NoError(t, err)
+	auditRepo, err := persistence.NewAuditRepository(dbpool)
+	require.NoError(t, err)
+	keyService := service.NewKeyService(cfg, keyRepo, map[string]kms.KMSProvider{"mock": kms.NewRetryingProvider("mock", mock, policy)},
+		slog.Default(), app_errors.NewErrorClassifier(slog.Default()), infra_audit.NewAuditLogger(slog.Default(), auditRepo, clock.System()), nil,
+		persistence.NewKeyTemplateRepository(dbpool), persistence.NewKeyAliasRepository(dbpool), clock.System())
+	requester := &pk.RequesterContext{ClientIdentity: "polykey-dev-client"}
+	create := func() (*pk.CreateKeyResponse, error) {
+		return keyService.CreateKey(ctx, &pk.CreateKeyRequest{KeyType: pk.KeyType_KEY_TYPE_AES_256, RequesterContext: requester})
+	}
+
+	// Throttling is retried.
+	mock.Script(kms_mocks.OpEncryptDEK, kms_mocks.Throttle())
+	created, err := create()
+	require.NoError(t, err)
+	require.Equal(t, 2, mock.CallCount(kms_mocks.OpEncryptDEK))
+	_, err = keyService.GetKey(ctx, &pk.GetKeyRequest{KeyId: created.KeyId, RequesterContext: requester})
+	require.NoError(t, err)
+
+	// Persistent throttling fails the request without storing a key.
+	mock.Reset()
+	mock.Script(kms_mocks.OpEncryptDEK, kms_mocks.Throttle(), kms_mocks.Throttle(), kms_mocks.Throttle())
+	_, err = create()
+	require.Error(t, err)
+	require.True(t, kms.IsTransient(err))
+	require.Equal(t, 3, mock.CallCount(kms_mocks.OpEncryptDEK))
+	count, err := keyRepo.CountKeys(ctx, domain.DefaultNamespace)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// A corrupt ciphertext cannot be unwrapped, and is not retried.
+	mock.Reset()
+	mock.Script(kms_mocks.OpEncryptDEK, kms_mocks.CorruptCiphertext())
+	corrupt, err := create()
+	require.NoError(t, err)
+	_, err = keyService.GetKey(ctx, &pk.GetKeyRequest{KeyId: corrupt.KeyId, RequesterContext: requester})
+	require.ErrorIs(t, err, app_errors.ErrKMSFailure)
+	require.False(t, kms.IsTransient(err))
+	require.Equal(t, 1, mock.CallCount(kms_mocks.OpDecryptDEK))
+
+	// A slow KMS counts against the caller's deadline.
+	mock.Reset()
+	mock.Script(kms_mocks.OpDecryptDEK, kms_mocks.Slow(time.Second))
+	deadline, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = keyService.GetKey(deadline, &pk.GetKeyRequest{KeyId: created.KeyId, RequesterContext: requester})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	calls := mock.Calls()
+	require.Len(t, calls, 1)
+	require.Equal(t, created.KeyId, calls[0].KeyID.String())
+	require.ErrorIs(t, calls[0].Err, context.DeadlineExceeded)
+}
+
+func TestKeyServiceRotatesDueKeys(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+	clk := clock.NewFake(time.Now())
+	cfg := &infra_config.Config{DefaultKMSProvider: "mock"}
+	keyRepo, err := persistence.NewPSQLAdapter(dbpool, slog.Default(), clk, metadataIntegrity)
+	require.NoError(t, err)
+	auditRepo, err := persistence.NewAuditRepository(dbpool)
+	require.NoError(t, err)
+	keyService := service.NewKeyService(cfg, keyRepo, map[string]kms.KMSProvider{"mock": kms_mocks.NewMockKMSProvider()},
+		slog.Default(), app_errors.NewErrorClassifier(slog.Default()), infra_audit.NewAuditLogger(slog.Default(), auditRepo, clk), nil,
+		persistence.NewKeyTemplateRepository(dbpool), persistence.NewKeyAliasRepository(dbpool), clk)
+	requester := &pk.RequesterContext{ClientIdentity: "polykey-dev-client"}
+	create := func(policies map[string]string) domain.KeyID {
+		resp, err := keyService.CreateKey(ctx, &pk.CreateKeyRequest{
+			KeyType:          pk.KeyType_KEY_TYPE_AES_256,
+			AccessPolicies:   policies,
+			RequesterContext: requester,
+		})
+		require.NoError(t, err)
+		id, err := domain.KeyIDFromString(resp.KeyId)
+		require.NoError(t, err)
+		return id
+	}
+	version := func(id domain.KeyID) int32 {
+		key, err := keyRepo.GetKey(ctx, id)
+		require.NoError(t, err)
+		return key.Version
+	}
+
+	due := create(map[string]string{"rotation_period": "1h"})
+	notDue := create(map[string]string{"rotation_period": "24h"})
+	unscheduled := create(nil)
+
+	clk.Advance(2 * time.Hour)
+	rotated, failed, err := keyService.RotateDueKeys(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, rotated)
+	require.Equal(t, 0, failed)
+	require.Equal(t, int32(2), version(due))
+	require.Equal(t, int32(1), version(notDue))
+	require.Equal(t, int32(1), version(unscheduled))
+
+	// The new version starts the period again.
+	rotated, _, err = keyService.RotateDueKeys(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, 0, rotated)
+
+	clk.Advance(time.Hour)
+	rotated, _, err = keyService.RotateDueKeys(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, rotated)
+	require.Equal(t, int32(3), version(due))
+}
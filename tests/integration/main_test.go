@@ -5,19 +5,53 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
-	"path/filepath" // Added import
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/ory/dockertest/v3"
-	"github.com/ory/dockertest/v3/docker"
+	"github.com/spounge-ai/polykey/internal/domain"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/localstack"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+const (
+	// envDatabaseURL points the tests at an existing database instead of a container.
+	// The database is migrated and truncated between tests, so it must be disposable.
+	envDatabaseURL = "POLYKEY_TEST_DATABASE_URL"
+	// envLocalStack starts a LocalStack container for the tests of the AWS backends
+	// (KMS, SSM and S3); they are skipped without it.
+	envLocalStack = "POLYKEY_TEST_LOCALSTACK"
+
+	postgresImage   = "postgres:17.6"
+	localStackImage = "localstack/localstack:4.4"
 )
 
 var dbpool *pgxpool.Pool
 
+// databaseURL is the URL of the test database.
+var databaseURL string
+
+// localStackEndpoint is the URL of the LocalStack container, empty when it was not
+// started.
+var localStackEndpoint string
+
+// testIntegrityKey keys the metadata checksums of the repositories under test.
+const testIntegrityKey = "bWV0YWRhdGEtaW50ZWdyaXR5LWtleS1mb3ItdGVzdHM="
+
+var metadataIntegrity = func() *domain.MetadataIntegrity {
+	integrity, err := domain.NewMetadataIntegrity(testIntegrityKey)
+	if err != nil {
+		panic(err)
+	}
+	return integrity
+}()
+
 // findModuleRoot finds the directory containing go.mod by traversing up from the current directory.
 func findModuleRoot() (string, error) {
 	currentDir, err := os.Getwd()
@@ -40,63 +74,52 @@ func findModuleRoot() (string, error) {
 }
 
 func TestMain(m *testing.M) {
-	pool, err := dockertest.NewPool("")
-	if err != nil {
-		log.Fatalf("Could not construct pool: %s", err)
-	}
-
-	err = pool.Client.Ping()
-	if err != nil {
-		log.Fatalf("Could not connect to Docker: %s", err)
-	}
+	os.Exit(run(m))
+}
 
-	// pull postgres docker image for version 13
-	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
-		Repository: "postgres",
-		Tag:        "17.6",
-		Env: []string{
-			"POSTGRES_PASSWORD=secret",
-			"POSTGRES_USER=user",
-			"POSTGRES_DB=polykey",
-			"listen_addresses = '*'",
-		},
-	}, func(config *docker.HostConfig) {
-		config.AutoRemove = true
-		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
-	})
-	if err != nil {
-		log.Fatalf("Could not start resource: %s", err)
+// run sets up the containers, runs the tests and terminates the containers, which
+// os.Exit in TestMain would skip.
+func run(m *testing.M) int {
+	ctx := context.Background()
+
+	databaseURL = os.Getenv(envDatabaseURL)
+	if databaseURL == "" {
+		container, err := postgres.Run(ctx, postgresImage,
+			postgres.WithDatabase("polykey"),
+			postgres.WithUsername("user"),
+			postgres.WithPassword("secret"),
+			postgres.BasicWaitStrategies(),
+		)
+		defer terminate(container)
+		if err != nil {
+			log.Fatalf("Could not start postgres: %s", err)
+		}
+		databaseURL, err = container.ConnectionString(ctx, "sslmode=disable")
+		if err != nil {
+			log.Fatalf("Could not get the postgres connection string: %s", err)
+		}
 	}
 
-	hostAndPort := resource.GetHostPort("5432/tcp")
-	databaseUrl := fmt.Sprintf("postgres://user:secret@%s/polykey?sslmode=disable", hostAndPort)
-
-	log.Println("Connecting to database on url: ", databaseUrl)
+	log.Println("Connecting to database on url: ", databaseURL)
 
-		if err := resource.Expire(120); err != nil {
-		log.Fatalf("Could not set resource expiration: %s", err)
+	var err error
+	dbpool, err = pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		log.Fatalf("Could not create the database pool: %s", err)
 	}
-
-	// exponential backoff-retry, because the application in the container might not be ready to accept connections yet
-	if err := pool.Retry(func() error {
-		var err error
-		dbpool, err = pgxpool.New(context.Background(), databaseUrl)
-		if err != nil {
-			return err
-		}
-		return dbpool.Ping(context.Background())
-	}); err != nil {
-		log.Fatalf("Could not connect to docker: %s", err)
+	defer dbpool.Close()
+	if err := dbpool.Ping(ctx); err != nil {
+		log.Fatalf("Could not connect to the database: %s", err)
 	}
 
 	// run migrations
-	moduleRoot, err := findModuleRoot() // Use the new helper
+	moduleRoot, err := findModuleRoot()
 	if err != nil {
 		log.Fatalf("Could not find module root: %s", err)
 	}
 	migrationsPath := filepath.Join(moduleRoot, "migrations") // Construct path relative to module root
 
-	mig, err := migrate.New("file://" + migrationsPath, databaseUrl)
+	mig, err := migrate.New("file://"+migrationsPath, databaseURL)
 	if err != nil {
 		log.Fatalf("Could not create migrate instance: %s", err)
 	}
@@ -104,19 +127,52 @@ func TestMain(m *testing.M) {
 		log.Fatalf("Could not run migrations: %s", err)
 	}
 
-	code := m.Run()
+	if os.Getenv(envLocalStack) != "" {
+		container, err := localstack.Run(ctx, localStackImage,
+			testcontainers.WithEnv(map[string]string{"SERVICES": "kms,ssm,s3"}),
+		)
+		defer terminate(container)
+		if err != nil {
+			log.Fatalf("Could not start localstack: %s", err)
+		}
+		localStackEndpoint, err = container.PortEndpoint(ctx, "4566/tcp", "http")
+		if err != nil {
+			log.Fatalf("Could not get the localstack endpoint: %s", err)
+		}
+	}
+
+	return m.Run()
+}
 
-	// You can't defer this because os.Exit doesn't care for defer
-	if err := pool.Purge(resource); err != nil {
-		log.Fatalf("Could not purge resource: %s", err)
+// terminate removes a container started by run; containers that failed to start may
+// still need removing.
+func terminate(container testcontainers.Container) {
+	if err := testcontainers.TerminateContainer(container); err != nil {
+		log.Printf("Could not terminate container: %s", err)
 	}
+}
 
-	os.Exit(code)
+// localStackConfig returns the AWS config of the LocalStack container, skipping the
+// test when it was not started.
+func localStackConfig(t *testing.T) aws.Config {
+	t.Helper()
+	if localStackEndpoint == "" {
+		t.Skipf("set %s=1 to run the tests against LocalStack", envLocalStack)
+	}
+	return aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(localStackEndpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+	}
 }
 
 func truncate(t *testing.T) {
-	_, err := dbpool.Exec(context.Background(), "TRUNCATE keys, audit_events RESTART IDENTITY")
+	_, err := dbpool.Exec(context.Background(), "TRUNCATE keys, key_outbox, replication_targets, key_event_outbox, key_event_consumers, archived_key_versions, kms_rewrap_checkpoints, audit_events, audit_archives, key_templates, key_aliases, issued_tokens, key_expiry_notice_watermark RESTART IDENTITY")
 	if err != nil {
 		t.Fatalf("failed to truncate database: %v", err)
 	}
-}
\ No newline at end of file
+	_, err = dbpool.Exec(context.Background(), "UPDATE audit_chain_head SET seq = 0, hash = repeat('0', 64)")
+	if err != nil {
+		t.Fatalf("failed to reset audit chain head: %v", err)
+	}
+}
@@ -3,19 +3,37 @@ package integration_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
+	infra_config "github.com/spounge-ai/polykey/internal/infra/config"
 	"github.com/spounge-ai/polykey/internal/infra/persistence"
+	"github.com/spounge-ai/polykey/internal/jobs"
+	"github.com/spounge-ai/polykey/internal/service"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"github.com/spounge-ai/polykey/pkg/patterns/chaos"
+	psql "github.com/spounge-ai/polykey/pkg/postgres"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func setupPersistence(t *testing.T) (*persistence.PSQLAdapter, func()) {
 	t.Helper()
-	adapter, err := persistence.NewPSQLAdapter(dbpool, slog.Default())
+	adapter, err := persistence.NewPSQLAdapter(dbpool, slog.Default(), clock.System(), metadataIntegrity)
 	require.NoError(t, err)
 
 	cleanup := func() {
@@ -57,6 +75,62 @@ func TestPersistence_CreateAndGetKey(t *testing.T) {
 	require.Equal(t, key.Status, retrievedKey.Status)
 }
 
+func TestPersistence_SlowQueryLogging(t *testing.T) {
+	defer truncate(t)
+	ctx := context.Background()
+
+	logs := &lockedBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, nil))
+
+	poolConfig, err := pgxpool.ParseConfig(dbpool.Config().ConnString())
+	require.NoError(t, err)
+	poolConfig.ConnConfig.Tracer = persistence.NewSlowQueryTracer(logger, 0)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	adapter, err := persistence.NewPSQLAdapter(pool, slog.Default(), clock.System(), metadataIntegrity)
+	require.NoError(t, err)
+	keyID := domain.NewKeyID()
+	require.NoError(t, adapter.CreateKey(ctx, &domain.Key{
+		ID:           keyID,
+		Version:      1,
+		Metadata:     &pk.KeyMetadata{Description: "slow query key", KeyType: pk.KeyType_KEY_TYPE_AES_256},
+		EncryptedDEK: []byte("slow-query-secret-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}))
+	_, err = adapter.GetKey(ctx, keyID)
+	require.NoError(t, err)
+
+	output := logs.String()
+	require.Contains(t, output, `"msg":"slow database query"`)
+	require.Contains(t, output, `"statement":"get_latest_key"`)
+	require.Contains(t, output, `"params":["string:`)
+	require.NotContains(t, output, keyID.String())
+	require.NotContains(t, output, "slow-query-secret-dek")
+	require.NotContains(t, output, "slow query key")
+}
+
+// lockedBuffer collects log output written from several connections at once.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
 func TestPersistence_RotateKey(t *testing.T) {
 	adapter, cleanup := setupPersistence(t)
 	defer cleanup()
@@ -81,7 +155,7 @@ func TestPersistence_RotateKey(t *testing.T) {
 	require.NoError(t, err)
 
 	newDEK := []byte("rotated-dek")
-	rotatedKey, err := adapter.RotateKey(ctx, keyID, newDEK)
+	rotatedKey, err := adapter.RotateKey(ctx, keyID, newDEK, time.Now().Add(time.Hour))
 	require.NoError(t, err)
 	require.NotNil(t, rotatedKey)
 	require.Equal(t, int32(2), rotatedKey.Version)
@@ -94,6 +168,85 @@ func TestPersistence_RotateKey(t *testing.T) {
 	v1Key, err := adapter.GetKeyByVersion(ctx, keyID, 1)
 	require.NoError(t, err)
 	require.Equal(t, domain.KeyStatusRotated, v1Key.Status)
+	require.NotNil(t, v1Key.GraceExpiresAt)
+}
+
+func TestPersistence_ExpireRotatedVersion(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	keyID := domain.NewKeyID()
+	key := &domain.Key{
+		ID:      keyID,
+		Version: 1,
+		Metadata: &pk.KeyMetadata{
+			KeyType: pk.KeyType_KEY_TYPE_AES_256,
+			Version: 1,
+		},
+		EncryptedDEK: []byte("initial-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, adapter.CreateKey(ctx, key))
+
+	_, err := adapter.RotateKey(ctx, keyID, []byte("rotated-dek"), time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	swept, err := adapter.ExpireKeys(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, swept, 1)
+	require.Equal(t, int32(1), swept[0].Version)
+
+	v1Key, err := adapter.GetKeyByVersion(ctx, keyID, 1)
+	require.NoError(t, err)
+	require.Equal(t, domain.KeyStatusExpired, v1Key.Status)
+
+	latestKey, err := adapter.GetKey(ctx, keyID)
+	require.NoError(t, err)
+	require.Equal(t, domain.KeyStatusActive, latestKey.Status)
+}
+
+func TestPersistence_PruneKeyVersions(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	keyID := domain.NewKeyID()
+	require.NoError(t, adapter.CreateKey(ctx, &domain.Key{
+		ID:           keyID,
+		Version:      1,
+		Metadata:     &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, Version: 1},
+		EncryptedDEK: []byte("dek-1"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}))
+	for i := 0; i < 3; i++ {
+		_, err := adapter.RotateKey(ctx, keyID, []byte("rotated-dek"), time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+	}
+
+	retention := domain.KeyVersionRetention{KeepVersions: 2, Before: time.Now(), Archive: true}
+	pruned, err := adapter.PruneKeyVersions(ctx, retention, 10)
+	require.NoError(t, err)
+	require.Len(t, pruned, 2)
+
+	versions, err := adapter.GetKeyVersions(ctx, keyID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, int32(4), versions[0].Version)
+	require.Equal(t, int32(3), versions[1].Version)
+
+	var archived int
+	require.NoError(t, dbpool.QueryRow(ctx, "SELECT count(*) FROM archived_key_versions WHERE id = $1", keyID.String()).Scan(&archived))
+	require.Equal(t, 2, archived)
+
+	// Versions still within their grace period are kept whatever their position.
+	pruned, err = adapter.PruneKeyVersions(ctx, domain.KeyVersionRetention{KeepVersions: 1, Before: time.Now().Add(-time.Hour)}, 10)
+	require.NoError(t, err)
+	require.Empty(t, pruned)
 }
 
 func TestPersistence_UpdateKeyMetadata(t *testing.T) {
@@ -132,6 +285,47 @@ func TestPersistence_UpdateKeyMetadata(t *testing.T) {
 	require.Equal(t, "b", retrievedKey.Metadata.Tags["a"])
 }
 
+func TestPersistence_UpdateKeyMetadataIfOwner(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	keyID := domain.NewKeyID()
+	key := &domain.Key{
+		ID:      keyID,
+		Version: 1,
+		Metadata: &pk.KeyMetadata{
+			KeyType:         pk.KeyType_KEY_TYPE_AES_256,
+			CreatorIdentity: "owner-a",
+		},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, adapter.CreateKey(ctx, key))
+
+	err := adapter.UpdateKeyMetadataIfOwner(ctx, keyID, 1, "owner-a", &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, CreatorIdentity: "owner-b"})
+	require.NoError(t, err)
+
+	// A second transfer that read the key before the first one sees a different owner.
+	err = adapter.UpdateKeyMetadataIfOwner(ctx, keyID, 1, "owner-a", &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, CreatorIdentity: "owner-c"})
+	require.ErrorIs(t, err, app_errors.ErrConflict)
+
+	_, err = adapter.RotateKey(ctx, keyID, []byte("encrypted-dek-2"), time.Time{})
+	require.NoError(t, err)
+	err = adapter.UpdateKeyMetadataIfOwner(ctx, keyID, 1, "owner-b", &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, CreatorIdentity: "owner-c"})
+	require.ErrorIs(t, err, app_errors.ErrConflict)
+
+	retrievedKey, err := adapter.GetKey(ctx, keyID)
+	require.NoError(t, err)
+	require.Equal(t, "owner-b", retrievedKey.Metadata.GetCreatorIdentity())
+
+	err = adapter.UpdateKeyMetadataIfOwner(ctx, domain.NewKeyID(), 1, "owner-b", &pk.KeyMetadata{})
+	require.Error(t, err)
+	require.NotErrorIs(t, err, app_errors.ErrConflict)
+}
+
 func TestPersistence_RevokeKey(t *testing.T) {
 	adapter, cleanup := setupPersistence(t)
 	defer cleanup()
@@ -162,3 +356,1043 @@ func TestPersistence_RevokeKey(t *testing.T) {
 	require.Equal(t, domain.KeyStatusRevoked, retrievedKey.Status)
 	require.NotNil(t, retrievedKey.RevokedAt)
 }
+
+func TestPersistence_ExpireKeys(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now()
+	newKey := func(expiresAt time.Time) *domain.Key {
+		return &domain.Key{
+			ID:      domain.NewKeyID(),
+			Version: 1,
+			Metadata: &pk.KeyMetadata{
+				KeyType:   pk.KeyType_KEY_TYPE_AES_256,
+				ExpiresAt: timestamppb.New(expiresAt),
+			},
+			EncryptedDEK: []byte("encrypted-dek"),
+			Status:       domain.KeyStatusActive,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+	}
+
+	expired := newKey(now.Add(-time.Hour))
+	expiring := newKey(now.Add(time.Hour))
+	require.NoError(t, adapter.CreateKey(ctx, expired))
+	require.NoError(t, adapter.CreateKey(ctx, expiring))
+
+	upcoming, err := adapter.ListExpiringKeys(ctx, domain.KeyExpiryCursor{ExpiresAt: now}, now.Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, upcoming, 1)
+	require.Equal(t, expiring.ID, upcoming[0].ID)
+
+	swept, err := adapter.ExpireKeys(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, swept, 1)
+	require.Equal(t, expired.ID, swept[0].ID)
+
+	retrievedKey, err := adapter.GetKey(ctx, expired.ID)
+	require.NoError(t, err)
+	require.Equal(t, domain.KeyStatusExpired, retrievedKey.Status)
+
+	retrievedKey, err = adapter.GetKey(ctx, expiring.ID)
+	require.NoError(t, err)
+	require.Equal(t, domain.KeyStatusActive, retrievedKey.Status)
+}
+
+func TestKeyExpirationJobFollowsClock(t *testing.T) {
+	defer truncate(t)
+	ctx := context.Background()
+	start := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	adapter, err := persistence.NewPSQLAdapter(dbpool, slog.Default(), clk, metadataIntegrity)
+	require.NoError(t, err)
+
+	rotated := &domain.Key{
+		ID:           domain.NewKeyID(),
+		Version:      1,
+		Metadata:     &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, Version: 1},
+		EncryptedDEK: []byte("initial-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    start,
+		UpdatedAt:    start,
+	}
+	require.NoError(t, adapter.CreateKey(ctx, rotated))
+	clk.Advance(time.Minute)
+	v2, err := adapter.RotateKey(ctx, rotated.ID, []byte("rotated-dek"), clk.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, v2.CreatedAt.Equal(clk.Now()), "the new version is stamped with the clock")
+
+	expiring := &domain.Key{
+		ID:      domain.NewKeyID(),
+		Version: 1,
+		Metadata: &pk.KeyMetadata{
+			KeyType:   pk.KeyType_KEY_TYPE_AES_256,
+			ExpiresAt: timestamppb.New(start.Add(90 * time.Minute)),
+		},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    start,
+		UpdatedAt:    start,
+	}
+	require.NoError(t, adapter.CreateKey(ctx, expiring))
+
+	job := jobs.NewKeyExpirationJob(adapter, nil, nil, slog.Default(), infra_config.KeyExpirationConfig{}, clk)
+	status := func(id domain.KeyID, version int32) domain.KeyStatus {
+		key, err := adapter.GetKeyByVersion(ctx, id, version)
+		require.NoError(t, err)
+		return key.Status
+	}
+
+	// Nothing is due yet, however late the test actually runs.
+	require.NoError(t, job.RunOnce(ctx))
+	require.Equal(t, domain.KeyStatusRotated, status(rotated.ID, 1))
+	require.Equal(t, domain.KeyStatusActive, status(expiring.ID, 1))
+
+	// The grace period of the rotated version ends first.
+	clk.Advance(time.Hour)
+	require.NoError(t, job.RunOnce(ctx))
+	require.Equal(t, domain.KeyStatusExpired, status(rotated.ID, 1))
+	require.Equal(t, domain.KeyStatusActive, status(expiring.ID, 1))
+
+	clk.Advance(30 * time.Minute)
+	require.NoError(t, job.RunOnce(ctx))
+	require.Equal(t, domain.KeyStatusExpired, status(expiring.ID, 1))
+	require.Equal(t, domain.KeyStatusActive, status(rotated.ID, 2))
+}
+
+func TestKeyExpirationJobNotifiesOnce(t *testing.T) {
+	defer truncate(t)
+	ctx := context.Background()
+	start := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	adapter, err := persistence.NewPSQLAdapter(dbpool, slog.Default(), clk, metadataIntegrity)
+	require.NoError(t, err)
+
+	// More keys share an expiry time than fit in a batch.
+	for range 5 {
+		require.NoError(t, adapter.CreateKey(ctx, &domain.Key{
+			ID:           domain.NewKeyID(),
+			Version:      1,
+			Metadata:     &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, ExpiresAt: timestamppb.New(start.Add(time.Hour))},
+			EncryptedDEK: []byte("encrypted-dek"),
+			Status:       domain.KeyStatusActive,
+			CreatedAt:    start,
+			UpdatedAt:    start,
+		}))
+	}
+
+	cfg := infra_config.KeyExpirationConfig{BatchSize: 2, NotifyBefore: 2 * time.Hour}
+	notices := persistence.NewKeyExpiryNoticeRepository(dbpool)
+	publisher := &recordingPublisher{}
+	require.NoError(t, jobs.NewKeyExpirationJob(adapter, notices, publisher, slog.Default(), cfg, clk).RunOnce(ctx))
+	announced := make(map[string]bool)
+	for _, event := range publisher.events {
+		require.Equal(t, domain.KeyEventExpiring, event.Type)
+		announced[event.KeyID] = true
+	}
+	require.Len(t, publisher.events, 5)
+	require.Len(t, announced, 5)
+
+	// A new job, as after a restart or a leader change, resumes from the saved watermark.
+	clk.Advance(time.Minute)
+	publisher.events = nil
+	require.NoError(t, jobs.NewKeyExpirationJob(adapter, notices, publisher, slog.Default(), cfg, clk).RunOnce(ctx))
+	require.Empty(t, publisher.events)
+}
+
+func TestChaosKeyRepository(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+	ctx := context.Background()
+	newKeys := func(n int) ([]*domain.Key, []domain.KeyID) {
+		keys := make([]*domain.Key, n)
+		ids := make([]domain.KeyID, n)
+		for i := range keys {
+			ids[i] = domain.NewKeyID()
+			keys[i] = &domain.Key{
+				ID:           ids[i],
+				Version:      1,
+				Metadata:     &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, Version: 1},
+				EncryptedDEK: []byte("encrypted-dek"),
+				Status:       domain.KeyStatusActive,
+				CreatedAt:    time.Now(),
+				UpdatedAt:    time.Now(),
+			}
+		}
+		return keys, ids
+	}
+
+	// Failed calls never reach the database.
+	failing := persistence.NewChaosKeyRepository(adapter, chaos.NewInjector(chaos.Faults{ErrorRate: 1}))
+	keys, ids := newKeys(1)
+	require.ErrorIs(t, failing.CreateKey(ctx, keys[0]), chaos.ErrInjected)
+	exists, err := adapter.Exists(ctx, ids[0])
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// A batch write stops at its first failed item, leaving the items before it written.
+	partial := persistence.NewChaosKeyRepository(adapter, chaos.NewInjector(chaos.Faults{ItemFailureRate: 0.5, Seed: 4}))
+	keys, ids = newKeys(20)
+	err = partial.CreateBatchKeys(ctx, keys)
+	require.ErrorIs(t, err, chaos.ErrInjected)
+	stored, err := adapter.GetBatchKeys(ctx, ids)
+	require.NoError(t, err)
+	require.NotEmpty(t, stored)
+	require.Less(t, len(stored), len(ids))
+	for i, id := range ids {
+		exists, err := adapter.Exists(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, i < len(stored), exists, "key %d", i)
+	}
+
+	// A batch read leaves out its failed items.
+	require.NoError(t, adapter.CreateBatchKeys(ctx, keys[len(stored):]))
+	all, err := partial.GetBatchKeys(ctx, ids)
+	require.NoError(t, err)
+	require.Less(t, len(all), len(ids))
+	lossless := persistence.NewChaosKeyRepository(adapter, chaos.NewInjector(chaos.Faults{}))
+	all, err = lossless.GetBatchKeys(ctx, ids)
+	require.NoError(t, err)
+	require.Len(t, all, len(ids))
+}
+
+func TestPersistence_RejectsInvalidTransitions(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	keyID := domain.NewKeyID()
+	key := &domain.Key{
+		ID:      keyID,
+		Version: 1,
+		Metadata: &pk.KeyMetadata{
+			KeyType: pk.KeyType_KEY_TYPE_AES_256,
+			Version: 1,
+		},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, adapter.CreateKey(ctx, key))
+	require.NoError(t, adapter.RevokeKey(ctx, keyID))
+
+	_, err := adapter.RotateKey(ctx, keyID, []byte("rotated-dek"), time.Time{})
+	require.ErrorIs(t, err, app_errors.ErrInvalidKeyTransition)
+
+	var transitionErr *domain.KeyTransitionError
+	require.ErrorAs(t, adapter.RevokeKey(ctx, keyID), &transitionErr)
+	require.Equal(t, domain.KeyStatusRevoked, transitionErr.From)
+	require.Equal(t, domain.KeyStatusRevoked, transitionErr.To)
+}
+
+func TestPersistence_RecordKeyAccesses(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	keyID := domain.NewKeyID()
+	key := &domain.Key{
+		ID:      keyID,
+		Version: 1,
+		Metadata: &pk.KeyMetadata{
+			KeyType: pk.KeyType_KEY_TYPE_AES_256,
+		},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, adapter.CreateKey(ctx, key))
+
+	accessRepo := persistence.NewKeyAccessRepository(dbpool)
+	accessedAt := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, accessRepo.RecordKeyAccesses(ctx, []domain.KeyAccess{{KeyID: keyID, Count: 3, LastAccessedAt: accessedAt}}))
+	require.NoError(t, accessRepo.RecordKeyAccesses(ctx, []domain.KeyAccess{{KeyID: keyID, Count: 2, LastAccessedAt: accessedAt}}))
+
+	metadata, err := adapter.GetKeyMetadata(ctx, keyID)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), metadata.GetAccessCount())
+	require.True(t, accessedAt.Equal(metadata.GetLastAccessedAt().AsTime()))
+}
+
+func TestPersistence_RestoreKey(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	keyID := domain.NewKeyID()
+	key := &domain.Key{
+		ID:      keyID,
+		Version: 1,
+		Metadata: &pk.KeyMetadata{
+			KeyType: pk.KeyType_KEY_TYPE_AES_256,
+		},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, adapter.CreateKey(ctx, key))
+
+	_, err := adapter.RestoreKey(ctx, keyID, time.Now().Add(-time.Hour))
+	require.ErrorIs(t, err, app_errors.ErrInvalidKeyTransition)
+
+	require.NoError(t, adapter.RevokeKey(ctx, keyID))
+
+	_, err = adapter.RestoreKey(ctx, keyID, time.Now().Add(time.Hour))
+	require.ErrorIs(t, err, app_errors.ErrRestoreWindowClosed)
+
+	restored, err := adapter.RestoreKey(ctx, keyID, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, domain.KeyStatusActive, restored.Status)
+	require.Nil(t, restored.RevokedAt)
+}
+
+func TestPersistence_NamespaceIsolation(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctxA := domain.NewContextWithNamespace(context.Background(), "tenant-a")
+	ctxB := domain.NewContextWithNamespace(context.Background(), "tenant-b")
+	keyID := domain.NewKeyID()
+	key := &domain.Key{
+		ID:      keyID,
+		Version: 1,
+		Metadata: &pk.KeyMetadata{
+			KeyType: pk.KeyType_KEY_TYPE_AES_256,
+		},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, adapter.CreateKey(ctxA, key))
+
+	retrievedKey, err := adapter.GetKey(ctxA, keyID)
+	require.NoError(t, err)
+	require.Equal(t, "tenant-a", retrievedKey.Namespace)
+
+	_, err = adapter.GetKey(ctxB, keyID)
+	require.ErrorIs(t, err, psql.ErrKeyNotFound)
+
+	keys, err := adapter.ListKeys(ctxB, domain.KeyFilter{}, domain.KeyPage{}, 10)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	count, err := adapter.CountKeys(ctxA, "tenant-a")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	count, err = adapter.CountKeys(ctxA, "tenant-b")
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
+
+func TestPersistence_ListKeysFilter(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	newKey := func(keyType pk.KeyType, creator, classification string, createdAt time.Time) domain.KeyID {
+		key := &domain.Key{
+			ID:      domain.NewKeyID(),
+			Version: 1,
+			Metadata: &pk.KeyMetadata{
+				KeyType:            keyType,
+				CreatorIdentity:    creator,
+				DataClassification: classification,
+			},
+			EncryptedDEK: []byte("encrypted-dek"),
+			Status:       domain.KeyStatusActive,
+			CreatedAt:    createdAt,
+			UpdatedAt:    createdAt,
+		}
+		require.NoError(t, adapter.CreateKey(ctx, key))
+		return key.ID
+	}
+	now := time.Now()
+	aes := newKey(pk.KeyType_KEY_TYPE_AES_256, "alice", "pii", now.Add(-3*time.Minute))
+	apiKey := newKey(pk.KeyType_KEY_TYPE_API_KEY, "alice", "", now.Add(-2*time.Minute))
+	revoked := newKey(pk.KeyType_KEY_TYPE_AES_256, "bob", "pii", now.Add(-time.Minute))
+	require.NoError(t, adapter.RevokeKey(ctx, revoked))
+
+	ids := func(filter domain.KeyFilter) []domain.KeyID {
+		keys, err := adapter.ListKeys(ctx, filter, domain.KeyPage{}, 10)
+		require.NoError(t, err)
+		var ids []domain.KeyID
+		for _, key := range keys {
+			ids = append(ids, key.ID)
+		}
+		return ids
+	}
+	require.Equal(t, []domain.KeyID{revoked, apiKey, aes}, ids(domain.KeyFilter{}))
+	require.Equal(t, []domain.KeyID{revoked, aes}, ids(domain.KeyFilter{KeyTypes: []pk.KeyType{pk.KeyType_KEY_TYPE_AES_256}}))
+	require.Equal(t, []domain.KeyID{apiKey, aes}, ids(domain.KeyFilter{Statuses: []domain.KeyStatus{domain.KeyStatusActive}}))
+	require.Equal(t, []domain.KeyID{apiKey, aes}, ids(domain.KeyFilter{CreatorIdentity: "alice"}))
+	require.Equal(t, []domain.KeyID{aes}, ids(domain.KeyFilter{DataClassification: "pii", Statuses: []domain.KeyStatus{domain.KeyStatusActive}}))
+
+	// The columns follow metadata updates.
+	require.NoError(t, adapter.UpdateKeyMetadata(ctx, apiKey, &pk.KeyMetadata{
+		KeyType:            pk.KeyType_KEY_TYPE_API_KEY,
+		CreatorIdentity:    "alice",
+		DataClassification: "pii",
+	}))
+	require.Equal(t, []domain.KeyID{apiKey, aes}, ids(domain.KeyFilter{DataClassification: "pii", Statuses: []domain.KeyStatus{domain.KeyStatusActive}}))
+}
+
+func TestPersistence_ListKeysOrder(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	aliases := persistence.NewKeyAliasRepository(dbpool)
+	accesses := persistence.NewKeyAccessRepository(dbpool)
+	now := time.Now().Truncate(time.Second)
+	newKey := func(createdAt time.Time, alias string) domain.KeyID {
+		key := &domain.Key{
+			ID:           domain.NewKeyID(),
+			Namespace:    "default",
+			Version:      1,
+			Metadata:     &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256},
+			EncryptedDEK: []byte("encrypted-dek"),
+			Status:       domain.KeyStatusActive,
+			CreatedAt:    createdAt,
+			UpdatedAt:    createdAt,
+		}
+		require.NoError(t, adapter.CreateKey(ctx, key))
+		if alias != "" {
+			require.NoError(t, aliases.PutKeyAlias(ctx, "default", alias, key.ID))
+		}
+		return key.ID
+	}
+	first := newKey(now.Add(-3*time.Minute), "zeta")
+	second := newKey(now.Add(-2*time.Minute), "")
+	third := newKey(now.Add(-time.Minute), "alpha")
+	require.NoError(t, adapter.UpdateKeyMetadata(ctx, first, &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256}))
+	require.NoError(t, accesses.RecordKeyAccesses(ctx, []domain.KeyAccess{
+		{KeyID: second, Count: 1, LastAccessedAt: now.Add(-time.Hour)},
+		{KeyID: third, Count: 1, LastAccessedAt: now},
+	}))
+
+	// Each order is walked one key at a time, to exercise its cursor.
+	walk := func(order string) []domain.KeyID {
+		parsed, err := domain.ParseKeyOrder(order)
+		require.NoError(t, err)
+		page := domain.KeyPage{Order: parsed}
+		var ids []domain.KeyID
+		for {
+			keys, err := adapter.ListKeys(ctx, domain.KeyFilter{}, page, 1)
+			require.NoError(t, err)
+			if len(keys) == 0 {
+				return ids
+			}
+			ids = append(ids, keys[0].ID)
+			cursor := parsed.CursorAfter(keys[0])
+			page.After = &cursor
+		}
+	}
+	require.Equal(t, []domain.KeyID{third, second, first}, walk(""))
+	require.Equal(t, []domain.KeyID{first, second, third}, walk("created_at"))
+	require.Equal(t, []domain.KeyID{first, third, second}, walk("updated_at desc"))
+	require.Equal(t, []domain.KeyID{first, second, third}, walk("last_accessed_at"))
+	require.Equal(t, []domain.KeyID{second, third, first}, walk("alias asc"))
+	require.Equal(t, []domain.KeyID{first, third, second}, walk("alias desc"))
+
+	_, err := domain.ParseKeyOrder("version")
+	require.Error(t, err)
+}
+
+func TestPersistence_ListKeysAuthorizedContext(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	newKey := func(contexts ...string) domain.KeyID {
+		key := &domain.Key{
+			ID:           domain.NewKeyID(),
+			Version:      1,
+			Metadata:     &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, AuthorizedContexts: contexts},
+			EncryptedDEK: []byte("encrypted-dek"),
+			Status:       domain.KeyStatusActive,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		}
+		require.NoError(t, adapter.CreateKey(ctx, key))
+		return key.ID
+	}
+	shared := newKey("alice", "bob")
+	newKey("bob")
+	newKey()
+
+	keys, err := adapter.ListKeys(ctx, domain.KeyFilter{AuthorizedContext: "alice"}, domain.KeyPage{}, 10)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Equal(t, shared, keys[0].ID)
+
+	// The latest version decides: a key alice was removed from is no longer listed.
+	require.NoError(t, adapter.UpdateKeyMetadata(ctx, shared, &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, AuthorizedContexts: []string{"bob"}}))
+	keys, err = adapter.ListKeys(ctx, domain.KeyFilter{AuthorizedContext: "alice"}, domain.KeyPage{}, 10)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	keys, err = adapter.ListKeys(ctx, domain.KeyFilter{AuthorizedContext: "bob"}, domain.KeyPage{}, 10)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+}
+
+func TestPersistence_MetadataChecksum(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	key := &domain.Key{
+		ID:      domain.NewKeyID(),
+		Version: 1,
+		Metadata: &pk.KeyMetadata{
+			KeyType:            pk.KeyType_KEY_TYPE_AES_256,
+			AuthorizedContexts: []string{"alice"},
+			Tags:               map[string]string{"env": "prod"},
+		},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, adapter.CreateKey(ctx, key))
+	require.True(t, strings.HasPrefix(key.Metadata.GetMetadataChecksum(), "hmac-sha256:"))
+
+	// Access counts and rotations change the metadata in place, which keeps it valid.
+	accesses := persistence.NewKeyAccessRepository(dbpool)
+	require.NoError(t, accesses.RecordKeyAccesses(ctx, []domain.KeyAccess{{KeyID: key.ID, Count: 2, LastAccessedAt: time.Now()}}))
+	_, err := adapter.RotateKey(ctx, key.ID, []byte("encrypted-dek-2"), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	metadata, err := adapter.GetKeyMetadata(ctx, key.ID)
+	require.NoError(t, err)
+	require.Equal(t, key.Metadata.GetMetadataChecksum(), metadata.GetMetadataChecksum())
+
+	_, err = dbpool.Exec(ctx, `UPDATE keys SET metadata = jsonb_set(metadata, '{authorized_contexts}', '["alice","mallory"]') WHERE id = $1::uuid`, key.ID.String())
+	require.NoError(t, err)
+	_, err = adapter.GetKeyMetadata(ctx, key.ID)
+	require.ErrorIs(t, err, app_errors.ErrMetadataIntegrity)
+	_, err = adapter.GetKey(ctx, key.ID)
+	require.ErrorIs(t, err, app_errors.ErrMetadataIntegrity)
+	keys, err := adapter.ListKeys(ctx, domain.KeyFilter{}, domain.KeyPage{}, 10)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// A checksum computed without the server's key does not verify.
+	forger, err := domain.NewMetadataIntegrity("Zm9yZ2VkLW1ldGFkYXRhLWludGVncml0eS1rZXktISE=")
+	require.NoError(t, err)
+	metadata.AuthorizedContexts = []string{"alice", "mallory"}
+	_, err = dbpool.Exec(ctx, `UPDATE keys SET metadata = jsonb_set(metadata, '{metadata_checksum}', to_jsonb($2::text)) WHERE id = $1::uuid`,
+		key.ID.String(), forger.Checksum(metadata))
+	require.NoError(t, err)
+	_, err = adapter.GetKeyMetadata(ctx, key.ID)
+	require.ErrorIs(t, err, app_errors.ErrMetadataIntegrity)
+
+	// Neither does metadata without a checksum, nor is it resealed once it has a keyed one.
+	_, err = dbpool.Exec(ctx, `UPDATE keys SET metadata = metadata - 'metadata_checksum' WHERE id = $1::uuid AND version = 1`, key.ID.String())
+	require.NoError(t, err)
+	_, err = adapter.GetKeyMetadataByVersion(ctx, key.ID, 1)
+	require.ErrorIs(t, err, app_errors.ErrMetadataIntegrity)
+	resealed, mismatched, err := persistence.ResealMetadata(ctx, dbpool, metadataIntegrity)
+	require.NoError(t, err)
+	require.Equal(t, 1, resealed)
+	require.Zero(t, mismatched)
+	_, err = adapter.GetKeyMetadataByVersion(ctx, key.ID, 1)
+	require.NoError(t, err)
+	_, err = adapter.GetKeyMetadata(ctx, key.ID)
+	require.ErrorIs(t, err, app_errors.ErrMetadataIntegrity)
+}
+
+func TestPersistence_ResealMetadata(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	newKey := func() *domain.Key {
+		key := &domain.Key{
+			ID:           domain.NewKeyID(),
+			Version:      1,
+			Metadata:     &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256, AuthorizedContexts: []string{"alice"}},
+			EncryptedDEK: []byte("encrypted-dek"),
+			Status:       domain.KeyStatusActive,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		}
+		require.NoError(t, adapter.CreateKey(ctx, key))
+		return key
+	}
+	unsealed, legacy, tampered := newKey(), newKey(), newKey()
+
+	// Rows written before checksums were keyed have none, or an unkeyed SHA-256 one.
+	_, err := dbpool.Exec(ctx, `UPDATE keys SET metadata = metadata - 'metadata_checksum' WHERE id = $1::uuid`, unsealed.ID.String())
+	require.NoError(t, err)
+	canonical := `{"key_id":"","key_type":2,"created_at":"","expires_at":"","creator_identity":"","authorized_contexts":["alice"],"access_policies":null,"description":"","tags":null,"data_classification":"","storage_type":0}`
+	sum := sha256.Sum256([]byte(canonical))
+	_, err = dbpool.Exec(ctx, `UPDATE keys SET metadata = jsonb_set(metadata, '{metadata_checksum}', to_jsonb($2::text)) WHERE id = ANY($1::uuid[])`,
+		[]string{legacy.ID.String(), tampered.ID.String()}, "sha256:"+hex.EncodeToString(sum[:]))
+	require.NoError(t, err)
+	_, err = dbpool.Exec(ctx, `UPDATE keys SET metadata = jsonb_set(metadata, '{authorized_contexts}', '["alice","mallory"]') WHERE id = $1::uuid`, tampered.ID.String())
+	require.NoError(t, err)
+
+	resealed, mismatched, err := persistence.ResealMetadata(ctx, dbpool, metadataIntegrity)
+	require.NoError(t, err)
+	require.Equal(t, 2, resealed)
+	require.Equal(t, 1, mismatched)
+
+	for _, key := range []*domain.Key{unsealed, legacy} {
+		metadata, err := adapter.GetKeyMetadata(ctx, key.ID)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(metadata.GetMetadataChecksum(), "hmac-sha256:"))
+	}
+	_, err = adapter.GetKeyMetadata(ctx, tampered.ID)
+	require.ErrorIs(t, err, app_errors.ErrMetadataIntegrity)
+
+	// A second run finds nothing left to reseal.
+	resealed, mismatched, err = persistence.ResealMetadata(ctx, dbpool, metadataIntegrity)
+	require.NoError(t, err)
+	require.Zero(t, resealed)
+	require.Equal(t, 1, mismatched)
+}
+
+func TestPersistence_AuditChainIntegrity(t *testing.T) {
+	defer truncate(t)
+	truncate(t)
+
+	ctx := context.Background()
+	repo, err := persistence.NewAuditRepository(dbpool)
+	require.NoError(t, err)
+
+	var events []*domain.AuditEvent
+	for i := 0; i < 5; i++ {
+		events = append(events, &domain.AuditEvent{
+			ID:             uuid.New().String(),
+			ClientIdentity: "polykey-dev-client",
+			Operation:      "CreateKey",
+			KeyID:          domain.NewKeyID().String(),
+			Success:        true,
+			Timestamp:      time.Now(),
+		})
+	}
+	events[1].PeerIP = "10.0.0.7"
+	events[1].UserAgent = "grpc-go/1.74.2"
+	events[1].CertFingerprint = strings.Repeat("ab", 32)
+	require.NoError(t, repo.CreateAuditEventsBatch(ctx, events[:3]))
+	require.NoError(t, repo.CreateAuditEvent(ctx, events[3]))
+	require.NoError(t, repo.CreateAuditEvent(ctx, events[4]))
+
+	report, err := repo.VerifyAuditIntegrity(ctx)
+	require.NoError(t, err)
+	require.True(t, report.Valid, report.Reason)
+	require.Equal(t, int64(5), report.CheckedEvents)
+	require.Equal(t, int64(5), report.HeadSequence)
+
+	found, err := repo.QueryAuditEvents(ctx, domain.AuditQuery{KeyID: events[1].KeyID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, events[1].PeerIP, found[0].PeerIP)
+	require.Equal(t, events[1].UserAgent, found[0].UserAgent)
+	require.Equal(t, events[1].CertFingerprint, found[0].CertFingerprint)
+
+	// The caller's details are covered by the hash.
+	_, err = dbpool.Exec(ctx, "UPDATE audit_events SET peer_ip = '10.0.0.8' WHERE seq = 2")
+	require.NoError(t, err)
+	report, err = repo.VerifyAuditIntegrity(ctx)
+	require.NoError(t, err)
+	require.False(t, report.Valid)
+	require.Equal(t, int64(2), report.BrokenAtSequence)
+	_, err = dbpool.Exec(ctx, "UPDATE audit_events SET peer_ip = '10.0.0.7' WHERE seq = 2")
+	require.NoError(t, err)
+
+	// Removing the newest event leaves the chain behind its head.
+	_, err = dbpool.Exec(ctx, "DELETE FROM audit_events WHERE seq = 5")
+	require.NoError(t, err)
+	report, err = repo.VerifyAuditIntegrity(ctx)
+	require.NoError(t, err)
+	require.False(t, report.Valid)
+	require.Equal(t, int64(5), report.BrokenAtSequence)
+
+	// Rewriting an event breaks its hash.
+	_, err = dbpool.Exec(ctx, "UPDATE audit_events SET success = false WHERE seq = 2")
+	require.NoError(t, err)
+	report, err = repo.VerifyAuditIntegrity(ctx)
+	require.NoError(t, err)
+	require.False(t, report.Valid)
+	require.Equal(t, int64(2), report.BrokenAtSequence)
+}
+
+// memoryArchiveStore stands in for S3 in the retention tests.
+type memoryArchiveStore struct {
+	mu      sync.Mutex
+	objects map[string][]*domain.AuditEvent
+}
+
+func (m *memoryArchiveStore) PutAuditArchive(_ context.Context, objectKey string, events []*domain.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[objectKey] = events
+	return nil
+}
+
+func (m *memoryArchiveStore) GetAuditArchive(_ context.Context, objectKey string) ([]*domain.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.objects[objectKey], nil
+}
+
+func TestPersistence_AuditRetention(t *testing.T) {
+	defer truncate(t)
+	truncate(t)
+
+	ctx := context.Background()
+	repo, err := persistence.NewAuditRepository(dbpool)
+	require.NoError(t, err)
+
+	now := time.Now()
+	var events []*domain.AuditEvent
+	for i := 0; i < 5; i++ {
+		timestamp := now
+		if i < 3 {
+			timestamp = now.Add(-48 * time.Hour)
+		}
+		events = append(events, &domain.AuditEvent{
+			ID:             uuid.New().String(),
+			ClientIdentity: "polykey-dev-client",
+			Operation:      "CreateKey",
+			KeyID:          domain.NewKeyID().String(),
+			Success:        true,
+			Timestamp:      timestamp,
+		})
+	}
+	require.NoError(t, repo.CreateAuditEventsBatch(ctx, events))
+
+	store := &memoryArchiveStore{objects: map[string][]*domain.AuditEvent{}}
+	job := jobs.NewAuditRetentionJob(repo, store, slog.Default(), infra_config.AuditRetentionConfig{
+		HotRetention: 24 * time.Hour,
+		BatchSize:    2,
+		Prefix:       "audit",
+	}, clock.System())
+	require.NoError(t, job.RunOnce(ctx))
+	require.Len(t, store.objects, 2)
+
+	// The archived events are gone and the rest of the chain still verifies.
+	report, err := repo.VerifyAuditIntegrity(ctx)
+	require.NoError(t, err)
+	require.True(t, report.Valid, report.Reason)
+	require.Equal(t, int64(3), report.ArchivedSequence)
+	require.Equal(t, int64(2), report.CheckedEvents)
+	hot, err := repo.QueryAuditEvents(ctx, domain.AuditQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, hot, 2)
+
+	audit := service.NewAuditService(repo, store, time.Hour, "secret", slog.Default(), clock.System())
+	result, err := audit.RestoreAuditArchives(ctx, now.Add(-72*time.Hour), now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Archives)
+	require.Equal(t, int64(3), result.RestoredEvents)
+	require.Empty(t, result.Pending)
+
+	// Restored events are visible, are not archived again and do not disturb verification.
+	all, err := repo.QueryAuditEvents(ctx, domain.AuditQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+	require.NoError(t, job.RunOnce(ctx))
+	require.Len(t, store.objects, 2)
+	report, err = repo.VerifyAuditIntegrity(ctx)
+	require.NoError(t, err)
+	require.True(t, report.Valid, report.Reason)
+
+	purged, err := repo.PurgeRestoredAuditEvents(ctx, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), purged)
+
+	// Deleting an event that was never archived is still detected.
+	_, err = dbpool.Exec(ctx, "DELETE FROM audit_events WHERE seq = 4")
+	require.NoError(t, err)
+	report, err = repo.VerifyAuditIntegrity(ctx)
+	require.NoError(t, err)
+	require.False(t, report.Valid)
+	require.Equal(t, int64(5), report.BrokenAtSequence)
+}
+
+func TestPersistence_LeaderElection(t *testing.T) {
+	ctx := context.Background()
+	cfg := infra_config.LeaderElectionConfig{LockName: "polykey-jobs-test", Interval: 50 * time.Millisecond}
+
+	first := persistence.NewAdvisoryLockElector(dbpool, slog.Default(), cfg)
+	second := persistence.NewAdvisoryLockElector(dbpool, slog.Default(), cfg)
+	require.NoError(t, first.Start(ctx))
+	require.NoError(t, second.Start(ctx))
+	defer func() { _ = second.Stop(ctx) }()
+
+	// The first to campaign leads and the other follows.
+	require.True(t, first.IsLeader())
+	require.False(t, second.IsLeader())
+
+	// A job on the follower stays on standby.
+	job := jobs.NewKeyVersionRetentionJob(nil, slog.Default(), infra_config.KeyVersionRetentionConfig{}, clock.System())
+	job.SetLeadership(second)
+	require.Contains(t, job.Health(ctx).Message, "standby")
+
+	// Once the leader steps down, the follower takes over on its next campaign.
+	require.NoError(t, first.Stop(ctx))
+	require.False(t, first.IsLeader())
+	require.Eventually(t, second.IsLeader, 5*time.Second, 50*time.Millisecond)
+}
+
+// stallingKeyRepository stalls the first GetKey until its context is cancelled.
+type stallingKeyRepository struct {
+	domain.KeyRepository
+	calls atomic.Int32
+}
+
+func (r *stallingKeyRepository) GetKey(ctx context.Context, id domain.KeyID) (*domain.Key, error) {
+	if r.calls.Add(1) == 1 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return r.KeyRepository.GetKey(ctx, id)
+}
+
+func TestPersistence_HedgedGetKey(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	key := &domain.Key{
+		ID:           domain.NewKeyID(),
+		Version:      1,
+		Metadata:     &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, adapter.CreateKey(ctx, key))
+
+	// The stalled query is answered by the hedge and then cancelled.
+	stalling := &stallingKeyRepository{KeyRepository: adapter}
+	repo := persistence.NewHedgedKeyRepository(stalling, 20*time.Millisecond, 1)
+	started := time.Now()
+	retrieved, err := repo.GetKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.Equal(t, key.ID, retrieved.ID)
+	require.Less(t, time.Since(started), time.Second)
+	require.Equal(t, int32(2), stalling.calls.Load())
+
+	// Without a hedge available, the stalled query is waited for.
+	stalling.calls.Store(0)
+	repo = persistence.NewHedgedKeyRepository(stalling, 20*time.Millisecond, 0)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	_, err = repo.GetKey(timeoutCtx, key.ID)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, int32(1), stalling.calls.Load())
+}
+
+// newStandbyDatabase creates and migrates a second database beside the test database.
+func newStandbyDatabase(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	ctx := context.Background()
+	_, err := dbpool.Exec(ctx, "DROP DATABASE IF EXISTS polykey_standby")
+	require.NoError(t, err)
+	_, err = dbpool.Exec(ctx, "CREATE DATABASE polykey_standby")
+	require.NoError(t, err)
+
+	standbyURL := strings.Replace(databaseURL, "/polykey?", "/polykey_standby?", 1)
+	moduleRoot, err := findModuleRoot()
+	require.NoError(t, err)
+	mig, err := migrate.New("file://"+filepath.Join(moduleRoot, "migrations"), standbyURL)
+	require.NoError(t, err)
+	require.NoError(t, mig.Up())
+	_, _ = mig.Close()
+
+	standby, err := pgxpool.New(ctx, standbyURL)
+	require.NoError(t, err)
+	t.Cleanup(standby.Close)
+	return standby
+}
+
+func TestPersistence_KeyReplication(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+	standby := newStandbyDatabase(t)
+	standbyAdapter, err := persistence.NewPSQLAdapter(standby, slog.Default(), clock.System(), metadataIntegrity)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	newKey := func(description string) *domain.Key {
+		key := &domain.Key{
+			ID:           domain.NewKeyID(),
+			Version:      1,
+			Metadata:     &pk.KeyMetadata{Description: description, KeyType: pk.KeyType_KEY_TYPE_AES_256},
+			EncryptedDEK: []byte("encrypted-dek"),
+			Status:       domain.KeyStatusActive,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		}
+		require.NoError(t, adapter.CreateKey(ctx, key))
+		return key
+	}
+
+	// Keys created before the standby registers are seeded, those after recorded.
+	before := newKey("before")
+	replicator := persistence.NewOutboxReplicator(dbpool, standby, "standby")
+	require.NoError(t, replicator.Register(ctx))
+	after := newKey("after")
+
+	batch, err := replicator.ReplicateBatch(ctx, 100)
+	require.NoError(t, err)
+	require.Equal(t, 2, batch.Shipped)
+	require.Empty(t, batch.Conflicts)
+	for _, key := range []*domain.Key{before, after} {
+		replicated, err := standbyAdapter.GetKey(ctx, key.ID)
+		require.NoError(t, err)
+		require.Equal(t, key.Metadata.Description, replicated.Metadata.Description)
+		require.Equal(t, key.EncryptedDEK, replicated.EncryptedDEK)
+	}
+
+	// Updates are shipped, except to versions the standby changed later.
+	before.Metadata.Description = "updated"
+	require.NoError(t, adapter.UpdateKeyMetadata(ctx, before.ID, before.Metadata))
+	_, err = standby.Exec(ctx, "UPDATE keys SET updated_at = now() + interval '1 hour' WHERE id = $1::uuid", after.ID.String())
+	require.NoError(t, err)
+	after.Metadata.Description = "lost"
+	require.NoError(t, adapter.UpdateKeyMetadata(ctx, after.ID, after.Metadata))
+
+	batch, err = replicator.ReplicateBatch(ctx, 100)
+	require.NoError(t, err)
+	require.Equal(t, 2, batch.Shipped)
+	require.Equal(t, []domain.KeyVersionCursor{{KeyID: after.ID.String(), Version: 1}}, batch.Conflicts)
+	replicated, err := standbyAdapter.GetKey(ctx, before.ID)
+	require.NoError(t, err)
+	require.Equal(t, "updated", replicated.Metadata.Description)
+	replicated, err = standbyAdapter.GetKey(ctx, after.ID)
+	require.NoError(t, err)
+	require.Equal(t, "after", replicated.Metadata.Description)
+
+	// Once unregistered, the primary records nothing more.
+	require.NoError(t, replicator.Unregister(ctx))
+	newKey("unrecorded")
+	backlog, err := replicator.Backlog(ctx)
+	require.NoError(t, err)
+	require.Zero(t, backlog.Pending)
+	require.Nil(t, backlog.Oldest)
+}
+
+// flakySink records the key events delivered to it, failing the first delivery of the
+// event at failAt.
+type flakySink struct {
+	failAt    int
+	attempts  int
+	delivered []domain.OutboxKeyEvent
+}
+
+func (s *flakySink) Name() string { return "test" }
+
+func (s *flakySink) Deliver(_ context.Context, event domain.OutboxKeyEvent) error {
+	s.attempts++
+	if s.attempts == s.failAt {
+		return errors.New("broker unreachable")
+	}
+	s.delivered = append(s.delivered, event)
+	return nil
+}
+
+func TestPersistence_KeyEventRelay(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	outbox := persistence.NewPostgresKeyEventOutbox(dbpool, "test")
+	require.NoError(t, outbox.Register(ctx))
+
+	keyID := domain.NewKeyID()
+	require.NoError(t, adapter.CreateKey(ctx, &domain.Key{
+		ID:           keyID,
+		Version:      1,
+		Metadata:     &pk.KeyMetadata{Description: "evented", KeyType: pk.KeyType_KEY_TYPE_AES_256},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}))
+	_, err := adapter.RotateKey(ctx, keyID, []byte("rotated-dek"), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, adapter.RevokeKey(ctx, keyID))
+
+	// A failed delivery ends the sweep; the next one resumes from the failed event.
+	sink := &flakySink{failAt: 2}
+	job := jobs.NewKeyEventRelayJob(outbox, sink, slog.Default(), time.Second, 2)
+	require.Error(t, job.RunOnce(ctx))
+	require.Len(t, sink.delivered, 1)
+	require.NoError(t, job.RunOnce(ctx))
+
+	var types []domain.KeyEventType
+	var versions []int32
+	for _, event := range sink.delivered {
+		require.Equal(t, keyID.String(), event.KeyID)
+		require.NotEmpty(t, event.ID)
+		types = append(types, event.Type)
+		versions = append(versions, event.Version)
+	}
+	require.Equal(t, []domain.KeyEventType{domain.KeyEventCreated, domain.KeyEventRotated, domain.KeyEventRevoked}, types)
+	require.Equal(t, []int32{1, 2, 2}, versions)
+
+	backlog, err := outbox.Backlog(ctx)
+	require.NoError(t, err)
+	require.Zero(t, backlog.Pending)
+	require.Nil(t, backlog.Oldest)
+}
+
+type recordingPublisher struct {
+	events []domain.KeyEvent
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event domain.KeyEvent) {
+	p.events = append(p.events, event)
+}
+
+func TestPersistence_VersionExpiredEvent(t *testing.T) {
+	adapter, cleanup := setupPersistence(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	outbox := persistence.NewPostgresKeyEventOutbox(dbpool, "test")
+	require.NoError(t, outbox.Register(ctx))
+	publisher := &recordingPublisher{}
+	repo := persistence.NewKeyEventRepository(adapter, publisher, clock.System())
+
+	keyID := domain.NewKeyID()
+	require.NoError(t, repo.CreateKey(ctx, &domain.Key{
+		ID:           keyID,
+		Version:      1,
+		Metadata:     &pk.KeyMetadata{KeyType: pk.KeyType_KEY_TYPE_AES_256},
+		EncryptedDEK: []byte("encrypted-dek"),
+		Status:       domain.KeyStatusActive,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}))
+	_, err := repo.RotateKey(ctx, keyID, []byte("rotated-dek"), time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	swept, err := repo.ExpireKeys(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, swept, 1)
+	require.Equal(t, int32(1), swept[0].Version)
+
+	last := publisher.events[len(publisher.events)-1]
+	require.Equal(t, domain.KeyEventVersionExpired, last.Type)
+	require.Equal(t, int32(1), last.Version)
+
+	pending, err := outbox.Pending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+	require.Equal(t, domain.KeyEventVersionExpired, pending[2].Type)
+	require.Equal(t, int32(1), pending[2].Version)
+}
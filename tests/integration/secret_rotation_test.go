@@ -0,0 +1,304 @@
+package integration_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spounge-ai/polykey/internal/infra/auth"
+	"github.com/spounge-ai/polykey/internal/infra/config"
+	"github.com/spounge-ai/polykey/internal/wiring"
+	"github.com/spounge-ai/polykey/pkg/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func rsaKeyPEM(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func TestTokenManagerRotateKey(t *testing.T) {
+	tokenManager, err := auth.NewTokenManager(rsaKeyPEM(t), auth.NewInMemoryTokenStore(clock.System()), nil, clock.System())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	before, err := tokenManager.GenerateToken("client-1", []string{"user"}, time.Hour)
+	require.NoError(t, err)
+
+	require.Error(t, tokenManager.RotateKey("not a key"))
+	require.NoError(t, tokenManager.RotateKey(rsaKeyPEM(t)))
+	after, err := tokenManager.GenerateToken("client-1", []string{"user"}, time.Hour)
+	require.NoError(t, err)
+
+	_, err = tokenManager.ValidateToken(ctx, before)
+	require.NoError(t, err, "tokens signed before a rotation stay valid")
+	_, err = tokenManager.ValidateToken(ctx, after)
+	require.NoError(t, err)
+
+	require.NoError(t, tokenManager.RotateKey(rsaKeyPEM(t)))
+	_, err = tokenManager.ValidateToken(ctx, before)
+	require.Error(t, err, "only the key before the last rotation is kept")
+	_, err = tokenManager.ValidateToken(ctx, after)
+	require.NoError(t, err)
+}
+
+// selfSignedCert returns a PEM certificate and key for localhost with the given serial.
+func selfSignedCert(t *testing.T, serial int64) (certPEM, keyPEM string) {
+	return selfSignedCertFor(t, serial, "localhost")
+}
+
+// selfSignedCertFor returns a PEM certificate and key for name with the given serial.
+func selfSignedCertFor(t *testing.T, serial int64, name string) (certPEM, keyPEM string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestReloadableTLS(t *testing.T) {
+	cert, key := selfSignedCert(t, 1)
+	reloadable, err := wiring.NewReloadableTLS(config.TLS{Enabled: true, ClientAuth: "NoClientCert"},
+		config.BootstrapSecrets{TLSServerCert: cert, TLSServerKey: key})
+	require.NoError(t, err)
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", reloadable.ServerConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	serial := func() int64 {
+		conn, err := tls.Dial("tcp", lis.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+		require.NoError(t, err)
+		defer conn.Close()
+		state := conn.ConnectionState()
+		require.Equal(t, "h2", state.NegotiatedProtocol)
+		return state.PeerCertificates[0].SerialNumber.Int64()
+	}
+	require.Equal(t, int64(1), serial())
+
+	cert, key = selfSignedCert(t, 2)
+	require.NoError(t, reloadable.Update(config.BootstrapSecrets{TLSServerCert: cert, TLSServerKey: key}))
+	require.Equal(t, int64(2), serial())
+
+	require.Error(t, reloadable.Update(config.BootstrapSecrets{TLSServerCert: cert, TLSServerKey: "broken"}))
+	require.Equal(t, int64(2), serial(), "an invalid key pair keeps the current certificate")
+}
+
+func TestCertificateWatcher(t *testing.T) {
+	dir := t.TempDir()
+	tlsCfg := config.TLS{
+		Enabled:    true,
+		ClientAuth: "NoClientCert",
+		CertFile:   filepath.Join(dir, "server.crt"),
+		KeyFile:    filepath.Join(dir, "server.key"),
+	}
+	writeCert := func(serial int64) {
+		cert, key := selfSignedCert(t, serial)
+		require.NoError(t, os.WriteFile(tlsCfg.CertFile, []byte(cert), 0o600))
+		require.NoError(t, os.WriteFile(tlsCfg.KeyFile, []byte(key), 0o600))
+	}
+	writeCert(1)
+
+	reloadable, err := wiring.NewReloadableTLS(tlsCfg, config.BootstrapSecrets{})
+	require.NoError(t, err)
+	cfg := &config.Config{Server: config.ServerConfig{TLS: tlsCfg}}
+	watcher := wiring.NewCertificateWatcher(reloadable, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	servedSerial := func() int64 { return reloadable.Certificate().Leaf.SerialNumber.Int64() }
+
+	require.NoError(t, watcher.CheckOnce(context.Background()))
+	require.Equal(t, int64(1), servedSerial())
+
+	writeCert(2)
+	require.NoError(t, watcher.CheckOnce(context.Background()))
+	require.Equal(t, int64(2), servedSerial())
+
+	require.NoError(t, os.WriteFile(tlsCfg.KeyFile, []byte("broken"), 0o600))
+	require.Error(t, watcher.CheckOnce(context.Background()))
+	require.Equal(t, int64(2), servedSerial(), "an invalid key pair keeps the current certificate")
+}
+
+func TestAdminServerConfig(t *testing.T) {
+	serverCert, serverKey := selfSignedCert(t, 1)
+	operatorCert, operatorKey := selfSignedCertFor(t, 2, "operator")
+	otherCert, otherKey := selfSignedCertFor(t, 3, "other")
+	// The self-signed client certificates act as their own CAs.
+	reloadable, err := wiring.NewReloadableTLS(config.TLS{Enabled: true, ClientAuth: "NoClientCert"},
+		config.BootstrapSecrets{TLSServerCert: serverCert, TLSServerKey: serverKey, SpoungeCA: operatorCert + otherCert})
+	require.NoError(t, err)
+
+	adminTLS, err := reloadable.AdminServerConfig(config.AdminServerConfig{AllowedIdentities: []string{"operator"}})
+	require.NoError(t, err)
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", adminTLS)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			if conn.(*tls.Conn).Handshake() == nil {
+				_, _ = conn.Write([]byte("ok"))
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	// With TLS 1.3 the server's verdict on the client certificate arrives after the
+	// client's handshake, so it is read from the connection.
+	connect := func(certs ...tls.Certificate) error {
+		conn, err := tls.Dial("tcp", lis.Addr().String(), &tls.Config{InsecureSkipVerify: true, Certificates: certs})
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = io.ReadFull(conn, make([]byte, 2))
+		return err
+	}
+	keyPair := func(certPEM, keyPEM string) tls.Certificate {
+		cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		require.NoError(t, err)
+		return cert
+	}
+
+	require.NoError(t, connect(keyPair(operatorCert, operatorKey)))
+	require.Error(t, connect(), "a client certificate is required even though client_auth is NoClientCert")
+	require.Error(t, connect(keyPair(otherCert, otherKey)), "only allowed identities may connect")
+}
+
+func TestCertificateWatcherClientCA(t *testing.T) {
+	// A directory as cert-manager writes it, with the CA of the clients in ca.crt.
+	dir := t.TempDir()
+	serverCert, serverKey := selfSignedCert(t, 1)
+	operatorCert, operatorKey := selfSignedCertFor(t, 2, "operator")
+	otherCert, _ := selfSignedCertFor(t, 3, "other")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.crt"), []byte(serverCert), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.key"), []byte(serverKey), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.crt"), []byte(otherCert), 0o600))
+	tlsCfg := config.TLS{
+		Enabled:      true,
+		ClientAuth:   "RequireAndVerifyClientCert",
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+
+	reloadable, err := wiring.NewReloadableTLS(tlsCfg, config.BootstrapSecrets{})
+	require.NoError(t, err)
+	watcher := wiring.NewCertificateWatcher(reloadable, &config.Config{Server: config.ServerConfig{TLS: tlsCfg}}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", reloadable.ServerConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			if conn.(*tls.Conn).Handshake() == nil {
+				_, _ = conn.Write([]byte("ok"))
+			}
+			_ = conn.Close()
+		}
+	}()
+	operator, err := tls.X509KeyPair([]byte(operatorCert), []byte(operatorKey))
+	require.NoError(t, err)
+	connect := func() error {
+		conn, err := tls.Dial("tcp", lis.Addr().String(), &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{operator}})
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = io.ReadFull(conn, make([]byte, 2))
+		return err
+	}
+
+	require.Error(t, connect(), "the client certificate is not signed by the CA in ca.crt")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.crt"), []byte(otherCert+operatorCert), 0o600))
+	require.NoError(t, watcher.CheckOnce(context.Background()))
+	require.NoError(t, connect())
+}
+
+func TestTLSPolicy(t *testing.T) {
+	serverCert, serverKey := selfSignedCert(t, 1)
+	secrets := config.BootstrapSecrets{TLSServerCert: serverCert, TLSServerKey: serverKey, SpoungeCA: serverCert}
+
+	for _, bad := range []config.TLS{
+		{Enabled: true, MinVersion: "1.1"},
+		{Enabled: true, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		{Enabled: true, CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}},
+		{Enabled: true, CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA"}},
+		{Enabled: true, MinVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+		{Enabled: true, ClientAuth: "RequireClientCert"},
+	} {
+		_, err := wiring.NewReloadableTLS(bad, secrets)
+		require.Error(t, err, "%+v", bad)
+	}
+
+	reloadable, err := wiring.NewReloadableTLS(config.TLS{Enabled: true, MinVersion: "1.3"}, secrets)
+	require.NoError(t, err)
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", reloadable.ServerConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	dial := func(maxVersion uint16) error {
+		conn, err := tls.Dial("tcp", lis.Addr().String(), &tls.Config{InsecureSkipVerify: true, MaxVersion: maxVersion})
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	require.NoError(t, dial(tls.VersionTLS13))
+	require.Error(t, dial(tls.VersionTLS12), "TLS 1.2 is below min_version")
+
+	_, err = reloadable.AdminServerConfig(config.AdminServerConfig{ClientAuth: "NoClientCert"})
+	require.Error(t, err, "the admin listener must verify client certificates")
+	_, err = reloadable.AdminServerConfig(config.AdminServerConfig{ClientAuth: "VerifyClientCertIfGiven"})
+	require.NoError(t, err)
+}
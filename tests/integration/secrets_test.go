@@ -0,0 +1,195 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	infra_secrets "github.com/spounge-ai/polykey/internal/infra/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault serves AppRole logins, KV v2 reads and transit decryption. Tokens are
+// numbered by login; only the latest is accepted.
+func fakeVault(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var logins atomic.Int32
+	kv := map[string]string{
+		"/v1/secret/data/spounge/dev/polykey/db/neondb_url":          "postgres://db.example/polykey",
+		"/v1/secret/data/spounge/dev/polykey/kms/polykey_master_key": "vault:v1:c2VhbGVk",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := func(status int, body any) {
+			w.WriteHeader(status)
+			require.NoError(t, json.NewEncoder(w).Encode(body))
+		}
+		if r.URL.Path == "/v1/auth/approle/login" {
+			var login map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&login))
+			if login["role_id"] != "polykey" || login["secret_id"] != "s3cret" {
+				reply(http.StatusBadRequest, map[string]any{"errors": []string{"invalid role or secret ID"}})
+				return
+			}
+			reply(http.StatusOK, map[string]any{"auth": map[string]any{"client_token": fmt.Sprintf("token-%d", logins.Add(1))}})
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != fmt.Sprintf("token-%d", logins.Load()) {
+			reply(http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		switch {
+		case r.URL.Path == "/v1/transit/decrypt/bootstrap":
+			var req map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			require.Equal(t, "vault:v1:c2VhbGVk", req["ciphertext"])
+			reply(http.StatusOK, map[string]any{"data": map[string]any{"plaintext": base64.StdEncoding.EncodeToString([]byte("master-key"))}})
+		case kv[r.URL.Path] != "":
+			reply(http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"value": kv[r.URL.Path]}, "metadata": map[string]any{"version": 1}}})
+		default:
+			reply(http.StatusNotFound, map[string]any{"errors": []string{}})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &logins
+}
+
+func TestVaultSecretProvider(t *testing.T) {
+	server, logins := fakeVault(t)
+	ctx := context.Background()
+
+	provider, err := infra_secrets.NewVault(ctx, infra_secrets.VaultConfig{
+		Address:    server.URL,
+		AuthMethod: infra_secrets.VaultAuthAppRole,
+		RoleID:     "polykey",
+		SecretID:   "s3cret",
+		TransitKey: "bootstrap",
+	})
+	require.NoError(t, err)
+
+	value, err := provider.GetSecret(ctx, "/spounge/dev/polykey/db/neondb_url")
+	require.NoError(t, err)
+	require.Equal(t, "postgres://db.example/polykey", value)
+
+	value, err = provider.GetSecret(ctx, "/spounge/dev/polykey/kms/polykey_master_key")
+	require.NoError(t, err)
+	require.Equal(t, "master-key", value)
+
+	// A revoked or expired token leads to a new login.
+	logins.Add(1)
+	value, err = provider.GetSecret(ctx, "/spounge/dev/polykey/db/neondb_url")
+	require.NoError(t, err)
+	require.Equal(t, "postgres://db.example/polykey", value)
+	require.Equal(t, int32(3), logins.Load())
+
+	_, err = provider.GetSecret(ctx, "/spounge/dev/polykey/missing")
+	require.Error(t, err)
+
+	_, err = infra_secrets.NewVault(ctx, infra_secrets.VaultConfig{Address: server.URL, AuthMethod: infra_secrets.VaultAuthAppRole, RoleID: "polykey", SecretID: "wrong"})
+	require.Error(t, err)
+}
+
+func TestSecretsManagerSecretProvider(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		require.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		var req struct{ SecretId string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if req.SecretId != "spounge/dev/polykey/db/neondb_url" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`))
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]string{"Name": req.SecretId, "SecretString": "postgres://db.example/polykey"}))
+	}))
+	t.Cleanup(server.Close)
+
+	awsCfg := aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+	}
+	provider := infra_secrets.NewCachedProvider(infra_secrets.NewSecretsManager(awsCfg), time.Minute)
+	ctx := context.Background()
+
+	for range 2 {
+		value, err := provider.GetSecret(ctx, "/spounge/dev/polykey/db/neondb_url")
+		require.NoError(t, err)
+		require.Equal(t, "postgres://db.example/polykey", value)
+	}
+	require.Equal(t, int32(1), calls.Load())
+
+	_, err := provider.GetSecret(ctx, "/spounge/dev/polykey/missing")
+	require.Error(t, err)
+}
+
+func TestParameterStoreSecretProvider(t *testing.T) {
+	awsCfg := localStackConfig(t)
+	ctx := context.Background()
+	_, err := ssm.NewFromConfig(awsCfg).PutParameter(ctx, &ssm.PutParameterInput{
+		Name:      aws.String("/spounge/dev/polykey/db/neondb_url"),
+		Value:     aws.String("postgres://db.example/polykey"),
+		Type:      ssmtypes.ParameterTypeSecureString,
+		Overwrite: aws.Bool(true),
+	})
+	require.NoError(t, err)
+
+	provider := infra_secrets.NewParameterStore(awsCfg)
+	value, err := provider.GetSecret(ctx, "/spounge/dev/polykey/db/neondb_url")
+	require.NoError(t, err)
+	require.Equal(t, "postgres://db.example/polykey", value)
+
+	_, err = provider.GetSecret(ctx, "/spounge/dev/polykey/missing")
+	require.Error(t, err)
+}
+
+func TestLocalSecretProvider(t *testing.T) {
+	plaintext := []byte("polykey/kms/polykey_master_key: file-master-key\npolykey/db/neondb_url: postgres://localhost/polykey\n")
+	key := make([]byte, 32)
+	sealed, err := infra_secrets.SealSecrets(plaintext, key)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "secrets.sealed")
+	require.NoError(t, os.WriteFile(file, sealed, 0o600))
+
+	_, err = infra_secrets.NewLocal("/spounge/dev", file)
+	require.Error(t, err, "a sealed file needs its key")
+
+	t.Setenv(infra_secrets.LocalSecretsKeyEnv, base64.StdEncoding.EncodeToString(key))
+	t.Setenv(infra_secrets.LocalSecretEnvVar("polykey/kms/polykey_master_key"), "env-master-key")
+	require.Equal(t, "POLYKEY_SECRET_POLYKEY_TLS_SERVER_CERT_PEM", infra_secrets.LocalSecretEnvVar("polykey/tls/server-cert.pem"))
+
+	provider, err := infra_secrets.NewLocal("/spounge/dev/", file)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	value, err := provider.GetSecret(ctx, "/spounge/dev/polykey/kms/polykey_master_key")
+	require.NoError(t, err)
+	require.Equal(t, "env-master-key", value, "environment variables take precedence over the file")
+
+	value, err = provider.GetSecret(ctx, "/spounge/dev/polykey/db/neondb_url")
+	require.NoError(t, err)
+	require.Equal(t, "postgres://localhost/polykey", value)
+
+	value, err = provider.GetSecret(ctx, "/spounge/dev/polykey/kms/aws_kms_key_arn")
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	_, err = infra_secrets.OpenSecrets(sealed, make([]byte, 16))
+	require.Error(t, err)
+	other := make([]byte, 32)
+	other[0] = 1
+	_, err = infra_secrets.OpenSecrets(sealed, other)
+	require.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package kms
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/spounge-ai/polykey/internal/domain"
+)
+
+// Op names a method of kms.KMSProvider.
+type Op string
+
+const (
+	OpEncryptDEK  Op = "EncryptDEK"
+	OpDecryptDEK  Op = "DecryptDEK"
+	OpHealthCheck Op = "HealthCheck"
+)
+
+// Fault is how a single scripted call misbehaves. The zero Fault succeeds.
+type Fault struct {
+	// Delay is waited before the call runs, or until its context is done.
+	Delay time.Duration
+	// Err is returned instead of running the call.
+	Err error
+	// Corrupt flips a bit of the ciphertext: the one EncryptDEK returns, or the one
+	// DecryptDEK is given, which then fails as AWS KMS does.
+	Corrupt bool
+}
+
+// Throttle fails a call with the ThrottlingException AWS KMS returns when a request
+// rate quota is exceeded.
+func Throttle() Fault {
+	return Fault{Err: &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded", Fault: smithy.FaultClient}}
+}
+
+// Unavailable fails a call with the KMSInternalException AWS KMS returns when it fails.
+func Unavailable() Fault {
+	return Fault{Err: &smithy.GenericAPIError{Code: "KMSInternalException", Message: "internal error", Fault: smithy.FaultServer}}
+}
+
+// CorruptCiphertext corrupts the ciphertext of a call.
+func CorruptCiphertext() Fault {
+	return Fault{Corrupt: true}
+}
+
+// Slow delays a call by d.
+func Slow(d time.Duration) Fault {
+	return Fault{Delay: d}
+}
+
+// Fail fails a call with err.
+func Fail(err error) Fault {
+	return Fault{Err: err}
+}
+
+// Call is a recorded call of a MockKMSProvider.
+type Call struct {
+	Op       Op
+	KeyID    domain.KeyID
+	Fault    Fault
+	Err      error
+	Duration time.Duration
+}
+
+// MockKMSProvider is a kms.KMSProvider whose calls fail as scripted, for tests of how
+// callers handle KMS failures. DEKs are wrapped with AES-GCM under a random key bound to
+// the key ID, so unwrapping succeeds only for ciphertexts it produced for the same key.
+// Calls follow the faults scripted for their method in order, and succeed once the
+// script runs out. Every call is recorded.
+type MockKMSProvider struct {
+	aead cipher.AEAD
+
+	mu     sync.Mutex
+	script map[Op][]Fault
+	calls  []Call
+}
+
+// NewMockKMSProvider creates a MockKMSProvider with an empty script.
+func NewMockKMSProvider() *MockKMSProvider {
+	wrappingKey := make([]byte, 32)
+	if _, err := rand.Read(wrappingKey); err != nil {
+		panic(err)
+	}
+	block, err := aes.NewCipher(wrappingKey)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &MockKMSProvider{aead: aead, script: make(map[Op][]Fault)}
+}
+
+// Script appends faults to the script of op; the next calls of op follow them in order.
+func (m *MockKMSProvider) Script(op Op, faults ...Fault) *MockKMSProvider {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.script[op] = append(m.script[op], faults...)
+	return m
+}
+
+// Calls returns the calls made so far, oldest first.
+func (m *MockKMSProvider) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.calls)
+}
+
+// CallCount returns the number of calls of op made so far.
+func (m *MockKMSProvider) CallCount(op Op) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, call := range m.calls {
+		if call.Op == op {
+			count++
+		}
+	}
+	return count
+}
+
+// Reset clears the script and the recorded calls.
+func (m *MockKMSProvider) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.script = make(map[Op][]Fault)
+	m.calls = nil
+}
+
+func (m *MockKMSProvider) EncryptDEK(ctx context.Context, plaintextDEK []byte, key *domain.Key) ([]byte, error) {
+	var ciphertext []byte
+	err := m.call(ctx, OpEncryptDEK, key, func(fault Fault) error {
+		nonce := make([]byte, m.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		ciphertext = m.aead.Seal(nonce, nonce, plaintextDEK, []byte(key.ID.String()))
+		if fault.Corrupt {
+			ciphertext[len(ciphertext)-1] ^= 1
+		}
+		return nil
+	})
+	return ciphertext, err
+}
+
+func (m *MockKMSProvider) DecryptDEK(ctx context.Context, key *domain.Key) ([]byte, error) {
+	var plaintext []byte
+	err := m.call(ctx, OpDecryptDEK, key, func(fault Fault) error {
+		ciphertext := slices.Clone(key.EncryptedDEK)
+		if fault.Corrupt && len(ciphertext) > 0 {
+			ciphertext[len(ciphertext)-1] ^= 1
+		}
+		invalid := &smithy.GenericAPIError{Code: "InvalidCiphertextException", Message: "ciphertext is invalid", Fault: smithy.FaultClient}
+		size := m.aead.NonceSize()
+		if len(ciphertext) < size {
+			return invalid
+		}
+		var err error
+		plaintext, err = m.aead.Open(nil, ciphertext[:size], ciphertext[size:], []byte(key.ID.String()))
+		if err != nil {
+			return invalid
+		}
+		return nil
+	})
+	return plaintext, err
+}
+
+func (m *MockKMSProvider) HealthCheck(ctx context.Context) error {
+	return m.call(ctx, OpHealthCheck, nil, func(Fault) error { return nil })
+}
+
+// call runs fn with the next fault scripted for op and records the call.
+func (m *MockKMSProvider) call(ctx context.Context, op Op, key *domain.Key, fn func(Fault) error) error {
+	m.mu.Lock()
+	var fault Fault
+	if script := m.script[op]; len(script) > 0 {
+		fault, m.script[op] = script[0], script[1:]
+	}
+	m.mu.Unlock()
+
+	started := time.Now()
+	err := run(ctx, fault, fn)
+
+	call := Call{Op: op, Fault: fault, Err: err, Duration: time.Since(started)}
+	if key != nil {
+		call.KeyID = key.ID
+	}
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+	m.mu.Unlock()
+	return err
+}
+
+func run(ctx context.Context, fault Fault, fn func(Fault) error) error {
+	if fault.Delay > 0 {
+		timer := time.NewTimer(fault.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if fault.Err != nil {
+		return fmt.Errorf("mock kms: %w", fault.Err)
+	}
+	return fn(fault)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/spounge-ai/polykey/internal/domain"
+	app_errors "github.com/spounge-ai/polykey/internal/errors"
 	pk "github.com/spounge-ai/spounge-proto/gen/go/polykey/v2"
 )
 
@@ -24,7 +25,7 @@ func NewInMemoryKeyRepository() *InMemoryKeyRepository {
 
 func (r *InMemoryKeyRepository) GetKey(ctx context.Context, id domain.KeyID) (*domain.Key, error) {
 	val, ok := r.keys.Load(id.String())
-	if !ok {
+	if !ok || !domain.NamespaceVisible(ctx, val.(*domain.Key).Namespace) {
 		return nil, fmt.Errorf("key not found")
 	}
 	return val.(*domain.Key), nil
@@ -56,15 +57,28 @@ func (r *InMemoryKeyRepository) CreateKey(ctx context.Context, key *domain.Key)
 	return key, nil
 }
 
-func (r *InMemoryKeyRepository) ListKeys(ctx context.Context, lastCreatedAt *time.Time, limit int) ([]*domain.Key, error) {
+func (r *InMemoryKeyRepository) ListKeys(ctx context.Context, filter domain.KeyFilter, page domain.KeyPage, limit int) ([]*domain.Key, error) {
 	var keys []*domain.Key
 	r.keys.Range(func(key, value interface{}) bool {
-		keys = append(keys, value.(*domain.Key))
+		if k := value.(*domain.Key); domain.NamespaceVisible(ctx, k.Namespace) && filter.Matches(k) {
+			keys = append(keys, k)
+		}
 		return true
 	})
 	return keys, nil
 }
 
+func (r *InMemoryKeyRepository) CountKeys(ctx context.Context, namespace string) (int, error) {
+	count := 0
+	r.keys.Range(func(key, value interface{}) bool {
+		if k := value.(*domain.Key); k.Namespace == namespace && k.Status != domain.KeyStatusDestroyed {
+			count++
+		}
+		return true
+	})
+	return count, nil
+}
+
 func (r *InMemoryKeyRepository) UpdateKeyMetadata(ctx context.Context, id domain.KeyID, metadata *pk.KeyMetadata) error {
 	key, err := r.GetKey(ctx, id)
 	if err != nil {
@@ -75,6 +89,19 @@ func (r *InMemoryKeyRepository) UpdateKeyMetadata(ctx context.Context, id domain
 	return nil
 }
 
+func (r *InMemoryKeyRepository) UpdateKeyMetadataIfOwner(ctx context.Context, id domain.KeyID, version int32, owner string, metadata *pk.KeyMetadata) error {
+	key, err := r.GetKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.Version != version || key.Metadata.GetCreatorIdentity() != owner {
+		return fmt.Errorf("%w: key changed version or owner", app_errors.ErrConflict)
+	}
+	key.Metadata = metadata
+	r.keys.Store(id.String(), key)
+	return nil
+}
+
 func (r *InMemoryKeyRepository) RotateKey(ctx context.Context, id domain.KeyID, newEncryptedDEK []byte) (*domain.Key, error) {
 	key, err := r.GetKey(ctx, id)
 	if err != nil {
@@ -98,6 +125,18 @@ func (r *InMemoryKeyRepository) RevokeKey(ctx context.Context, id domain.KeyID)
 	return nil
 }
 
+func (r *InMemoryKeyRepository) RestoreKey(ctx context.Context, id domain.KeyID, revokedSince time.Time) (*domain.Key, error) {
+	key, err := r.GetKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	key.Status = domain.KeyStatusActive
+	key.UpdatedAt = time.Now()
+	key.RevokedAt = nil
+	r.keys.Store(id.String(), key)
+	return key, nil
+}
+
 func (r *InMemoryKeyRepository) GetKeyVersions(ctx context.Context, id domain.KeyID) ([]*domain.Key, error) {
 	// This is a simplified implementation. A real implementation would need to store versions.
 	key, err := r.GetKey(ctx, id)